@@ -0,0 +1,779 @@
+// Command api serves the latency-sensitive HTTP API. Long-running work is
+// submitted to the event bus and executed by cmd/worker.
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/index"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/tofu"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/trustroot"
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+	"github.com/salman-frs/keystone/apps/api/internal/cache/redis"
+	"github.com/salman-frs/keystone/apps/api/internal/compliance"
+	"github.com/salman-frs/keystone/apps/api/internal/diagnostics"
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+	"github.com/salman-frs/keystone/apps/api/internal/faults"
+	"github.com/salman-frs/keystone/apps/api/internal/history"
+	"github.com/salman-frs/keystone/apps/api/internal/jobs"
+	"github.com/salman-frs/keystone/apps/api/internal/messages"
+	"github.com/salman-frs/keystone/apps/api/internal/metering"
+	"github.com/salman-frs/keystone/apps/api/internal/quota"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+	"github.com/salman-frs/keystone/apps/api/internal/webhooks"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("api: %v", err)
+	}
+}
+
+func run() error {
+	addr := flag.String("addr", ":8080", "HTTP listen address")
+	dbPath := flag.String("db", storage.DefaultDatabasePath(), "SQLite database path")
+	migrationsDir := flag.String("migrations", "internal/storage/migrations", "Migrations directory")
+	uploadPolicy := flag.String("upload-policy", "", "Identity policy YAML uploaded attestations must satisfy; any Sigstore identity when empty")
+	tofuMode := flag.String("tofu", "off", "Pin each artifact's first verified signer: off, warn (publish an event on change) or enforce (reject changes)")
+	messagesDir := flag.String("messages", "", "Directory of message catalog translations (<locale>.yaml); English is built in")
+	flag.Parse()
+
+	db, err := storage.OpenDatabase(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// The API owns schema migrations; workers wait for it unless started with --migrate
+	migrateCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	err = storage.NewMigrationManager(db, *migrationsDir).MigrateWithLock(migrateCtx, "api", 5*time.Minute)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	busConfig := events.ConfigFromEnv()
+	busConfig.ClientName = "keystone-api"
+	bus, err := events.New(busConfig)
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+
+	limits, err := quota.LimitsFromEnv()
+	if err != nil {
+		return err
+	}
+	tenants, err := metering.TenantTokensFromEnv()
+	if err != nil {
+		return err
+	}
+
+	trust, closeCache, err := newTrustRoot(db)
+	if err != nil {
+		log.Printf("No Sigstore trust root (%v); attestation uploads are disabled", err)
+	} else {
+		defer closeCache()
+	}
+	mode, err := tofu.ParseMode(*tofuMode)
+	if err != nil {
+		return err
+	}
+	var policy attestation.IdentityPolicy
+	if *uploadPolicy != "" {
+		if policy, err = attestation.LoadIdentityPolicy(*uploadPolicy); err != nil {
+			return err
+		}
+		if err := compliance.RetainPolicy(context.Background(), history.NewStore(db), compliance.UploadPolicyKey, policy, time.Now()); err != nil {
+			log.Printf("Failed to retain upload policy: %v", err)
+		}
+	}
+
+	catalog := messages.Default()
+	if *messagesDir != "" {
+		if err := catalog.LoadDir(*messagesDir); err != nil {
+			return err
+		}
+	}
+
+	meter := metering.NewMeter(db)
+	server := &server{
+		bus:        bus,
+		meter:      meter,
+		quotas:     quota.NewEnforcer(db, meter, limits),
+		profiler:   diagnostics.NewProfiler(diagnostics.NewStore(db), diagnostics.DefaultConfig()),
+		queue:      github.NewQueue(nil, github.QueueConfig{DeadLetters: github.NewDeadLetterStore(db)}),
+		index:      index.NewStore(db),
+		trust:      trust,
+		policy:     policy,
+		pins:       tofu.NewStore(db, tofu.Config{Mode: mode}),
+		messages:   catalog,
+		adminToken: os.Getenv("KEYSTONE_ADMIN_TOKEN"),
+		tenants:    tenants,
+		acceptJobs: busConfig.Backend != events.BackendMemory && busConfig.Backend != "",
+	}
+	if faults.Enabled {
+		server.injector = faults.NewInjector()
+		log.Printf("Built with fault injection; faults can be set through /api/v1/admin/faults")
+	}
+	if !server.acceptJobs {
+		log.Printf("EVENT_BUS_BACKEND is %q; job submission is disabled until a shared bus is configured", busConfig.Backend)
+	}
+	if secret := os.Getenv(webhooks.SecretEnv); secret != "" {
+		if server.webhooks, err = webhooks.NewReceiver(secret); err != nil {
+			return err
+		}
+		server.webhooks.OnPackage(server.rescanPublishedImage)
+	}
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           server.routes(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if server.injector != nil {
+		go server.injector.Run(ctx, bus)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		log.Printf("API listening on %s", *addr)
+		errChan <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+// server holds the dependencies of the HTTP handlers
+type server struct {
+	bus        events.Bus
+	meter      *metering.Meter
+	quotas     *quota.Enforcer
+	profiler   *diagnostics.Profiler
+	queue      *github.Queue // Runs no requests; serves those other processes dead-lettered
+	index      *index.Store
+	trust      *trustroot.Manager         // Verifies uploads; nil disables them
+	policy     attestation.IdentityPolicy // Identity uploaded attestations must satisfy
+	pins       *tofu.Store                // Identities pinned on first use, per uploaded subject
+	messages   *messages.Catalog          // Renders report messages in the requester's locale
+	adminToken string                     // Bearer token that bypasses quotas and manages overrides
+	tenants    metering.TenantTokens      // Bearer tokens that authenticate tenants
+	acceptJobs bool
+	injector   *faults.Injector   // Nil unless built with the faults tag
+	webhooks   *webhooks.Receiver // Nil unless KEYSTONE_WEBHOOK_SECRET is set
+}
+
+// routes registers the HTTP handlers
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/api/v1/jobs", s.handleSubmitJob)
+	mux.HandleFunc("/api/v1/usage", s.handleUsage)
+	mux.HandleFunc("/api/v1/attestations", s.handleAttestations)
+	mux.HandleFunc("/api/v1/admin/quotas", s.handleQuotaOverride)
+	mux.Handle("/api/v1/admin/identity-pins", requireAdmin(http.HandlerFunc(s.handleIdentityPins)))
+	if s.injector != nil {
+		mux.Handle("/api/v1/admin/faults", requireAdmin(http.HandlerFunc(s.handleFaults)))
+	}
+	if s.webhooks != nil {
+		mux.Handle(webhookPath, s.webhooks)
+	}
+	mux.Handle("/debug/pprof/", requireAdmin(diagnostics.PprofHandler()))
+	profiles := requireAdmin(http.StripPrefix("/api/v1/admin/diagnostics/profiles", s.profiler.Handler()))
+	mux.Handle("/api/v1/admin/diagnostics/profiles", profiles)
+	mux.Handle("/api/v1/admin/diagnostics/profiles/", profiles)
+	deadLetters := requireAdmin(http.StripPrefix("/api/v1/admin/github/dead-letters", s.queue.DeadLetterHandler()))
+	mux.Handle("/api/v1/admin/github/dead-letters", deadLetters)
+	mux.Handle("/api/v1/admin/github/dead-letters/", deadLetters)
+	return s.withTenant(s.withQuota(mux))
+}
+
+// webhookPath receives GitHub webhook deliveries
+const webhookPath = "/api/v1/webhooks/github"
+
+// rescanPublishedImage submits a scan of each container image published to
+// GitHub Packages
+func (s *server) rescanPublishedImage(ctx context.Context, event *webhooks.PackageEvent) error {
+	image := event.Image()
+	if event.Action != webhooks.PackagePublished || image == "" {
+		return nil
+	}
+	if !s.acceptJobs {
+		log.Printf("Not scanning %s published to %s: no worker event bus configured", image, event.Repository.FullName)
+		return nil
+	}
+	_, err := jobs.Submit(ctx, s.bus, "keystone-api", jobs.KindScan, jobs.ScanPayload{
+		Image:   image,
+		Trigger: "webhook:" + event.Delivery,
+	})
+	return err
+}
+
+// tenantHeader selects the tenant usage is attributed to in shared deployments
+const tenantHeader = "X-Keystone-Tenant"
+
+// withTenant attributes each request to the tenant its bearer token
+// authenticates. Administrators may act for the tenant named in the tenant
+// header; anyone else naming a tenant they aren't authenticated as is refused,
+// and anonymous requests share the default tenant.
+func (s *server) withTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get(tenantHeader)
+		if !s.isAdmin(r) {
+			authenticated := s.tenants.Tenant(bearerToken(r))
+			if tenant != "" && tenant != authenticated {
+				writeError(w, http.StatusForbidden, "tenant token required")
+				return
+			}
+			tenant = authenticated
+		}
+
+		ctx := metering.WithTenant(r.Context(), tenant)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withQuota marks administrator requests as exempt from quotas and applies the
+// per-minute request limit to everyone else
+func (s *server) withQuota(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Webhook deliveries come from GitHub, authenticated by signature
+		if r.URL.Path == "/health" || r.URL.Path == webhookPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if s.isAdmin(r) {
+			next.ServeHTTP(w, r.WithContext(quota.WithOverride(r.Context())))
+			return
+		}
+
+		if err := s.quotas.AllowRequest(r.Context()); err != nil {
+			writeQuotaError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin rejects requests without the admin bearer token; withQuota
+// marks those that carry it
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !quota.Overridden(r.Context()) {
+			writeError(w, http.StatusForbidden, "admin token required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAdmin reports whether the request carries the admin bearer token
+func (s *server) isAdmin(r *http.Request) bool {
+	return s.adminToken != "" && subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(s.adminToken)) == 1
+}
+
+// bearerToken returns the request's bearer token, or "" when it has none
+func bearerToken(r *http.Request) string {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return ""
+	}
+	return token
+}
+
+// handleHealth reports liveness for container health checks
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// submitJobRequest is the body of POST /api/v1/jobs
+type submitJobRequest struct {
+	Kind     string          `json:"kind"`
+	Artifact string          `json:"artifact,omitempty"` // Counted against the tenant's tracked artifact quota
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// handleSubmitJob queues a job for a worker and returns immediately
+func (s *server) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.acceptJobs {
+		writeError(w, http.StatusServiceUnavailable, "no worker event bus configured")
+		return
+	}
+
+	var req submitJobRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 10<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	switch req.Kind {
+	case jobs.KindScan, jobs.KindVerification, jobs.KindAdvisorySync:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown job kind %q", req.Kind))
+		return
+	}
+
+	if err := jobs.Admit(r.Context(), s.quotas, req.Kind); err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+	if req.Artifact != "" {
+		if err := s.quotas.TrackArtifact(r.Context(), req.Artifact); err != nil {
+			writeQuotaError(w, err)
+			return
+		}
+	}
+
+	job, err := jobs.Submit(r.Context(), s.bus, "keystone-api", req.Kind, req.Payload)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// handleUsage reports the calling tenant's metered usage, defaulting to the
+// last 30 days. Callers need their tenant's token, or the admin token to read
+// the usage of the tenant named in the tenant header.
+func (s *server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	// Anonymous requests share the default tenant, whose usage isn't theirs to read
+	if !quota.Overridden(r.Context()) && s.tenants.Tenant(bearerToken(r)) == "" {
+		writeError(w, http.StatusUnauthorized, "tenant token required")
+		return
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			writeError(w, http.StatusBadRequest, "from must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			writeError(w, http.StatusBadRequest, "to must be an RFC 3339 timestamp")
+			return
+		}
+	}
+
+	usage, err := s.meter.Usage(r.Context(), metering.TenantFromContext(r.Context()), from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// newTrustRoot creates the trust root manager uploads are verified against,
+// from SIGSTORE_TRUSTED_ROOT or the Sigstore TUF repository
+func newTrustRoot(db *sql.DB) (*trustroot.Manager, func(), error) {
+	config, err := trustroot.ConfigFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+	config.History = history.NewStore(db)
+	if len(config.PinnedRoot) > 0 {
+		manager, err := trustroot.NewManager(config, nil)
+		return manager, func() {}, err
+	}
+
+	// Replicas share the fetched trust root through Redis when it's configured
+	var l3 cache.L3CacheClient
+	closeL3 := func() {}
+	if redisConfig, err := redis.ConfigFromEnv(); err == nil {
+		client := redis.NewClient(redisConfig)
+		l3, closeL3 = client, func() { client.Close() }
+	} else if !errors.Is(err, redis.ErrNotConfigured) {
+		return nil, nil, err
+	}
+	hierCache, err := cache.NewHierarchicalCache(cache.DefaultCacheConfig(), db, l3)
+	if err != nil {
+		closeL3()
+		return nil, nil, err
+	}
+	manager, err := trustroot.NewManager(config, hierCache)
+	if err != nil {
+		hierCache.Close()
+		closeL3()
+		return nil, nil, err
+	}
+	return manager, func() { hierCache.Close(); closeL3() }, nil
+}
+
+// handleAttestations lists attestations on GET and accepts uploads on POST
+func (s *server) handleAttestations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listAttestations(w, r)
+	case http.MethodPost:
+		s.uploadAttestation(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// listAttestations lists the indexed attestations about an artifact digest
+// (?digest=sha256:...), optionally filtered by ?predicate_type=
+func (s *server) listAttestations(w http.ResponseWriter, r *http.Request) {
+
+	digest := r.URL.Query().Get("digest")
+	if alg, value, found := strings.Cut(digest, ":"); !found || alg == "" || value == "" {
+		writeError(w, http.StatusBadRequest, "digest must be in alg:hex form")
+		return
+	}
+
+	entries, err := s.index.ForSubject(r.Context(), digest, r.URL.Query().Get("predicate_type"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// uploadRequest is the body of POST /api/v1/attestations
+type uploadRequest struct {
+	Subject string              `json:"subject,omitempty"` // Artifact name; defaults to the digest
+	Digest  string              `json:"digest"`            // alg:hex of the artifact the bundle attests to
+	Bundle  *attestation.Bundle `json:"bundle"`
+}
+
+// uploadAttestation verifies a bundle against the server's trust root and
+// upload policy before indexing it. Rejected uploads get the verification
+// report, carrying the SIGN_ code and remediation, with a 422.
+func (s *server) uploadAttestation(w http.ResponseWriter, r *http.Request) {
+	if s.trust == nil {
+		writeError(w, http.StatusServiceUnavailable, "no Sigstore trust root configured")
+		return
+	}
+
+	var req uploadRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 10<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if req.Subject == "" {
+		req.Subject = req.Digest
+	}
+	subject, err := attestation.NewSubject(req.Subject, req.Digest)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	trust, err := s.trust.TrustRoot(r.Context())
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	result, err := attestation.VerifyUpload(req.Bundle, subject, trust, s.policy)
+	report := attestation.NewReport(req.Bundle, result)
+	if err != nil {
+		failed := events.VerificationFailed{
+			Target:    req.Subject,
+			ErrorCode: result.ErrorCode,
+			Message:   result.ErrorMessage,
+		}
+		if result.Message != nil {
+			failed.MessageID, failed.MessageParams = string(result.Message.ID), result.Message.Params
+		}
+		s.publish(r.Context(), events.TypeVerificationFailed, failed)
+		s.writeReport(w, r, http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	// Identities are pinned per named artifact; a bare digest names nothing to pin to
+	if req.Subject != req.Digest {
+		pin, err := s.pins.Check(r.Context(), subject.Name, result, attestation.RecordID(req.Bundle.Envelope.Signatures[0].Sig))
+		if err != nil && attestation.CodeOf(err) != attestation.CodeIdentityChanged {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err != nil {
+			s.publishIdentityChanged(r.Context(), pin, result)
+			report = attestation.NewReport(req.Bundle, result)
+			if !result.Valid {
+				s.writeReport(w, r, http.StatusUnprocessableEntity, report)
+				return
+			}
+		}
+	}
+
+	if err := s.index.RecordVerified(r.Context(), req.Bundle, result); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.writeReport(w, r, http.StatusCreated, report)
+}
+
+// writeReport writes a verification report with its messages in the locale
+// the request's Accept-Language prefers
+func (s *server) writeReport(w http.ResponseWriter, r *http.Request, status int, report *attestation.Report) {
+	locale := s.messages.Negotiate(r.Header.Get("Accept-Language"))
+	report.Localize(s.messages, locale)
+	w.Header().Set("Content-Language", locale)
+	writeJSON(w, status, report)
+}
+
+// publishIdentityChanged alerts that a pinned identity changed. Failing to
+// publish is logged rather than failing the upload.
+func (s *server) publishIdentityChanged(ctx context.Context, pin *tofu.Pin, result *attestation.VerificationResult) {
+	event, err := events.NewEvent(events.TypeIdentityChanged, "keystone-api", events.IdentityChanged{
+		Scope:          pin.Scope,
+		PinnedIssuer:   pin.Issuer,
+		PinnedIdentity: pin.Identity,
+		Issuer:         result.Certificate.Issuer,
+		Identity:       tofu.PinnedIdentity(result.Certificate),
+		FirstSeen:      pin.FirstSeen,
+		Enforced:       s.pins.Mode() == tofu.ModeEnforce,
+	})
+	if err == nil {
+		err = s.bus.Publish(ctx, event)
+	}
+	if err != nil {
+		log.Printf("Failed to publish identity change for %s: %v", pin.Scope, err)
+	}
+}
+
+// publishAdminAction records an administrator's change for audit
+func (s *server) publishAdminAction(r *http.Request, action, target, detail string) {
+	s.publish(r.Context(), events.TypeAdminAction, events.AdminAction{
+		Action:     action,
+		Target:     target,
+		Tenant:     metering.TenantFromContext(r.Context()),
+		RemoteAddr: r.RemoteAddr,
+		Detail:     detail,
+	})
+}
+
+// publish sends an audit or security event. Failing to publish is logged
+// rather than failing the request.
+func (s *server) publish(ctx context.Context, eventType string, data interface{}) {
+	event, err := events.NewEvent(eventType, "keystone-api", data)
+	if err == nil {
+		err = s.bus.Publish(ctx, event)
+	}
+	if err != nil {
+		log.Printf("Failed to publish %s: %v", eventType, err)
+	}
+}
+
+// handleIdentityPins shows (GET) and resets (DELETE) the identity pinned for
+// ?scope=, e.g. after a legitimate change of release workflow
+func (s *server) handleIdentityPins(w http.ResponseWriter, r *http.Request) {
+	scope := r.URL.Query().Get("scope")
+	if scope == "" {
+		writeError(w, http.StatusBadRequest, "scope is required")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		pin, err := s.pins.Get(r.Context(), scope)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if pin == nil {
+			writeError(w, http.StatusNotFound, "no identity pinned for "+scope)
+			return
+		}
+		writeJSON(w, http.StatusOK, pin)
+
+	case http.MethodDelete:
+		if err := s.pins.Reset(r.Context(), scope); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.publishAdminAction(r, "identity_pin.reset", scope, "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// quotaOverrideRequest is the body of PUT /api/v1/admin/quotas
+type quotaOverrideRequest struct {
+	Tenant string       `json:"tenant"`
+	Limits quota.Limits `json:"limits"`
+}
+
+// handleQuotaOverride lets administrators inspect, set and clear per-tenant limits
+func (s *server) handleQuotaOverride(w http.ResponseWriter, r *http.Request) {
+	if !quota.Overridden(r.Context()) {
+		writeError(w, http.StatusForbidden, "admin token required")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tenant := r.URL.Query().Get("tenant")
+		if tenant == "" {
+			writeError(w, http.StatusBadRequest, "tenant is required")
+			return
+		}
+		limits, err := s.quotas.Limits(r.Context(), tenant)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, quotaOverrideRequest{Tenant: tenant, Limits: limits})
+
+	case http.MethodPut:
+		var req quotaOverrideRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		if req.Tenant == "" {
+			writeError(w, http.StatusBadRequest, "tenant is required")
+			return
+		}
+		if err := s.quotas.SetOverride(r.Context(), req.Tenant, req.Limits); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		limits, _ := json.Marshal(req.Limits)
+		s.publishAdminAction(r, "quota.override_set", req.Tenant, string(limits))
+		writeJSON(w, http.StatusOK, req)
+
+	case http.MethodDelete:
+		tenant := r.URL.Query().Get("tenant")
+		if tenant == "" {
+			writeError(w, http.StatusBadRequest, "tenant is required")
+			return
+		}
+		if err := s.quotas.DeleteOverride(r.Context(), tenant); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.publishAdminAction(r, "quota.override_deleted", tenant, "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// defaultFaultTTL bounds faults set without an expiry, so a forgotten
+// experiment doesn't outlive the session
+const defaultFaultTTL = 10 * time.Minute
+
+// handleFaults lists (GET), sets (PUT) and clears (DELETE, optionally for one
+// ?target=) injected faults, broadcasting each change to the workers
+func (s *server) handleFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, faults.Changed{Faults: s.injector.Faults()})
+
+	case http.MethodPut:
+		var fault faults.Fault
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&fault); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		if fault.ExpiresAt.IsZero() {
+			fault.ExpiresAt = time.Now().Add(defaultFaultTTL).UTC()
+		}
+		if err := s.injector.Set(fault); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.injector.Publish(r.Context(), s.bus, "keystone-api"); err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("fault set locally but not broadcast: %v", err))
+			return
+		}
+		detail, _ := json.Marshal(fault)
+		s.publishAdminAction(r, "faults.set", fault.Target, string(detail))
+		writeJSON(w, http.StatusOK, fault)
+
+	case http.MethodDelete:
+		target := r.URL.Query().Get("target")
+		s.injector.Clear(target)
+		if err := s.injector.Publish(r.Context(), s.bus, "keystone-api"); err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("faults cleared locally but not broadcast: %v", err))
+			return
+		}
+		s.publishAdminAction(r, "faults.cleared", target, "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// writeQuotaError responds 429 with Retry-After for quotas that replenish and
+// 403 for those that do not
+func writeQuotaError(w http.ResponseWriter, err error) {
+	var exceeded *quota.ExceededError
+	if !errors.As(err, &exceeded) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	status := http.StatusForbidden
+	if exceeded.Temporary() {
+		status = http.StatusTooManyRequests
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(exceeded.RetryAfter.Seconds()))))
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"error": exceeded.Error(),
+		"quota": exceeded.Quota,
+		"limit": exceeded.Limit,
+		"used":  exceeded.Used,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/diagnostics"
+	"github.com/salman-frs/keystone/apps/api/internal/metering"
+	"github.com/salman-frs/keystone/apps/api/internal/quota"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// The routes are only reachable from package main, so unlike the packages
+// under tests/unit this is tested in place
+func TestDeadLettersRequireAdmin(t *testing.T) {
+	db, err := storage.OpenDatabase(filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, storage.NewMigrationManager(db, "../../internal/storage/migrations").MigrateWithLock(context.Background(), "test", time.Minute))
+
+	store := github.NewDeadLetterStore(db)
+	_, err = store.Add(context.Background(), github.DeadLetter{RequestID: "widgets", EnqueuedAt: time.Now(), FailedAt: time.Now()})
+	require.NoError(t, err)
+
+	meter := metering.NewMeter(db)
+	s := &server{
+		meter:      meter,
+		quotas:     quota.NewEnforcer(db, meter, quota.DefaultLimits()),
+		profiler:   diagnostics.NewProfiler(diagnostics.NewStore(db), diagnostics.DefaultConfig()),
+		queue:      github.NewQueue(nil, github.QueueConfig{DeadLetters: store}),
+		adminToken: "admin-token",
+	}
+	routes := s.routes()
+
+	serve := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		routes.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	for _, token := range []string{"", "not-the-admin-token"} {
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/v1/admin/github/dead-letters", token))
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/api/v1/admin/github/dead-letters/1/requeue", token))
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodDelete, "/api/v1/admin/github/dead-letters", token))
+	}
+	count, err := store.Count(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/v1/admin/github/dead-letters", "admin-token"))
+	// Requests dead-lettered by other processes can't be requeued by the API
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/api/v1/admin/github/dead-letters/1/requeue", "admin-token"))
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/api/v1/admin/github/dead-letters/1", "admin-token"))
+}
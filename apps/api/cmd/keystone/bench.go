@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/salman-frs/keystone/apps/api/internal/loadtest"
+)
+
+// runBench implements "keystone bench", running the verification load test
+// matrix and writing its report
+func runBench(args []string) error {
+	defaults := loadtest.DefaultConfig()
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	artifacts := flags.Int("artifacts", defaults.Artifacts, "Synthetic signed artifacts to generate")
+	verifications := flags.Int("verifications", defaults.Verifications, "Verification requests per scenario")
+	hotFraction := flags.Float64("hot-fraction", defaults.HotFraction, "Share of requests for the hottest tenth of artifacts")
+	concurrency := flags.String("concurrency", "1,8,32", "Comma-separated parallel verifier counts")
+	l1Items := flags.String("l1-items", "100,1000,10000", "Comma-separated in-memory cache sizes")
+	databases := flags.String("databases", strings.Join(defaults.Databases, ","), "Comma-separated databases: sqlite-file, sqlite-memory")
+	index := flags.Bool("index", defaults.Index, "Record each verification in the attestation index")
+	migrationsDir := flags.String("migrations", defaults.MigrationsDir, "Migrations directory")
+	dir := flags.String("dir", "", "Directory for file databases (defaults to a temporary directory)")
+	seed := flags.Int64("seed", defaults.Seed, "Seed for the request sequence")
+	out := flags.String("out", "", "Write the JSON report to this file instead of stdout")
+	markdown := flags.String("markdown", "", "Also write a Markdown summary to this file")
+	flags.Parse(args)
+
+	config := defaults
+	config.Artifacts = *artifacts
+	config.Verifications = *verifications
+	config.HotFraction = *hotFraction
+	config.Databases = strings.Split(*databases, ",")
+	config.Index = *index
+	config.MigrationsDir = *migrationsDir
+	config.Dir = *dir
+	config.Seed = *seed
+	var err error
+	if config.Concurrency, err = parseIntList(*concurrency); err != nil {
+		return fmt.Errorf("invalid --concurrency: %w", err)
+	}
+	if config.L1MaxItems, err = parseIntList(*l1Items); err != nil {
+		return fmt.Errorf("invalid --l1-items: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "Signing %d synthetic artifacts and running %d scenarios\n", config.Artifacts, len(config.Scenarios()))
+	report, err := loadtest.Run(ctx, config, func(result loadtest.Result) {
+		fmt.Fprintf(os.Stderr, "%-32s %8.0f verifications/s  p95 %-10s cache hits %5.1f%%  busy %d  failures %d\n",
+			result.Scenario.Name, result.Throughput, result.Latency.P95, result.CacheHitRatio*100,
+			result.BusyErrors, result.Failures)
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := report.JSON()
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		fmt.Println(string(data))
+	} else if err := os.WriteFile(*out, data, 0644); err != nil {
+		return err
+	}
+	if *markdown != "" {
+		if err := os.WriteFile(*markdown, []byte(report.Markdown()), 0644); err != nil {
+			return err
+		}
+	}
+
+	for _, result := range report.Results {
+		if result.Failures > 0 {
+			return fmt.Errorf("scenario %s had %d failed verifications", result.Scenario.Name, result.Failures)
+		}
+	}
+	return nil
+}
+
+// parseIntList parses a comma-separated list of positive integers
+func parseIntList(value string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("%d is not positive", n)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
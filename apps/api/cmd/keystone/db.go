@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+// openDatabase opens the SQLite database and applies pending migrations
+func openDatabase(path, migrationsDir string) (*sql.DB, error) {
+	db, err := storage.OpenDatabase(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	migrations := storage.NewMigrationManager(db, migrationsDir)
+	if err := migrations.MigrateWithLock(ctx, "keystone-cli", 5*time.Minute); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// runDigest implements "keystone digest", printing in-toto subjects for local
+// artifacts so they can be attested without a registry
+func runDigest(args []string) error {
+	flags := flag.NewFlagSet("digest", flag.ExitOnError)
+	algorithms := flags.String("algorithm", attestation.DigestSHA256, "Comma-separated digest algorithms: sha256, sha512")
+	oci := flags.Bool("oci", false, "Treat paths as OCI image layouts (directories or tar archives) and print a subject per manifest")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: keystone digest [--algorithm sha256,sha512] [--oci] <path>...")
+	}
+
+	subjects := []attestation.Subject{}
+	for _, path := range flags.Args() {
+		if *oci {
+			manifests, err := attestation.DigestOCILayout(path)
+			if err != nil {
+				return err
+			}
+			subjects = append(subjects, manifests...)
+			continue
+		}
+
+		subject, err := attestation.DigestPath(path, strings.Split(*algorithms, ",")...)
+		if err != nil {
+			return err
+		}
+		subjects = append(subjects, subject)
+	}
+
+	printJSON(subjects)
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/exceptions"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+const exceptionUsage = `usage: keystone exception <command> [flags]
+
+Commands:
+  request   Request acceptance of a finding for a scope
+  approve   Approve a pending exception as one of its owners
+  reject    Reject a pending exception as one of its owners
+  revoke    Withdraw a pending or approved exception
+  show      Print an exception with its approvals and audit trail`
+
+// runException dispatches "keystone exception" subcommands
+func runException(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(exceptionUsage)
+	}
+
+	switch args[0] {
+	case "request":
+		return runExceptionRequest(args[1:])
+	case "approve", "reject", "revoke":
+		return runExceptionDecision(args[0], args[1:])
+	case "show":
+		return runExceptionShow(args[1:])
+	default:
+		return fmt.Errorf("unknown exception command %q\n\n%s", args[0], exceptionUsage)
+	}
+}
+
+// exceptionFlags are the flags shared by every exception command
+type exceptionFlags struct {
+	dbPath        *string
+	migrationsDir *string
+	actor         *string
+}
+
+func newExceptionFlags(flags *flag.FlagSet) exceptionFlags {
+	return exceptionFlags{
+		dbPath:        flags.String("db", storage.DefaultDatabasePath(), "SQLite database path"),
+		migrationsDir: flags.String("migrations", "internal/storage/migrations", "Migrations directory"),
+		actor:         flags.String("as", os.Getenv("USER"), "Name recorded as the actor in the audit trail"),
+	}
+}
+
+// open opens the database, requiring an actor for the audit trail
+func (f exceptionFlags) open() (*sql.DB, error) {
+	if *f.actor == "" {
+		return nil, fmt.Errorf("--as is required when USER is not set")
+	}
+	return openDatabase(*f.dbPath, *f.migrationsDir)
+}
+
+// runExceptionRequest implements "keystone exception request"
+func runExceptionRequest(args []string) error {
+	flags := flag.NewFlagSet("exception request", flag.ExitOnError)
+	common := newExceptionFlags(flags)
+	scope := flags.String("scope", "", "Artifact or repository the finding is accepted for")
+	finding := flags.String("finding", "", "Finding ID (source:rule:subject)")
+	severity := flags.String("severity", "", "Severity of the finding: CRITICAL, HIGH, MEDIUM, LOW or INFO")
+	reason := flags.String("reason", "", "Why the risk is accepted")
+	expires := flags.Duration("expires", 0, "How long the acceptance lasts (defaults to the maximum)")
+	owners := flags.String("owners", "", "JSON ownership map naming the approvers of each scope")
+	threshold := flags.String("threshold", string(exceptions.DefaultConfig().Threshold), "Severity at which approvals are required")
+	approvals := flags.Int("approvals", exceptions.DefaultConfig().Approvals, "Approvals required at or above the threshold")
+	flags.Parse(args)
+
+	req := exceptions.Request{
+		Scope:       *scope,
+		FindingID:   *finding,
+		Severity:    findings.Severity(strings.ToUpper(*severity)),
+		Reason:      *reason,
+		RequestedBy: *common.actor,
+	}
+	if *expires > 0 {
+		req.ExpiresAt = time.Now().Add(*expires)
+	}
+
+	ownership := &exceptions.OwnershipMap{}
+	if *owners != "" {
+		var err error
+		if ownership, err = exceptions.LoadOwnershipMap(*owners); err != nil {
+			return err
+		}
+	}
+
+	db, err := common.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	config := exceptions.DefaultConfig()
+	config.Threshold = findings.Severity(strings.ToUpper(*threshold))
+	config.Approvals = *approvals
+	exception, err := exceptions.NewStore(db, ownership, config).Request(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	printJSON(exception)
+	return nil
+}
+
+// runExceptionDecision implements "keystone exception approve|reject|revoke"
+func runExceptionDecision(command string, args []string) error {
+	flags := flag.NewFlagSet("exception "+command, flag.ExitOnError)
+	common := newExceptionFlags(flags)
+	comment := flags.String("comment", "", "Comment recorded with the decision")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: keystone exception %s [flags] <id>", command)
+	}
+	id := flags.Arg(0)
+
+	db, err := common.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	store := exceptions.NewStore(db, nil, exceptions.DefaultConfig())
+	var exception *exceptions.Exception
+	switch command {
+	case "approve":
+		exception, err = store.Approve(ctx, id, *common.actor, *comment)
+	case "reject":
+		exception, err = store.Reject(ctx, id, *common.actor, *comment)
+	default:
+		exception, err = store.Revoke(ctx, id, *common.actor, *comment)
+	}
+	if err != nil {
+		return err
+	}
+	printJSON(exception)
+	return nil
+}
+
+// runExceptionShow implements "keystone exception show"
+func runExceptionShow(args []string) error {
+	flags := flag.NewFlagSet("exception show", flag.ExitOnError)
+	dbPath := flags.String("db", storage.DefaultDatabasePath(), "SQLite database path")
+	migrationsDir := flags.String("migrations", "internal/storage/migrations", "Migrations directory")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: keystone exception show [flags] <id>")
+	}
+
+	db, err := openDatabase(*dbPath, *migrationsDir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	store := exceptions.NewStore(db, nil, exceptions.DefaultConfig())
+	exception, err := store.Get(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	audit, err := store.Audit(ctx, exception.ID)
+	if err != nil {
+		return err
+	}
+	printJSON(struct {
+		*exceptions.Exception
+		Audit []exceptions.AuditEntry `json:"audit"`
+	}{exception, audit})
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/salman-frs/keystone/apps/api/internal/scaffold"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// runInit implements "keystone init"
+func runInit(args []string) error {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	dir := flags.String("dir", ".", "Repository to inspect and scaffold")
+	repository := flags.String("repo", "", "GitHub repository as owner/name (defaults to the origin remote)")
+	branch := flags.String("default-branch", "", "Branch the workflow and policy target (defaults to main, or the GitHub default with --pr)")
+	force := flags.Bool("force", false, "Overwrite existing files")
+	dryRun := flags.Bool("dry-run", false, "Print the generated files instead of writing them")
+	openPR := flags.Bool("pr", false, "Open a pull request with the files instead of writing them locally")
+	head := flags.String("pr-branch", "keystone-init", "Branch created for the pull request")
+	flags.Parse(args)
+
+	project, err := scaffold.Inspect(*dir)
+	if err != nil {
+		return err
+	}
+	if *repository != "" {
+		owner, name, found := strings.Cut(*repository, "/")
+		if !found || owner == "" || name == "" {
+			return fmt.Errorf("--repo must be owner/name, got %q", *repository)
+		}
+		project.Owner, project.Name = owner, name
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var client *github.Client
+	if *openPR {
+		if project.Owner == "" {
+			return fmt.Errorf("no GitHub remote found; pass --repo owner/name")
+		}
+		githubConfig, err := github.ConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		client = github.NewClient(githubConfig)
+		if *branch == "" {
+			if project.DefaultBranch, err = client.DefaultBranch(ctx, project.Owner, project.Name); err != nil {
+				return err
+			}
+		}
+	}
+	if *branch != "" {
+		project.DefaultBranch = *branch
+	}
+
+	files, err := scaffold.Generate(project)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *dryRun:
+		for _, file := range files {
+			fmt.Printf("--- %s\n%s\n", file.Path, file.Content)
+		}
+		return nil
+	case *openPR:
+		change := github.PullRequestChange{
+			Base:  project.DefaultBranch,
+			Head:  *head,
+			Title: "Adopt Keystone supply chain checks",
+			Body: "Adds a workflow that builds, generates an SBOM, scans, signs and verifies on every push, " +
+				"with the default verification policy and keystone.yaml generated by `keystone init`.",
+		}
+		for _, file := range files {
+			change.Files = append(change.Files, github.FileChange{Path: file.Path, Content: file.Content})
+		}
+		pull, err := client.OpenPullRequest(ctx, project.Owner, project.Name, change)
+		if err != nil {
+			return err
+		}
+		printJSON(pull)
+		return nil
+	}
+
+	if err := scaffold.Write(*dir, files, *force); err != nil {
+		return fmt.Errorf("%w (use --force to overwrite)", err)
+	}
+	for _, file := range files {
+		fmt.Println("wrote", file.Path)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Command keystone is the operator CLI for the Keystone security platform
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: keystone <command> [arguments]
+
+Commands:
+  bench           Measure verification throughput, cache effectiveness and database contention
+  digest          Print in-toto subjects for local files, directories or OCI layouts
+  exception       Request, approve and audit vulnerability exceptions
+  init            Scaffold a Keystone workflow, policy and keystone.yaml for a repository
+  sync backfill   Backfill historical GitHub security advisories into the local store
+  verify          Verify a bundle against an identity policy and print a report
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "bench":
+		err = runBench(os.Args[2:])
+	case "digest":
+		err = runDigest(os.Args[2:])
+	case "exception":
+		err = runException(os.Args[2:])
+	case "init":
+		err = runInit(os.Args[2:])
+	case "sync":
+		err = runSync(os.Args[2:])
+	case "verify":
+		err = runVerify(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/advisories"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// runSync dispatches "keystone sync" subcommands
+func runSync(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: keystone sync backfill [flags]")
+	}
+
+	switch args[0] {
+	case "backfill":
+		return runBackfill(args[1:])
+	default:
+		return fmt.Errorf("unknown sync command %q", args[0])
+	}
+}
+
+// runBackfill implements "keystone sync backfill"
+func runBackfill(args []string) error {
+	flags := flag.NewFlagSet("sync backfill", flag.ExitOnError)
+	ecosystem := flags.String("ecosystem", "", "Advisory ecosystem to backfill (go, npm, pip, maven, ...)")
+	since := flags.String("since", "", "Start of the backfill range (YYYY, YYYY-MM or YYYY-MM-DD)")
+	until := flags.String("until", "", "End of the backfill range (defaults to now)")
+	perPage := flags.Int("per-page", 100, "Advisories requested per page")
+	reserve := flags.Int("reserve", 1000, "Requests to leave untouched for interactive traffic")
+	planOnly := flags.Bool("plan", false, "Print the request plan and exit")
+	dbPath := flags.String("db", storage.DefaultDatabasePath(), "SQLite database path")
+	migrationsDir := flags.String("migrations", "internal/storage/migrations", "Migrations directory")
+	flags.Parse(args)
+
+	if *ecosystem == "" || *since == "" {
+		return fmt.Errorf("--ecosystem and --since are required")
+	}
+
+	sinceTime, err := parseRangeDate(*since)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	config := advisories.DefaultBackfillConfig(*ecosystem, sinceTime)
+	config.PerPage = *perPage
+	config.Reserve = *reserve
+	if *until != "" {
+		if config.Until, err = parseRangeDate(*until); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+
+	githubConfig, err := github.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase(*dbPath, *migrationsDir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Interrupts cancel the run; progress is checkpointed and resumed on the next invocation
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := github.NewClient(githubConfig)
+	defer client.Close()
+	backfiller := advisories.NewBackfiller(client, advisories.NewStore(db), config)
+
+	plan, err := backfiller.Plan(ctx)
+	if err != nil {
+		return err
+	}
+	printJSON(plan)
+	if *planOnly {
+		return nil
+	}
+
+	checkpoint, err := backfiller.Run(ctx)
+	if checkpoint != nil {
+		printJSON(checkpoint)
+	}
+	if err != nil && checkpoint != nil && checkpoint.Status == advisories.StatusInterrupted {
+		return fmt.Errorf("backfill interrupted; rerun the same command to resume: %w", err)
+	}
+	return err
+}
+
+// parseRangeDate accepts YYYY, YYYY-MM or YYYY-MM-DD in UTC
+func parseRangeDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected YYYY, YYYY-MM or YYYY-MM-DD, got %q", value)
+}
+
+// printJSON writes a value as indented JSON to stdout
+func printJSON(value interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/trustroot"
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+	"github.com/salman-frs/keystone/apps/api/internal/compliance"
+	"github.com/salman-frs/keystone/apps/api/internal/history"
+	"github.com/salman-frs/keystone/apps/api/internal/messages"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+	"github.com/salman-frs/keystone/apps/api/internal/scaffold"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+// runVerify implements "keystone verify"
+func runVerify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	bundlePath := flags.String("bundle", "", "Bundle to verify")
+	blobPath := flags.String("blob", "", "Blob the bundle must attest to, if any")
+	policyPath := flags.String("policy", scaffold.PolicyPath, "Identity policy YAML")
+	format := flags.String("format", "json", "Report printed to stdout: json or markdown")
+	markdownPath := flags.String("markdown", "", "Also write the Markdown report to this file, e.g. $GITHUB_STEP_SUMMARY")
+	at := flags.String("at", "", "Evaluate compliance as of this time (RFC 3339 or YYYY-MM-DD) from retained history")
+	sbomPath := flags.String("sbom", "", "With --at, SBOM whose components are checked against the advisories known then")
+	dbPath := flags.String("db", storage.DefaultDatabasePath(), "SQLite database caching the TUF trust root, and with --at holding the history")
+	migrationsDir := flags.String("migrations", "internal/storage/migrations", "Migrations directory")
+	policyKey := flags.String("policy-key", compliance.UploadPolicyKey, "With --at, retained policy to verify against")
+	trustKey := flags.String("trust-key", "", "With --at, retained trust root: a TUF mirror or \"pinned\" (defaults to the configured one)")
+	locale := flags.String("locale", messages.DefaultLocale, "Locale failure and policy messages are rendered in")
+	messagesDir := flags.String("messages", "", "Directory of message catalog translations (<locale>.yaml)")
+	flags.Parse(args)
+
+	if *bundlePath == "" {
+		return fmt.Errorf("--bundle is required")
+	}
+	if *at != "" {
+		return verifyAt(*at, *bundlePath, *sbomPath, *dbPath, *migrationsDir, *policyKey, *trustKey)
+	}
+	if *format != "json" && *format != "markdown" {
+		return fmt.Errorf("--format must be json or markdown, got %q", *format)
+	}
+
+	policy, err := attestation.LoadIdentityPolicy(*policyPath)
+	if err != nil {
+		return err
+	}
+	bundle, err := attestation.ReadBundle(*bundlePath)
+	if err != nil {
+		return err
+	}
+
+	trust, err := loadTrustRoot(*dbPath, *migrationsDir)
+	if err != nil {
+		return err
+	}
+
+	var result *attestation.VerificationResult
+	var verifyErr error
+	if *blobPath != "" {
+		blob, err := attestation.DigestFile(*blobPath)
+		if err != nil {
+			return err
+		}
+		result, verifyErr = attestation.VerifyBlob(bundle, blob, trust, policy)
+	} else {
+		result, verifyErr = attestation.VerifyBundle(bundle, trust, policy)
+	}
+
+	catalog := messages.Default()
+	if *messagesDir != "" {
+		if err := catalog.LoadDir(*messagesDir); err != nil {
+			return err
+		}
+	}
+	report := attestation.NewReport(bundle, result)
+	report.Localize(catalog, *locale)
+	if *markdownPath != "" {
+		// Appended, as job summaries collect output from several steps
+		file, err := os.OpenFile(*markdownPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open Markdown report: %w", err)
+		}
+		report.WriteMarkdown(file)
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write Markdown report: %w", err)
+		}
+	}
+
+	if *format == "markdown" {
+		report.WriteMarkdown(os.Stdout)
+	} else {
+		printJSON(report)
+	}
+
+	if verifyErr != nil {
+		return fmt.Errorf("verification failed: %w", verifyErr)
+	}
+	return nil
+}
+
+// loadTrustRoot returns the configured Sigstore trust root: the pinned
+// SIGSTORE_TRUSTED_ROOT, or the root fetched through TUF and cached in the
+// database. The root a bundle carries is never trusted.
+func loadTrustRoot(dbPath, migrationsDir string) (attestation.TrustRoot, error) {
+	config, err := trustroot.ConfigFromEnv()
+	if err != nil {
+		return attestation.TrustRoot{}, err
+	}
+	if len(config.PinnedRoot) > 0 {
+		return trustroot.ParseTrustedRoot(config.PinnedRoot)
+	}
+
+	db, err := openDatabase(dbPath, migrationsDir)
+	if err != nil {
+		return attestation.TrustRoot{}, err
+	}
+	defer db.Close()
+	hierCache, err := cache.NewHierarchicalCache(cache.DefaultCacheConfig(), db, nil)
+	if err != nil {
+		return attestation.TrustRoot{}, err
+	}
+	defer hierCache.Close()
+	manager, err := trustroot.NewManager(config, hierCache)
+	if err != nil {
+		return attestation.TrustRoot{}, err
+	}
+	return manager.TrustRoot(context.Background())
+}
+
+// verifyAt implements "keystone verify --at": whether the bundle, and the
+// SBOM if given, were compliant with the data retained as of a past time
+func verifyAt(at, bundlePath, sbomPath, dbPath, migrationsDir, policyKey, trustKey string) error {
+	when, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		// A bare date means the end of that day
+		day, dayErr := time.Parse("2006-01-02", at)
+		if dayErr != nil {
+			return fmt.Errorf("--at must be RFC 3339 or YYYY-MM-DD, got %q", at)
+		}
+		when = day.Add(24*time.Hour - time.Second)
+	}
+
+	if trustKey == "" {
+		// The key the API retains its configured trust root under
+		config, err := trustroot.ConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		trustKey = config.Mirror
+		if len(config.PinnedRoot) > 0 {
+			trustKey = trustroot.PinnedHistoryKey
+		}
+	}
+
+	bundle, err := attestation.ReadBundle(bundlePath)
+	if err != nil {
+		return err
+	}
+	query := compliance.Query{At: when, Bundle: bundle, PolicyKey: policyKey, TrustKey: trustKey}
+	if sbomPath != "" {
+		data, err := os.ReadFile(sbomPath)
+		if err != nil {
+			return fmt.Errorf("failed to read SBOM: %w", err)
+		}
+		if query.SBOM, err = sbom.Decode(data); err != nil {
+			return err
+		}
+	}
+
+	db, err := openDatabase(dbPath, migrationsDir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := compliance.NewEvaluator(history.NewStore(db)).Evaluate(context.Background(), query)
+	if err != nil {
+		return err
+	}
+	printJSON(report)
+
+	if !report.Compliant {
+		return fmt.Errorf("not compliant as of %s", report.At.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,577 @@
+// Command worker runs background jobs (scans, verifications, advisory syncs)
+// consumed from the event bus, keeping long-running work out of the API process
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/advisories"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/index"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/monitor"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/trustroot"
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+	"github.com/salman-frs/keystone/apps/api/internal/cache/redis"
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+	"github.com/salman-frs/keystone/apps/api/internal/exceptions"
+	"github.com/salman-frs/keystone/apps/api/internal/faults"
+	"github.com/salman-frs/keystone/apps/api/internal/history"
+	"github.com/salman-frs/keystone/apps/api/internal/ingest"
+	"github.com/salman-frs/keystone/apps/api/internal/jobs"
+	"github.com/salman-frs/keystone/apps/api/internal/metering"
+	"github.com/salman-frs/keystone/apps/api/internal/metrics"
+	"github.com/salman-frs/keystone/apps/api/internal/pkgverify"
+	"github.com/salman-frs/keystone/apps/api/internal/plugins"
+	"github.com/salman-frs/keystone/apps/api/internal/quota"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+	"github.com/salman-frs/keystone/apps/api/internal/siem"
+	"github.com/salman-frs/keystone/apps/api/internal/slo"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/actionscache"
+	"github.com/salman-frs/keystone/apps/api/pkg/oidc"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("worker: %v", err)
+	}
+}
+
+func run() error {
+	hostname, _ := os.Hostname()
+
+	dbPath := flag.String("db", storage.DefaultDatabasePath(), "SQLite database path shared with the API")
+	migrationsDir := flag.String("migrations", "internal/storage/migrations", "Migrations directory")
+	migrate := flag.Bool("migrate", false, "Apply pending migrations instead of waiting for the API to apply them")
+	schemaTimeout := flag.Duration("schema-timeout", 2*time.Minute, "How long to wait for the schema to reach the latest version")
+	name := flag.String("name", "worker-"+hostname, "Worker name reported in job results")
+	concurrency := flag.Int("concurrency", 4, "Jobs executed in parallel")
+	jobTimeout := flag.Duration("job-timeout", 30*time.Minute, "Maximum duration of a single job")
+	mavenKeys := flag.String("maven-keys", "", "Directory of armored PGP keys for Maven signature verification")
+	mavenTrust := flag.String("maven-trust", "", "Comma-separated groupPrefix=fingerprint pins for Maven signers")
+	npmProvenance := flag.Bool("npm-provenance", false, "Verify the Sigstore provenance of npm packages published with --provenance")
+	pypiProvenance := flag.Bool("pypi-provenance", false, "Verify the PEP 740 attestations of PyPI distributions")
+	publisherPolicy := flag.String("publisher-policy", "", "YAML mapping npm and PyPI package names to the identity policy their provenance must satisfy")
+	rekorWatch := flag.String("rekor-watch", "", "Comma-separated owner/repo whose workflow identities are monitored in Rekor for entries Keystone didn't produce")
+	rekorInterval := flag.Duration("rekor-interval", time.Minute, "How often the Rekor monitor polls for new entries")
+	ingestSources := flag.String("ingest", "", "Comma-separated s3://bucket/prefix or gs://bucket/prefix drop buckets to ingest bundles and SBOMs from")
+	ingestInterval := flag.Duration("ingest-interval", time.Minute, "How often drop buckets are polled")
+	ingestPolicy := flag.String("ingest-policy", "", "Identity policy YAML ingested bundles must satisfy")
+	syslogAddr := flag.String("syslog-addr", "", "host:port of a syslog collector to export audit and security events to")
+	syslogFormat := flag.String("syslog-format", siem.FormatRFC5424, "Exported event format: rfc5424 or cef")
+	syslogFraming := flag.String("syslog-framing", siem.FramingOctetCounting, "Syslog message framing: octet-counting or newline")
+	syslogTLS := flag.Bool("syslog-tls", false, "Send events to the syslog collector over TLS")
+	syslogCA := flag.String("syslog-ca", "", "PEM CA certificates the syslog collector's TLS certificate must chain to; system roots when empty")
+	syslogEvents := flag.String("syslog-events", "", "Comma-separated event types to export; audit and security events when empty")
+	pluginsPath := flag.String("plugins", "", "YAML listing plugin executables providing finding enrichers, predicate generators and policy gates")
+	historyRetention := flag.Duration("history-retention", 0, "Prune advisory, policy and trust root history older than this; 0 keeps it all")
+	packages := flag.String("packages", "", "Comma-separated owner/package GHCR container packages whose expired attestations are deleted")
+	packageRetention := flag.Duration("package-retention", 0, "Delete attestation, signature and SBOM versions of -packages older than this; 0 keeps them all")
+	untaggedRetention := flag.Duration("untagged-retention", 0, "Also delete untagged versions of -packages older than this; 0 keeps them all")
+	advisorySync := flag.String("advisory-sync", "", "Comma-separated advisory ecosystems (go, npm, pip, ...) kept current by incremental sync")
+	advisorySyncInterval := flag.Duration("advisory-sync-interval", time.Hour, "How often -advisory-sync ecosystems fetch advisories updated since their last sync")
+	githubBudgets := flag.String("github-budgets", "", "Comma-separated consumer=share reservations of the GitHub rate limit, e.g. advisory-sync=0.3,attestation-checks=0.5")
+	l3Backend := flag.String("l3-cache", l3Auto, "Shared cache for verification results: auto, redis (KEYSTONE_REDIS_URL), actions (the Actions cache service) or none")
+	metricsAddr := flag.String("metrics-addr", "", "host:port serving Prometheus metrics at /metrics, e.g. :9090; disabled when empty")
+	flag.Parse()
+
+	busConfig := events.ConfigFromEnv()
+	busConfig.ClientName = *name
+	if busConfig.Backend == "" || busConfig.Backend == events.BackendMemory {
+		return fmt.Errorf("worker requires a shared event bus; set EVENT_BUS_BACKEND to %s or %s",
+			events.BackendNATS, events.BackendKafka)
+	}
+
+	db, err := storage.OpenDatabase(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	schemaCtx, cancel := context.WithTimeout(ctx, *schemaTimeout)
+	migrations := storage.NewMigrationManager(db, *migrationsDir)
+	if *migrate {
+		err = migrations.MigrateWithLock(schemaCtx, *name, 5*time.Minute)
+	} else {
+		log.Printf("Waiting for schema migrations to be applied")
+		err = migrations.WaitForSchema(schemaCtx, time.Second)
+	}
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	bus, err := events.New(busConfig)
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+
+	// Only builds with the faults tag apply faults set through the API
+	var injector *faults.Injector
+	if faults.Enabled {
+		injector = faults.NewInjector()
+		go injector.Run(ctx, bus)
+		log.Printf("Built with fault injection; faults set through the API apply to this worker")
+	}
+
+	config := jobs.DefaultWorkerConfig(*name)
+	config.Concurrency = *concurrency
+	config.JobTimeout = *jobTimeout
+	worker := jobs.NewWorker(bus, config)
+	meter := metering.NewMeter(db)
+	worker.SetMeter(meter)
+
+	limits, err := quota.LimitsFromEnv()
+	if err != nil {
+		return err
+	}
+	worker.SetAdmitter(quota.NewEnforcer(db, meter, limits))
+
+	var registry *metrics.Registry
+	if *metricsAddr != "" {
+		registry = metrics.NewRegistry()
+		go serveMetrics(ctx, *metricsAddr, registry)
+	}
+
+	if githubConfig, err := github.ConfigFromEnv(); err == nil {
+		githubConfig.Metrics = registry
+		githubConfig.OnRequest = meter.GitHubRequestHook()
+		githubConfig.Transport = injector.Transport(faults.TargetGitHub, githubConfig.Transport)
+		if *githubBudgets != "" {
+			shares, err := github.ParseBudgets(*githubBudgets)
+			if err != nil {
+				return err
+			}
+			if githubConfig.Budget, err = github.NewBudgetManager(shares); err != nil {
+				return err
+			}
+		}
+		// Revalidating cached responses with ETags spares the rate limit
+		githubCache, err := cache.NewHierarchicalCache(cache.DefaultCacheConfig(), db, nil)
+		if err != nil {
+			return err
+		}
+		defer githubCache.Close()
+		githubConfig.Cache = githubCache
+		client := github.NewClient(githubConfig)
+		worker.Register(jobs.KindAdvisorySync, jobs.AdvisorySyncRunner(client, advisories.NewStore(db)))
+		if *advisorySync != "" {
+			var syncers []*advisories.Syncer
+			for _, ecosystem := range strings.Split(*advisorySync, ",") {
+				config := advisories.DefaultSyncConfig(strings.TrimSpace(ecosystem))
+				syncers = append(syncers, advisories.NewSyncer(client, advisories.NewStore(db), config))
+			}
+			go syncAdvisories(ctx, syncers, *advisorySyncInterval)
+		}
+		if *packages != "" {
+			policy := github.RetentionPolicy{MaxAge: *packageRetention, UntaggedAge: *untaggedRetention}
+			go prunePackages(ctx, client, strings.Split(*packages, ","), policy)
+		}
+	} else if errors.Is(err, github.ErrNoCredentials) {
+		log.Printf("No GitHub credentials set; advisory_sync jobs will be rejected")
+	} else {
+		return err
+	}
+
+	if *mavenKeys != "" || *npmProvenance || *pypiProvenance || *pluginsPath != "" {
+		verifiers, closeVerifiers, err := newVerifiers(db, *mavenKeys, *mavenTrust, *npmProvenance, *pypiProvenance, *publisherPolicy, *l3Backend, injector)
+		if err != nil {
+			return err
+		}
+		defer closeVerifiers()
+		if *pluginsPath != "" {
+			host, err := newPluginHost(*pluginsPath)
+			if err != nil {
+				return err
+			}
+			defer host.Close()
+			verifiers.Plugins = host
+		}
+		worker.Register(jobs.KindVerification, jobs.VerificationRunner(verifiers, exceptions.NewStore(db, nil, exceptions.DefaultConfig())))
+	}
+
+	slos := slo.NewTracker(slo.DefaultConfig())
+	worker.SetSLOTracker(slos)
+	go slos.Run(ctx, bus, *name, time.Minute)
+
+	if *rekorWatch != "" {
+		rekor, err := newRekorMonitor(db, *rekorWatch, injector)
+		if err != nil {
+			return err
+		}
+		go rekor.Run(ctx, bus, *name, *rekorInterval)
+	}
+
+	if *ingestSources != "" {
+		ingesters, closeIngest, err := newIngesters(db, *ingestSources, *ingestPolicy)
+		if err != nil {
+			return err
+		}
+		defer closeIngest()
+		for _, ingester := range ingesters {
+			go ingester.Run(ctx, *ingestInterval)
+		}
+	}
+
+	if *syslogAddr != "" {
+		siemConfig := siem.DefaultConfig(*syslogAddr)
+		siemConfig.Format = *syslogFormat
+		siemConfig.Framing = *syslogFraming
+		if *syslogEvents != "" {
+			siemConfig.EventTypes = strings.Split(*syslogEvents, ",")
+		}
+		if *syslogTLS {
+			if siemConfig.TLS, err = siem.TLSConfig(*syslogCA); err != nil {
+				return err
+			}
+		}
+		exporter, err := siem.NewExporter(siemConfig)
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := exporter.Run(ctx, bus); err != nil {
+				log.Printf("Syslog export: %v", err)
+			}
+		}()
+		log.Printf("Exporting %s events to %s as %s", strings.Join(siemConfig.EventTypes, ", "), *syslogAddr, siemConfig.Format)
+	}
+
+	if *historyRetention > 0 {
+		go pruneHistory(ctx, history.NewStore(db), *historyRetention)
+	}
+
+	if err := worker.Start(); err != nil {
+		return err
+	}
+	log.Printf("Worker %s consuming %s jobs from %s (concurrency %d)",
+		*name, strings.Join(worker.Kinds(), ", "), busConfig.Backend, config.Concurrency)
+
+	<-ctx.Done()
+	log.Printf("Shutting down; waiting for in-flight jobs")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return worker.Stop(shutdownCtx)
+}
+
+// newIngesters creates an ingester per drop bucket, verifying bundles against
+// the trust root from SIGSTORE_TRUSTED_ROOT or the Sigstore TUF repository
+func newIngesters(db *sql.DB, sources, policyPath string) ([]*ingest.Ingester, func(), error) {
+	config := ingest.DefaultConfig()
+	if policyPath != "" {
+		policy, err := attestation.LoadIdentityPolicy(policyPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		config.Policy = policy
+	}
+
+	trust, closeCache, err := newTrustManager(db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ingesters []*ingest.Ingester
+	for _, source := range strings.Split(sources, ",") {
+		if source = strings.TrimSpace(source); source == "" {
+			continue
+		}
+		bucket, prefix, err := ingest.OpenBucket(source, ingest.CredentialsFromEnv())
+		if err != nil {
+			closeCache()
+			return nil, nil, err
+		}
+		ingesters = append(ingesters, ingest.New(bucket, prefix, trust, index.NewStore(db), sbom.NewStore(db), config))
+		log.Printf("Ingesting bundles and SBOMs dropped in %s", source)
+	}
+	return ingesters, closeCache, nil
+}
+
+// newTrustManager loads the Sigstore trust root from SIGSTORE_TRUSTED_ROOT or
+// the Sigstore TUF repository, caching TUF metadata unless the root is pinned
+func newTrustManager(db *sql.DB) (*trustroot.Manager, func(), error) {
+	trustConfig, err := trustroot.ConfigFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+	trustConfig.History = history.NewStore(db)
+	closeCache := func() {}
+	var trustCache *cache.HierarchicalCache
+	if len(trustConfig.PinnedRoot) == 0 {
+		if trustCache, err = cache.NewHierarchicalCache(cache.DefaultCacheConfig(), db, nil); err != nil {
+			return nil, nil, err
+		}
+		closeCache = func() { trustCache.Close() }
+	}
+	trust, err := trustroot.NewManager(trustConfig, trustCache)
+	if err != nil {
+		closeCache()
+		return nil, nil, err
+	}
+	return trust, closeCache, nil
+}
+
+// pruneHistory hourly deletes history no longer needed to evaluate compliance
+// within the retention period
+func pruneHistory(ctx context.Context, store *history.Store, retention time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if pruned, err := store.Prune(ctx, time.Now().Add(-retention)); err != nil {
+			log.Printf("Failed to prune history: %v", err)
+		} else if pruned > 0 {
+			log.Printf("Pruned %d history snapshots", pruned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncAdvisories periodically stores the advisories updated since each
+// syncer's high-water mark, keeping the store current for offline matching
+func syncAdvisories(ctx context.Context, syncers []*advisories.Syncer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, syncer := range syncers {
+			if cp, err := syncer.Run(ctx); err != nil {
+				log.Printf("Failed to sync %s: %v", syncer.JobID(), err)
+			} else if cp.FetchedCount > 0 {
+				log.Printf("Synced %d advisories in %s, now current to %s", cp.FetchedCount, cp.JobID, cp.RangeEnd.Format(time.RFC3339))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// serveMetrics serves the registry at /metrics until ctx is done
+func serveMetrics(ctx context.Context, addr string, registry *metrics.Registry) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry.Handler())
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	log.Printf("Serving metrics on %s/metrics", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Metrics server: %v", err)
+	}
+}
+
+// prunePackages daily deletes the container package versions the policy
+// expires, such as attestations of images long since replaced
+func prunePackages(ctx context.Context, client *github.Client, packages []string, policy github.RetentionPolicy) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for {
+		for _, pkg := range packages {
+			owner, name, found := strings.Cut(strings.TrimSpace(pkg), "/")
+			if !found {
+				log.Printf("Ignoring package %q; expected owner/package", pkg)
+				continue
+			}
+			if pruned, err := client.PrunePackageVersions(ctx, owner, name, policy); err != nil {
+				log.Printf("Failed to prune %s: %v", pkg, err)
+			} else if len(pruned) > 0 {
+				log.Printf("Deleted %d expired versions of %s", len(pruned), pkg)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// newRekorMonitor watches the GitHub Actions identities of the repositories in
+// the Sigstore environment's transparency log
+func newRekorMonitor(db *sql.DB, repositories string, injector *faults.Injector) (*monitor.Monitor, error) {
+	sigstore, err := attestation.SigstoreConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	config := monitor.DefaultConfig(sigstore.RekorURL)
+	for _, repository := range strings.Split(repositories, ",") {
+		if repository = strings.TrimSpace(repository); repository != "" {
+			config.Watch = append(config.Watch, attestation.IdentityPolicy{
+				Issuer:     oidc.IssuerFromEnv(),
+				Repository: repository,
+			})
+		}
+	}
+	log.Printf("Monitoring %s for entries signed by %s", sigstore.RekorURL, repositories)
+	client := monitor.NewRekorClient(sigstore.RekorURL)
+	client.Client.Transport = injector.Transport(faults.TargetRekor, client.Client.Transport)
+	return monitor.New(client, monitor.NewStore(db),
+		index.NewStore(db).HasRekorEntry, config)
+}
+
+// L3 cache backends selected with -l3-cache
+const (
+	l3Auto    = "auto"
+	l3Actions = "actions"
+	l3Redis   = "redis"
+	l3None    = "none"
+)
+
+// l3Cache returns the shared cache level verification results are kept in.
+// Redis shares them between replicas of a long-running deployment; the
+// Actions cache service shares them with the other jobs of the repository
+// the worker runs in. auto picks Redis when KEYSTONE_REDIS_URL is set, then
+// the Actions cache inside a job, and otherwise none.
+func l3Cache(backend string) (cache.L3CacheClient, func(), error) {
+	if backend == l3Auto || backend == l3Redis {
+		config, err := redis.ConfigFromEnv()
+		if err == nil {
+			client := redis.NewClient(config)
+			log.Printf("Sharing verification results through Redis at %s", strings.Join(config.Addrs, ", "))
+			return client, func() { client.Close() }, nil
+		}
+		if backend == l3Redis || !errors.Is(err, redis.ErrNotConfigured) {
+			return nil, nil, err
+		}
+	}
+	if backend == l3Auto || backend == l3Actions {
+		config, err := actionscache.ConfigFromEnv()
+		if err == nil {
+			log.Printf("Sharing verification results through the Actions cache")
+			return actionscache.NewClient(config), func() {}, nil
+		}
+		if backend == l3Actions {
+			return nil, nil, err
+		}
+	}
+	if backend != l3Auto && backend != l3None {
+		return nil, nil, fmt.Errorf("unknown L3 cache %q; use %s, %s, %s or %s", backend, l3Auto, l3Redis, l3Actions, l3None)
+	}
+	return nil, func() {}, nil
+}
+
+// newVerifiers builds the enabled package verifiers backed by the shared cache
+func newVerifiers(db *sql.DB, mavenKeys, mavenTrust string, npm, pypi bool, policyPath, l3Backend string, injector *faults.Injector) (jobs.Verifiers, func(), error) {
+	var verifiers jobs.Verifiers
+	l3, closeL3, err := l3Cache(l3Backend)
+	if err != nil {
+		return verifiers, nil, err
+	}
+	resultCache, err := cache.NewHierarchicalCache(cache.DefaultCacheConfig(), db, l3)
+	if err != nil {
+		closeL3()
+		return verifiers, nil, err
+	}
+	closers := []func(){func() { resultCache.Close() }, closeL3}
+	closeAll := func() {
+		for _, closer := range closers {
+			closer()
+		}
+	}
+
+	if mavenKeys != "" {
+		if verifiers.Maven, err = newMavenVerifier(mavenKeys, mavenTrust, resultCache, injector); err != nil {
+			closeAll()
+			return verifiers, nil, err
+		}
+	}
+
+	if npm || pypi {
+		var policies pkgverify.PublisherPolicies
+		if policyPath != "" {
+			if policies, err = pkgverify.LoadPublisherPolicies(policyPath); err != nil {
+				closeAll()
+				return verifiers, nil, err
+			}
+		}
+		trust, closeTrust, err := newTrustManager(db)
+		if err != nil {
+			closeAll()
+			return verifiers, nil, err
+		}
+		closers = append(closers, closeTrust)
+
+		if npm {
+			config := pkgverify.DefaultNpmConfig()
+			config.Policies = policies
+			config.Transport = injector.Transport(faults.TargetRegistry, config.Transport)
+			verifiers.Npm = pkgverify.NewNpmVerifier(config, trust, injector.WrapCache(resultCache))
+			log.Printf("Verifying npm provenance from %s", config.RegistryURL)
+		}
+		if pypi {
+			config := pkgverify.DefaultPyPIConfig()
+			config.Policies = policies
+			config.Transport = injector.Transport(faults.TargetRegistry, config.Transport)
+			verifiers.PyPI = pkgverify.NewPyPIVerifier(config, trust, injector.WrapCache(resultCache))
+			log.Printf("Verifying PyPI attestations from %s", config.RegistryURL)
+		}
+	}
+
+	return verifiers, closeAll, nil
+}
+
+// newPluginHost starts the plugins listed in the configuration file
+func newPluginHost(path string) (*plugins.Host, error) {
+	config, err := plugins.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	host, err := plugins.New(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := host.Start(); err != nil {
+		return nil, err
+	}
+	log.Printf("Started %d plugins from %s", len(config.Plugins), path)
+	return host, nil
+}
+
+// newMavenVerifier builds a Maven signature verifier from a key directory and trust pins
+func newMavenVerifier(keysDir, trust string, resultCache *cache.HierarchicalCache, injector *faults.Injector) (*pkgverify.MavenVerifier, error) {
+	keyring := pkgverify.NewKeyring()
+	if err := keyring.LoadDir(keysDir); err != nil {
+		return nil, err
+	}
+	for _, pin := range strings.Split(trust, ",") {
+		if pin == "" {
+			continue
+		}
+		prefix, fingerprint, found := strings.Cut(pin, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid --maven-trust entry %q, expected groupPrefix=fingerprint", pin)
+		}
+		keyring.Trust(strings.TrimSpace(prefix), strings.TrimSpace(fingerprint))
+	}
+
+	config := pkgverify.DefaultMavenConfig()
+	config.Transport = injector.Transport(faults.TargetRegistry, config.Transport)
+	return pkgverify.NewMavenVerifier(config, keyring, injector.WrapCache(resultCache)), nil
+}
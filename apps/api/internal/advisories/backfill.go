@@ -0,0 +1,302 @@
+package advisories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// rateLimitWindow is the length of GitHub's primary rate limit window
+const rateLimitWindow = time.Hour
+
+// BackfillConfig holds advisory backfill configuration
+type BackfillConfig struct {
+	Ecosystem      string
+	Since          time.Time
+	Until          time.Time // Zero means now
+	PerPage        int
+	Reserve        int     // Requests left untouched for interactive traffic
+	PagesPerWindow float64 // Expected pages per monthly window, used for planning
+	ResetBuffer    time.Duration
+}
+
+// DefaultBackfillConfig returns default backfill configuration
+func DefaultBackfillConfig(ecosystem string, since time.Time) BackfillConfig {
+	return BackfillConfig{
+		Ecosystem:      ecosystem,
+		Since:          since,
+		PerPage:        100,
+		Reserve:        1000, // Matches the client's 20% rate limit buffer
+		PagesPerWindow: 1.5,
+		ResetBuffer:    5 * time.Second,
+	}
+}
+
+// Plan describes the expected cost of a backfill against the current quota
+type Plan struct {
+	JobID              string        `json:"job_id"`
+	Resuming           bool          `json:"resuming"`
+	TotalWindows       int           `json:"total_windows"`
+	RemainingWindows   int           `json:"remaining_windows"`
+	EstimatedRequests  int           `json:"estimated_requests"`
+	RateLimitLimit     int           `json:"rate_limit_limit"`
+	RateLimitRemaining int           `json:"rate_limit_remaining"`
+	RateLimitReset     time.Time     `json:"rate_limit_reset"`
+	UsableNow          int           `json:"usable_now"`
+	RateLimitWindows   int           `json:"rate_limit_windows"`
+	EstimatedDuration  time.Duration `json:"estimated_duration"`
+}
+
+// Backfiller pages through historical advisories month by month, checkpointing after every page
+type Backfiller struct {
+	client *github.Client
+	store  *Store
+	config BackfillConfig
+}
+
+// NewBackfiller creates a new advisory backfiller
+func NewBackfiller(client *github.Client, store *Store, config BackfillConfig) *Backfiller {
+	if config.Until.IsZero() {
+		config.Until = time.Now().UTC()
+	}
+	config.Since = monthStart(config.Since)
+	return &Backfiller{
+		client: client,
+		store:  store,
+		config: config,
+	}
+}
+
+// JobID returns the checkpoint key for this backfill
+func (b *Backfiller) JobID() string {
+	return fmt.Sprintf("backfill:%s:%s", b.config.Ecosystem, b.config.Since.Format("2006-01-02"))
+}
+
+// Plan estimates request counts and how many rate limit windows the backfill will span
+func (b *Backfiller) Plan(ctx context.Context) (*Plan, error) {
+	rateLimit, err := b.client.GetRateLimit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rate limit: %w", err)
+	}
+
+	cp, err := b.store.LoadCheckpoint(ctx, b.JobID())
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		JobID:              b.JobID(),
+		TotalWindows:       countWindows(b.config.Since, b.config.Until),
+		RateLimitLimit:     rateLimit.Limit,
+		RateLimitRemaining: rateLimit.Remaining,
+		RateLimitReset:     rateLimit.Reset,
+	}
+
+	start := b.config.Since
+	if cp != nil {
+		plan.Resuming = true
+		start = cp.WindowStart
+		if cp.Status == StatusCompleted {
+			start = b.config.Until
+		}
+	}
+	plan.RemainingWindows = countWindows(start, b.config.Until)
+	plan.EstimatedRequests = int(math.Ceil(float64(plan.RemainingWindows) * b.config.PagesPerWindow))
+	plan.UsableNow = max(0, rateLimit.Remaining-b.config.Reserve)
+
+	switch {
+	case plan.EstimatedRequests == 0:
+		plan.RateLimitWindows = 0
+	case plan.EstimatedRequests <= plan.UsableNow:
+		plan.RateLimitWindows = 1
+	default:
+		perWindow := max(1, rateLimit.Limit-b.config.Reserve)
+		overflow := plan.EstimatedRequests - plan.UsableNow
+		extraWindows := int(math.Ceil(float64(overflow) / float64(perWindow)))
+		plan.RateLimitWindows = 1 + extraWindows
+		plan.EstimatedDuration = time.Until(rateLimit.Reset) + time.Duration(extraWindows-1)*rateLimitWindow
+	}
+
+	return plan, nil
+}
+
+// Run executes or resumes the backfill until the range is exhausted or ctx is cancelled
+func (b *Backfiller) Run(ctx context.Context) (*Checkpoint, error) {
+	ctx = github.WithConsumer(ctx, github.ConsumerAdvisorySync)
+	cp, err := b.store.LoadCheckpoint(ctx, b.JobID())
+	if err != nil {
+		return nil, err
+	}
+
+	if cp == nil {
+		cp = &Checkpoint{
+			JobID:       b.JobID(),
+			JobType:     "backfill",
+			Ecosystem:   b.config.Ecosystem,
+			WindowStart: b.config.Since,
+			RangeEnd:    b.config.Until,
+		}
+	} else if cp.Status == StatusCompleted {
+		return cp, nil
+	} else {
+		log.Printf("Resuming %s from %s (%d advisories fetched so far)",
+			cp.JobID, cp.WindowStart.Format("2006-01"), cp.FetchedCount)
+	}
+
+	cp.Status = StatusRunning
+	cp.LastError = ""
+	if err := b.store.SaveCheckpoint(ctx, cp); err != nil {
+		return nil, err
+	}
+
+	for cp.WindowStart.Before(cp.RangeEnd) {
+		if err := waitForQuota(ctx, b.client, b.store, cp, b.config.Reserve, b.config.ResetBuffer); err != nil {
+			return stop(ctx, b.store, cp, err)
+		}
+
+		windowEnd := nextWindow(cp.WindowStart, cp.RangeEnd)
+		page, err := b.client.ListAdvisories(ctx, github.AdvisoryQuery{
+			Ecosystem: b.config.Ecosystem,
+			Published: fmt.Sprintf("%s..%s",
+				cp.WindowStart.Format("2006-01-02"), windowEnd.Add(-time.Second).Format("2006-01-02")),
+			PerPage: b.config.PerPage,
+			After:   cp.Cursor,
+		})
+		if waited, err := waitForBudget(ctx, b.store, cp, err, b.config.ResetBuffer); waited {
+			if err != nil {
+				return stop(ctx, b.store, cp, err)
+			}
+			continue
+		}
+		cp.RequestCount++
+		if err != nil {
+			return stop(ctx, b.store, cp, err)
+		}
+
+		cp.FetchedCount += len(page.Advisories)
+		if page.NextCursor != "" {
+			cp.Cursor = page.NextCursor
+		} else {
+			cp.WindowStart = windowEnd
+			cp.Cursor = ""
+		}
+
+		if err := b.store.SavePage(ctx, b.config.Ecosystem, page.Advisories, cp); err != nil {
+			return stop(ctx, b.store, cp, err)
+		}
+	}
+
+	cp.Status = StatusCompleted
+	if err := b.store.SaveCheckpoint(ctx, cp); err != nil {
+		return cp, err
+	}
+
+	return cp, nil
+}
+
+// waitForQuota sleeps until the rate limit resets when remaining requests fall to the reserve
+func waitForQuota(ctx context.Context, client *github.Client, store *Store, cp *Checkpoint, reserve int, resetBuffer time.Duration) error {
+	for {
+		rateLimit := client.Stats().LastRateLimit
+		if rateLimit == nil || rateLimit.Remaining > reserve {
+			return nil
+		}
+
+		wait := time.Until(rateLimit.Reset) + resetBuffer
+		if wait <= 0 {
+			wait = resetBuffer
+		}
+
+		cp.Status = StatusWaiting
+		if err := store.SaveCheckpoint(ctx, cp); err != nil {
+			return err
+		}
+		log.Printf("%s: %d requests remaining (reserve %d), waiting %s for rate limit reset",
+			cp.JobID, rateLimit.Remaining, reserve, wait.Round(time.Second))
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if _, err := client.GetRateLimit(ctx); err != nil {
+			return fmt.Errorf("failed to refresh rate limit: %w", err)
+		}
+		cp.Status = StatusRunning
+	}
+}
+
+// waitForBudget sleeps until the next rate limit window when the request
+// failed because the sync spent its share of this one, reporting whether it
+// waited
+func waitForBudget(ctx context.Context, store *Store, cp *Checkpoint, cause error, resetBuffer time.Duration) (bool, error) {
+	var budgetErr *github.BudgetError
+	if !errors.As(cause, &budgetErr) {
+		return false, nil
+	}
+	wait := time.Until(budgetErr.Reset) + resetBuffer
+	if wait <= 0 {
+		wait = resetBuffer
+	}
+
+	cp.Status = StatusWaiting
+	if err := store.SaveCheckpoint(ctx, cp); err != nil {
+		return true, err
+	}
+	log.Printf("%s: rate limit budget spent, waiting %s for the next window", cp.JobID, wait.Round(time.Second))
+
+	select {
+	case <-time.After(wait):
+	case <-ctx.Done():
+		return true, ctx.Err()
+	}
+	cp.Status = StatusRunning
+	return true, nil
+}
+
+// stop records why a sync stopped; cancellation is resumable, other errors are failures
+func stop(ctx context.Context, store *Store, cp *Checkpoint, cause error) (*Checkpoint, error) {
+	cp.Status = StatusFailed
+	// The circuit breaker reports a cancelled caller as a request timeout, so check ctx too
+	if ctx.Err() != nil || errors.Is(cause, context.Canceled) || errors.Is(cause, context.DeadlineExceeded) {
+		cp.Status = StatusInterrupted
+	}
+	cp.LastError = cause.Error()
+
+	// The caller's context may already be cancelled; the checkpoint must still be written
+	if err := store.SaveCheckpoint(context.Background(), cp); err != nil {
+		log.Printf("Failed to save checkpoint for %s: %v", cp.JobID, err)
+	}
+
+	return cp, cause
+}
+
+// monthStart truncates a time to the first day of its month in UTC
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// nextWindow returns the end of the monthly window starting at start, capped at end
+func nextWindow(start, end time.Time) time.Time {
+	next := monthStart(start).AddDate(0, 1, 0)
+	if next.After(end) {
+		return end
+	}
+	return next
+}
+
+// countWindows returns the number of monthly windows between start and end
+func countWindows(start, end time.Time) int {
+	count := 0
+	for cursor := start; cursor.Before(end); cursor = nextWindow(cursor, end) {
+		count++
+	}
+	return count
+}
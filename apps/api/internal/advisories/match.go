@@ -0,0 +1,174 @@
+package advisories
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// ecosystems maps purl types to GitHub advisory ecosystems
+var ecosystems = map[string]string{
+	"golang":   "go",
+	"npm":      "npm",
+	"pypi":     "pip",
+	"maven":    "maven",
+	"gem":      "rubygems",
+	"cargo":    "rust",
+	"nuget":    "nuget",
+	"composer": "composer",
+	"pub":      "pub",
+	"swift":    "swift",
+	"hex":      "erlang",
+	"github":   "actions",
+}
+
+// PackageName returns the GitHub advisory ecosystem and package name of a
+// purl, or false when the ecosystem isn't covered by GitHub advisories
+func PackageName(purl *sbom.PackageURL) (string, string, bool) {
+	ecosystem, found := ecosystems[purl.Type]
+	if !found {
+		return "", "", false
+	}
+
+	name := purl.Name
+	switch {
+	case purl.Type == "maven" && purl.Namespace != "":
+		name = purl.Namespace + ":" + purl.Name
+	case purl.Type == "pypi":
+		name = strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	case purl.Namespace != "":
+		name = purl.Namespace + "/" + purl.Name
+	}
+	return ecosystem, name, true
+}
+
+// Affects reports whether a GitHub advisory lists the package version as
+// vulnerable, returning the first patched version when one is known
+func Affects(advisory github.Advisory, purl *sbom.PackageURL) (bool, string) {
+	ecosystem, name, ok := PackageName(purl)
+	if !ok || purl.Version == "" {
+		return false, ""
+	}
+
+	for _, vulnerability := range advisory.Vulnerabilities {
+		pkg := vulnerability.Package
+		if !strings.EqualFold(pkg.Ecosystem, ecosystem) || !samePackage(ecosystem, pkg.Name, name) {
+			continue
+		}
+		if InRange(purl.Version, vulnerability.VulnerableVersionRange) {
+			return true, string(vulnerability.FirstPatchedVersion)
+		}
+	}
+	return false, ""
+}
+
+// samePackage compares package names, case-insensitively where the
+// ecosystem's names are
+func samePackage(ecosystem, a, b string) bool {
+	switch ecosystem {
+	case "go", "maven":
+		return a == b
+	default:
+		return strings.EqualFold(a, b)
+	}
+}
+
+// InRange reports whether version satisfies a GitHub vulnerable version
+// range: comma-separated constraints such as ">= 1.0.0, < 1.2.3" that must
+// all hold. An empty range matches nothing.
+func InRange(version, versionRange string) bool {
+	if strings.TrimSpace(versionRange) == "" {
+		return false
+	}
+	for _, constraint := range strings.Split(versionRange, ",") {
+		constraint = strings.TrimSpace(constraint)
+		bound := strings.TrimLeft(constraint, "<>=!")
+		operator := constraint[:len(constraint)-len(bound)]
+		bound = strings.TrimSpace(bound)
+		if bound == "" {
+			return false
+		}
+
+		c := CompareVersions(version, bound)
+		var satisfied bool
+		switch operator {
+		case "<":
+			satisfied = c < 0
+		case "<=":
+			satisfied = c <= 0
+		case ">":
+			satisfied = c > 0
+		case ">=":
+			satisfied = c >= 0
+		case "=", "==", "":
+			satisfied = c == 0
+		case "!=":
+			satisfied = c != 0
+		default:
+			return false
+		}
+		if !satisfied {
+			return false
+		}
+	}
+	return true
+}
+
+// CompareVersions orders dotted versions segment by segment, numerically
+// where both segments are numbers; a pre-release sorts before its release
+func CompareVersions(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	a, _, _ = strings.Cut(a, "+")
+	b, _, _ = strings.Cut(b, "+")
+	aRelease, aPre, aHasPre := strings.Cut(a, "-")
+	bRelease, bPre, bHasPre := strings.Cut(b, "-")
+
+	if c := compareSegments(strings.Split(aRelease, "."), strings.Split(bRelease, ".")); c != 0 {
+		return c
+	}
+	switch {
+	case aHasPre && !bHasPre:
+		return -1
+	case !aHasPre && bHasPre:
+		return 1
+	case aHasPre && bHasPre:
+		return compareSegments(strings.Split(aPre, "."), strings.Split(bPre, "."))
+	}
+	return 0
+}
+
+// compareSegments compares version segments, treating missing ones as zero
+func compareSegments(a, b []string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		x, y := "0", "0"
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+
+		xn, xErr := strconv.Atoi(x)
+		yn, yErr := strconv.Atoi(y)
+		switch {
+		case xErr == nil && yErr == nil:
+			if xn != yn {
+				if xn < yn {
+					return -1
+				}
+				return 1
+			}
+		case xErr == nil:
+			return -1 // Numeric identifiers sort before alphanumeric ones
+		case yErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,227 @@
+package advisories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/history"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// Checkpoint statuses
+const (
+	StatusRunning     = "running"
+	StatusWaiting     = "waiting"
+	StatusInterrupted = "interrupted"
+	StatusCompleted   = "completed"
+	StatusFailed      = "failed"
+)
+
+// Checkpoint records the resumable progress of a sync job
+type Checkpoint struct {
+	JobID        string    `json:"job_id"`
+	JobType      string    `json:"job_type"`
+	Ecosystem    string    `json:"ecosystem"`
+	WindowStart  time.Time `json:"window_start"`
+	RangeEnd     time.Time `json:"range_end"`
+	Cursor       string    `json:"cursor,omitempty"`
+	FetchedCount int       `json:"fetched_count"`
+	RequestCount int       `json:"request_count"`
+	Status       string    `json:"status"`
+	LastError    string    `json:"last_error,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Store persists advisories and sync checkpoints in SQLite
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a new advisory store
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// LoadCheckpoint returns the checkpoint for a job, or nil if the job has never run
+func (s *Store) LoadCheckpoint(ctx context.Context, jobID string) (*Checkpoint, error) {
+	query := `
+		SELECT job_id, job_type, ecosystem, window_start, range_end, COALESCE(cursor, ''),
+		       fetched_count, request_count, status, COALESCE(last_error, ''), updated_at
+		FROM sync_checkpoints
+		WHERE job_id = ?
+	`
+
+	var cp Checkpoint
+	err := s.db.QueryRowContext(ctx, query, jobID).Scan(
+		&cp.JobID,
+		&cp.JobType,
+		&cp.Ecosystem,
+		&cp.WindowStart,
+		&cp.RangeEnd,
+		&cp.Cursor,
+		&cp.FetchedCount,
+		&cp.RequestCount,
+		&cp.Status,
+		&cp.LastError,
+		&cp.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint %s: %w", jobID, err)
+	}
+
+	return &cp, nil
+}
+
+// SaveCheckpoint upserts a checkpoint
+func (s *Store) SaveCheckpoint(ctx context.Context, cp *Checkpoint) error {
+	return saveCheckpoint(ctx, s.db, cp)
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// saveCheckpoint upserts a checkpoint using the given executor
+func saveCheckpoint(ctx context.Context, db execer, cp *Checkpoint) error {
+	cp.UpdatedAt = time.Now().UTC()
+
+	upsertSQL := `
+		INSERT INTO sync_checkpoints
+		(job_id, job_type, ecosystem, window_start, range_end, cursor, fetched_count, request_count, status, last_error, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(job_id) DO UPDATE SET
+			window_start = excluded.window_start,
+			range_end = excluded.range_end,
+			cursor = excluded.cursor,
+			fetched_count = excluded.fetched_count,
+			request_count = excluded.request_count,
+			status = excluded.status,
+			last_error = excluded.last_error,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := db.ExecContext(ctx, upsertSQL,
+		cp.JobID,
+		cp.JobType,
+		cp.Ecosystem,
+		cp.WindowStart.UTC(),
+		cp.RangeEnd.UTC(),
+		cp.Cursor,
+		cp.FetchedCount,
+		cp.RequestCount,
+		cp.Status,
+		cp.LastError,
+		cp.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint %s: %w", cp.JobID, err)
+	}
+	return nil
+}
+
+// SavePage upserts a page of advisories and advances the checkpoint in one transaction,
+// so a crash never records progress for advisories that were not stored
+func (s *Store) SavePage(ctx context.Context, ecosystem string, advisories []github.Advisory, cp *Checkpoint) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, advisory := range advisories {
+		if err := upsertAdvisory(ctx, tx, ecosystem, advisory); err != nil {
+			return err
+		}
+	}
+
+	if err := saveCheckpoint(ctx, tx, cp); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// upsertAdvisory inserts or updates a single advisory keyed by GHSA ID and
+// records the fetched version in the advisory history
+func upsertAdvisory(ctx context.Context, tx *sql.Tx, ecosystem string, advisory github.Advisory) error {
+	ghsaID := advisory.GHSAID
+	if ghsaID == "" {
+		return nil // Nothing to key the advisory on
+	}
+
+	rawData, err := advisory.Document()
+	if err != nil {
+		return fmt.Errorf("failed to encode advisory %s: %w", ghsaID, err)
+	}
+
+	upsertSQL := `
+		INSERT INTO github_advisories
+		(ghsa_id, cve_id, ecosystem, severity, summary, published_at, advisory_updated_at, withdrawn_at, raw_data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(ghsa_id) DO UPDATE SET
+			cve_id = excluded.cve_id,
+			severity = excluded.severity,
+			summary = excluded.summary,
+			published_at = excluded.published_at,
+			advisory_updated_at = excluded.advisory_updated_at,
+			withdrawn_at = excluded.withdrawn_at,
+			raw_data = excluded.raw_data,
+			updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err = tx.ExecContext(ctx, upsertSQL,
+		ghsaID,
+		nullableString(advisory.CVEID),
+		ecosystem,
+		strings.ToUpper(advisory.Severity),
+		advisory.Summary,
+		nullableTime(advisory.PublishedAt),
+		nullableTime(advisory.UpdatedAt),
+		nullableTime(advisory.WithdrawnAt),
+		string(rawData),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store advisory %s: %w", ghsaID, err)
+	}
+
+	// The fetched version is the one published at its update time, so a
+	// backfill records advisories as they stood then rather than today
+	effective := time.Now().UTC()
+	if updated := advisory.UpdatedAt; updated != nil && updated.Before(effective) {
+		effective = updated.UTC()
+	}
+	if _, err := history.RecordTx(ctx, tx, history.KindAdvisory, ghsaID, rawData, effective); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CountAdvisories returns the number of stored advisories for an ecosystem
+func (s *Store) CountAdvisories(ctx context.Context, ecosystem string) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM github_advisories WHERE ecosystem = ?", ecosystem).Scan(&count)
+	return count, err
+}
+
+// nullableString converts an optional string to a SQL value
+func nullableString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// nullableTime converts an optional time to a SQL value
+func nullableTime(value *time.Time) interface{} {
+	if value == nil {
+		return nil
+	}
+	return value.UTC()
+}
@@ -0,0 +1,154 @@
+package advisories
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// SyncConfig holds incremental advisory sync configuration
+type SyncConfig struct {
+	Ecosystem   string
+	Since       time.Time // High-water mark of the first run; zero fetches every advisory
+	PerPage     int
+	Reserve     int // Requests left untouched for interactive traffic
+	ResetBuffer time.Duration
+}
+
+// DefaultSyncConfig returns default incremental sync configuration
+func DefaultSyncConfig(ecosystem string) SyncConfig {
+	return SyncConfig{
+		Ecosystem:   ecosystem,
+		PerPage:     100,
+		Reserve:     1000, // Matches the client's 20% rate limit buffer
+		ResetBuffer: 5 * time.Second,
+	}
+}
+
+// Syncer keeps the store current by fetching the advisories updated since
+// the last run. Publishing an advisory updates it too, so new advisories are
+// fetched along with revised and withdrawn ones.
+//
+// The checkpoint's RangeEnd is the high-water mark: the latest updated_at
+// stored. A run asks for advisories updated at or after it, oldest first, and
+// keeps that lower bound in WindowStart while paging, so an interrupted run
+// resumes its query from the stored cursor.
+type Syncer struct {
+	client *github.Client
+	store  *Store
+	config SyncConfig
+}
+
+// NewSyncer creates a new incremental advisory syncer
+func NewSyncer(client *github.Client, store *Store, config SyncConfig) *Syncer {
+	return &Syncer{
+		client: client,
+		store:  store,
+		config: config,
+	}
+}
+
+// JobID returns the checkpoint key for this ecosystem's incremental sync
+func (s *Syncer) JobID() string {
+	return syncJobID(s.config.Ecosystem)
+}
+
+// Run fetches and stores the advisories updated since the high-water mark,
+// advancing the mark with every page
+func (s *Syncer) Run(ctx context.Context) (*Checkpoint, error) {
+	ctx = github.WithConsumer(ctx, github.ConsumerAdvisorySync)
+	cp, err := s.store.LoadCheckpoint(ctx, s.JobID())
+	if err != nil {
+		return nil, err
+	}
+
+	if cp == nil {
+		cp = &Checkpoint{
+			JobID:     s.JobID(),
+			JobType:   "incremental",
+			Ecosystem: s.config.Ecosystem,
+			RangeEnd:  s.config.Since.UTC(),
+		}
+	} else if cp.Cursor != "" {
+		log.Printf("Resuming %s from advisories updated since %s", cp.JobID, cp.WindowStart.Format(time.RFC3339))
+	}
+
+	// A fresh run starts at the high-water mark; counts are per run
+	if cp.Cursor == "" {
+		cp.WindowStart = cp.RangeEnd
+		cp.FetchedCount = 0
+		cp.RequestCount = 0
+	}
+	cp.Status = StatusRunning
+	cp.LastError = ""
+	if err := s.store.SaveCheckpoint(ctx, cp); err != nil {
+		return nil, err
+	}
+
+	for {
+		if err := waitForQuota(ctx, s.client, s.store, cp, s.config.Reserve, s.config.ResetBuffer); err != nil {
+			return stop(ctx, s.store, cp, err)
+		}
+
+		query := github.AdvisoryQuery{
+			Ecosystem: s.config.Ecosystem,
+			Sort:      "updated",
+			Direction: "asc",
+			PerPage:   s.config.PerPage,
+			After:     cp.Cursor,
+		}
+		// Inclusive, so advisories sharing the mark's second aren't missed;
+		// refetching the last one is an idempotent upsert
+		if !cp.WindowStart.IsZero() {
+			query.Updated = ">=" + cp.WindowStart.UTC().Format(time.RFC3339)
+		}
+		page, err := s.client.ListAdvisories(ctx, query)
+		if waited, err := waitForBudget(ctx, s.store, cp, err, s.config.ResetBuffer); waited {
+			if err != nil {
+				return stop(ctx, s.store, cp, err)
+			}
+			continue
+		}
+		cp.RequestCount++
+		if err != nil {
+			return stop(ctx, s.store, cp, err)
+		}
+
+		cp.FetchedCount += len(page.Advisories)
+		for _, advisory := range page.Advisories {
+			if updated := advisory.UpdatedAt; updated != nil && updated.After(cp.RangeEnd) {
+				cp.RangeEnd = updated.UTC()
+			}
+		}
+		cp.Cursor = page.NextCursor
+		if cp.Cursor == "" {
+			cp.WindowStart = cp.RangeEnd
+			cp.Status = StatusCompleted
+		}
+
+		if err := s.store.SavePage(ctx, s.config.Ecosystem, page.Advisories, cp); err != nil {
+			return stop(ctx, s.store, cp, err)
+		}
+		if cp.Status == StatusCompleted {
+			return cp, nil
+		}
+	}
+}
+
+// HighWaterMark returns the latest advisory update stored by the ecosystem's
+// incremental sync, or the zero time if it has never run
+func (s *Store) HighWaterMark(ctx context.Context, ecosystem string) (time.Time, error) {
+	cp, err := s.LoadCheckpoint(ctx, syncJobID(ecosystem))
+	if err != nil || cp == nil {
+		return time.Time{}, err
+	}
+	return cp.RangeEnd, nil
+}
+
+// syncJobID returns the checkpoint key of an ecosystem's incremental sync
+func syncJobID(ecosystem string) string {
+	return fmt.Sprintf("incremental:%s", ecosystem)
+}
@@ -0,0 +1,102 @@
+package attestation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// ApprovedWorkflow is a reusable workflow permitted to sign, at pinned refs
+type ApprovedWorkflow struct {
+	Workflow string   `json:"workflow"`          // owner/repo/.github/workflows/file.yml
+	Refs     []string `json:"refs"`              // Pinned tags (refs/tags/v1.2.0) or commit SHAs
+	Callers  []string `json:"callers,omitempty"` // owner/repo patterns allowed to call it; empty allows any
+}
+
+// WorkflowAllowlist restricts signing to approved workflows; signing is
+// permitted only when the OIDC job_workflow_ref names an approved workflow at a pinned ref
+type WorkflowAllowlist struct {
+	Workflows []ApprovedWorkflow `json:"workflows"`
+}
+
+// LoadWorkflowAllowlist reads an allowlist from a JSON file
+func LoadWorkflowAllowlist(filePath string) (*WorkflowAllowlist, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow allowlist: %w", err)
+	}
+
+	var allowlist WorkflowAllowlist
+	if err := json.Unmarshal(data, &allowlist); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow allowlist %s: %w", filePath, err)
+	}
+	if err := allowlist.Validate(); err != nil {
+		return nil, err
+	}
+	return &allowlist, nil
+}
+
+// Validate rejects entries that could not match a pinned workflow
+func (a *WorkflowAllowlist) Validate() error {
+	for i, approved := range a.Workflows {
+		if !strings.Contains(approved.Workflow, "/.github/workflows/") || strings.Contains(approved.Workflow, "@") {
+			return fmt.Errorf("allowlist entry %d: workflow must be owner/repo/.github/workflows/<file> without a ref", i)
+		}
+		if len(approved.Refs) == 0 {
+			return fmt.Errorf("allowlist entry %d: workflow %s has no pinned refs", i, approved.Workflow)
+		}
+		for _, caller := range approved.Callers {
+			if _, err := path.Match(caller, ""); err != nil {
+				return fmt.Errorf("allowlist entry %d: invalid caller pattern %q: %w", i, caller, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Authorize checks that the token's job_workflow_ref is an approved workflow at
+// a pinned ref, called from a permitted repository
+func (a *WorkflowAllowlist) Authorize(claims Claims) error {
+	jobWorkflowRef, _ := claims["job_workflow_ref"].(string)
+	if jobWorkflowRef == "" {
+		return Errorf(CodeWorkflowNotApproved, "OIDC token has no job_workflow_ref claim")
+	}
+
+	workflow, ref, ok := strings.Cut(jobWorkflowRef, "@")
+	if !ok || ref == "" {
+		return Errorf(CodeWorkflowNotApproved, "job_workflow_ref %q does not include a ref", jobWorkflowRef)
+	}
+
+	caller, _ := claims["repository"].(string)
+	for _, approved := range a.Workflows {
+		if !strings.EqualFold(approved.Workflow, workflow) {
+			continue
+		}
+		if !matchesCaller(approved.Callers, caller) {
+			return Errorf(CodePermissionDenied, "Repository %q is not permitted to call %s", caller, workflow)
+		}
+		for _, pinned := range approved.Refs {
+			if ref == pinned {
+				return nil
+			}
+		}
+		return Errorf(CodeWorkflowRefNotPinned, "Workflow %s is approved but ref %q is not one of its pinned refs", workflow, ref)
+	}
+
+	return Errorf(CodeWorkflowNotApproved, "Workflow %s is not an approved signing workflow", workflow)
+}
+
+// matchesCaller reports whether a repository matches any caller pattern; no patterns allows all
+func matchesCaller(patterns []string, repository string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(repository)); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package attestation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/remediation"
+)
+
+// BlobBundleSuffix is appended to a blob's path to name its detached bundle,
+// e.g. keystone_linux_amd64.tar.gz.bundle.json
+const BlobBundleSuffix = ".bundle.json"
+
+// BlobBundlePath returns where the detached bundle for a blob is written
+func BlobBundlePath(path string) string {
+	return path + BlobBundleSuffix
+}
+
+// DigestBlob computes the SHA-256 subject of a blob read from r
+func DigestBlob(name string, r io.Reader) (Subject, error) {
+	return DigestReader(name, r, DigestSHA256)
+}
+
+// DigestFile computes the SHA-256 subject of a local file such as a release
+// tarball or binary, named by its base name
+func DigestFile(path string) (Subject, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Subject{}, Wrap(CodeTargetNotResolved, err, "Failed to open %s", path)
+	}
+	defer f.Close()
+	return DigestBlob(filepath.Base(path), f)
+}
+
+// SignBlobs digests the files locally and signs a statement, usually
+// provenance, naming them all as subjects; each blob is then verifiable with
+// VerifyBlob
+func SignBlobs(ctx context.Context, signer Signer, builder PredicateGenerator, build BuildContext, paths ...string) (*Envelope, []Subject, error) {
+	if len(paths) == 0 {
+		return nil, nil, Errorf(CodeTargetNotResolved, "No blobs to sign")
+	}
+
+	subjects := make([]Subject, 0, len(paths))
+	for _, path := range paths {
+		subject, err := DigestFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		subjects = append(subjects, subject)
+	}
+
+	statement, err := builder.Build(subjects, build)
+	if err != nil {
+		return nil, nil, err
+	}
+	envelope, err := NewEnvelope(statement)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := SignEnvelope(ctx, envelope, signer); err != nil {
+		return nil, nil, err
+	}
+	return envelope, subjects, nil
+}
+
+// VerifyBlob checks that the bundle attests to the blob's digest and verifies
+// the bundle against the trust root and policy, as VerifyBundle does for
+// container images. Subjects are matched by digest alone, so renamed
+// downloads still verify.
+func VerifyBlob(bundle *Bundle, blob Subject, trust TrustRoot, policy IdentityPolicy) (*VerificationResult, error) {
+	if err := matchBlob(bundle, blob); err != nil {
+		result := &VerificationResult{Subject: blob.Name, VerifiedAt: time.Now().UTC()}
+		result.record(CheckBlobDigest, err, "")
+		result.Fail(err, remediation.Context{Target: blob.Name, Issuer: policy.Issuer})
+		return result, err
+	}
+
+	result, err := VerifyBundle(bundle, trust, policy)
+	result.Subject = blob.Name
+	matched := Check{Name: CheckBlobDigest, Status: CheckPassed, Detail: fmt.Sprintf("Bundle attests to sha256:%s", blob.Digest["sha256"])}
+	result.Checks = append([]Check{matched}, result.Checks...)
+	return result, err
+}
+
+// matchBlob finds a statement subject carrying the blob's SHA-256 digest
+func matchBlob(bundle *Bundle, blob Subject) error {
+	if bundle == nil || bundle.Envelope == nil {
+		return Errorf(CodeVerificationFailed, "Bundle is missing its envelope")
+	}
+	statement, err := bundle.Envelope.Statement()
+	if err != nil {
+		return err
+	}
+
+	digest := blob.Digest["sha256"]
+	for _, subject := range statement.Subject {
+		if digest != "" && subject.Digest["sha256"] == digest {
+			return nil
+		}
+	}
+	return Errorf(CodeBlobDigestMismatch, "Bundle does not attest to %s", blob)
+}
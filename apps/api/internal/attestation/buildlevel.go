@@ -0,0 +1,274 @@
+package attestation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SLSA build levels (https://slsa.dev/spec/v1.0/levels#build-track)
+const (
+	BuildLevel0 = 0 // No provenance
+	BuildLevel1 = 1 // Provenance describes how the artifact was built
+	BuildLevel2 = 2 // Signed provenance generated by a hosted build platform
+	BuildLevel3 = 3 // Hardened platform: isolated builds and unforgeable provenance
+)
+
+// TrustedBuilder is a build platform trusted to meet a SLSA build level.
+// A * at the start or end of an ID or signer matches any suffix or prefix.
+type TrustedBuilder struct {
+	ID    string `json:"id" yaml:"id"`       // Provenance builder ID
+	Level int    `json:"level" yaml:"level"` // Highest level the platform's isolation supports
+	// Signer is the certificate SAN the platform signs its provenance with.
+	// Level 3 requires it, so tenants can't forge the builder's provenance.
+	Signer string `json:"signer,omitempty" yaml:"signer,omitempty"`
+}
+
+// DefaultTrustedBuilders are used when a policy lists none
+var DefaultTrustedBuilders = []TrustedBuilder{
+	{
+		// Reusable workflows run in their own job, out of the caller's reach
+		ID:     "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/*",
+		Level:  BuildLevel3,
+		Signer: "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/*",
+	},
+	{ID: "https://github.com/actions/runner/github-hosted", Level: BuildLevel2},
+	{
+		ID:     "https://cloudbuild.googleapis.com/GoogleHostedWorker*",
+		Level:  BuildLevel3,
+		Signer: "*@cloudbuild.gserviceaccount.com",
+	},
+}
+
+// BuildRequirement is one SLSA requirement and whether the provenance meets it
+type BuildRequirement struct {
+	Level       int    `json:"level"`
+	Requirement string `json:"requirement"`
+	Met         bool   `json:"met"`
+	Detail      string `json:"detail"`
+}
+
+// BuildLevelAssessment is the SLSA build level a provenance statement earns,
+// with every requirement that was assessed
+type BuildLevelAssessment struct {
+	Level        int                `json:"level"`
+	BuilderID    string             `json:"builder_id,omitempty"`
+	BuildType    string             `json:"build_type,omitempty"`
+	Requirements []BuildRequirement `json:"requirements"`
+}
+
+// String renders the level as L0-L3
+func (a *BuildLevelAssessment) String() string {
+	return fmt.Sprintf("L%d", a.Level)
+}
+
+// ClassifyBuildLevel assigns the SLSA build level the provenance and its
+// signer earn: L1 when the provenance names a builder and build type, L2 when
+// a hosted platform ran the build, and L3 when a builder trusted for isolated
+// builds signed it with complete parameters. A level is only earned when every
+// requirement of the lower levels is met. Statements that aren't provenance
+// are L0.
+func ClassifyBuildLevel(statement *UpgradedStatement, identity *CertificateIdentity, builders []TrustedBuilder) *BuildLevelAssessment {
+	assessment := &BuildLevelAssessment{Requirements: []BuildRequirement{}}
+	if statement == nil || statement.PredicateType != PredicateSLSAProvenanceV1 {
+		assessment.require(BuildLevel1, "provenance", false, "Statement is not SLSA provenance")
+		return assessment
+	}
+	provenance, ok := statement.Predicate.(ProvenanceV1)
+	if !ok {
+		assessment.require(BuildLevel1, "provenance", false, "Provenance predicate could not be decoded")
+		return assessment
+	}
+	if identity == nil {
+		identity = &CertificateIdentity{}
+	}
+	if len(builders) == 0 {
+		builders = DefaultTrustedBuilders
+	}
+
+	builderID := provenance.RunDetails.Builder.ID
+	assessment.BuilderID = builderID
+	assessment.BuildType = provenance.BuildDefinition.BuildType
+	trusted := trustedBuilder(builders, builderID)
+	runner := runnerEnvironment(provenance)
+
+	assessment.require(BuildLevel1, "provenance", true, "Statement is SLSA provenance")
+	assessment.require(BuildLevel1, "builder_id", builderID != "", detailOr(builderID, "Provenance names no builder"))
+	assessment.require(BuildLevel1, "build_type", assessment.BuildType != "", detailOr(assessment.BuildType, "Provenance names no build type"))
+
+	switch {
+	case runner == "self-hosted":
+		assessment.require(BuildLevel2, "hosted_builder", false, "Provenance was generated on a self-hosted runner")
+	case trusted != nil && trusted.Level >= BuildLevel2:
+		assessment.require(BuildLevel2, "hosted_builder", true, fmt.Sprintf("Builder is trusted for L%d", trusted.Level))
+	case hostedIssuer(identity.Issuer):
+		assessment.require(BuildLevel2, "hosted_builder", true, fmt.Sprintf("Signed with a CI job identity from %s", identity.Issuer))
+	default:
+		assessment.require(BuildLevel2, "hosted_builder", false, fmt.Sprintf("Builder %q is not trusted and the signer is not a hosted CI job", builderID))
+	}
+	invocation := provenance.RunDetails.Metadata.InvocationID
+	assessment.require(BuildLevel2, "invocation_id", invocation != "", detailOr(invocation, "Provenance does not identify the build run"))
+
+	switch {
+	case trusted == nil || trusted.Level < BuildLevel3:
+		assessment.require(BuildLevel3, "isolated_builder", false, "Builder is not trusted to isolate builds")
+	case runner == "self-hosted":
+		assessment.require(BuildLevel3, "isolated_builder", false, "Provenance was generated on a self-hosted runner")
+	default:
+		assessment.require(BuildLevel3, "isolated_builder", true, fmt.Sprintf("Builder %s isolates builds", trusted.ID))
+	}
+	signedByBuilder := trusted != nil && trusted.Signer != "" && matchesBuilderPattern(trusted.Signer, identity.SAN)
+	assessment.require(BuildLevel3, "unforgeable_provenance", signedByBuilder,
+		fmt.Sprintf("Signed by %q", identity.SAN))
+	complete, detail := parametersComplete(provenance.BuildDefinition)
+	assessment.require(BuildLevel3, "complete_parameters", complete, detail)
+
+	for level := BuildLevel1; level <= BuildLevel3; level++ {
+		if !assessment.meets(level) {
+			break
+		}
+		assessment.Level = level
+	}
+	return assessment
+}
+
+// require records the outcome of one requirement
+func (a *BuildLevelAssessment) require(level int, requirement string, met bool, detail string) {
+	a.Requirements = append(a.Requirements, BuildRequirement{Level: level, Requirement: requirement, Met: met, Detail: detail})
+}
+
+// meets reports whether every requirement of the level was met
+func (a *BuildLevelAssessment) meets(level int) bool {
+	for _, requirement := range a.Requirements {
+		if requirement.Level == level && !requirement.Met {
+			return false
+		}
+	}
+	return true
+}
+
+// trustedBuilder returns the builder matching the ID, or nil
+func trustedBuilder(builders []TrustedBuilder, id string) *TrustedBuilder {
+	if id == "" {
+		return nil
+	}
+	for i := range builders {
+		if matchesBuilderPattern(builders[i].ID, id) {
+			return &builders[i]
+		}
+	}
+	return nil
+}
+
+// matchesBuilderPattern compares a value to a pattern that may start or end with *
+func matchesBuilderPattern(pattern, value string) bool {
+	if prefix, wildcard := strings.CutSuffix(pattern, "*"); wildcard {
+		return strings.HasPrefix(value, prefix)
+	}
+	if suffix, wildcard := strings.CutPrefix(pattern, "*"); wildcard {
+		return strings.HasSuffix(value, suffix)
+	}
+	return pattern == value
+}
+
+// hostedIssuer reports whether the issuer belongs to a CI platform whose
+// tokens identify the definition that ran, so the job ran on the platform
+// rather than on a developer's machine
+func hostedIssuer(issuer string) bool {
+	for _, profile := range issuerProfiles {
+		if profile.Claims.BuildSigner != "" && profile.MatchesIssuer(issuer) {
+			return true
+		}
+	}
+	return false
+}
+
+// runnerEnvironment returns the runner environment the provenance records, as
+// GitHub's provenance and Keystone's claim mappings do, or ""
+func runnerEnvironment(provenance ProvenanceV1) string {
+	internal := provenance.BuildDefinition.InternalParameters
+	if github, ok := internal["github"].(map[string]interface{}); ok {
+		if value, ok := github["runner_environment"].(string); ok && value != "" {
+			return value
+		}
+	}
+	switch claims := internal["oidc_claims"].(type) {
+	case map[string]interface{}:
+		value, _ := claims["keystone.oidc.runner_environment"].(string)
+		return value
+	case map[string]string:
+		return claims["keystone.oidc.runner_environment"]
+	}
+	return ""
+}
+
+// parametersComplete checks the provenance records the parameters the build
+// was started with and pins every dependency by digest
+func parametersComplete(definition BuildDefinitionV1) (bool, string) {
+	if len(definition.ExternalParameters) == 0 {
+		return false, "Provenance records no external parameters"
+	}
+	if len(definition.ResolvedDependencies) == 0 {
+		return false, "Provenance records no resolved dependencies"
+	}
+	for _, dependency := range definition.ResolvedDependencies {
+		if len(dependency.Digest) == 0 {
+			name := dependency.URI
+			if name == "" {
+				name = dependency.Name
+			}
+			return false, fmt.Sprintf("Dependency %s is not pinned by digest", name)
+		}
+	}
+	return true, fmt.Sprintf("%d external parameters, %d pinned dependencies",
+		len(definition.ExternalParameters), len(definition.ResolvedDependencies))
+}
+
+// detailOr returns value, or fallback when it is empty
+func detailOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// verifyBuildLevel records whether the provenance meets the policy's minimum
+// build level, when it sets one. Statements that aren't provenance are not
+// held to it.
+func (p *IdentityPolicy) verifyBuildLevel(result *VerificationResult) error {
+	if p.MinBuildLevel <= BuildLevel0 {
+		return nil
+	}
+	if result.BuildLevel == nil {
+		result.skip(CheckBuildLevel, "Statement is not SLSA provenance")
+		return nil
+	}
+
+	assessment := result.BuildLevel
+	var err error
+	if assessment.Level < p.MinBuildLevel {
+		err = Errorf(CodeBuildLevelTooLow, "Provenance from builder %q is SLSA build %s, policy requires L%d%s",
+			assessment.BuilderID, assessment, p.MinBuildLevel, unmetRequirements(assessment, p.MinBuildLevel))
+	}
+	result.Policy = append(result.Policy, PolicyResult{
+		Rule:      "build_level",
+		Expected:  fmt.Sprintf("L%d", p.MinBuildLevel),
+		Actual:    assessment.String(),
+		Passed:    err == nil,
+		ErrorCode: CodeOf(err),
+	})
+	return result.record(CheckBuildLevel, err, fmt.Sprintf("SLSA build %s meets the required L%d", assessment, p.MinBuildLevel))
+}
+
+// unmetRequirements lists the requirements up to the level that weren't met
+func unmetRequirements(assessment *BuildLevelAssessment, level int) string {
+	var unmet []string
+	for _, requirement := range assessment.Requirements {
+		if requirement.Level <= level && !requirement.Met {
+			unmet = append(unmet, fmt.Sprintf("%s (%s)", requirement.Requirement, requirement.Detail))
+		}
+	}
+	if len(unmet) == 0 {
+		return ""
+	}
+	return ": unmet " + strings.Join(unmet, "; ")
+}
@@ -0,0 +1,517 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/remediation"
+)
+
+// BundleMediaType identifies offline verification bundles
+const BundleMediaType = "application/vnd.keystone.verification-bundle.v1+json"
+
+// Bundle packages everything needed to verify an attestation without network access
+type Bundle struct {
+	MediaType        string             `json:"mediaType"`
+	Attestation      *AttestationRecord `json:"attestation,omitempty"`
+	Envelope         *Envelope          `json:"dsseEnvelope"`
+	CertificateChain []string           `json:"certificateChain"` // PEM, leaf first
+	TlogEntry        *TlogEntry         `json:"tlogEntry"`
+	Timestamps       []string           `json:"rfc3161Timestamps,omitempty"` // Base64 DER tokens over the envelope signature
+	TrustRoot        TrustRoot          `json:"trustRoot"`                   // What the producer trusted; informational, never verified against
+	CreatedAt        time.Time          `json:"createdAt"`
+}
+
+// TlogEntry is a Rekor transparency log entry with its signed entry timestamp
+type TlogEntry struct {
+	UUID                 string `json:"uuid,omitempty"`
+	LogIndex             int64  `json:"logIndex"`
+	LogID                string `json:"logID"` // Hex SHA-256 of the log public key
+	IntegratedTime       int64  `json:"integratedTime"`
+	Body                 string `json:"body"`                 // Base64 canonicalized entry
+	SignedEntryTimestamp string `json:"signedEntryTimestamp"` // Base64 signature by the log key
+}
+
+// TrustRoot holds the Fulcio roots, Rekor keys and timestamp authorities a bundle is verified against
+type TrustRoot struct {
+	FulcioCertificates   []string `json:"fulcioCertificates"`             // PEM root and intermediate CAs
+	RekorPublicKeys      []string `json:"rekorPublicKeys"`                // PEM public keys
+	TimestampAuthorities []string `json:"timestampAuthorities,omitempty"` // PEM TSA certificates
+}
+
+// NewBundle packages an envelope, its signing certificate chain, transparency
+// log entry and trust root for offline verification
+func NewBundle(envelope *Envelope, chain []*x509.Certificate, entry *TlogEntry, trust TrustRoot) (*Bundle, error) {
+	if envelope == nil || len(chain) == 0 || entry == nil {
+		return nil, Errorf(CodeVerificationFailed, "Bundle requires an envelope, certificate chain and transparency log entry")
+	}
+
+	bundle := &Bundle{
+		MediaType: BundleMediaType,
+		Envelope:  envelope,
+		TlogEntry: entry,
+		TrustRoot: trust,
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, cert := range chain {
+		bundle.CertificateChain = append(bundle.CertificateChain,
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+	}
+	return bundle, nil
+}
+
+// AddTimestamp requests an RFC 3161 timestamp over the envelope signature and
+// embeds it, so the bundle stays verifiable after the signing certificate expires
+func (b *Bundle) AddTimestamp(ctx context.Context, tsa *TimestampAuthority) error {
+	if b.Envelope == nil || len(b.Envelope.Signatures) == 0 {
+		return Errorf(CodeTimestampFailed, "Bundle envelope is not signed")
+	}
+	sig, err := base64.StdEncoding.DecodeString(b.Envelope.Signatures[0].Sig)
+	if err != nil {
+		return Wrap(CodeTimestampFailed, err, "Envelope signature is not valid base64")
+	}
+
+	token, err := tsa.Timestamp(ctx, sig)
+	if err != nil {
+		return err
+	}
+	b.Timestamps = append(b.Timestamps, base64.StdEncoding.EncodeToString(token))
+	return nil
+}
+
+// WriteFile writes the bundle as JSON
+func (b *Bundle) WriteFile(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// ReadBundle reads a bundle written by WriteFile
+func ReadBundle(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, Wrap(CodeVerificationFailed, err, "Bundle %s is not valid JSON", path)
+	}
+	if bundle.MediaType != BundleMediaType {
+		return nil, Errorf(CodeVerificationFailed, "Unsupported bundle media type %q", bundle.MediaType)
+	}
+	return &bundle, nil
+}
+
+// VerifyBundle verifies a bundle offline against a trusted root: the
+// certificate chain, the transparency log SET and its binding to the envelope,
+// any RFC 3161 timestamps, the envelope signature, and the certificate identity
+// against the policy. The trust root the bundle carries is never used, since
+// whoever produced the bundle chose it; pass the configured one, e.g. from
+// trustroot.Manager.
+// The returned result is populated with remediation hints on failure.
+func VerifyBundle(bundle *Bundle, trust TrustRoot, policy IdentityPolicy) (*VerificationResult, error) {
+	result := &VerificationResult{VerifiedAt: time.Now().UTC()}
+	identity, err := verifyBundle(bundle, trust, &policy, result)
+	if identity != nil {
+		result.Identity = identity.SAN
+		result.Issuer = identity.Issuer
+	}
+	if err != nil {
+		c := remediation.Context{Issuer: policy.Issuer}
+		if bundle != nil && bundle.Attestation != nil {
+			c.Target = bundle.Attestation.Target
+		}
+		result.Fail(err, c)
+		return result, err
+	}
+
+	result.Valid = true
+	return result, nil
+}
+
+func verifyBundle(bundle *Bundle, trust TrustRoot, policy *IdentityPolicy, result *VerificationResult) (*CertificateIdentity, error) {
+	if bundle == nil || bundle.Envelope == nil || (bundle.TlogEntry == nil && len(bundle.Timestamps) == 0) {
+		return nil, result.record(CheckBundle, Errorf(CodeVerificationFailed, "Bundle is missing its envelope or a transparency log entry or timestamp"), "")
+	}
+
+	chain, err := parseCertificates(bundle.CertificateChain)
+	if err != nil || len(chain) == 0 {
+		return nil, result.record(CheckBundle, Wrap(CodePublicKeyExtraction, err, "Bundle certificate chain is invalid"), "")
+	}
+	leaf := chain[0]
+	result.CertificateChain = bundle.CertificateChain
+	result.record(CheckBundle, nil, fmt.Sprintf("%d certificates, %d signatures", len(chain), len(bundle.Envelope.Signatures)))
+
+	statement, err := bundle.Envelope.UpgradedStatement()
+	if err == nil {
+		if len(statement.Subject) > 0 {
+			result.Subject = statement.Subject[0].Name
+		}
+		result.Conversions = statement.Conversions
+	}
+
+	// Fulcio certificates live for minutes, so validity is checked at the time
+	// a timestamp authority or the log vouched for the signature rather than now
+	var signedAt time.Time
+	if len(bundle.Timestamps) > 0 {
+		signedAt, err = verifyTimestamps(bundle, trust)
+		if err := result.record(CheckTimestamps, err, fmt.Sprintf("%d timestamps, earliest %s", len(bundle.Timestamps), signedAt.UTC().Format(time.RFC3339))); err != nil {
+			return nil, err
+		}
+		result.TimestampVerified = true
+	} else {
+		result.skip(CheckTimestamps, "Bundle has no RFC 3161 timestamps; the transparency log time is used")
+		signedAt = time.Unix(bundle.TlogEntry.IntegratedTime, 0)
+	}
+	signedAtUTC := signedAt.UTC()
+	result.SignedAt = &signedAtUTC
+	if err := result.record(CheckCertificateChain, verifyChain(leaf, chain[1:], trust, signedAt),
+		fmt.Sprintf("Chains to the trusted Fulcio root at %s", signedAt.UTC().Format(time.RFC3339))); err != nil {
+		return nil, err
+	}
+
+	if bundle.TlogEntry != nil {
+		if err := result.record(CheckTransparencyLog, verifyTlogEntry(bundle.TlogEntry, bundle.Envelope, leaf, trust),
+			fmt.Sprintf("Rekor entry %d has a valid signed entry timestamp", bundle.TlogEntry.LogIndex)); err != nil {
+			return nil, err
+		}
+		result.RekorVerified = true
+	} else {
+		result.skip(CheckTransparencyLog, "Bundle has no transparency log entry")
+	}
+
+	// The steps of IdentityPolicy.VerifyEnvelope, recorded separately
+	if err := result.record(CheckSignature, VerifyEnvelopeKey(bundle.Envelope, leaf.PublicKey), "Envelope is signed by the certificate's key"); err != nil {
+		return nil, err
+	}
+	if policy.RequireCanonical {
+		if err := result.record(CheckCanonicalPayload, bundle.Envelope.VerifyCanonical(), "Payload is RFC 8785 canonical JSON"); err != nil {
+			return nil, err
+		}
+	} else {
+		result.skip(CheckCanonicalPayload, "Not required by the policy")
+	}
+
+	identity := ParseCertificateIdentity(leaf)
+	result.Certificate = identity
+	result.Policy = policy.Evaluate(identity)
+	if err := result.record(CheckIdentityPolicy, policy.Verify(identity),
+		fmt.Sprintf("%d constraints satisfied", len(result.Policy))); err != nil {
+		return identity, err
+	}
+
+	if statement != nil && statement.PredicateType == PredicateSLSAProvenanceV1 {
+		result.BuildLevel = ClassifyBuildLevel(statement, identity, policy.TrustedBuilders)
+	}
+	return identity, policy.verifyBuildLevel(result)
+}
+
+// verifyChain checks the leaf certificate chains to a trusted Fulcio root at the given time
+func verifyChain(leaf *x509.Certificate, intermediates []*x509.Certificate, trust TrustRoot, at time.Time) error {
+	trusted, err := parseCertificates(trust.FulcioCertificates)
+	if err != nil {
+		return Wrap(CodeCertificateUntrusted, err, "Trust root certificates are invalid")
+	}
+	if len(trusted) == 0 {
+		return Errorf(CodeCertificateUntrusted, "Trust root has no Fulcio certificates")
+	}
+
+	roots, pool := x509.NewCertPool(), x509.NewCertPool()
+	for _, cert := range trusted {
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+			roots.AddCert(cert)
+		} else {
+			pool.AddCert(cert)
+		}
+	}
+	for _, cert := range intermediates {
+		pool.AddCert(cert)
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: pool,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return Wrap(CodeCertificateUntrusted, err, "Signing certificate does not chain to the trusted Fulcio root")
+	}
+	return nil
+}
+
+// verifyTimestamps checks every embedded timestamp covers an envelope signature
+// and chains to a trusted timestamp authority, returning the earliest trusted time
+func verifyTimestamps(bundle *Bundle, trust TrustRoot) (time.Time, error) {
+	trusted, err := parseCertificates(trust.TimestampAuthorities)
+	if err != nil {
+		return time.Time{}, Wrap(CodeTimestampInvalid, err, "Trust root timestamp authority certificates are invalid")
+	}
+	if len(trusted) == 0 {
+		return time.Time{}, Errorf(CodeTimestampInvalid, "Trust root has no timestamp authorities")
+	}
+	roots := x509.NewCertPool()
+	for _, cert := range trusted {
+		roots.AddCert(cert)
+	}
+
+	var earliest time.Time
+	for _, encoded := range bundle.Timestamps {
+		token, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return time.Time{}, Wrap(CodeTimestampInvalid, err, "Bundle timestamp is not valid base64")
+		}
+
+		var ts *Timestamp
+		for _, signature := range bundle.Envelope.Signatures {
+			sig, decodeErr := base64.StdEncoding.DecodeString(signature.Sig)
+			if decodeErr != nil {
+				continue
+			}
+			if ts, err = VerifyTimestamp(token, sig, roots); err == nil {
+				break
+			}
+		}
+		if ts == nil {
+			if err == nil {
+				err = Errorf(CodeTimestampInvalid, "Timestamp does not cover an envelope signature")
+			}
+			return time.Time{}, err
+		}
+		if earliest.IsZero() || ts.Time.Before(earliest) {
+			earliest = ts.Time
+		}
+	}
+	return earliest, nil
+}
+
+// setPayload is the canonical JSON Rekor signs in a signed entry timestamp
+type setPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// tlogBody is the part of a dsse (v0.0.1) or intoto (v0.0.2) Rekor entry that
+// binds it to one envelope: the payload hash, and each signature with the base64
+// PEM certificate or public key that verifies it
+type tlogBody struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Spec       struct {
+		PayloadHash tlogHash `json:"payloadHash"`
+		Signatures  []struct {
+			Signature string `json:"signature"` // Base64 signature
+			Verifier  string `json:"verifier"`
+		} `json:"signatures"`
+		Content struct {
+			PayloadHash tlogHash `json:"payloadHash"`
+			Envelope    struct {
+				Signatures []struct {
+					Sig       string `json:"sig"` // Base64 of the envelope's base64 signature
+					PublicKey string `json:"publicKey"`
+				} `json:"signatures"`
+			} `json:"envelope"`
+		} `json:"content"`
+	} `json:"spec"`
+}
+
+// tlogHash is a digest recorded in a Rekor entry
+type tlogHash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"` // Hex
+}
+
+// tlogSignature is one signature a Rekor entry records, decoded
+type tlogSignature struct {
+	sig      []byte
+	verifier string // Base64 PEM
+}
+
+// signatures returns the entry's payload hash and signatures, or an error for
+// entry kinds that don't record a DSSE envelope
+func (b *tlogBody) signatures() (tlogHash, []tlogSignature, error) {
+	var signatures []tlogSignature
+	switch {
+	case b.Kind == "dsse" && b.APIVersion == "0.0.1":
+		for _, signature := range b.Spec.Signatures {
+			sig, err := base64.StdEncoding.DecodeString(signature.Signature)
+			if err != nil {
+				return tlogHash{}, nil, Wrap(CodeRekorEntryNotFound, err, "Transparency log entry signature is not valid base64")
+			}
+			signatures = append(signatures, tlogSignature{sig: sig, verifier: signature.Verifier})
+		}
+		return b.Spec.PayloadHash, signatures, nil
+	case b.Kind == "intoto" && b.APIVersion == "0.0.2":
+		for _, signature := range b.Spec.Content.Envelope.Signatures {
+			encoded, err := base64.StdEncoding.DecodeString(signature.Sig)
+			if err != nil {
+				return tlogHash{}, nil, Wrap(CodeRekorEntryNotFound, err, "Transparency log entry signature is not valid base64")
+			}
+			sig, err := base64.StdEncoding.DecodeString(string(encoded))
+			if err != nil {
+				return tlogHash{}, nil, Wrap(CodeRekorEntryNotFound, err, "Transparency log entry signature is not valid base64")
+			}
+			signatures = append(signatures, tlogSignature{sig: sig, verifier: signature.PublicKey})
+		}
+		return b.Spec.Content.PayloadHash, signatures, nil
+	}
+	return tlogHash{}, nil, Errorf(CodeRekorEntryNotFound, "Transparency log entry kind %s %s does not record a DSSE envelope", b.Kind, b.APIVersion)
+}
+
+// verifiedBy reports whether a base64 PEM verifier from a Rekor entry is the
+// leaf certificate or its public key
+func verifiedBy(verifier string, leaf *x509.Certificate) bool {
+	data, err := base64.StdEncoding.DecodeString(verifier)
+	if err != nil {
+		return false
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return false
+	}
+	switch block.Type {
+	case "CERTIFICATE":
+		return bytes.Equal(block.Bytes, leaf.Raw)
+	case "PUBLIC KEY":
+		return bytes.Equal(block.Bytes, leaf.RawSubjectPublicKeyInfo)
+	}
+	return false
+}
+
+// verifyTlogEntry checks the SET against the trusted Rekor keys and that the
+// entry records the envelope's payload, signed by one of the envelope's
+// signatures and the leaf certificate. The SET alone only vouches that Rekor
+// logged the body, so an entry that merely mentions the payload must not lend
+// its integrated time to another signature.
+func verifyTlogEntry(entry *TlogEntry, envelope *Envelope, leaf *x509.Certificate, trust TrustRoot) error {
+	payload, err := json.Marshal(setPayload{
+		Body:           entry.Body,
+		IntegratedTime: entry.IntegratedTime,
+		LogID:          entry.LogID,
+		LogIndex:       entry.LogIndex,
+	})
+	if err != nil {
+		return Wrap(CodeRekorSETInvalid, err, "Failed to encode signed entry timestamp payload")
+	}
+
+	set, err := base64.StdEncoding.DecodeString(entry.SignedEntryTimestamp)
+	if err != nil || len(set) == 0 {
+		return Errorf(CodeRekorSETInvalid, "Transparency log entry has no valid signed entry timestamp")
+	}
+
+	digest := sha256.Sum256(payload)
+	verified := false
+	for _, encoded := range trust.RekorPublicKeys {
+		key, err := parsePublicKey(encoded)
+		if err != nil {
+			return Wrap(CodeRekorSETInvalid, err, "Trust root Rekor key is invalid")
+		}
+		if verifyWithKey(key, digest[:], payload, set) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return Errorf(CodeRekorSETInvalid, "Signed entry timestamp was not signed by a trusted Rekor key")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(entry.Body)
+	if err != nil {
+		return Wrap(CodeRekorSETInvalid, err, "Transparency log entry body is not valid base64")
+	}
+	var body tlogBody
+	if err := json.Unmarshal(data, &body); err != nil {
+		return Wrap(CodeRekorEntryNotFound, err, "Transparency log entry body is not valid JSON")
+	}
+	recordedHash, recorded, err := body.signatures()
+	if err != nil {
+		return err
+	}
+
+	envelopePayload, err := envelope.DecodePayload()
+	if err != nil {
+		return err
+	}
+	payloadHash := sha256.Sum256(envelopePayload)
+	if recordedHash.Algorithm != "sha256" || recordedHash.Value != hex.EncodeToString(payloadHash[:]) {
+		return Errorf(CodeRekorEntryNotFound, "Transparency log entry does not record this envelope")
+	}
+
+	for _, signature := range envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(signature.Sig)
+		if err != nil {
+			continue
+		}
+		for _, logged := range recorded {
+			if bytes.Equal(logged.sig, sig) && verifiedBy(logged.verifier, leaf) {
+				return nil
+			}
+		}
+	}
+	return Errorf(CodeRekorEntryNotFound, "Transparency log entry does not record this envelope's signature and certificate")
+}
+
+// parseCertificates decodes a list of PEM certificates
+func parseCertificates(encoded []string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for _, data := range encoded {
+		rest := []byte(data)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		}
+	}
+	return certs, nil
+}
+
+// parsePublicKey decodes a PEM PKIX public key
+func parsePublicKey(encoded string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// verifyWithKey verifies sig over message (or its SHA-256 digest) with a public key
+func verifyWithKey(key crypto.PublicKey, digest, message, sig []byte) bool {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest, sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig) == nil ||
+			rsa.VerifyPSS(key, crypto.SHA256, digest, sig, nil) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, message, sig)
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package attestation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Claims are the decoded claims of a CI job OIDC token
+type Claims map[string]interface{}
+
+// DecodeClaims decodes the payload of an OIDC JWT without verifying its
+// signature; the token is verified by Fulcio when the signing certificate is issued
+func DecodeClaims(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, Errorf(CodeOIDCTokenRequestFailed, "OIDC token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, Wrap(CodeOIDCTokenRequestFailed, err, "OIDC token payload is not valid base64")
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, Wrap(CodeOIDCTokenRequestFailed, err, "OIDC token payload is not valid JSON")
+	}
+	return claims, nil
+}
+
+// ClaimMapping copies a value rendered from OIDC claims into signing annotations
+type ClaimMapping struct {
+	Key       string `json:"key"`                 // Annotation and predicate field name
+	Template  string `json:"template"`            // text/template over the claims, e.g. {{.environment}}
+	Required  bool   `json:"required,omitempty"`  // Fail signing when the rendered value is empty
+	Predicate bool   `json:"predicate,omitempty"` // Also record the value in attestation predicates
+}
+
+// DefaultClaimMappings returns the GitHub Actions mappings applied when none
+// are configured; see IssuerProfile for other platforms
+func DefaultClaimMappings() []ClaimMapping {
+	return []ClaimMapping{
+		{Key: "keystone.oidc.environment", Template: "{{.environment}}", Predicate: true},
+		{Key: "keystone.oidc.ref_type", Template: "{{.ref_type}}", Predicate: true},
+		{Key: "keystone.oidc.job_workflow_ref", Template: "{{.job_workflow_ref}}", Predicate: true},
+		{Key: "keystone.oidc.runner_environment", Template: "{{.runner_environment}}", Predicate: true},
+	}
+}
+
+// LoadClaimMappings reads a JSON array of claim mappings from a file
+func LoadClaimMappings(path string) ([]ClaimMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read claim mappings: %w", err)
+	}
+
+	var mappings []ClaimMapping
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		return nil, fmt.Errorf("failed to parse claim mappings %s: %w", path, err)
+	}
+	return mappings, nil
+}
+
+// ClaimValues are the values rendered by a ClaimMapper
+type ClaimValues struct {
+	Annotations map[string]string `json:"annotations"`
+	Predicate   map[string]string `json:"predicate,omitempty"`
+}
+
+// ClaimMapper renders configured OIDC claims into annotations and predicate fields
+type ClaimMapper struct {
+	mappings  []ClaimMapping
+	templates []*template.Template
+}
+
+// NewClaimMapper compiles claim mapping templates
+func NewClaimMapper(mappings []ClaimMapping) (*ClaimMapper, error) {
+	m := &ClaimMapper{mappings: mappings}
+	seen := make(map[string]bool)
+	for _, mapping := range mappings {
+		if mapping.Key == "" {
+			return nil, fmt.Errorf("claim mapping has no key")
+		}
+		if seen[mapping.Key] {
+			return nil, fmt.Errorf("duplicate claim mapping for %s", mapping.Key)
+		}
+		seen[mapping.Key] = true
+
+		// Missing claims render as empty strings rather than "<no value>"
+		tmpl, err := template.New(mapping.Key).Option("missingkey=zero").Parse(mapping.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for claim mapping %s: %w", mapping.Key, err)
+		}
+		m.templates = append(m.templates, tmpl)
+	}
+	return m, nil
+}
+
+// Map renders every mapping against the claims; empty values are omitted
+func (m *ClaimMapper) Map(claims Claims) (*ClaimValues, error) {
+	values := &ClaimValues{
+		Annotations: make(map[string]string),
+		Predicate:   make(map[string]string),
+	}
+
+	var missing []string
+	for i, mapping := range m.mappings {
+		var rendered bytes.Buffer
+		if err := m.templates[i].Execute(&rendered, map[string]interface{}(claims)); err != nil {
+			return nil, Wrap(CodeSigningFailed, err, "Failed to render claim mapping %s", mapping.Key)
+		}
+
+		value := strings.TrimSpace(rendered.String())
+		if value == "" || value == "<no value>" {
+			if mapping.Required {
+				missing = append(missing, mapping.Key)
+			}
+			continue
+		}
+
+		values.Annotations[mapping.Key] = value
+		if mapping.Predicate {
+			values.Predicate[mapping.Key] = value
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, Errorf(CodeMissingSubject, "OIDC token is missing claims required by %s", strings.Join(missing, ", "))
+	}
+	return values, nil
+}
+
+// Apply renders the mappings into the signing metadata annotations
+func (m *ClaimMapper) Apply(metadata *SigningMetadata, claims Claims) (*ClaimValues, error) {
+	values, err := m.Map(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata.Annotations == nil {
+		metadata.Annotations = make(map[string]string)
+	}
+	for key, value := range values.Annotations {
+		metadata.Annotations[key] = value
+	}
+	return values, nil
+}
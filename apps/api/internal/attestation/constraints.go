@@ -0,0 +1,142 @@
+package attestation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// SigningConstraints restrict which pipelines may sign, by the claims of
+// their OIDC token. Each list holds path.Match patterns; an empty list
+// doesn't restrict that claim, and a token must satisfy every non-empty one.
+type SigningConstraints struct {
+	Repositories []string `json:"repositories,omitempty"`  // owner/repo, e.g. my-org/*
+	Branches     []string `json:"branches,omitempty"`      // Branch names, e.g. main or release/*
+	Tags         []string `json:"tags,omitempty"`          // Tag names, e.g. v*
+	Environments []string `json:"environments,omitempty"`  // Deployment environments, e.g. production
+	WorkflowRefs []string `json:"workflow_refs,omitempty"` // job_workflow_ref of the reusable workflow, e.g. my-org/signing/.github/workflows/sign.yml@refs/tags/v*
+	EventNames   []string `json:"event_names,omitempty"`   // Triggering events, e.g. push or release
+}
+
+// LoadSigningConstraints reads signing constraints from a JSON file
+func LoadSigningConstraints(filePath string) (*SigningConstraints, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing constraints: %w", err)
+	}
+
+	var constraints SigningConstraints
+	if err := json.Unmarshal(data, &constraints); err != nil {
+		return nil, fmt.Errorf("failed to parse signing constraints %s: %w", filePath, err)
+	}
+	if err := constraints.Validate(); err != nil {
+		return nil, err
+	}
+	return &constraints, nil
+}
+
+// Validate rejects malformed patterns
+func (c *SigningConstraints) Validate() error {
+	lists := map[string][]string{
+		"repositories":  c.Repositories,
+		"branches":      c.Branches,
+		"tags":          c.Tags,
+		"environments":  c.Environments,
+		"workflow_refs": c.WorkflowRefs,
+		"event_names":   c.EventNames,
+	}
+	for name, patterns := range lists {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("signing constraints: invalid %s pattern %q: %w", name, pattern, err)
+			}
+		}
+	}
+	for _, pattern := range c.WorkflowRefs {
+		if !strings.Contains(pattern, "@") {
+			return fmt.Errorf("signing constraints: workflow_refs pattern %q must include a ref", pattern)
+		}
+	}
+	return nil
+}
+
+// Authorize checks the token's claims against every constraint, failing
+// with the code of the first one violated. The repository, ref and
+// environment fall back to those in the sub claim for tokens without the
+// dedicated claims.
+func (c *SigningConstraints) Authorize(claims Claims) error {
+	subject := subjectClaim(claims)
+	if subject == nil {
+		subject = &SubjectClaim{}
+	}
+	claimOrSubject := func(name, fromSubject string) string {
+		if value := claimString(claims, name); value != "" {
+			return value
+		}
+		return fromSubject
+	}
+
+	if len(c.Repositories) > 0 {
+		repository := claimOrSubject("repository", subject.Repository)
+		if !matchesAny(c.Repositories, repository, true) {
+			return Errorf(CodeRepositoryNotAllowed, "Repository %q is not permitted to sign", repository)
+		}
+	}
+
+	if len(c.EventNames) > 0 {
+		event := claimString(claims, "event_name")
+		if !matchesAny(c.EventNames, event, false) {
+			return Errorf(CodeEventNotAllowed, "Signing is not permitted from %q events", event)
+		}
+	}
+
+	if len(c.Branches) > 0 || len(c.Tags) > 0 {
+		ref := claimOrSubject("ref", subject.Ref)
+		branch, isBranch := strings.CutPrefix(ref, "refs/heads/")
+		tag, isTag := strings.CutPrefix(ref, "refs/tags/")
+		if !(isBranch && matchesAny(c.Branches, branch, false)) && !(isTag && matchesAny(c.Tags, tag, false)) {
+			return Errorf(CodeRefNotAllowed, "Signing is not permitted from ref %q", ref)
+		}
+	}
+
+	if len(c.Environments) > 0 {
+		environment := claimOrSubject("environment", subject.Environment)
+		if environment == "" {
+			return Errorf(CodeEnvironmentNotAllowed, "Signing requires a deployment environment, and the job has none")
+		}
+		if !matchesAny(c.Environments, environment, false) {
+			return Errorf(CodeEnvironmentNotAllowed, "Signing is not permitted from environment %q", environment)
+		}
+	}
+
+	if len(c.WorkflowRefs) > 0 {
+		workflowRef := claimString(claims, "job_workflow_ref")
+		if !matchesAny(c.WorkflowRefs, workflowRef, true) {
+			return Errorf(CodeWorkflowNotApproved, "Workflow %q is not permitted to sign", workflowRef)
+		}
+	}
+	return nil
+}
+
+// matchesAny reports whether a non-empty value matches any pattern. GitHub
+// owner and repository names are case-insensitive, so they match regardless
+// of case.
+func matchesAny(patterns []string, value string, foldCase bool) bool {
+	if value == "" {
+		return false
+	}
+	if foldCase {
+		value = strings.ToLower(value)
+	}
+	for _, pattern := range patterns {
+		if foldCase {
+			pattern = strings.ToLower(pattern)
+		}
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
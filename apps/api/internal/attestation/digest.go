@@ -0,0 +1,346 @@
+package attestation
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Digest algorithms local artifacts can be digested with
+const (
+	DigestSHA256 = "sha256"
+	DigestSHA512 = "sha512"
+)
+
+// newDigest returns the hash for a supported algorithm
+func newDigest(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case DigestSHA256:
+		return sha256.New(), nil
+	case DigestSHA512:
+		return sha512.New(), nil
+	default:
+		return nil, Errorf(CodeTargetNotResolved, "Unsupported digest algorithm %q; use sha256 or sha512", algorithm)
+	}
+}
+
+// digesters creates one hash per algorithm, defaulting to SHA-256
+func digesters(algorithms []string) (map[string]hash.Hash, error) {
+	if len(algorithms) == 0 {
+		algorithms = []string{DigestSHA256}
+	}
+	hashes := make(map[string]hash.Hash, len(algorithms))
+	for _, algorithm := range algorithms {
+		h, err := newDigest(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		hashes[algorithm] = h
+	}
+	return hashes, nil
+}
+
+// sums returns the hex digest of each hash
+func sums(hashes map[string]hash.Hash) DigestSet {
+	set := make(DigestSet, len(hashes))
+	for algorithm, h := range hashes {
+		set[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+	return set
+}
+
+// DigestReader digests the content read from r with each algorithm, SHA-256
+// when none are given, in a single pass
+func DigestReader(name string, r io.Reader, algorithms ...string) (Subject, error) {
+	hashes, err := digesters(algorithms)
+	if err != nil {
+		return Subject{}, err
+	}
+	writers := make([]io.Writer, 0, len(hashes))
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return Subject{}, Wrap(CodeTargetNotResolved, err, "Failed to read %s", name)
+	}
+
+	subject := Subject{Name: name, Digest: sums(hashes)}
+	return subject, subject.Validate()
+}
+
+// DigestPath digests a local artifact named by its base name: a file by its
+// content and a directory with DigestDirectory
+func DigestPath(artifactPath string, algorithms ...string) (Subject, error) {
+	info, err := os.Stat(artifactPath)
+	if err != nil {
+		return Subject{}, Wrap(CodeTargetNotResolved, err, "Failed to open %s", artifactPath)
+	}
+	if info.IsDir() {
+		return DigestDirectory(artifactPath, algorithms...)
+	}
+
+	f, err := os.Open(artifactPath)
+	if err != nil {
+		return Subject{}, Wrap(CodeTargetNotResolved, err, "Failed to open %s", artifactPath)
+	}
+	defer f.Close()
+	return DigestReader(filepath.Base(artifactPath), f, algorithms...)
+}
+
+// Directory tree entry types hashed by DigestDirectory
+const (
+	treeFile       = 'f'
+	treeExecutable = 'x'
+	treeSymlink    = 'l'
+	treeDirectory  = 'd'
+)
+
+// DigestDirectory digests a directory tree as a Merkle tree, so the digest
+// depends only on names, content, executable bits and symlink targets, not on
+// timestamps, ownership or the order the filesystem lists entries in. Each
+// entry contributes its type byte, name, a NUL and its digest, in name order:
+// a file's digest is that of its content, a symlink's that of its target
+// (links are not followed) and a subdirectory's is computed the same way.
+func DigestDirectory(dir string, algorithms ...string) (Subject, error) {
+	if len(algorithms) == 0 {
+		algorithms = []string{DigestSHA256}
+	}
+
+	digest := make(DigestSet, len(algorithms))
+	for _, algorithm := range algorithms {
+		sum, err := digestTree(dir, algorithm)
+		if err != nil {
+			return Subject{}, err
+		}
+		digest[algorithm] = hex.EncodeToString(sum)
+	}
+
+	subject := Subject{Name: filepath.Base(filepath.Clean(dir)), Digest: digest}
+	return subject, subject.Validate()
+}
+
+// digestTree returns the Merkle digest of one directory
+func digestTree(dir, algorithm string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, Wrap(CodeTargetNotResolved, err, "Failed to read directory %s", dir)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	tree, err := newDigest(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		entryPath := filepath.Join(dir, entry.Name())
+		kind, sum, err := digestTreeEntry(entryPath, entry, algorithm)
+		if err != nil {
+			return nil, err
+		}
+		tree.Write([]byte{kind})
+		tree.Write([]byte(entry.Name()))
+		tree.Write([]byte{0})
+		tree.Write(sum)
+	}
+	return tree.Sum(nil), nil
+}
+
+// digestTreeEntry returns the type byte and digest of one directory entry
+func digestTreeEntry(entryPath string, entry fs.DirEntry, algorithm string) (byte, []byte, error) {
+	switch {
+	case entry.Type()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(entryPath)
+		if err != nil {
+			return 0, nil, Wrap(CodeTargetNotResolved, err, "Failed to read symlink %s", entryPath)
+		}
+		h, _ := newDigest(algorithm)
+		h.Write([]byte(filepath.ToSlash(target)))
+		return treeSymlink, h.Sum(nil), nil
+
+	case entry.IsDir():
+		sum, err := digestTree(entryPath, algorithm)
+		return treeDirectory, sum, err
+
+	case entry.Type().IsRegular():
+		info, err := entry.Info()
+		if err != nil {
+			return 0, nil, Wrap(CodeTargetNotResolved, err, "Failed to stat %s", entryPath)
+		}
+		kind := byte(treeFile)
+		if info.Mode()&0o111 != 0 {
+			kind = treeExecutable
+		}
+		f, err := os.Open(entryPath)
+		if err != nil {
+			return 0, nil, Wrap(CodeTargetNotResolved, err, "Failed to open %s", entryPath)
+		}
+		defer f.Close()
+		h, _ := newDigest(algorithm)
+		if _, err := io.Copy(h, f); err != nil {
+			return 0, nil, Wrap(CodeTargetNotResolved, err, "Failed to read %s", entryPath)
+		}
+		return kind, h.Sum(nil), nil
+
+	default:
+		return 0, nil, Errorf(CodeTargetNotResolved, "%s is not a regular file, directory or symlink", entryPath)
+	}
+}
+
+// OCI image layout annotation naming a manifest in index.json
+const annotationRefName = "org.opencontainers.image.ref.name"
+
+// ociIndex is the subset of an OCI image layout's index.json we read
+type ociIndex struct {
+	Manifests []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"manifests"`
+}
+
+// DigestOCILayout returns a subject for each manifest listed in the index of
+// an OCI image layout, given as a directory or a tar archive (optionally
+// gzipped) as written by docker buildx --output type=oci. Manifests are
+// named by their ref name annotation, falling back to the layout's name, and
+// each manifest blob is re-hashed against its listed digest so a tampered
+// layout is rejected.
+func DigestOCILayout(layoutPath string) ([]Subject, error) {
+	info, err := os.Stat(layoutPath)
+	if err != nil {
+		return nil, Wrap(CodeTargetNotResolved, err, "Failed to open %s", layoutPath)
+	}
+
+	var files map[string][]byte
+	if info.IsDir() {
+		files, err = readLayoutDir(layoutPath)
+	} else {
+		files, err = readLayoutArchive(layoutPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, found := files["index.json"]
+	if !found {
+		return nil, Errorf(CodeTargetNotResolved, "%s is not an OCI image layout: no index.json", layoutPath)
+	}
+	var index ociIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, Wrap(CodeTargetNotResolved, err, "%s has an invalid index.json", layoutPath)
+	}
+	if len(index.Manifests) == 0 {
+		return nil, Errorf(CodeTargetNotResolved, "%s lists no manifests", layoutPath)
+	}
+
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(layoutPath), ".gz"), ".tar")
+	subjects := make([]Subject, 0, len(index.Manifests))
+	for _, manifest := range index.Manifests {
+		algorithm, value, _ := strings.Cut(manifest.Digest, ":")
+		blob, found := files[path.Join("blobs", algorithm, value)]
+		if !found {
+			return nil, Errorf(CodeTargetNotResolved, "%s is missing manifest blob %s", layoutPath, manifest.Digest)
+		}
+		h, err := newDigest(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(blob)
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != value {
+			return nil, Errorf(CodeTargetNotResolved, "Manifest blob %s in %s hashes to %s:%s", manifest.Digest, layoutPath, algorithm, actual)
+		}
+
+		subjectName := name
+		if ref := manifest.Annotations[annotationRefName]; ref != "" {
+			subjectName = ref
+		}
+		subject, err := NewSubject(subjectName, manifest.Digest)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, subject)
+	}
+	return subjects, nil
+}
+
+// readLayoutDir reads index.json and the manifest blobs it lists from a
+// layout directory, leaving layers unread
+func readLayoutDir(dir string) (map[string][]byte, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return nil, Wrap(CodeTargetNotResolved, err, "%s is not an OCI image layout: no index.json", dir)
+	}
+	files := map[string][]byte{"index.json": data}
+
+	var index ociIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return files, nil
+	}
+	for _, manifest := range index.Manifests {
+		algorithm, value, _ := strings.Cut(manifest.Digest, ":")
+		name := path.Join("blobs", algorithm, value)
+		blob, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+		files[name] = blob
+	}
+	return files, nil
+}
+
+// maxLayoutFile bounds the archive entries read into memory; manifests and
+// indexes are small, layers are skipped
+const maxLayoutFile = 4 << 20
+
+// readLayoutArchive reads the index and small blobs of a layout tar archive
+func readLayoutArchive(archivePath string) (map[string][]byte, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, Wrap(CodeTargetNotResolved, err, "Failed to open %s", archivePath)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var r io.Reader = reader
+	if magic, _ := reader.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, Wrap(CodeTargetNotResolved, err, "%s is not a valid gzip archive", archivePath)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	files := make(map[string][]byte)
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, Wrap(CodeTargetNotResolved, err, "%s is not a valid tar archive", archivePath)
+		}
+		if header.Typeflag != tar.TypeReg || header.Size > maxLayoutFile {
+			continue
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return nil, Wrap(CodeTargetNotResolved, err, "Failed to read %s from %s", header.Name, archivePath)
+		}
+		files[path.Clean(strings.TrimPrefix(header.Name, "./"))] = data
+	}
+	return files, nil
+}
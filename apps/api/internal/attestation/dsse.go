@@ -0,0 +1,94 @@
+package attestation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// PayloadTypeInToto is the DSSE payload type of in-toto statements
+const PayloadTypeInToto = "application/vnd.in-toto+json"
+
+// Envelope is a DSSE envelope carrying a signed payload
+type Envelope struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     string              `json:"payload"` // Base64 encoded
+	Signatures  []EnvelopeSignature `json:"signatures"`
+}
+
+// EnvelopeSignature is one signature over the envelope's PAE encoding
+type EnvelopeSignature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"` // Base64 encoded
+}
+
+// NewEnvelope wraps an in-toto statement in an unsigned DSSE envelope. The
+// statement is encoded as canonical JSON, so identical statements always sign
+// and digest identically.
+func NewEnvelope(statement *Statement) (*Envelope, error) {
+	payload, err := CanonicalJSON(statement)
+	if err != nil {
+		return nil, Wrap(CodeSigningFailed, err, "Failed to encode statement")
+	}
+	return &Envelope{
+		PayloadType: PayloadTypeInToto,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []EnvelopeSignature{},
+	}, nil
+}
+
+// ParseEnvelope decodes a JSON DSSE envelope, rejecting envelopes whose
+// payload or signatures are not valid base64 so later stages can rely on them
+func ParseEnvelope(data []byte) (*Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, Wrap(CodeVerificationFailed, err, "Envelope is not valid JSON")
+	}
+	if envelope.PayloadType == "" {
+		return nil, Errorf(CodeVerificationFailed, "Envelope has no payload type")
+	}
+	if _, err := envelope.DecodePayload(); err != nil {
+		return nil, err
+	}
+	for i, signature := range envelope.Signatures {
+		if _, err := base64.StdEncoding.DecodeString(signature.Sig); err != nil {
+			return nil, Wrap(CodeVerificationFailed, err, "Envelope signature %d is not valid base64", i)
+		}
+	}
+	if envelope.Signatures == nil {
+		envelope.Signatures = []EnvelopeSignature{}
+	}
+	return &envelope, nil
+}
+
+// DecodePayload returns the raw payload bytes
+func (e *Envelope) DecodePayload() ([]byte, error) {
+	payload, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, Wrap(CodeVerificationFailed, err, "Envelope payload is not valid base64")
+	}
+	return payload, nil
+}
+
+// Statement decodes the in-toto statement carried by the envelope
+func (e *Envelope) Statement() (*Statement, error) {
+	if e.PayloadType != PayloadTypeInToto {
+		return nil, Errorf(CodeVerificationFailed, "Envelope payload type %q is not an in-toto statement", e.PayloadType)
+	}
+
+	payload, err := e.DecodePayload()
+	if err != nil {
+		return nil, err
+	}
+
+	var statement Statement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return nil, Wrap(CodeVerificationFailed, err, "Envelope payload is not a valid statement")
+	}
+	return &statement, nil
+}
+
+// PAE returns the DSSE pre-authentication encoding that signatures are computed over
+func PAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
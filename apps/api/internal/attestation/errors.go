@@ -0,0 +1,102 @@
+package attestation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Signing and verification error codes shared by the API, CLI and workflow logs
+const (
+	CodeOIDCTokenUnavailable   = "SIGN_001"
+	CodeOIDCURLUnavailable     = "SIGN_002"
+	CodeOIDCTokenRequestFailed = "SIGN_003"
+	CodeInvalidIssuer          = "SIGN_004"
+	CodeInvalidAudience        = "SIGN_005"
+	CodeMissingSubject         = "SIGN_006"
+	CodeInvalidTokenSignature  = "SIGN_007"
+	CodeTokenExpired           = "SIGN_008"
+	CodeChecksumMismatch       = "SIGN_011"
+	CodeBlobDigestMismatch     = "SIGN_012"
+	CodeSubjectMismatch        = "SIGN_013"
+	CodeTargetNotResolved      = "SIGN_021"
+	CodeSigningFailed          = "SIGN_031"
+	CodeRegistryPushFailed     = "SIGN_032"
+	CodeKeyResidencyUnverified = "SIGN_033"
+	CodeTimestampFailed        = "SIGN_034"
+	CodePublicKeyExtraction    = "SIGN_041"
+	CodeRekorEntryNotFound     = "SIGN_042"
+	CodeCertificateUntrusted   = "SIGN_045"
+	CodeRekorSETInvalid        = "SIGN_046"
+	CodeTimestampInvalid       = "SIGN_047"
+	CodeTrustRootInvalid       = "SIGN_048"
+	CodeVerificationFailed     = "SIGN_051"
+	CodeAttestationNotFound    = "SIGN_052"
+	CodeIssuerMismatch         = "SIGN_053"
+	CodeSANMismatch            = "SIGN_054"
+	CodeRepositoryMismatch     = "SIGN_055"
+	CodeWorkflowMismatch       = "SIGN_056"
+	CodeBranchMismatch         = "SIGN_057"
+	CodeThresholdNotMet        = "SIGN_058"
+	CodeNonCanonicalPayload    = "SIGN_059"
+	CodeIdentityChanged        = "SIGN_060"
+	CodeSBOMSigningFailed      = "SIGN_061"
+	CodeBuildLevelTooLow       = "SIGN_062"
+	CodeNetworkTimeout         = "SIGN_071"
+	CodeSigningUnavailable     = "SIGN_072"
+	CodePermissionDenied       = "SIGN_081"
+	CodeWorkflowNotApproved    = "SIGN_082"
+	CodeWorkflowRefNotPinned   = "SIGN_083"
+	CodeRepositoryNotAllowed   = "SIGN_084"
+	CodeRefNotAllowed          = "SIGN_085"
+	CodeEnvironmentNotAllowed  = "SIGN_086"
+	CodeEventNotAllowed        = "SIGN_087"
+	CodeFederationFailed       = "SIGN_088"
+)
+
+// Error is a coded signing or verification error
+type Error struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+// Errorf creates a coded error with a formatted message
+func Errorf(code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap creates a coded error that wraps an underlying cause
+func Wrap(code string, err error, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// wrapUncoded wraps err with a code unless it already carries one, so causes
+// such as permission or network failures keep their more specific code
+func wrapUncoded(code string, err error, format string, args ...interface{}) error {
+	if CodeOf(err) != "" {
+		return err
+	}
+	return Wrap(code, err, format, args...)
+}
+
+// Error renders the error as "SIGN_xxx: message[: cause]"
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// CodeOf extracts the SIGN_ code from an error chain, or "" if none is present
+func CodeOf(err error) string {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	return ""
+}
@@ -0,0 +1,308 @@
+package attestation
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/salman-frs/keystone/apps/api/internal/messages"
+)
+
+// Fulcio certificate extension OIDs (https://github.com/sigstore/fulcio/blob/main/docs/oid-info.md)
+var (
+	oidIssuerV1             = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidWorkflowRepositoryV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 5}
+	oidWorkflowRefV1        = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 6}
+	oidIssuer               = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+	oidBuildSignerURI       = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 9}
+	oidSourceRepositoryURI  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 12}
+	oidSourceRepositoryRef  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 14}
+)
+
+// CertificateIdentity is the signer identity recorded in a Fulcio certificate
+type CertificateIdentity struct {
+	Issuer      string `json:"issuer"`
+	SAN         string `json:"san"`          // URI or email subject alternative name
+	Repository  string `json:"repository"`   // owner/repo
+	WorkflowRef string `json:"workflow_ref"` // owner/repo/.github/workflows/file.yml@ref
+	Ref         string `json:"ref"`          // Git ref the workflow ran for, e.g. refs/heads/main
+
+	// Environment is the job's deployment environment. Certificates don't
+	// record it, so it's only known for identities derived from tokens.
+	Environment string `json:"environment,omitempty"`
+}
+
+// ParseCertificateIdentity extracts the signer identity from a Fulcio certificate,
+// preferring the v2 extensions and falling back to the deprecated v1 ones
+func ParseCertificateIdentity(cert *x509.Certificate) *CertificateIdentity {
+	identity := &CertificateIdentity{}
+
+	switch {
+	case len(cert.URIs) > 0:
+		identity.SAN = cert.URIs[0].String()
+	case len(cert.EmailAddresses) > 0:
+		identity.SAN = cert.EmailAddresses[0]
+	}
+
+	var repositoryURI, buildSigner string
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuer):
+			identity.Issuer = derString(ext.Value)
+		case ext.Id.Equal(oidIssuerV1) && identity.Issuer == "":
+			identity.Issuer = string(ext.Value)
+		case ext.Id.Equal(oidSourceRepositoryURI):
+			repositoryURI = derString(ext.Value)
+		case ext.Id.Equal(oidWorkflowRepositoryV1) && identity.Repository == "":
+			identity.Repository = string(ext.Value)
+		case ext.Id.Equal(oidSourceRepositoryRef):
+			identity.Ref = derString(ext.Value)
+		case ext.Id.Equal(oidWorkflowRefV1) && identity.Ref == "":
+			identity.Ref = string(ext.Value)
+		case ext.Id.Equal(oidBuildSignerURI):
+			buildSigner = derString(ext.Value)
+		}
+	}
+
+	if repositoryURI != "" {
+		identity.Repository = uriPath(repositoryURI)
+	}
+	if buildSigner == "" && strings.Contains(identity.SAN, "/.github/workflows/") {
+		// Older certificates only carry the workflow in the SAN
+		buildSigner = identity.SAN
+	}
+	identity.WorkflowRef = uriPath(buildSigner)
+
+	return identity
+}
+
+// derString decodes a DER-encoded string extension value, as used by the v2 OIDs
+func derString(value []byte) string {
+	var s string
+	if _, err := asn1.Unmarshal(value, &s); err != nil {
+		return ""
+	}
+	return s
+}
+
+// uriPath strips the scheme and host from a GitHub URI, leaving owner/repo[/...]
+func uriPath(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return raw
+	}
+	return strings.TrimPrefix(parsed.Path, "/")
+}
+
+// IdentityPolicy constrains which certificate identities may sign an attestation;
+// empty fields are not enforced
+type IdentityPolicy struct {
+	Issuer      string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	SANRegexp   string `json:"san_regexp,omitempty" yaml:"san_regexp,omitempty"`
+	Repository  string `json:"repository,omitempty" yaml:"repository,omitempty"`     // owner/repo
+	WorkflowRef string `json:"workflow_ref,omitempty" yaml:"workflow_ref,omitempty"` // Path from the repository root, or owner/repo/path@ref to pin the ref
+	Branch      string `json:"branch,omitempty" yaml:"branch,omitempty"`             // Branch name or full ref
+
+	// RequireCanonical rejects payloads that are not RFC 8785 canonical JSON.
+	// Keystone always signs canonical payloads; other producers may not.
+	RequireCanonical bool `json:"require_canonical,omitempty" yaml:"require_canonical,omitempty"`
+
+	// MinBuildLevel rejects SLSA provenance below the build level (1-3).
+	// TrustedBuilders replace DefaultTrustedBuilders when classifying it.
+	MinBuildLevel   int              `json:"min_build_level,omitempty" yaml:"min_build_level,omitempty"`
+	TrustedBuilders []TrustedBuilder `json:"trusted_builders,omitempty" yaml:"trusted_builders,omitempty"`
+}
+
+// LoadIdentityPolicy reads a YAML policy such as the one keystone init
+// generates. Unknown fields are rejected so a misspelt constraint isn't
+// silently ignored.
+func LoadIdentityPolicy(path string) (IdentityPolicy, error) {
+	var policy IdentityPolicy
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return policy, fmt.Errorf("failed to read policy: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil {
+		return policy, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+	if policy.MinBuildLevel < BuildLevel0 || policy.MinBuildLevel > BuildLevel3 {
+		return policy, fmt.Errorf("invalid policy %s: min_build_level must be between 0 and 3", path)
+	}
+	return policy, nil
+}
+
+// PolicyResult is the outcome of one enforced identity policy constraint
+type PolicyResult struct {
+	Rule      string            `json:"rule"`
+	Expected  string            `json:"expected"`
+	Actual    string            `json:"actual"`
+	Passed    bool              `json:"passed"`
+	ErrorCode string            `json:"error_code,omitempty"`
+	Message   *messages.Message `json:"message,omitempty"` // Catalog message for a violated rule
+}
+
+// policyRule is one enforced constraint of an identity policy
+type policyRule struct {
+	name     string
+	expected string
+	actual   string
+	check    func() error
+}
+
+// rules returns the policy's enforced constraints, in the order they are checked
+func (p *IdentityPolicy) rules(identity *CertificateIdentity) []policyRule {
+	var rules []policyRule
+	if p.Issuer != "" {
+		rules = append(rules, policyRule{"issuer", p.Issuer, identity.Issuer, func() error {
+			if identity.Issuer != p.Issuer {
+				return Errorf(CodeIssuerMismatch, "Certificate issuer %q does not match expected issuer %q", identity.Issuer, p.Issuer)
+			}
+			return nil
+		}})
+	}
+
+	if p.SANRegexp != "" {
+		rules = append(rules, policyRule{"san", anchored(p.SANRegexp), identity.SAN, func() error {
+			pattern, err := regexp.Compile(anchored(p.SANRegexp))
+			if err != nil {
+				return Wrap(CodeSANMismatch, err, "Invalid SAN pattern %q", p.SANRegexp)
+			}
+			if !pattern.MatchString(identity.SAN) {
+				return Errorf(CodeSANMismatch, "Certificate identity %q does not match %q", identity.SAN, p.SANRegexp)
+			}
+			return nil
+		}})
+	}
+
+	if p.Repository != "" {
+		rules = append(rules, policyRule{"repository", p.Repository, identity.Repository, func() error {
+			if !strings.EqualFold(identity.Repository, p.Repository) {
+				return Errorf(CodeRepositoryMismatch, "Certificate was issued for repository %q, expected %q", identity.Repository, p.Repository)
+			}
+			return nil
+		}})
+	}
+
+	if p.WorkflowRef != "" {
+		rules = append(rules, policyRule{"workflow_ref", p.WorkflowRef, identity.WorkflowRef, func() error {
+			if !matchesWorkflow(identity, p.WorkflowRef) {
+				return Errorf(CodeWorkflowMismatch, "Certificate was issued for workflow %q, expected %q", identity.WorkflowRef, p.WorkflowRef)
+			}
+			return nil
+		}})
+	}
+
+	if p.Branch != "" {
+		rules = append(rules, policyRule{"branch", branchRef(p.Branch), identity.Ref, func() error {
+			if identity.Ref != branchRef(p.Branch) {
+				return Errorf(CodeBranchMismatch, "Certificate was issued for ref %q, expected %q", identity.Ref, branchRef(p.Branch))
+			}
+			return nil
+		}})
+	}
+	return rules
+}
+
+// Verify checks a certificate identity against the policy
+func (p *IdentityPolicy) Verify(identity *CertificateIdentity) error {
+	for _, rule := range p.rules(identity) {
+		if err := rule.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evaluate checks every enforced constraint against a certificate identity.
+// Unlike Verify it doesn't stop at the first failure, so reports can show all
+// of them.
+func (p *IdentityPolicy) Evaluate(identity *CertificateIdentity) []PolicyResult {
+	rules := p.rules(identity)
+	results := make([]PolicyResult, 0, len(rules))
+	for _, rule := range rules {
+		err := rule.check()
+		result := PolicyResult{
+			Rule:      rule.name,
+			Expected:  rule.expected,
+			Actual:    rule.actual,
+			Passed:    err == nil,
+			ErrorCode: CodeOf(err),
+		}
+		if err != nil {
+			result.Message = messages.ForPolicyRule(rule.name, rule.expected, rule.actual)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// VerifyCertificate checks the identity recorded in a Fulcio certificate against the policy
+func (p *IdentityPolicy) VerifyCertificate(cert *x509.Certificate) (*CertificateIdentity, error) {
+	identity := ParseCertificateIdentity(cert)
+	if err := p.Verify(identity); err != nil {
+		return identity, err
+	}
+	return identity, nil
+}
+
+// VerifyEnvelope checks that a signature on the envelope was made by the
+// certificate's key and that the certificate identity satisfies the policy
+func (p *IdentityPolicy) VerifyEnvelope(envelope *Envelope, cert *x509.Certificate) (*CertificateIdentity, error) {
+	if err := VerifyEnvelopeKey(envelope, cert.PublicKey); err != nil {
+		return nil, err
+	}
+	if p.RequireCanonical {
+		if err := envelope.VerifyCanonical(); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.VerifyCertificate(cert)
+}
+
+// anchored forces a pattern to match the whole string, as cosign does for
+// identity regexps. The pattern is grouped first, so each branch of an
+// alternation is anchored too.
+func anchored(pattern string) string {
+	return "^(?:" + pattern + ")$"
+}
+
+// matchesWorkflow compares the identity's workflow ref with an expected one.
+// An expected value with @ must match exactly; one without matches any ref,
+// either as the full owner/repo/path or as a path relative to the root of
+// the identity's own repository. A reusable workflow in another repository
+// only matches its full owner/repo/path.
+func matchesWorkflow(identity *CertificateIdentity, expected string) bool {
+	if strings.Contains(expected, "@") {
+		return strings.EqualFold(identity.WorkflowRef, expected)
+	}
+	path, _, _ := strings.Cut(identity.WorkflowRef, "@")
+	if strings.EqualFold(path, expected) {
+		return true
+	}
+
+	repo := identity.Repository + "/"
+	if identity.Repository == "" || len(path) <= len(repo) || !strings.EqualFold(path[:len(repo)], repo) {
+		return false
+	}
+	// GitLab separates the project from the CI config path with //
+	return strings.EqualFold(strings.TrimLeft(path[len(repo):], "/"), strings.TrimLeft(expected, "/"))
+}
+
+// branchRef expands a branch name to its full ref
+func branchRef(branch string) string {
+	if strings.HasPrefix(branch, "refs/") {
+		return branch
+	}
+	return "refs/heads/" + branch
+}
@@ -0,0 +1,205 @@
+// Package index keeps a local SQLite index of every attestation this
+// deployment generated or verified, so the API can list an artifact's
+// attestations without querying the registry
+package index
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// Entry is one attestation about one subject; an attestation naming several
+// subjects has an entry per subject
+type Entry struct {
+	AttestationID string     `json:"attestation_id"`
+	SubjectName   string     `json:"subject_name"`
+	SubjectDigest string     `json:"subject_digest"` // alg:hex
+	PredicateType string     `json:"predicate_type"`
+	Identity      string     `json:"identity,omitempty"`
+	Issuer        string     `json:"issuer,omitempty"`
+	RekorUUID     string     `json:"rekor_uuid,omitempty"`
+	BuildLevel    *int       `json:"build_level,omitempty"` // SLSA build level of verified provenance
+	GeneratedAt   *time.Time `json:"generated_at,omitempty"`
+	VerifiedAt    *time.Time `json:"verified_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Store persists the attestation index in SQLite
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates an attestation index on the migrated database
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// RecordGenerated indexes an attestation signed by this deployment under each
+// subject of its statement
+func (s *Store) RecordGenerated(ctx context.Context, record *attestation.AttestationRecord, statement *attestation.Statement) error {
+	generatedAt := record.Metadata.Timestamp.UTC()
+	entry := Entry{
+		AttestationID: record.ID,
+		PredicateType: statement.PredicateType,
+		Identity:      record.Metadata.Identity,
+		Issuer:        record.Metadata.Issuer,
+		GeneratedAt:   &generatedAt,
+	}
+	if record.RekorEntry != nil {
+		entry.RekorUUID = record.RekorEntry.UUID
+	}
+	return s.record(ctx, entry, statement.Subject)
+}
+
+// RecordVerified indexes a successfully verified bundle under each subject of
+// its statement. Failed verifications are not indexed.
+func (s *Store) RecordVerified(ctx context.Context, bundle *attestation.Bundle, result *attestation.VerificationResult) error {
+	if result == nil || !result.Valid {
+		return nil
+	}
+	if bundle == nil || bundle.Envelope == nil || len(bundle.Envelope.Signatures) == 0 {
+		return fmt.Errorf("bundle has no signed envelope to index")
+	}
+	statement, err := bundle.Envelope.Statement()
+	if err != nil {
+		return err
+	}
+
+	verifiedAt := result.VerifiedAt.UTC()
+	entry := Entry{
+		AttestationID: attestation.RecordID(bundle.Envelope.Signatures[0].Sig),
+		PredicateType: statement.PredicateType,
+		Identity:      result.Identity,
+		Issuer:        result.Issuer,
+		VerifiedAt:    &verifiedAt,
+	}
+	if result.BuildLevel != nil {
+		entry.BuildLevel = &result.BuildLevel.Level
+	}
+	if bundle.TlogEntry != nil {
+		entry.RekorUUID = bundle.TlogEntry.UUID
+	}
+	return s.record(ctx, entry, statement.Subject)
+}
+
+// record upserts an entry per subject digest. Fields already known are kept
+// when a later record leaves them empty, so verifying an attestation this
+// deployment generated only adds the verification time.
+func (s *Store) record(ctx context.Context, entry Entry, subjects []attestation.Subject) error {
+	upsertSQL := `
+		INSERT INTO attestation_index
+		(attestation_id, subject_name, subject_digest, predicate_type, identity, issuer, rekor_uuid, build_level, generated_at, verified_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(attestation_id, subject_digest) DO UPDATE SET
+			identity = CASE WHEN excluded.identity != '' THEN excluded.identity ELSE identity END,
+			issuer = CASE WHEN excluded.issuer != '' THEN excluded.issuer ELSE issuer END,
+			rekor_uuid = CASE WHEN excluded.rekor_uuid != '' THEN excluded.rekor_uuid ELSE rekor_uuid END,
+			build_level = COALESCE(excluded.build_level, build_level),
+			generated_at = COALESCE(excluded.generated_at, generated_at),
+			verified_at = COALESCE(excluded.verified_at, verified_at),
+			updated_at = excluded.updated_at
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, subject := range subjects {
+		for alg, value := range subject.Digest {
+			_, err := tx.ExecContext(ctx, upsertSQL,
+				entry.AttestationID,
+				subject.Name,
+				alg+":"+strings.ToLower(value),
+				entry.PredicateType,
+				entry.Identity,
+				entry.Issuer,
+				entry.RekorUUID,
+				entry.BuildLevel,
+				entry.GeneratedAt,
+				entry.VerifiedAt,
+				now,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to index attestation %s: %w", entry.AttestationID, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// ForSubject returns the attestations about a subject digest ("alg:hex"),
+// optionally limited to one predicate type, most recently updated first
+func (s *Store) ForSubject(ctx context.Context, digest, predicateType string) ([]Entry, error) {
+	query := `
+		SELECT attestation_id, subject_name, subject_digest, predicate_type, identity, issuer, rekor_uuid,
+		       build_level, generated_at, verified_at, updated_at
+		FROM attestation_index
+		WHERE subject_digest = ? AND (? = '' OR predicate_type = ?)
+		ORDER BY updated_at DESC, id DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, strings.ToLower(digest), predicateType, predicateType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attestations for %s: %w", digest, err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		var buildLevel sql.NullInt64
+		var generatedAt, verifiedAt sql.NullTime
+		err := rows.Scan(
+			&entry.AttestationID,
+			&entry.SubjectName,
+			&entry.SubjectDigest,
+			&entry.PredicateType,
+			&entry.Identity,
+			&entry.Issuer,
+			&entry.RekorUUID,
+			&buildLevel,
+			&generatedAt,
+			&verifiedAt,
+			&entry.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attestation index entry: %w", err)
+		}
+		if buildLevel.Valid {
+			level := int(buildLevel.Int64)
+			entry.BuildLevel = &level
+		}
+		if generatedAt.Valid {
+			entry.GeneratedAt = &generatedAt.Time
+		}
+		if verifiedAt.Valid {
+			entry.VerifiedAt = &verifiedAt.Time
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// HasRekorEntry reports whether an attestation this deployment generated was
+// logged under the Rekor entry UUID
+func (s *Store) HasRekorEntry(ctx context.Context, uuid string) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM attestation_index WHERE rekor_uuid = ? AND generated_at IS NOT NULL
+		)
+	`
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, query, uuid).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up rekor entry %s: %w", uuid, err)
+	}
+	return exists, nil
+}
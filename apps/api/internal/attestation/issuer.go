@@ -0,0 +1,320 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// OIDC issuer profiles
+const (
+	IssuerProfileGitHub     = "github"
+	IssuerProfileGitLab     = "gitlab"
+	IssuerProfileBuildkite  = "buildkite"
+	IssuerProfileCircleCI   = "circleci"
+	IssuerProfileCloudBuild = "cloudbuild"
+)
+
+// OIDC issuers of the CI platforms with built-in profiles
+const (
+	// GitLabIssuer is the OIDC issuer of GitLab.com CI job tokens; self-managed
+	// instances issue tokens from their own URL
+	GitLabIssuer = "https://gitlab.com"
+	// BuildkiteIssuer issues Buildkite agent job tokens
+	BuildkiteIssuer = "https://agent.buildkite.com"
+	// CircleCIIssuer matches the per-organization issuers of CircleCI job
+	// tokens; the organization's own issuer must be configured for verification
+	CircleCIIssuer = "https://oidc.circleci.com/org/*"
+	// GoogleIssuer issues the service account tokens Cloud Build steps sign with
+	GoogleIssuer = "https://accounts.google.com"
+)
+
+// SigstoreAudience is the audience Fulcio requires identity tokens to carry
+const SigstoreAudience = "sigstore"
+
+// IssuerClaims derive the CI identity of a signing job from its token claims,
+// the way Fulcio derives the certificate's SAN and extensions. Each field is a
+// text/template over the claims; claims whose names aren't identifiers are
+// read with index, e.g. {{index . "oidc.circleci.com/vcs-ref"}}. Every
+// non-empty template must render a value.
+type IssuerClaims struct {
+	SAN           string // Certificate subject alternative name
+	RepositoryURI string // Source repository URI; empty when the platform has no repository
+	Ref           string // Source ref, e.g. refs/heads/main
+	BuildSigner   string // URI of the CI definition that ran, with its ref
+}
+
+// IssuerProfile describes how a CI platform's OIDC tokens identify a job
+type IssuerProfile struct {
+	Name          string
+	Issuer        string // Expected token issuer; * matches one path segment
+	Audience      string // Audience the token must be requested for
+	Claims        IssuerClaims
+	ClaimMappings []ClaimMapping // Defaults recorded in signing annotations
+	TokenEnv      string         // Variable the pipeline exposes the token in; empty when it must be requested
+}
+
+// issuerProfiles are the built-in profiles by name
+var issuerProfiles = map[string]IssuerProfile{
+	IssuerProfileGitHub: {
+		Name:     IssuerProfileGitHub,
+		Issuer:   GitHubActionsIssuer,
+		Audience: SigstoreAudience,
+		Claims: IssuerClaims{
+			SAN:           "https://github.com/{{.job_workflow_ref}}",
+			RepositoryURI: "https://github.com/{{.repository}}",
+			Ref:           "{{.ref}}",
+			BuildSigner:   "https://github.com/{{.job_workflow_ref}}",
+		},
+		ClaimMappings: DefaultClaimMappings(),
+	},
+	IssuerProfileGitLab: {
+		Name:     IssuerProfileGitLab,
+		Issuer:   GitLabIssuer,
+		Audience: SigstoreAudience,
+		Claims: IssuerClaims{
+			// e.g. https://gitlab.com/group/project//.gitlab-ci.yml@refs/heads/main
+			SAN:           "https://{{.ci_config_ref_uri}}",
+			RepositoryURI: "{{.iss}}/{{.project_path}}",
+			Ref:           `{{if eq (print .ref_type) "branch"}}refs/heads/{{.ref}}{{else if eq (print .ref_type) "tag"}}refs/tags/{{.ref}}{{end}}`,
+			BuildSigner:   "https://{{.ci_config_ref_uri}}",
+		},
+		ClaimMappings: []ClaimMapping{
+			{Key: "keystone.oidc.environment", Template: "{{.environment}}", Predicate: true},
+			{Key: "keystone.oidc.ref_type", Template: "{{.ref_type}}", Predicate: true},
+			{Key: "keystone.oidc.ci_config_ref_uri", Template: "{{.ci_config_ref_uri}}", Predicate: true},
+			{Key: "keystone.oidc.runner_environment", Template: "{{.runner_environment}}", Predicate: true},
+		},
+		TokenEnv: "SIGSTORE_ID_TOKEN",
+	},
+	IssuerProfileBuildkite: {
+		Name:     IssuerProfileBuildkite,
+		Issuer:   BuildkiteIssuer,
+		Audience: SigstoreAudience,
+		Claims: IssuerClaims{
+			// Fulcio identifies Buildkite jobs by their pipeline
+			SAN:           "https://buildkite.com/{{.organization_slug}}/{{.pipeline_slug}}",
+			RepositoryURI: "https://buildkite.com/{{.organization_slug}}/{{.pipeline_slug}}",
+			Ref:           `{{if .build_tag}}refs/tags/{{.build_tag}}{{else if .build_branch}}refs/heads/{{.build_branch}}{{end}}`,
+		},
+		ClaimMappings: []ClaimMapping{
+			{Key: "keystone.oidc.build_number", Template: "{{.build_number}}", Predicate: true},
+			{Key: "keystone.oidc.build_commit", Template: "{{.build_commit}}", Predicate: true},
+			{Key: "keystone.oidc.step_key", Template: "{{.step_key}}", Predicate: true},
+		},
+	},
+	IssuerProfileCircleCI: {
+		Name:     IssuerProfileCircleCI,
+		Issuer:   CircleCIIssuer,
+		Audience: SigstoreAudience,
+		Claims: IssuerClaims{
+			SAN:           `https://circleci.com/api/v2/projects/{{index . "oidc.circleci.com/project-id"}}/pipeline-definitions/{{index . "oidc.circleci.com/pipeline-definition-id"}}`,
+			RepositoryURI: `https://{{index . "oidc.circleci.com/vcs-origin"}}`,
+			Ref:           `{{index . "oidc.circleci.com/vcs-ref"}}`,
+			BuildSigner:   `https://circleci.com/api/v2/projects/{{index . "oidc.circleci.com/project-id"}}/pipeline-definitions/{{index . "oidc.circleci.com/pipeline-definition-id"}}`,
+		},
+		ClaimMappings: []ClaimMapping{
+			{Key: "keystone.oidc.context_ids", Template: `{{index . "oidc.circleci.com/context-ids"}}`, Predicate: true},
+			{Key: "keystone.oidc.ssh_rerun", Template: `{{index . "oidc.circleci.com/ssh-rerun"}}`, Predicate: true},
+		},
+		TokenEnv: "CIRCLE_OIDC_TOKEN_V2",
+	},
+	IssuerProfileCloudBuild: {
+		Name:     IssuerProfileCloudBuild,
+		Issuer:   GoogleIssuer,
+		Audience: SigstoreAudience,
+		Claims: IssuerClaims{
+			// Fulcio only issues email identities for verified addresses
+			SAN: "{{if .email_verified}}{{.email}}{{end}}",
+		},
+	},
+}
+
+// LookupIssuerProfile returns a built-in issuer profile, defaulting to GitHub
+// Actions when name is empty
+func LookupIssuerProfile(name string) (IssuerProfile, error) {
+	if name == "" {
+		name = IssuerProfileGitHub
+	}
+	profile, found := issuerProfiles[strings.ToLower(name)]
+	if !found {
+		return IssuerProfile{}, fmt.Errorf("unknown OIDC issuer profile %q; use one of %s", name, strings.Join(IssuerProfileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// IssuerProfileNames lists the built-in issuer profiles
+func IssuerProfileNames() []string {
+	names := make([]string, 0, len(issuerProfiles))
+	for name := range issuerProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Token reads the job's OIDC token from the variable the pipeline exposes it in
+func (p IssuerProfile) Token() (string, error) {
+	if p.TokenEnv == "" {
+		return "", Errorf(CodeOIDCTokenUnavailable, "%s tokens are requested from the platform, not read from the environment", p.Name)
+	}
+	token := strings.TrimSpace(os.Getenv(p.TokenEnv))
+	if token == "" {
+		return "", Errorf(CodeOIDCTokenUnavailable, "%s is not set; request the token with audience %s", p.TokenEnv, p.Audience)
+	}
+	return token, nil
+}
+
+// TokenSource requests OIDC tokens from the CI platform for an audience;
+// oidc.Client requests them from GitHub Actions
+type TokenSource interface {
+	Token(ctx context.Context, audience string) (string, error)
+}
+
+// RequestToken returns the job's OIDC token for the profile's audience, read
+// from the pipeline's variable when the platform exposes one and requested
+// from source otherwise
+func (p IssuerProfile) RequestToken(ctx context.Context, source TokenSource) (string, error) {
+	if p.TokenEnv != "" {
+		return p.Token()
+	}
+	if source == nil {
+		return "", Errorf(CodeOIDCTokenUnavailable, "%s tokens are requested from the platform and no token source is configured", p.Name)
+	}
+	token, err := source.Token(ctx, p.Audience)
+	if err != nil {
+		return "", wrapUncoded(CodeOIDCTokenRequestFailed, err, "Failed to request %s OIDC token", p.Name)
+	}
+	return token, nil
+}
+
+// MatchesIssuer reports whether a token issuer is the profile's
+func (p IssuerProfile) MatchesIssuer(issuer string) bool {
+	issuer, expected := strings.TrimSuffix(issuer, "/"), strings.TrimSuffix(p.Issuer, "/")
+	if !strings.Contains(expected, "*") {
+		return issuer == expected
+	}
+	matched, err := path.Match(expected, issuer)
+	return err == nil && matched
+}
+
+// Identity maps a token's claims to the certificate identity Fulcio will
+// issue for it, so an identity policy can be checked before signing. The
+// token must come from the profile's issuer for its audience.
+func (p IssuerProfile) Identity(claims Claims) (*CertificateIdentity, error) {
+	issuer := claimString(claims, "iss")
+	if !p.MatchesIssuer(issuer) {
+		return nil, Errorf(CodeIssuerMismatch, "OIDC token was issued by %q, expected %s issuer %q", issuer, p.Name, p.Issuer)
+	}
+	if p.Audience != "" && !hasAudience(claims, p.Audience) {
+		return nil, Errorf(CodeInvalidAudience, "OIDC token is not for audience %q", p.Audience)
+	}
+
+	rendered := make(map[string]string)
+	for _, field := range []struct{ name, template string }{
+		{"SAN", p.Claims.SAN},
+		{"repository", p.Claims.RepositoryURI},
+		{"ref", p.Claims.Ref},
+		{"build signer", p.Claims.BuildSigner},
+	} {
+		if field.template == "" {
+			continue
+		}
+		value, err := renderClaims(field.template, claims)
+		if err != nil || value == "" {
+			return nil, Errorf(CodeMissingSubject, "OIDC token claims don't identify the job's %s for %s", field.name, p.Name)
+		}
+		rendered[field.name] = value
+	}
+
+	identity := &CertificateIdentity{
+		Issuer: strings.TrimSuffix(issuer, "/"),
+		SAN:    rendered["SAN"],
+		Ref:    rendered["ref"],
+	}
+	if uri := rendered["repository"]; uri != "" {
+		identity.Repository = uriPath(uri)
+	}
+	if uri := rendered["build signer"]; uri != "" {
+		identity.WorkflowRef = uriPath(uri)
+	}
+
+	identity.Environment = claimString(claims, "environment")
+	if subject := subjectClaim(claims); subject != nil {
+		if identity.Environment == "" {
+			identity.Environment = subject.Environment
+		}
+		// The subject and the repository claim are both signed by the
+		// issuer, so a mismatch means the claims were assembled elsewhere
+		if subject.Repository != "" && identity.Repository != "" && !strings.EqualFold(subject.Repository, identity.Repository) {
+			return nil, Errorf(CodeMissingSubject, "OIDC subject names repository %q, but the token's repository is %q", subject.Repository, identity.Repository)
+		}
+	}
+	return identity, nil
+}
+
+// VerifyToken decodes a token and checks the identity it asserts against the
+// policy, expecting the token's issuer when the policy names none
+func (p IssuerProfile) VerifyToken(token string, policy IdentityPolicy) (*CertificateIdentity, error) {
+	claims, err := DecodeClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := p.Identity(claims)
+	if err != nil {
+		return nil, err
+	}
+	if policy.Issuer == "" {
+		policy.Issuer = identity.Issuer
+	}
+	if err := policy.Verify(identity); err != nil {
+		return identity, err
+	}
+	return identity, nil
+}
+
+// renderClaims executes a claim template, rendering missing claims as empty
+func renderClaims(text string, claims Claims) (string, error) {
+	tmpl, err := template.New("claims").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, map[string]interface{}(claims)); err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(rendered.String())
+	if strings.Contains(value, "<no value>") {
+		return "", nil
+	}
+	return value, nil
+}
+
+// hasAudience reports whether the aud claim, a string or a list, includes audience
+func hasAudience(claims Claims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimString returns a string claim, or "" when it is absent or not a string
+func claimString(claims Claims, name string) string {
+	if name == "" {
+		return ""
+	}
+	value, _ := claims[name].(string)
+	return value
+}
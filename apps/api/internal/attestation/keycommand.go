@@ -0,0 +1,115 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CommandKeyProvider keeps keys in a TPM or Secure Enclave through a helper
+// installed on a self-hosted runner, so the private key never enters this process.
+//
+// The helper is invoked as "<command> [args...] generate", printing a
+// KeyResidencyAttestation as JSON, and "<command> [args...] sign <key_id>",
+// reading a base64 SHA-256 digest on stdin and printing a base64 signature.
+type CommandKeyProvider struct {
+	Command  string
+	Args     []string
+	Env      []string // Extra environment, e.g. TPM device selection
+	Hardware string   // ResidencyTPM2 or ResidencySecureEnclave
+	Timeout  time.Duration
+}
+
+// Residency reports the hardware the helper keeps keys in
+func (p *CommandKeyProvider) Residency() string {
+	return p.Hardware
+}
+
+// Generate asks the helper for a new hardware-resident key and its attestation
+func (p *CommandKeyProvider) Generate(ctx context.Context) (crypto.Signer, *KeyResidencyAttestation, error) {
+	output, err := p.run(ctx, nil, "generate")
+	if err != nil {
+		return nil, nil, Wrap(CodeSigningFailed, err, "Hardware key generation failed")
+	}
+
+	var att KeyResidencyAttestation
+	if err := json.Unmarshal(output, &att); err != nil {
+		return nil, nil, Wrap(CodeSigningFailed, err, "Key helper returned an invalid attestation")
+	}
+	if att.KeyID == "" {
+		return nil, nil, Errorf(CodeSigningFailed, "Key helper did not return a key ID")
+	}
+	if att.Residency != p.Hardware {
+		return nil, nil, Errorf(CodeKeyResidencyUnverified, "Key helper reported %q residency, expected %q", att.Residency, p.Hardware)
+	}
+	if att.CreatedAt.IsZero() {
+		att.CreatedAt = time.Now().UTC()
+	}
+
+	publicKey, err := parsePublicKey(att.PublicKey)
+	if err != nil {
+		return nil, nil, Wrap(CodePublicKeyExtraction, err, "Key helper returned an invalid public key")
+	}
+
+	return &commandSigner{provider: p, keyID: att.KeyID, public: publicKey}, &att, nil
+}
+
+// run executes the helper with a subcommand and returns its stdout
+func (p *CommandKeyProvider) run(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.Command, append(append([]string{}, p.Args...), args...)...)
+	cmd.Env = append(cmd.Environ(), p.Env...)
+	cmd.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s %s: %w: %s", p.Command, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// commandSigner signs digests with a key held by the helper
+type commandSigner struct {
+	provider *CommandKeyProvider
+	keyID    string
+	public   crypto.PublicKey
+}
+
+// Public returns the hardware key's public half
+func (s *commandSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign has the helper sign a SHA-256 digest
+func (s *commandSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, fmt.Errorf("hardware keys only sign SHA-256 digests")
+	}
+
+	stdin := strings.NewReader(base64.StdEncoding.EncodeToString(digest))
+	output, err := s.provider.run(context.Background(), stdin, "sign", s.keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(output)))
+	if err != nil {
+		return nil, fmt.Errorf("key helper returned an invalid signature: %w", err)
+	}
+	return sig, nil
+}
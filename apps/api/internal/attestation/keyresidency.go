@@ -0,0 +1,223 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"time"
+)
+
+// Key residencies recorded in signing metadata
+const (
+	ResidencySoftware      = "software"
+	ResidencyTPM2          = "tpm2"
+	ResidencySecureEnclave = "secure-enclave"
+)
+
+// AttestationFormatX509Chain is residency evidence in which a hardware-rooted
+// CA certifies the signing key itself (TPM2 AK-issued or Secure Enclave attestation certificates)
+const AttestationFormatX509Chain = "x509-chain"
+
+// Signing metadata annotations describing key residency
+const (
+	AnnotationKeyResidency         = "keystone.key.residency"
+	AnnotationKeyAttestationFormat = "keystone.key.attestation_format"
+	AnnotationKeyAttestationDigest = "keystone.key.attestation_digest"
+	AnnotationKeyResidencyVerified = "keystone.key.residency_verified"
+)
+
+// KeyResidencyAttestation is evidence that an ephemeral signing key was generated inside hardware
+type KeyResidencyAttestation struct {
+	Residency    string    `json:"residency"`
+	KeyID        string    `json:"key_id,omitempty"`
+	PublicKey    string    `json:"public_key"` // PEM
+	Format       string    `json:"format,omitempty"`
+	Certificates []string  `json:"certificates,omitempty"` // PEM, attestation leaf first
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Digest returns a stable SHA-256 over the attested key and evidence, for annotations
+func (a *KeyResidencyAttestation) Digest() string {
+	h := sha256.New()
+	h.Write([]byte(a.Residency))
+	h.Write([]byte(a.PublicKey))
+	for _, cert := range a.Certificates {
+		h.Write([]byte(cert))
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// KeyProvider generates ephemeral signing keys and evidence of where they reside
+type KeyProvider interface {
+	Residency() string
+	Generate(ctx context.Context) (crypto.Signer, *KeyResidencyAttestation, error)
+}
+
+// SoftwareKeyProvider generates in-memory ECDSA P-256 keys; it is the default
+// on hosted runners and records software residency
+type SoftwareKeyProvider struct{}
+
+// Residency reports software residency
+func (SoftwareKeyProvider) Residency() string {
+	return ResidencySoftware
+}
+
+// Generate creates an ephemeral in-memory key
+func (SoftwareKeyProvider) Generate(ctx context.Context) (crypto.Signer, *KeyResidencyAttestation, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, Wrap(CodeSigningFailed, err, "Failed to generate signing key")
+	}
+	publicKey, err := encodePublicKey(key.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, &KeyResidencyAttestation{
+		Residency: ResidencySoftware,
+		PublicKey: publicKey,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// VerifyKeyResidency checks hardware residency evidence: the attestation
+// certificate must chain to one of the vendor roots and certify the attested key
+func VerifyKeyResidency(att *KeyResidencyAttestation, roots *x509.CertPool) error {
+	if att.Residency == ResidencySoftware {
+		return Errorf(CodeKeyResidencyUnverified, "Signing key is not hardware backed")
+	}
+	if att.Format != AttestationFormatX509Chain {
+		return Errorf(CodeKeyResidencyUnverified, "Unsupported key attestation format %q", att.Format)
+	}
+
+	chain, err := parseCertificates(att.Certificates)
+	if err != nil || len(chain) == 0 {
+		return Wrap(CodeKeyResidencyUnverified, err, "Key attestation has no valid certificates")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return Wrap(CodeKeyResidencyUnverified, err, "Key attestation does not chain to a trusted %s root", att.Residency)
+	}
+
+	attested, err := x509.MarshalPKIXPublicKey(chain[0].PublicKey)
+	if err != nil {
+		return Wrap(CodeKeyResidencyUnverified, err, "Key attestation certificate key is unsupported")
+	}
+	claimed, err := decodePublicKeyDER(att.PublicKey)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(attested, claimed) {
+		return Errorf(CodeKeyResidencyUnverified, "Key attestation certifies a different key than the signing key")
+	}
+	return nil
+}
+
+// RecordKeyResidency annotates signing metadata with the key's residency evidence
+func RecordKeyResidency(metadata *SigningMetadata, att *KeyResidencyAttestation, verified bool) {
+	if metadata.Annotations == nil {
+		metadata.Annotations = make(map[string]string)
+	}
+	metadata.Annotations[AnnotationKeyResidency] = att.Residency
+	if att.Format != "" {
+		metadata.Annotations[AnnotationKeyAttestationFormat] = att.Format
+		metadata.Annotations[AnnotationKeyAttestationDigest] = att.Digest()
+	}
+	if verified {
+		metadata.Annotations[AnnotationKeyResidencyVerified] = "true"
+	}
+}
+
+// CertificateRequest is a Fulcio v2 signing certificate request extended with
+// key residency evidence
+type CertificateRequest struct {
+	Credentials struct {
+		OIDCIdentityToken string `json:"oidcIdentityToken"`
+	} `json:"credentials"`
+	PublicKeyRequest struct {
+		PublicKey struct {
+			Algorithm string `json:"algorithm"`
+			Content   string `json:"content"` // PEM
+		} `json:"publicKey"`
+		ProofOfPossession string `json:"proofOfPossession"` // Base64 signature over the token subject
+	} `json:"publicKeyRequest"`
+	KeyResidency *KeyResidencyAttestation `json:"keyResidency,omitempty"`
+}
+
+// NewCertificateRequest generates a key with the provider and builds a certificate
+// request proving possession of it; the signer is returned for signing the payload
+func NewCertificateRequest(ctx context.Context, provider KeyProvider, token string) (*CertificateRequest, crypto.Signer, error) {
+	claims, err := DecodeClaims(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, nil, Errorf(CodeMissingSubject, "OIDC token has no subject claim")
+	}
+
+	signer, att, err := provider.Generate(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	digest := sha256.Sum256([]byte(subject))
+	proof, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, nil, Wrap(CodeSigningFailed, err, "Failed to sign proof of possession")
+	}
+
+	req := &CertificateRequest{KeyResidency: att}
+	req.Credentials.OIDCIdentityToken = token
+	req.PublicKeyRequest.PublicKey.Algorithm = keyAlgorithm(signer.Public())
+	req.PublicKeyRequest.PublicKey.Content = att.PublicKey
+	req.PublicKeyRequest.ProofOfPossession = base64.StdEncoding.EncodeToString(proof)
+	return req, signer, nil
+}
+
+// keyAlgorithm names the key algorithm as Fulcio expects it
+func keyAlgorithm(key crypto.PublicKey) string {
+	switch key.(type) {
+	case *ecdsa.PublicKey:
+		return "ECDSA"
+	case ed25519.PublicKey:
+		return "ED25519"
+	default:
+		return "RSA"
+	}
+}
+
+// encodePublicKey PEM encodes a PKIX public key
+func encodePublicKey(key crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", Wrap(CodePublicKeyExtraction, err, "Failed to encode public key")
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// decodePublicKeyDER returns the DER bytes of a PEM public key
+func decodePublicKeyDER(encoded string) ([]byte, error) {
+	key, err := parsePublicKey(encoded)
+	if err != nil {
+		return nil, Wrap(CodePublicKeyExtraction, err, "Attested public key is invalid")
+	}
+	return x509.MarshalPKIXPublicKey(key)
+}
@@ -0,0 +1,127 @@
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/sigv4"
+)
+
+// awsSigner signs with AWS KMS asymmetric keys through the JSON API
+type awsSigner struct {
+	config   Config
+	endpoint string
+	region   string
+	keyID    string
+	keys     publicKeyCache
+}
+
+// newAWSSigner parses awskms://[endpoint]/<key id, alias or ARN>
+func newAWSSigner(config Config, path string) (*awsSigner, error) {
+	host, keyID, found := strings.Cut(path, "/")
+	if !found || keyID == "" {
+		return nil, fmt.Errorf("awskms key reference must be awskms://[endpoint]/<key>")
+	}
+
+	region := config.AWSRegion
+	if strings.HasPrefix(keyID, "arn:") {
+		// arn:aws:kms:<region>:<account>:key/<id>
+		if parts := strings.Split(keyID, ":"); len(parts) > 3 && parts[3] != "" {
+			region = parts[3]
+		}
+	}
+	if region == "" {
+		return nil, fmt.Errorf("awskms requires AWS_REGION or a key ARN")
+	}
+	if config.AWSAccessKeyID == "" || config.AWSSecretAccessKey == "" {
+		return nil, fmt.Errorf("awskms requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	endpoint := config.Endpoint
+	switch {
+	case endpoint != "":
+	case host != "":
+		endpoint = "https://" + host
+	default:
+		endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com", region)
+	}
+
+	return &awsSigner{config: config, endpoint: strings.TrimSuffix(endpoint, "/"), region: region, keyID: keyID}, nil
+}
+
+// Backend names AWS KMS
+func (s *awsSigner) Backend() string {
+	return BackendAWS
+}
+
+// KeyID returns the key reference
+func (s *awsSigner) KeyID() string {
+	return s.config.KeyRef
+}
+
+// PublicKey fetches the key's public half with GetPublicKey
+func (s *awsSigner) PublicKey(ctx context.Context) (crypto.PublicKey, error) {
+	return s.keys.get(ctx, func(ctx context.Context) (crypto.PublicKey, error) {
+		var resp struct {
+			PublicKey string
+		}
+		if err := s.call(ctx, "GetPublicKey", map[string]string{"KeyId": s.keyID}, &resp); err != nil {
+			return nil, err
+		}
+		der, err := base64.StdEncoding.DecodeString(resp.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key encoding: %w", err)
+		}
+		return x509.ParsePKIXPublicKey(der)
+	})
+}
+
+// SignDigest signs a SHA-256 digest with Sign
+func (s *awsSigner) SignDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	key, err := s.PublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	algorithm := "ECDSA_SHA_256"
+	if _, ok := key.(*rsa.PublicKey); ok {
+		algorithm = "RSASSA_PKCS1_V1_5_SHA_256"
+	}
+
+	var resp struct {
+		Signature string
+	}
+	err = s.call(ctx, "Sign", map[string]string{
+		"KeyId":            s.keyID,
+		"Message":          base64.StdEncoding.EncodeToString(digest),
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": algorithm,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Signature)
+}
+
+// call invokes a KMS API action with a SigV4-signed request
+func (s *awsSigner) call(ctx context.Context, action string, in, out interface{}) error {
+	return doJSON(ctx, s.config.HTTPClient, http.MethodPost, s.endpoint+"/", in, out, func(req *http.Request, body []byte) error {
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "TrentService."+action)
+		sigv4.Sign(req, body, sigv4.Credentials{
+			AccessKeyID:     s.config.AWSAccessKeyID,
+			SecretAccessKey: s.config.AWSSecretAccessKey,
+			SessionToken:    s.config.AWSSessionToken,
+		}, s.region, "kms", time.Now())
+		return nil
+	})
+}
+
+var _ attestation.Signer = (*awsSigner)(nil)
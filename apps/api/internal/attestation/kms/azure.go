@@ -0,0 +1,175 @@
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// azureAPIVersion is the Key Vault REST API version used
+const azureAPIVersion = "7.4"
+
+// azureMetadataTokenURL serves managed identity tokens for Key Vault on Azure hosts
+const azureMetadataTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token?api-version=2018-02-01&resource=https%3A%2F%2Fvault.azure.net"
+
+// azureSigner signs with Azure Key Vault keys through the REST API
+type azureSigner struct {
+	config   Config
+	endpoint string
+	name     string
+	version  string
+	keys     publicKeyCache
+}
+
+// newAzureSigner parses azurekms://<vault>.vault.azure.net/<key>[/<version>]
+func newAzureSigner(config Config, ref string) (*azureSigner, error) {
+	host, key, found := strings.Cut(ref, "/")
+	if !found || host == "" || key == "" {
+		return nil, fmt.Errorf("azurekms key reference must be azurekms://<vault host>/<key>[/<version>]")
+	}
+	name, version, _ := strings.Cut(key, "/")
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = "https://" + host
+	}
+	return &azureSigner{config: config, endpoint: strings.TrimSuffix(endpoint, "/"), name: name, version: version}, nil
+}
+
+// Backend names Azure Key Vault
+func (s *azureSigner) Backend() string {
+	return BackendAzure
+}
+
+// KeyID returns the key reference
+func (s *azureSigner) KeyID() string {
+	return s.config.KeyRef
+}
+
+// azureJWK is the subset of a Key Vault JSON web key used for signing
+type azureJWK struct {
+	KID string `json:"kid"`
+	KTY string `json:"kty"`
+	CRV string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// PublicKey fetches the key and pins its version when none was configured
+func (s *azureSigner) PublicKey(ctx context.Context) (crypto.PublicKey, error) {
+	return s.keys.get(ctx, func(ctx context.Context) (crypto.PublicKey, error) {
+		var resp struct {
+			Key azureJWK `json:"key"`
+		}
+		if err := s.call(ctx, http.MethodGet, "", nil, &resp); err != nil {
+			return nil, err
+		}
+		if s.version == "" {
+			s.version = path.Base(resp.Key.KID)
+		}
+		return resp.Key.publicKey()
+	})
+}
+
+// SignDigest signs a SHA-256 digest with ES256 or RS256
+func (s *azureSigner) SignDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	key, err := s.PublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	algorithm := "ES256"
+	if _, ok := key.(*rsa.PublicKey); ok {
+		algorithm = "RS256"
+	}
+
+	var resp struct {
+		Value string `json:"value"`
+	}
+	req := map[string]string{"alg": algorithm, "value": base64.RawURLEncoding.EncodeToString(digest)}
+	if err := s.call(ctx, http.MethodPost, "/sign", req, &resp); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(resp.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if algorithm == "ES256" {
+		// Key Vault returns the raw r||s form; DSSE verifiers expect ASN.1
+		return asn1.Marshal(struct{ R, S *big.Int }{
+			new(big.Int).SetBytes(sig[:len(sig)/2]),
+			new(big.Int).SetBytes(sig[len(sig)/2:]),
+		})
+	}
+	return sig, nil
+}
+
+// call invokes the Key Vault keys API with an access token
+func (s *azureSigner) call(ctx context.Context, method, suffix string, in, out interface{}) error {
+	token := s.config.Token
+	if token == "" {
+		var err error
+		token, err = metadataToken(ctx, s.config.HTTPClient, azureMetadataTokenURL, map[string]string{"Metadata": "true"})
+		if err != nil {
+			return err
+		}
+	}
+
+	url := s.endpoint + "/keys/" + s.name
+	if s.version != "" {
+		url += "/" + s.version
+	}
+	return doJSON(ctx, s.config.HTTPClient, method, url+suffix+"?api-version="+azureAPIVersion, in, out, bearer(token))
+}
+
+// publicKey converts an EC P-256 or RSA JSON web key
+func (k azureJWK) publicKey() (crypto.PublicKey, error) {
+	decode := func(value string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key encoding: %w", err)
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.KTY {
+	case "EC", "EC-HSM":
+		if k.CRV != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q; only P-256 keys sign with SHA-256", k.CRV)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "RSA", "RSA-HSM":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.KTY)
+}
+
+var _ attestation.Signer = (*azureSigner)(nil)
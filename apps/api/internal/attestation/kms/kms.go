@@ -0,0 +1,239 @@
+// Package kms signs attestations with keys held by a cloud key management
+// service, HashiCorp Vault, or a static cosign key pair, for deployments where
+// keyless signing isn't viable
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// Signing backends, named after the key reference scheme cosign uses
+const (
+	BackendKeyless = "keyless"
+	BackendAWS     = "awskms"
+	BackendGCP     = "gcpkms"
+	BackendAzure   = "azurekms"
+	BackendVault   = "hashivault"
+	BackendStatic  = "key"
+)
+
+// Config selects the signing backend and holds its credentials
+type Config struct {
+	KeyRef   string // e.g. awskms:///arn:..., gcpkms://projects/..., azurekms://vault.vault.azure.net/key, hashivault://key, cosign.key, env://COSIGN_KEY
+	Endpoint string // Overrides the service endpoint; the Vault address for hashivault
+
+	KeyPassword    string // Decrypts an encrypted static key
+	SkipTlogUpload bool   // Don't upload signatures to Rekor, for air-gapped pipelines
+
+	Token string // Bearer token for GCP and Azure, or the Vault token
+
+	AWSRegion          string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	AWSSessionToken    string
+
+	VaultTransitMount string // Vault transit engine mount path
+	HTTPClient        *http.Client
+}
+
+// ConfigFromEnv reads SIGNING_KEY and the credentials of its backend from the environment
+func ConfigFromEnv() Config {
+	config := Config{
+		KeyRef:             os.Getenv("SIGNING_KEY"),
+		Endpoint:           os.Getenv("SIGNING_KMS_ENDPOINT"),
+		AWSRegion:          os.Getenv("AWS_REGION"),
+		AWSAccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		AWSSecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		AWSSessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		VaultTransitMount:  os.Getenv("VAULT_TRANSIT_MOUNT"),
+		SkipTlogUpload:     os.Getenv("SIGNING_SKIP_TLOG_UPLOAD") == "true",
+	}
+
+	switch config.Backend() {
+	case BackendGCP:
+		config.Token = os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	case BackendAzure:
+		config.Token = os.Getenv("AZURE_ACCESS_TOKEN")
+	case BackendVault:
+		config.Token = os.Getenv("VAULT_TOKEN")
+		if config.Endpoint == "" {
+			config.Endpoint = os.Getenv("VAULT_ADDR")
+		}
+	case BackendStatic:
+		config.KeyPassword = os.Getenv("COSIGN_PASSWORD")
+	}
+	return config
+}
+
+// Backend returns the backend named by the key reference scheme; a plain path
+// or env:// reference names a static key
+func (c Config) Backend() string {
+	if c.KeyRef == "" {
+		return BackendKeyless
+	}
+	scheme, _, found := strings.Cut(c.KeyRef, "://")
+	if !found || scheme == "env" {
+		return BackendStatic
+	}
+	return scheme
+}
+
+// Annotate records the transparency log choice in signing metadata, so
+// verifiers of a skipped upload don't look for a Rekor entry
+func (c Config) Annotate(metadata *attestation.SigningMetadata) {
+	if !c.SkipTlogUpload {
+		return
+	}
+	if metadata.Annotations == nil {
+		metadata.Annotations = make(map[string]string)
+	}
+	metadata.Annotations[attestation.AnnotationTlogUpload] = "skipped"
+}
+
+// Enabled reports whether a KMS or static key is configured instead of keyless signing
+func (c Config) Enabled() bool {
+	return c.Backend() != BackendKeyless
+}
+
+// New creates a signer for the configured key
+func New(config Config) (attestation.Signer, error) {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	_, path, _ := strings.Cut(config.KeyRef, "://")
+
+	switch config.Backend() {
+	case BackendAWS:
+		return newAWSSigner(config, path)
+	case BackendGCP:
+		return newGCPSigner(config, path)
+	case BackendAzure:
+		return newAzureSigner(config, path)
+	case BackendVault:
+		return newVaultSigner(config, path)
+	case BackendStatic:
+		return newStaticSigner(config)
+	case BackendKeyless:
+		return nil, fmt.Errorf("no signing key configured; set SIGNING_KEY to a KMS key reference or key file")
+	default:
+		return nil, fmt.Errorf("unknown signing backend %q", config.Backend())
+	}
+}
+
+// publicKeyCache fetches a signer's public key once
+type publicKeyCache struct {
+	mu  sync.Mutex
+	key crypto.PublicKey
+}
+
+func (c *publicKeyCache) get(ctx context.Context, fetch func(ctx context.Context) (crypto.PublicKey, error)) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.key != nil {
+		return c.key, nil
+	}
+
+	key, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.key = key
+	return key, nil
+}
+
+// doJSON sends a JSON request and decodes the JSON response; prepare may add
+// authentication headers and sees the encoded body
+func doJSON(ctx context.Context, client *http.Client, method, url string, in, out interface{}, prepare func(req *http.Request, body []byte) error) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if prepare != nil {
+		if err := prepare(req, body); err != nil {
+			return err
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return attestation.Wrap(attestation.CodeNetworkTimeout, err, "Key management service is unreachable")
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return attestation.Errorf(attestation.CodePermissionDenied, "Key management service denied access (%d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("key management service returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// bearer returns a prepare function setting a bearer token
+func bearer(token string) func(req *http.Request, body []byte) error {
+	return func(req *http.Request, _ []byte) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// metadataToken fetches an access token from a cloud instance metadata endpoint
+func metadataToken(ctx context.Context, client *http.Client, url string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("no access token configured and the metadata server is unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode metadata token: %w", err)
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,217 @@
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// PEM block types of cosign key pairs
+const (
+	PEMTypeEncryptedSigstoreKey = "ENCRYPTED SIGSTORE PRIVATE KEY"
+	PEMTypeEncryptedCosignKey   = "ENCRYPTED COSIGN PRIVATE KEY"
+	PEMTypePublicKey            = "PUBLIC KEY"
+)
+
+// scrypt parameters cosign uses when encrypting private keys
+const (
+	scryptN      = 32768
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+// encryptedKey is the securesystemslib envelope cosign stores encrypted keys in
+type encryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// staticSigner signs with an ECDSA key pair loaded from a file or the environment
+type staticSigner struct {
+	ref string
+	key *ecdsa.PrivateKey
+}
+
+// newStaticSigner loads a key from a path or env://<VAR>
+func newStaticSigner(config Config) (*staticSigner, error) {
+	var data []byte
+	if name, ok := strings.CutPrefix(config.KeyRef, "env://"); ok {
+		value := os.Getenv(name)
+		if value == "" {
+			return nil, fmt.Errorf("environment variable %s holds no signing key", name)
+		}
+		data = []byte(value)
+	} else {
+		var err error
+		if data, err = os.ReadFile(config.KeyRef); err != nil {
+			return nil, fmt.Errorf("failed to read signing key: %w", err)
+		}
+	}
+
+	key, err := LoadPrivateKey(data, []byte(config.KeyPassword))
+	if err != nil {
+		return nil, err
+	}
+	return &staticSigner{ref: config.KeyRef, key: key}, nil
+}
+
+// Backend names static key signing
+func (s *staticSigner) Backend() string {
+	return BackendStatic
+}
+
+// KeyID returns the key reference
+func (s *staticSigner) KeyID() string {
+	return s.ref
+}
+
+// PublicKey returns the key pair's public half
+func (s *staticSigner) PublicKey(context.Context) (crypto.PublicKey, error) {
+	return s.key.Public(), nil
+}
+
+// SignDigest signs a SHA-256 digest
+func (s *staticSigner) SignDigest(_ context.Context, digest []byte) ([]byte, error) {
+	return ecdsa.SignASN1(rand.Reader, s.key, digest)
+}
+
+// LoadPrivateKey decodes a cosign encrypted private key, or an unencrypted
+// PKCS#8 or SEC 1 ECDSA key, from PEM
+func LoadPrivateKey(data, password []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key is not PEM encoded")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case PEMTypeEncryptedSigstoreKey, PEMTypeEncryptedCosignKey:
+		der, decryptErr := decryptPrivateKey(block.Bytes, password)
+		if decryptErr != nil {
+			return nil, decryptErr
+		}
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+
+	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key must be ECDSA, got %T", key)
+	}
+	return ecdsaKey, nil
+}
+
+// decryptPrivateKey opens a scrypt and secretbox encrypted key
+func decryptPrivateKey(data, password []byte) ([]byte, error) {
+	var envelope encryptedKey
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("encrypted signing key is malformed: %w", err)
+	}
+	if envelope.KDF.Name != "scrypt" || envelope.Cipher.Name != "nacl/secretbox" {
+		return nil, fmt.Errorf("unsupported key encryption %s/%s", envelope.KDF.Name, envelope.Cipher.Name)
+	}
+	if len(envelope.Cipher.Nonce) != 24 {
+		return nil, fmt.Errorf("encrypted signing key has an invalid nonce")
+	}
+
+	params := envelope.KDF.Params
+	secret, err := scrypt.Key(password, envelope.KDF.Salt, params.N, params.R, params.P, scryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key encryption key: %w", err)
+	}
+
+	var nonce [24]byte
+	var boxKey [32]byte
+	copy(nonce[:], envelope.Cipher.Nonce)
+	copy(boxKey[:], secret)
+	plaintext, ok := secretbox.Open(nil, envelope.Ciphertext, &nonce, &boxKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt signing key: wrong password")
+	}
+	return plaintext, nil
+}
+
+// GenerateKeyPair creates an ECDSA P-256 key pair in cosign's format: the
+// private key encrypted with password, and the PEM public key
+func GenerateKeyPair(password []byte) (privatePEM, publicPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var envelope encryptedKey
+	envelope.KDF.Name = "scrypt"
+	envelope.KDF.Params.N, envelope.KDF.Params.R, envelope.KDF.Params.P = scryptN, scryptR, scryptP
+	envelope.KDF.Salt = make([]byte, 32)
+	envelope.Cipher.Name = "nacl/secretbox"
+	envelope.Cipher.Nonce = make([]byte, 24)
+	if _, err := rand.Read(envelope.KDF.Salt); err != nil {
+		return nil, nil, err
+	}
+	if _, err := rand.Read(envelope.Cipher.Nonce); err != nil {
+		return nil, nil, err
+	}
+
+	secret, err := scrypt.Key(password, envelope.KDF.Salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, nil, err
+	}
+	var nonce [24]byte
+	var boxKey [32]byte
+	copy(nonce[:], envelope.Cipher.Nonce)
+	copy(boxKey[:], secret)
+	envelope.Ciphertext = secretbox.Seal(nil, der, &nonce, &boxKey)
+
+	encrypted, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeEncryptedSigstoreKey, Bytes: encrypted}),
+		pem.EncodeToMemory(&pem.Block{Type: PEMTypePublicKey, Bytes: publicDER}), nil
+}
+
+var _ attestation.Signer = (*staticSigner)(nil)
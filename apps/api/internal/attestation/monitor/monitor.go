@@ -0,0 +1,293 @@
+// Package monitor watches a Rekor transparency log for entries signed by the
+// workflow identities this deployment signs with. An entry under one of those
+// identities that Keystone didn't produce means the identity signed something
+// it shouldn't have, e.g. through a compromised workflow or a stolen OIDC
+// token, and raises an alert on the event bus.
+package monitor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+)
+
+// Log is the transparency log being monitored; RekorClient is the HTTP client
+type Log interface {
+	Size(ctx context.Context) (int64, error)
+	Entries(ctx context.Context, indexes []int64) ([]LogEntry, error)
+}
+
+// KnownFunc reports whether this deployment produced the Rekor entry, such as
+// index.Store.HasRekorEntry
+type KnownFunc func(ctx context.Context, uuid string) (bool, error)
+
+// Config holds monitor configuration
+type Config struct {
+	LogURL string // Identifies the log's checkpoint
+
+	// Watch are the identities to monitor. An entry is signed by a watched
+	// identity if its certificate satisfies any of the policies.
+	Watch []attestation.IdentityPolicy
+
+	// MaxEntries caps the entries scanned per poll so a monitor that fell
+	// behind catches up over several polls
+	MaxEntries int
+
+	// Settle leaves entries this recent for the next poll, giving Keystone
+	// time to index the attestations it just logged
+	Settle time.Duration
+
+	Clock clock.Clock // Defaults to the system clock
+}
+
+// DefaultConfig returns the monitor defaults for the log at logURL
+func DefaultConfig(logURL string) Config {
+	return Config{
+		LogURL:     logURL,
+		MaxEntries: 2000,
+		Settle:     2 * time.Minute,
+	}
+}
+
+// Monitor scans new log entries for unexpected signatures by watched identities
+type Monitor struct {
+	log    Log
+	store  *Store
+	known  KnownFunc
+	config Config
+	clock  clock.Clock
+}
+
+// New creates a monitor reading from the log, keeping its checkpoint in store
+// and asking known which entries were expected
+func New(rekor Log, store *Store, known KnownFunc, config Config) (*Monitor, error) {
+	if config.LogURL == "" {
+		return nil, fmt.Errorf("monitor requires the log URL")
+	}
+	if len(config.Watch) == 0 {
+		return nil, fmt.Errorf("monitor requires at least one identity to watch")
+	}
+	for i, policy := range config.Watch {
+		// A policy without constraints would match every entry in the log
+		if reflect.DeepEqual(policy, attestation.IdentityPolicy{}) {
+			return nil, fmt.Errorf("watched identity %d has no constraints", i)
+		}
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultConfig("").MaxEntries
+	}
+
+	return &Monitor{
+		log:    rekor,
+		store:  store,
+		known:  known,
+		config: config,
+		clock:  clock.OrReal(config.Clock),
+	}, nil
+}
+
+// Poll scans the entries logged since the last poll and returns those signed
+// by a watched identity that this deployment didn't produce. The first poll
+// only records the current end of the log; earlier entries aren't scanned.
+//
+// Several workers may poll the same log: each scans independently, but only
+// the one that advances the checkpoint returns the alerts, so every alert is
+// raised once.
+func (m *Monitor) Poll(ctx context.Context) ([]events.IdentityMisuse, error) {
+	next, found, err := m.store.Checkpoint(ctx, m.config.LogURL)
+	if err != nil {
+		return nil, err
+	}
+	size, err := m.log.Size(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		_, err := m.store.Advance(ctx, m.config.LogURL, -1, size)
+		return nil, err
+	}
+
+	end := size
+	if end > next+int64(m.config.MaxEntries) {
+		end = next + int64(m.config.MaxEntries)
+	}
+	if end <= next {
+		return nil, nil
+	}
+
+	indexes := make([]int64, 0, end-next)
+	for i := next; i < end; i++ {
+		indexes = append(indexes, i)
+	}
+	entries, err := m.log.Entries(ctx, indexes)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LogIndex < entries[j].LogIndex })
+
+	settled := m.clock.Now().Add(-m.config.Settle)
+	var alerts []events.IdentityMisuse
+	for _, entry := range entries {
+		if entry.IntegratedTime.After(settled) {
+			end = entry.LogIndex
+			break
+		}
+		alert, err := m.check(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+		if alert != nil {
+			alerts = append(alerts, *alert)
+		}
+	}
+
+	advanced, err := m.store.Advance(ctx, m.config.LogURL, next, end)
+	if err != nil || !advanced {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// check returns an alert if a watched identity signed the entry and this
+// deployment didn't produce it
+func (m *Monitor) check(ctx context.Context, entry LogEntry) (*events.IdentityMisuse, error) {
+	for _, cert := range entry.Certificates {
+		identity := attestation.ParseCertificateIdentity(cert)
+		if !m.watched(identity) {
+			continue
+		}
+
+		known, err := m.known(ctx, entry.UUID)
+		if err != nil {
+			return nil, err
+		}
+		if known {
+			return nil, nil
+		}
+		return &events.IdentityMisuse{
+			LogURL:         m.config.LogURL,
+			UUID:           entry.UUID,
+			LogIndex:       entry.LogIndex,
+			IntegratedTime: entry.IntegratedTime,
+			Kind:           entry.Kind,
+			Identity:       identity.SAN,
+			Issuer:         identity.Issuer,
+			Repository:     identity.Repository,
+			WorkflowRef:    identity.WorkflowRef,
+			Ref:            identity.Ref,
+		}, nil
+	}
+	return nil, nil
+}
+
+func (m *Monitor) watched(identity *attestation.CertificateIdentity) bool {
+	for i := range m.config.Watch {
+		if m.config.Watch[i].Verify(identity) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify polls the log and publishes an identity misuse event for each
+// unexpected entry
+func (m *Monitor) Notify(ctx context.Context, bus events.Bus, source string) error {
+	alerts, err := m.Poll(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range alerts {
+		event, err := events.NewEvent(events.TypeIdentityMisuse, source, alert)
+		if err != nil {
+			return err
+		}
+		if err := bus.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run calls Notify every interval until the context is cancelled
+func (m *Monitor) Run(ctx context.Context, bus events.Bus, source string, interval time.Duration) {
+	ticker := m.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if err := m.Notify(ctx, bus, source); err != nil {
+				log.Printf("Rekor monitor: %v", err)
+			}
+		}
+	}
+}
+
+// Store persists each log's checkpoint in SQLite
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a checkpoint store on the migrated database
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Checkpoint returns the first log index not yet scanned, and false if the
+// log has never been polled
+func (s *Store) Checkpoint(ctx context.Context, logURL string) (int64, bool, error) {
+	query := `SELECT next_index FROM rekor_monitor_checkpoints WHERE log_url = ?`
+
+	var next int64
+	err := s.db.QueryRowContext(ctx, query, logURL).Scan(&next)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get rekor checkpoint: %w", err)
+	}
+	return next, true, nil
+}
+
+// Advance moves the checkpoint from one index to the next, reporting false if
+// another monitor moved it first. A from of -1 creates the checkpoint.
+func (s *Store) Advance(ctx context.Context, logURL string, from, to int64) (bool, error) {
+	insertSQL := `
+		INSERT INTO rekor_monitor_checkpoints (log_url, next_index, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(log_url) DO NOTHING
+	`
+	updateSQL := `
+		UPDATE rekor_monitor_checkpoints
+		SET next_index = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE log_url = ? AND next_index = ?
+	`
+
+	var result sql.Result
+	var err error
+	if from < 0 {
+		result, err = s.db.ExecContext(ctx, insertSQL, logURL, to)
+	} else {
+		result, err = s.db.ExecContext(ctx, updateSQL, to, logURL, from)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to advance rekor checkpoint: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to advance rekor checkpoint: %w", err)
+	}
+	return rows == 1, nil
+}
@@ -0,0 +1,212 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRetrieveBatch is the most entries Rekor returns from one retrieve request
+const maxRetrieveBatch = 10
+
+// LogEntry is a Rekor entry with the signing certificates it embeds
+type LogEntry struct {
+	UUID           string
+	LogIndex       int64
+	IntegratedTime time.Time
+	Kind           string // Entry type, e.g. hashedrekord, dsse or intoto
+
+	// Certificates signing the entry. Entries signed with a bare public key
+	// have none.
+	Certificates []*x509.Certificate
+}
+
+// RekorClient reads entries from a Rekor transparency log
+type RekorClient struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewRekorClient creates a client for the Rekor instance at url
+func NewRekorClient(url string) *RekorClient {
+	return &RekorClient{
+		URL:    strings.TrimSuffix(url, "/"),
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Size returns the number of entries in the log across all shards, which is
+// one past the newest global log index
+func (c *RekorClient) Size(ctx context.Context) (int64, error) {
+	var info struct {
+		TreeSize       int64 `json:"treeSize"`
+		InactiveShards []struct {
+			TreeSize int64 `json:"treeSize"`
+		} `json:"inactiveShards"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/log", nil, &info); err != nil {
+		return 0, err
+	}
+
+	size := info.TreeSize
+	for _, shard := range info.InactiveShards {
+		size += shard.TreeSize
+	}
+	return size, nil
+}
+
+// Entries retrieves the entries at the given global log indexes, in batches
+// of at most ten. Indexes Rekor doesn't return are omitted.
+func (c *RekorClient) Entries(ctx context.Context, indexes []int64) ([]LogEntry, error) {
+	var entries []LogEntry
+	for start := 0; start < len(indexes); start += maxRetrieveBatch {
+		end := start + maxRetrieveBatch
+		if end > len(indexes) {
+			end = len(indexes)
+		}
+
+		var response []map[string]rekorEntry
+		request := map[string]interface{}{"logIndexes": indexes[start:end]}
+		if err := c.do(ctx, http.MethodPost, "/api/v1/log/entries/retrieve", request, &response); err != nil {
+			return nil, err
+		}
+		for _, batch := range response {
+			for uuid, raw := range batch {
+				entry, err := raw.decode(uuid)
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// do sends a JSON request and decodes the JSON response
+func (c *RekorClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode rekor request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, reader)
+	if err != nil {
+		return fmt.Errorf("invalid rekor URL: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("rekor %s is unreachable: %w", c.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rekor %s %s returned status %d", method, path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode rekor response: %w", err)
+	}
+	return nil
+}
+
+// rekorEntry is an entry as returned by the Rekor API
+type rekorEntry struct {
+	Body           string `json:"body"` // Base64 JSON of the entry type and spec
+	IntegratedTime int64  `json:"integratedTime"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// entryBody covers where each entry type keeps its signing keys, all of them
+// base64 PEM: hashedrekord and rekord sign with one key, dsse with one per
+// signature and intoto in the envelope (v0.0.2) or the spec (v0.0.1)
+type entryBody struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Signature struct {
+			PublicKey struct {
+				Content string `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+		Signatures []struct {
+			Verifier string `json:"verifier"`
+		} `json:"signatures"`
+		Content struct {
+			Envelope struct {
+				Signatures []struct {
+					PublicKey string `json:"publicKey"`
+				} `json:"signatures"`
+			} `json:"envelope"`
+		} `json:"content"`
+		PublicKey string `json:"publicKey"`
+	} `json:"spec"`
+}
+
+func (e rekorEntry) decode(uuid string) (LogEntry, error) {
+	entry := LogEntry{
+		UUID:           uuid,
+		LogIndex:       e.LogIndex,
+		IntegratedTime: time.Unix(e.IntegratedTime, 0).UTC(),
+	}
+
+	data, err := base64.StdEncoding.DecodeString(e.Body)
+	if err != nil {
+		return entry, fmt.Errorf("rekor entry %s has an invalid body: %w", uuid, err)
+	}
+	var body entryBody
+	if err := json.Unmarshal(data, &body); err != nil {
+		return entry, fmt.Errorf("rekor entry %s has an invalid body: %w", uuid, err)
+	}
+	entry.Kind = body.Kind
+
+	keys := []string{body.Spec.Signature.PublicKey.Content, body.Spec.PublicKey}
+	for _, signature := range body.Spec.Signatures {
+		keys = append(keys, signature.Verifier)
+	}
+	for _, signature := range body.Spec.Content.Envelope.Signatures {
+		keys = append(keys, signature.PublicKey)
+	}
+	for _, key := range keys {
+		if cert := parseCertificate(key); cert != nil {
+			entry.Certificates = append(entry.Certificates, cert)
+		}
+	}
+	return entry, nil
+}
+
+// parseCertificate decodes a base64 PEM certificate, returning nil for
+// anything else, such as a bare public key
+func parseCertificate(encoded string) *x509.Certificate {
+	if encoded == "" {
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return cert
+}
@@ -0,0 +1,148 @@
+package ocistore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// Discovery sources
+const (
+	SourceReferrers = "referrers"
+	SourceTag       = "tag"
+)
+
+// DiscoveredAttestation is a DSSE envelope found attached to an image
+type DiscoveredAttestation struct {
+	PredicateType string                `json:"predicate_type"`
+	Manifest      ocispec.Descriptor    `json:"manifest"`
+	Layer         ocispec.Descriptor    `json:"layer"`
+	Source        string                `json:"source"`
+	Envelope      *attestation.Envelope `json:"envelope"`
+}
+
+// Discovery holds every attestation attached to an image, grouped by predicate type
+type Discovery struct {
+	Subject         ocispec.Descriptor                 `json:"subject"`
+	ByPredicateType map[string][]DiscoveredAttestation `json:"by_predicate_type"`
+}
+
+// PredicateTypes returns the discovered predicate types in sorted order
+func (d *Discovery) PredicateTypes() []string {
+	types := make([]string, 0, len(d.ByPredicateType))
+	for predicateType := range d.ByPredicateType {
+		types = append(types, predicateType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Find returns the attestations for any of the given predicate types
+func (d *Discovery) Find(predicateTypes ...string) []DiscoveredAttestation {
+	var found []DiscoveredAttestation
+	for _, predicateType := range predicateTypes {
+		found = append(found, d.ByPredicateType[predicateType]...)
+	}
+	return found
+}
+
+// Discover lists the attestations attached to the image identified by subjectRef.
+// The OCI referrers API (or its tag schema fallback) is queried first, then the
+// cosign "<alg>-<hex>.att" tag; envelopes present in both are reported once.
+func (s *Store) Discover(ctx context.Context, subjectRef string) (*Discovery, error) {
+	subject, err := s.target.Resolve(ctx, subjectRef)
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeTargetNotResolved, err, "Failed to resolve %s", subjectRef)
+	}
+
+	discovery := &Discovery{
+		Subject:         subject,
+		ByPredicateType: make(map[string][]DiscoveredAttestation),
+	}
+	seen := make(map[digest.Digest]bool)
+
+	manifests, err := s.referrers(ctx, subject)
+	if err != nil {
+		// Registries that reject the referrers query still serve the cosign tag
+		log.Printf("Referrers lookup for %s failed, falling back to tag scheme: %v", subject.Digest, err)
+	}
+	for _, manifest := range manifests {
+		if err := s.collect(ctx, discovery, manifest, SourceReferrers, seen); err != nil {
+			return nil, err
+		}
+	}
+
+	tagged, err := s.target.Resolve(ctx, AttestationTag(subject.Digest))
+	switch {
+	case err == nil:
+		if err := s.collect(ctx, discovery, tagged, SourceTag, seen); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, errdef.ErrNotFound):
+		return nil, attestation.Wrap(attestation.CodeAttestationNotFound, err, "Failed to resolve %s", AttestationTag(subject.Digest))
+	}
+
+	return discovery, nil
+}
+
+// referrers lists manifests referencing the subject, if the target supports graph queries
+func (s *Store) referrers(ctx context.Context, subject ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	graph, ok := s.target.(content.ReadOnlyGraphStorage)
+	if !ok {
+		return nil, nil
+	}
+	return registry.Referrers(ctx, graph, subject, "")
+}
+
+// collect adds every DSSE layer of a manifest to the discovery, skipping envelopes already seen
+func (s *Store) collect(ctx context.Context, discovery *Discovery, manifestDesc ocispec.Descriptor, source string, seen map[digest.Digest]bool) error {
+	manifest, err := s.fetchManifest(ctx, manifestDesc)
+	if err != nil {
+		return err
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != MediaTypeDSSEEnvelope || seen[layer.Digest] {
+			continue
+		}
+		seen[layer.Digest] = true
+
+		data, err := content.FetchAll(ctx, s.target, layer)
+		if err != nil {
+			return attestation.Wrap(attestation.CodeAttestationNotFound, err, "Failed to fetch envelope %s", layer.Digest)
+		}
+
+		envelope, err := attestation.ParseEnvelope(data)
+		if err != nil {
+			log.Printf("Skipping malformed envelope %s: %v", layer.Digest, err)
+			continue
+		}
+
+		predicateType := layer.Annotations[AnnotationPredicateType]
+		if statement, err := envelope.Statement(); err == nil {
+			predicateType = statement.PredicateType
+		}
+		if predicateType == "" {
+			predicateType = manifest.Annotations[AnnotationPredicateType]
+		}
+
+		discovery.ByPredicateType[predicateType] = append(discovery.ByPredicateType[predicateType], DiscoveredAttestation{
+			PredicateType: predicateType,
+			Manifest:      manifestDesc,
+			Layer:         layer,
+			Source:        source,
+			Envelope:      envelope,
+		})
+	}
+
+	return nil
+}
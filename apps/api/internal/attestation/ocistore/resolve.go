@@ -0,0 +1,65 @@
+package ocistore
+
+import (
+	"context"
+	"os"
+
+	"oras.land/oras-go/v2/registry"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// GHCRRegistry is the GitHub Container Registry, which accepts the workflow's
+// GITHUB_TOKEN
+const GHCRRegistry = "ghcr.io"
+
+// RemoteOptionsFromEnv returns options for a registry. On ghcr.io it
+// authenticates as GITHUB_ACTOR with GITHUB_TOKEN when set; otherwise NewRemote
+// falls back to the Docker config.
+func RemoteOptionsFromEnv(registryName string) RemoteOptions {
+	var opts RemoteOptions
+	if registryName != GHCRRegistry {
+		return opts
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		opts.Username = os.Getenv("GITHUB_ACTOR")
+		if opts.Username == "" {
+			opts.Username = "keystone"
+		}
+		opts.Password = token
+	}
+	return opts
+}
+
+// ResolveDigest converts an image reference such as "ghcr.io/owner/app:v1.2.0"
+// into a subject naming the repository and the digest the tag points to, so
+// attestations are generated and verified against immutable content rather
+// than a tag that can move. Digest references resolve without contacting the
+// registry; a reference without a tag resolves "latest".
+func ResolveDigest(ctx context.Context, reference string, opts RemoteOptions) (attestation.Subject, error) {
+	ref, err := registry.ParseReference(reference)
+	if err != nil {
+		return attestation.Subject{}, attestation.Wrap(attestation.CodeTargetNotResolved, err, "Invalid image reference %q", reference)
+	}
+	name := ref.Registry + "/" + ref.Repository
+
+	if digest, err := ref.Digest(); err == nil {
+		return attestation.NewSubject(name, digest.String())
+	}
+
+	store, err := NewRemote(name, opts)
+	if err != nil {
+		return attestation.Subject{}, err
+	}
+	return store.ResolveSubject(ctx, name, ref.ReferenceOrDefault())
+}
+
+// ResolveSubject resolves a tag or digest in the store's repository to a
+// subject with the given name
+func (s *Store) ResolveSubject(ctx context.Context, name, reference string) (attestation.Subject, error) {
+	desc, err := s.target.Resolve(ctx, reference)
+	if err != nil {
+		return attestation.Subject{}, attestation.Wrap(attestation.CodeTargetNotResolved, err, "Failed to resolve %s:%s", name, reference)
+	}
+	return attestation.NewSubject(name, desc.Digest.String())
+}
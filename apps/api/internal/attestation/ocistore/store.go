@@ -0,0 +1,329 @@
+// Package ocistore stores DSSE attestation envelopes in OCI registries as
+// artifacts attached to the image they describe
+package ocistore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/retry"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+)
+
+// Media types and annotations used for attestation artifacts
+const (
+	MediaTypeDSSEEnvelope   = "application/vnd.dsse.envelope.v1+json"
+	ArtifactTypeAttestation = "application/vnd.in-toto+json"
+
+	AnnotationPredicateType = "in-toto.io/predicate-type"
+	AnnotationSubjectName   = "dev.keystone.attestation.subject"
+
+	// TagSuffix is the cosign-compatible suffix of digest-addressed attestation tags
+	TagSuffix = ".att"
+)
+
+// Store pushes and fetches attestation artifacts in one repository
+type Store struct {
+	target oras.Target
+}
+
+// CredentialSource supplies short-lived registry logins, such as those
+// federation exchanges the workflow's OIDC token for. Empty credentials fall
+// back to the Docker config.
+type CredentialSource interface {
+	Credential(ctx context.Context, registry string) (username, password string, err error)
+}
+
+// RemoteOptions configures access to a remote repository
+type RemoteOptions struct {
+	Username    string            // With Password, overrides credentials from the Docker config
+	Password    string            // Password or token, e.g. GITHUB_TOKEN for ghcr.io
+	Credentials CredentialSource  // Consulted on every login when Username and Password are empty
+	PlainHTTP   bool              // For local test registries
+	Transport   http.RoundTripper // Optional, e.g. to report call outcomes to the offline detector
+	RetryBudget *circuit.Budget   // Optional cap on retries shared with other clients
+}
+
+// New creates a store backed by any ORAS target, such as an in-memory or OCI layout store
+func New(target oras.Target) *Store {
+	return &Store{target: target}
+}
+
+// NewRemote creates a store for a remote repository such as "ghcr.io/owner/app"
+func NewRemote(repository string, opts RemoteOptions) (*Store, error) {
+	repo, err := remote.NewRepository(repository)
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeTargetNotResolved, err, "Invalid repository %q", repository)
+	}
+	repo.PlainHTTP = opts.PlainHTTP
+
+	client := &auth.Client{
+		Client: retry.DefaultClient,
+		Cache:  auth.NewCache(),
+	}
+	if opts.Transport != nil || opts.RetryBudget != nil {
+		transport := retry.NewTransport(opts.Transport)
+		if opts.RetryBudget != nil {
+			transport.Policy = func() retry.Policy { return budgetPolicy{budget: opts.RetryBudget} }
+		}
+		client.Client = &http.Client{Transport: transport}
+	}
+	var docker auth.CredentialFunc
+	if store, err := credentials.NewStoreFromDocker(credentials.StoreOptions{}); err == nil {
+		docker = credentials.Credential(store)
+	}
+	switch {
+	case opts.Username != "" || opts.Password != "":
+		client.Credential = auth.StaticCredential(repo.Reference.Registry, auth.Credential{
+			Username: opts.Username,
+			Password: opts.Password,
+		})
+	case opts.Credentials != nil:
+		// Asked again on each login, so logins outliving the credentials
+		// get fresh ones
+		client.Credential = func(ctx context.Context, hostport string) (auth.Credential, error) {
+			username, password, err := opts.Credentials.Credential(ctx, hostport)
+			if err != nil {
+				return auth.EmptyCredential, err
+			}
+			if username == "" && password == "" && docker != nil {
+				return docker(ctx, hostport)
+			}
+			return auth.Credential{Username: username, Password: password}, nil
+		}
+	case docker != nil:
+		// Fall back to "docker login" credentials, including credential helpers
+		client.Credential = docker
+	}
+	repo.Client = client
+
+	return New(repo), nil
+}
+
+// budgetPolicy applies the default retry policy, charging its retries to a
+// shared budget
+type budgetPolicy struct {
+	budget *circuit.Budget
+}
+
+func (p budgetPolicy) Retry(attempt int, resp *http.Response, err error) (time.Duration, error) {
+	if attempt == 0 {
+		p.budget.RecordRequest()
+	}
+	duration, policyErr := retry.DefaultPolicy.Retry(attempt, resp, err)
+	if policyErr != nil || duration < 0 {
+		return duration, policyErr
+	}
+	if budgetErr := p.budget.TryRetry(); budgetErr != nil {
+		return 0, budgetErr
+	}
+	return duration, nil
+}
+
+// PushResult describes an attestation stored in the registry
+type PushResult struct {
+	Subject       ocispec.Descriptor `json:"subject"`
+	Manifest      ocispec.Descriptor `json:"manifest"`
+	Envelope      ocispec.Descriptor `json:"envelope"`
+	Tag           string             `json:"tag"`
+	PredicateType string             `json:"predicate_type"`
+}
+
+// Push attaches a DSSE envelope to the image identified by subjectRef (a tag or digest).
+// The artifact manifest references the image as its subject so it is discoverable through
+// the referrers API, and the envelope is also added to the digest-addressed
+// "<alg>-<hex>.att" tag used by registries without referrers support.
+func (s *Store) Push(ctx context.Context, subjectRef string, envelope *attestation.Envelope) (*PushResult, error) {
+	statement, err := envelope.Statement()
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := s.target.Resolve(ctx, subjectRef)
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeTargetNotResolved, err, "Failed to resolve %s", subjectRef)
+	}
+	if err := matchesStatement(subject, statement); err != nil {
+		return nil, err
+	}
+
+	// Only canonical payloads are stored, so an attestation always has one digest
+	if err := envelope.VerifyCanonical(); err != nil {
+		return nil, err
+	}
+	data, err := attestation.CanonicalJSON(envelope)
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeRegistryPushFailed, err, "Failed to encode envelope")
+	}
+
+	layer := content.NewDescriptorFromBytes(MediaTypeDSSEEnvelope, data)
+	layer.Annotations = map[string]string{AnnotationPredicateType: statement.PredicateType}
+	if err := pushIfMissing(ctx, s.target, layer, data); err != nil {
+		return nil, attestation.Wrap(attestation.CodeRegistryPushFailed, err, "Failed to push envelope blob")
+	}
+
+	created := time.Now().UTC().Format(time.RFC3339)
+	manifest, err := oras.PackManifest(ctx, s.target, oras.PackManifestVersion1_1, ArtifactTypeAttestation, oras.PackManifestOptions{
+		Subject: &subject,
+		Layers:  []ocispec.Descriptor{layer},
+		ManifestAnnotations: map[string]string{
+			ocispec.AnnotationCreated: created,
+			AnnotationPredicateType:   statement.PredicateType,
+			AnnotationSubjectName:     subjectRef,
+		},
+	})
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeRegistryPushFailed, err, "Failed to push attestation manifest")
+	}
+
+	tag := AttestationTag(subject.Digest)
+	if err := s.appendToTag(ctx, tag, layer, created); err != nil {
+		return nil, err
+	}
+
+	return &PushResult{
+		Subject:       subject,
+		Manifest:      manifest,
+		Envelope:      layer,
+		Tag:           tag,
+		PredicateType: statement.PredicateType,
+	}, nil
+}
+
+// appendToTag adds the envelope layer to the manifest behind the digest-addressed tag,
+// keeping envelopes pushed earlier for the same image
+func (s *Store) appendToTag(ctx context.Context, tag string, layer ocispec.Descriptor, created string) error {
+	layers, err := s.tagLayers(ctx, tag)
+	if err != nil {
+		return err
+	}
+	for _, existing := range layers {
+		if existing.Digest == layer.Digest {
+			return nil
+		}
+	}
+	layers = append(layers, layer)
+
+	manifest, err := oras.PackManifest(ctx, s.target, oras.PackManifestVersion1_1, ArtifactTypeAttestation, oras.PackManifestOptions{
+		Layers:              layers,
+		ManifestAnnotations: map[string]string{ocispec.AnnotationCreated: created},
+	})
+	if err != nil {
+		return attestation.Wrap(attestation.CodeRegistryPushFailed, err, "Failed to push %s manifest", tag)
+	}
+	if err := s.target.Tag(ctx, manifest, tag); err != nil {
+		return attestation.Wrap(attestation.CodeRegistryPushFailed, err, "Failed to tag %s", tag)
+	}
+	return nil
+}
+
+// tagLayers returns the envelope layers currently behind a tag, or none if it does not exist
+func (s *Store) tagLayers(ctx context.Context, tag string) ([]ocispec.Descriptor, error) {
+	desc, err := s.target.Resolve(ctx, tag)
+	if errors.Is(err, errdef.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeRegistryPushFailed, err, "Failed to resolve %s", tag)
+	}
+
+	manifest, err := s.fetchManifest(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Layers, nil
+}
+
+// FetchEnvelopes returns the DSSE envelopes carried by an attestation manifest
+func (s *Store) FetchEnvelopes(ctx context.Context, manifestDesc ocispec.Descriptor) ([]*attestation.Envelope, error) {
+	manifest, err := s.fetchManifest(ctx, manifestDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelopes []*attestation.Envelope
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != MediaTypeDSSEEnvelope {
+			continue
+		}
+		data, err := content.FetchAll(ctx, s.target, layer)
+		if err != nil {
+			return nil, attestation.Wrap(attestation.CodeAttestationNotFound, err, "Failed to fetch envelope %s", layer.Digest)
+		}
+
+		envelope, err := attestation.ParseEnvelope(data)
+		if err != nil {
+			return nil, attestation.Wrap(attestation.CodeVerificationFailed, err, "Envelope %s is malformed", layer.Digest)
+		}
+		envelopes = append(envelopes, envelope)
+	}
+
+	return envelopes, nil
+}
+
+// fetchManifest fetches and decodes an image manifest
+func (s *Store) fetchManifest(ctx context.Context, desc ocispec.Descriptor) (*ocispec.Manifest, error) {
+	data, err := content.FetchAll(ctx, s.target, desc)
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeAttestationNotFound, err, "Failed to fetch manifest %s", desc.Digest)
+	}
+
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, attestation.Wrap(attestation.CodeAttestationNotFound, err, "Manifest %s is malformed", desc.Digest)
+	}
+	return &manifest, nil
+}
+
+// AttestationTag returns the digest-addressed tag for an image digest, e.g. "sha256-abc….att"
+func AttestationTag(subject digest.Digest) string {
+	return strings.Replace(subject.String(), ":", "-", 1) + TagSuffix
+}
+
+// matchesStatement rejects envelopes whose statement does not cover the resolved image
+func matchesStatement(subject ocispec.Descriptor, statement *attestation.Statement) error {
+	alg, hex := subject.Digest.Algorithm().String(), subject.Digest.Encoded()
+	for _, s := range statement.Subject {
+		if strings.EqualFold(s.Digest[alg], hex) {
+			return nil
+		}
+	}
+	return attestation.Errorf(attestation.CodeTargetNotResolved,
+		"Statement subjects do not include %s; refusing to attach the attestation", subject.Digest)
+}
+
+// pushIfMissing pushes a blob unless the target already has it
+func pushIfMissing(ctx context.Context, target oras.Target, desc ocispec.Descriptor, data []byte) error {
+	exists, err := target.Exists(ctx, desc)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	err = target.Push(ctx, desc, bytes.NewReader(data))
+	if errors.Is(err, errdef.ErrAlreadyExists) {
+		return nil
+	}
+	return err
+}
+
+// StorageBytes reports the registry storage consumed by the pushed envelope and manifest
+func (r *PushResult) StorageBytes() int64 {
+	return r.Envelope.Size + r.Manifest.Size
+}
@@ -0,0 +1,304 @@
+// Package pipeline generates several attestations about one artifact in a
+// single run: provenance, SBOM, scan results and any other predicates are
+// generated concurrently where they don't depend on each other, against one
+// resolved subject, then signed and pushed as a batch
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/ocistore"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+)
+
+// Input is what a step generates its statement from
+type Input struct {
+	Subject attestation.Subject // Resolved once and shared by every step
+	Build   attestation.BuildContext
+
+	// Statements generated by the step's dependencies, by step name
+	Statements map[string]*attestation.Statement
+}
+
+// GenerateFunc produces a step's statement
+type GenerateFunc func(ctx context.Context, in Input) (*attestation.Statement, error)
+
+// Step generates one attestation. Steps run as soon as the steps they depend
+// on have finished, so independent steps run concurrently.
+type Step struct {
+	Name      string
+	DependsOn []string
+	Generate  GenerateFunc
+}
+
+// Generator adapts a predicate generator, such as a provenance or SCAI
+// builder, into a step
+func Generator(name string, generator attestation.PredicateGenerator, dependsOn ...string) Step {
+	return Step{
+		Name:      name,
+		DependsOn: dependsOn,
+		Generate: func(_ context.Context, in Input) (*attestation.Statement, error) {
+			return generator.Build([]attestation.Subject{in.Subject}, in.Build)
+		},
+	}
+}
+
+// Predicate makes a step attesting a document produced by another tool, e.g.
+// a CycloneDX SBOM or vulnerability scan results, under the predicate type
+func Predicate(name, predicateType string, produce func(ctx context.Context, in Input) (interface{}, error), dependsOn ...string) Step {
+	return Step{
+		Name:      name,
+		DependsOn: dependsOn,
+		Generate: func(ctx context.Context, in Input) (*attestation.Statement, error) {
+			predicate, err := produce(ctx, in)
+			if err != nil {
+				return nil, err
+			}
+			return &attestation.Statement{
+				Type:          attestation.StatementTypeV1,
+				Subject:       []attestation.Subject{in.Subject},
+				PredicateType: predicateType,
+				Predicate:     predicate,
+			}, nil
+		},
+	}
+}
+
+// Publisher stores signed envelopes; ocistore.Store is the registry publisher
+type Publisher interface {
+	Push(ctx context.Context, subjectRef string, envelope *attestation.Envelope) (*ocistore.PushResult, error)
+}
+
+// Config holds pipeline configuration
+type Config struct {
+	Concurrency int         // Steps generated or signed at once; every step when zero
+	Clock       clock.Clock // Defaults to the system clock
+}
+
+// Pipeline runs a fixed set of steps for each artifact
+type Pipeline struct {
+	steps     []Step
+	index     map[string]int
+	signer    attestation.Signer
+	publisher Publisher
+	config    Config
+	clock     clock.Clock
+}
+
+// New creates a pipeline signing with the signer and pushing to the
+// publisher, which may be nil to only sign. Step names must be unique and
+// dependencies must name earlier steps, which rules out cycles.
+func New(signer attestation.Signer, publisher Publisher, config Config, steps ...Step) (*Pipeline, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("pipeline requires a signer")
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("pipeline requires at least one step")
+	}
+
+	index := make(map[string]int, len(steps))
+	for i, step := range steps {
+		if step.Name == "" || step.Generate == nil {
+			return nil, fmt.Errorf("step %d needs a name and a generate function", i)
+		}
+		if _, duplicate := index[step.Name]; duplicate {
+			return nil, fmt.Errorf("duplicate step %q", step.Name)
+		}
+		for _, dependency := range step.DependsOn {
+			if _, earlier := index[dependency]; !earlier {
+				return nil, fmt.Errorf("step %q depends on %q, which is not an earlier step", step.Name, dependency)
+			}
+		}
+		index[step.Name] = i
+	}
+
+	if config.Concurrency <= 0 {
+		config.Concurrency = len(steps)
+	}
+	return &Pipeline{
+		steps:     steps,
+		index:     index,
+		signer:    signer,
+		publisher: publisher,
+		config:    config,
+		clock:     clock.OrReal(config.Clock),
+	}, nil
+}
+
+// StepResult is one step's attestation
+type StepResult struct {
+	Name          string                 `json:"name"`
+	PredicateType string                 `json:"predicate_type"`
+	Envelope      *attestation.Envelope  `json:"envelope"`
+	Push          *ocistore.PushResult   `json:"push,omitempty"` // Nil without a publisher
+	Generated     time.Duration          `json:"generated"`      // Time the step took to generate its statement
+	Statement     *attestation.Statement `json:"-"`
+}
+
+// Result is the outcome of a run, with step results in step order
+type Result struct {
+	Subject attestation.Subject `json:"subject"`
+	Steps   []StepResult        `json:"steps"`
+	Elapsed time.Duration       `json:"elapsed"` // Wall time of the whole run
+}
+
+// Run generates every step's statement about the subject, then signs them
+// all and pushes them to the subject's repository. Nothing is signed or
+// pushed unless every step succeeds; the first failure cancels steps still
+// running.
+func (p *Pipeline) Run(ctx context.Context, subject attestation.Subject, build attestation.BuildContext) (*Result, error) {
+	if err := subject.Validate(); err != nil {
+		return nil, err
+	}
+	started := p.clock.Now()
+
+	results, err := p.generate(ctx, subject, build)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.sign(ctx, results); err != nil {
+		return nil, err
+	}
+	if p.publisher != nil {
+		if err := p.push(ctx, subject, results); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Result{
+		Subject: subject,
+		Steps:   results,
+		Elapsed: p.clock.Since(started),
+	}, nil
+}
+
+// generate runs the steps as a dependency graph, each starting once its
+// dependencies are done and a concurrency slot is free
+func (p *Pipeline) generate(ctx context.Context, subject attestation.Subject, build attestation.BuildContext) ([]StepResult, error) {
+	results := make([]StepResult, len(p.steps))
+	done := make([]chan struct{}, len(p.steps))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	slots := make(chan struct{}, p.config.Concurrency)
+
+	group, ctx := errgroup.WithContext(ctx)
+	for i, step := range p.steps {
+		i, step := i, step
+		group.Go(func() error {
+			statements := make(map[string]*attestation.Statement, len(step.DependsOn))
+			for _, dependency := range step.DependsOn {
+				j := p.index[dependency]
+				select {
+				case <-done[j]:
+					statements[dependency] = results[j].Statement
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			// Slots are taken only once dependencies are met, so waiting
+			// steps never hold one
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			defer func() { <-slots }()
+
+			started := p.clock.Now()
+			statement, err := step.Generate(ctx, Input{Subject: subject, Build: build, Statements: statements})
+			if err != nil {
+				return fmt.Errorf("step %s: %w", step.Name, err)
+			}
+			if err := coversSubject(statement, subject); err != nil {
+				return fmt.Errorf("step %s: %w", step.Name, err)
+			}
+
+			results[i] = StepResult{
+				Name:          step.Name,
+				PredicateType: statement.PredicateType,
+				Statement:     statement,
+				Generated:     p.clock.Since(started),
+			}
+			close(done[i])
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// sign envelopes every statement canonically and signs them concurrently
+func (p *Pipeline) sign(ctx context.Context, results []StepResult) error {
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(p.config.Concurrency)
+	for i := range results {
+		result := &results[i]
+		group.Go(func() error {
+			envelope, err := attestation.NewEnvelope(result.Statement)
+			if err != nil {
+				return fmt.Errorf("step %s: %w", result.Name, err)
+			}
+			if err := attestation.SignEnvelope(ctx, envelope, p.signer); err != nil {
+				return fmt.Errorf("step %s: %w", result.Name, err)
+			}
+			result.Envelope = envelope
+			return nil
+		})
+	}
+	return group.Wait()
+}
+
+// push stores the envelopes one at a time against the resolved digest. Pushes
+// for one subject update the same digest-addressed tag, so running them
+// concurrently would lose envelopes.
+func (p *Pipeline) push(ctx context.Context, subject attestation.Subject, results []StepResult) error {
+	reference, err := digestReference(subject)
+	if err != nil {
+		return err
+	}
+
+	for i := range results {
+		pushed, err := p.publisher.Push(ctx, reference, results[i].Envelope)
+		if err != nil {
+			return fmt.Errorf("step %s: %w", results[i].Name, err)
+		}
+		results[i].Push = pushed
+	}
+	return nil
+}
+
+// digestReference pins pushes to the resolved digest, so a tag moving
+// mid-run can't split the batch across two images
+func digestReference(subject attestation.Subject) (string, error) {
+	for _, algorithm := range []string{"sha256", "sha512"} {
+		if value := subject.Digest[algorithm]; value != "" {
+			return subject.Name + "@" + algorithm + ":" + value, nil
+		}
+	}
+	return "", attestation.Errorf(attestation.CodeTargetNotResolved, "Subject %s has no sha256 or sha512 digest to push against", subject.Name)
+}
+
+// coversSubject rejects statements that are not about the shared subject
+func coversSubject(statement *attestation.Statement, subject attestation.Subject) error {
+	if statement == nil {
+		return fmt.Errorf("no statement generated")
+	}
+	for _, candidate := range statement.Subject {
+		for algorithm, value := range subject.Digest {
+			if strings.EqualFold(candidate.Digest[algorithm], value) {
+				return nil
+			}
+		}
+	}
+	return attestation.Errorf(attestation.CodeTargetNotResolved, "Statement is not about the pipeline subject %s", subject)
+}
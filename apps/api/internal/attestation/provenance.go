@@ -0,0 +1,387 @@
+package attestation
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// SLSA provenance predicate types
+const (
+	PredicateSLSAProvenanceV1  = "https://slsa.dev/provenance/v1"
+	PredicateSLSAProvenanceV02 = "https://slsa.dev/provenance/v0.2"
+)
+
+// Build types recorded in provenance predicates
+const (
+	BuildTypeGitHubWorkflowV1 = "https://actions.github.io/buildtypes/workflow/v1"
+	BuildTypeGenericV02       = "https://github.com/slsa-framework/slsa-github-generator/generic@v1"
+)
+
+// BuildContext describes the CI run that produced the attested artifacts
+type BuildContext struct {
+	ServerURL    string    `json:"server_url"`
+	Repository   string    `json:"repository"` // owner/name
+	RepositoryID string    `json:"repository_id,omitempty"`
+	OwnerID      string    `json:"owner_id,omitempty"`
+	Ref          string    `json:"ref"`
+	SHA          string    `json:"sha"`
+	WorkflowPath string    `json:"workflow_path"` // .github/workflows/release.yml
+	EventName    string    `json:"event_name"`
+	RunID        string    `json:"run_id"`
+	RunAttempt   string    `json:"run_attempt"`
+	BuilderID    string    `json:"builder_id,omitempty"`
+	StartedOn    time.Time `json:"started_on"`
+	FinishedOn   time.Time `json:"finished_on"`
+}
+
+// BuildContextFromEnv populates a build context from GitHub Actions environment variables
+func BuildContextFromEnv() BuildContext {
+	workflowPath := ""
+	if ref := os.Getenv("GITHUB_WORKFLOW_REF"); ref != "" {
+		// owner/repo/.github/workflows/release.yml@refs/heads/main
+		path, _, _ := strings.Cut(ref, "@")
+		if idx := strings.Index(path, "/.github/"); idx >= 0 {
+			workflowPath = path[idx+1:]
+		}
+	}
+
+	return BuildContext{
+		ServerURL:    os.Getenv("GITHUB_SERVER_URL"),
+		Repository:   os.Getenv("GITHUB_REPOSITORY"),
+		RepositoryID: os.Getenv("GITHUB_REPOSITORY_ID"),
+		OwnerID:      os.Getenv("GITHUB_REPOSITORY_OWNER_ID"),
+		Ref:          os.Getenv("GITHUB_REF"),
+		SHA:          os.Getenv("GITHUB_SHA"),
+		WorkflowPath: workflowPath,
+		EventName:    os.Getenv("GITHUB_EVENT_NAME"),
+		RunID:        os.Getenv("GITHUB_RUN_ID"),
+		RunAttempt:   os.Getenv("GITHUB_RUN_ATTEMPT"),
+	}
+}
+
+// serverURL returns the configured server URL, defaulting to github.com
+func (b BuildContext) serverURL() string {
+	if b.ServerURL == "" {
+		return "https://github.com"
+	}
+	return strings.TrimRight(b.ServerURL, "/")
+}
+
+// builderID returns the explicit builder ID or derives one from the workflow identity
+func (b BuildContext) builderID() string {
+	if b.BuilderID != "" {
+		return b.BuilderID
+	}
+	return fmt.Sprintf("%s/%s/%s@%s", b.serverURL(), b.Repository, b.WorkflowPath, b.Ref)
+}
+
+// sourceURI returns the git URI of the source repository at the built ref
+func (b BuildContext) sourceURI() string {
+	host := strings.TrimPrefix(strings.TrimPrefix(b.serverURL(), "https://"), "http://")
+	return fmt.Sprintf("git+https://%s/%s@%s", host, b.Repository, b.Ref)
+}
+
+// invocationID returns a URI uniquely identifying the workflow run attempt
+func (b BuildContext) invocationID() string {
+	attempt := b.RunAttempt
+	if attempt == "" {
+		attempt = "1"
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s/attempts/%s", b.serverURL(), b.Repository, b.RunID, attempt)
+}
+
+// validate checks the fields every provenance version requires
+func (b BuildContext) validate() error {
+	var missing []string
+	if b.Repository == "" {
+		missing = append(missing, "repository")
+	}
+	if b.SHA == "" {
+		missing = append(missing, "sha")
+	}
+	if b.Ref == "" {
+		missing = append(missing, "ref")
+	}
+	if b.BuilderID == "" && b.WorkflowPath == "" {
+		missing = append(missing, "workflow_path")
+	}
+	if len(missing) > 0 {
+		return Errorf(CodeSigningFailed, "Build context is missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ProvenanceV1 is the SLSA v1 provenance predicate
+type ProvenanceV1 struct {
+	BuildDefinition BuildDefinitionV1 `json:"buildDefinition"`
+	RunDetails      RunDetailsV1      `json:"runDetails"`
+}
+
+// BuildDefinitionV1 describes the inputs of a SLSA v1 build
+type BuildDefinitionV1 struct {
+	BuildType            string                 `json:"buildType"`
+	ExternalParameters   map[string]interface{} `json:"externalParameters"`
+	InternalParameters   map[string]interface{} `json:"internalParameters,omitempty"`
+	ResolvedDependencies []ResourceDescriptor   `json:"resolvedDependencies,omitempty"`
+}
+
+// ResourceDescriptor references an artifact consumed by a SLSA v1 build or
+// cited by a SCAI attribute
+type ResourceDescriptor struct {
+	Name   string    `json:"name,omitempty"`
+	URI    string    `json:"uri,omitempty"`
+	Digest DigestSet `json:"digest,omitempty"`
+}
+
+// RunDetailsV1 describes the execution of a SLSA v1 build
+type RunDetailsV1 struct {
+	Builder  BuilderV1       `json:"builder"`
+	Metadata BuildMetadataV1 `json:"metadata"`
+}
+
+// BuilderV1 identifies the SLSA v1 build platform
+type BuilderV1 struct {
+	ID string `json:"id"`
+}
+
+// BuildMetadataV1 holds SLSA v1 run metadata
+type BuildMetadataV1 struct {
+	InvocationID string     `json:"invocationId"`
+	StartedOn    *time.Time `json:"startedOn,omitempty"`
+	FinishedOn   *time.Time `json:"finishedOn,omitempty"`
+}
+
+// ProvenanceV02 is the SLSA v0.2 provenance predicate
+type ProvenanceV02 struct {
+	Builder     BuilderV02       `json:"builder"`
+	BuildType   string           `json:"buildType"`
+	Invocation  InvocationV02    `json:"invocation"`
+	Metadata    BuildMetadataV02 `json:"metadata"`
+	Materials   []MaterialV02    `json:"materials"`
+	BuildConfig interface{}      `json:"buildConfig,omitempty"`
+}
+
+// BuilderV02 identifies the SLSA v0.2 builder
+type BuilderV02 struct {
+	ID string `json:"id"`
+}
+
+// InvocationV02 describes how a SLSA v0.2 build was started
+type InvocationV02 struct {
+	ConfigSource ConfigSourceV02        `json:"configSource"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	Environment  map[string]interface{} `json:"environment"`
+}
+
+// ConfigSourceV02 references the build definition file of a SLSA v0.2 build
+type ConfigSourceV02 struct {
+	URI        string    `json:"uri"`
+	Digest     DigestSet `json:"digest"`
+	EntryPoint string    `json:"entryPoint"`
+}
+
+// BuildMetadataV02 holds SLSA v0.2 run metadata
+type BuildMetadataV02 struct {
+	BuildInvocationID string          `json:"buildInvocationId"`
+	BuildStartedOn    *time.Time      `json:"buildStartedOn,omitempty"`
+	BuildFinishedOn   *time.Time      `json:"buildFinishedOn,omitempty"`
+	Completeness      CompletenessV02 `json:"completeness"`
+	Reproducible      bool            `json:"reproducible"`
+}
+
+// CompletenessV02 declares which SLSA v0.2 fields are complete
+type CompletenessV02 struct {
+	Parameters  bool `json:"parameters"`
+	Environment bool `json:"environment"`
+	Materials   bool `json:"materials"`
+}
+
+// MaterialV02 references an artifact consumed by a SLSA v0.2 build
+type MaterialV02 struct {
+	URI    string    `json:"uri"`
+	Digest DigestSet `json:"digest"`
+}
+
+// ProvenanceOption configures a ProvenanceBuilder
+type ProvenanceOption func(*ProvenanceBuilder)
+
+// WithProvenanceVersion selects the predicate version to emit
+func WithProvenanceVersion(predicateType string) ProvenanceOption {
+	return func(b *ProvenanceBuilder) {
+		b.predicateType = predicateType
+	}
+}
+
+// WithExternalParameters adds user-controlled build parameters to the predicate
+func WithExternalParameters(params map[string]interface{}) ProvenanceOption {
+	return func(b *ProvenanceBuilder) {
+		for key, value := range params {
+			b.externalParameters[key] = value
+		}
+	}
+}
+
+// WithClaims records mapped OIDC claim values (see ClaimMapper) in the predicate's
+// internal parameters (v1) or invocation environment (v0.2)
+func WithClaims(values map[string]string) ProvenanceOption {
+	return func(b *ProvenanceBuilder) {
+		for key, value := range values {
+			b.claims[key] = value
+		}
+	}
+}
+
+// ProvenanceBuilder produces SLSA provenance statements in a selected predicate version
+type ProvenanceBuilder struct {
+	predicateType      string
+	externalParameters map[string]interface{}
+	claims             map[string]string
+}
+
+// NewProvenanceBuilder creates a builder that emits v1 provenance unless another version is selected
+func NewProvenanceBuilder(opts ...ProvenanceOption) *ProvenanceBuilder {
+	b := &ProvenanceBuilder{
+		predicateType:      PredicateSLSAProvenanceV1,
+		externalParameters: make(map[string]interface{}),
+		claims:             make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Build validates the subjects and build context and returns a provenance statement
+func (b *ProvenanceBuilder) Build(subjects []Subject, build BuildContext) (*Statement, error) {
+	if err := validateSubjects(subjects); err != nil {
+		return nil, err
+	}
+	if err := build.validate(); err != nil {
+		return nil, err
+	}
+
+	switch b.predicateType {
+	case PredicateSLSAProvenanceV1:
+		return generateSLSAProvenance(subjects, build, b.externalParameters, b.claims), nil
+	case PredicateSLSAProvenanceV02:
+		return generateSLSAProvenanceV02(subjects, build, b.externalParameters, b.claims), nil
+	default:
+		return nil, Errorf(CodeSigningFailed, "Unsupported provenance predicate type %s", b.predicateType)
+	}
+}
+
+// generateSLSAProvenance builds a slsa.dev/provenance/v1 statement
+func generateSLSAProvenance(subjects []Subject, build BuildContext, params map[string]interface{}, claims map[string]string) *Statement {
+	external := map[string]interface{}{
+		"workflow": map[string]interface{}{
+			"ref":        build.Ref,
+			"repository": fmt.Sprintf("%s/%s", build.serverURL(), build.Repository),
+			"path":       build.WorkflowPath,
+		},
+	}
+	for key, value := range params {
+		external[key] = value
+	}
+
+	internal := map[string]interface{}{
+		"github": map[string]interface{}{
+			"event_name":          build.EventName,
+			"repository_id":       build.RepositoryID,
+			"repository_owner_id": build.OwnerID,
+		},
+	}
+	if len(claims) > 0 {
+		internal["oidc_claims"] = claims
+	}
+
+	predicate := ProvenanceV1{
+		BuildDefinition: BuildDefinitionV1{
+			BuildType:          BuildTypeGitHubWorkflowV1,
+			ExternalParameters: external,
+			InternalParameters: internal,
+			ResolvedDependencies: []ResourceDescriptor{{
+				URI:    build.sourceURI(),
+				Digest: DigestSet{"gitCommit": build.SHA},
+			}},
+		},
+		RunDetails: RunDetailsV1{
+			Builder: BuilderV1{ID: build.builderID()},
+			Metadata: BuildMetadataV1{
+				InvocationID: build.invocationID(),
+				StartedOn:    optionalTime(build.StartedOn),
+				FinishedOn:   optionalTime(build.FinishedOn),
+			},
+		},
+	}
+
+	return &Statement{
+		Type:          StatementTypeV1,
+		Subject:       subjects,
+		PredicateType: PredicateSLSAProvenanceV1,
+		Predicate:     predicate,
+	}
+}
+
+// generateSLSAProvenanceV02 builds a slsa.dev/provenance/v0.2 statement for older verifiers
+func generateSLSAProvenanceV02(subjects []Subject, build BuildContext, params map[string]interface{}, claims map[string]string) *Statement {
+	parameters := make(map[string]interface{})
+	for key, value := range params {
+		parameters[key] = value
+	}
+
+	predicate := ProvenanceV02{
+		Builder:   BuilderV02{ID: build.builderID()},
+		BuildType: BuildTypeGenericV02,
+		Invocation: InvocationV02{
+			ConfigSource: ConfigSourceV02{
+				URI:        build.sourceURI(),
+				Digest:     DigestSet{"sha1": build.SHA},
+				EntryPoint: build.WorkflowPath,
+			},
+			Parameters: parameters,
+			Environment: map[string]interface{}{
+				"github_event_name":          build.EventName,
+				"github_run_id":              build.RunID,
+				"github_run_attempt":         build.RunAttempt,
+				"github_repository_id":       build.RepositoryID,
+				"github_repository_owner_id": build.OwnerID,
+			},
+		},
+		Metadata: BuildMetadataV02{
+			BuildInvocationID: build.invocationID(),
+			BuildStartedOn:    optionalTime(build.StartedOn),
+			BuildFinishedOn:   optionalTime(build.FinishedOn),
+			Completeness: CompletenessV02{
+				Parameters:  true,
+				Environment: false,
+				Materials:   false,
+			},
+		},
+		Materials: []MaterialV02{{
+			URI:    build.sourceURI(),
+			Digest: DigestSet{"sha1": build.SHA},
+		}},
+	}
+	if len(claims) > 0 {
+		predicate.Invocation.Environment["oidc_claims"] = claims
+	}
+
+	// v0.2 predicates are consumed by verifiers that expect the v0.1 statement envelope
+	return &Statement{
+		Type:          StatementTypeV01,
+		Subject:       subjects,
+		PredicateType: PredicateSLSAProvenanceV02,
+		Predicate:     predicate,
+	}
+}
+
+// optionalTime returns nil for zero times so they are omitted from JSON
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
@@ -0,0 +1,258 @@
+package attestation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/messages"
+	"github.com/salman-frs/keystone/apps/api/internal/remediation"
+)
+
+// Report is a self-contained account of a verification: every check
+// performed, the signer identity, the transparency log entry, the policy
+// results and, on failure, the SIGN_ code with remediation. It is rendered as
+// JSON for tooling and as Markdown for pull request comments.
+type Report struct {
+	Subject     string                `json:"subject"`
+	Valid       bool                  `json:"valid"`
+	VerifiedAt  time.Time             `json:"verified_at"`
+	Checks      []Check               `json:"checks"`
+	Certificate *CertificateIdentity  `json:"certificate,omitempty"`
+	Rekor       *RekorEntry           `json:"rekor,omitempty"`
+	Signers     []string              `json:"signers,omitempty"`
+	Policy      []PolicyResult        `json:"policy,omitempty"`
+	BuildLevel  *BuildLevelAssessment `json:"build_level,omitempty"`
+	Conversions []Conversion          `json:"conversions,omitempty"`
+	Failure     *ReportFailure        `json:"failure,omitempty"`
+}
+
+// ReportFailure is why a verification failed
+type ReportFailure struct {
+	Code        string             `json:"code"`
+	Message     string             `json:"message"`
+	Localized   *messages.Message  `json:"localized,omitempty"` // Catalog message for Code
+	Remediation []remediation.Hint `json:"remediation,omitempty"`
+}
+
+// NewReport builds a report from a verification result. The bundle, when
+// given, supplies the transparency log entry.
+func NewReport(bundle *Bundle, result *VerificationResult) *Report {
+	report := &Report{
+		Subject:     result.Subject,
+		Valid:       result.Valid,
+		VerifiedAt:  result.VerifiedAt,
+		Checks:      result.Checks,
+		Certificate: result.Certificate,
+		Signers:     result.Signers,
+		Policy:      result.Policy,
+		BuildLevel:  result.BuildLevel,
+		Conversions: result.Conversions,
+	}
+	if report.Checks == nil {
+		report.Checks = []Check{}
+	}
+
+	if bundle != nil && bundle.TlogEntry != nil {
+		entry := bundle.TlogEntry
+		report.Rekor = &RekorEntry{
+			UUID:           entry.UUID,
+			LogIndex:       entry.LogIndex,
+			IntegratedTime: entry.IntegratedTime,
+			LogID:          entry.LogID,
+			Verified:       result.RekorVerified,
+			CreatedAt:      time.Unix(entry.IntegratedTime, 0).UTC(),
+		}
+	}
+
+	if !result.Valid {
+		report.Failure = &ReportFailure{
+			Code:        result.ErrorCode,
+			Message:     result.ErrorMessage,
+			Localized:   result.Message,
+			Remediation: result.Remediation,
+		}
+	}
+	return report
+}
+
+// Localize renders the failure and policy violation messages in the locale.
+// The report's copies are localized, so results shared with a cache or other
+// readers are left untouched.
+func (r *Report) Localize(catalog *messages.Catalog, locale string) {
+	if r.Failure != nil && r.Failure.Localized != nil {
+		localized := *r.Failure.Localized
+		catalog.Localize(locale, &localized)
+		r.Failure.Localized = &localized
+	}
+	if len(r.Policy) > 0 {
+		policy := make([]PolicyResult, len(r.Policy))
+		copy(policy, r.Policy)
+		for i := range policy {
+			if policy[i].Message != nil {
+				localized := *policy[i].Message
+				catalog.Localize(locale, &localized)
+				policy[i].Message = &localized
+			}
+		}
+		r.Policy = policy
+	}
+}
+
+// JSON encodes the report as indented JSON
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// checkTitles are the headings checks are shown under in Markdown
+var checkTitles = map[string]string{
+	CheckBundle:           "Bundle structure",
+	CheckBlobDigest:       "Blob digest",
+	CheckSubjectDigest:    "Subject digest",
+	CheckCertificateChain: "Certificate chain",
+	CheckTimestamps:       "RFC 3161 timestamps",
+	CheckTransparencyLog:  "Transparency log",
+	CheckSignature:        "Envelope signature",
+	CheckCanonicalPayload: "Canonical payload",
+	CheckIdentityPolicy:   "Identity policy",
+	CheckThreshold:        "Signature threshold",
+	CheckIdentityPin:      "Pinned identity",
+	CheckSignedBefore:     "Signed before",
+	CheckBuildLevel:       "SLSA build level",
+}
+
+// statusLabels prefix each status with a symbol that reads at a glance in PRs
+var statusLabels = map[CheckStatus]string{
+	CheckPassed:  "✅ passed",
+	CheckFailed:  "❌ failed",
+	CheckSkipped: "➖ skipped",
+}
+
+// Markdown renders the report for a pull request comment or job summary
+func (r *Report) Markdown() string {
+	var buf bytes.Buffer
+	r.WriteMarkdown(&buf)
+	return buf.String()
+}
+
+// WriteMarkdown writes the report as Markdown
+func (r *Report) WriteMarkdown(w io.Writer) {
+	subject := r.Subject
+	if subject == "" {
+		subject = "attestation"
+	}
+	if r.Valid {
+		fmt.Fprintf(w, "## ✅ Verification passed: `%s`\n\n", subject)
+	} else {
+		fmt.Fprintf(w, "## ❌ Verification failed: `%s`\n\n", subject)
+	}
+	fmt.Fprintf(w, "Verified at %s.\n", r.VerifiedAt.UTC().Format(time.RFC3339))
+
+	if r.Failure != nil {
+		message := r.Failure.Message
+		if r.Failure.Localized != nil && r.Failure.Localized.Text != "" {
+			message = r.Failure.Localized.Text
+		}
+		fmt.Fprintf(w, "\n**%s**: %s\n", r.Failure.Code, markdownEscape(message))
+	}
+
+	fmt.Fprint(w, "\n### Checks\n\n| Check | Result | Details |\n| --- | --- | --- |\n")
+	for _, check := range r.Checks {
+		title := checkTitles[check.Name]
+		if title == "" {
+			title = check.Name
+		}
+		detail := markdownEscape(check.Detail)
+		if check.ErrorCode != "" {
+			detail = fmt.Sprintf("`%s` %s", check.ErrorCode, detail)
+		}
+		fmt.Fprintf(w, "| %s | %s | %s |\n", title, statusLabels[check.Status], detail)
+	}
+
+	if r.Certificate != nil {
+		fmt.Fprint(w, "\n### Certificate identity\n\n| Field | Value |\n| --- | --- |\n")
+		for _, field := range [][2]string{
+			{"Subject alternative name", r.Certificate.SAN},
+			{"Issuer", r.Certificate.Issuer},
+			{"Repository", r.Certificate.Repository},
+			{"Workflow", r.Certificate.WorkflowRef},
+			{"Ref", r.Certificate.Ref},
+		} {
+			if field[1] != "" {
+				fmt.Fprintf(w, "| %s | `%s` |\n", field[0], markdownEscape(field[1]))
+			}
+		}
+	}
+
+	if len(r.Signers) > 0 {
+		fmt.Fprintf(w, "\n### Signers\n\n%s\n", markdownEscape(strings.Join(r.Signers, ", ")))
+	}
+
+	if len(r.Policy) > 0 {
+		fmt.Fprint(w, "\n### Policy\n\n| Rule | Expected | Actual | Result |\n| --- | --- | --- | --- |\n")
+		for _, result := range r.Policy {
+			status := CheckPassed
+			if !result.Passed {
+				status = CheckFailed
+			}
+			fmt.Fprintf(w, "| %s | `%s` | `%s` | %s |\n", result.Rule,
+				markdownEscape(result.Expected), markdownEscape(result.Actual), statusLabels[status])
+		}
+		for _, result := range r.Policy {
+			if result.Message != nil && result.Message.Text != "" {
+				fmt.Fprintf(w, "\n> %s\n", markdownEscape(result.Message.Text))
+			}
+		}
+	}
+
+	if r.BuildLevel != nil {
+		fmt.Fprintf(w, "\n### SLSA build level: %s\n\n| Level | Requirement | Result | Details |\n| --- | --- | --- | --- |\n", r.BuildLevel)
+		for _, requirement := range r.BuildLevel.Requirements {
+			status := CheckPassed
+			if !requirement.Met {
+				status = CheckFailed
+			}
+			fmt.Fprintf(w, "| L%d | %s | %s | %s |\n", requirement.Level, requirement.Requirement,
+				statusLabels[status], markdownEscape(requirement.Detail))
+		}
+	}
+
+	if r.Rekor != nil {
+		fmt.Fprint(w, "\n### Transparency log\n\n| Field | Value |\n| --- | --- |\n")
+		if r.Rekor.UUID != "" {
+			fmt.Fprintf(w, "| UUID | `%s` |\n", r.Rekor.UUID)
+		}
+		fmt.Fprintf(w, "| Log index | %d |\n", r.Rekor.LogIndex)
+		fmt.Fprintf(w, "| Integrated | %s |\n", r.Rekor.CreatedAt.Format(time.RFC3339))
+		fmt.Fprintf(w, "| Verified | %t |\n", r.Rekor.Verified)
+	}
+
+	if len(r.Conversions) > 0 {
+		fmt.Fprint(w, "\n### Format upgrades\n\n")
+		for _, conversion := range r.Conversions {
+			fmt.Fprintf(w, "- `%s` → `%s`\n", conversion.From, conversion.To)
+		}
+	}
+
+	if r.Failure != nil && len(r.Failure.Remediation) > 0 {
+		fmt.Fprint(w, "\n### Remediation\n\n")
+		for i, hint := range r.Failure.Remediation {
+			fmt.Fprintf(w, "%d. %s", i+1, markdownEscape(hint.Summary))
+			if hint.DocURL != "" {
+				fmt.Fprintf(w, " ([docs](%s))", hint.DocURL)
+			}
+			fmt.Fprintln(w)
+			if hint.Command != "" {
+				fmt.Fprintf(w, "   ```sh\n   %s\n   ```\n", strings.ReplaceAll(hint.Command, "\n", "\n   "))
+			}
+		}
+	}
+}
+
+// markdownEscape keeps a value on one table row
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "`", "'").Replace(s)
+}
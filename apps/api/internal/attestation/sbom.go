@@ -0,0 +1,136 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+// DSSE payload types of standalone SBOM documents
+const (
+	PayloadTypeCycloneDX = "application/vnd.cyclonedx+json"
+	PayloadTypeSPDX      = "application/spdx+json"
+)
+
+// SBOMEnvelopeSuffix is appended to an SBOM's path to name its detached
+// envelope, e.g. sbom.cdx.json.dsse.json
+const SBOMEnvelopeSuffix = ".dsse.json"
+
+// SBOMEnvelopePath returns where the detached envelope for an SBOM file is written
+func SBOMEnvelopePath(path string) string {
+	return path + SBOMEnvelopeSuffix
+}
+
+// sbomPayloadTypes maps each SBOM format to its payload type
+var sbomPayloadTypes = map[sbom.Format]string{
+	sbom.FormatCycloneDX: PayloadTypeCycloneDX,
+	sbom.FormatSPDX:      PayloadTypeSPDX,
+}
+
+// SignSBOM signs a CycloneDX or SPDX JSON document as it is, in a DSSE
+// envelope typed by the SBOM format rather than wrapped in an in-toto
+// statement, for consumers that expect the SBOM file itself to be signed.
+// The document bytes are not re-encoded, so the envelope payload is exactly
+// the file that was published.
+func SignSBOM(ctx context.Context, document []byte, signer Signer) (*Envelope, error) {
+	decoded, err := sbom.Decode(document)
+	if err != nil {
+		return nil, Wrap(CodeSBOMSigningFailed, err, "Document is not a CycloneDX or SPDX JSON SBOM")
+	}
+
+	payloadType := sbomPayloadTypes[decoded.Format]
+	digest := sha256.Sum256(PAE(payloadType, document))
+	sig, err := signer.SignDigest(ctx, digest[:])
+	if err != nil {
+		return nil, wrapUncoded(CodeSBOMSigningFailed, err, "Failed to sign %s SBOM with %s key %s", decoded.Format, signer.Backend(), signer.KeyID())
+	}
+
+	return &Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(document),
+		Signatures: []EnvelopeSignature{{
+			KeyID: signer.KeyID(),
+			Sig:   base64.StdEncoding.EncodeToString(sig),
+		}},
+	}, nil
+}
+
+// VerifySBOM checks that the envelope carries an SBOM of the format its
+// payload type names and was signed by key, returning the decoded document
+func VerifySBOM(envelope *Envelope, key crypto.PublicKey) (*sbom.Document, error) {
+	if envelope == nil {
+		return nil, Errorf(CodeVerificationFailed, "No SBOM envelope to verify")
+	}
+	payload, err := envelope.DecodePayload()
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := sbom.Decode(payload)
+	if err != nil {
+		return nil, Wrap(CodeVerificationFailed, err, "Envelope payload is not a CycloneDX or SPDX JSON SBOM")
+	}
+	if expected := sbomPayloadTypes[decoded.Format]; envelope.PayloadType != expected {
+		return nil, Errorf(CodeVerificationFailed, "Envelope payload type %q does not match its %s document; expected %q",
+			envelope.PayloadType, decoded.Format, expected)
+	}
+
+	if err := VerifyEnvelopeKey(envelope, key); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// SignSBOMFile signs the SBOM at path and writes the envelope beside it at
+// SBOMEnvelopePath
+func SignSBOMFile(ctx context.Context, path string, signer Signer) (*Envelope, error) {
+	document, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Wrap(CodeSBOMSigningFailed, err, "Failed to read SBOM %s", path)
+	}
+	envelope, err := SignSBOM(ctx, document, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return nil, Wrap(CodeSBOMSigningFailed, err, "Failed to encode SBOM envelope")
+	}
+	if err := os.WriteFile(SBOMEnvelopePath(path), data, 0o644); err != nil {
+		return nil, Wrap(CodeSBOMSigningFailed, err, "Failed to write SBOM envelope")
+	}
+	return envelope, nil
+}
+
+// VerifySBOMFile verifies the SBOM at path against its detached envelope,
+// which must sign exactly the file's contents
+func VerifySBOMFile(path string, key crypto.PublicKey) (*sbom.Document, error) {
+	document, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Wrap(CodeVerificationFailed, err, "Failed to read SBOM %s", path)
+	}
+	data, err := os.ReadFile(SBOMEnvelopePath(path))
+	if err != nil {
+		return nil, Wrap(CodeAttestationNotFound, err, "No signed envelope found for %s", path)
+	}
+	envelope, err := ParseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := envelope.DecodePayload()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(payload, document) {
+		return nil, Errorf(CodeBlobDigestMismatch, "SBOM %s differs from the signed document", path)
+	}
+	return VerifySBOM(envelope, key)
+}
@@ -0,0 +1,112 @@
+package attestation
+
+import "strings"
+
+// PredicateSCAIV02 is the in-toto Software Supply Chain Attribute Integrity
+// (SCAI) attribute report predicate type
+const PredicateSCAIV02 = "https://in-toto.io/attestation/scai/attribute-report/v0.2"
+
+// Build attributes asserted in SCAI attribute reports
+const (
+	AttributeHardenedBuild = "HARDENED_BUILD" // Compiled with hardening flags, listed in the conditions
+	AttributeReproducible  = "REPRODUCIBLE"   // An independent rebuild produced identical artifacts
+)
+
+// PredicateGenerator builds an in-toto statement of one predicate type about
+// the subjects, e.g. SLSA provenance or a SCAI attribute report
+type PredicateGenerator interface {
+	Build(subjects []Subject, build BuildContext) (*Statement, error)
+}
+
+// SCAIAttributeReport is the SCAI v0.2 predicate
+type SCAIAttributeReport struct {
+	Attributes []SCAIAttribute     `json:"attributes"`
+	Producer   *ResourceDescriptor `json:"producer,omitempty"`
+}
+
+// SCAIAttribute asserts a property of the subjects, or of Target when set,
+// under the given conditions and backed by optional evidence
+type SCAIAttribute struct {
+	Attribute  string                 `json:"attribute"`
+	Target     *ResourceDescriptor    `json:"target,omitempty"`
+	Conditions map[string]interface{} `json:"conditions,omitempty"`
+	Evidence   *ResourceDescriptor    `json:"evidence,omitempty"`
+}
+
+// HardenedBuildAttribute asserts the subjects were compiled with the given
+// hardening flags, e.g. -fstack-protector-strong or -D_FORTIFY_SOURCE=3
+func HardenedBuildAttribute(flags ...string) SCAIAttribute {
+	return SCAIAttribute{
+		Attribute:  AttributeHardenedBuild,
+		Conditions: map[string]interface{}{"flags": flags},
+	}
+}
+
+// ReproducibleAttribute asserts an independent rebuild produced the subjects
+// bit for bit; evidence references the rebuild's log or attestation
+func ReproducibleAttribute(evidence ResourceDescriptor) SCAIAttribute {
+	return SCAIAttribute{
+		Attribute: AttributeReproducible,
+		Evidence:  &evidence,
+	}
+}
+
+// SCAIOption configures a SCAIBuilder
+type SCAIOption func(*SCAIBuilder)
+
+// WithAttributes adds attribute assertions to the report
+func WithAttributes(attributes ...SCAIAttribute) SCAIOption {
+	return func(b *SCAIBuilder) {
+		b.attributes = append(b.attributes, attributes...)
+	}
+}
+
+// SCAIBuilder produces SCAI attribute report statements. The build context's
+// builder is recorded as the producer, so reports sit alongside the provenance
+// of the same run.
+type SCAIBuilder struct {
+	attributes []SCAIAttribute
+}
+
+// NewSCAIBuilder creates a SCAI attribute report builder
+func NewSCAIBuilder(opts ...SCAIOption) *SCAIBuilder {
+	b := &SCAIBuilder{}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Build validates the subjects, build context and attributes and returns an
+// attribute report statement
+func (b *SCAIBuilder) Build(subjects []Subject, build BuildContext) (*Statement, error) {
+	if err := validateSubjects(subjects); err != nil {
+		return nil, err
+	}
+	if err := build.validate(); err != nil {
+		return nil, err
+	}
+	if len(b.attributes) == 0 {
+		return nil, Errorf(CodeSigningFailed, "SCAI attribute report has no attributes")
+	}
+	for _, attribute := range b.attributes {
+		if strings.TrimSpace(attribute.Attribute) == "" {
+			return nil, Errorf(CodeSigningFailed, "SCAI attribute has no name")
+		}
+		for _, descriptor := range []*ResourceDescriptor{attribute.Target, attribute.Evidence} {
+			if descriptor != nil && descriptor.URI == "" && len(descriptor.Digest) == 0 {
+				return nil, Errorf(CodeSigningFailed, "SCAI attribute %s references a resource with no URI or digest", attribute.Attribute)
+			}
+		}
+	}
+
+	return &Statement{
+		Type:          StatementTypeV1,
+		Subject:       subjects,
+		PredicateType: PredicateSCAIV02,
+		Predicate: SCAIAttributeReport{
+			Attributes: b.attributes,
+			Producer:   &ResourceDescriptor{URI: build.builderID()},
+		},
+	}, nil
+}
@@ -0,0 +1,152 @@
+package attestation
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// Signing metadata annotations describing the signing backend
+const (
+	AnnotationSigningBackend = "keystone.signing.backend"
+	AnnotationSigningKeyID   = "keystone.signing.key_id"
+	AnnotationTlogUpload     = "keystone.signing.tlog_upload" // "skipped" when no transparency log entry was created
+)
+
+// Signer signs SHA-256 digests with a key that is either ephemeral (keyless),
+// held by a key management service, or a static key pair
+type Signer interface {
+	// Backend names the signing backend, e.g. "keyless" or "awskms"
+	Backend() string
+	// KeyID identifies the signing key, e.g. a KMS key reference
+	KeyID() string
+	// PublicKey returns the public half of the signing key
+	PublicKey(ctx context.Context) (crypto.PublicKey, error)
+	// SignDigest signs a SHA-256 digest
+	SignDigest(ctx context.Context, digest []byte) ([]byte, error)
+}
+
+// CryptoSigner adapts an in-process crypto.Signer, such as an ephemeral keyless key
+type CryptoSigner struct {
+	Signer crypto.Signer
+	ID     string
+}
+
+// Backend reports keyless signing
+func (s *CryptoSigner) Backend() string {
+	return "keyless"
+}
+
+// KeyID returns the configured key ID
+func (s *CryptoSigner) KeyID() string {
+	return s.ID
+}
+
+// PublicKey returns the signer's public key
+func (s *CryptoSigner) PublicKey(context.Context) (crypto.PublicKey, error) {
+	return s.Signer.Public(), nil
+}
+
+// SignDigest signs a SHA-256 digest
+func (s *CryptoSigner) SignDigest(_ context.Context, digest []byte) ([]byte, error) {
+	return s.Signer.Sign(rand.Reader, digest, crypto.SHA256)
+}
+
+// SignEnvelope signs the envelope's PAE encoding and appends the signature
+func SignEnvelope(ctx context.Context, envelope *Envelope, signer Signer) error {
+	payload, err := envelope.DecodePayload()
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(PAE(envelope.PayloadType, payload))
+	sig, err := signer.SignDigest(ctx, digest[:])
+	if err != nil {
+		return wrapUncoded(CodeSigningFailed, err, "Failed to sign envelope with %s key %s", signer.Backend(), signer.KeyID())
+	}
+
+	envelope.Signatures = append(envelope.Signatures, EnvelopeSignature{
+		KeyID: signer.KeyID(),
+		Sig:   base64.StdEncoding.EncodeToString(sig),
+	})
+	return nil
+}
+
+// NewKeySignedRecord signs the envelope with a managed key and returns the same
+// record shape as keyless signing; Certificate carries the PEM public key
+// since there is no Fulcio certificate
+func NewKeySignedRecord(ctx context.Context, target string, envelope *Envelope, signer Signer, metadata SigningMetadata) (*AttestationRecord, error) {
+	statement, err := envelope.Statement()
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := signer.PublicKey(ctx)
+	if err != nil {
+		return nil, wrapUncoded(CodePublicKeyExtraction, err, "Failed to fetch public key for %s", signer.KeyID())
+	}
+	encoded, err := encodePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := SignEnvelope(ctx, envelope, signer); err != nil {
+		return nil, err
+	}
+
+	if metadata.Annotations == nil {
+		metadata.Annotations = make(map[string]string)
+	}
+	metadata.Annotations[AnnotationSigningBackend] = signer.Backend()
+	metadata.Annotations[AnnotationSigningKeyID] = signer.KeyID()
+	if metadata.Identity == "" {
+		metadata.Identity = signer.KeyID()
+	}
+	if metadata.Timestamp.IsZero() {
+		metadata.Timestamp = time.Now().UTC()
+	}
+
+	signature := envelope.Signatures[len(envelope.Signatures)-1].Sig
+	return &AttestationRecord{
+		ID:          RecordID(signature),
+		Type:        statement.PredicateType,
+		Target:      target,
+		Signature:   signature,
+		Certificate: encoded,
+		Metadata:    metadata,
+	}, nil
+}
+
+// RecordID identifies an attestation by the SHA-256 of its base64 signature
+func RecordID(signature string) string {
+	id := sha256.Sum256([]byte(signature))
+	return hex.EncodeToString(id[:])
+}
+
+// VerifyEnvelopeKey checks the envelope was signed by the given public key
+func VerifyEnvelopeKey(envelope *Envelope, key crypto.PublicKey) error {
+	payload, err := envelope.DecodePayload()
+	if err != nil {
+		return err
+	}
+	if len(envelope.Signatures) == 0 {
+		return Errorf(CodeVerificationFailed, "Envelope is not signed")
+	}
+
+	message := PAE(envelope.PayloadType, payload)
+	digest := sha256.Sum256(message)
+	for _, signature := range envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(signature.Sig)
+		if err != nil {
+			continue
+		}
+		if verifyWithKey(key, digest[:], message, sig) {
+			return nil
+		}
+	}
+	return Errorf(CodeVerificationFailed, "No envelope signature was made by the signing key")
+}
@@ -0,0 +1,162 @@
+package attestation
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Sigstore environments
+const (
+	SigstoreProduction = "production"
+	SigstoreStaging    = "staging"
+	SigstoreCustom     = "custom" // A self-hosted instance; every endpoint must be configured
+)
+
+// GitHubActionsIssuer is the OIDC issuer of GitHub Actions workflow tokens
+const GitHubActionsIssuer = "https://token.actions.githubusercontent.com"
+
+// SigstoreConfig holds the endpoints of the Sigstore instance certificates are
+// issued by and signatures are logged to
+type SigstoreConfig struct {
+	Environment string
+	FulcioURL   string // Certificate authority
+	RekorURL    string // Transparency log
+	OIDCIssuer  string // Issuer of the identity tokens Fulcio accepts and verification expects
+	OIDCProfile string // Issuer profile mapping the tokens' claims, see LookupIssuerProfile
+	TUFMirror   string // TUF repository distributing the instance's trust root
+}
+
+// SigstoreEnvironment returns the endpoints of a public Sigstore environment
+func SigstoreEnvironment(name string) (SigstoreConfig, error) {
+	switch name {
+	case "", SigstoreProduction:
+		return SigstoreConfig{
+			Environment: SigstoreProduction,
+			FulcioURL:   "https://fulcio.sigstore.dev",
+			RekorURL:    "https://rekor.sigstore.dev",
+			OIDCIssuer:  GitHubActionsIssuer,
+			OIDCProfile: IssuerProfileGitHub,
+			TUFMirror:   "https://tuf-repo-cdn.sigstore.dev",
+		}, nil
+	case SigstoreStaging:
+		return SigstoreConfig{
+			Environment: SigstoreStaging,
+			FulcioURL:   "https://fulcio.sigstage.dev",
+			RekorURL:    "https://rekor.sigstage.dev",
+			OIDCIssuer:  GitHubActionsIssuer,
+			OIDCProfile: IssuerProfileGitHub,
+			TUFMirror:   "https://tuf-repo-cdn.sigstage.dev",
+		}, nil
+	case SigstoreCustom:
+		return SigstoreConfig{Environment: SigstoreCustom, OIDCProfile: IssuerProfileGitHub}, nil
+	default:
+		return SigstoreConfig{}, fmt.Errorf("unknown Sigstore environment %q; use %s, %s or %s", name, SigstoreProduction, SigstoreStaging, SigstoreCustom)
+	}
+}
+
+// SigstoreConfigFromEnv reads SIGSTORE_ENV and overrides its endpoints with
+// SIGSTORE_FULCIO_URL, SIGSTORE_REKOR_URL, SIGSTORE_OIDC_ISSUER and SIGSTORE_TUF_MIRROR.
+// SIGSTORE_OIDC_PROFILE selects the CI platform whose tokens are expected,
+// which sets the default issuer; SIGSTORE_OIDC_ISSUER still overrides it for
+// self-managed instances such as a GitLab server.
+func SigstoreConfigFromEnv() (SigstoreConfig, error) {
+	config, err := SigstoreEnvironment(os.Getenv("SIGSTORE_ENV"))
+	if err != nil {
+		return SigstoreConfig{}, err
+	}
+
+	if name := os.Getenv("SIGSTORE_OIDC_PROFILE"); name != "" {
+		profile, err := LookupIssuerProfile(name)
+		if err != nil {
+			return SigstoreConfig{}, err
+		}
+		config.OIDCProfile = profile.Name
+		if config.Environment != SigstoreCustom {
+			config.OIDCIssuer = profile.Issuer
+		}
+	}
+
+	overrides := map[string]*string{
+		"SIGSTORE_FULCIO_URL":  &config.FulcioURL,
+		"SIGSTORE_REKOR_URL":   &config.RekorURL,
+		"SIGSTORE_OIDC_ISSUER": &config.OIDCIssuer,
+		"SIGSTORE_TUF_MIRROR":  &config.TUFMirror,
+	}
+	for name, field := range overrides {
+		if value := os.Getenv(name); value != "" {
+			*field = strings.TrimSuffix(value, "/")
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return SigstoreConfig{}, err
+	}
+	return config, nil
+}
+
+// Validate checks that every configured endpoint is an absolute URL; a custom
+// instance must configure Fulcio, Rekor and the OIDC issuer, while its trust
+// root may be pinned instead of distributed through TUF
+func (c SigstoreConfig) Validate() error {
+	endpoints := []struct {
+		name     string
+		value    string
+		required bool
+	}{
+		{"Fulcio URL", c.FulcioURL, true},
+		{"Rekor URL", c.RekorURL, true},
+		{"OIDC issuer", c.OIDCIssuer, true},
+		{"TUF mirror", c.TUFMirror, false},
+	}
+
+	if _, err := LookupIssuerProfile(c.OIDCProfile); err != nil {
+		return err
+	}
+	if strings.Contains(c.OIDCIssuer, "*") {
+		// Tokens and certificates name one concrete issuer, e.g. a CircleCI organization's
+		return fmt.Errorf("Sigstore OIDC issuer %q is a pattern; set SIGSTORE_OIDC_ISSUER to the issuer of your tokens", c.OIDCIssuer)
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.value == "" {
+			if endpoint.required {
+				return fmt.Errorf("Sigstore %s is not configured for the %s environment", endpoint.name, c.Environment)
+			}
+			continue
+		}
+		parsed, err := url.Parse(endpoint.value)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return fmt.Errorf("Sigstore %s %q is not an absolute http(s) URL", endpoint.name, endpoint.value)
+		}
+	}
+	return nil
+}
+
+// FulcioConfigurationURL is the Fulcio endpoint probed for availability
+func (c SigstoreConfig) FulcioConfigurationURL() string {
+	return strings.TrimSuffix(c.FulcioURL, "/") + "/api/v2/configuration"
+}
+
+// ApplyIssuer returns the policy with the environment's OIDC issuer expected
+// when the policy doesn't name one
+func (c SigstoreConfig) ApplyIssuer(policy IdentityPolicy) IdentityPolicy {
+	if policy.Issuer == "" {
+		policy.Issuer = c.OIDCIssuer
+	}
+	return policy
+}
+
+// IssuerProfile returns the configured issuer profile, expecting tokens from
+// the configured OIDC issuer
+func (c SigstoreConfig) IssuerProfile() (IssuerProfile, error) {
+	profile, err := LookupIssuerProfile(c.OIDCProfile)
+	if err != nil {
+		return IssuerProfile{}, err
+	}
+	if c.OIDCIssuer != "" {
+		profile.Issuer = c.OIDCIssuer
+	}
+	return profile, nil
+}
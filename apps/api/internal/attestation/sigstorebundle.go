@@ -0,0 +1,143 @@
+package attestation
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SigstoreBundleMediaType prefixes the media types of Sigstore bundles, which
+// carry a version suffix such as ";version=0.2"
+const SigstoreBundleMediaType = "application/vnd.dev.sigstore.bundle"
+
+// SigstoreBundle is the protobuf JSON form of a Sigstore bundle, as published
+// by the npm registry and produced by cosign and sigstore-js
+type SigstoreBundle struct {
+	MediaType            string                       `json:"mediaType"`
+	VerificationMaterial SigstoreVerificationMaterial `json:"verificationMaterial"`
+	DSSEEnvelope         *Envelope                    `json:"dsseEnvelope"`
+}
+
+// SigstoreVerificationMaterial holds the signing certificate and log entries.
+// Bundles before v0.3 carry a chain, later ones only the leaf.
+type SigstoreVerificationMaterial struct {
+	X509CertificateChain *SigstoreCertificateChain `json:"x509CertificateChain,omitempty"`
+	Certificate          *SigstoreCertificate      `json:"certificate,omitempty"`
+	TlogEntries          []SigstoreTlogEntry       `json:"tlogEntries"`
+}
+
+// SigstoreCertificateChain is a certificate chain, leaf first
+type SigstoreCertificateChain struct {
+	Certificates []SigstoreCertificate `json:"certificates"`
+}
+
+// SigstoreCertificate is a base64 DER certificate
+type SigstoreCertificate struct {
+	RawBytes string `json:"rawBytes"`
+}
+
+// SigstoreTlogEntry is a Rekor entry in protobuf JSON form, where 64-bit
+// integers are encoded as strings and the log ID as base64
+type SigstoreTlogEntry struct {
+	LogIndex          string            `json:"logIndex"`
+	LogID             SigstoreLogID     `json:"logId"`
+	IntegratedTime    string            `json:"integratedTime"`
+	InclusionPromise  *SigstorePromise  `json:"inclusionPromise,omitempty"`
+	KindVersion       map[string]string `json:"kindVersion,omitempty"`
+	CanonicalizedBody string            `json:"canonicalizedBody"`
+}
+
+// SigstoreLogID identifies the log by the base64 SHA-256 of its public key
+type SigstoreLogID struct {
+	KeyID string `json:"keyId"`
+}
+
+// SigstorePromise is the log's signed entry timestamp
+type SigstorePromise struct {
+	SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+}
+
+// ParseSigstoreBundle decodes a JSON Sigstore bundle carrying a DSSE envelope
+func ParseSigstoreBundle(data []byte) (*SigstoreBundle, error) {
+	var bundle SigstoreBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, Wrap(CodeVerificationFailed, err, "Sigstore bundle is not valid JSON")
+	}
+	if !strings.HasPrefix(bundle.MediaType, SigstoreBundleMediaType) {
+		return nil, Errorf(CodeVerificationFailed, "Unsupported Sigstore bundle media type %q", bundle.MediaType)
+	}
+	if bundle.DSSEEnvelope == nil {
+		return nil, Errorf(CodeVerificationFailed, "Sigstore bundle does not carry a DSSE envelope")
+	}
+	return &bundle, nil
+}
+
+// Bundle converts the Sigstore bundle into an offline verification bundle
+// checked against the given trust root. Only the first log entry is kept, and
+// it must carry a signed entry timestamp since inclusion proofs aren't checked.
+func (b *SigstoreBundle) Bundle(trust TrustRoot) (*Bundle, error) {
+	material := b.VerificationMaterial
+	certificates := material.X509CertificateChain
+	if material.Certificate != nil {
+		certificates = &SigstoreCertificateChain{Certificates: []SigstoreCertificate{*material.Certificate}}
+	}
+	if certificates == nil || len(certificates.Certificates) == 0 {
+		return nil, Errorf(CodePublicKeyExtraction, "Sigstore bundle has no signing certificate")
+	}
+	if len(material.TlogEntries) == 0 {
+		return nil, Errorf(CodeRekorEntryNotFound, "Sigstore bundle has no transparency log entry")
+	}
+
+	entry, err := material.TlogEntries[0].TlogEntry()
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &Bundle{
+		MediaType: BundleMediaType,
+		Envelope:  b.DSSEEnvelope,
+		TlogEntry: entry,
+		TrustRoot: trust,
+		CreatedAt: time.Now().UTC(),
+	}
+	for i, certificate := range certificates.Certificates {
+		der, err := base64.StdEncoding.DecodeString(certificate.RawBytes)
+		if err != nil {
+			return nil, Wrap(CodePublicKeyExtraction, err, "Sigstore bundle certificate %d is not valid base64", i)
+		}
+		bundle.CertificateChain = append(bundle.CertificateChain,
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	}
+	return bundle, nil
+}
+
+// TlogEntry converts the entry into the form its signed entry timestamp covers
+func (e SigstoreTlogEntry) TlogEntry() (*TlogEntry, error) {
+	if e.InclusionPromise == nil || e.InclusionPromise.SignedEntryTimestamp == "" {
+		return nil, Errorf(CodeRekorSETInvalid, "Transparency log entry has no signed entry timestamp")
+	}
+	logIndex, err := strconv.ParseInt(e.LogIndex, 10, 64)
+	if err != nil {
+		return nil, Wrap(CodeRekorSETInvalid, err, "Transparency log entry index %q is invalid", e.LogIndex)
+	}
+	integratedTime, err := strconv.ParseInt(e.IntegratedTime, 10, 64)
+	if err != nil {
+		return nil, Wrap(CodeRekorSETInvalid, err, "Transparency log entry time %q is invalid", e.IntegratedTime)
+	}
+	logID, err := base64.StdEncoding.DecodeString(e.LogID.KeyID)
+	if err != nil {
+		return nil, Wrap(CodeRekorSETInvalid, err, "Transparency log ID is not valid base64")
+	}
+
+	return &TlogEntry{
+		LogIndex:             logIndex,
+		LogID:                hex.EncodeToString(logID),
+		IntegratedTime:       integratedTime,
+		Body:                 e.CanonicalizedBody,
+		SignedEntryTimestamp: e.InclusionPromise.SignedEntryTimestamp,
+	}, nil
+}
@@ -0,0 +1,103 @@
+package attestation
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// in-toto statement types
+const (
+	StatementTypeV1  = "https://in-toto.io/Statement/v1"
+	StatementTypeV01 = "https://in-toto.io/Statement/v0.1"
+)
+
+// Predicate types of documents produced by other tools and attested as-is
+const (
+	PredicateCycloneDX = "https://cyclonedx.org/bom"
+	PredicateSPDX      = "https://spdx.dev/Document"
+	PredicateVulnsV01  = "https://in-toto.io/attestation/vulns/v0.1"
+)
+
+// DigestSet maps digest algorithm names to hex encoded digests
+type DigestSet map[string]string
+
+// Subject identifies an artifact an attestation is about
+type Subject struct {
+	Name   string    `json:"name"`
+	Digest DigestSet `json:"digest"`
+}
+
+// Statement is an in-toto attestation statement
+type Statement struct {
+	Type          string      `json:"_type"`
+	Subject       []Subject   `json:"subject"`
+	PredicateType string      `json:"predicateType"`
+	Predicate     interface{} `json:"predicate"`
+}
+
+// digestLengths holds the expected hex length for supported digest algorithms
+var digestLengths = map[string]int{
+	"sha256": 64,
+	"sha384": 96,
+	"sha512": 128,
+	"sha1":   40,
+}
+
+// NewSubject creates a subject from a name and an "alg:hex" digest string
+func NewSubject(name, digest string) (Subject, error) {
+	alg, value, found := strings.Cut(digest, ":")
+	if !found {
+		return Subject{}, Errorf(CodeTargetNotResolved, "Digest %q for %s is not in alg:hex form", digest, name)
+	}
+
+	subject := Subject{Name: name, Digest: DigestSet{strings.ToLower(alg): strings.ToLower(value)}}
+	if err := subject.Validate(); err != nil {
+		return Subject{}, err
+	}
+	return subject, nil
+}
+
+// Validate checks that the subject carries at least one well-formed digest
+func (s Subject) Validate() error {
+	if s.Name == "" {
+		return Errorf(CodeTargetNotResolved, "Subject name is empty")
+	}
+	if len(s.Digest) == 0 {
+		return Errorf(CodeTargetNotResolved, "Subject %s has no digest", s.Name)
+	}
+
+	for alg, value := range s.Digest {
+		expected, known := digestLengths[alg]
+		if !known {
+			return Errorf(CodeTargetNotResolved, "Subject %s uses unsupported digest algorithm %s", s.Name, alg)
+		}
+		if _, err := hex.DecodeString(value); err != nil || len(value) != expected {
+			return Errorf(CodeTargetNotResolved, "Subject %s has malformed %s digest", s.Name, alg)
+		}
+	}
+	return nil
+}
+
+// String renders the subject as name@alg:digest using its strongest digest
+func (s Subject) String() string {
+	for _, alg := range []string{"sha512", "sha384", "sha256", "sha1"} {
+		if value, ok := s.Digest[alg]; ok {
+			return fmt.Sprintf("%s@%s:%s", s.Name, alg, value)
+		}
+	}
+	return s.Name
+}
+
+// validateSubjects checks a subject list shared by every predicate generator
+func validateSubjects(subjects []Subject) error {
+	if len(subjects) == 0 {
+		return Errorf(CodeTargetNotResolved, "At least one subject is required")
+	}
+	for _, subject := range subjects {
+		if err := subject.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
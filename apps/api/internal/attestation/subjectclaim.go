@@ -0,0 +1,122 @@
+package attestation
+
+import (
+	"strings"
+)
+
+// SubjectKind is what a GitHub Actions subject claim identifies the job by,
+// after its repository
+type SubjectKind string
+
+const (
+	SubjectRef         SubjectKind = "ref"          // repo:owner/name:ref:refs/heads/main
+	SubjectEnvironment SubjectKind = "environment"  // repo:owner/name:environment:production
+	SubjectPullRequest SubjectKind = "pull_request" // repo:owner/name:pull_request
+	SubjectCustom      SubjectKind = "custom"       // A repository's customized template, e.g. repo:owner/name:context:prod
+)
+
+// SubjectClaim is a decomposed GitHub Actions sub claim. The default subject
+// is the repository followed by the environment, the pull request marker or
+// the ref; repositories can customize it to a list of claim:value pairs.
+type SubjectClaim struct {
+	Raw         string            `json:"raw"`
+	Repository  string            `json:"repository,omitempty"` // owner/name
+	Kind        SubjectKind       `json:"kind"`
+	Ref         string            `json:"ref,omitempty"`         // Full ref, e.g. refs/tags/v1.2.0
+	Environment string            `json:"environment,omitempty"` // Deployment environment
+	Fields      map[string]string `json:"fields,omitempty"`      // Every claim:value pair, customized ones included
+}
+
+// ParseSubjectClaim decomposes a GitHub Actions sub claim. Git refs can't
+// contain colons, so each pair splits cleanly; only pull_request stands
+// alone.
+func ParseSubjectClaim(sub string) (*SubjectClaim, error) {
+	if sub == "" {
+		return nil, Errorf(CodeMissingSubject, "OIDC token has no subject claim")
+	}
+
+	subject := &SubjectClaim{Raw: sub, Fields: make(map[string]string)}
+	parts := strings.Split(sub, ":")
+	var keys []string
+	for i := 0; i < len(parts); i++ {
+		key := parts[i]
+		if key == string(SubjectPullRequest) {
+			subject.Fields[key] = ""
+			keys = append(keys, key)
+			continue
+		}
+		if key == "" || i+1 >= len(parts) {
+			return nil, Errorf(CodeMissingSubject, "OIDC subject claim %q isn't a list of claim:value pairs", sub)
+		}
+		if _, duplicate := subject.Fields[key]; duplicate {
+			return nil, Errorf(CodeMissingSubject, "OIDC subject claim %q repeats %s", sub, key)
+		}
+		i++
+		subject.Fields[key] = parts[i]
+		keys = append(keys, key)
+	}
+
+	subject.Repository = subject.Fields["repo"]
+	subject.Environment = subject.Fields["environment"]
+	subject.Ref = subject.Fields["ref"]
+	if subject.Repository != "" && strings.Count(subject.Repository, "/") != 1 {
+		return nil, Errorf(CodeMissingSubject, "OIDC subject claim %q names repository %q, expected owner/name", sub, subject.Repository)
+	}
+
+	// The default templates are the repository and one of three qualifiers
+	subject.Kind = SubjectCustom
+	if len(keys) == 2 && keys[0] == "repo" {
+		switch keys[1] {
+		case "ref":
+			subject.Kind = SubjectRef
+		case "environment":
+			subject.Kind = SubjectEnvironment
+		case "pull_request":
+			subject.Kind = SubjectPullRequest
+		}
+	}
+	return subject, nil
+}
+
+// Owner returns the repository owner
+func (s *SubjectClaim) Owner() string {
+	owner, _, _ := strings.Cut(s.Repository, "/")
+	return owner
+}
+
+// Name returns the repository name without its owner
+func (s *SubjectClaim) Name() string {
+	_, name, _ := strings.Cut(s.Repository, "/")
+	return name
+}
+
+// Branch returns the branch of a ref subject
+func (s *SubjectClaim) Branch() (string, bool) {
+	return strings.CutPrefix(s.Ref, "refs/heads/")
+}
+
+// Tag returns the tag of a ref subject
+func (s *SubjectClaim) Tag() (string, bool) {
+	return strings.CutPrefix(s.Ref, "refs/tags/")
+}
+
+// PullRequest reports whether the job ran for a pull request
+func (s *SubjectClaim) PullRequest() bool {
+	_, found := s.Fields[string(SubjectPullRequest)]
+	return found
+}
+
+// String returns the claim as it appeared in the token
+func (s *SubjectClaim) String() string {
+	return s.Raw
+}
+
+// subjectClaim parses the token's sub claim, or returns nil when it has none
+// or it isn't in GitHub's format
+func subjectClaim(claims Claims) *SubjectClaim {
+	subject, err := ParseSubjectClaim(claimString(claims, "sub"))
+	if err != nil {
+		return nil
+	}
+	return subject
+}
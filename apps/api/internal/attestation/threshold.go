@@ -0,0 +1,170 @@
+package attestation
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/remediation"
+)
+
+// ThresholdPolicy requires signatures from at least Threshold of the trusted
+// signers, e.g. both the build system and a security reviewer
+type ThresholdPolicy struct {
+	Threshold int             `json:"threshold" yaml:"threshold"`
+	Signers   []TrustedSigner `json:"signers" yaml:"signers"`
+}
+
+// TrustedSigner is one party whose signature counts towards a threshold
+type TrustedSigner struct {
+	Name      string `json:"name" yaml:"name"`             // Role shown in results, e.g. "build-system"
+	PublicKey string `json:"public_key" yaml:"public_key"` // PEM PKIX public key
+}
+
+// Validate checks the threshold is reachable and the signers are distinct,
+// by name and by public key, so no signature counts twice
+func (p *ThresholdPolicy) Validate() error {
+	if p.Threshold < 1 || p.Threshold > len(p.Signers) {
+		return Errorf(CodeThresholdNotMet, "Threshold %d is not between 1 and the %d trusted signers", p.Threshold, len(p.Signers))
+	}
+
+	names := make(map[string]bool, len(p.Signers))
+	keys := make(map[[sha256.Size]byte]string, len(p.Signers)) // SPKI fingerprint -> signer name
+	for _, signer := range p.Signers {
+		if signer.Name == "" {
+			return Errorf(CodeThresholdNotMet, "Trusted signer has no name")
+		}
+		if names[signer.Name] {
+			return Errorf(CodeThresholdNotMet, "Trusted signer %s is listed twice", signer.Name)
+		}
+		names[signer.Name] = true
+
+		// Compared re-encoded, since one key has many PEM spellings
+		key, err := parsePublicKey(signer.PublicKey)
+		if err != nil {
+			return Wrap(CodePublicKeyExtraction, err, "Public key of trusted signer %s is invalid", signer.Name)
+		}
+		spki, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return Wrap(CodePublicKeyExtraction, err, "Public key of trusted signer %s is invalid", signer.Name)
+		}
+		fingerprint := sha256.Sum256(spki)
+		if other, found := keys[fingerprint]; found {
+			return Errorf(CodeThresholdNotMet, "Trusted signers %s and %s share a public key", other, signer.Name)
+		}
+		keys[fingerprint] = signer.Name
+	}
+	return nil
+}
+
+// CoSign adds a party's signature to an envelope that may already carry others.
+// Signing twice with the same key is a no-op, so retried pipeline steps don't
+// accumulate duplicate signatures.
+func CoSign(ctx context.Context, envelope *Envelope, signer Signer) error {
+	publicKey, err := signer.PublicKey(ctx)
+	if err != nil {
+		return wrapUncoded(CodePublicKeyExtraction, err, "Failed to fetch public key for %s", signer.KeyID())
+	}
+	if len(envelope.Signatures) > 0 && VerifyEnvelopeKey(envelope, publicKey) == nil {
+		return nil
+	}
+	return SignEnvelope(ctx, envelope, signer)
+}
+
+// MergeEnvelopes combines envelopes signed separately over the same payload
+// into one carrying every distinct signature
+func MergeEnvelopes(envelopes ...*Envelope) (*Envelope, error) {
+	if len(envelopes) == 0 || envelopes[0] == nil {
+		return nil, Errorf(CodeSigningFailed, "No envelopes to merge")
+	}
+
+	first := envelopes[0]
+	merged := &Envelope{
+		PayloadType: first.PayloadType,
+		Payload:     first.Payload,
+		Signatures:  []EnvelopeSignature{},
+	}
+	seen := make(map[string]bool)
+	for _, envelope := range envelopes {
+		if envelope == nil || envelope.PayloadType != first.PayloadType || envelope.Payload != first.Payload {
+			return nil, Errorf(CodeSigningFailed, "Envelopes sign different payloads and cannot be merged")
+		}
+		for _, signature := range envelope.Signatures {
+			if seen[signature.Sig] {
+				continue
+			}
+			seen[signature.Sig] = true
+			merged.Signatures = append(merged.Signatures, signature)
+		}
+	}
+	return merged, nil
+}
+
+// VerifyThreshold checks that enough distinct trusted signers signed the
+// envelope. Each signer counts once however many of its signatures are
+// present, and signatures by unknown keys are ignored. The result lists the
+// signers that were verified and is populated with remediation hints on failure.
+func VerifyThreshold(envelope *Envelope, policy ThresholdPolicy) (*VerificationResult, error) {
+	result := &VerificationResult{VerifiedAt: time.Now().UTC()}
+	err := verifyThreshold(envelope, &policy, result)
+	result.record(CheckThreshold, err, fmt.Sprintf("Signed by %s (%d of %d required)",
+		strings.Join(result.Signers, ", "), len(result.Signers), policy.Threshold))
+	if err != nil {
+		result.Fail(err, remediation.Context{Target: result.Subject})
+		return result, err
+	}
+
+	result.Valid = true
+	return result, nil
+}
+
+func verifyThreshold(envelope *Envelope, policy *ThresholdPolicy, result *VerificationResult) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	if envelope == nil {
+		return Errorf(CodeVerificationFailed, "Envelope is missing")
+	}
+	if statement, err := envelope.Statement(); err == nil && len(statement.Subject) > 0 {
+		result.Subject = statement.Subject[0].Name
+	}
+
+	payload, err := envelope.DecodePayload()
+	if err != nil {
+		return err
+	}
+	message := PAE(envelope.PayloadType, payload)
+	digest := sha256.Sum256(message)
+
+	var missing []string
+	for _, signer := range policy.Signers {
+		key, err := parsePublicKey(signer.PublicKey)
+		if err != nil {
+			return Wrap(CodePublicKeyExtraction, err, "Public key of trusted signer %s is invalid", signer.Name)
+		}
+
+		signed := false
+		for _, signature := range envelope.Signatures {
+			sig, err := base64.StdEncoding.DecodeString(signature.Sig)
+			if err == nil && verifyWithKey(key, digest[:], message, sig) {
+				signed = true
+				break
+			}
+		}
+		if signed {
+			result.Signers = append(result.Signers, signer.Name)
+		} else {
+			missing = append(missing, signer.Name)
+		}
+	}
+
+	if len(result.Signers) < policy.Threshold {
+		return Errorf(CodeThresholdNotMet, "Envelope has %d of the %d required signatures; missing %s",
+			len(result.Signers), policy.Threshold, strings.Join(missing, ", "))
+	}
+	return nil
+}
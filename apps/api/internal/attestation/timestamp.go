@@ -0,0 +1,398 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// RFC 3161 and CMS object identifiers
+var (
+	oidSignedData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidAttributeDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// RFC 3161 ASN.1 structures
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional,default:false"`
+}
+
+type timeStampResp struct {
+	Status asn1.RawValue
+	Token  asn1.RawValue `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type encapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time     `asn1:"generalized"`
+	Accuracy       accuracy      `asn1:"optional"`
+	Ordering       bool          `asn1:"optional,default:false"`
+	Nonce          *big.Int      `asn1:"optional"`
+	TSA            asn1.RawValue `asn1:"optional,tag:0"`
+	Extensions     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// Timestamp is a parsed RFC 3161 timestamp token
+type Timestamp struct {
+	Time         time.Time
+	Accuracy     time.Duration
+	SerialNumber *big.Int
+	Policy       asn1.ObjectIdentifier
+	Hash         crypto.Hash
+	HashedValue  []byte
+	Certificates []*x509.Certificate
+
+	signedData signedData
+	content    []byte
+}
+
+// TimestampAuthority requests RFC 3161 timestamps from a TSA
+type TimestampAuthority struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewTimestampAuthority creates a TSA client
+func NewTimestampAuthority(url string) *TimestampAuthority {
+	return &TimestampAuthority{URL: url, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Timestamp requests a timestamp token over the SHA-256 digest of data, typically a signature
+func (t *TimestampAuthority) Timestamp(ctx context.Context, data []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, Wrap(CodeTimestampFailed, err, "Failed to generate timestamp nonce")
+	}
+
+	digest := crypto.SHA256.New()
+	digest.Write(data)
+	request, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest.Sum(nil),
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, Wrap(CodeTimestampFailed, err, "Failed to encode timestamp request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(request))
+	if err != nil {
+		return nil, Wrap(CodeTimestampFailed, err, "Invalid timestamp authority URL")
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+	req.Header.Set("Accept", "application/timestamp-reply")
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, Wrap(CodeNetworkTimeout, err, "Timestamp authority %s is unreachable", t.URL)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, Wrap(CodeTimestampFailed, err, "Failed to read timestamp response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, Errorf(CodeTimestampFailed, "Timestamp authority returned status %d", resp.StatusCode)
+	}
+
+	token, err := parseTimestampResponse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := ParseTimestamp(token)
+	if err != nil {
+		return nil, err
+	}
+	if ts.nonce() == nil || ts.nonce().Cmp(nonce) != 0 {
+		return nil, Errorf(CodeTimestampFailed, "Timestamp response nonce does not match the request")
+	}
+	return token, nil
+}
+
+// parseTimestampResponse checks the PKI status and returns the timestamp token
+func parseTimestampResponse(body []byte) ([]byte, error) {
+	var resp timeStampResp
+	if _, err := asn1.Unmarshal(body, &resp); err != nil {
+		return nil, Wrap(CodeTimestampFailed, err, "Timestamp response is not valid DER")
+	}
+
+	var status int
+	if _, err := asn1.Unmarshal(resp.Status.Bytes, &status); err != nil {
+		return nil, Wrap(CodeTimestampFailed, err, "Timestamp response has no status")
+	}
+	// 0 is granted, 1 is granted with modifications
+	if status > 1 {
+		return nil, Errorf(CodeTimestampFailed, "Timestamp authority rejected the request with status %d", status)
+	}
+	if len(resp.Token.FullBytes) == 0 {
+		return nil, Errorf(CodeTimestampFailed, "Timestamp response has no token")
+	}
+	return resp.Token.FullBytes, nil
+}
+
+// ParseTimestamp decodes a timestamp token without verifying it
+func ParseTimestamp(token []byte) (*Timestamp, error) {
+	var info contentInfo
+	if _, err := asn1.Unmarshal(token, &info); err != nil {
+		return nil, Wrap(CodeTimestampInvalid, err, "Timestamp token is not valid CMS")
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, Errorf(CodeTimestampInvalid, "Timestamp token is not CMS signed data")
+	}
+
+	var sd signedData
+	// Explicitly tagged raw values keep their tag, so the content is the [0] body
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		return nil, Wrap(CodeTimestampInvalid, err, "Timestamp token signed data is malformed")
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return nil, Errorf(CodeTimestampInvalid, "Timestamp token does not contain TSTInfo")
+	}
+
+	var content []byte
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &content); err != nil {
+		return nil, Wrap(CodeTimestampInvalid, err, "Timestamp token has no TSTInfo content")
+	}
+	var tst tstInfo
+	if _, err := asn1.Unmarshal(content, &tst); err != nil {
+		return nil, Wrap(CodeTimestampInvalid, err, "Timestamp TSTInfo is malformed")
+	}
+
+	hash, err := hashForOID(tst.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	if len(sd.Certificates.Bytes) > 0 {
+		if certs, err = x509.ParseCertificates(sd.Certificates.Bytes); err != nil {
+			return nil, Wrap(CodeTimestampInvalid, err, "Timestamp token certificates are malformed")
+		}
+	}
+
+	return &Timestamp{
+		Time: tst.GenTime.UTC(),
+		Accuracy: time.Duration(tst.Accuracy.Seconds)*time.Second +
+			time.Duration(tst.Accuracy.Millis)*time.Millisecond +
+			time.Duration(tst.Accuracy.Micros)*time.Microsecond,
+		SerialNumber: tst.SerialNumber,
+		Policy:       tst.Policy,
+		Hash:         hash,
+		HashedValue:  tst.MessageImprint.HashedMessage,
+		Certificates: certs,
+		signedData:   sd,
+		content:      content,
+	}, nil
+}
+
+// nonce returns the TSTInfo nonce, if any
+func (t *Timestamp) nonce() *big.Int {
+	var tst tstInfo
+	if _, err := asn1.Unmarshal(t.content, &tst); err != nil {
+		return nil
+	}
+	return tst.Nonce
+}
+
+// VerifyTimestamp verifies that a timestamp token covers data and was signed by a
+// TSA certificate chaining to one of the roots, and returns the trusted time
+func VerifyTimestamp(token, data []byte, roots *x509.CertPool) (*Timestamp, error) {
+	ts, err := ParseTimestamp(token)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := ts.Hash.New()
+	digest.Write(data)
+	if !bytes.Equal(digest.Sum(nil), ts.HashedValue) {
+		return nil, Errorf(CodeTimestampInvalid, "Timestamp does not cover the signature")
+	}
+
+	if len(ts.signedData.SignerInfos) != 1 {
+		return nil, Errorf(CodeTimestampInvalid, "Timestamp token must have exactly one signer")
+	}
+	signer := ts.signedData.SignerInfos[0]
+
+	signedAttrs, err := verifySignedAttributes(signer, ts.content)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signer identifier is not trusted; the certificate whose key verifies
+	// the signature must chain to a root and carry the timestamping usage
+	hash, err := hashForOID(signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	attrsDigest := hash.New()
+	attrsDigest.Write(signedAttrs)
+	sum := attrsDigest.Sum(nil)
+
+	for _, cert := range ts.Certificates {
+		if !verifyDigest(cert.PublicKey, hash, sum, signedAttrs, signer.Signature) {
+			continue
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, other := range ts.Certificates {
+			if other != cert {
+				intermediates.AddCert(other)
+			}
+		}
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   ts.Time,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		})
+		if err != nil {
+			return nil, Wrap(CodeTimestampInvalid, err, "Timestamp signer does not chain to a trusted timestamp authority")
+		}
+		return ts, nil
+	}
+
+	return nil, Errorf(CodeTimestampInvalid, "Timestamp signature does not verify with any embedded certificate")
+}
+
+// verifySignedAttributes checks the messageDigest attribute against the content
+// and returns the DER the signature is computed over
+func verifySignedAttributes(signer signerInfo, content []byte) ([]byte, error) {
+	if len(signer.SignedAttrs.FullBytes) == 0 {
+		return nil, Errorf(CodeTimestampInvalid, "Timestamp signer has no signed attributes")
+	}
+
+	// The signature covers the attributes re-tagged as a universal SET
+	signed := append([]byte{0x31}, signer.SignedAttrs.FullBytes[1:]...)
+
+	var attrs []attribute
+	if _, err := asn1.UnmarshalWithParams(signed, &attrs, "set"); err != nil {
+		return nil, Wrap(CodeTimestampInvalid, err, "Timestamp signed attributes are malformed")
+	}
+
+	hash, err := hashForOID(signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	contentDigest := hash.New()
+	contentDigest.Write(content)
+
+	for _, attr := range attrs {
+		if !attr.Type.Equal(oidAttributeDigest) {
+			continue
+		}
+		var messageDigest []byte
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &messageDigest); err != nil {
+			return nil, Wrap(CodeTimestampInvalid, err, "Timestamp message digest attribute is malformed")
+		}
+		if !bytes.Equal(messageDigest, contentDigest.Sum(nil)) {
+			return nil, Errorf(CodeTimestampInvalid, "Timestamp message digest does not match TSTInfo")
+		}
+		return signed, nil
+	}
+	return nil, Errorf(CodeTimestampInvalid, "Timestamp signed attributes have no message digest")
+}
+
+// verifyDigest verifies a signature over a digest computed with hash
+func verifyDigest(key crypto.PublicKey, hash crypto.Hash, digest, message, sig []byte) bool {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest, sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil ||
+			rsa.VerifyPSS(key, hash, digest, sig, nil) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, message, sig)
+	}
+	return false
+}
+
+// hashForOID maps digest algorithm identifiers to hashes
+func hashForOID(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, Errorf(CodeTimestampInvalid, "Unsupported timestamp digest algorithm %s", oid)
+}
+
+// String renders the timestamp for logs
+func (t *Timestamp) String() string {
+	return fmt.Sprintf("%s (serial %s)", t.Time.Format(time.RFC3339), t.SerialNumber)
+}
@@ -0,0 +1,218 @@
+// Package tofu pins the signer identity of each artifact on first use, for
+// teams without an explicit identity policy. The first verified attestation
+// of an artifact records who signed it; later attestations signed by anyone
+// else are flagged, or rejected when pins are enforced.
+package tofu
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/remediation"
+)
+
+// Mode selects what happens when a signer differs from the pinned one
+type Mode string
+
+const (
+	ModeOff     Mode = "off"     // Identities are neither pinned nor checked
+	ModeWarn    Mode = "warn"    // Changed identities are reported but accepted
+	ModeEnforce Mode = "enforce" // Changed identities fail verification
+)
+
+// ParseMode parses a mode name, defaulting to ModeOff when empty
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(name)); mode {
+	case "":
+		return ModeOff, nil
+	case ModeOff, ModeWarn, ModeEnforce:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown TOFU mode %q, expected off, warn or enforce", name)
+	}
+}
+
+// Pin is the identity first seen signing an artifact
+type Pin struct {
+	Scope         string    `json:"scope"`
+	Issuer        string    `json:"issuer"`
+	Identity      string    `json:"identity"`
+	AttestationID string    `json:"attestation_id"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// Matches reports whether the certificate identity is the pinned one
+func (p *Pin) Matches(identity *attestation.CertificateIdentity) bool {
+	return p.Issuer == identity.Issuer && p.Identity == PinnedIdentity(identity)
+}
+
+// PinnedIdentity is the part of a certificate identity that is pinned: the
+// SAN without its workflow ref, since a workflow signing each release tag
+// has a new ref every time
+func PinnedIdentity(identity *attestation.CertificateIdentity) string {
+	san := identity.SAN
+	if strings.Contains(san, "://") {
+		if i := strings.LastIndex(san, "@"); i > 0 {
+			return san[:i]
+		}
+	}
+	return san
+}
+
+// Config holds pinning configuration
+type Config struct {
+	Mode  Mode
+	Clock clock.Clock // Defaults to the system clock
+}
+
+// Store persists pins in SQLite
+type Store struct {
+	db     *sql.DB
+	config Config
+	clock  clock.Clock
+}
+
+// NewStore creates a pin store on the migrated database
+func NewStore(db *sql.DB, config Config) *Store {
+	if config.Mode == "" {
+		config.Mode = ModeOff
+	}
+	return &Store{db: db, config: config, clock: clock.OrReal(config.Clock)}
+}
+
+// Mode returns the configured mode
+func (s *Store) Mode() Mode {
+	return s.config.Mode
+}
+
+// Check pins the signer of a successful verification to the scope on first
+// use, and compares it with the pin afterwards. The outcome is recorded as a
+// check on the result. A changed identity returns a CodeIdentityChanged error
+// with the existing pin; under ModeEnforce it also fails the result, while
+// under ModeWarn the result stays valid so the caller only reports it.
+func (s *Store) Check(ctx context.Context, scope string, result *attestation.VerificationResult, attestationID string) (*Pin, error) {
+	if s.config.Mode == ModeOff || result == nil || !result.Valid {
+		return nil, nil
+	}
+	identity := result.Certificate
+	if identity == nil || identity.SAN == "" {
+		result.Checks = append(result.Checks, attestation.Check{
+			Name:   attestation.CheckIdentityPin,
+			Status: attestation.CheckSkipped,
+			Detail: "No certificate identity to pin",
+		})
+		return nil, nil
+	}
+
+	now := s.clock.Now().UTC()
+	pin, created, err := s.pin(ctx, scope, identity, attestationID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if created {
+		result.Checks = append(result.Checks, attestation.Check{
+			Name:   attestation.CheckIdentityPin,
+			Status: attestation.CheckPassed,
+			Detail: fmt.Sprintf("Pinned %s on first use", pin.Identity),
+		})
+		return pin, nil
+	}
+
+	if pin.Matches(identity) {
+		if err := s.touch(ctx, scope, now); err != nil {
+			return nil, err
+		}
+		result.Checks = append(result.Checks, attestation.Check{
+			Name:   attestation.CheckIdentityPin,
+			Status: attestation.CheckPassed,
+			Detail: fmt.Sprintf("Matches the identity pinned on %s", pin.FirstSeen.Format(time.RFC3339)),
+		})
+		return pin, nil
+	}
+
+	changed := attestation.Errorf(attestation.CodeIdentityChanged,
+		"%s is pinned to %s (%s) since %s, but this attestation was signed by %s (%s)",
+		scope, pin.Identity, pin.Issuer, pin.FirstSeen.Format(time.RFC3339), PinnedIdentity(identity), identity.Issuer)
+	result.Checks = append(result.Checks, attestation.Check{
+		Name:      attestation.CheckIdentityPin,
+		Status:    attestation.CheckFailed,
+		Detail:    changed.Error(),
+		ErrorCode: attestation.CodeIdentityChanged,
+	})
+	if s.config.Mode == ModeEnforce {
+		result.Fail(changed, remediation.Context{Target: scope, Identity: pin.Identity, Issuer: pin.Issuer})
+	}
+	return pin, changed
+}
+
+// Get returns the pin for a scope, or nil if none is recorded
+func (s *Store) Get(ctx context.Context, scope string) (*Pin, error) {
+	query := `
+		SELECT scope, issuer, identity, attestation_id, first_seen, last_seen
+		FROM identity_pins WHERE scope = ?
+	`
+
+	var pin Pin
+	err := s.db.QueryRowContext(ctx, query, scope).Scan(
+		&pin.Scope, &pin.Issuer, &pin.Identity, &pin.AttestationID, &pin.FirstSeen, &pin.LastSeen)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity pin: %w", err)
+	}
+	return &pin, nil
+}
+
+// Reset removes the pin for a scope, so the next verified signer is pinned
+// in its place
+func (s *Store) Reset(ctx context.Context, scope string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM identity_pins WHERE scope = ?`, scope); err != nil {
+		return fmt.Errorf("failed to reset identity pin: %w", err)
+	}
+	return nil
+}
+
+// pin records the identity unless the scope is already pinned, returning the
+// scope's pin and whether this call created it. Concurrent first uses agree
+// on whichever insert lands first.
+func (s *Store) pin(ctx context.Context, scope string, identity *attestation.CertificateIdentity, attestationID string, now time.Time) (*Pin, bool, error) {
+	insertSQL := `
+		INSERT INTO identity_pins (scope, issuer, identity, attestation_id, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(scope) DO NOTHING
+	`
+
+	result, err := s.db.ExecContext(ctx, insertSQL, scope, identity.Issuer, PinnedIdentity(identity), attestationID, now, now)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to pin identity: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to pin identity: %w", err)
+	}
+
+	pin, err := s.Get(ctx, scope)
+	if err != nil {
+		return nil, false, err
+	}
+	if pin == nil {
+		return nil, false, fmt.Errorf("identity pin for %s disappeared", scope)
+	}
+	return pin, rows == 1, nil
+}
+
+// touch records that the pinned identity signed again
+func (s *Store) touch(ctx context.Context, scope string, now time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE identity_pins SET last_seen = ? WHERE scope = ?`, now, scope); err != nil {
+		return fmt.Errorf("failed to update identity pin: %w", err)
+	}
+	return nil
+}
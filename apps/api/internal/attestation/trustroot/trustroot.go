@@ -0,0 +1,342 @@
+// Package trustroot maintains the Sigstore trust root bundles are verified
+// against: it follows the Sigstore TUF repository from a trusted root.json to
+// the signed trusted_root.json target, caching and refreshing it through the
+// HierarchicalCache, or serves a pinned trust root for private deployments
+package trustroot
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+	"github.com/salman-frs/keystone/apps/api/internal/history"
+)
+
+// DefaultMirror is the public Sigstore TUF repository
+const DefaultMirror = "https://tuf-repo-cdn.sigstore.dev"
+
+// TrustedRootTarget is the TUF target holding the Sigstore trust root
+const TrustedRootTarget = "trusted_root.json"
+
+// Cache namespaces: the trust root is loaded on demand, while the latest
+// verified TUF root is kept so later refreshes resume from it
+const (
+	namespace     = "tuf"
+	rootNamespace = "tuf-root"
+)
+
+// Config selects where the trust root comes from
+type Config struct {
+	Mirror          string        // TUF repository URL; defaults to DefaultMirror
+	InitialRoot     []byte        // root.json trusted out of band, the start of the TUF chain
+	PinnedRoot      []byte        // trusted_root.json used as-is without TUF, for private Sigstore deployments
+	RefreshInterval time.Duration // How long a fetched trust root is used before refreshing
+	MaxStaleness    time.Duration // How long past a refresh a cached trust root is served while refreshing fails
+	HTTPClient      *http.Client
+	History         *history.Store // Retains each trust root served, for point-in-time verification; optional
+}
+
+// DefaultConfig returns a configuration for the public Sigstore TUF repository
+func DefaultConfig(initialRoot []byte) Config {
+	return Config{
+		Mirror:          DefaultMirror,
+		InitialRoot:     initialRoot,
+		RefreshInterval: 24 * time.Hour,
+		MaxStaleness:    7 * 24 * time.Hour,
+	}
+}
+
+// ConfigFromEnv reads SIGSTORE_TRUSTED_ROOT (a pinned trusted_root.json), or
+// SIGSTORE_TUF_ROOT (the initial root.json) and the TUF mirror of the
+// SIGSTORE_ENV environment
+func ConfigFromEnv() (Config, error) {
+	sigstore, err := attestation.SigstoreConfigFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+	config := DefaultConfig(nil)
+	config.Mirror = sigstore.TUFMirror
+
+	if path := os.Getenv("SIGSTORE_TRUSTED_ROOT"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read pinned trust root: %w", err)
+		}
+		config.PinnedRoot = data
+		return config, nil
+	}
+
+	if path := os.Getenv("SIGSTORE_TUF_ROOT"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read TUF root: %w", err)
+		}
+		config.InitialRoot = data
+	}
+	if config.Mirror == "" {
+		return Config{}, attestation.Errorf(attestation.CodeTrustRootInvalid, "The %s Sigstore environment has no TUF mirror; set SIGSTORE_TUF_MIRROR or pin a trust root with SIGSTORE_TRUSTED_ROOT", sigstore.Environment)
+	}
+	return config, nil
+}
+
+// Manager serves the current trust root. A cache serves one Manager, since the
+// "tuf" namespace loader belongs to it.
+type Manager struct {
+	config   Config
+	cache    *cache.HierarchicalCache
+	key      string
+	pinned   *attestation.TrustRoot
+	mutex    sync.Mutex
+	versions map[string]int64 // Highest TUF metadata versions seen, to reject rollbacks
+	onRotate []func(ctx context.Context) error
+}
+
+// NewManager creates a manager; with a pinned root the cache may be nil
+func NewManager(config Config, hierCache *cache.HierarchicalCache) (*Manager, error) {
+	defaults := DefaultConfig(nil)
+	if config.Mirror == "" {
+		config.Mirror = defaults.Mirror
+	}
+	config.Mirror = strings.TrimSuffix(config.Mirror, "/")
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaults.RefreshInterval
+	}
+	if config.MaxStaleness < 0 {
+		config.MaxStaleness = 0
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	manager := &Manager{
+		config:   config,
+		cache:    hierCache,
+		key:      namespace + ":" + config.Mirror,
+		versions: make(map[string]int64),
+	}
+
+	if len(config.PinnedRoot) > 0 {
+		trust, err := ParseTrustedRoot(config.PinnedRoot)
+		if err != nil {
+			return nil, err
+		}
+		manager.pinned = &trust
+		manager.retain(context.Background(), PinnedHistoryKey, config.PinnedRoot)
+		return manager, nil
+	}
+
+	if len(config.InitialRoot) == 0 {
+		return nil, attestation.Errorf(attestation.CodeTrustRootInvalid, "No TUF root configured; set SIGSTORE_TUF_ROOT to a trusted root.json or pin a trust root with SIGSTORE_TRUSTED_ROOT")
+	}
+	if _, err := newTUFClient(config.Mirror, config.HTTPClient, config.InitialRoot); err != nil {
+		return nil, err
+	}
+	if hierCache == nil {
+		return nil, fmt.Errorf("TUF trust root requires a cache")
+	}
+
+	hierCache.RegisterLoader(namespace, manager.load, cache.LoaderOptions{
+		TTL:                  config.RefreshInterval,
+		StaleWhileRevalidate: config.MaxStaleness,
+	})
+	return manager, nil
+}
+
+// TrustRoot returns the current trust root, fetching it through TUF when the
+// cached copy is missing and refreshing it in the background once it is due
+func (m *Manager) TrustRoot(ctx context.Context) (attestation.TrustRoot, error) {
+	if m.pinned != nil {
+		return *m.pinned, nil
+	}
+
+	value, err := m.cache.Load(ctx, m.key)
+	if err != nil {
+		return attestation.TrustRoot{}, err
+	}
+	data, ok := value.(string)
+	if !ok {
+		return attestation.TrustRoot{}, attestation.Errorf(attestation.CodeTrustRootInvalid, "Cached trust root has unexpected type %T", value)
+	}
+	return ParseTrustedRoot([]byte(data))
+}
+
+// OnRotate registers a callback run when a fetched trust root differs from the
+// one it replaces, such as invalidating verification results made against it
+func (m *Manager) OnRotate(callback func(ctx context.Context) error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.onRotate = append(m.onRotate, callback)
+}
+
+// Refresh fetches the trust root through TUF now, keeping the cached copy if
+// the refresh fails
+func (m *Manager) Refresh(ctx context.Context) error {
+	if m.pinned != nil {
+		return nil
+	}
+
+	value, err := m.load(ctx, m.key)
+	if err != nil {
+		return err
+	}
+	return m.cache.Set(ctx, m.key, value, m.config.RefreshInterval)
+}
+
+// load is the "tuf" namespace loader: it updates TUF metadata from the latest
+// verified root and returns the trusted_root.json target
+func (m *Manager) load(ctx context.Context, _ string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rootKey := rootNamespace + ":" + m.config.Mirror
+	rootJSON := m.config.InitialRoot
+	if cached, found := m.cache.Get(ctx, rootKey); found {
+		if data, ok := cached.(string); ok {
+			rootJSON = []byte(data)
+		}
+	}
+
+	client, err := newTUFClient(m.config.Mirror, m.config.HTTPClient, rootJSON)
+	if err != nil {
+		// A corrupt cached root falls back to the configured one
+		if client, err = newTUFClient(m.config.Mirror, m.config.HTTPClient, m.config.InitialRoot); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := client.fetchTarget(ctx, TrustedRootTarget, m.versions)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ParseTrustedRoot(data); err != nil {
+		return nil, err
+	}
+
+	if err := m.cache.Set(ctx, rootKey, string(client.rootJSON), 365*24*time.Hour); err != nil {
+		return nil, fmt.Errorf("failed to cache TUF root: %w", err)
+	}
+	m.detectRotation(ctx, data)
+	m.retain(ctx, m.config.Mirror, data)
+	return string(data), nil
+}
+
+// PinnedHistoryKey is the history key a pinned trust root is retained under;
+// trust roots fetched through TUF are keyed by their mirror
+const PinnedHistoryKey = "pinned"
+
+// retain records the trust root in the history, if one is configured. A
+// failure is logged rather than failing verification.
+func (m *Manager) retain(ctx context.Context, key string, data []byte) {
+	if m.config.History == nil {
+		return
+	}
+	if _, err := m.config.History.Record(ctx, history.KindTrustRoot, key, data, time.Now()); err != nil {
+		log.Printf("Failed to retain trust root: %v", err)
+	}
+}
+
+// detectRotation runs the OnRotate callbacks when the trust root fingerprint
+// recorded by the previous load, possibly by another process, has changed
+func (m *Manager) detectRotation(ctx context.Context, data []byte) {
+	digest := sha256.Sum256(data)
+	fingerprint := hex.EncodeToString(digest[:])
+	fingerprintKey := rootNamespace + ":" + m.config.Mirror + ":trusted"
+
+	previous, found := m.cache.Get(ctx, fingerprintKey)
+	if found && previous == fingerprint {
+		return
+	}
+	if err := m.cache.Set(ctx, fingerprintKey, fingerprint, 365*24*time.Hour); err != nil {
+		log.Printf("Failed to record trust root fingerprint: %v", err)
+	}
+	if !found {
+		return
+	}
+
+	log.Printf("Sigstore trust root from %s rotated", m.config.Mirror)
+	for _, callback := range m.onRotate {
+		if err := callback(ctx); err != nil {
+			log.Printf("Trust root rotation callback failed: %v", err)
+		}
+	}
+}
+
+// trustedRoot is the Sigstore trusted_root.json format
+// (application/vnd.dev.sigstore.trustedroot+json)
+type trustedRoot struct {
+	MediaType string `json:"mediaType"`
+	Tlogs     []struct {
+		PublicKey struct {
+			RawBytes []byte `json:"rawBytes"` // DER SubjectPublicKeyInfo
+		} `json:"publicKey"`
+	} `json:"tlogs"`
+	CertificateAuthorities []certificateAuthority `json:"certificateAuthorities"`
+	TimestampAuthorities   []certificateAuthority `json:"timestampAuthorities"`
+}
+
+type certificateAuthority struct {
+	CertChain struct {
+		Certificates []struct {
+			RawBytes []byte `json:"rawBytes"` // DER certificate
+		} `json:"certificates"`
+	} `json:"certChain"`
+}
+
+// ParseTrustedRoot converts a Sigstore trusted_root.json into the trust root
+// bundles are verified against
+func ParseTrustedRoot(data []byte) (attestation.TrustRoot, error) {
+	var root trustedRoot
+	if err := json.Unmarshal(data, &root); err != nil {
+		return attestation.TrustRoot{}, attestation.Wrap(attestation.CodeTrustRootInvalid, err, "Trust root is not valid JSON")
+	}
+	if !strings.HasPrefix(root.MediaType, "application/vnd.dev.sigstore.trustedroot") {
+		return attestation.TrustRoot{}, attestation.Errorf(attestation.CodeTrustRootInvalid, "Unsupported trust root media type %q", root.MediaType)
+	}
+
+	var trust attestation.TrustRoot
+	var err error
+	if trust.FulcioCertificates, err = encodeCertificates(root.CertificateAuthorities); err != nil {
+		return attestation.TrustRoot{}, err
+	}
+	if trust.TimestampAuthorities, err = encodeCertificates(root.TimestampAuthorities); err != nil {
+		return attestation.TrustRoot{}, err
+	}
+	for _, tlog := range root.Tlogs {
+		if _, err := x509.ParsePKIXPublicKey(tlog.PublicKey.RawBytes); err != nil {
+			return attestation.TrustRoot{}, attestation.Wrap(attestation.CodeTrustRootInvalid, err, "Trust root has an invalid transparency log key")
+		}
+		trust.RekorPublicKeys = append(trust.RekorPublicKeys,
+			string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: tlog.PublicKey.RawBytes})))
+	}
+
+	if len(trust.FulcioCertificates) == 0 && len(trust.TimestampAuthorities) == 0 {
+		return attestation.TrustRoot{}, attestation.Errorf(attestation.CodeTrustRootInvalid, "Trust root has no certificate or timestamp authorities")
+	}
+	return trust, nil
+}
+
+// encodeCertificates PEM-encodes every certificate of the authorities' chains
+func encodeCertificates(authorities []certificateAuthority) ([]string, error) {
+	var encoded []string
+	for _, authority := range authorities {
+		for _, cert := range authority.CertChain.Certificates {
+			if _, err := x509.ParseCertificate(cert.RawBytes); err != nil {
+				return nil, attestation.Wrap(attestation.CodeTrustRootInvalid, err, "Trust root has an invalid certificate")
+			}
+			encoded = append(encoded, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.RawBytes})))
+		}
+	}
+	return encoded, nil
+}
@@ -0,0 +1,457 @@
+package trustroot
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// Size limits for TUF downloads whose length isn't known in advance
+const (
+	maxMetadataSize  = 512 << 10
+	maxRootRotations = 32
+)
+
+// errNotFound reports a missing file on the mirror
+var errNotFound = errors.New("not found on TUF mirror")
+
+// signedMetadata is a TUF metadata file: a signed payload and its signatures
+type signedMetadata struct {
+	Signed     json.RawMessage `json:"signed"`
+	Signatures []struct {
+		KeyID string `json:"keyid"`
+		Sig   string `json:"sig"`
+	} `json:"signatures"`
+}
+
+// tufKey is a public key in root metadata
+type tufKey struct {
+	KeyType string `json:"keytype"`
+	Scheme  string `json:"scheme"`
+	KeyVal  struct {
+		Public string `json:"public"`
+	} `json:"keyval"`
+}
+
+// tufRole lists the keys trusted for a role and how many must sign
+type tufRole struct {
+	KeyIDs    []string `json:"keyids"`
+	Threshold int      `json:"threshold"`
+}
+
+// metadataHeader holds the fields common to every role's metadata
+type metadataHeader struct {
+	Type    string    `json:"_type"`
+	Version int64     `json:"version"`
+	Expires time.Time `json:"expires"`
+}
+
+type rootMetadata struct {
+	metadataHeader
+	ConsistentSnapshot bool               `json:"consistent_snapshot"`
+	Keys               map[string]tufKey  `json:"keys"`
+	Roles              map[string]tufRole `json:"roles"`
+}
+
+// fileMeta describes a metadata or target file listed by another role
+type fileMeta struct {
+	Version int64             `json:"version"`
+	Length  int64             `json:"length"`
+	Hashes  map[string]string `json:"hashes"`
+}
+
+// snapshotMetadata is the shape of both timestamp and snapshot metadata
+type snapshotMetadata struct {
+	metadataHeader
+	Meta map[string]fileMeta `json:"meta"`
+}
+
+type targetsMetadata struct {
+	metadataHeader
+	Targets map[string]fileMeta `json:"targets"`
+}
+
+// tufClient walks a TUF repository from a trusted root to a verified target,
+// following the TUF client workflow without delegations
+type tufClient struct {
+	mirror   string
+	client   *http.Client
+	root     rootMetadata
+	rootJSON []byte
+	now      time.Time
+}
+
+// newTUFClient starts from a root.json trusted out of band
+func newTUFClient(mirror string, client *http.Client, rootJSON []byte) (*tufClient, error) {
+	var signed signedMetadata
+	if err := json.Unmarshal(rootJSON, &signed); err != nil {
+		return nil, attestation.Wrap(attestation.CodeTrustRootInvalid, err, "Trusted TUF root is not valid JSON")
+	}
+	c := &tufClient{mirror: strings.TrimSuffix(mirror, "/"), client: client, rootJSON: rootJSON, now: time.Now()}
+	if err := json.Unmarshal(signed.Signed, &c.root); err != nil || c.root.Type != "root" {
+		return nil, attestation.Errorf(attestation.CodeTrustRootInvalid, "Trusted TUF root is not root metadata")
+	}
+	return c, nil
+}
+
+// fetchTarget updates the root, timestamp, snapshot and targets metadata and
+// downloads the named target, verifying its length and hashes
+func (c *tufClient) fetchTarget(ctx context.Context, name string, versions map[string]int64) ([]byte, error) {
+	if err := c.updateRoot(ctx); err != nil {
+		return nil, err
+	}
+
+	var timestamp snapshotMetadata
+	if err := c.fetchRole(ctx, "timestamp", "timestamp.json", nil, &timestamp, versions); err != nil {
+		return nil, err
+	}
+	snapshotMeta, ok := timestamp.Meta["snapshot.json"]
+	if !ok {
+		return nil, attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF timestamp does not list snapshot.json")
+	}
+
+	var snapshot snapshotMetadata
+	if err := c.fetchRole(ctx, "snapshot", c.versioned("snapshot.json", snapshotMeta.Version), &snapshotMeta, &snapshot, versions); err != nil {
+		return nil, err
+	}
+	targetsMeta, ok := snapshot.Meta["targets.json"]
+	if !ok {
+		return nil, attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF snapshot does not list targets.json")
+	}
+
+	var targets targetsMetadata
+	if err := c.fetchRole(ctx, "targets", c.versioned("targets.json", targetsMeta.Version), &targetsMeta, &targets, versions); err != nil {
+		return nil, err
+	}
+
+	target, ok := targets.Targets[name]
+	if !ok {
+		return nil, attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF targets do not list %s", name)
+	}
+	path := "targets/" + name
+	if c.root.ConsistentSnapshot {
+		digest, ok := target.Hashes["sha256"]
+		if !ok {
+			return nil, attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF target %s has no sha256 hash", name)
+		}
+		path = "targets/" + digest + "." + name
+	}
+
+	data, err := c.fetch(ctx, path, target.Length)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFile(name, data, target); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// updateRoot follows root rotations: each N+1.root.json must be signed by a
+// threshold of both the current and its own root keys
+func (c *tufClient) updateRoot(ctx context.Context) error {
+	for i := 0; i < maxRootRotations; i++ {
+		next := c.root.Version + 1
+		data, err := c.fetch(ctx, strconv.FormatInt(next, 10)+".root.json", 0)
+		if errors.Is(err, errNotFound) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var signed signedMetadata
+		if err := json.Unmarshal(data, &signed); err != nil {
+			return attestation.Wrap(attestation.CodeTrustRootInvalid, err, "TUF root v%d is not valid JSON", next)
+		}
+		if err := verifyRole(signed, c.root, "root"); err != nil {
+			return err
+		}
+		var root rootMetadata
+		if err := json.Unmarshal(signed.Signed, &root); err != nil || root.Type != "root" {
+			return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF root v%d is not root metadata", next)
+		}
+		if err := verifyRole(signed, root, "root"); err != nil {
+			return err
+		}
+		if root.Version != next {
+			return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF root v%d claims version %d", next, root.Version)
+		}
+		c.root, c.rootJSON = root, data
+	}
+
+	if c.now.After(c.root.Expires) {
+		return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF root v%d expired at %s", c.root.Version, c.root.Expires.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// fetchRole downloads and verifies a role's metadata against the root, the
+// metadata listing it (if any) and the versions already seen
+func (c *tufClient) fetchRole(ctx context.Context, role, path string, listed *fileMeta, out interface{}, versions map[string]int64) error {
+	var limit int64
+	if listed != nil {
+		limit = listed.Length
+	}
+	data, err := c.fetch(ctx, path, limit)
+	if err != nil {
+		return err
+	}
+	if listed != nil && len(listed.Hashes) > 0 {
+		if err := checkFile(role+".json", data, *listed); err != nil {
+			return err
+		}
+	}
+
+	var signed signedMetadata
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return attestation.Wrap(attestation.CodeTrustRootInvalid, err, "TUF %s metadata is not valid JSON", role)
+	}
+	if err := verifyRole(signed, c.root, role); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(signed.Signed, out); err != nil {
+		return attestation.Wrap(attestation.CodeTrustRootInvalid, err, "TUF %s metadata is malformed", role)
+	}
+
+	var header metadataHeader
+	if err := json.Unmarshal(signed.Signed, &header); err != nil || header.Type != role {
+		return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF %s metadata has the wrong type", role)
+	}
+	if listed != nil && header.Version != listed.Version {
+		return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF %s is version %d, expected %d", role, header.Version, listed.Version)
+	}
+	if header.Version < versions[role] {
+		return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF %s rolled back from version %d to %d", role, versions[role], header.Version)
+	}
+	if c.now.After(header.Expires) {
+		return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF %s metadata expired at %s", role, header.Expires.Format(time.RFC3339))
+	}
+	versions[role] = header.Version
+	return nil
+}
+
+// versioned names a metadata file under consistent snapshots
+func (c *tufClient) versioned(name string, version int64) string {
+	if c.root.ConsistentSnapshot && version > 0 {
+		return strconv.FormatInt(version, 10) + "." + name
+	}
+	return name
+}
+
+// fetch downloads a file from the mirror; limit is its expected length, or 0
+func (c *tufClient) fetch(ctx context.Context, path string, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		limit = maxMetadataSize
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.mirror+"/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeNetworkTimeout, err, "TUF mirror %s is unreachable", c.mirror)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden:
+		// Object stores answer 403 for missing keys
+		return nil, errNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("TUF mirror returned status %d for %s", resp.StatusCode, path)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", path, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF file %s exceeds %d bytes", path, limit)
+	}
+	return data, nil
+}
+
+// checkFile verifies a download against its listed length and hashes
+func checkFile(name string, data []byte, meta fileMeta) error {
+	if meta.Length > 0 && int64(len(data)) != meta.Length {
+		return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF file %s is %d bytes, expected %d", name, len(data), meta.Length)
+	}
+
+	checked := 0
+	for algorithm, expected := range meta.Hashes {
+		var h hash.Hash
+		switch algorithm {
+		case "sha256":
+			h = sha256.New()
+		case "sha512":
+			h = sha512.New()
+		default:
+			continue
+		}
+		h.Write(data)
+		if hex.EncodeToString(h.Sum(nil)) != strings.ToLower(expected) {
+			return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF file %s does not match its %s hash", name, algorithm)
+		}
+		checked++
+	}
+	if checked == 0 {
+		return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF file %s has no supported hash", name)
+	}
+	return nil
+}
+
+// verifyRole checks a threshold of the role's keys signed the canonical payload
+func verifyRole(signed signedMetadata, root rootMetadata, roleName string) error {
+	role, ok := root.Roles[roleName]
+	if !ok || role.Threshold < 1 {
+		return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF root v%d does not define the %s role", root.Version, roleName)
+	}
+
+	payload, err := canonicalJSON(signed.Signed)
+	if err != nil {
+		return attestation.Wrap(attestation.CodeTrustRootInvalid, err, "TUF %s metadata cannot be canonicalized", roleName)
+	}
+	digest := sha256.Sum256(payload)
+
+	trusted := make(map[string]bool, len(role.KeyIDs))
+	for _, id := range role.KeyIDs {
+		trusted[id] = true
+	}
+	valid := make(map[string]bool)
+	for _, sig := range signed.Signatures {
+		key, ok := root.Keys[sig.KeyID]
+		if !trusted[sig.KeyID] || !ok || valid[sig.KeyID] {
+			continue
+		}
+		raw, err := hex.DecodeString(sig.Sig)
+		if err != nil || len(raw) == 0 {
+			continue
+		}
+		if verifyKeySignature(key, payload, digest[:], raw) {
+			valid[sig.KeyID] = true
+		}
+	}
+
+	if len(valid) < role.Threshold {
+		return attestation.Errorf(attestation.CodeTrustRootInvalid, "TUF %s metadata has %d of %d required signatures", roleName, len(valid), role.Threshold)
+	}
+	return nil
+}
+
+// verifyKeySignature checks one signature with an ECDSA, Ed25519 or RSA-PSS key
+func verifyKeySignature(key tufKey, payload, digest, sig []byte) bool {
+	switch key.KeyType {
+	case "ed25519":
+		public, err := hex.DecodeString(key.KeyVal.Public)
+		if err != nil || len(public) != ed25519.PublicKeySize {
+			return false
+		}
+		return ed25519.Verify(ed25519.PublicKey(public), payload, sig)
+	case "ecdsa", "ecdsa-sha2-nistp256", "rsa":
+		block, _ := pem.Decode([]byte(key.KeyVal.Public))
+		if block == nil {
+			return false
+		}
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return false
+		}
+		switch public := public.(type) {
+		case *ecdsa.PublicKey:
+			return ecdsa.VerifyASN1(public, digest, sig)
+		case *rsa.PublicKey:
+			return rsa.VerifyPSS(public, crypto.SHA256, digest, sig, nil) == nil
+		}
+	}
+	return false
+}
+
+// canonicalJSON re-encodes JSON in the OLPC canonical form TUF signs: sorted
+// keys, no whitespace, integers only and strings escaping just '"' and '\'
+func canonicalJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(value))
+	case json.Number:
+		if _, err := value.Int64(); err != nil {
+			return fmt.Errorf("canonical JSON does not allow non-integer %s", value)
+		}
+		buf.WriteString(value.String())
+	case string:
+		buf.WriteByte('"')
+		buf.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value))
+		buf.WriteByte('"')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, value[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported canonical JSON value %T", value)
+	}
+	return nil
+}
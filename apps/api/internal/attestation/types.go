@@ -0,0 +1,127 @@
+package attestation
+
+import (
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/messages"
+	"github.com/salman-frs/keystone/apps/api/internal/remediation"
+)
+
+// SigningMetadata represents metadata for cryptographic signing operations
+type SigningMetadata struct {
+	Identity    string            `json:"identity"`
+	Issuer      string            `json:"issuer"`
+	Audience    string            `json:"audience"`
+	Subject     string            `json:"subject"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// AttestationRecord represents SLSA provenance and signature metadata
+type AttestationRecord struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Target      string          `json:"target"`
+	Signature   string          `json:"signature"`
+	Certificate string          `json:"certificate"`
+	Metadata    SigningMetadata `json:"metadata"`
+	RekorEntry  *RekorEntry     `json:"rekor_entry,omitempty"`
+}
+
+// VerificationResult represents signature validation outcomes
+type VerificationResult struct {
+	Valid             bool                  `json:"valid"`
+	Identity          string                `json:"identity"`
+	Issuer            string                `json:"issuer"`
+	Subject           string                `json:"subject"`
+	VerifiedAt        time.Time             `json:"verified_at"`
+	SignedAt          *time.Time            `json:"signed_at,omitempty"` // When a timestamp authority or the log vouched for the signature
+	CertificateChain  []string              `json:"certificate_chain"`
+	RekorVerified     bool                  `json:"rekor_verified"`
+	TimestampVerified bool                  `json:"timestamp_verified"`
+	Cached            bool                  `json:"cached,omitempty"`      // Reused from an earlier verification of the same digest and policy
+	Signers           []string              `json:"signers,omitempty"`     // Trusted signers verified under a threshold policy
+	Conversions       []Conversion          `json:"conversions,omitempty"` // Upgrades applied to read a statement in an older format
+	Certificate       *CertificateIdentity  `json:"certificate,omitempty"` // Signer identity from the leaf certificate
+	Checks            []Check               `json:"checks,omitempty"`      // Every verification step, in order
+	Policy            []PolicyResult        `json:"policy,omitempty"`      // Each enforced identity policy constraint
+	BuildLevel        *BuildLevelAssessment `json:"build_level,omitempty"` // SLSA build level of provenance statements
+	ErrorCode         string                `json:"error_code,omitempty"`
+	ErrorMessage      string                `json:"error_message,omitempty"`
+	Message           *messages.Message     `json:"message,omitempty"` // Catalog message for ErrorCode, for rendering in the reader's locale
+	Remediation       []remediation.Hint    `json:"remediation,omitempty"`
+}
+
+// Fail marks the result invalid and attaches remediation hints for the error's code
+func (r *VerificationResult) Fail(err error, c remediation.Context) {
+	r.Valid = false
+	r.ErrorMessage = err.Error()
+	r.ErrorCode = CodeOf(err)
+	if r.ErrorCode == "" {
+		r.ErrorCode = CodeVerificationFailed
+	}
+	r.Message = messages.ForCode(r.ErrorCode, c.Target)
+	r.Remediation = append(r.Remediation, remediation.ForErrorCode(r.ErrorCode, c)...)
+}
+
+// CheckStatus is the outcome of a verification step
+type CheckStatus string
+
+const (
+	CheckPassed  CheckStatus = "passed"
+	CheckFailed  CheckStatus = "failed"
+	CheckSkipped CheckStatus = "skipped" // Not applicable to this bundle or policy
+)
+
+// Verification steps recorded in VerificationResult.Checks
+const (
+	CheckBundle           = "bundle"
+	CheckBlobDigest       = "blob_digest"
+	CheckSubjectDigest    = "subject_digest"
+	CheckCertificateChain = "certificate_chain"
+	CheckTimestamps       = "timestamps"
+	CheckTransparencyLog  = "transparency_log"
+	CheckSignature        = "signature"
+	CheckCanonicalPayload = "canonical_payload"
+	CheckIdentityPolicy   = "identity_policy"
+	CheckThreshold        = "threshold"
+	CheckIdentityPin      = "identity_pin"
+	CheckSignedBefore     = "signed_before"
+	CheckBuildLevel       = "build_level"
+)
+
+// Check is one verification step and its outcome
+type Check struct {
+	Name      string      `json:"name"`
+	Status    CheckStatus `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	ErrorCode string      `json:"error_code,omitempty"`
+}
+
+// record appends the outcome of a step, with the detail on success or the
+// error on failure, and returns the step's error
+func (r *VerificationResult) record(name string, err error, detail string) error {
+	check := Check{Name: name, Status: CheckPassed, Detail: detail}
+	if err != nil {
+		check.Status = CheckFailed
+		check.Detail = err.Error()
+		check.ErrorCode = CodeOf(err)
+	}
+	r.Checks = append(r.Checks, check)
+	return err
+}
+
+// skip appends a step that did not apply
+func (r *VerificationResult) skip(name, reason string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: CheckSkipped, Detail: reason})
+}
+
+// RekorEntry represents transparency log entry information
+type RekorEntry struct {
+	UUID           string    `json:"uuid"`
+	LogIndex       int64     `json:"log_index"`
+	IntegratedTime int64     `json:"integrated_time"`
+	LogID          string    `json:"log_id"`
+	Verified       bool      `json:"verified"`
+	CreatedAt      time.Time `json:"created_at"`
+}
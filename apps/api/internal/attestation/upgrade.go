@@ -0,0 +1,192 @@
+package attestation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Conversion records one upgrade applied to a statement read in an older
+// format, so results built from the upgraded model can be traced back to what
+// was actually signed
+type Conversion struct {
+	Shim           string    `json:"shim"`
+	From           string    `json:"from"`
+	To             string    `json:"to"`
+	OriginalDigest DigestSet `json:"original_digest"` // Of the statement before any upgrade, as canonical JSON
+}
+
+// UpgradedStatement is a statement in the current models: an in-toto v1
+// statement whose provenance predicate, if any, is a ProvenanceV1
+type UpgradedStatement struct {
+	*Statement
+	Conversions []Conversion `json:"conversions,omitempty"` // Empty when the statement was already current
+}
+
+// Upgraded reports whether any shim was applied
+func (u *UpgradedStatement) Upgraded() bool {
+	return len(u.Conversions) > 0
+}
+
+// upgradeShim converts statements of one older type to the next version
+type upgradeShim struct {
+	name    string
+	applies func(*Statement) bool
+	from    func(*Statement) string
+	to      string
+	upgrade func(*Statement) error
+}
+
+// upgradeShims run in order, so a v0.1 statement carrying SLSA v0.2 provenance
+// is upgraded by both
+var upgradeShims = []upgradeShim{
+	{
+		name:    "in-toto-statement-v0.1-to-v1",
+		applies: func(s *Statement) bool { return s.Type == StatementTypeV01 },
+		from:    func(s *Statement) string { return s.Type },
+		to:      StatementTypeV1,
+		upgrade: func(s *Statement) error {
+			// The v1 statement only tightens v0.1's rules; subjects carry over unchanged
+			s.Type = StatementTypeV1
+			return nil
+		},
+	},
+	{
+		name:    "slsa-provenance-v0.2-to-v1",
+		applies: func(s *Statement) bool { return s.PredicateType == PredicateSLSAProvenanceV02 },
+		from:    func(s *Statement) string { return s.PredicateType },
+		to:      PredicateSLSAProvenanceV1,
+		upgrade: upgradeProvenanceV02,
+	},
+}
+
+// UpgradeStatement converts a statement read in an older format to the
+// current models, recording each conversion. Statements that are already
+// current are returned with their provenance predicate decoded into a
+// ProvenanceV1. The original statement is not modified.
+func UpgradeStatement(statement *Statement) (*UpgradedStatement, error) {
+	if statement == nil {
+		return nil, Errorf(CodeVerificationFailed, "Statement is missing")
+	}
+	if statement.Type != StatementTypeV1 && statement.Type != StatementTypeV01 {
+		return nil, Errorf(CodeVerificationFailed, "Unsupported statement type %q", statement.Type)
+	}
+
+	original, err := CanonicalJSON(statement)
+	if err != nil {
+		return nil, Wrap(CodeVerificationFailed, err, "Failed to encode statement")
+	}
+	digest := sha256.Sum256(original)
+	originalDigest := DigestSet{"sha256": hex.EncodeToString(digest[:])}
+
+	current := *statement
+	current.Subject = append([]Subject(nil), statement.Subject...)
+	upgraded := &UpgradedStatement{Statement: &current}
+	for _, shim := range upgradeShims {
+		if !shim.applies(&current) {
+			continue
+		}
+		from := shim.from(&current)
+		if err := shim.upgrade(&current); err != nil {
+			return nil, err
+		}
+		upgraded.Conversions = append(upgraded.Conversions, Conversion{
+			Shim:           shim.name,
+			From:           from,
+			To:             shim.to,
+			OriginalDigest: originalDigest,
+		})
+	}
+
+	if current.PredicateType == PredicateSLSAProvenanceV1 {
+		if _, typed := current.Predicate.(ProvenanceV1); !typed {
+			var predicate ProvenanceV1
+			if err := decodePredicate(current.Predicate, &predicate); err != nil {
+				return nil, err
+			}
+			current.Predicate = predicate
+		}
+	}
+	return upgraded, nil
+}
+
+// UpgradedStatement decodes the envelope's statement and upgrades it to the
+// current models
+func (e *Envelope) UpgradedStatement() (*UpgradedStatement, error) {
+	statement, err := e.Statement()
+	if err != nil {
+		return nil, err
+	}
+	return UpgradeStatement(statement)
+}
+
+// upgradeProvenanceV02 maps SLSA v0.2 provenance onto v1 following the SLSA
+// migration guide: parameters and the config source become external
+// parameters, the environment becomes internal parameters and materials
+// become resolved dependencies
+func upgradeProvenanceV02(s *Statement) error {
+	var old ProvenanceV02
+	if err := decodePredicate(s.Predicate, &old); err != nil {
+		return err
+	}
+
+	external := make(map[string]interface{})
+	for key, value := range old.Invocation.Parameters {
+		external[key] = value
+	}
+	if source := old.Invocation.ConfigSource; source.URI != "" {
+		external["configSource"] = map[string]interface{}{
+			"uri":        source.URI,
+			"digest":     source.Digest,
+			"entryPoint": source.EntryPoint,
+		}
+	}
+
+	var internal map[string]interface{}
+	if len(old.Invocation.Environment) > 0 || old.BuildConfig != nil {
+		internal = make(map[string]interface{})
+		for key, value := range old.Invocation.Environment {
+			internal[key] = value
+		}
+		if old.BuildConfig != nil {
+			internal["buildConfig"] = old.BuildConfig
+		}
+	}
+
+	dependencies := make([]ResourceDescriptor, 0, len(old.Materials))
+	for _, material := range old.Materials {
+		dependencies = append(dependencies, ResourceDescriptor{URI: material.URI, Digest: material.Digest})
+	}
+
+	s.PredicateType = PredicateSLSAProvenanceV1
+	s.Predicate = ProvenanceV1{
+		BuildDefinition: BuildDefinitionV1{
+			BuildType:            old.BuildType,
+			ExternalParameters:   external,
+			InternalParameters:   internal,
+			ResolvedDependencies: dependencies,
+		},
+		RunDetails: RunDetailsV1{
+			Builder: BuilderV1{ID: old.Builder.ID},
+			Metadata: BuildMetadataV1{
+				InvocationID: old.Metadata.BuildInvocationID,
+				StartedOn:    old.Metadata.BuildStartedOn,
+				FinishedOn:   old.Metadata.BuildFinishedOn,
+			},
+		},
+	}
+	return nil
+}
+
+// decodePredicate converts a predicate decoded as generic JSON, or already
+// typed, into the target model
+func decodePredicate(predicate interface{}, target interface{}) error {
+	data, err := json.Marshal(predicate)
+	if err == nil {
+		err = json.Unmarshal(data, target)
+	}
+	if err != nil {
+		return Wrap(CodeVerificationFailed, err, "Predicate does not match its predicate type")
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package attestation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/remediation"
+)
+
+// VerifyUpload checks a bundle submitted for storage before it is accepted:
+// the statement must name the claimed subject's digest, and the bundle must
+// verify against the server's trust root and policy. The trust root the
+// bundle carries is ignored, since the uploader chose it.
+func VerifyUpload(bundle *Bundle, subject Subject, trust TrustRoot, policy IdentityPolicy) (*VerificationResult, error) {
+	digest, err := matchSubject(bundle, subject)
+	if err != nil {
+		result := &VerificationResult{Subject: subject.Name, VerifiedAt: time.Now().UTC()}
+		result.record(CheckSubjectDigest, err, "")
+		result.Fail(err, remediation.Context{Target: subject.Name, Issuer: policy.Issuer})
+		return result, err
+	}
+
+	result, err := VerifyBundle(bundle, trust, policy)
+	result.Subject = subject.Name
+	matched := Check{Name: CheckSubjectDigest, Status: CheckPassed, Detail: "Statement names " + digest}
+	result.Checks = append([]Check{matched}, result.Checks...)
+	return result, err
+}
+
+// matchSubject finds a statement subject carrying one of the subject's
+// digests, returning the matched digest in alg:hex form
+func matchSubject(bundle *Bundle, subject Subject) (string, error) {
+	if err := subject.Validate(); err != nil {
+		return "", err
+	}
+	if bundle == nil || bundle.Envelope == nil {
+		return "", Errorf(CodeVerificationFailed, "Bundle is missing its envelope")
+	}
+	statement, err := bundle.Envelope.Statement()
+	if err != nil {
+		return "", err
+	}
+
+	for _, candidate := range statement.Subject {
+		for algorithm, value := range subject.Digest {
+			if strings.EqualFold(candidate.Digest[algorithm], value) {
+				return fmt.Sprintf("%s:%s", algorithm, value), nil
+			}
+		}
+	}
+	return "", Errorf(CodeSubjectMismatch, "Attestation is not about %s", subject)
+}
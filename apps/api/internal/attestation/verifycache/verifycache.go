@@ -0,0 +1,139 @@
+// Package verifycache caches attestation verification results in the
+// HierarchicalCache, so pipelines verifying the same digest against the same
+// policy on every run skip repeated signature, certificate and transparency
+// log checks
+package verifycache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/trustroot"
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+)
+
+// Cache namespaces: results are keyed under the current generation, which
+// invalidation replaces so every earlier result is unreachable
+const (
+	namespace     = "verification"
+	generationKey = "verification-generation:current"
+)
+
+// Config tunes result caching
+type Config struct {
+	TTL time.Duration // How long a successful verification is reused
+}
+
+// DefaultConfig reuses results for an hour
+func DefaultConfig() Config {
+	return Config{TTL: time.Hour}
+}
+
+// Cache reuses successful verification results
+type Cache struct {
+	config Config
+	cache  *cache.HierarchicalCache
+}
+
+// entry is a cached result with its own expiry, since adaptive tuning may keep
+// the cache entry longer than the configured TTL
+type entry struct {
+	Result    *attestation.VerificationResult `json:"result"`
+	ExpiresAt time.Time                       `json:"expires_at"`
+}
+
+// New creates a verification result cache
+func New(config Config, hierCache *cache.HierarchicalCache) *Cache {
+	if config.TTL <= 0 {
+		config.TTL = DefaultConfig().TTL
+	}
+	return &Cache{config: config, cache: hierCache}
+}
+
+// Watch invalidates cached results whenever the manager's trust root rotates
+func (c *Cache) Watch(manager *trustroot.Manager) {
+	manager.OnRotate(c.Invalidate)
+}
+
+// Verify returns the cached result for the subject digest and policy, or runs
+// verify and caches its result if it succeeded. Failures aren't cached, so a
+// newly published attestation is picked up on the next run.
+func (c *Cache) Verify(ctx context.Context, subjectDigest string, policy attestation.IdentityPolicy, verify func(ctx context.Context) (*attestation.VerificationResult, error)) (*attestation.VerificationResult, error) {
+	key, err := c.key(ctx, subjectDigest, policy)
+	if err != nil {
+		return verify(ctx)
+	}
+
+	if value, found := c.cache.Get(ctx, key); found {
+		if result, ok := decodeEntry(value); ok {
+			return result, nil
+		}
+	}
+
+	result, err := verify(ctx)
+	if err != nil || result == nil || !result.Valid {
+		return result, err
+	}
+
+	data, err := json.Marshal(entry{Result: result, ExpiresAt: time.Now().Add(c.config.TTL)})
+	if err == nil {
+		c.cache.Set(ctx, key, string(data), c.config.TTL)
+	}
+	return result, nil
+}
+
+// Invalidate discards every cached result by starting a new generation
+func (c *Cache) Invalidate(ctx context.Context) error {
+	generation := strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := c.cache.Set(ctx, generationKey, generation, 365*24*time.Hour); err != nil {
+		return fmt.Errorf("failed to invalidate verification results: %w", err)
+	}
+	return nil
+}
+
+// key derives the cache key from the current generation, the subject digest
+// and a hash of the policy
+func (c *Cache) key(ctx context.Context, subjectDigest string, policy attestation.IdentityPolicy) (string, error) {
+	policyHash, err := PolicyHash(policy)
+	if err != nil {
+		return "", err
+	}
+
+	generation := "0"
+	if value, found := c.cache.Get(ctx, generationKey); found {
+		if current, ok := value.(string); ok {
+			generation = current
+		}
+	}
+	return namespace + ":" + generation + ":" + subjectDigest + ":" + policyHash, nil
+}
+
+// PolicyHash identifies a policy by the SHA-256 of its canonical JSON encoding
+func PolicyHash(policy attestation.IdentityPolicy) (string, error) {
+	data, err := attestation.CanonicalJSON(policy)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode policy: %w", err)
+	}
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// decodeEntry returns an unexpired cached result, marked as served from the cache
+func decodeEntry(value interface{}) (*attestation.VerificationResult, bool) {
+	data, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	var cached entry
+	if err := json.Unmarshal([]byte(data), &cached); err != nil || cached.Result == nil || time.Now().After(cached.ExpiresAt) {
+		return nil, false
+	}
+	cached.Result.Cached = true
+	return cached.Result, true
+}
@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TTLBounds limits the TTLs adaptive tuning may choose for a namespace; Max is
+// the namespace's staleness tolerance
+type TTLBounds struct {
+	Min time.Duration `json:"min"`
+	Max time.Duration `json:"max"`
+}
+
+// DefaultTTLBounds returns bounds for the namespaces Keystone caches: published
+// CVEs rarely change, while rate limits and advisory lists go stale quickly, and
+// the Sigstore trust root is refreshed at least daily
+func DefaultTTLBounds() map[string]TTLBounds {
+	return map[string]TTLBounds{
+		"cve":        {Min: time.Hour, Max: 7 * 24 * time.Hour},
+		"maven-sig":  {Min: time.Hour, Max: 7 * 24 * time.Hour},
+		"advisories": {Min: time.Minute, Max: time.Hour},
+		"ratelimit":  {Min: 10 * time.Second, Max: 5 * time.Minute},
+		"tuf":        {Min: time.Hour, Max: 24 * time.Hour},
+	}
+}
+
+// AdaptiveConfig tunes how TTLs respond to observed behaviour
+type AdaptiveConfig struct {
+	Bounds         map[string]TTLBounds // Per-namespace bounds; others get [ttl/4, ttl*4]
+	Window         int                  // Refreshes observed before each adjustment
+	StableRatio    float64              // Change ratio at or below which TTLs grow
+	VolatileRatio  float64              // Change ratio at or above which TTLs shrink
+	TargetHitRatio float64              // Stable namespaces stop growing once reached
+	GrowFactor     float64
+	ShrinkFactor   float64
+	MaxTrackedKeys int // Bound on remembered value fingerprints
+}
+
+// DefaultAdaptiveConfig returns conservative tuning defaults
+func DefaultAdaptiveConfig() AdaptiveConfig {
+	return AdaptiveConfig{
+		Bounds:         DefaultTTLBounds(),
+		Window:         20,
+		StableRatio:    0.1,
+		VolatileRatio:  0.5,
+		TargetHitRatio: 0.95,
+		GrowFactor:     1.5,
+		ShrinkFactor:   0.5,
+		MaxTrackedKeys: 10000,
+	}
+}
+
+// NamespaceTTL reports the TTL chosen for a namespace and why
+type NamespaceTTL struct {
+	Namespace   string        `json:"namespace"`
+	TTL         time.Duration `json:"ttl"`
+	Bounds      TTLBounds     `json:"bounds"`
+	HitRatio    float64       `json:"hit_ratio"`
+	ChangeRatio float64       `json:"change_ratio"`
+	Refreshes   int64         `json:"refreshes"`
+	Adjustments int64         `json:"adjustments"`
+	Reason      string        `json:"reason"`
+}
+
+// namespaceStats accumulates observations for one namespace
+type namespaceStats struct {
+	ttl         time.Duration
+	bounds      TTLBounds
+	hits        int64
+	misses      int64
+	refreshes   int64
+	changes     int64
+	window      int
+	windowDiffs int
+	adjustments int64
+	changeRatio float64
+	reason      string
+}
+
+// AdaptiveTTL chooses per-namespace TTLs from hit rates and how often refreshed
+// values actually change. A namespace is the key prefix before the first ':'.
+type AdaptiveTTL struct {
+	config       AdaptiveConfig
+	mutex        sync.Mutex
+	namespaces   map[string]*namespaceStats
+	fingerprints map[string][sha256.Size]byte
+}
+
+// NewAdaptiveTTL creates an adaptive TTL policy
+func NewAdaptiveTTL(config AdaptiveConfig) *AdaptiveTTL {
+	if config.Window <= 0 {
+		config.Window = DefaultAdaptiveConfig().Window
+	}
+	if config.GrowFactor <= 1 {
+		config.GrowFactor = DefaultAdaptiveConfig().GrowFactor
+	}
+	if config.ShrinkFactor <= 0 || config.ShrinkFactor >= 1 {
+		config.ShrinkFactor = DefaultAdaptiveConfig().ShrinkFactor
+	}
+	return &AdaptiveTTL{
+		config:       config,
+		namespaces:   make(map[string]*namespaceStats),
+		fingerprints: make(map[string][sha256.Size]byte),
+	}
+}
+
+// Namespace returns the namespace of a cache key
+func Namespace(key string) string {
+	if i := strings.IndexByte(key, ':'); i > 0 {
+		return key[:i]
+	}
+	return "default"
+}
+
+// stats returns the namespace's stats, seeding its TTL from the first requested TTL
+func (a *AdaptiveTTL) stats(namespace string, requested time.Duration) *namespaceStats {
+	stats, ok := a.namespaces[namespace]
+	if ok {
+		return stats
+	}
+
+	bounds, ok := a.config.Bounds[namespace]
+	if !ok {
+		bounds = TTLBounds{Min: requested / 4, Max: requested * 4}
+	}
+	stats = &namespaceStats{ttl: clampTTL(requested, bounds), bounds: bounds, reason: "initial"}
+	a.namespaces[namespace] = stats
+	return stats
+}
+
+// TTL records a write of value under key and returns the TTL to store it with
+func (a *AdaptiveTTL) TTL(key string, value interface{}, requested time.Duration) time.Duration {
+	fingerprint, ok := fingerprintValue(value)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	stats := a.stats(Namespace(key), requested)
+	if !ok {
+		return stats.ttl
+	}
+
+	if previous, seen := a.fingerprints[key]; seen {
+		stats.refreshes++
+		stats.window++
+		if previous != fingerprint {
+			stats.changes++
+			stats.windowDiffs++
+		}
+		if stats.window >= a.config.Window {
+			a.adjust(stats)
+		}
+	}
+
+	if len(a.fingerprints) >= a.config.MaxTrackedKeys && a.config.MaxTrackedKeys > 0 {
+		a.fingerprints = make(map[string][sha256.Size]byte)
+	}
+	a.fingerprints[key] = fingerprint
+	return stats.ttl
+}
+
+// adjust lengthens or shortens the namespace TTL from the last window
+func (a *AdaptiveTTL) adjust(stats *namespaceStats) {
+	stats.changeRatio = float64(stats.windowDiffs) / float64(stats.window)
+	hitRatio := stats.hitRatio()
+	stats.window, stats.windowDiffs = 0, 0
+
+	previous := stats.ttl
+	switch {
+	case stats.changeRatio >= a.config.VolatileRatio:
+		stats.ttl = clampTTL(time.Duration(float64(stats.ttl)*a.config.ShrinkFactor), stats.bounds)
+		stats.reason = "volatile: refreshed values usually changed"
+	case stats.changeRatio <= a.config.StableRatio && hitRatio < a.config.TargetHitRatio:
+		stats.ttl = clampTTL(time.Duration(float64(stats.ttl)*a.config.GrowFactor), stats.bounds)
+		stats.reason = "stable: refreshed values rarely changed"
+	case stats.changeRatio <= a.config.StableRatio:
+		stats.reason = "stable: hit ratio target reached"
+	default:
+		stats.reason = "mixed: holding"
+	}
+
+	if stats.ttl != previous {
+		stats.adjustments++
+	} else if stats.ttl == stats.bounds.Min || stats.ttl == stats.bounds.Max {
+		stats.reason += " (at bound)"
+	}
+}
+
+// ObserveGet records a cache lookup outcome for the key's namespace
+func (a *AdaptiveTTL) ObserveGet(key string, hit bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	stats, ok := a.namespaces[Namespace(key)]
+	if !ok {
+		return
+	}
+	if hit {
+		stats.hits++
+	} else {
+		stats.misses++
+	}
+}
+
+// Report returns the chosen TTL for every namespace, sorted by name
+func (a *AdaptiveTTL) Report() []NamespaceTTL {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	report := make([]NamespaceTTL, 0, len(a.namespaces))
+	for namespace, stats := range a.namespaces {
+		report = append(report, NamespaceTTL{
+			Namespace:   namespace,
+			TTL:         stats.ttl,
+			Bounds:      stats.bounds,
+			HitRatio:    stats.hitRatio(),
+			ChangeRatio: stats.changeRatio,
+			Refreshes:   stats.refreshes,
+			Adjustments: stats.adjustments,
+			Reason:      stats.reason,
+		})
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Namespace < report[j].Namespace })
+	return report
+}
+
+func (s *namespaceStats) hitRatio() float64 {
+	if total := s.hits + s.misses; total > 0 {
+		return float64(s.hits) / float64(total)
+	}
+	return 0
+}
+
+// clampTTL keeps a TTL within bounds
+func clampTTL(ttl time.Duration, bounds TTLBounds) time.Duration {
+	if bounds.Min > 0 && ttl < bounds.Min {
+		return bounds.Min
+	}
+	if bounds.Max > 0 && ttl > bounds.Max {
+		return bounds.Max
+	}
+	return ttl
+}
+
+// fingerprintValue hashes the JSON form of a value, as stored in L2
+func fingerprintValue(value interface{}) ([sha256.Size]byte, bool) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256(data), true
+}
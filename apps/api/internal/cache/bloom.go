@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// BloomConfig sizes the L2 existence filter
+type BloomConfig struct {
+	ExpectedKeys      int     // Keys the filter is sized for; it grows on rebuild
+	FalsePositiveRate float64 // Target rate of lookups that still reach SQLite for absent keys
+}
+
+// DefaultBloomConfig returns a filter sized for 100k keys at 1% false positives
+func DefaultBloomConfig() BloomConfig {
+	return BloomConfig{ExpectedKeys: 100000, FalsePositiveRate: 0.01}
+}
+
+// BloomFilter is a probabilistic set: MayContain never misses an added key but
+// may report keys that were never added. It is not safe for concurrent use.
+type BloomFilter struct {
+	bits []uint64
+	m    uint64 // Bits
+	k    uint32 // Hash functions
+}
+
+// NewBloomFilter sizes a filter for n keys at false positive rate p
+func NewBloomFilter(n int, p float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = DefaultBloomConfig().FalsePositiveRate
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) / 64 * 64
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{bits: make([]uint64, m/64), m: m, k: k}
+}
+
+// Add records a key
+func (b *BloomFilter) Add(key string) {
+	h1, h2 := bloomHashes(key)
+	for i := uint32(0); i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// MayContain reports whether a key may have been added
+func (b *BloomFilter) MayContain(key string) bool {
+	h1, h2 := bloomHashes(key)
+	for i := uint32(0); i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// FalsePositiveRate estimates the current false positive rate from the share of set bits
+func (b *BloomFilter) FalsePositiveRate() float64 {
+	var set int
+	for _, word := range b.bits {
+		set += bits.OnesCount64(word)
+	}
+	return math.Pow(float64(set)/float64(b.m), float64(b.k))
+}
+
+// bloomFilterMagic prefixes serialized filters
+const bloomFilterMagic = "KBF1"
+
+// MarshalBinary serializes the filter
+func (b *BloomFilter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, 16+len(b.bits)*8)
+	data = append(data, bloomFilterMagic...)
+	data = binary.LittleEndian.AppendUint32(data, b.k)
+	data = binary.LittleEndian.AppendUint64(data, b.m)
+	for _, word := range b.bits {
+		data = binary.LittleEndian.AppendUint64(data, word)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary restores a filter serialized by MarshalBinary
+func (b *BloomFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 || string(data[:4]) != bloomFilterMagic {
+		return errors.New("not a serialized bloom filter")
+	}
+	k := binary.LittleEndian.Uint32(data[4:])
+	m := binary.LittleEndian.Uint64(data[8:])
+	if k == 0 || m == 0 || m%64 != 0 || uint64(len(data)-16) != m/8 {
+		return fmt.Errorf("bloom filter header does not match its %d bytes", len(data))
+	}
+
+	b.k, b.m = k, m
+	b.bits = make([]uint64, m/64)
+	for i := range b.bits {
+		b.bits[i] = binary.LittleEndian.Uint64(data[16+i*8:])
+	}
+	return nil
+}
+
+// bloomHashes derives the two hashes double hashing combines into k indexes
+func bloomHashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	return sum & 0xffffffff, sum>>32 | 1
+}
+
+// l2Filter guards the filter in front of L2. While a rebuild scans SQLite,
+// writes go to both the current and the replacement filter.
+type l2Filter struct {
+	config  BloomConfig
+	mutex   sync.RWMutex
+	filter  *BloomFilter
+	pending *BloomFilter
+}
+
+// initL2Filter restores the filter from its last snapshot, adding keys written
+// since, or builds it from L2 when there is no usable snapshot
+func (h *HierarchicalCache) initL2Filter() error {
+	_, err := h.db.Exec(`
+		CREATE TABLE IF NOT EXISTS cache_filter_snapshots (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			data BLOB NOT NULL,
+			created_at TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	var createdAt string
+	err = h.db.QueryRow(`SELECT data, created_at FROM cache_filter_snapshots WHERE id = 1`).Scan(&data, &createdAt)
+	if err == nil {
+		filter := &BloomFilter{}
+		if err := filter.UnmarshalBinary(data); err == nil {
+			// created_at has second precision, so the snapshot second is replayed
+			if err := h.scanL2Keys(context.Background(), filter, createdAt); err == nil {
+				h.l2Filter.filter = filter
+				return nil
+			}
+		}
+		log.Printf("Discarding unusable L2 filter snapshot")
+	}
+
+	return h.rebuildL2Filter(context.Background())
+}
+
+// scanL2Keys adds keys written at or after since ("" for all) to filter
+func (h *HierarchicalCache) scanL2Keys(ctx context.Context, filter *BloomFilter, since string) error {
+	rows, err := h.db.QueryContext(ctx, `SELECT key FROM cache_entries WHERE created_at >= ?`, since)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return err
+		}
+		h.l2Filter.mutex.Lock()
+		filter.Add(key)
+		h.l2Filter.mutex.Unlock()
+	}
+	return rows.Err()
+}
+
+// rebuildL2Filter replaces the filter with one sized for the current L2,
+// dropping bits left behind by expired and deleted keys
+func (h *HierarchicalCache) rebuildL2Filter(ctx context.Context) error {
+	var rows int
+	if err := h.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM cache_entries`).Scan(&rows); err != nil {
+		return err
+	}
+	capacity := h.l2Filter.config.ExpectedKeys
+	if 2*rows > capacity {
+		capacity = 2 * rows
+	}
+	replacement := NewBloomFilter(capacity, h.l2Filter.config.FalsePositiveRate)
+
+	h.l2Filter.mutex.Lock()
+	h.l2Filter.pending = replacement
+	h.l2Filter.mutex.Unlock()
+
+	err := h.scanL2Keys(ctx, replacement, "")
+
+	h.l2Filter.mutex.Lock()
+	defer h.l2Filter.mutex.Unlock()
+	h.l2Filter.pending = nil
+	if err != nil {
+		return fmt.Errorf("failed to rebuild L2 filter: %w", err)
+	}
+	h.l2Filter.filter = replacement
+	return nil
+}
+
+// l2MayContain reports whether L2 may hold key; true when the filter is disabled
+func (h *HierarchicalCache) l2MayContain(key string) bool {
+	if h.config.L2Filter == nil {
+		return true
+	}
+
+	h.l2Filter.mutex.RLock()
+	defer h.l2Filter.mutex.RUnlock()
+	return h.l2Filter.filter == nil || h.l2Filter.filter.MayContain(key)
+}
+
+// addToL2Filter records a key about to be written to L2
+func (h *HierarchicalCache) addToL2Filter(key string) {
+	if h.config.L2Filter == nil {
+		return
+	}
+
+	h.l2Filter.mutex.Lock()
+	defer h.l2Filter.mutex.Unlock()
+	if h.l2Filter.filter != nil {
+		h.l2Filter.filter.Add(key)
+	}
+	if h.l2Filter.pending != nil {
+		h.l2Filter.pending.Add(key)
+	}
+}
+
+// maintainL2Filter rebuilds a saturated filter and snapshots it
+func (h *HierarchicalCache) maintainL2Filter() {
+	if h.config.L2Filter == nil {
+		return
+	}
+
+	h.l2Filter.mutex.RLock()
+	saturated := h.l2Filter.filter.FalsePositiveRate() > 2*h.l2Filter.config.FalsePositiveRate
+	h.l2Filter.mutex.RUnlock()
+	if saturated {
+		if err := h.rebuildL2Filter(context.Background()); err != nil {
+			log.Printf("Failed to rebuild L2 filter: %v", err)
+		}
+	}
+
+	if err := h.saveL2Filter(); err != nil {
+		log.Printf("Failed to snapshot L2 filter: %v", err)
+	}
+}
+
+// saveL2Filter persists the filter so restarts skip the full L2 scan
+func (h *HierarchicalCache) saveL2Filter() error {
+	if h.config.L2Filter == nil {
+		return nil
+	}
+
+	// Taken before serializing: keys written from here on are replayed on load
+	createdAt := time.Now().UTC().Format("2006-01-02 15:04:05")
+	h.l2Filter.mutex.RLock()
+	data, err := h.l2Filter.filter.MarshalBinary()
+	h.l2Filter.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	_, err = h.db.Exec(`INSERT OR REPLACE INTO cache_filter_snapshots (id, data, created_at) VALUES (1, ?, ?)`, data, createdAt)
+	return err
+}
@@ -0,0 +1,507 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Peer endpoints served by PeerHandler
+const (
+	peerGossipPath = "/cache/v1/gossip"
+	peerKeysPath   = "/cache/v1/keys"
+)
+
+// DistributedConfig enables the distributed L1 mode: replicas gossip membership
+// and route hot keys to the replica owning them on a consistent hash ring, so a
+// replica set fetches each hot key upstream once instead of once per replica
+type DistributedConfig struct {
+	NodeID          string        // Unique replica name; defaults to AdvertiseAddr
+	AdvertiseAddr   string        // Base URL peers reach this replica's PeerHandler at
+	Seeds           []string      // Base URLs of replicas to join through
+	Secret          string        // Shared bearer token peers authenticate with
+	GossipInterval  time.Duration // How often membership is exchanged with a random peer
+	DeadAfter       time.Duration // Members not heard from this long leave the ring
+	VirtualNodes    int           // Ring points per member
+	HotKeyThreshold int           // Lookups per window before a key is routed; 0 routes every key
+	HotKeyWindow    time.Duration
+	PeerTimeout     time.Duration
+	HTTPClient      *http.Client
+}
+
+// DefaultDistributedConfig returns defaults for a replica advertised at addr
+func DefaultDistributedConfig(addr string, seeds ...string) DistributedConfig {
+	return DistributedConfig{
+		AdvertiseAddr:   addr,
+		Seeds:           seeds,
+		GossipInterval:  time.Second,
+		DeadAfter:       10 * time.Second,
+		VirtualNodes:    100,
+		HotKeyThreshold: 10,
+		HotKeyWindow:    time.Minute,
+		PeerTimeout:     2 * time.Second,
+	}
+}
+
+// Member is a replica in the distributed L1
+type Member struct {
+	ID        string `json:"id"`
+	Addr      string `json:"addr"`
+	Heartbeat uint64 `json:"heartbeat"` // Incremented by the member every gossip round
+}
+
+// memberState is a member and when its heartbeat last advanced locally
+type memberState struct {
+	Member
+	updated time.Time
+}
+
+// tombstone records a reaped member's last heartbeat
+type tombstone struct {
+	heartbeat uint64
+	reaped    time.Time
+}
+
+// distributedL1 tracks membership and routes hot keys to their owners
+type distributedL1 struct {
+	config  DistributedConfig
+	self    Member
+	mutex   sync.RWMutex
+	members map[string]*memberState
+	dead    map[string]tombstone // Reaped members, so stale views can't revive them
+	ring    *HashRing
+	hot     hotKeys
+}
+
+func newDistributedL1(config DistributedConfig) (*distributedL1, error) {
+	if config.AdvertiseAddr == "" {
+		return nil, fmt.Errorf("distributed L1 requires an advertise address")
+	}
+	defaults := DefaultDistributedConfig(config.AdvertiseAddr)
+	if config.NodeID == "" {
+		config.NodeID = config.AdvertiseAddr
+	}
+	if config.GossipInterval <= 0 {
+		config.GossipInterval = defaults.GossipInterval
+	}
+	if config.DeadAfter <= 0 {
+		config.DeadAfter = defaults.DeadAfter
+	}
+	if config.HotKeyWindow <= 0 {
+		config.HotKeyWindow = defaults.HotKeyWindow
+	}
+	if config.PeerTimeout <= 0 {
+		config.PeerTimeout = defaults.PeerTimeout
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: config.PeerTimeout}
+	}
+
+	d := &distributedL1{
+		config:  config,
+		self:    Member{ID: config.NodeID, Addr: strings.TrimSuffix(config.AdvertiseAddr, "/")},
+		members: make(map[string]*memberState),
+		dead:    make(map[string]tombstone),
+		ring:    NewHashRing(config.VirtualNodes),
+		hot:     hotKeys{threshold: config.HotKeyThreshold, window: config.HotKeyWindow},
+	}
+	d.ring.Add(d.self.ID)
+	return d, nil
+}
+
+// route counts a lookup of key and returns its owner when the key is hot and
+// owned by another replica
+func (d *distributedL1) route(key string) (Member, bool) {
+	if !d.hot.hit(key) {
+		return Member{}, false
+	}
+	return d.owner(key)
+}
+
+// owner returns the replica owning key when it isn't this one
+func (d *distributedL1) owner(key string) (Member, bool) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	id := d.ring.Owner(key)
+	member, ok := d.members[id]
+	if !ok {
+		return Member{}, false
+	}
+	return member.Member, true
+}
+
+// Members returns the live replicas, this one included, sorted by ID
+func (d *distributedL1) Members() []Member {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	members := []Member{d.self}
+	for _, member := range d.members {
+		members = append(members, member.Member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+	return members
+}
+
+// merge folds a peer's membership view into ours
+func (d *distributedL1) merge(view []Member) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	for _, member := range view {
+		if member.ID == "" || member.ID == d.self.ID {
+			continue
+		}
+		known, exists := d.members[member.ID]
+		if !exists {
+			if dead, reaped := d.dead[member.ID]; reaped && member.Heartbeat <= dead.heartbeat {
+				continue
+			}
+			delete(d.dead, member.ID)
+			d.members[member.ID] = &memberState{Member: member, updated: now}
+			d.ring.Add(member.ID)
+			continue
+		}
+		if member.Heartbeat > known.Heartbeat {
+			known.Member = member
+			known.updated = now
+		}
+	}
+}
+
+// reap removes members whose heartbeat hasn't advanced within DeadAfter, and
+// forgets tombstones once every peer has had time to reap the same member
+func (d *distributedL1) reap() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	for id, dead := range d.dead {
+		if now.Sub(dead.reaped) > 10*d.config.DeadAfter {
+			delete(d.dead, id)
+		}
+	}
+
+	cutoff := now.Add(-d.config.DeadAfter)
+	for id, member := range d.members {
+		if member.updated.Before(cutoff) {
+			delete(d.members, id)
+			d.dead[id] = tombstone{heartbeat: member.Heartbeat, reaped: now}
+			d.ring.Remove(id)
+			log.Printf("Cache peer %s left the ring", id)
+		}
+	}
+}
+
+// gossip advances our heartbeat and exchanges views with a random member, or
+// a seed while we know of none
+func (d *distributedL1) gossip(ctx context.Context) {
+	d.mutex.Lock()
+	d.self.Heartbeat++
+	targets := make([]string, 0, len(d.members))
+	for _, member := range d.members {
+		targets = append(targets, member.Addr)
+	}
+	d.mutex.Unlock()
+
+	if len(targets) == 0 {
+		for _, seed := range d.config.Seeds {
+			if seed = strings.TrimSuffix(seed, "/"); seed != d.self.Addr {
+				targets = append(targets, seed)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	target := targets[rand.Intn(len(targets))]
+
+	var view []Member
+	if err := d.call(ctx, http.MethodPost, target+peerGossipPath, d.Members(), &view); err != nil {
+		log.Printf("Cache gossip with %s failed: %v", target, err)
+		return
+	}
+	d.merge(view)
+}
+
+// fetch asks a key's owner for its value, letting the owner load it on a miss
+func (d *distributedL1) fetch(ctx context.Context, owner Member, key string) (interface{}, bool, error) {
+	var value interface{}
+	err := d.call(ctx, http.MethodGet, owner.Addr+peerKeysPath+"?key="+url.QueryEscape(key), nil, &value)
+	if errors.Is(err, errPeerMiss) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// store writes a value into the owner's L1
+func (d *distributedL1) store(ctx context.Context, owner Member, key string, value interface{}, ttl time.Duration) error {
+	target := fmt.Sprintf("%s%s?key=%s&ttl=%s", owner.Addr, peerKeysPath, url.QueryEscape(key), ttl)
+	return d.call(ctx, http.MethodPut, target, value, nil)
+}
+
+// errPeerMiss reports that the owner has no value for a key
+var errPeerMiss = errors.New("peer cache miss")
+
+// call sends an authenticated JSON request to a peer
+func (d *distributedL1) call(ctx context.Context, method, target string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.config.PeerTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.config.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+d.config.Secret)
+	}
+
+	resp, err := d.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errPeerMiss
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("peer returned status %d", resp.StatusCode)
+	case out == nil:
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(out)
+}
+
+// authorized checks a peer request carries the shared secret
+func (d *distributedL1) authorized(r *http.Request) bool {
+	if d.config.Secret == "" {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(d.config.Secret)) == 1
+}
+
+// hotKeys counts lookups per key over a fixed window
+type hotKeys struct {
+	threshold int
+	window    time.Duration
+	mutex     sync.Mutex
+	counts    map[string]int
+	started   time.Time
+}
+
+// maxHotKeys bounds the counted keys between window resets
+const maxHotKeys = 10000
+
+// hit records a lookup and reports whether the key is hot
+func (h *hotKeys) hit(key string) bool {
+	if h.threshold <= 0 {
+		return true
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := time.Now()
+	if h.counts == nil || now.Sub(h.started) > h.window || len(h.counts) >= maxHotKeys {
+		h.counts = make(map[string]int)
+		h.started = now
+	}
+	h.counts[key]++
+	return h.counts[key] >= h.threshold
+}
+
+// isHot reports whether a key is hot without counting a lookup
+func (h *hotKeys) isHot(key string) bool {
+	if h.threshold <= 0 {
+		return true
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.counts[key] >= h.threshold
+}
+
+// forwardedContextKey marks lookups made on behalf of a peer; they are never
+// routed again, so replicas with diverging membership views can't bounce a key
+// between each other
+type forwardedContextKey struct{}
+
+func isForwarded(ctx context.Context) bool {
+	forwarded, _ := ctx.Value(forwardedContextKey{}).(bool)
+	return forwarded
+}
+
+// routeToOwner returns the owner of a hot key this replica doesn't own
+func (h *HierarchicalCache) routeToOwner(ctx context.Context, key string) (Member, bool) {
+	if h.distributed == nil || isForwarded(ctx) {
+		return Member{}, false
+	}
+	return h.distributed.route(key)
+}
+
+// getFromOwner fetches a hot key from its owner and keeps a local L1 copy
+func (h *HierarchicalCache) getFromOwner(ctx context.Context, owner Member, key string) (interface{}, bool) {
+	value, found, err := h.distributed.fetch(ctx, owner, key)
+	if err != nil {
+		h.metrics.mutex.Lock()
+		h.metrics.PeerErrors++
+		h.metrics.mutex.Unlock()
+		log.Printf("Failed to fetch %s from cache peer %s: %v", key, owner.ID, err)
+		return nil, false
+	}
+	if !found {
+		return nil, false
+	}
+
+	h.metrics.mutex.Lock()
+	h.metrics.PeerHits++
+	h.metrics.mutex.Unlock()
+	h.setToL1(key, value, h.config.L1TTL)
+	return value, true
+}
+
+// setToOwner mirrors a write of a hot key into its owner's L1
+func (h *HierarchicalCache) setToOwner(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	if h.distributed == nil || isForwarded(ctx) || !h.distributed.hot.isHot(key) {
+		return
+	}
+	owner, remote := h.distributed.owner(key)
+	if !remote {
+		return
+	}
+	if err := h.distributed.store(ctx, owner, key, value, ttl); err != nil {
+		h.metrics.mutex.Lock()
+		h.metrics.PeerErrors++
+		h.metrics.mutex.Unlock()
+		log.Printf("Failed to store %s on cache peer %s: %v", key, owner.ID, err)
+	}
+}
+
+// Members returns the replicas of the distributed L1, or nil when it is disabled
+func (h *HierarchicalCache) Members() []Member {
+	if h.distributed == nil {
+		return nil
+	}
+	return h.distributed.Members()
+}
+
+// PeerHandler serves the endpoints replicas gossip and route keys through; it
+// must be reachable at DistributedConfig.AdvertiseAddr
+func (h *HierarchicalCache) PeerHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(peerGossipPath, h.handleGossip)
+	mux.HandleFunc(peerKeysPath, h.handlePeerKey)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.distributed == nil {
+			http.Error(w, "distributed cache disabled", http.StatusNotFound)
+			return
+		}
+		if !h.distributed.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// handleGossip merges a peer's membership view and replies with ours
+func (h *HierarchicalCache) handleGossip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var view []Member
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&view); err != nil {
+		http.Error(w, "invalid membership view", http.StatusBadRequest)
+		return
+	}
+	h.distributed.merge(view)
+	writePeerJSON(w, h.distributed.Members())
+}
+
+// handlePeerKey serves lookups and writes routed to this replica as owner
+func (h *HierarchicalCache) handlePeerKey(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "key required", http.StatusBadRequest)
+		return
+	}
+	ctx := context.WithValue(r.Context(), forwardedContextKey{}, true)
+
+	switch r.Method {
+	case http.MethodGet:
+		value, err := h.Load(ctx, key)
+		if err != nil {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		writePeerJSON(w, value)
+	case http.MethodPut:
+		ttl, err := time.ParseDuration(r.URL.Query().Get("ttl"))
+		if err != nil || ttl <= 0 {
+			ttl = h.config.L1TTL
+		}
+		var value interface{}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 10<<20)).Decode(&value); err != nil {
+			http.Error(w, "invalid value", http.StatusBadRequest)
+			return
+		}
+		h.setToL1(key, value, ttl)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writePeerJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write cache peer response: %v", err)
+	}
+}
+
+// gossipWorker exchanges membership until the cache is closed
+func (h *HierarchicalCache) gossipWorker() {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(h.distributed.config.GossipInterval)
+	defer ticker.Stop()
+
+	for {
+		h.distributed.gossip(context.Background())
+		h.distributed.reap()
+		select {
+		case <-ticker.C:
+		case <-h.stopChan:
+			return
+		}
+	}
+}
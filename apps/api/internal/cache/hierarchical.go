@@ -4,11 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"sync"
 	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
 )
 
+// ErrSecretValue is returned when asked to cache a credential. Cache entries
+// are persisted and shared between jobs, so tokens are never stored.
+var ErrSecretValue = errors.New("refusing to cache a credential")
+
 // CacheLevel represents different cache levels
 type CacheLevel int
 
@@ -27,20 +36,28 @@ type CacheEntry struct {
 	Size       int64       `json:"size"`
 	AccessTime time.Time   `json:"access_time"`
 	HitCount   int64       `json:"hit_count"`
+	StaleUntil time.Time   `json:"stale_until"` // End of the stale-while-revalidate window
 }
 
 // CacheConfig holds cache configuration
 type CacheConfig struct {
-	L1MaxItems     int           // Maximum items in L1 cache
-	L1TTL          time.Duration // L1 cache TTL
-	L2TTL          time.Duration // L2 cache TTL
-	L3TTL          time.Duration // L3 cache TTL
-	EvictionPolicy string        // LRU, LFU, TTL
-	MaxMemoryMB    int64         // Maximum memory usage for L1
+	L1MaxItems     int                // Maximum items in L1 cache
+	L1TTL          time.Duration      // L1 cache TTL
+	L2TTL          time.Duration      // L2 cache TTL
+	L3TTL          time.Duration      // L3 cache TTL
+	EvictionPolicy string             // LRU, LFU, TTL
+	MaxMemoryMB    int64              // Maximum memory usage for L1
+	Adaptive       *AdaptiveConfig    // Per-namespace TTL tuning; nil uses TTLs as given
+	Distributed    *DistributedConfig // Routes hot keys to owner replicas; nil keeps L1 local
+	L2Filter       *BloomConfig       // Skips SQLite for keys never written; nil queries L2 on every miss. Leave nil when other writers share the L2 database.
+	Clock          clock.Clock        // Expires entries; defaults to the system clock
 }
 
-// DefaultCacheConfig returns default cache configuration
+// DefaultCacheConfig returns default cache configuration. It has no L2
+// filter, since the API and worker processes share one SQLite cache and a
+// filter would only know its own process's writes.
 func DefaultCacheConfig() CacheConfig {
+	adaptive := DefaultAdaptiveConfig()
 	return CacheConfig{
 		L1MaxItems:     1000,
 		L1TTL:          5 * time.Minute,
@@ -48,20 +65,26 @@ func DefaultCacheConfig() CacheConfig {
 		L3TTL:          24 * time.Hour,
 		EvictionPolicy: "LRU",
 		MaxMemoryMB:    100,
+		Adaptive:       &adaptive,
 	}
 }
 
 // HierarchicalCache implements a multi-level caching strategy
 type HierarchicalCache struct {
-	config     CacheConfig
-	l1Cache    map[string]*CacheEntry // In-memory cache
-	l1Mutex    sync.RWMutex
-	db         *sql.DB // SQLite cache
-	l3Client   L3CacheClient
-	metrics    *CacheMetrics
-	evictChan  chan string
-	stopChan   chan struct{}
-	wg         sync.WaitGroup
+	config      CacheConfig
+	l1Cache     map[string]*CacheEntry // In-memory cache
+	l1Mutex     sync.RWMutex
+	db          *sql.DB // SQLite cache
+	l3Client    L3CacheClient
+	adaptive    *AdaptiveTTL
+	loaders     loaderRegistry
+	distributed *distributedL1
+	l2Filter    l2Filter
+	metrics     *CacheMetrics
+	clock       clock.Clock
+	evictChan   chan string
+	stopChan    chan struct{}
+	wg          sync.WaitGroup
 }
 
 // L3CacheClient interface for GitHub Actions cache
@@ -73,16 +96,22 @@ type L3CacheClient interface {
 
 // CacheMetrics tracks cache performance
 type CacheMetrics struct {
-	L1Hits      int64
-	L1Misses    int64
-	L2Hits      int64
-	L2Misses    int64
-	L3Hits      int64
-	L3Misses    int64
-	Evictions   int64
-	TotalGets   int64
-	TotalSets   int64
-	mutex       sync.RWMutex
+	L1Hits        int64
+	L1Misses      int64
+	L2Hits        int64
+	L2Misses      int64
+	L3Hits        int64
+	L3Misses      int64
+	Evictions     int64
+	TotalGets     int64
+	TotalSets     int64
+	Loads         int64 // Read-through loader calls
+	LoadErrors    int64
+	StaleHits     int64 // Expired values served while revalidating
+	PeerHits      int64 // Hot keys served by their owner replica
+	PeerErrors    int64
+	L2FilterSkips int64 // L2 lookups answered by the bloom filter without querying SQLite
+	mutex         sync.RWMutex
 }
 
 // NewHierarchicalCache creates a new hierarchical cache
@@ -93,19 +122,43 @@ func NewHierarchicalCache(config CacheConfig, db *sql.DB, l3Client L3CacheClient
 		db:        db,
 		l3Client:  l3Client,
 		metrics:   &CacheMetrics{},
+		clock:     clock.OrReal(config.Clock),
 		evictChan: make(chan string, 100),
 		stopChan:  make(chan struct{}),
 	}
+	if config.Adaptive != nil {
+		cache.adaptive = NewAdaptiveTTL(*config.Adaptive)
+	}
+	if config.Distributed != nil {
+		distributed, err := newDistributedL1(*config.Distributed)
+		if err != nil {
+			return nil, err
+		}
+		cache.distributed = distributed
+	}
 
 	// Initialize L2 cache table
 	if err := cache.initL2Cache(); err != nil {
 		return nil, fmt.Errorf("failed to initialize L2 cache: %w", err)
 	}
+	if config.L2Filter != nil {
+		cache.l2Filter.config = *config.L2Filter
+		if cache.l2Filter.config.ExpectedKeys <= 0 {
+			cache.l2Filter.config.ExpectedKeys = DefaultBloomConfig().ExpectedKeys
+		}
+		if err := cache.initL2Filter(); err != nil {
+			return nil, fmt.Errorf("failed to initialize L2 filter: %w", err)
+		}
+	}
 
 	// Start background workers
 	cache.wg.Add(2)
 	go cache.evictionWorker()
 	go cache.cleanupWorker()
+	if cache.distributed != nil {
+		cache.wg.Add(1)
+		go cache.gossipWorker()
+	}
 
 	return cache, nil
 }
@@ -138,8 +191,28 @@ func (h *HierarchicalCache) initL2Cache() error {
 	return err
 }
 
-// Get retrieves a value from the cache hierarchy
+// Get retrieves a value from the cache hierarchy, populating misses from the
+// namespace's loader when one is registered
 func (h *HierarchicalCache) Get(ctx context.Context, key string) (interface{}, bool) {
+	value, err := h.Load(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrCacheMiss) {
+			log.Printf("Failed to load %s: %v", key, err)
+		}
+		return nil, false
+	}
+	return value, true
+}
+
+// observeGet feeds lookup outcomes to adaptive TTL tuning
+func (h *HierarchicalCache) observeGet(key string, hit bool) {
+	if h.adaptive != nil {
+		h.adaptive.ObserveGet(key, hit)
+	}
+}
+
+// get looks the key up level by level, promoting hits to faster levels
+func (h *HierarchicalCache) get(ctx context.Context, key string) (interface{}, bool) {
 	h.metrics.mutex.Lock()
 	h.metrics.TotalGets++
 	h.metrics.mutex.Unlock()
@@ -192,10 +265,18 @@ func (h *HierarchicalCache) Get(ctx context.Context, key string) (interface{}, b
 
 // Set stores a value in the cache hierarchy
 func (h *HierarchicalCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if secret.Is(value) {
+		return fmt.Errorf("%w under %s", ErrSecretValue, key)
+	}
+
 	h.metrics.mutex.Lock()
 	h.metrics.TotalSets++
 	h.metrics.mutex.Unlock()
 
+	if h.adaptive != nil {
+		ttl = h.adaptive.TTL(key, value, ttl)
+	}
+
 	// Set in all levels
 	h.setToL1(key, value, ttl)
 	
@@ -208,6 +289,8 @@ func (h *HierarchicalCache) Set(ctx context.Context, key string, value interface
 		fmt.Printf("Warning: failed to set L3 cache: %v\n", err)
 	}
 
+	h.setToOwner(ctx, key, value, ttl)
+
 	return nil
 }
 
@@ -222,17 +305,20 @@ func (h *HierarchicalCache) getFromL1(key string) (interface{}, bool) {
 	}
 
 	// Check expiration
-	if time.Now().After(entry.ExpiresAt) {
-		// Schedule for deletion
-		select {
-		case h.evictChan <- key:
-		default:
+	now := h.clock.Now()
+	if now.After(entry.ExpiresAt) {
+		// Schedule for deletion unless it may still be served stale
+		if now.After(entry.StaleUntil) {
+			select {
+			case h.evictChan <- key:
+			default:
+			}
 		}
 		return nil, false
 	}
 
 	// Update access statistics
-	entry.AccessTime = time.Now()
+	entry.AccessTime = now
 	entry.HitCount++
 
 	return entry.Value, true
@@ -240,6 +326,8 @@ func (h *HierarchicalCache) getFromL1(key string) (interface{}, bool) {
 
 // setToL1 stores in L1 cache
 func (h *HierarchicalCache) setToL1(key string, value interface{}, ttl time.Duration) {
+	staleWindow := h.staleWindow(key)
+
 	h.l1Mutex.Lock()
 	defer h.l1Mutex.Unlock()
 
@@ -248,14 +336,16 @@ func (h *HierarchicalCache) setToL1(key string, value interface{}, ttl time.Dura
 		h.evictFromL1()
 	}
 
+	now := h.clock.Now()
 	entry := &CacheEntry{
 		Key:        key,
 		Value:      value,
-		ExpiresAt:  time.Now().Add(ttl),
+		ExpiresAt:  now.Add(ttl),
 		Level:      L1Memory,
-		AccessTime: time.Now(),
+		AccessTime: now,
 		HitCount:   0,
 	}
+	entry.StaleUntil = entry.ExpiresAt.Add(staleWindow)
 
 	h.l1Cache[key] = entry
 }
@@ -269,7 +359,7 @@ func (h *HierarchicalCache) evictFromL1() {
 	var keyToEvict string
 	switch h.config.EvictionPolicy {
 	case "LRU":
-		oldestTime := time.Now()
+		oldestTime := h.clock.Now()
 		for key, entry := range h.l1Cache {
 			if entry.AccessTime.Before(oldestTime) {
 				oldestTime = entry.AccessTime
@@ -285,7 +375,7 @@ func (h *HierarchicalCache) evictFromL1() {
 			}
 		}
 	default: // TTL
-		earliestExpiry := time.Now().Add(24 * time.Hour)
+		earliestExpiry := h.clock.Now().Add(24 * time.Hour)
 		for key, entry := range h.l1Cache {
 			if entry.ExpiresAt.Before(earliestExpiry) {
 				earliestExpiry = entry.ExpiresAt
@@ -304,13 +394,20 @@ func (h *HierarchicalCache) evictFromL1() {
 
 // getFromL2 retrieves from SQLite cache
 func (h *HierarchicalCache) getFromL2(ctx context.Context, key string) (interface{}, bool) {
+	if !h.l2MayContain(key) {
+		h.metrics.mutex.Lock()
+		h.metrics.L2FilterSkips++
+		h.metrics.mutex.Unlock()
+		return nil, false
+	}
+
 	query := `
 		SELECT value FROM cache_entries 
-		WHERE key = ? AND expires_at > datetime('now')
+		WHERE key = ? AND expires_at > ?
 	`
 
 	var valueJSON string
-	err := h.db.QueryRowContext(ctx, query, key).Scan(&valueJSON)
+	err := h.db.QueryRowContext(ctx, query, key, h.clock.Now().UTC()).Scan(&valueJSON)
 	if err != nil {
 		return nil, false
 	}
@@ -343,9 +440,12 @@ func (h *HierarchicalCache) setToL2(ctx context.Context, key string, value inter
 		VALUES (?, ?, ?, ?)
 	`
 
-	expiresAt := time.Now().Add(ttl)
+	expiresAt := h.clock.Now().UTC().Add(ttl)
 	size := int64(len(valueJSON))
 
+	// Recorded before the write so a concurrent lookup can't skip a stored key
+	h.addToL2Filter(key)
+
 	_, err = h.db.ExecContext(ctx, insertSQL, key, string(valueJSON), expiresAt, size)
 	return err
 }
@@ -439,17 +539,19 @@ func (h *HierarchicalCache) cleanupWorker() {
 func (h *HierarchicalCache) cleanup() {
 	// Clean L1 cache
 	h.l1Mutex.Lock()
-	now := time.Now()
+	now := h.clock.Now()
 	for key, entry := range h.l1Cache {
-		if now.After(entry.ExpiresAt) {
+		if now.After(entry.StaleUntil) {
 			delete(h.l1Cache, key)
 		}
 	}
 	h.l1Mutex.Unlock()
 
 	// Clean L2 cache
-	cleanupSQL := `DELETE FROM cache_entries WHERE expires_at < datetime('now')`
-	h.db.Exec(cleanupSQL)
+	cleanupSQL := `DELETE FROM cache_entries WHERE expires_at < ?`
+	h.db.Exec(cleanupSQL, now.UTC())
+
+	h.maintainL2Filter()
 }
 
 // Stats returns cache statistics
@@ -461,6 +563,7 @@ type Stats struct {
 	L1Ratio   float64       `json:"l1_ratio"`
 	L2Ratio   float64       `json:"l2_ratio"`
 	L3Ratio   float64       `json:"l3_ratio"`
+	TTLs      []NamespaceTTL `json:"ttls,omitempty"`
 }
 
 // Stats returns current cache statistics
@@ -473,7 +576,7 @@ func (h *HierarchicalCache) Stats() *Stats {
 	h.l1Mutex.RUnlock()
 
 	var l2Size int
-	h.db.QueryRow("SELECT COUNT(*) FROM cache_entries WHERE expires_at > datetime('now')").Scan(&l2Size)
+	h.db.QueryRow("SELECT COUNT(*) FROM cache_entries WHERE expires_at > ?", h.clock.Now().UTC()).Scan(&l2Size)
 
 	totalHits := h.metrics.L1Hits + h.metrics.L2Hits + h.metrics.L3Hits
 	totalRequests := h.metrics.TotalGets
@@ -483,6 +586,9 @@ func (h *HierarchicalCache) Stats() *Stats {
 		L2Size:  l2Size,
 		Metrics: h.metrics,
 	}
+	if h.adaptive != nil {
+		stats.TTLs = h.adaptive.Report()
+	}
 
 	if totalRequests > 0 {
 		stats.HitRatio = float64(totalHits) / float64(totalRequests)
@@ -499,5 +605,9 @@ func (h *HierarchicalCache) Close() error {
 	close(h.stopChan)
 	h.wg.Wait()
 	close(h.evictChan)
+
+	if err := h.saveL2Filter(); err != nil {
+		log.Printf("Failed to snapshot L2 filter: %v", err)
+	}
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrCacheMiss is returned by Load when a key is cached nowhere and its
+// namespace has no loader
+var ErrCacheMiss = errors.New("cache miss")
+
+// Loader fetches the value for a key that missed every cache level
+type Loader func(ctx context.Context, key string) (interface{}, error)
+
+// LoaderOptions configure read-through behaviour for a namespace
+type LoaderOptions struct {
+	TTL                  time.Duration // TTL for loaded values; adaptive tuning still applies
+	StaleWhileRevalidate time.Duration // How long past expiry an L1 value may be served while it is refreshed
+	Timeout              time.Duration // Bound on background refreshes
+}
+
+// loaderRegistry maps namespaces to loaders and deduplicates concurrent loads
+type loaderRegistry struct {
+	mutex   sync.RWMutex
+	loaders map[string]registeredLoader
+	group   singleflight.Group
+}
+
+type registeredLoader struct {
+	load    Loader
+	options LoaderOptions
+}
+
+// RegisterLoader makes Get and Load populate misses in namespace (e.g. "cve" or
+// "cve:") by calling loader. Concurrent misses for a key share one load.
+func (h *HierarchicalCache) RegisterLoader(namespace string, loader Loader, options LoaderOptions) {
+	if options.TTL <= 0 {
+		options.TTL = h.config.L2TTL
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 30 * time.Second
+	}
+
+	h.loaders.mutex.Lock()
+	defer h.loaders.mutex.Unlock()
+	if h.loaders.loaders == nil {
+		h.loaders.loaders = make(map[string]registeredLoader)
+	}
+	h.loaders.loaders[strings.TrimSuffix(namespace, ":")] = registeredLoader{load: loader, options: options}
+}
+
+// loaderFor returns the loader registered for the key's namespace
+func (h *HierarchicalCache) loaderFor(key string) (registeredLoader, bool) {
+	h.loaders.mutex.RLock()
+	defer h.loaders.mutex.RUnlock()
+	loader, ok := h.loaders.loaders[Namespace(key)]
+	return loader, ok
+}
+
+// staleWindow returns how long past expiry L1 keeps a key's value
+func (h *HierarchicalCache) staleWindow(key string) time.Duration {
+	loader, ok := h.loaderFor(key)
+	if !ok {
+		return 0
+	}
+	return loader.options.StaleWhileRevalidate
+}
+
+// Load returns the cached value for key, calling the namespace loader on a miss.
+// Within the stale-while-revalidate window an expired value is returned at once
+// and refreshed in the background. In distributed mode, hot keys missing locally
+// are fetched from the replica owning them, which loads them at most once.
+func (h *HierarchicalCache) Load(ctx context.Context, key string) (interface{}, error) {
+	loader, hasLoader := h.loaderFor(key)
+	owner, routed := h.routeToOwner(ctx, key)
+
+	if hasLoader && loader.options.StaleWhileRevalidate > 0 {
+		if value, stale := h.staleFromL1(key); stale {
+			h.metrics.mutex.Lock()
+			h.metrics.StaleHits++
+			h.metrics.mutex.Unlock()
+			h.refresh(key, loader)
+			return value, nil
+		}
+	}
+
+	if value, found := h.get(ctx, key); found {
+		h.observeGet(key, true)
+		return value, nil
+	}
+	if routed {
+		if value, found := h.getFromOwner(ctx, owner, key); found {
+			h.observeGet(key, true)
+			return value, nil
+		}
+	}
+	h.observeGet(key, false)
+
+	if !hasLoader {
+		return nil, ErrCacheMiss
+	}
+
+	value, err, _ := h.loaders.group.Do(key, func() (interface{}, error) {
+		return h.load(ctx, key, loader)
+	})
+	return value, err
+}
+
+// load calls the loader and stores its result in every level
+func (h *HierarchicalCache) load(ctx context.Context, key string, loader registeredLoader) (interface{}, error) {
+	h.metrics.mutex.Lock()
+	h.metrics.Loads++
+	h.metrics.mutex.Unlock()
+
+	value, err := loader.load(ctx, key)
+	if err != nil {
+		h.metrics.mutex.Lock()
+		h.metrics.LoadErrors++
+		h.metrics.mutex.Unlock()
+		return nil, err
+	}
+
+	if err := h.Set(ctx, key, value, loader.options.TTL); err != nil {
+		log.Printf("Failed to cache loaded value for %s: %v", key, err)
+	}
+	return value, nil
+}
+
+// refresh reloads a stale key in the background, sharing any load in flight
+func (h *HierarchicalCache) refresh(key string, loader registeredLoader) {
+	h.loaders.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), loader.options.Timeout)
+		defer cancel()
+		value, err := h.load(ctx, key, loader)
+		if err != nil {
+			log.Printf("Background refresh of %s failed: %v", key, err)
+		}
+		return value, err
+	})
+}
+
+// staleFromL1 returns an expired L1 value that is still inside its stale window
+func (h *HierarchicalCache) staleFromL1(key string) (interface{}, bool) {
+	h.l1Mutex.RLock()
+	defer h.l1Mutex.RUnlock()
+
+	entry, exists := h.l1Cache[key]
+	if !exists {
+		return nil, false
+	}
+	now := h.clock.Now()
+	if !now.After(entry.ExpiresAt) || now.After(entry.StaleUntil) {
+		return nil, false
+	}
+	return entry.Value, true
+}
@@ -8,19 +8,60 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
 
-// OfflineMode represents the current offline mode state
-type OfflineMode int
+// Mode is the overall connectivity mode, derived from the critical services
+type Mode int
 
 const (
-	OnlineMode OfflineMode = iota
-	LimitedMode
-	OfflineMode
+	OnlineMode  Mode = iota
+	LimitedMode      // Some critical services are down
+	OfflineMode      // Every critical service is down
 )
 
+// String names the mode
+func (m Mode) String() string {
+	switch m {
+	case OnlineMode:
+		return "online"
+	case LimitedMode:
+		return "limited"
+	case OfflineMode:
+		return "offline"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// MarshalText encodes the mode by name
+func (m Mode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Capability is a feature whose availability depends on external services,
+// so features degrade independently rather than with the overall mode
+type Capability string
+
+const (
+	CapabilitySigning       Capability = "signing"
+	CapabilityScanning      Capability = "scanning"
+	CapabilityAdvisoryFetch Capability = "advisory-fetch"
+	CapabilityRegistry      Capability = "registry"
+)
+
+// DefaultCapabilities maps each capability to the services it needs
+func DefaultCapabilities() map[Capability][]string {
+	return map[Capability][]string{
+		CapabilitySigning:       {"sigstore", "oidc"},
+		CapabilityScanning:      {"nvd"},
+		CapabilityAdvisoryFetch: {"github"},
+		CapabilityRegistry:      {"registry"},
+	}
+}
+
 // ServiceStatus represents external service availability
 type ServiceStatus struct {
 	Name         string    `json:"name"`
@@ -29,26 +70,32 @@ type ServiceStatus struct {
 	ResponseTime int64     `json:"response_time_ms"`
 	ErrorCount   int       `json:"error_count"`
 	LastError    string    `json:"last_error,omitempty"`
+	StableSince  time.Time `json:"stable_since,omitempty"` // Start of the current run of successful probes
+	NextCheck    time.Time `json:"next_check"`
 }
 
 // OfflineDetector monitors external service availability
 type OfflineDetector struct {
-	services      map[string]ServiceConfig
-	status        map[string]*ServiceStatus
-	mode          OfflineMode
-	db            *sql.DB
-	cache         *HierarchicalCache
-	mutex         sync.RWMutex
-	stopChan      chan struct{}
-	wg            sync.WaitGroup
-	checkInterval time.Duration
+	services         map[string]ServiceConfig
+	status           map[string]*ServiceStatus
+	capabilities     map[Capability][]string
+	mode             Mode
+	db               *sql.DB
+	cache            *HierarchicalCache
+	mutex            sync.RWMutex
+	stopChan         chan struct{}
+	wg               sync.WaitGroup
+	checkInterval    time.Duration // Probe interval after a failure, and how often due probes are looked for
+	maxInterval      time.Duration // Probe interval of long-stable services
+	stableStep       time.Duration // Stability that doubles a service's probe interval
 	offlineThreshold int
+	wrapProbe        func(service string, base http.RoundTripper) http.RoundTripper
 }
 
 // ServiceConfig holds service monitoring configuration
 type ServiceConfig struct {
 	Name     string
-	URL      string
+	URL      string // Probe URL; services without one are tracked from reported calls only
 	Timeout  time.Duration
 	Critical bool // If true, affects overall offline mode determination
 }
@@ -74,19 +121,38 @@ func DefaultServices() map[string]ServiceConfig {
 			Timeout:  10 * time.Second,
 			Critical: false,
 		},
+		"oidc": {
+			Name:     "OIDC Issuer",
+			URL:      oidcDiscoveryURL(DefaultOIDCIssuer),
+			Timeout:  10 * time.Second,
+			Critical: false,
+		},
 	}
 }
 
+// DefaultOIDCIssuer is the GitHub Actions token issuer, whose tokens Fulcio
+// exchanges for signing certificates
+const DefaultOIDCIssuer = "https://token.actions.githubusercontent.com"
+
+// oidcDiscoveryURL returns the issuer's OpenID configuration document, which
+// every conforming issuer serves
+func oidcDiscoveryURL(issuer string) string {
+	return strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+}
+
 // NewOfflineDetector creates a new offline mode detector
 func NewOfflineDetector(db *sql.DB, cache *HierarchicalCache) *OfflineDetector {
 	detector := &OfflineDetector{
 		services:         DefaultServices(),
 		status:           make(map[string]*ServiceStatus),
-		mode:            OnlineMode,
-		db:              db,
-		cache:           cache,
-		stopChan:        make(chan struct{}),
-		checkInterval:   30 * time.Second,
+		capabilities:     DefaultCapabilities(),
+		mode:             OnlineMode,
+		db:               db,
+		cache:            cache,
+		stopChan:         make(chan struct{}),
+		checkInterval:    30 * time.Second,
+		maxInterval:      5 * time.Minute,
+		stableStep:       time.Hour,
 		offlineThreshold: 3, // Consider offline after 3 consecutive failures
 	}
 
@@ -102,6 +168,59 @@ func NewOfflineDetector(db *sql.DB, cache *HierarchicalCache) *OfflineDetector {
 	return detector
 }
 
+// SetSigstoreURL probes the given Fulcio endpoint instead of public Sigstore,
+// for staging and self-hosted instances. Call it before Start.
+func (d *OfflineDetector) SetSigstoreURL(url string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	service := d.services["sigstore"]
+	service.URL = url
+	d.services["sigstore"] = service
+}
+
+// SetOIDCIssuer probes the discovery document of the given OIDC issuer
+// instead of the GitHub Actions one, e.g. for GitHub Enterprise Server or a
+// customized issuer. Call it before Start.
+func (d *OfflineDetector) SetOIDCIssuer(issuer string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	service := d.services["oidc"]
+	service.URL = oidcDiscoveryURL(issuer)
+	d.services["oidc"] = service
+}
+
+// SetGitHubAPI probes the given REST API root instead of github.com's, for
+// GitHub Enterprise Server. Call it before Start.
+func (d *OfflineDetector) SetGitHubAPI(root string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	service := d.services["github"]
+	service.URL = strings.TrimSuffix(root, "/") + "/rate_limit"
+	d.services["github"] = service
+}
+
+// SetProbeIntervals sets the probe interval used after a failure, the interval
+// long-stable services back off to, and how long a service must stay stable
+// for its interval to double. Call it before Start.
+func (d *OfflineDetector) SetProbeIntervals(min, max, stableStep time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.checkInterval = min
+	d.maxInterval = max
+	d.stableStep = stableStep
+}
+
+// WrapProbes wraps the transport of each service's probe, e.g. to inject the
+// faults injected into the service's clients. Call it before Start.
+func (d *OfflineDetector) WrapProbes(wrap func(service string, base http.RoundTripper) http.RoundTripper) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.wrapProbe = wrap
+}
+
 // Start begins monitoring external services
 func (d *OfflineDetector) Start() {
 	d.wg.Add(1)
@@ -134,25 +253,43 @@ func (d *OfflineDetector) monitorServices() {
 	}
 }
 
-// checkAllServices checks all configured services
+// checkAllServices checks the configured services whose probe is due. Probes
+// run without the lock so reported calls aren't held up by a slow service.
 func (d *OfflineDetector) checkAllServices() {
-	d.mutex.Lock()
-	defer d.mutex.Unlock()
-
+	d.mutex.RLock()
+	due := make(map[string]ServiceConfig)
 	for name, service := range d.services {
-		status := d.checkService(service)
+		previous := d.status[name]
+		if service.URL == "" || (previous != nil && time.Now().Before(previous.NextCheck)) {
+			continue
+		}
+		due[name] = service
+	}
+	d.mutex.RUnlock()
+
+	for name, service := range due {
+		status := d.checkService(name, service)
+
+		d.mutex.Lock()
+		if !status.IsAvailable {
+			status.ErrorCount = d.getErrorCount(name) + 1
+		}
+		d.scheduleNextCheck(d.status[name], status)
 		d.status[name] = status
-		
+
 		// Update database
 		d.updateServiceStatus(status)
+		d.mutex.Unlock()
 	}
 
 	// Update overall mode
+	d.mutex.Lock()
 	d.updateMode()
+	d.mutex.Unlock()
 }
 
 // checkService checks a single service
-func (d *OfflineDetector) checkService(service ServiceConfig) *ServiceStatus {
+func (d *OfflineDetector) checkService(name string, service ServiceConfig) *ServiceStatus {
 	start := time.Now()
 	status := &ServiceStatus{
 		Name:      service.Name,
@@ -169,36 +306,130 @@ func (d *OfflineDetector) checkService(service ServiceConfig) *ServiceStatus {
 		return status
 	}
 
+	var transport http.RoundTripper = &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+		}).DialContext,
+	}
+	d.mutex.RLock()
+	if d.wrapProbe != nil {
+		transport = d.wrapProbe(name, transport)
+	}
+	d.mutex.RUnlock()
 	client := &http.Client{
-		Timeout: service.Timeout,
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout: 5 * time.Second,
-			}).DialContext,
-		},
+		Timeout:   service.Timeout,
+		Transport: transport,
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
 		status.IsAvailable = false
 		status.LastError = fmt.Sprintf("Request failed: %v", err)
-		status.ErrorCount = d.getErrorCount(service.Name) + 1
 	} else {
 		resp.Body.Close()
 		status.IsAvailable = resp.StatusCode < 500
 		status.ResponseTime = time.Since(start).Milliseconds()
-		
+
 		if !status.IsAvailable {
 			status.LastError = fmt.Sprintf("HTTP %d", resp.StatusCode)
-			status.ErrorCount = d.getErrorCount(service.Name) + 1
-		} else {
-			status.ErrorCount = 0 // Reset on success
 		}
 	}
 
 	return status
 }
 
+// scheduleNextCheck carries a service's stable run forward and backs its probe
+// interval off from checkInterval, doubling it for every stableStep the service
+// has been available, up to maxInterval; a failure tightens it immediately
+func (d *OfflineDetector) scheduleNextCheck(previous, status *ServiceStatus) {
+	interval := d.checkInterval
+	if status.IsAvailable {
+		status.StableSince = status.LastCheck
+		if previous != nil && previous.IsAvailable && !previous.StableSince.IsZero() {
+			status.StableSince = previous.StableSince
+		}
+		if d.stableStep > 0 {
+			for steps := status.LastCheck.Sub(status.StableSince) / d.stableStep; steps > 0 && interval < d.maxInterval; steps-- {
+				interval *= 2
+			}
+		}
+		if interval > d.maxInterval {
+			interval = d.maxInterval
+		}
+	}
+	status.NextCheck = status.LastCheck.Add(interval)
+}
+
+// ReportCall records the outcome of a real call to a service, so the mode
+// reflects failures of the endpoints clients actually use rather than only
+// the probe. A failure counts toward the offline threshold and makes the next
+// probe due immediately; a success clears the failure count. Services without
+// a probe, such as a container registry, are tracked from the first report.
+func (d *OfflineDetector) ReportCall(service string, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	status, exists := d.status[service]
+	if !exists {
+		d.services[service] = ServiceConfig{Name: service}
+		status = &ServiceStatus{Name: service, IsAvailable: true, StableSince: now}
+		d.status[service] = status
+	}
+	status.LastCheck = now
+
+	if err != nil {
+		status.IsAvailable = false
+		status.LastError = err.Error()
+		status.ErrorCount++
+		status.StableSince = time.Time{}
+		status.NextCheck = now
+	} else if !status.IsAvailable || status.ErrorCount > 0 {
+		status.IsAvailable = true
+		status.LastError = ""
+		status.ErrorCount = 0
+		status.StableSince = now
+	} else {
+		return
+	}
+
+	d.updateServiceStatus(status)
+	d.updateMode()
+}
+
+// Transport wraps base (http.DefaultTransport if nil) so every request made
+// through it is reported for service: transport errors and 5xx responses are
+// failures, other responses successes. Requests whose context was canceled by
+// the caller aren't reported.
+func (d *OfflineDetector) Transport(service string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &reportingTransport{detector: d, service: service, base: base}
+}
+
+// reportingTransport reports request outcomes to an OfflineDetector
+type reportingTransport struct {
+	detector *OfflineDetector
+	service  string
+	base     http.RoundTripper
+}
+
+func (t *reportingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case req.Context().Err() != nil:
+		// Canceled by the caller, which says nothing about the service
+	case err != nil:
+		t.detector.ReportCall(t.service, err)
+	case resp.StatusCode >= 500:
+		t.detector.ReportCall(t.service, fmt.Errorf("HTTP %d", resp.StatusCode))
+	default:
+		t.detector.ReportCall(t.service, nil)
+	}
+	return resp, err
+}
+
 // getErrorCount retrieves the current error count for a service
 func (d *OfflineDetector) getErrorCount(serviceName string) int {
 	if status, exists := d.status[serviceName]; exists {
@@ -256,7 +487,7 @@ func (d *OfflineDetector) updateMode() {
 }
 
 // GetMode returns the current operational mode
-func (d *OfflineDetector) GetMode() OfflineMode {
+func (d *OfflineDetector) GetMode() Mode {
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
 	return d.mode
@@ -272,6 +503,57 @@ func (d *OfflineDetector) IsOffline() bool {
 	return d.GetMode() == OfflineMode
 }
 
+// Available reports whether every service the capability needs is up. Services
+// that have never been checked or reported are assumed up.
+func (d *OfflineDetector) Available(capability Capability) bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.available(capability)
+}
+
+// Capabilities reports the availability of every capability
+func (d *OfflineDetector) Capabilities() map[Capability]bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	result := make(map[Capability]bool, len(d.capabilities))
+	for capability := range d.capabilities {
+		result[capability] = d.available(capability)
+	}
+	return result
+}
+
+// Unavailable lists the services the capability needs that are down, so
+// callers can fail fast naming them rather than time out calling them
+func (d *OfflineDetector) Unavailable(capability Capability) []string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	var down []string
+	for _, name := range d.capabilities[capability] {
+		if d.down(name) {
+			down = append(down, name)
+		}
+	}
+	return down
+}
+
+func (d *OfflineDetector) available(capability Capability) bool {
+	for _, name := range d.capabilities[capability] {
+		if d.down(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// down reports whether a service has failed often enough to count as
+// offline. Callers hold the mutex.
+func (d *OfflineDetector) down(name string) bool {
+	status, exists := d.status[name]
+	return exists && status.ErrorCount >= d.offlineThreshold
+}
+
 // GetServiceStatus returns status for all services
 func (d *OfflineDetector) GetServiceStatus() map[string]*ServiceStatus {
 	d.mutex.RLock()
@@ -287,6 +569,8 @@ func (d *OfflineDetector) GetServiceStatus() map[string]*ServiceStatus {
 			ResponseTime: status.ResponseTime,
 			ErrorCount:   status.ErrorCount,
 			LastError:    status.LastError,
+			StableSince:  status.StableSince,
+			NextCheck:    status.NextCheck,
 		}
 	}
 
@@ -302,21 +586,29 @@ type OfflineModeManager struct {
 
 // NewOfflineModeManager creates a new offline mode manager
 func NewOfflineModeManager(detector *OfflineDetector, cache *HierarchicalCache, db *sql.DB) *OfflineModeManager {
-	return &OfflineModeManager{
+	manager := &OfflineModeManager{
 		detector: detector,
 		cache:    cache,
 		db:       db,
 	}
+	cache.RegisterLoader("cve", manager.loadVulnerability, LoaderOptions{TTL: 1 * time.Hour})
+	return manager
 }
 
-// GetVulnerabilityData retrieves vulnerability data with fallback strategy
+// GetVulnerabilityData retrieves vulnerability data through the cache, which
+// loads misses with the fallback strategy
 func (o *OfflineModeManager) GetVulnerabilityData(ctx context.Context, cveID string) (interface{}, error) {
-	// Try cache first (all modes)
-	if data, found := o.cache.Get(ctx, fmt.Sprintf("cve:%s", cveID)); found {
-		return data, nil
-	}
+	return o.cache.Load(ctx, fmt.Sprintf("cve:%s", cveID))
+}
 
+// loadVulnerability is the "cve" namespace loader; it picks sources by the
+// availability of scanning data and the connectivity mode
+func (o *OfflineModeManager) loadVulnerability(ctx context.Context, key string) (interface{}, error) {
+	cveID := strings.TrimPrefix(key, "cve:")
 	mode := o.detector.GetMode()
+	if !o.detector.Available(CapabilityScanning) {
+		mode = OfflineMode
+	}
 
 	switch mode {
 	case OnlineMode:
@@ -349,9 +641,6 @@ func (o *OfflineModeManager) fetchFromLiveAPI(ctx context.Context, cveID string)
 		"description": fmt.Sprintf("Live API data for %s", cveID),
 	}
 
-	// Cache the result
-	o.cache.Set(ctx, fmt.Sprintf("cve:%s", cveID), data, 1*time.Hour)
-
 	return data, nil
 }
 
@@ -429,12 +718,13 @@ func (o *OfflineModeManager) GetOfflineCapabilities() map[string]interface{} {
 	services := o.detector.GetServiceStatus()
 
 	return map[string]interface{}{
-		"mode":                    mode,
-		"local_vulnerabilities":   localVulnCount,
-		"cached_vulnerabilities":  cachedVulnCount,
-		"service_status":          services,
-		"offline_scanning":        true, // Trivy/Grype work offline
-		"policy_evaluation":       true, // OPA works offline
+		"mode":                      mode,
+		"local_vulnerabilities":     localVulnCount,
+		"cached_vulnerabilities":    cachedVulnCount,
+		"service_status":            services,
+		"capabilities":              o.detector.Capabilities(),
+		"offline_scanning":          true, // Trivy/Grype work offline
+		"policy_evaluation":         true, // OPA works offline
 		"vulnerability_correlation": localVulnCount > 0,
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,458 @@
+// Package redis stores cache entries in Redis, so the hierarchical cache's L3
+// level is shared by every replica of a long-running deployment. It
+// implements cache.L3CacheClient over a single server or a Redis Cluster,
+// with TLS and ACL or password authentication.
+//
+// In cluster mode, keys are sent to the seed nodes first and the client
+// learns which node serves each hash slot from the MOVED redirects it gets
+// back, following ASK redirects while a slot migrates.
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+)
+
+// Environment variables configuring the client
+const (
+	URLEnv     = "KEYSTONE_REDIS_URL"     // redis://[user:password@]host:port[,host:port...][/db]; rediss:// for TLS
+	ClusterEnv = "KEYSTONE_REDIS_CLUSTER" // "true" when the URL's hosts are Redis Cluster seed nodes
+	CAEnv      = "KEYSTONE_REDIS_CA"      // PEM CA certificates the server's must chain to; system roots when empty
+)
+
+// Defaults for a zero Config
+const (
+	DefaultKeyPrefix   = "keystone:l3:"
+	DefaultPoolSize    = 10
+	DefaultDialTimeout = 5 * time.Second
+	DefaultTimeout     = 3 * time.Second
+)
+
+const (
+	slotCount    = 16384
+	maxRedirects = 5
+)
+
+// ErrNotFound is returned by Get for a key with no entry, or an expired one
+var ErrNotFound = errors.New("redis cache entry not found")
+
+// ErrNotConfigured is returned by ConfigFromEnv when KEYSTONE_REDIS_URL is
+// unset
+var ErrNotConfigured = errors.New("no redis cache: " + URLEnv + " is unset")
+
+// Config configures the cache client
+type Config struct {
+	Addrs       []string       // host:port of the server, or of cluster seed nodes
+	Username    string         // ACL user; the default user when empty
+	Password    *secret.String // Nil when the server doesn't require AUTH
+	DB          int            // Database selected on a single server; clusters only have 0
+	Cluster     bool
+	TLS         *tls.Config   // Nil connects over plain TCP
+	KeyPrefix   string        // Prepended to every key; DefaultKeyPrefix when empty
+	PoolSize    int           // Idle connections kept per node; DefaultPoolSize when zero
+	DialTimeout time.Duration // DefaultDialTimeout when zero
+	Timeout     time.Duration // Per command when the context has no deadline; DefaultTimeout when zero
+}
+
+// ParseURL parses a redis:// or rediss:// URL, the latter connecting over
+// TLS. Several comma-separated hosts are cluster seed nodes.
+func ParseURL(rawURL string) (Config, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	var config Config
+	switch parsed.Scheme {
+	case "redis":
+	case "rediss":
+		config.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	default:
+		return Config{}, fmt.Errorf("redis URL scheme must be redis or rediss, not %q", parsed.Scheme)
+	}
+
+	for _, addr := range strings.Split(parsed.Host, ",") {
+		if addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "6379")
+		}
+		config.Addrs = append(config.Addrs, addr)
+	}
+	if len(config.Addrs) == 0 {
+		return Config{}, fmt.Errorf("redis URL has no host")
+	}
+	config.Cluster = len(config.Addrs) > 1
+
+	if parsed.User != nil {
+		config.Username = parsed.User.Username()
+		if password, set := parsed.User.Password(); set {
+			config.Password = secret.New(password)
+		} else if config.Username != "" {
+			// redis://password@host, as other clients accept
+			config.Username, config.Password = "", secret.New(config.Username)
+		}
+	}
+	if db := strings.Trim(parsed.Path, "/"); db != "" {
+		if config.DB, err = strconv.Atoi(db); err != nil {
+			return Config{}, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	return config, nil
+}
+
+// ConfigFromEnv returns the config KEYSTONE_REDIS_URL, KEYSTONE_REDIS_CLUSTER
+// and KEYSTONE_REDIS_CA describe, or ErrNotConfigured
+func ConfigFromEnv() (Config, error) {
+	rawURL := os.Getenv(URLEnv)
+	if rawURL == "" {
+		return Config{}, ErrNotConfigured
+	}
+	config, err := ParseURL(rawURL)
+	if err != nil {
+		return Config{}, err
+	}
+	if cluster := os.Getenv(ClusterEnv); cluster != "" {
+		if config.Cluster, err = strconv.ParseBool(cluster); err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", ClusterEnv, err)
+		}
+	}
+	if caFile := os.Getenv(CAEnv); caFile != "" {
+		if config.TLS == nil {
+			return Config{}, fmt.Errorf("%s is set but %s isn't a rediss:// URL", CAEnv, URLEnv)
+		}
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read redis CA: %w", err)
+		}
+		config.TLS.RootCAs = x509.NewCertPool()
+		if !config.TLS.RootCAs.AppendCertsFromPEM(data) {
+			return Config{}, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	return config, nil
+}
+
+// Client reads and writes entries in Redis
+type Client struct {
+	config Config
+
+	mutex  sync.Mutex
+	nodes  map[string]*node  // By address
+	slots  [slotCount]string // The node serving each slot, learned from redirects; empty until one is
+	closed bool
+}
+
+// node is a server with a pool of idle connections
+type node struct {
+	addr string
+	idle chan *conn
+}
+
+// NewClient creates a cache client. Connections are made on first use.
+func NewClient(config Config) *Client {
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = DefaultKeyPrefix
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = DefaultPoolSize
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = DefaultDialTimeout
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	return &Client{config: config, nodes: make(map[string]*node)}
+}
+
+// Get returns the data set for key, or ErrNotFound if there is none or it
+// expired
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := c.do(ctx, c.key(key), args("GET", c.key(key))...)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrNotFound
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return data, nil
+}
+
+// Set saves data for key until ttl passes; a ttl of zero keeps it until
+// Redis evicts it
+func (c *Client) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	command := [][]byte{[]byte("SET"), []byte(c.key(key)), data}
+	if ttl > 0 {
+		// PX takes whole milliseconds; round up so short TTLs don't become 0
+		milliseconds := (ttl + time.Millisecond - 1) / time.Millisecond
+		command = append(command, args("PX", strconv.FormatInt(int64(milliseconds), 10))...)
+	}
+	_, err := c.do(ctx, c.key(key), command...)
+	return err
+}
+
+// Delete removes key; deleting a missing key isn't an error
+func (c *Client) Delete(ctx context.Context, key string) error {
+	_, err := c.do(ctx, c.key(key), args("DEL", c.key(key))...)
+	return err
+}
+
+// Ping checks every seed node is reachable with the configured credentials
+func (c *Client) Ping(ctx context.Context) error {
+	for _, addr := range c.config.Addrs {
+		if _, err := c.doOn(ctx, addr, false, args("PING")...); err != nil {
+			return fmt.Errorf("redis %s: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+// Close closes idle connections; the client can't be used after
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	for _, n := range c.nodes {
+		for len(n.idle) > 0 {
+			(<-n.idle).Close()
+		}
+	}
+	return nil
+}
+
+// key returns the Redis key a cache key is stored under
+func (c *Client) key(key string) string {
+	return c.config.KeyPrefix + key
+}
+
+// do runs a command on the node serving key, following cluster redirects
+func (c *Client) do(ctx context.Context, key string, command ...[]byte) (interface{}, error) {
+	addr := c.route(key)
+	asking := false
+	for redirects := 0; ; redirects++ {
+		reply, err := c.doOn(ctx, addr, asking, command...)
+		var replyErr *ReplyError
+		if c.config.Cluster && err != nil && !errors.As(err, &replyErr) {
+			// The node may have failed over; ask the seeds again next time
+			c.mutex.Lock()
+			c.slots[slot(key)] = ""
+			c.mutex.Unlock()
+		}
+		if !c.config.Cluster || !errors.As(err, &replyErr) || redirects == maxRedirects {
+			return reply, err
+		}
+
+		switch replyErr.Code() {
+		case "MOVED":
+			// MOVED <slot> <addr>: the slot has a new owner
+			slot, target, ok := parseRedirect(replyErr.Message)
+			if !ok {
+				return nil, err
+			}
+			c.mutex.Lock()
+			c.slots[slot] = target
+			c.mutex.Unlock()
+			addr, asking = target, false
+		case "ASK":
+			// ASK <slot> <addr>: the key is moving; ask the target this once
+			_, target, ok := parseRedirect(replyErr.Message)
+			if !ok {
+				return nil, err
+			}
+			addr, asking = target, true
+		case "TRYAGAIN", "CLUSTERDOWN":
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(redirects+1) * 50 * time.Millisecond):
+			}
+			addr, asking = c.route(key), false
+		default:
+			return reply, err
+		}
+	}
+}
+
+// route returns the node to send key's commands to
+func (c *Client) route(key string) string {
+	if c.config.Cluster {
+		c.mutex.Lock()
+		addr := c.slots[slot(key)]
+		c.mutex.Unlock()
+		if addr != "" {
+			return addr
+		}
+	}
+	return c.config.Addrs[0]
+}
+
+// doOn runs a command on a node, preceded by ASKING when following an ASK
+// redirect. The connection is pooled again unless it failed.
+func (c *Client) doOn(ctx context.Context, addr string, asking bool, command ...[]byte) (interface{}, error) {
+	n, err := c.node(addr)
+	if err != nil {
+		return nil, err
+	}
+	cn, err := c.acquire(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.config.Timeout)
+	}
+	cn.SetDeadline(deadline)
+
+	if asking {
+		if _, err = cn.do(args("ASKING")...); err != nil {
+			c.release(n, cn, err)
+			return nil, err
+		}
+	}
+	reply, err := cn.do(command...)
+	c.release(n, cn, err)
+	return reply, err
+}
+
+// node returns the node at addr, adding it when first redirected to
+func (c *Client) node(addr string) (*node, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return nil, errors.New("redis client is closed")
+	}
+	n, found := c.nodes[addr]
+	if !found {
+		n = &node{addr: addr, idle: make(chan *conn, c.config.PoolSize)}
+		c.nodes[addr] = n
+	}
+	return n, nil
+}
+
+// acquire takes an idle connection to a node, or dials a new one
+func (c *Client) acquire(ctx context.Context, n *node) (*conn, error) {
+	select {
+	case cn := <-n.idle:
+		return cn, nil
+	default:
+	}
+	return c.dial(ctx, n.addr)
+}
+
+// release returns a connection to its node's pool, or closes it after a
+// network or protocol error, or when the pool is full
+func (c *Client) release(n *node, cn *conn, err error) {
+	var replyErr *ReplyError
+	if err != nil && !errors.As(err, &replyErr) {
+		cn.Close()
+		return
+	}
+	select {
+	case n.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+// dial connects to addr, negotiating TLS, authenticating and selecting the
+// database as configured
+func (c *Client) dial(ctx context.Context, addr string) (*conn, error) {
+	dialer := &net.Dialer{Timeout: c.config.DialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.config.TLS != nil {
+		tlsConfig := c.config.TLS.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tlsConn := tls.Client(netConn, tlsConfig)
+		handshakeCtx, cancel := context.WithTimeout(ctx, c.config.DialTimeout)
+		err := tlsConn.HandshakeContext(handshakeCtx)
+		cancel()
+		if err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("redis TLS handshake with %s: %w", addr, err)
+		}
+		netConn = tlsConn
+	}
+
+	cn := newConn(netConn)
+	cn.SetDeadline(time.Now().Add(c.config.DialTimeout))
+	if c.config.Password != nil {
+		auth := args("AUTH")
+		if c.config.Username != "" {
+			auth = append(auth, []byte(c.config.Username))
+		}
+		auth = append(auth, []byte(c.config.Password.Reveal()))
+		if _, err := cn.do(auth...); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis AUTH on %s: %w", addr, err)
+		}
+	}
+	if c.config.DB != 0 && !c.config.Cluster {
+		if _, err := cn.do(args("SELECT", strconv.Itoa(c.config.DB))...); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis SELECT %d on %s: %w", c.config.DB, addr, err)
+		}
+	}
+	return cn, nil
+}
+
+// parseRedirect parses the slot and address of a MOVED or ASK error
+func parseRedirect(message string) (int, string, bool) {
+	fields := strings.Fields(message)
+	if len(fields) != 3 {
+		return 0, "", false
+	}
+	slot, err := strconv.Atoi(fields[1])
+	if err != nil || slot < 0 || slot >= slotCount {
+		return 0, "", false
+	}
+	return slot, fields[2], true
+}
+
+// slot returns the cluster hash slot of key: CRC16 of the key, or of the
+// part within its first {braces} when that isn't empty
+func slot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % slotCount
+}
+
+// crc16 is CRC-16/XMODEM, the checksum Redis Cluster hashes keys with
+func crc16(key string) uint16 {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxBulkLen bounds the bulk strings read, Redis's own proto-max-bulk-len
+const maxBulkLen = 512 << 20
+
+// ReplyError is an error reply from the server, e.g. "WRONGTYPE ..." or
+// "NOAUTH ...". The connection stays usable after one.
+type ReplyError struct {
+	Message string
+}
+
+func (e *ReplyError) Error() string {
+	return "redis: " + e.Message
+}
+
+// Code returns the error's first word, e.g. MOVED
+func (e *ReplyError) Code() string {
+	code, _, _ := strings.Cut(e.Message, " ")
+	return code
+}
+
+// conn is a connection speaking RESP2
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+func newConn(netConn net.Conn) *conn {
+	return &conn{Conn: netConn, reader: bufio.NewReader(netConn), writer: bufio.NewWriter(netConn)}
+}
+
+// do sends a command and reads its reply: nil, a string, []byte, int64 or
+// []interface{}. An error reply is returned as a *ReplyError.
+func (c *conn) do(args ...[]byte) (interface{}, error) {
+	if err := c.write(args); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// write sends a command as an array of bulk strings
+func (c *conn) write(args [][]byte) error {
+	fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.writer, "$%d\r\n", len(arg))
+		c.writer.Write(arg)
+		c.writer.WriteString("\r\n")
+	}
+	return c.writer.Flush()
+}
+
+// read reads one reply
+func (c *conn) read() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, &ReplyError{Message: payload}
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		length, err := strconv.Atoi(payload)
+		if err != nil || length > maxBulkLen {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", payload)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return data[:length], nil
+	case '*':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", payload)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, length)
+		for i := range items {
+			item, err := c.read()
+			var replyErr *ReplyError
+			if err != nil && !errors.As(err, &replyErr) {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
+
+// args converts strings to command arguments
+func args(values ...string) [][]byte {
+	converted := make([][]byte, len(values))
+	for i, value := range values {
+		converted[i] = []byte(value)
+	}
+	return converted
+}
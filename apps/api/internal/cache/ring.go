@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// HashRing assigns keys to nodes by consistent hashing. Each node owns several
+// virtual points, so keys spread evenly and only the departed node's keys move
+// when membership changes. HashRing is not safe for concurrent use.
+type HashRing struct {
+	replicas int
+	points   []uint32
+	owners   map[uint32]string
+	nodes    map[string]struct{}
+}
+
+// NewHashRing creates an empty ring with the given virtual nodes per node
+func NewHashRing(replicas int) *HashRing {
+	if replicas <= 0 {
+		replicas = 100
+	}
+	return &HashRing{
+		replicas: replicas,
+		owners:   make(map[uint32]string),
+		nodes:    make(map[string]struct{}),
+	}
+}
+
+// Add places nodes on the ring
+func (r *HashRing) Add(nodes ...string) {
+	for _, node := range nodes {
+		if _, exists := r.nodes[node]; exists {
+			continue
+		}
+		r.nodes[node] = struct{}{}
+		for i := 0; i < r.replicas; i++ {
+			point := ringHash(node + "#" + strconv.Itoa(i))
+			if _, taken := r.owners[point]; taken {
+				continue
+			}
+			r.owners[point] = node
+			r.points = append(r.points, point)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+// Remove takes a node off the ring
+func (r *HashRing) Remove(node string) {
+	if _, exists := r.nodes[node]; !exists {
+		return
+	}
+	delete(r.nodes, node)
+
+	points := r.points[:0]
+	for _, point := range r.points {
+		if r.owners[point] == node {
+			delete(r.owners, point)
+			continue
+		}
+		points = append(points, point)
+	}
+	r.points = points
+}
+
+// Owner returns the node owning key, or "" for an empty ring
+func (r *HashRing) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	hash := ringHash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// Nodes returns the nodes on the ring, sorted
+func (r *HashRing) Nodes() []string {
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+func ringHash(value string) uint32 {
+	sum := sha256.Sum256([]byte(value))
+	return binary.BigEndian.Uint32(sum[:4])
+}
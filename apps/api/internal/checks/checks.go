@@ -0,0 +1,323 @@
+// Package checks publishes verification results as a GitHub check run on the
+// verified commit: whether each attestation verified, how its policy fared
+// and what findings were raised, with findings annotated on the files they
+// concern. Branch protection can then require the check like any other.
+package checks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// DefaultName is the check run name, which branch protection rules refer to
+const DefaultName = "keystone"
+
+// MaxAnnotations caps the annotations published, most severe first, as each
+// batch of github.MaxCheckAnnotations takes a request
+const MaxAnnotations = 500
+
+// maxSummary is the longest output summary GitHub accepts
+const maxSummary = 65535
+
+// severities lists severities from most to least severe
+var severities = []findings.Severity{
+	findings.SeverityCritical,
+	findings.SeverityHigh,
+	findings.SeverityMedium,
+	findings.SeverityLow,
+	findings.SeverityInfo,
+}
+
+// Result is what a verification of a commit produced
+type Result struct {
+	Reports  []*attestation.Report
+	Findings []findings.Finding
+	// Path is the repository file findings without a path are annotated on,
+	// e.g. the scanned manifest. Findings without either aren't annotated.
+	Path string
+	// Baseline is what was found on the pull request's base, if scanned.
+	// Pull request comments then report the vulnerabilities it introduces
+	// and fixes rather than all of them.
+	Baseline []findings.Finding
+}
+
+// Reporter publishes results as check runs
+type Reporter struct {
+	client *github.Client
+
+	Name       string            // Defaults to DefaultName
+	FailOn     findings.Severity // Findings this severe or worse fail the check; defaults to HIGH
+	DetailsURL string            // Linked from the check, e.g. the workflow run
+}
+
+// NewReporter creates a reporter publishing through the client, which must
+// authenticate as a GitHub App: only apps can write check runs
+func NewReporter(client *github.Client) *Reporter {
+	return &Reporter{
+		client: client,
+		Name:   DefaultName,
+		FailOn: findings.SeverityHigh,
+	}
+}
+
+// Start creates an in-progress check run on the commit, so pull requests
+// show verification as pending until Publish completes it
+func (r *Reporter) Start(ctx context.Context, owner, repo, sha string) (*github.CheckRun, error) {
+	ctx = github.WithConsumer(ctx, github.ConsumerAttestationChecks)
+	return r.client.CreateCheckRun(ctx, owner, repo, github.CheckRunOptions{
+		Name:       r.name(),
+		HeadSHA:    sha,
+		Status:     github.CheckRunInProgress,
+		DetailsURL: r.DetailsURL,
+	})
+}
+
+// Publish completes the check run on the commit with the result. A run Start
+// left in progress is completed; otherwise a new run is created, which
+// supersedes earlier runs of the same name.
+func (r *Reporter) Publish(ctx context.Context, owner, repo, sha string, result Result) (*github.CheckRun, error) {
+	ctx = github.WithConsumer(ctx, github.ConsumerAttestationChecks)
+	output := result.Output(r.failOn())
+	options := github.CheckRunOptions{
+		Status:     github.CheckRunCompleted,
+		Conclusion: result.Conclusion(r.failOn()),
+		DetailsURL: r.DetailsURL,
+		Output:     &output,
+	}
+
+	existing, err := r.client.FindCheckRun(ctx, owner, repo, sha, r.name())
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Status != github.CheckRunCompleted {
+		return r.client.UpdateCheckRun(ctx, owner, repo, existing.ID, options)
+	}
+	options.Name = r.name()
+	options.HeadSHA = sha
+	return r.client.CreateCheckRun(ctx, owner, repo, options)
+}
+
+func (r *Reporter) name() string {
+	if r.Name == "" {
+		return DefaultName
+	}
+	return r.Name
+}
+
+func (r *Reporter) failOn() findings.Severity {
+	if r.FailOn == "" {
+		return findings.SeverityHigh
+	}
+	return r.FailOn
+}
+
+// Conclusion is failure when an attestation failed to verify or a finding is
+// failOn or worse, and success otherwise
+func (r Result) Conclusion(failOn findings.Severity) string {
+	if r.invalid() > 0 || r.failing(failOn) > 0 {
+		return github.CheckConclusionFailure
+	}
+	return github.CheckConclusionSuccess
+}
+
+// Output renders the result: a title stating the outcome, a Markdown summary
+// of attestations, policy results and finding counts, and an annotation per
+// locatable finding
+func (r Result) Output(failOn findings.Severity) github.CheckRunOutput {
+	annotations, omitted := r.annotations(failOn)
+	summary := r.summary(omitted)
+	if len(summary) > maxSummary {
+		const truncated = "\n\n_Summary truncated._\n"
+		summary = strings.ToValidUTF8(summary[:maxSummary-len(truncated)], "") + truncated
+	}
+	return github.CheckRunOutput{Title: r.title(failOn), Summary: summary, Annotations: annotations}
+}
+
+// title states the outcome in a line
+func (r Result) title(failOn findings.Severity) string {
+	var problems []string
+	if invalid := r.invalid(); invalid > 0 {
+		problems = append(problems, plural(invalid, "attestation")+" failed verification")
+	}
+	if failing := r.failing(failOn); failing > 0 {
+		problems = append(problems, fmt.Sprintf("%s %s or worse", plural(failing, "finding"), failOn))
+	}
+
+	if len(problems) > 0 {
+		return strings.Join(problems, ", ")
+	}
+	return fmt.Sprintf("%s verified, %s", plural(len(r.Reports), "attestation"), plural(len(r.Findings), "finding"))
+}
+
+// summary renders the result as Markdown
+func (r Result) summary(omitted int) string {
+	var b strings.Builder
+
+	if len(r.Reports) > 0 {
+		b.WriteString("### Attestations\n\n| Subject | Result | Details |\n| --- | --- | --- |\n")
+		for _, report := range r.Reports {
+			status, details := "✅ verified", ""
+			if !report.Valid {
+				status = "❌ failed"
+				if report.Failure != nil {
+					message := report.Failure.Message
+					if report.Failure.Localized != nil && report.Failure.Localized.Text != "" {
+						message = report.Failure.Localized.Text
+					}
+					details = fmt.Sprintf("`%s` %s", report.Failure.Code, escape(message))
+				}
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", escape(subject(report)), status, details)
+		}
+	}
+
+	passed, total := 0, 0
+	var violations []string
+	for _, report := range r.Reports {
+		for _, result := range report.Policy {
+			total++
+			if result.Passed {
+				passed++
+				continue
+			}
+			violations = append(violations, fmt.Sprintf("| `%s` | %s | `%s` | `%s` |\n",
+				escape(subject(report)), result.Rule, escape(result.Expected), escape(result.Actual)))
+		}
+	}
+	if total > 0 {
+		fmt.Fprintf(&b, "\n### Policy\n\n%d of %s passed.\n", passed, plural(total, "rule"))
+		if len(violations) > 0 {
+			b.WriteString("\n| Subject | Rule | Expected | Actual |\n| --- | --- | --- | --- |\n")
+			b.WriteString(strings.Join(violations, ""))
+		}
+	}
+
+	counts := make(map[findings.Category]map[findings.Severity]int)
+	var categories []string
+	for _, finding := range r.Findings {
+		if counts[finding.Category] == nil {
+			counts[finding.Category] = make(map[findings.Severity]int)
+			categories = append(categories, string(finding.Category))
+		}
+		counts[finding.Category][finding.Severity]++
+	}
+	b.WriteString("\n### Findings\n\n")
+	if len(categories) == 0 {
+		b.WriteString("No findings.\n")
+	} else {
+		sort.Strings(categories)
+		b.WriteString("| Category | Critical | High | Medium | Low | Info |\n| --- | --- | --- | --- | --- | --- |\n")
+		for _, category := range categories {
+			fmt.Fprintf(&b, "| %s |", category)
+			for _, severity := range severities {
+				fmt.Fprintf(&b, " %d |", counts[findings.Category(category)][severity])
+			}
+			b.WriteString("\n")
+		}
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&b, "\n%s not annotated; only the %d most severe are.\n", plural(omitted, "finding"), MaxAnnotations)
+	}
+	return b.String()
+}
+
+// annotations locates findings at their path and line, most severe first,
+// returning how many were left out over MaxAnnotations
+func (r Result) annotations(failOn findings.Severity) ([]github.CheckAnnotation, int) {
+	located := make([]findings.Finding, 0, len(r.Findings))
+	for _, finding := range r.Findings {
+		if finding.Metadata[findings.MetadataPath] != "" || r.Path != "" {
+			located = append(located, finding)
+		}
+	}
+	sort.SliceStable(located, func(i, j int) bool {
+		return located[i].Severity.AtLeast(located[j].Severity) && !located[j].Severity.AtLeast(located[i].Severity)
+	})
+
+	omitted := 0
+	if len(located) > MaxAnnotations {
+		omitted = len(located) - MaxAnnotations
+		located = located[:MaxAnnotations]
+	}
+
+	annotations := make([]github.CheckAnnotation, 0, len(located))
+	for _, finding := range located {
+		path := finding.Metadata[findings.MetadataPath]
+		if path == "" {
+			path = r.Path
+		}
+		line, err := strconv.Atoi(finding.Metadata[findings.MetadataLine])
+		if err != nil || line < 1 {
+			line = 1
+		}
+
+		level := github.AnnotationNotice
+		switch {
+		case finding.Severity.AtLeast(failOn):
+			level = github.AnnotationFailure
+		case finding.Severity.AtLeast(findings.SeverityMedium):
+			level = github.AnnotationWarning
+		}
+		annotations = append(annotations, github.CheckAnnotation{
+			Path:            strings.TrimPrefix(path, "./"),
+			StartLine:       line,
+			EndLine:         line,
+			AnnotationLevel: level,
+			Title:           fmt.Sprintf("%s: %s", finding.Severity, finding.RuleID()),
+			Message:         finding.Title,
+			RawDetails:      finding.Description,
+		})
+	}
+	return annotations, omitted
+}
+
+// invalid counts the attestations that failed verification
+func (r Result) invalid() int {
+	count := 0
+	for _, report := range r.Reports {
+		if !report.Valid {
+			count++
+		}
+	}
+	return count
+}
+
+// failing counts the findings at least failOn
+func (r Result) failing(failOn findings.Severity) int {
+	count := 0
+	for _, finding := range r.Findings {
+		if finding.Severity.AtLeast(failOn) {
+			count++
+		}
+	}
+	return count
+}
+
+func subject(report *attestation.Report) string {
+	if report.Subject == "" {
+		return "attestation"
+	}
+	return report.Subject
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	if strings.HasSuffix(noun, "y") {
+		return fmt.Sprintf("%d %sies", n, strings.TrimSuffix(noun, "y"))
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// escape keeps a value on one table row
+func escape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "`", "'").Replace(s)
+}
@@ -0,0 +1,156 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// DefaultMarker identifies the report comment among a pull request's
+// comments. It's an HTML comment, so readers don't see it.
+const DefaultMarker = "<!-- keystone:security-report -->"
+
+// maxListed caps the vulnerabilities a comment lists individually
+const maxListed = 25
+
+// CommentReporter publishes results as a single pull request comment, edited
+// in place on every run rather than posting a new one
+type CommentReporter struct {
+	client *github.Client
+
+	Marker string            // Defaults to DefaultMarker; reporters with different markers keep separate comments
+	FailOn findings.Severity // Findings this severe or worse fail the verdict; defaults to HIGH
+}
+
+// NewCommentReporter creates a comment reporter publishing through the client
+func NewCommentReporter(client *github.Client) *CommentReporter {
+	return &CommentReporter{
+		client: client,
+		Marker: DefaultMarker,
+		FailOn: findings.SeverityHigh,
+	}
+}
+
+// Publish posts the result as the pull request's report comment, or updates
+// the comment an earlier run posted. An unchanged report isn't edited, so
+// subscribers aren't notified again.
+func (r *CommentReporter) Publish(ctx context.Context, owner, repo string, number int, result Result) (*github.IssueComment, error) {
+	ctx = github.WithConsumer(ctx, github.ConsumerPullRequestReports)
+	body := r.Body(result)
+
+	comments, err := r.client.ListIssueComments(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	for i := range comments {
+		if !strings.Contains(comments[i].Body, r.marker()) {
+			continue
+		}
+		if comments[i].Body == body {
+			return &comments[i], nil
+		}
+		return r.client.UpdateIssueComment(ctx, owner, repo, comments[i].ID, body)
+	}
+	return r.client.CreateIssueComment(ctx, owner, repo, number, body)
+}
+
+// Body renders the comment: the verdict, the change in vulnerabilities and
+// the attestation and policy summary a check run shows
+func (r *CommentReporter) Body(result Result) string {
+	failOn := r.FailOn
+	if failOn == "" {
+		failOn = findings.SeverityHigh
+	}
+
+	var b strings.Builder
+	b.WriteString(r.marker())
+	b.WriteString("\n## Keystone security report\n\n")
+	if result.Conclusion(failOn) == github.CheckConclusionFailure {
+		fmt.Fprintf(&b, "❌ **Failed**: %s.\n", result.title(failOn))
+	} else {
+		fmt.Fprintf(&b, "✅ **Passed**: %s.\n", result.title(failOn))
+	}
+
+	b.WriteString("\n### Vulnerabilities\n\n")
+	introduced, fixed := vulnerabilityDelta(result)
+	switch {
+	case result.Baseline == nil:
+		fmt.Fprintf(&b, "%s found.\n", plural(len(introduced), "vulnerability"))
+	case len(introduced) == 0 && fixed == 0:
+		b.WriteString("No change from the base branch.\n")
+	default:
+		fmt.Fprintf(&b, "%s introduced, %s fixed.\n", plural(len(introduced), "vulnerability"), plural(fixed, "vulnerability"))
+	}
+	if len(introduced) > 0 {
+		b.WriteString("\n| Severity | Advisory | Component | Summary |\n| --- | --- | --- | --- |\n")
+		for i, finding := range introduced {
+			if i == maxListed {
+				fmt.Fprintf(&b, "\n…and %d more.\n", len(introduced)-maxListed)
+				break
+			}
+			component := finding.PURL
+			if component == "" {
+				// The subject the finding's ID ends with
+				parts := strings.SplitN(finding.ID, ":", 3)
+				component = parts[len(parts)-1]
+			}
+			fmt.Fprintf(&b, "| %s | %s | `%s` | %s |\n", finding.Severity, escape(finding.RuleID()), escape(component), escape(finding.Title))
+		}
+	}
+
+	b.WriteString("\n")
+	b.WriteString(result.summary(0))
+
+	body := b.String()
+	if len(body) > github.MaxCommentBody {
+		const truncated = "\n\n_Report truncated._\n"
+		body = strings.ToValidUTF8(body[:github.MaxCommentBody-len(truncated)], "") + truncated
+	}
+	return body
+}
+
+func (r *CommentReporter) marker() string {
+	if r.Marker == "" {
+		return DefaultMarker
+	}
+	return r.Marker
+}
+
+// vulnerabilityDelta returns the vulnerabilities not in the baseline, most
+// severe first, and how many of the baseline's are gone. Without a baseline
+// every vulnerability is new.
+func vulnerabilityDelta(result Result) ([]findings.Finding, int) {
+	baseline := make(map[string]bool)
+	for _, finding := range result.Baseline {
+		if finding.Category == findings.CategoryVulnerability {
+			baseline[finding.ID] = true
+		}
+	}
+
+	var introduced []findings.Finding
+	current := make(map[string]bool)
+	for _, finding := range result.Findings {
+		if finding.Category != findings.CategoryVulnerability || current[finding.ID] {
+			continue
+		}
+		current[finding.ID] = true
+		if !baseline[finding.ID] {
+			introduced = append(introduced, finding)
+		}
+	}
+	sort.SliceStable(introduced, func(i, j int) bool {
+		return introduced[i].Severity.AtLeast(introduced[j].Severity) && !introduced[j].Severity.AtLeast(introduced[i].Severity)
+	})
+
+	fixed := 0
+	for id := range baseline {
+		if !current[id] {
+			fixed++
+		}
+	}
+	return introduced, fixed
+}
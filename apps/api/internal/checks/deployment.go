@@ -0,0 +1,67 @@
+package checks
+
+import (
+	"context"
+
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// DeploymentGate publishes results as deployment statuses, so a deployment
+// is only marked successful once its artifacts pass verification. Deploy
+// tooling that waits for the deployment's success then can't ship an
+// unverified artifact.
+type DeploymentGate struct {
+	client *github.Client
+
+	FailOn findings.Severity // Findings this severe or worse fail the deployment; defaults to HIGH
+	LogURL string            // The keystone report, e.g. a job summary or uploaded artifact
+}
+
+// NewDeploymentGate creates a deployment gate publishing through the client
+func NewDeploymentGate(client *github.Client) *DeploymentGate {
+	return &DeploymentGate{
+		client: client,
+		FailOn: findings.SeverityHigh,
+	}
+}
+
+// Start marks the deployment in progress while it's verified
+func (g *DeploymentGate) Start(ctx context.Context, owner, repo string, deployment int64) (*github.DeploymentStatus, error) {
+	ctx = github.WithConsumer(ctx, github.ConsumerAttestationChecks)
+	return g.client.CreateDeploymentStatus(ctx, owner, repo, deployment, github.DeploymentStatus{
+		State:       github.DeploymentStateInProgress,
+		Description: "Verifying attestations and dependencies",
+		LogURL:      g.LogURL,
+	})
+}
+
+// Publish marks the deployment successful if the result passes, and failed
+// otherwise. The description is the title a check run would show.
+func (g *DeploymentGate) Publish(ctx context.Context, owner, repo string, deployment int64, result Result) (*github.DeploymentStatus, error) {
+	ctx = github.WithConsumer(ctx, github.ConsumerAttestationChecks)
+	failOn := g.FailOn
+	if failOn == "" {
+		failOn = findings.SeverityHigh
+	}
+	state := github.DeploymentStateSuccess
+	if result.Conclusion(failOn) == github.CheckConclusionFailure {
+		state = github.DeploymentStateFailure
+	}
+	return g.client.CreateDeploymentStatus(ctx, owner, repo, deployment, github.DeploymentStatus{
+		State:       state,
+		Description: result.title(failOn),
+		LogURL:      g.LogURL,
+	})
+}
+
+// Fail marks the deployment errored, for verifications that couldn't run at
+// all, e.g. because the bundle couldn't be fetched
+func (g *DeploymentGate) Fail(ctx context.Context, owner, repo string, deployment int64, err error) (*github.DeploymentStatus, error) {
+	ctx = github.WithConsumer(ctx, github.ConsumerAttestationChecks)
+	return g.client.CreateDeploymentStatus(ctx, owner, repo, deployment, github.DeploymentStatus{
+		State:       github.DeploymentStateError,
+		Description: err.Error(),
+		LogURL:      g.LogURL,
+	})
+}
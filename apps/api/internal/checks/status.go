@@ -0,0 +1,78 @@
+package checks
+
+import (
+	"context"
+
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// StatusReporter publishes results as commit statuses: a state and a one-line
+// description linking to the full report. It suits workflows whose token
+// can't write check runs, at the cost of the summary and annotations.
+type StatusReporter struct {
+	client *github.Client
+
+	Context   string            // Defaults to DefaultName
+	FailOn    findings.Severity // Findings this severe or worse fail the status; defaults to HIGH
+	TargetURL string            // The keystone report, e.g. a job summary or uploaded artifact
+}
+
+// NewStatusReporter creates a status reporter publishing through the client
+func NewStatusReporter(client *github.Client) *StatusReporter {
+	return &StatusReporter{
+		client:  client,
+		Context: DefaultName,
+		FailOn:  findings.SeverityHigh,
+	}
+}
+
+// Start sets the commit's status to pending
+func (r *StatusReporter) Start(ctx context.Context, owner, repo, sha string) (*github.CommitStatus, error) {
+	ctx = github.WithConsumer(ctx, github.ConsumerAttestationChecks)
+	return r.client.CreateCommitStatus(ctx, owner, repo, sha, github.CommitStatus{
+		State:       github.CommitStatePending,
+		Context:     r.context(),
+		Description: "Verifying attestations and dependencies",
+		TargetURL:   r.TargetURL,
+	})
+}
+
+// Publish sets the commit's status from the result, replacing the pending
+// status Start set. The description is the title a check run would show.
+func (r *StatusReporter) Publish(ctx context.Context, owner, repo, sha string, result Result) (*github.CommitStatus, error) {
+	ctx = github.WithConsumer(ctx, github.ConsumerAttestationChecks)
+	failOn := r.FailOn
+	if failOn == "" {
+		failOn = findings.SeverityHigh
+	}
+	state := github.CommitStateSuccess
+	if result.Conclusion(failOn) == github.CheckConclusionFailure {
+		state = github.CommitStateFailure
+	}
+	return r.client.CreateCommitStatus(ctx, owner, repo, sha, github.CommitStatus{
+		State:       state,
+		Context:     r.context(),
+		Description: result.title(failOn),
+		TargetURL:   r.TargetURL,
+	})
+}
+
+// Fail sets the commit's status to error, for verifications that couldn't
+// run at all, e.g. because the bundle couldn't be fetched
+func (r *StatusReporter) Fail(ctx context.Context, owner, repo, sha string, err error) (*github.CommitStatus, error) {
+	ctx = github.WithConsumer(ctx, github.ConsumerAttestationChecks)
+	return r.client.CreateCommitStatus(ctx, owner, repo, sha, github.CommitStatus{
+		State:       github.CommitStateError,
+		Context:     r.context(),
+		Description: err.Error(),
+		TargetURL:   r.TargetURL,
+	})
+}
+
+func (r *StatusReporter) context() string {
+	if r.Context == "" {
+		return DefaultName
+	}
+	return r.Context
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
 )
 
 // State represents the circuit breaker state
@@ -23,6 +25,7 @@ type Config struct {
 	SuccessThreshold   int           // Number of successes needed to close from half-open
 	RequestTimeout     time.Duration // Timeout for individual requests
 	MaxConcurrentCalls int           // Maximum concurrent calls in half-open state
+	Clock              clock.Clock   // Defaults to the system clock; tests use clock.Fake
 }
 
 // DefaultConfig returns a default circuit breaker configuration
@@ -45,6 +48,7 @@ type Breaker struct {
 	lastFailureTime time.Time
 	mutex           sync.RWMutex
 	activeCalls     int
+	clock           clock.Clock
 }
 
 // New creates a new circuit breaker with the given configuration
@@ -52,6 +56,7 @@ func New(config Config) *Breaker {
 	return &Breaker{
 		config: config,
 		state:  StateClosed,
+		clock:  clock.OrReal(config.Clock),
 	}
 }
 
@@ -71,10 +76,6 @@ func (b *Breaker) Call(ctx context.Context, fn func() error) error {
 
 	defer b.afterCall(state == StateHalfOpen)
 
-	// Create context with timeout
-	callCtx, cancel := context.WithTimeout(ctx, b.config.RequestTimeout)
-	defer cancel()
-
 	// Execute the function in a goroutine to handle timeouts
 	errChan := make(chan error, 1)
 	go func() {
@@ -85,7 +86,10 @@ func (b *Breaker) Call(ctx context.Context, fn func() error) error {
 	case err := <-errChan:
 		b.onResult(err)
 		return err
-	case <-callCtx.Done():
+	case <-b.clock.After(b.config.RequestTimeout):
+		b.onResult(ErrRequestTimeout)
+		return ErrRequestTimeout
+	case <-ctx.Done():
 		b.onResult(ErrRequestTimeout)
 		return ErrRequestTimeout
 	}
@@ -96,7 +100,7 @@ func (b *Breaker) beforeCall() (State, error) {
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	now := time.Now()
+	now := b.clock.Now()
 
 	switch b.state {
 	case StateClosed:
@@ -150,7 +154,7 @@ func (b *Breaker) onResult(err error) {
 // onFailure handles a failed call
 func (b *Breaker) onFailure() {
 	b.failureCount++
-	b.lastFailureTime = time.Now()
+	b.lastFailureTime = b.clock.Now()
 
 	switch b.state {
 	case StateClosed:
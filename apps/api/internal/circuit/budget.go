@@ -0,0 +1,135 @@
+package circuit
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+)
+
+// ErrRetryBudgetExhausted rejects a retry because retries already make up too
+// much of recent traffic
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// BudgetConfig holds retry budget configuration
+type BudgetConfig struct {
+	Ratio      float64       // Retries allowed per first attempt, e.g. 0.2 for 20%
+	MinRetries int           // Retries always allowed per window, so quiet periods can still retry
+	Window     time.Duration // Sliding window traffic is counted over, in whole seconds
+	Clock      clock.Clock   // Defaults to the system clock; tests use clock.Fake
+}
+
+// DefaultBudgetConfig allows retries of up to 20% of requests over ten seconds
+func DefaultBudgetConfig() BudgetConfig {
+	return BudgetConfig{
+		Ratio:      0.2,
+		MinRetries: 10,
+		Window:     10 * time.Second,
+	}
+}
+
+// Budget caps retries at a fraction of recent first attempts. Sharing one
+// budget between the request queue and registry clients keeps an upstream
+// outage from multiplying into a retry storm: once retries exceed the ratio,
+// further retries fail fast with ErrRetryBudgetExhausted. A nil Budget allows
+// every retry.
+type Budget struct {
+	config  BudgetConfig
+	clock   clock.Clock
+	mutex   sync.Mutex
+	buckets []budgetBucket // One per second of the window, indexed by Unix second
+	stats   BudgetStats
+}
+
+// budgetBucket counts one second of traffic
+type budgetBucket struct {
+	second   int64
+	requests int64
+	retries  int64
+}
+
+// BudgetStats counts traffic since the budget was created
+type BudgetStats struct {
+	Requests int64 `json:"requests"`
+	Retries  int64 `json:"retries"`
+	Rejected int64 `json:"rejected"`
+}
+
+// NewBudget creates a retry budget
+func NewBudget(config BudgetConfig) *Budget {
+	seconds := int(config.Window / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &Budget{
+		config:  config,
+		clock:   clock.OrReal(config.Clock),
+		buckets: make([]budgetBucket, seconds),
+	}
+}
+
+// RecordRequest counts a first attempt, which earns retry allowance
+func (b *Budget) RecordRequest() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.bucket().requests++
+	b.stats.Requests++
+}
+
+// TryRetry spends allowance on a retry, or returns ErrRetryBudgetExhausted
+func (b *Budget) TryRetry() error {
+	if b == nil {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	current := b.bucket()
+	requests, retries := b.window()
+	if float64(retries) >= b.config.Ratio*float64(requests)+float64(b.config.MinRetries) {
+		b.stats.Rejected++
+		return ErrRetryBudgetExhausted
+	}
+
+	current.retries++
+	b.stats.Retries++
+	return nil
+}
+
+// Stats returns the budget's traffic counters
+func (b *Budget) Stats() BudgetStats {
+	if b == nil {
+		return BudgetStats{}
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.stats
+}
+
+// bucket returns the current second's bucket, clearing it if it last counted
+// an earlier window
+func (b *Budget) bucket() *budgetBucket {
+	second := b.clock.Now().Unix()
+	bucket := &b.buckets[int(second%int64(len(b.buckets)))]
+	if bucket.second != second {
+		*bucket = budgetBucket{second: second}
+	}
+	return bucket
+}
+
+// window sums the traffic counted within the window
+func (b *Budget) window() (requests, retries int64) {
+	oldest := b.clock.Now().Unix() - int64(len(b.buckets))
+	for _, bucket := range b.buckets {
+		if bucket.second > oldest {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+	return requests, retries
+}
@@ -0,0 +1,201 @@
+// Package clock abstracts time so the queue, circuit breaker and cache can be
+// driven by a fake clock in tests, advancing time deterministically instead of
+// sleeping
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits for durations to pass
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks at intervals, like time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real returns the system clock
+func Real() Clock {
+	return realClock{}
+}
+
+// OrReal returns c, or the system clock if c is nil
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real()
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.ticker.C }
+func (t realTicker) Stop()               { t.ticker.Stop() }
+
+// Fake is a clock that only moves when advanced. Timers and tickers fire
+// during Advance, in time order; like time.Ticker, a ticker drops ticks its
+// reader hasn't received.
+type Fake struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending After, Sleep or ticker
+type fakeWaiter struct {
+	at      time.Time
+	period  time.Duration // Zero for one-shot waiters
+	ch      chan time.Time
+	stopped bool
+}
+
+// NewFake creates a fake clock set to start
+func NewFake(start time.Time) *Fake {
+	f := &Fake{now: start}
+	f.cond = sync.NewCond(&f.mutex)
+	return f
+}
+
+// Now returns the fake time
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+// Since returns the fake time elapsed since t
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// After delivers the fake time once the clock has advanced by d
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.add(&fakeWaiter{at: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Sleep blocks until the clock has advanced by d
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+// NewTicker ticks every d of fake time
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	waiter := &fakeWaiter{at: f.now.Add(d), period: d, ch: make(chan time.Time, 1)}
+	f.add(waiter)
+	return &fakeTicker{clock: f, waiter: waiter}
+}
+
+func (f *Fake) add(waiter *fakeWaiter) {
+	f.waiters = append(f.waiters, waiter)
+	f.cond.Broadcast()
+}
+
+// Advance moves the clock forward by d, firing the timers and ticks due
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = f.now.Add(d)
+
+	sort.Slice(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+	pending := f.waiters[:0]
+	for _, waiter := range f.waiters {
+		if waiter.stopped {
+			continue
+		}
+		if waiter.at.After(f.now) {
+			pending = append(pending, waiter)
+			continue
+		}
+
+		select {
+		case waiter.ch <- waiter.at:
+		default:
+		}
+		if waiter.period > 0 {
+			for !waiter.at.After(f.now) {
+				waiter.at = waiter.at.Add(waiter.period)
+			}
+			pending = append(pending, waiter)
+		}
+	}
+	f.waiters = pending
+}
+
+// Waiters returns the number of pending timers and tickers
+func (f *Fake) Waiters() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.active()
+}
+
+// BlockUntil waits until at least n timers or tickers are pending, so a test
+// can advance the clock once the code under test is waiting on it
+func (f *Fake) BlockUntil(n int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for f.active() < n {
+		f.cond.Wait()
+	}
+}
+
+func (f *Fake) active() int {
+	count := 0
+	for _, waiter := range f.waiters {
+		if !waiter.stopped {
+			count++
+		}
+	}
+	return count
+}
+
+type fakeTicker struct {
+	clock  *Fake
+	waiter *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.waiter.ch }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	t.waiter.stopped = true
+}
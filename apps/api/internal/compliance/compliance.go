@@ -0,0 +1,222 @@
+// Package compliance answers whether an artifact was compliant at a point in
+// time, for incident forensics and audits. It evaluates the artifact against
+// the vulnerability advisories, identity policy and trust root retained in
+// the history as they stood at that time, rather than today's.
+package compliance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/salman-frs/keystone/apps/api/internal/advisories"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/trustroot"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/history"
+	"github.com/salman-frs/keystone/apps/api/internal/remediation"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// UploadPolicyKey is the history key of the policy uploads are verified against
+const UploadPolicyKey = "upload"
+
+// RetainPolicy records an identity policy in the history as in effect from at
+func RetainPolicy(ctx context.Context, store *history.Store, key string, policy attestation.IdentityPolicy, at time.Time) error {
+	data, err := yaml.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to encode policy %s: %w", key, err)
+	}
+	_, err = store.Record(ctx, history.KindPolicy, key, data, at)
+	return err
+}
+
+// Query selects the artifact and the point in time to evaluate
+type Query struct {
+	At        time.Time
+	Bundle    *attestation.Bundle
+	SBOM      *sbom.Document    // Components checked for advisories; optional
+	PolicyKey string            // History key of the identity policy; defaults to UploadPolicyKey
+	TrustKey  string            // History key of the trust root: a TUF mirror or trustroot.PinnedHistoryKey
+	Threshold findings.Severity // Vulnerabilities at or above it are non-compliant; defaults to HIGH
+}
+
+// Source identifies the retained version an evaluation used
+type Source struct {
+	Key        string    `json:"key"`
+	Digest     string    `json:"digest"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Report is the outcome of a point-in-time evaluation
+type Report struct {
+	At              time.Time                       `json:"at"`
+	Compliant       bool                            `json:"compliant"`
+	TrustRoot       Source                          `json:"trust_root"`
+	Policy          Source                          `json:"policy"`
+	Verification    *attestation.VerificationResult `json:"verification"`
+	Advisories      int                             `json:"advisories"` // Advisories known at the time
+	Vulnerabilities []findings.Finding              `json:"vulnerabilities,omitempty"`
+}
+
+// Evaluator evaluates artifacts against retained history
+type Evaluator struct {
+	history *history.Store
+}
+
+// NewEvaluator creates an evaluator over the history store
+func NewEvaluator(store *history.Store) *Evaluator {
+	return &Evaluator{history: store}
+}
+
+// Evaluate verifies the bundle with the trust root and policy in effect at
+// the query time and, given an SBOM, matches its components against the
+// advisories known then. The bundle must also have been signed by then: an
+// attestation made afterwards could not have vouched for the artifact.
+// Errors are returned when the history doesn't reach back to the query time.
+func (e *Evaluator) Evaluate(ctx context.Context, q Query) (*Report, error) {
+	if q.At.IsZero() {
+		return nil, fmt.Errorf("evaluation time is required")
+	}
+	if q.Bundle == nil {
+		return nil, fmt.Errorf("bundle is required")
+	}
+	if q.PolicyKey == "" {
+		q.PolicyKey = UploadPolicyKey
+	}
+	if q.Threshold == "" {
+		q.Threshold = findings.SeverityHigh
+	}
+	at := q.At.UTC()
+	report := &Report{At: at}
+
+	trustSnapshot, err := e.history.At(ctx, history.KindTrustRoot, q.TrustKey, at)
+	if err != nil {
+		return nil, err
+	}
+	if trustSnapshot == nil {
+		return nil, fmt.Errorf("no trust root %q was retained by %s", q.TrustKey, at.Format(time.RFC3339))
+	}
+	trust, err := trustroot.ParseTrustedRoot(trustSnapshot.Data)
+	if err != nil {
+		return nil, err
+	}
+	report.TrustRoot = sourceOf(trustSnapshot)
+
+	policySnapshot, err := e.history.At(ctx, history.KindPolicy, q.PolicyKey, at)
+	if err != nil {
+		return nil, err
+	}
+	if policySnapshot == nil {
+		return nil, fmt.Errorf("no policy %q was retained by %s", q.PolicyKey, at.Format(time.RFC3339))
+	}
+	var policy attestation.IdentityPolicy
+	if err := yaml.Unmarshal(policySnapshot.Data, &policy); err != nil {
+		return nil, fmt.Errorf("retained policy %q is invalid: %w", q.PolicyKey, err)
+	}
+	report.Policy = sourceOf(policySnapshot)
+
+	report.Verification, _ = attestation.VerifyBundle(q.Bundle, trust, policy)
+	checkSignedBefore(report.Verification, at)
+
+	if q.SBOM != nil {
+		if report.Vulnerabilities, report.Advisories, err = e.vulnerabilities(ctx, q.SBOM, at); err != nil {
+			return nil, err
+		}
+	}
+
+	report.Compliant = report.Verification.Valid
+	for _, finding := range report.Vulnerabilities {
+		if finding.Severity.AtLeast(q.Threshold) {
+			report.Compliant = false
+		}
+	}
+	return report, nil
+}
+
+// checkSignedBefore fails a valid result whose signature postdates the
+// evaluation time
+func checkSignedBefore(result *attestation.VerificationResult, at time.Time) {
+	if !result.Valid || result.SignedAt == nil {
+		return
+	}
+	if result.SignedAt.After(at) {
+		err := attestation.Errorf(attestation.CodeAttestationNotFound, "Attestation was signed at %s, after %s",
+			result.SignedAt.Format(time.RFC3339), at.Format(time.RFC3339))
+		result.Checks = append(result.Checks, attestation.Check{
+			Name:      attestation.CheckSignedBefore,
+			Status:    attestation.CheckFailed,
+			Detail:    err.Error(),
+			ErrorCode: attestation.CodeAttestationNotFound,
+		})
+		result.Fail(err, remediation.Context{Target: result.Subject, Issuer: result.Issuer})
+		return
+	}
+	result.Checks = append(result.Checks, attestation.Check{
+		Name:   attestation.CheckSignedBefore,
+		Status: attestation.CheckPassed,
+		Detail: fmt.Sprintf("Signed at %s", result.SignedAt.Format(time.RFC3339)),
+	})
+}
+
+// vulnerabilities matches the SBOM's components against the advisories in
+// effect at a time, skipping those withdrawn by then
+func (e *Evaluator) vulnerabilities(ctx context.Context, doc *sbom.Document, at time.Time) ([]findings.Finding, int, error) {
+	snapshots, err := e.history.AllAt(ctx, history.KindAdvisory, at)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var found []findings.Finding
+	known := 0
+	for _, snapshot := range snapshots {
+		var advisory github.Advisory
+		if err := json.Unmarshal(snapshot.Data, &advisory); err != nil {
+			continue
+		}
+		if advisory.WithdrawnAt != nil && !advisory.WithdrawnAt.After(at) {
+			continue
+		}
+		known++
+
+		for _, component := range doc.Components {
+			purl, err := component.PackageURL()
+			if err != nil {
+				continue
+			}
+			affected, patched := advisories.Affects(advisory, purl)
+			if !affected {
+				continue
+			}
+			found = append(found, advisoryFinding(snapshot.Key, advisory, component, purl, patched, at))
+		}
+	}
+	return found, known, nil
+}
+
+// advisoryFinding describes a component affected by an advisory
+func advisoryFinding(ghsaID string, advisory github.Advisory, component sbom.Component, purl *sbom.PackageURL, patched string, at time.Time) findings.Finding {
+	_, name, _ := advisories.PackageName(purl)
+
+	finding := findings.New("github-advisory", findings.CategoryVulnerability, findings.AdvisorySeverity(advisory.Severity), ghsaID, component.PURL, advisory.Summary)
+	finding.Component = name
+	finding.Version = purl.Version
+	finding.PURL = component.PURL
+	finding.DetectedAt = at
+	if advisory.CVEID != "" {
+		finding.Metadata["cve_id"] = advisory.CVEID
+	}
+	if patched != "" {
+		finding.Metadata["fixed_version"] = patched
+	}
+	return finding
+}
+
+// sourceOf describes the snapshot an evaluation used
+func sourceOf(snapshot *history.Snapshot) Source {
+	return Source{Key: snapshot.Key, Digest: snapshot.Digest, RecordedAt: snapshot.RecordedAt}
+}
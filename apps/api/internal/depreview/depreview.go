@@ -0,0 +1,111 @@
+// Package depreview evaluates the dependencies a pull request introduces,
+// as reported by GitHub's dependency review, so they can gate the merge like
+// any other findings. Vulnerable dependencies become vulnerability findings
+// and dependencies under a disallowed license become policy findings.
+package depreview
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// Source is the finding source of dependency review
+const Source = "github-dependency-review"
+
+// noAssertion is how SPDX records a license nobody determined
+const noAssertion = "NOASSERTION"
+
+// LicensePolicy lists the SPDX license identifiers dependencies may use
+type LicensePolicy struct {
+	Allow []string `yaml:"allow,omitempty"` // When set, any other license is disallowed, and unknown ones are reported
+	Deny  []string `yaml:"deny,omitempty"`
+}
+
+// Findings describes what the added dependencies introduce. Removed
+// dependencies can only reduce risk, so they're ignored.
+func Findings(changes []github.DependencyChange, policy LicensePolicy) []findings.Finding {
+	var found []findings.Finding
+	for _, change := range changes {
+		if change.ChangeType != github.DependencyAdded {
+			continue
+		}
+		subject := change.PackageURL
+		if subject == "" {
+			subject = change.Ecosystem + "/" + change.Name + "@" + change.Version
+		}
+
+		for _, vulnerability := range change.Vulnerabilities {
+			finding := dependencyFinding(change, findings.CategoryVulnerability, findings.AdvisorySeverity(vulnerability.Severity),
+				vulnerability.AdvisoryGHSAID, subject, vulnerability.AdvisorySummary)
+			finding.Metadata["advisory_url"] = vulnerability.AdvisoryURL
+			found = append(found, finding)
+		}
+
+		switch allowed, known := policy.Allows(change.License); {
+		case !known && len(policy.Allow) > 0:
+			found = append(found, dependencyFinding(change, findings.CategoryPolicy, findings.SeverityLow, "unknown-license", subject,
+				fmt.Sprintf("%s %s has no known license", change.Name, change.Version)))
+		case !allowed:
+			found = append(found, dependencyFinding(change, findings.CategoryPolicy, findings.SeverityHigh, "disallowed-license", subject,
+				fmt.Sprintf("%s %s is licensed under %s, which isn't allowed", change.Name, change.Version, change.License)))
+		}
+	}
+	return found
+}
+
+// dependencyFinding describes an added dependency, located at its manifest
+func dependencyFinding(change github.DependencyChange, category findings.Category, severity findings.Severity, rule, subject, title string) findings.Finding {
+	finding := findings.New(Source, category, severity, rule, subject, title)
+	finding.Component = change.Name
+	finding.Version = change.Version
+	finding.PURL = change.PackageURL
+	if change.Manifest != "" {
+		finding.Metadata[findings.MetadataPath] = change.Manifest
+	}
+	if change.Scope != "" {
+		finding.Metadata["scope"] = change.Scope
+	}
+	return finding
+}
+
+// Allows reports whether an SPDX license expression satisfies the policy,
+// and whether the license is known at all. An expression is allowed when
+// one of its OR alternatives only uses allowed licenses. Parentheses are
+// ignored, so AND always binds tighter than OR.
+func (p LicensePolicy) Allows(expression string) (allowed, known bool) {
+	expression = strings.NewReplacer("(", " ", ")", " ").Replace(expression)
+	if strings.TrimSpace(expression) == "" || strings.EqualFold(strings.TrimSpace(expression), noAssertion) {
+		return len(p.Allow) == 0, false
+	}
+
+	for _, alternative := range strings.Split(expression, " OR ") {
+		allowed = true
+		for _, term := range strings.Split(alternative, " AND ") {
+			license := strings.TrimSpace(term)
+			if i := strings.Index(license, " WITH "); i >= 0 {
+				license = strings.TrimSpace(license[:i])
+			}
+			if contains(p.Deny, license) || (len(p.Allow) > 0 && !contains(p.Allow, license)) {
+				allowed = false
+				break
+			}
+		}
+		if allowed {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// contains reports whether a license is listed, ignoring case
+func contains(licenses []string, license string) bool {
+	for _, listed := range licenses {
+		if strings.EqualFold(listed, license) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,281 @@
+// Package diagnostics captures runtime profiles from a running process on
+// demand and keeps them in the database, so performance problems seen in
+// production can be diagnosed without redeploying
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+)
+
+// Capture errors
+var (
+	ErrCaptureInProgress = errors.New("a profile capture is already in progress")
+	ErrBundleNotFound    = errors.New("profile bundle not found")
+	ErrDurationTooLong   = errors.New("profile duration is too long")
+)
+
+// Config holds profile capture configuration
+type Config struct {
+	CPUDuration time.Duration // CPU profile length when the caller doesn't choose one
+	MaxDuration time.Duration // Longest CPU profile a caller may request
+	Retain      int           // Bundles kept; older ones are deleted after each capture
+	Clock       clock.Clock   // Defaults to the system clock
+}
+
+// DefaultConfig captures 30s CPU profiles, allows up to two minutes, and keeps
+// the 20 most recent bundles
+func DefaultConfig() Config {
+	return Config{
+		CPUDuration: 30 * time.Second,
+		MaxDuration: 2 * time.Minute,
+		Retain:      20,
+	}
+}
+
+// Bundle describes a stored profile bundle: a gzipped tar holding cpu.pprof,
+// heap.pprof, goroutine.pprof and runtime.json
+type Bundle struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Host      string    `json:"host"`
+	Duration  int64     `json:"duration_seconds"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RuntimeStats is the runtime.json entry of a bundle
+type RuntimeStats struct {
+	GoVersion  string           `json:"go_version"`
+	NumCPU     int              `json:"num_cpu"`
+	GOMAXPROCS int              `json:"gomaxprocs"`
+	Goroutines int              `json:"goroutines"`
+	Memory     runtime.MemStats `json:"memory"`
+}
+
+// Profiler captures profile bundles into a store. Only one capture runs at a
+// time, because the runtime supports a single CPU profile.
+type Profiler struct {
+	store     *Store
+	config    Config
+	clock     clock.Clock
+	capturing atomic.Bool
+}
+
+// NewProfiler creates a profiler storing bundles in the store
+func NewProfiler(store *Store, config Config) *Profiler {
+	return &Profiler{
+		store:  store,
+		config: config,
+		clock:  clock.OrReal(config.Clock),
+	}
+}
+
+// Capture records a CPU profile for the duration, or the configured default
+// when zero, then snapshots the heap and goroutines and stores the bundle.
+// Cancelling the context ends the CPU profile early and discards it.
+func (p *Profiler) Capture(ctx context.Context, duration time.Duration) (Bundle, error) {
+	if duration <= 0 {
+		duration = p.config.CPUDuration
+	}
+	if p.config.MaxDuration > 0 && duration > p.config.MaxDuration {
+		return Bundle{}, fmt.Errorf("%w: %s exceeds the %s limit", ErrDurationTooLong, duration, p.config.MaxDuration)
+	}
+	if !p.capturing.CompareAndSwap(false, true) {
+		return Bundle{}, ErrCaptureInProgress
+	}
+	defer p.capturing.Store(false)
+
+	var cpu bytes.Buffer
+	if err := pprof.StartCPUProfile(&cpu); err != nil {
+		// Another CPU profile, e.g. from /debug/pprof/profile, is running
+		return Bundle{}, fmt.Errorf("%w: %v", ErrCaptureInProgress, err)
+	}
+	select {
+	case <-ctx.Done():
+		pprof.StopCPUProfile()
+		return Bundle{}, ctx.Err()
+	case <-p.clock.After(duration):
+	}
+	pprof.StopCPUProfile()
+
+	// Collect garbage first so the heap profile reflects live objects
+	runtime.GC()
+	files := []struct {
+		name string
+		data func() ([]byte, error)
+	}{
+		{"cpu.pprof", func() ([]byte, error) { return cpu.Bytes(), nil }},
+		{"heap.pprof", lookupProfile("heap")},
+		{"goroutine.pprof", lookupProfile("goroutine")},
+		{"runtime.json", runtimeStats},
+	}
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	now := p.clock.Now().UTC()
+	for _, file := range files {
+		data, err := file.data()
+		if err != nil {
+			return Bundle{}, fmt.Errorf("failed to collect %s: %w", file.name, err)
+		}
+		header := &tar.Header{Name: file.name, Mode: 0o644, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return Bundle{}, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return Bundle{}, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return Bundle{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return Bundle{}, err
+	}
+
+	host, _ := os.Hostname()
+	bundle := Bundle{
+		Name:      fmt.Sprintf("profile-%s.tar.gz", now.Format("20060102T150405Z")),
+		Host:      host,
+		Duration:  int64(duration / time.Second),
+		Size:      int64(archive.Len()),
+		CreatedAt: now,
+	}
+	// Store even if the caller went away; the profile is already paid for
+	storeCtx := context.WithoutCancel(ctx)
+	id, err := p.store.Add(storeCtx, bundle, archive.Bytes())
+	if err != nil {
+		return Bundle{}, err
+	}
+	bundle.ID = id
+
+	if p.config.Retain > 0 {
+		if err := p.store.Prune(storeCtx, p.config.Retain); err != nil {
+			return bundle, err
+		}
+	}
+	return bundle, nil
+}
+
+func lookupProfile(name string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		var buf bytes.Buffer
+		if err := pprof.Lookup(name).WriteTo(&buf, 0); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+func runtimeStats() ([]byte, error) {
+	stats := RuntimeStats{
+		GoVersion:  runtime.Version(),
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Goroutines: runtime.NumGoroutine(),
+	}
+	runtime.ReadMemStats(&stats.Memory)
+	return json.MarshalIndent(stats, "", "  ")
+}
+
+// Store persists profile bundles in SQLite
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a bundle store on the migrated database
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Add stores a bundle's archive and returns its ID
+func (s *Store) Add(ctx context.Context, bundle Bundle, data []byte) (int64, error) {
+	insertSQL := `
+		INSERT INTO diagnostic_profiles (name, host, duration_seconds, size, data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := s.db.ExecContext(ctx, insertSQL, bundle.Name, bundle.Host, bundle.Duration,
+		len(data), data, bundle.CreatedAt.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to store profile bundle %s: %w", bundle.Name, err)
+	}
+	return result.LastInsertId()
+}
+
+// List returns up to limit bundles, most recent first
+func (s *Store) List(ctx context.Context, limit int) ([]Bundle, error) {
+	query := `
+		SELECT id, name, host, duration_seconds, size, created_at
+		FROM diagnostic_profiles
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profile bundles: %w", err)
+	}
+	defer rows.Close()
+
+	bundles := []Bundle{}
+	for rows.Next() {
+		var bundle Bundle
+		if err := rows.Scan(&bundle.ID, &bundle.Name, &bundle.Host, &bundle.Duration, &bundle.Size, &bundle.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan profile bundle: %w", err)
+		}
+		bundles = append(bundles, bundle)
+	}
+	return bundles, rows.Err()
+}
+
+// Get returns a bundle and its archive
+func (s *Store) Get(ctx context.Context, id int64) (Bundle, []byte, error) {
+	query := `
+		SELECT id, name, host, duration_seconds, size, created_at, data
+		FROM diagnostic_profiles
+		WHERE id = ?
+	`
+
+	var bundle Bundle
+	var data []byte
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&bundle.ID, &bundle.Name, &bundle.Host,
+		&bundle.Duration, &bundle.Size, &bundle.CreatedAt, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Bundle{}, nil, ErrBundleNotFound
+	}
+	if err != nil {
+		return Bundle{}, nil, fmt.Errorf("failed to load profile bundle %d: %w", id, err)
+	}
+	return bundle, data, nil
+}
+
+// Prune deletes all but the most recent keep bundles
+func (s *Store) Prune(ctx context.Context, keep int) error {
+	deleteSQL := `
+		DELETE FROM diagnostic_profiles
+		WHERE id NOT IN (
+			SELECT id FROM diagnostic_profiles ORDER BY created_at DESC, id DESC LIMIT ?
+		)
+	`
+
+	if _, err := s.db.ExecContext(ctx, deleteSQL, keep); err != nil {
+		return fmt.Errorf("failed to prune profile bundles: %w", err)
+	}
+	return nil
+}
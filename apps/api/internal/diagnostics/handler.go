@@ -0,0 +1,124 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PprofHandler serves the standard net/http/pprof endpoints. It expects to be
+// mounted at /debug/pprof/ behind admin authentication.
+func PprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// Handler serves profile bundles for operators:
+//
+//	POST /          captures a bundle (?seconds=, default 30) and returns it
+//	GET  /          lists bundles (?limit=, default 20)
+//	GET  /{id}      downloads a bundle's archive
+//
+// Mount it under a prefix with http.StripPrefix and behind admin
+// authentication. Captures block for the CPU profile's duration.
+func (p *Profiler) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(r.URL.Path, "/")
+		switch {
+		case path == "" && r.Method == http.MethodPost:
+			p.handleCapture(w, r)
+		case path == "" && r.Method == http.MethodGet:
+			p.handleList(w, r)
+		case r.Method == http.MethodGet:
+			p.handleDownload(w, r, path)
+		default:
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+}
+
+func (p *Profiler) handleCapture(w http.ResponseWriter, r *http.Request) {
+	var duration time.Duration
+	if value := r.URL.Query().Get("seconds"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "seconds must be a positive integer")
+			return
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	bundle, err := p.Capture(r.Context(), duration)
+	switch {
+	case errors.Is(err, ErrCaptureInProgress):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrDurationTooLong):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusCreated, bundle)
+	}
+}
+
+func (p *Profiler) handleList(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+
+	bundles, err := p.store.List(r.Context(), limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, bundles)
+}
+
+func (p *Profiler) handleDownload(w http.ResponseWriter, r *http.Request, path string) {
+	id, err := strconv.ParseInt(path, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	bundle, data, err := p.store.Get(r.Context(), id)
+	if errors.Is(err, ErrBundleNotFound) {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", bundle.Name))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
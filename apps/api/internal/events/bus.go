@@ -0,0 +1,261 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+)
+
+// Event types published by the platform
+const (
+	TypeScanCompleted      = "scan.completed"
+	TypeVerificationFailed = "verification.failed"
+	TypeModeChanged        = "mode.changed"
+	TypeJobRequested       = "job.requested"
+	TypeJobCompleted       = "job.completed"
+	TypeJobFailed          = "job.failed"
+	TypeSLOBurnRate        = "slo.burn_rate"
+	TypeIdentityMisuse     = "rekor.identity_misuse"
+	TypeIdentityChanged    = "tofu.identity_changed"
+	TypeAdminAction        = "audit.admin_action"
+	TypeFaultsChanged      = "faults.changed" // Payload is faults.Changed; only builds with the faults tag publish it
+)
+
+// Backend names
+const (
+	BackendMemory = "memory"
+	BackendNATS   = "nats"
+	BackendKafka  = "kafka"
+)
+
+// ErrBusClosed is returned when publishing to or subscribing on a closed bus
+var ErrBusClosed = errors.New("event bus is closed")
+
+// Event is the envelope carried by every bus backend
+type Event struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Source string          `json:"source"`
+	Time   time.Time       `json:"time"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// NewEvent creates an event with a random ID, encoding data as the payload
+func NewEvent(eventType, source string, data interface{}) (Event, error) {
+	event := Event{
+		ID:     newID(),
+		Type:   eventType,
+		Source: source,
+		Time:   time.Now().UTC(),
+	}
+
+	if data != nil {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return Event{}, fmt.Errorf("failed to encode %s payload: %w", eventType, err)
+		}
+		// Events feed the audit trail and SIEM, so credentials are redacted
+		event.Data = json.RawMessage(secret.Scrub(string(payload)))
+	}
+
+	return event, nil
+}
+
+// Decode unmarshals the event payload into v
+func (e Event) Decode(v interface{}) error {
+	if len(e.Data) == 0 {
+		return fmt.Errorf("event %s has no payload", e.ID)
+	}
+	return json.Unmarshal(e.Data, v)
+}
+
+// ScanCompleted is the payload of TypeScanCompleted
+type ScanCompleted struct {
+	ScanID        string         `json:"scan_id"`
+	Target        string         `json:"target"`
+	FindingCount  int            `json:"finding_count"`
+	SeverityCount map[string]int `json:"severity_count,omitempty"`
+}
+
+// VerificationFailed is the payload of TypeVerificationFailed
+type VerificationFailed struct {
+	Target        string            `json:"target"`
+	ErrorCode     string            `json:"error_code"`
+	Message       string            `json:"message"`
+	MessageID     string            `json:"message_id,omitempty"`     // Catalog message notifications render in their reader's locale
+	MessageParams map[string]string `json:"message_params,omitempty"` // Parameters of MessageID
+}
+
+// ModeChanged is the payload of TypeModeChanged
+type ModeChanged struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// SLOBurnRate is the payload of TypeSLOBurnRate, published when a burn-rate
+// alert starts or stops firing
+type SLOBurnRate struct {
+	Operation     string  `json:"operation"`
+	Severity      string  `json:"severity"` // page or ticket
+	Firing        bool    `json:"firing"`   // False when the alert resolves
+	Latency       string  `json:"latency"`
+	Target        float64 `json:"target"`
+	LongWindow    string  `json:"long_window"`
+	ShortWindow   string  `json:"short_window"`
+	Threshold     float64 `json:"threshold"`
+	LongBurnRate  float64 `json:"long_burn_rate"` // Rates when the alert fired
+	ShortBurnRate float64 `json:"short_burn_rate"`
+}
+
+// IdentityMisuse is the payload of TypeIdentityMisuse, published when Rekor
+// logs an entry signed by a monitored identity that this deployment did not
+// produce
+type IdentityMisuse struct {
+	LogURL         string    `json:"log_url"`
+	UUID           string    `json:"uuid"`
+	LogIndex       int64     `json:"log_index"`
+	IntegratedTime time.Time `json:"integrated_time"`
+	Kind           string    `json:"kind"` // Rekor entry type, e.g. hashedrekord or dsse
+	Identity       string    `json:"identity"`
+	Issuer         string    `json:"issuer"`
+	Repository     string    `json:"repository,omitempty"`
+	WorkflowRef    string    `json:"workflow_ref,omitempty"`
+	Ref            string    `json:"ref,omitempty"`
+}
+
+// IdentityChanged is the payload of TypeIdentityChanged, published when an
+// artifact's attestation is signed by an identity other than the one pinned
+// on first use
+type IdentityChanged struct {
+	Scope          string    `json:"scope"`
+	PinnedIssuer   string    `json:"pinned_issuer"`
+	PinnedIdentity string    `json:"pinned_identity"`
+	Issuer         string    `json:"issuer"`
+	Identity       string    `json:"identity"`
+	FirstSeen      time.Time `json:"first_seen"`
+	Enforced       bool      `json:"enforced"` // False when the attestation was still accepted
+}
+
+// AdminAction is the payload of TypeAdminAction, published when an
+// administrator changes quotas, identity pins or injected faults
+type AdminAction struct {
+	Action     string `json:"action"` // e.g. quota.override_set or identity_pin.reset
+	Target     string `json:"target"` // Tenant or scope acted on
+	Tenant     string `json:"tenant,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// Handler processes a delivered event
+type Handler func(ctx context.Context, event Event) error
+
+// Subscription is an active registration of a handler on the bus
+type Subscription interface {
+	Unsubscribe() error
+}
+
+// Bus publishes events and delivers them to subscribers
+type Bus interface {
+	Publish(ctx context.Context, event Event) error
+	Subscribe(eventType string, handler Handler, opts ...SubscribeOption) (Subscription, error)
+	Close() error
+}
+
+// subscribeOptions holds per-subscription settings
+type subscribeOptions struct {
+	queueGroup string
+}
+
+// SubscribeOption configures a subscription
+type SubscribeOption func(*subscribeOptions)
+
+// WithQueueGroup load-balances events across subscribers sharing the group name,
+// so each event is handled by exactly one member instead of every subscriber
+func WithQueueGroup(name string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.queueGroup = name
+	}
+}
+
+func applySubscribeOptions(opts []SubscribeOption) subscribeOptions {
+	var options subscribeOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// Config holds event bus configuration
+type Config struct {
+	Backend     string   // memory, nats or kafka
+	NATSURL     string   // NATS server URL
+	Brokers     []string // Kafka bootstrap brokers
+	TopicPrefix string   // Prefix for NATS subjects and Kafka topics
+	ClientName  string   // Connection name reported to the broker
+}
+
+// DefaultConfig returns the in-process bus configuration
+func DefaultConfig() Config {
+	return Config{
+		Backend:     BackendMemory,
+		NATSURL:     "nats://127.0.0.1:4222",
+		Brokers:     []string{"127.0.0.1:9092"},
+		TopicPrefix: "keystone",
+		ClientName:  "keystone",
+	}
+}
+
+// ConfigFromEnv overlays EVENT_BUS_BACKEND, NATS_URL and KAFKA_BROKERS on the defaults
+func ConfigFromEnv() Config {
+	config := DefaultConfig()
+	if backend := os.Getenv("EVENT_BUS_BACKEND"); backend != "" {
+		config.Backend = strings.ToLower(backend)
+	}
+	if url := os.Getenv("NATS_URL"); url != "" {
+		config.NATSURL = url
+	}
+	if brokers := os.Getenv("KAFKA_BROKERS"); brokers != "" {
+		config.Brokers = strings.Split(brokers, ",")
+	}
+	return config
+}
+
+// New creates a bus for the configured backend
+func New(config Config) (Bus, error) {
+	switch config.Backend {
+	case "", BackendMemory:
+		return NewMemoryBus(), nil
+	case BackendNATS:
+		return NewNATSBus(config)
+	case BackendKafka:
+		return NewKafkaBus(config)
+	default:
+		return nil, fmt.Errorf("unknown event bus backend %q", config.Backend)
+	}
+}
+
+// topicName maps an event type to a broker subject or topic
+func topicName(prefix, eventType string) string {
+	if prefix == "" {
+		return eventType
+	}
+	return prefix + "." + eventType
+}
+
+// newID returns a random 128-bit hex identifier
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,184 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaBus publishes events as JSON messages on Kafka topics
+type KafkaBus struct {
+	config Config
+	writer *kafka.Writer
+
+	mutex  sync.Mutex
+	subs   map[*kafkaSubscription]struct{}
+	closed bool
+}
+
+// kafkaSubscription is a consumer loop reading one topic
+type kafkaSubscription struct {
+	bus    *KafkaBus
+	reader *kafka.Reader
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewKafkaBus creates a Kafka-backed bus; connections are established lazily
+func NewKafkaBus(config Config) (*KafkaBus, error) {
+	if len(config.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka event bus requires at least one broker")
+	}
+
+	return &KafkaBus{
+		config: config,
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(config.Brokers...),
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+			BatchTimeout:           10 * time.Millisecond,
+		},
+		subs: make(map[*kafkaSubscription]struct{}),
+	}, nil
+}
+
+// Publish writes the event to the topic for its type, keyed by event ID
+func (b *KafkaBus) Publish(ctx context.Context, event Event) error {
+	b.mutex.Lock()
+	closed := b.closed
+	b.mutex.Unlock()
+	if closed {
+		return ErrBusClosed
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+	}
+
+	err = b.writer.WriteMessages(ctx, kafka.Message{
+		Topic: topicName(b.config.TopicPrefix, event.Type),
+		Key:   []byte(event.ID),
+		Value: data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish event %s: %w", event.ID, err)
+	}
+	return nil
+}
+
+// Subscribe starts a consumer for an event type. A queue group maps to a Kafka
+// consumer group; without one each subscription gets its own group and sees every event.
+func (b *KafkaBus) Subscribe(eventType string, handler Handler, opts ...SubscribeOption) (Subscription, error) {
+	options := applySubscribeOptions(opts)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return nil, ErrBusClosed
+	}
+
+	groupID := options.queueGroup
+	startOffset := kafka.FirstOffset
+	if groupID == "" {
+		groupID = fmt.Sprintf("%s-%s", b.config.ClientName, newID())
+		startOffset = kafka.LastOffset // Broadcast subscribers only see new events
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &kafkaSubscription{
+		bus: b,
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers:     b.config.Brokers,
+			Topic:       topicName(b.config.TopicPrefix, eventType),
+			GroupID:     groupID,
+			StartOffset: startOffset,
+		}),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	b.subs[sub] = struct{}{}
+
+	go sub.consume(ctx, eventType, handler)
+
+	return sub, nil
+}
+
+// consume fetches messages until cancelled, committing each after its handler runs
+func (s *kafkaSubscription) consume(ctx context.Context, eventType string, handler Handler) {
+	defer close(s.done)
+
+	for {
+		msg, err := s.reader.FetchMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			log.Printf("Kafka fetch for %s failed: %v", eventType, err)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		var event Event
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			log.Printf("Dropping malformed event on %s: %v", msg.Topic, err)
+		} else if err := handler(ctx, event); err != nil {
+			log.Printf("Event handler for %s failed on %s: %v", eventType, event.ID, err)
+		}
+
+		if err := s.reader.CommitMessages(ctx, msg); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Kafka commit for %s failed: %v", eventType, err)
+		}
+	}
+}
+
+// Unsubscribe stops the consumer loop and closes its reader
+func (s *kafkaSubscription) Unsubscribe() error {
+	s.bus.mutex.Lock()
+	delete(s.bus.subs, s)
+	s.bus.mutex.Unlock()
+
+	return s.stop()
+}
+
+func (s *kafkaSubscription) stop() error {
+	s.cancel()
+	<-s.done
+	return s.reader.Close()
+}
+
+// Close stops all consumers and flushes pending writes
+func (b *KafkaBus) Close() error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return nil
+	}
+	b.closed = true
+	subs := b.subs
+	b.subs = make(map[*kafkaSubscription]struct{})
+	b.mutex.Unlock()
+
+	var firstErr error
+	for sub := range subs {
+		if err := sub.stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := b.writer.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
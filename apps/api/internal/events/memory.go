@@ -0,0 +1,119 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// MemoryBus delivers events to subscribers within the current process
+type MemoryBus struct {
+	mutex    sync.RWMutex
+	subs     map[string][]*memorySubscription
+	next     map[string]int // Round-robin position per type and queue group
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+// memorySubscription is a handler registered on a MemoryBus
+type memorySubscription struct {
+	bus        *MemoryBus
+	eventType  string
+	queueGroup string
+	handler    Handler
+}
+
+// NewMemoryBus creates a new in-process event bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		subs: make(map[string][]*memorySubscription),
+		next: make(map[string]int),
+	}
+}
+
+// Publish dispatches the event asynchronously to every subscriber, or to one
+// member of each queue group
+func (b *MemoryBus) Publish(ctx context.Context, event Event) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	groups := make(map[string][]*memorySubscription)
+	for _, sub := range b.subs[event.Type] {
+		if sub.queueGroup == "" {
+			b.dispatch(sub, event)
+			continue
+		}
+		groups[sub.queueGroup] = append(groups[sub.queueGroup], sub)
+	}
+
+	for group, members := range groups {
+		key := event.Type + "/" + group
+		b.dispatch(members[b.next[key]%len(members)], event)
+		b.next[key]++
+	}
+
+	return nil
+}
+
+// dispatch runs a handler in its own goroutine; the caller must hold the lock
+func (b *MemoryBus) dispatch(sub *memorySubscription, event Event) {
+	b.inflight.Add(1)
+	go func() {
+		defer b.inflight.Done()
+		if err := sub.handler(context.Background(), event); err != nil {
+			log.Printf("Event handler for %s failed on %s: %v", sub.eventType, event.ID, err)
+		}
+	}()
+}
+
+// Subscribe registers a handler for an event type
+func (b *MemoryBus) Subscribe(eventType string, handler Handler, opts ...SubscribeOption) (Subscription, error) {
+	options := applySubscribeOptions(opts)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return nil, ErrBusClosed
+	}
+
+	sub := &memorySubscription{
+		bus:        b,
+		eventType:  eventType,
+		queueGroup: options.queueGroup,
+		handler:    handler,
+	}
+	b.subs[eventType] = append(b.subs[eventType], sub)
+
+	return sub, nil
+}
+
+// Unsubscribe removes the handler from the bus
+func (s *memorySubscription) Unsubscribe() error {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+
+	subs := s.bus.subs[s.eventType]
+	for i, sub := range subs {
+		if sub == s {
+			s.bus.subs[s.eventType] = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Close stops accepting events and waits for in-flight handlers to finish
+func (b *MemoryBus) Close() error {
+	b.mutex.Lock()
+	b.closed = true
+	b.subs = make(map[string][]*memorySubscription)
+	b.mutex.Unlock()
+
+	b.inflight.Wait()
+	return nil
+}
@@ -0,0 +1,97 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSBus publishes events as JSON messages on NATS subjects
+type NATSBus struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// NewNATSBus connects to the configured NATS server
+func NewNATSBus(config Config) (*NATSBus, error) {
+	conn, err := nats.Connect(config.NATSURL,
+		nats.Name(config.ClientName),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("NATS disconnected: %v", err)
+			}
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %w", config.NATSURL, err)
+	}
+
+	return &NATSBus{conn: conn, prefix: config.TopicPrefix}, nil
+}
+
+// Publish sends the event to the subject for its type
+func (b *NATSBus) Publish(ctx context.Context, event Event) error {
+	if b.conn.IsClosed() {
+		return ErrBusClosed
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+	}
+
+	if err := b.conn.Publish(topicName(b.prefix, event.Type), data); err != nil {
+		return fmt.Errorf("failed to publish event %s: %w", event.ID, err)
+	}
+
+	// Flush so the publish is acknowledged by the server before returning
+	return b.conn.FlushWithContext(ctx)
+}
+
+// Subscribe registers a handler on the subject for an event type
+func (b *NATSBus) Subscribe(eventType string, handler Handler, opts ...SubscribeOption) (Subscription, error) {
+	if b.conn.IsClosed() {
+		return nil, ErrBusClosed
+	}
+
+	options := applySubscribeOptions(opts)
+	subject := topicName(b.prefix, eventType)
+
+	callback := func(msg *nats.Msg) {
+		var event Event
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			log.Printf("Dropping malformed event on %s: %v", msg.Subject, err)
+			return
+		}
+		if err := handler(context.Background(), event); err != nil {
+			log.Printf("Event handler for %s failed on %s: %v", eventType, event.ID, err)
+		}
+	}
+
+	var sub *nats.Subscription
+	var err error
+	if options.queueGroup != "" {
+		sub, err = b.conn.QueueSubscribe(subject, options.queueGroup, callback)
+	} else {
+		sub, err = b.conn.Subscribe(subject, callback)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+
+	return sub, nil
+}
+
+// Close drains pending messages and closes the connection
+func (b *NATSBus) Close() error {
+	if b.conn.IsClosed() {
+		return nil
+	}
+	return b.conn.Drain()
+}
@@ -0,0 +1,566 @@
+// Package exceptions records risk acceptances for vulnerability findings.
+// Acceptances at or above a severity threshold only take effect once enough
+// owners of the affected scope have approved them, and every acceptance
+// expires. Each step is kept in an audit trail.
+package exceptions
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+)
+
+var (
+	ErrNotFound    = errors.New("exception not found")
+	ErrNotPending  = errors.New("exception is not awaiting approval")
+	ErrNotApprover = errors.New("not an eligible approver for this exception")
+)
+
+// Status is the lifecycle state of an exception
+type Status string
+
+const (
+	StatusPending  Status = "pending"  // Awaiting approvals; not applied
+	StatusApproved Status = "approved" // Applied until it expires
+	StatusRejected Status = "rejected" // Declined by an approver
+	StatusRevoked  Status = "revoked"  // Withdrawn after it was requested
+)
+
+// Audit actions
+const (
+	ActionRequested = "requested"
+	ActionApproved  = "approved"
+	ActionRejected  = "rejected"
+	ActionActivated = "activated"
+	ActionRevoked   = "revoked"
+)
+
+// Config holds the approval policy
+type Config struct {
+	Threshold findings.Severity // Acceptances at or above this severity need approval
+	Approvals int               // Distinct approvals required above the threshold
+	MaxTTL    time.Duration     // Longest an acceptance may last
+	Clock     clock.Clock       // Defaults to the system clock
+}
+
+// DefaultConfig requires two approvals for HIGH and CRITICAL findings and
+// caps acceptances at 90 days
+func DefaultConfig() Config {
+	return Config{
+		Threshold: findings.SeverityHigh,
+		Approvals: 2,
+		MaxTTL:    90 * 24 * time.Hour,
+	}
+}
+
+// Request asks for a finding to be accepted in a scope
+type Request struct {
+	Scope       string            `json:"scope"`
+	FindingID   string            `json:"finding_id"`
+	Severity    findings.Severity `json:"severity"`
+	Reason      string            `json:"reason"`
+	RequestedBy string            `json:"requested_by"`
+	ExpiresAt   time.Time         `json:"expires_at,omitempty"` // Defaults to the maximum TTL
+}
+
+// Approver is an owner eligible to sign off on an exception
+type Approver struct {
+	Name      string     `json:"name"`
+	Decision  string     `json:"decision,omitempty"` // approved or rejected once decided
+	Comment   string     `json:"comment,omitempty"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+}
+
+// Exception is a recorded risk acceptance
+type Exception struct {
+	ID                string            `json:"id"`
+	Scope             string            `json:"scope"`
+	FindingID         string            `json:"finding_id"`
+	Severity          findings.Severity `json:"severity"`
+	Reason            string            `json:"reason"`
+	RequestedBy       string            `json:"requested_by"`
+	Status            Status            `json:"status"`
+	RequiredApprovals int               `json:"required_approvals"`
+	Approvers         []Approver        `json:"approvers,omitempty"`
+	ExpiresAt         time.Time         `json:"expires_at"`
+	CreatedAt         time.Time         `json:"created_at"`
+	DecidedAt         *time.Time        `json:"decided_at,omitempty"`
+}
+
+// Active reports whether the exception is applied at a given time
+func (e *Exception) Active(at time.Time) bool {
+	return e.Status == StatusApproved && at.Before(e.ExpiresAt)
+}
+
+// Covers reports whether the exception accepts a finding: the same finding,
+// no more severe than the severity that was approved
+func (e *Exception) Covers(finding findings.Finding) bool {
+	return e.FindingID == finding.ID && e.Severity.AtLeast(finding.Severity)
+}
+
+// AuditEntry is one step in an exception's history
+type AuditEntry struct {
+	ExceptionID string    `json:"exception_id"`
+	Actor       string    `json:"actor"`
+	Action      string    `json:"action"`
+	Detail      string    `json:"detail,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Waiver is a finding suppressed by an active exception
+type Waiver struct {
+	Finding     findings.Finding `json:"finding"`
+	ExceptionID string           `json:"exception_id"`
+	ExpiresAt   time.Time        `json:"expires_at"`
+}
+
+// Store persists exceptions in SQLite
+type Store struct {
+	db     *sql.DB
+	owners Owners
+	config Config
+	clock  clock.Clock
+}
+
+// NewStore creates an exception store on the migrated database
+func NewStore(db *sql.DB, owners Owners, config Config) *Store {
+	defaults := DefaultConfig()
+	if config.Threshold == "" {
+		config.Threshold = defaults.Threshold
+	}
+	if config.Approvals <= 0 {
+		config.Approvals = defaults.Approvals
+	}
+	if config.MaxTTL <= 0 {
+		config.MaxTTL = defaults.MaxTTL
+	}
+	return &Store{db: db, owners: owners, config: config, clock: clock.OrReal(config.Clock)}
+}
+
+// Request records an exception. Below the threshold it takes effect at once;
+// otherwise it waits for approvals from the scope's owners, excluding the
+// requester, and fails if there aren't enough of them to ever be approved.
+func (s *Store) Request(ctx context.Context, req Request) (*Exception, error) {
+	now := s.clock.Now().UTC()
+	if req.Scope == "" || req.FindingID == "" || req.RequestedBy == "" {
+		return nil, fmt.Errorf("exception requires a scope, finding and requester")
+	}
+	if req.Reason == "" {
+		return nil, fmt.Errorf("exception for %s requires a reason", req.FindingID)
+	}
+	if !validSeverity(req.Severity) {
+		return nil, fmt.Errorf("unknown severity %q", req.Severity)
+	}
+	if req.ExpiresAt.IsZero() {
+		req.ExpiresAt = now.Add(s.config.MaxTTL)
+	}
+	if !req.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("exception expiry %s is in the past", req.ExpiresAt.Format(time.RFC3339))
+	}
+	if req.ExpiresAt.After(now.Add(s.config.MaxTTL)) {
+		return nil, fmt.Errorf("exception expiry %s is beyond the maximum of %s", req.ExpiresAt.Format(time.RFC3339), s.config.MaxTTL)
+	}
+
+	exception := &Exception{
+		ID:          newID(),
+		Scope:       req.Scope,
+		FindingID:   req.FindingID,
+		Severity:    req.Severity,
+		Reason:      req.Reason,
+		RequestedBy: req.RequestedBy,
+		Status:      StatusApproved,
+		ExpiresAt:   req.ExpiresAt.UTC(),
+		CreatedAt:   now,
+	}
+
+	if req.Severity.AtLeast(s.config.Threshold) {
+		eligible, err := s.owners.Approvers(ctx, req.Scope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve owners of %s: %w", req.Scope, err)
+		}
+		for _, name := range eligible {
+			if name != req.RequestedBy {
+				exception.Approvers = append(exception.Approvers, Approver{Name: name})
+			}
+		}
+		if len(exception.Approvers) < s.config.Approvals {
+			return nil, fmt.Errorf("%s exceptions need %d approvals but %s has %d eligible approvers besides the requester",
+				req.Severity, s.config.Approvals, req.Scope, len(exception.Approvers))
+		}
+		exception.Status = StatusPending
+		exception.RequiredApprovals = s.config.Approvals
+	} else {
+		exception.DecidedAt = &now
+	}
+
+	if err := s.insert(ctx, exception); err != nil {
+		return nil, err
+	}
+	return exception, nil
+}
+
+// insert writes a new exception, its approvers and its audit entries
+func (s *Store) insert(ctx context.Context, e *Exception) error {
+	insertSQL := `
+		INSERT INTO vulnerability_exceptions (
+			id, scope, finding_id, severity, reason, requested_by, status,
+			required_approvals, expires_at, created_at, decided_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	approverSQL := `INSERT INTO vulnerability_exception_approvers (exception_id, approver) VALUES (?, ?)`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, insertSQL, e.ID, e.Scope, e.FindingID, string(e.Severity), e.Reason, e.RequestedBy,
+		string(e.Status), e.RequiredApprovals, e.ExpiresAt, e.CreatedAt, e.DecidedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record exception: %w", err)
+	}
+	for _, approver := range e.Approvers {
+		if _, err := tx.ExecContext(ctx, approverSQL, e.ID, approver.Name); err != nil {
+			return fmt.Errorf("failed to record exception approver: %w", err)
+		}
+	}
+
+	detail := fmt.Sprintf("%s %s in %s until %s: %s", e.Severity, e.FindingID, e.Scope, e.ExpiresAt.Format(time.RFC3339), e.Reason)
+	if err := audit(ctx, tx, e.ID, e.RequestedBy, ActionRequested, detail, e.CreatedAt); err != nil {
+		return err
+	}
+	if e.Status == StatusApproved {
+		detail := fmt.Sprintf("Below the %s approval threshold", s.config.Threshold)
+		if err := audit(ctx, tx, e.ID, e.RequestedBy, ActionActivated, detail, e.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Approve records an approver's sign-off. The exception takes effect when it
+// reaches its required approvals; later approvals are still recorded.
+func (s *Store) Approve(ctx context.Context, id, approver, comment string) (*Exception, error) {
+	return s.decide(ctx, id, approver, ActionApproved, comment)
+}
+
+// Reject records an approver declining the exception, which rejects it
+func (s *Store) Reject(ctx context.Context, id, approver, comment string) (*Exception, error) {
+	return s.decide(ctx, id, approver, ActionRejected, comment)
+}
+
+// decide records one approver's decision on a pending exception
+func (s *Store) decide(ctx context.Context, id, approver, decision, comment string) (*Exception, error) {
+	now := s.clock.Now().UTC()
+
+	// Conditional updates keep concurrent decisions from both completing an
+	// exception or overwriting each other
+	decideSQL := `
+		UPDATE vulnerability_exception_approvers
+		SET decision = ?, comment = ?, decided_at = ?
+		WHERE exception_id = ? AND approver = ? AND decision IS NULL
+		AND EXISTS (
+			SELECT 1 FROM vulnerability_exceptions
+			WHERE id = ? AND status = 'pending' AND expires_at > ?
+		)
+	`
+	activateSQL := `
+		UPDATE vulnerability_exceptions SET status = 'approved', decided_at = ?
+		WHERE id = ? AND status = 'pending' AND required_approvals <= (
+			SELECT COUNT(*) FROM vulnerability_exception_approvers
+			WHERE exception_id = ? AND decision = 'approved'
+		)
+	`
+	rejectSQL := `UPDATE vulnerability_exceptions SET status = 'rejected', decided_at = ? WHERE id = ? AND status = 'pending'`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, decideSQL, decision, comment, now, id, approver, id, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record decision: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		tx.Rollback()
+		return nil, s.undecidable(ctx, id, approver, now)
+	}
+	if err := audit(ctx, tx, id, approver, decision, comment, now); err != nil {
+		return nil, err
+	}
+
+	if decision == ActionRejected {
+		if _, err := tx.ExecContext(ctx, rejectSQL, now, id); err != nil {
+			return nil, fmt.Errorf("failed to reject exception: %w", err)
+		}
+	} else {
+		result, err := tx.ExecContext(ctx, activateSQL, now, id, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to activate exception: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 1 {
+			if err := audit(ctx, tx, id, approver, ActionActivated, "Required approvals reached", now); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to record decision: %w", err)
+	}
+	return s.Get(ctx, id)
+}
+
+// undecidable explains why a decision could not be recorded
+func (s *Store) undecidable(ctx context.Context, id, approver string, now time.Time) error {
+	e, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if e.Status != StatusPending {
+		return fmt.Errorf("%w: %s is %s", ErrNotPending, id, e.Status)
+	}
+	if !now.Before(e.ExpiresAt) {
+		return fmt.Errorf("%w: %s expired at %s", ErrNotPending, id, e.ExpiresAt.Format(time.RFC3339))
+	}
+	for _, candidate := range e.Approvers {
+		if candidate.Name == approver {
+			return fmt.Errorf("%s already %s %s", approver, candidate.Decision, id)
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrNotApprover, approver)
+}
+
+// Revoke withdraws a pending or approved exception
+func (s *Store) Revoke(ctx context.Context, id, actor, reason string) (*Exception, error) {
+	now := s.clock.Now().UTC()
+	revokeSQL := `
+		UPDATE vulnerability_exceptions SET status = 'revoked', decided_at = ?
+		WHERE id = ? AND status IN ('pending', 'approved')
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, revokeSQL, now, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke exception: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		tx.Rollback()
+		e, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("exception %s is already %s", id, e.Status)
+	}
+	if err := audit(ctx, tx, id, actor, ActionRevoked, reason, now); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to revoke exception: %w", err)
+	}
+	return s.Get(ctx, id)
+}
+
+// Get returns an exception with its approvers, or ErrNotFound
+func (s *Store) Get(ctx context.Context, id string) (*Exception, error) {
+	query := `
+		SELECT id, scope, finding_id, severity, reason, requested_by, status,
+			required_approvals, expires_at, created_at, decided_at
+		FROM vulnerability_exceptions WHERE id = ?
+	`
+
+	e, err := scanException(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exception: %w", err)
+	}
+	if e.Approvers, err = s.approvers(ctx, id); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// approvers returns the eligible approvers of an exception and their decisions
+func (s *Store) approvers(ctx context.Context, id string) ([]Approver, error) {
+	query := `
+		SELECT approver, decision, comment, decided_at
+		FROM vulnerability_exception_approvers WHERE exception_id = ?
+		ORDER BY approver
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exception approvers: %w", err)
+	}
+	defer rows.Close()
+
+	var approvers []Approver
+	for rows.Next() {
+		var approver Approver
+		var decision, comment sql.NullString
+		var decidedAt sql.NullTime
+		if err := rows.Scan(&approver.Name, &decision, &comment, &decidedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan exception approver: %w", err)
+		}
+		approver.Decision, approver.Comment = decision.String, comment.String
+		if decidedAt.Valid {
+			approver.DecidedAt = &decidedAt.Time
+		}
+		approvers = append(approvers, approver)
+	}
+	return approvers, rows.Err()
+}
+
+// Active returns the exceptions in effect for a scope
+func (s *Store) Active(ctx context.Context, scope string) ([]Exception, error) {
+	query := `
+		SELECT id, scope, finding_id, severity, reason, requested_by, status,
+			required_approvals, expires_at, created_at, decided_at
+		FROM vulnerability_exceptions
+		WHERE scope = ? AND status = 'approved' AND expires_at > ?
+		ORDER BY created_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, scope, s.clock.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active exceptions: %w", err)
+	}
+	defer rows.Close()
+
+	var active []Exception
+	for rows.Next() {
+		e, err := scanException(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan exception: %w", err)
+		}
+		active = append(active, *e)
+	}
+	return active, rows.Err()
+}
+
+// Apply removes the findings accepted by an active exception for the scope,
+// returning the findings that still apply and the waivers for the rest.
+// Pending, rejected, revoked and expired exceptions waive nothing.
+func (s *Store) Apply(ctx context.Context, scope string, found []findings.Finding) ([]findings.Finding, []Waiver, error) {
+	active, err := s.Active(ctx, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var remaining []findings.Finding
+	var waived []Waiver
+	for _, finding := range found {
+		waiver := -1
+		for i := range active {
+			if active[i].Covers(finding) {
+				waiver = i
+				break
+			}
+		}
+		if waiver < 0 {
+			remaining = append(remaining, finding)
+			continue
+		}
+		waived = append(waived, Waiver{Finding: finding, ExceptionID: active[waiver].ID, ExpiresAt: active[waiver].ExpiresAt})
+	}
+	return remaining, waived, nil
+}
+
+// Audit returns an exception's history, oldest first
+func (s *Store) Audit(ctx context.Context, id string) ([]AuditEntry, error) {
+	query := `
+		SELECT exception_id, actor, action, detail, created_at
+		FROM vulnerability_exception_audit WHERE exception_id = ?
+		ORDER BY id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exception audit: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var detail sql.NullString
+		if err := rows.Scan(&entry.ExceptionID, &entry.Actor, &entry.Action, &detail, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan exception audit: %w", err)
+		}
+		entry.Detail = detail.String
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// audit appends an entry to an exception's history. Details are free text
+// pasted by people, so credentials in them are redacted.
+func audit(ctx context.Context, tx *sql.Tx, id, actor, action, detail string, at time.Time) error {
+	detail = secret.Scrub(detail)
+	insertSQL := `
+		INSERT INTO vulnerability_exception_audit (exception_id, actor, action, detail, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	if _, err := tx.ExecContext(ctx, insertSQL, id, actor, action, detail, at); err != nil {
+		return fmt.Errorf("failed to audit exception: %w", err)
+	}
+	return nil
+}
+
+// scanner is satisfied by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanException reads an exception row without its approvers
+func scanException(row scanner) (*Exception, error) {
+	var e Exception
+	var severity, status string
+	var decidedAt sql.NullTime
+	err := row.Scan(&e.ID, &e.Scope, &e.FindingID, &severity, &e.Reason, &e.RequestedBy, &status,
+		&e.RequiredApprovals, &e.ExpiresAt, &e.CreatedAt, &decidedAt)
+	if err != nil {
+		return nil, err
+	}
+	e.Severity, e.Status = findings.Severity(severity), Status(status)
+	if decidedAt.Valid {
+		e.DecidedAt = &decidedAt.Time
+	}
+	return &e, nil
+}
+
+// validSeverity reports whether s is a known finding severity
+func validSeverity(s findings.Severity) bool {
+	switch s {
+	case findings.SeverityCritical, findings.SeverityHigh, findings.SeverityMedium, findings.SeverityLow, findings.SeverityInfo:
+		return true
+	}
+	return false
+}
+
+// newID returns a random 128-bit hex identifier
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,69 @@
+package exceptions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Owners resolves who may approve exceptions for a scope
+type Owners interface {
+	Approvers(ctx context.Context, scope string) ([]string, error)
+}
+
+// OwnershipRule assigns owners to the scopes matching a pattern
+type OwnershipRule struct {
+	Scope     string   `json:"scope"` // path.Match pattern, e.g. ghcr.io/acme/* or acme/payments
+	Approvers []string `json:"approvers"`
+}
+
+// OwnershipMap is a static ownership model read from a file
+type OwnershipMap struct {
+	Owners []OwnershipRule `json:"owners"`
+}
+
+// LoadOwnershipMap reads an ownership map from a JSON file
+func LoadOwnershipMap(filePath string) (*OwnershipMap, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ownership map: %w", err)
+	}
+
+	var owners OwnershipMap
+	if err := json.Unmarshal(data, &owners); err != nil {
+		return nil, fmt.Errorf("failed to parse ownership map %s: %w", filePath, err)
+	}
+	for i, rule := range owners.Owners {
+		if _, err := path.Match(rule.Scope, ""); err != nil || rule.Scope == "" {
+			return nil, fmt.Errorf("ownership rule %d: invalid scope pattern %q", i, rule.Scope)
+		}
+		if len(rule.Approvers) == 0 {
+			return nil, fmt.Errorf("ownership rule %d: scope %s has no approvers", i, rule.Scope)
+		}
+	}
+	return &owners, nil
+}
+
+// Approvers returns the owners of every rule matching the scope, sorted and
+// without duplicates
+func (m *OwnershipMap) Approvers(_ context.Context, scope string) ([]string, error) {
+	seen := make(map[string]bool)
+	var approvers []string
+	for _, rule := range m.Owners {
+		if matched, _ := path.Match(strings.ToLower(rule.Scope), strings.ToLower(scope)); !matched {
+			continue
+		}
+		for _, approver := range rule.Approvers {
+			if !seen[approver] {
+				seen[approver] = true
+				approvers = append(approvers, approver)
+			}
+		}
+	}
+	sort.Strings(approvers)
+	return approvers, nil
+}
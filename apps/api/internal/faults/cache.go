@@ -0,0 +1,55 @@
+package faults
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Cache is the subset of the hierarchical cache clients memoize results in,
+// as pkgverify.ResultCache
+type Cache interface {
+	Get(ctx context.Context, key string) (interface{}, bool)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+}
+
+// WrapCache delays cache calls and fails them while a fault is active on
+// TargetCache: failed lookups miss and failed stores return an error. A nil
+// Injector returns cache unchanged.
+func (i *Injector) WrapCache(cache Cache) Cache {
+	if i == nil {
+		return cache
+	}
+	return &faultCache{injector: i, cache: cache}
+}
+
+// faultCache applies an injector's faults to a cache
+type faultCache struct {
+	injector *Injector
+	cache    Cache
+}
+
+func (c *faultCache) Get(ctx context.Context, key string) (interface{}, bool) {
+	outcome, ok := c.injector.decide(TargetCache)
+	if !ok {
+		return c.cache.Get(ctx, key)
+	}
+	if outcome.delay(ctx) != nil || outcome.fail {
+		return nil, false
+	}
+	return c.cache.Get(ctx, key)
+}
+
+func (c *faultCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	outcome, ok := c.injector.decide(TargetCache)
+	if !ok {
+		return c.cache.Set(ctx, key, value, ttl)
+	}
+	if err := outcome.delay(ctx); err != nil {
+		return err
+	}
+	if outcome.fail {
+		return fmt.Errorf("failed to set %s: %w", key, ErrInjected)
+	}
+	return c.cache.Set(ctx, key, value, ttl)
+}
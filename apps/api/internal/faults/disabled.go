@@ -0,0 +1,7 @@
+//go:build !faults
+
+package faults
+
+// Enabled reports whether the binary was built with the faults tag and may
+// inject faults
+const Enabled = false
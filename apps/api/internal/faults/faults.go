@@ -0,0 +1,220 @@
+// Package faults injects latency, errors and truncated responses into calls
+// to external services and the cache, so the circuit breaker and offline mode
+// can be exercised against realistic failures. Injection is only wired up in
+// binaries built with the faults tag; production builds never construct an
+// Injector, and a nil Injector passes every call through untouched.
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+)
+
+// Targets faults can be injected into. The HTTP targets share their names
+// with the offline detector's services.
+const (
+	TargetGitHub   = "github"
+	TargetNVD      = "nvd"
+	TargetRegistry = "registry"
+	TargetRekor    = "rekor"
+	TargetCache    = "cache"
+)
+
+// Targets lists every target
+var Targets = []string{TargetGitHub, TargetNVD, TargetRegistry, TargetRekor, TargetCache}
+
+// ErrInjected is wrapped by every injected failure that isn't an HTTP status
+var ErrInjected = errors.New("injected fault")
+
+// Fault describes the failures injected into one target
+type Fault struct {
+	Target      string    `json:"target"`
+	LatencyMs   int64     `json:"latency_ms,omitempty"`   // Added before every call
+	ErrorRate   float64   `json:"error_rate,omitempty"`   // Fraction of calls that fail, 0 to 1
+	StatusCode  int       `json:"status_code,omitempty"`  // Status of failed HTTP calls; 0 fails them as if the connection dropped
+	PartialRate float64   `json:"partial_rate,omitempty"` // Fraction of HTTP responses whose body is cut short
+	ExpiresAt   time.Time `json:"expires_at,omitempty"`   // Zero keeps the fault until it is cleared
+}
+
+// Validate checks the target is known and the rates are fractions
+func (f Fault) Validate() error {
+	known := false
+	for _, target := range Targets {
+		known = known || f.Target == target
+	}
+	switch {
+	case !known:
+		return fmt.Errorf("unknown fault target %q", f.Target)
+	case f.LatencyMs < 0:
+		return fmt.Errorf("latency_ms must not be negative")
+	case f.ErrorRate < 0 || f.ErrorRate > 1:
+		return fmt.Errorf("error_rate must be between 0 and 1")
+	case f.PartialRate < 0 || f.PartialRate > 1:
+		return fmt.Errorf("partial_rate must be between 0 and 1")
+	case f.StatusCode != 0 && (f.StatusCode < 400 || f.StatusCode > 599):
+		return fmt.Errorf("status_code must be an HTTP error status")
+	case f.Target == TargetCache && (f.StatusCode != 0 || f.PartialRate != 0):
+		return fmt.Errorf("the cache only supports latency and errors")
+	}
+	return nil
+}
+
+// Changed is the payload of events.TypeFaultsChanged, carrying every active
+// fault so each process converges on the same set
+type Changed struct {
+	Faults []Fault `json:"faults"`
+}
+
+// Injector holds the active faults and applies them to wrapped clients
+type Injector struct {
+	mutex  sync.RWMutex
+	faults map[string]Fault
+	now    func() time.Time
+	random func() float64
+}
+
+// NewInjector creates an injector with no active faults
+func NewInjector() *Injector {
+	return &Injector{
+		faults: make(map[string]Fault),
+		now:    time.Now,
+		random: rand.Float64,
+	}
+}
+
+// Set activates a fault, replacing any on the same target
+func (i *Injector) Set(fault Fault) error {
+	if err := fault.Validate(); err != nil {
+		return err
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.faults[fault.Target] = fault
+	return nil
+}
+
+// Clear removes the fault on target, or every fault when target is empty
+func (i *Injector) Clear(target string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if target == "" {
+		i.faults = make(map[string]Fault)
+		return
+	}
+	delete(i.faults, target)
+}
+
+// Replace swaps the active faults for the given set, skipping invalid ones
+func (i *Injector) Replace(faults []Fault) {
+	active := make(map[string]Fault, len(faults))
+	for _, fault := range faults {
+		if fault.Validate() == nil {
+			active[fault.Target] = fault
+		}
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.faults = active
+}
+
+// Faults returns the unexpired faults sorted by target
+func (i *Injector) Faults() []Fault {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	now := i.now()
+	faults := make([]Fault, 0, len(i.faults))
+	for _, fault := range i.faults {
+		if fault.ExpiresAt.IsZero() || now.Before(fault.ExpiresAt) {
+			faults = append(faults, fault)
+		}
+	}
+	sort.Slice(faults, func(a, b int) bool { return faults[a].Target < faults[b].Target })
+	return faults
+}
+
+// Publish broadcasts the active faults to every process running Run
+func (i *Injector) Publish(ctx context.Context, bus events.Bus, source string) error {
+	event, err := events.NewEvent(events.TypeFaultsChanged, source, Changed{Faults: i.Faults()})
+	if err != nil {
+		return err
+	}
+	return bus.Publish(ctx, event)
+}
+
+// Run applies the faults published on the bus until the context is
+// cancelled. It subscribes without a queue group, so every process sees every
+// change; changes published before the last one applied are ignored.
+func (i *Injector) Run(ctx context.Context, bus events.Bus) error {
+	var mutex sync.Mutex
+	var applied time.Time
+	sub, err := bus.Subscribe(events.TypeFaultsChanged, func(ctx context.Context, event events.Event) error {
+		var changed Changed
+		if err := event.Decode(&changed); err != nil {
+			return err
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		if event.Time.Before(applied) {
+			return nil
+		}
+		applied = event.Time
+		i.Replace(changed.Faults)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", events.TypeFaultsChanged, err)
+	}
+	defer sub.Unsubscribe()
+
+	<-ctx.Done()
+	return nil
+}
+
+// outcome is what an injector decided for one call
+type outcome struct {
+	fault   Fault
+	fail    bool
+	partial bool
+}
+
+// decide looks up the fault on target and rolls for failure and truncation.
+// ok is false when there is no active fault.
+func (i *Injector) decide(target string) (outcome, bool) {
+	if i == nil {
+		return outcome{}, false
+	}
+	i.mutex.RLock()
+	fault, ok := i.faults[target]
+	i.mutex.RUnlock()
+	if !ok || (!fault.ExpiresAt.IsZero() && !i.now().Before(fault.ExpiresAt)) {
+		return outcome{}, false
+	}
+	return outcome{
+		fault:   fault,
+		fail:    fault.ErrorRate > 0 && i.random() < fault.ErrorRate,
+		partial: fault.PartialRate > 0 && i.random() < fault.PartialRate,
+	}, true
+}
+
+// delay waits out the fault's latency, returning early with the context's error
+func (o outcome) delay(ctx context.Context) error {
+	if o.fault.LatencyMs <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Duration(o.fault.LatencyMs) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,93 @@
+package faults
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Transport wraps base (http.DefaultTransport if nil) so requests to target
+// are delayed, failed or truncated while a fault is active. Its signature
+// matches cache.OfflineDetector.WrapProbes, so probes can share the faults of
+// the clients they stand in for. A nil Injector returns base unchanged.
+func (i *Injector) Transport(target string, base http.RoundTripper) http.RoundTripper {
+	if i == nil {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &faultTransport{injector: i, target: target, base: base}
+}
+
+// faultTransport applies an injector's faults to HTTP requests
+type faultTransport struct {
+	injector *Injector
+	target   string
+	base     http.RoundTripper
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	outcome, ok := t.injector.decide(t.target)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	if err := outcome.delay(req.Context()); err != nil {
+		return nil, err
+	}
+
+	if outcome.fail {
+		if outcome.fault.StatusCode == 0 {
+			return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Host, ErrInjected)
+		}
+		body := fmt.Sprintf(`{"message":%q}`, ErrInjected.Error())
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", outcome.fault.StatusCode, http.StatusText(outcome.fault.StatusCode)),
+			StatusCode:    outcome.fault.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || !outcome.partial {
+		return resp, err
+	}
+	return truncate(resp)
+}
+
+// truncate keeps the first half of the body and then fails the read, as when
+// the connection drops mid-response
+func truncate(resp *http.Response) (*http.Response, error) {
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &truncatedBody{Reader: bytes.NewReader(data[:len(data)/2])}
+	return resp, nil
+}
+
+// truncatedBody reports io.ErrUnexpectedEOF once its bytes run out
+type truncatedBody struct {
+	*bytes.Reader
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		err = fmt.Errorf("response body cut short: %w", io.ErrUnexpectedEOF)
+	}
+	return n, err
+}
+
+func (b *truncatedBody) Close() error {
+	return nil
+}
@@ -0,0 +1,278 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+	"github.com/salman-frs/keystone/apps/api/internal/sigv4"
+)
+
+// AWSAudience is the audience AWS STS expects of GitHub's OIDC tokens
+const AWSAudience = "sts.amazonaws.com"
+
+// AWSConfig holds the IAM role assumed with the workflow's OIDC token
+type AWSConfig struct {
+	RoleARN     string        // Role trusting token.actions.githubusercontent.com, e.g. arn:aws:iam::123456789012:role/keystone
+	SessionName string        // Defaults to keystone-<run id> in Actions, otherwise keystone
+	Region      string        // Regional STS endpoint; the global one when empty
+	Duration    time.Duration // Session lifetime; defaults to an hour
+	Audience    string        // Token audience; defaults to AWSAudience
+	STSEndpoint string        // Overrides the STS endpoint, e.g. for tests
+	ECREndpoint string        // Overrides the ECR API endpoint, e.g. for tests
+	HTTPClient  *http.Client
+	Clock       clock.Clock // Defaults to the system clock
+}
+
+// AWSCredentials are temporary credentials for the assumed role
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey *secret.String
+	SessionToken    *secret.String
+	Expiry          time.Time
+}
+
+// Signing returns the credentials for signing requests with SigV4
+func (c *AWSCredentials) Signing() sigv4.Credentials {
+	return sigv4.Credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey.Reveal(),
+		SessionToken:    c.SessionToken.Reveal(),
+	}
+}
+
+// AWS assumes an IAM role with AssumeRoleWithWebIdentity and logs in to ECR
+// with the role's credentials. Credentials are cached until shortly before
+// they expire.
+type AWS struct {
+	config AWSConfig
+	tokens attestation.TokenSource
+	client *http.Client
+	clock  clock.Clock
+
+	mutex       sync.Mutex
+	credentials *AWSCredentials
+	registries  map[string]*RegistryCredential
+}
+
+// NewAWS creates an AWS federation requesting tokens from tokens, typically
+// an oidc.TokenManager
+func NewAWS(tokens attestation.TokenSource, config AWSConfig) (*AWS, error) {
+	if !strings.HasPrefix(config.RoleARN, "arn:") || !strings.Contains(config.RoleARN, ":role/") {
+		return nil, fmt.Errorf("aws federation requires an IAM role ARN, got %q", config.RoleARN)
+	}
+	if config.SessionName == "" {
+		config.SessionName = defaultSessionName()
+	}
+	if config.Duration <= 0 {
+		config.Duration = time.Hour
+	}
+	if config.Audience == "" {
+		config.Audience = AWSAudience
+	}
+	if config.STSEndpoint == "" {
+		config.STSEndpoint = "https://sts.amazonaws.com"
+		if config.Region != "" {
+			config.STSEndpoint = fmt.Sprintf("https://sts.%s.amazonaws.com", config.Region)
+		}
+	}
+	return &AWS{
+		config:     config,
+		tokens:     tokens,
+		client:     httpClient(config.HTTPClient),
+		clock:      clock.OrReal(config.Clock),
+		registries: make(map[string]*RegistryCredential),
+	}, nil
+}
+
+// Credentials returns the role's temporary credentials. They're zeroed when
+// replaced, so callers use them right away rather than retaining them.
+func (a *AWS) Credentials(ctx context.Context) (*AWSCredentials, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.assumeRole(ctx)
+}
+
+// assumeRole returns the cached credentials, or assumes the role again.
+// Callers hold the mutex.
+func (a *AWS) assumeRole(ctx context.Context) (*AWSCredentials, error) {
+	if a.credentials != nil && fresh(a.credentials.Expiry, a.clock.Now()) {
+		return a.credentials, nil
+	}
+
+	webIdentity, err := token(ctx, a.tokens, a.config.Audience)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {a.config.RoleARN},
+		"RoleSessionName":  {a.config.SessionName},
+		"WebIdentityToken": {webIdentity},
+		"DurationSeconds":  {strconv.Itoa(int(a.config.Duration / time.Second))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(a.config.STSEndpoint, "/")+"/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/xml")
+
+	body, err := send(a.client, req, "AWS STS", describeSTSError)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &resp); err != nil || resp.Credentials.AccessKeyID == "" {
+		return nil, attestation.Errorf(attestation.CodeFederationFailed, "AWS STS returned no credentials for %s", a.config.RoleARN)
+	}
+
+	a.release()
+	a.credentials = &AWSCredentials{
+		AccessKeyID:     resp.Credentials.AccessKeyID,
+		SecretAccessKey: secret.New(resp.Credentials.SecretAccessKey),
+		SessionToken:    secret.New(resp.Credentials.SessionToken),
+		Expiry:          resp.Credentials.Expiration,
+	}
+	return a.credentials, nil
+}
+
+// RegistryCredential logs in to an ECR registry with GetAuthorizationToken
+func (a *AWS) RegistryCredential(ctx context.Context, registry string) (*RegistryCredential, error) {
+	region, found := ecrRegion(registry)
+	if !found {
+		return nil, fmt.Errorf("%s is not an ECR registry", registry)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if credential, found := a.registries[registry]; found && fresh(credential.Expiry, a.clock.Now()) {
+		return credential, nil
+	}
+	credentials, err := a.assumeRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := a.config.ECREndpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://api.ecr.%s.amazonaws.com", region)
+	}
+	payload := []byte("{}")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken")
+	sigv4.Sign(req, payload, credentials.Signing(), region, "ecr", a.clock.Now())
+
+	body, err := send(a.client, req, "Amazon ECR", describeAWSJSONError)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		AuthorizationData []struct {
+			AuthorizationToken string  `json:"authorizationToken"`
+			ExpiresAt          float64 `json:"expiresAt"`
+		} `json:"authorizationData"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.AuthorizationData) == 0 {
+		return nil, attestation.Errorf(attestation.CodeFederationFailed, "Amazon ECR returned no authorization token for %s", registry)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(resp.AuthorizationData[0].AuthorizationToken)
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeFederationFailed, err, "Amazon ECR returned a malformed authorization token")
+	}
+	username, password, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return nil, attestation.Errorf(attestation.CodeFederationFailed, "Amazon ECR returned a malformed authorization token")
+	}
+
+	seconds, fraction := math.Modf(resp.AuthorizationData[0].ExpiresAt)
+	credential := &RegistryCredential{
+		Username: username,
+		Password: secret.New(password),
+		Expiry:   time.Unix(int64(seconds), int64(fraction*1e9)),
+	}
+	if previous, found := a.registries[registry]; found {
+		previous.Password.Release()
+	}
+	a.registries[registry] = credential
+	return credential, nil
+}
+
+// Release zeroes the cached credentials
+func (a *AWS) Release() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.release()
+	for registry, credential := range a.registries {
+		credential.Password.Release()
+		delete(a.registries, registry)
+	}
+}
+
+// release zeroes the cached role credentials. Callers hold the mutex.
+func (a *AWS) release() {
+	if a.credentials != nil {
+		a.credentials.SecretAccessKey.Release()
+		a.credentials.SessionToken.Release()
+		a.credentials = nil
+	}
+}
+
+// defaultSessionName names the role session after the workflow run, so
+// CloudTrail shows which run pushed
+func defaultSessionName() string {
+	if runID := os.Getenv("GITHUB_RUN_ID"); runID != "" {
+		return "keystone-" + runID
+	}
+	return "keystone"
+}
+
+// describeSTSError reads an STS error response
+func describeSTSError(body []byte) string {
+	var resp struct {
+		Code    string `xml:"Error>Code"`
+		Message string `xml:"Error>Message"`
+	}
+	if xml.Unmarshal(body, &resp) != nil || resp.Code == "" {
+		return ""
+	}
+	return resp.Code + ": " + resp.Message
+}
+
+// describeAWSJSONError reads an AWS JSON protocol error response
+func describeAWSJSONError(body []byte) string {
+	var resp struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &resp) != nil || resp.Type == "" {
+		return ""
+	}
+	return resp.Type + ": " + resp.Message
+}
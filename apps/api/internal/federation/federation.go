@@ -0,0 +1,174 @@
+// Package federation exchanges the workflow's OIDC token for short-lived
+// cloud credentials, so attestations can be pushed to Amazon ECR and Google
+// Artifact Registry without long-lived registry secrets. AWS trusts the token
+// through an IAM role's web identity trust policy and GCP through a workload
+// identity pool provider; both check its sub and repository claims.
+package federation
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+)
+
+// refreshMargin is how long before expiry cached credentials are replaced,
+// so a push never starts with credentials about to lapse
+const refreshMargin = 5 * time.Minute
+
+// maxResponseSize bounds provider responses
+const maxResponseSize = 1 << 20
+
+// RegistryCredential is a registry login
+type RegistryCredential struct {
+	Username string
+	Password *secret.String
+	Expiry   time.Time
+}
+
+// Registries routes registry hosts to the cloud that issues their
+// credentials. It satisfies ocistore.CredentialSource.
+type Registries struct {
+	AWS *AWS // Amazon ECR, <account>.dkr.ecr.<region>.amazonaws.com
+	GCP *GCP // Artifact Registry, <location>-docker.pkg.dev, and Container Registry, gcr.io
+}
+
+// Environment variables configuring federation in a workflow
+const (
+	AWSRoleARNEnv                  = "KEYSTONE_AWS_ROLE_ARN"
+	AWSRegionEnv                   = "AWS_REGION"
+	GCPWorkloadIdentityProviderEnv = "KEYSTONE_GCP_WORKLOAD_IDENTITY_PROVIDER"
+	GCPServiceAccountEnv           = "KEYSTONE_GCP_SERVICE_ACCOUNT"
+)
+
+// RegistriesFromEnv configures the clouds named by the job's environment,
+// exchanging tokens from tokens. Clouds without configuration are left nil.
+func RegistriesFromEnv(tokens attestation.TokenSource) (Registries, error) {
+	var registries Registries
+	if roleARN := os.Getenv(AWSRoleARNEnv); roleARN != "" {
+		aws, err := NewAWS(tokens, AWSConfig{RoleARN: roleARN, Region: os.Getenv(AWSRegionEnv)})
+		if err != nil {
+			return Registries{}, err
+		}
+		registries.AWS = aws
+	}
+	if provider := os.Getenv(GCPWorkloadIdentityProviderEnv); provider != "" {
+		gcp, err := NewGCP(tokens, GCPConfig{WorkloadIdentityProvider: provider, ServiceAccount: os.Getenv(GCPServiceAccountEnv)})
+		if err != nil {
+			return Registries{}, err
+		}
+		registries.GCP = gcp
+	}
+	return registries, nil
+}
+
+// Credential returns a login for the registry, or empty credentials for
+// registries neither cloud serves
+func (r Registries) Credential(ctx context.Context, registry string) (string, string, error) {
+	var (
+		credential *RegistryCredential
+		err        error
+	)
+	switch {
+	case r.AWS != nil && IsECR(registry):
+		credential, err = r.AWS.RegistryCredential(ctx, registry)
+	case r.GCP != nil && IsArtifactRegistry(registry):
+		credential, err = r.GCP.RegistryCredential(ctx, registry)
+	default:
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return credential.Username, credential.Password.Reveal(), nil
+}
+
+// IsECR reports whether a registry host is an Amazon ECR private registry
+func IsECR(registry string) bool {
+	_, found := ecrRegion(registry)
+	return found
+}
+
+// ecrRegion returns the region of an ECR registry host such as
+// 123456789012.dkr.ecr.eu-west-1.amazonaws.com
+func ecrRegion(registry string) (string, bool) {
+	host := hostname(registry)
+	_, rest, found := strings.Cut(host, ".dkr.ecr.")
+	if !found {
+		return "", false
+	}
+	region, domain, found := strings.Cut(rest, ".")
+	if !found || region == "" || !strings.HasPrefix(domain, "amazonaws.com") {
+		return "", false
+	}
+	return region, true
+}
+
+// IsArtifactRegistry reports whether a registry host is served by Google
+// Artifact Registry or Container Registry
+func IsArtifactRegistry(registry string) bool {
+	host := hostname(registry)
+	return strings.HasSuffix(host, "-docker.pkg.dev") || host == "gcr.io" || strings.HasSuffix(host, ".gcr.io")
+}
+
+// hostname strips the port from a registry host
+func hostname(registry string) string {
+	host := strings.ToLower(registry)
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return host
+}
+
+// fresh reports whether a credential expiring at expiry can still be used
+func fresh(expiry, now time.Time) bool {
+	return expiry.Sub(now) > refreshMargin
+}
+
+// token requests the workflow's OIDC token for the audience
+func token(ctx context.Context, tokens attestation.TokenSource, audience string) (string, error) {
+	if tokens == nil {
+		return "", attestation.Errorf(attestation.CodeOIDCTokenUnavailable, "No OIDC token source for credential federation")
+	}
+	return tokens.Token(ctx, audience)
+}
+
+// send performs a provider request, returning the response body of a
+// successful one. Rejections fail with SIGN_088 quoting the provider's
+// explanation, read by describe.
+func send(client *http.Client, req *http.Request, provider string, describe func([]byte) string) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeNetworkTimeout, err, "%s is unreachable", provider)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeNetworkTimeout, err, "Failed to read %s response", provider)
+	}
+	if resp.StatusCode >= 500 {
+		return nil, attestation.Errorf(attestation.CodeNetworkTimeout, "%s returned status %d", provider, resp.StatusCode)
+	}
+	if resp.StatusCode >= 300 {
+		reason := describe(body)
+		if reason == "" {
+			reason = http.StatusText(resp.StatusCode)
+		}
+		return nil, attestation.Errorf(attestation.CodeFederationFailed, "%s rejected the request (%d): %s", provider, resp.StatusCode, secret.Scrub(reason))
+	}
+	return body, nil
+}
+
+// httpClient returns the configured client, or one with a 30 second timeout
+func httpClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: 30 * time.Second}
+}
@@ -0,0 +1,237 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+)
+
+// CloudPlatformScope grants access to Google Cloud APIs, Artifact Registry
+// included
+const CloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// artifactRegistryUsername is the username Artifact Registry accepts with an
+// OAuth access token as the password
+const artifactRegistryUsername = "oauth2accesstoken"
+
+// GCPConfig holds the workload identity provider trusting the workflow's
+// OIDC tokens
+type GCPConfig struct {
+	// WorkloadIdentityProvider is the provider's resource name, e.g.
+	// projects/123456789/locations/global/workloadIdentityPools/github/providers/github
+	WorkloadIdentityProvider string
+	// ServiceAccount is impersonated with the federated token when set, for
+	// providers granting access through a service account rather than
+	// directly to the federated identity
+	ServiceAccount string
+	Scopes         []string      // Defaults to CloudPlatformScope
+	Lifetime       time.Duration // Impersonated token lifetime; defaults to an hour
+	Audience       string        // Token audience; defaults to the provider's https://iam.googleapis.com/ URL
+
+	STSEndpoint            string // Overrides https://sts.googleapis.com, e.g. for tests
+	IAMCredentialsEndpoint string // Overrides https://iamcredentials.googleapis.com, e.g. for tests
+	HTTPClient             *http.Client
+	Clock                  clock.Clock // Defaults to the system clock
+}
+
+// AccessToken is a Google Cloud OAuth access token
+type AccessToken struct {
+	Token  *secret.String
+	Expiry time.Time
+}
+
+// GCP exchanges the workflow's OIDC token with Google's Security Token
+// Service, then impersonates a service account when one is configured.
+// Access tokens are cached until shortly before they expire.
+type GCP struct {
+	config GCPConfig
+	tokens attestation.TokenSource
+	client *http.Client
+	clock  clock.Clock
+
+	mutex sync.Mutex
+	token *AccessToken
+}
+
+// NewGCP creates a GCP federation requesting tokens from tokens, typically
+// an oidc.TokenManager
+func NewGCP(tokens attestation.TokenSource, config GCPConfig) (*GCP, error) {
+	config.WorkloadIdentityProvider = strings.TrimPrefix(config.WorkloadIdentityProvider, "//iam.googleapis.com/")
+	if !strings.HasPrefix(config.WorkloadIdentityProvider, "projects/") || !strings.Contains(config.WorkloadIdentityProvider, "/workloadIdentityPools/") {
+		return nil, fmt.Errorf("gcp federation requires a workload identity provider resource name, got %q", config.WorkloadIdentityProvider)
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{CloudPlatformScope}
+	}
+	if config.Lifetime <= 0 {
+		config.Lifetime = time.Hour
+	}
+	if config.Audience == "" {
+		config.Audience = "https://iam.googleapis.com/" + config.WorkloadIdentityProvider
+	}
+	if config.STSEndpoint == "" {
+		config.STSEndpoint = "https://sts.googleapis.com"
+	}
+	if config.IAMCredentialsEndpoint == "" {
+		config.IAMCredentialsEndpoint = "https://iamcredentials.googleapis.com"
+	}
+	return &GCP{
+		config: config,
+		tokens: tokens,
+		client: httpClient(config.HTTPClient),
+		clock:  clock.OrReal(config.Clock),
+	}, nil
+}
+
+// AccessToken returns an access token for the federated identity, or for the
+// impersonated service account. It's zeroed when replaced, so callers use it
+// right away rather than retaining it.
+func (g *GCP) AccessToken(ctx context.Context) (*AccessToken, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.token != nil && fresh(g.token.Expiry, g.clock.Now()) {
+		return g.token, nil
+	}
+
+	token, err := g.exchange(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if g.config.ServiceAccount != "" {
+		federated := token
+		token, err = g.impersonate(ctx, federated)
+		federated.Token.Release()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if g.token != nil {
+		g.token.Token.Release()
+	}
+	g.token = token
+	return token, nil
+}
+
+// RegistryCredential logs in to Artifact Registry with an access token
+func (g *GCP) RegistryCredential(ctx context.Context, registry string) (*RegistryCredential, error) {
+	if !IsArtifactRegistry(registry) {
+		return nil, fmt.Errorf("%s is not an Artifact Registry registry", registry)
+	}
+	token, err := g.AccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &RegistryCredential{Username: artifactRegistryUsername, Password: token.Token, Expiry: token.Expiry}, nil
+}
+
+// Release zeroes the cached access token
+func (g *GCP) Release() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.token != nil {
+		g.token.Token.Release()
+		g.token = nil
+	}
+}
+
+// exchange trades the OIDC token for a federated access token
+func (g *GCP) exchange(ctx context.Context) (*AccessToken, error) {
+	subjectToken, err := token(ctx, g.tokens, g.config.Audience)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"audience":             {"//iam.googleapis.com/" + g.config.WorkloadIdentityProvider},
+		"scope":                {strings.Join(g.config.Scopes, " ")},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"subject_token_type":   {"urn:ietf:params:oauth:token-type:jwt"},
+		"subject_token":        {subjectToken},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(g.config.STSEndpoint, "/")+"/v1/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	now := g.clock.Now()
+	body, err := send(g.client, req, "Google STS", describeGoogleError)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.AccessToken == "" {
+		return nil, attestation.Errorf(attestation.CodeFederationFailed, "Google STS returned no access token for %s", g.config.WorkloadIdentityProvider)
+	}
+	return &AccessToken{Token: secret.New(resp.AccessToken), Expiry: now.Add(time.Duration(resp.ExpiresIn) * time.Second)}, nil
+}
+
+// impersonate trades a federated access token for the service account's
+func (g *GCP) impersonate(ctx context.Context, federated *AccessToken) (*AccessToken, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"scope":    g.config.Scopes,
+		"lifetime": fmt.Sprintf("%ds", int64(g.config.Lifetime/time.Second)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/v1/projects/-/serviceAccounts/%s:generateAccessToken",
+		strings.TrimSuffix(g.config.IAMCredentialsEndpoint, "/"), url.PathEscape(g.config.ServiceAccount))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+federated.Token.Reveal())
+
+	body, err := send(g.client, req, "Google IAM Credentials", describeGoogleError)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.AccessToken == "" {
+		return nil, attestation.Errorf(attestation.CodeFederationFailed, "Google IAM Credentials returned no access token for %s", g.config.ServiceAccount)
+	}
+	return &AccessToken{Token: secret.New(resp.AccessToken), Expiry: resp.ExpireTime}, nil
+}
+
+// describeGoogleError reads an OAuth or Google API error response
+func describeGoogleError(body []byte) string {
+	var resp struct {
+		Error            json.RawMessage `json:"error"`
+		ErrorDescription string          `json:"error_description"`
+	}
+	if json.Unmarshal(body, &resp) != nil || len(resp.Error) == 0 {
+		return ""
+	}
+	// STS answers with OAuth errors, IAM Credentials with Google API errors
+	var code string
+	if json.Unmarshal(resp.Error, &code) == nil {
+		return strings.TrimSpace(code + ": " + resp.ErrorDescription)
+	}
+	var apiError struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(resp.Error, &apiError) != nil {
+		return ""
+	}
+	return apiError.Status + ": " + apiError.Message
+}
@@ -0,0 +1,89 @@
+package findings
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/messages"
+)
+
+// Severity represents the severity of a finding
+type Severity string
+
+const (
+	SeverityCritical Severity = "CRITICAL"
+	SeverityHigh     Severity = "HIGH"
+	SeverityMedium   Severity = "MEDIUM"
+	SeverityLow      Severity = "LOW"
+	SeverityInfo     Severity = "INFO"
+)
+
+// Category groups findings by the subsystem that produced them
+type Category string
+
+const (
+	CategoryVulnerability Category = "vulnerability"
+	CategorySignature     Category = "signature"
+	CategoryProvenance    Category = "provenance"
+	CategoryPolicy        Category = "policy"
+	CategorySecret        Category = "secret"
+)
+
+// Finding represents a single security observation about an artifact or component
+type Finding struct {
+	ID          string            `json:"id"`
+	Source      string            `json:"source"`
+	Category    Category          `json:"category"`
+	Severity    Severity          `json:"severity"`
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	Component   string            `json:"component,omitempty"`
+	Version     string            `json:"version,omitempty"`
+	PURL        string            `json:"purl,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Message     *messages.Message `json:"message,omitempty"` // Catalog message for rendering Title in the reader's locale
+	DetectedAt  time.Time         `json:"detected_at"`
+}
+
+// New creates a finding with a deterministic ID derived from source, rule and subject
+func New(source string, category Category, severity Severity, rule, subject, title string) Finding {
+	return Finding{
+		ID:         fmt.Sprintf("%s:%s:%s", source, rule, subject),
+		Source:     source,
+		Category:   category,
+		Severity:   severity,
+		Title:      title,
+		Metadata:   make(map[string]string),
+		DetectedAt: time.Now(),
+	}
+}
+
+// rank orders severities from least to most severe
+var rank = map[Severity]int{
+	SeverityInfo:     0,
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+// AtLeast returns true if the severity is equal to or more severe than the threshold
+func (s Severity) AtLeast(threshold Severity) bool {
+	return rank[s] >= rank[threshold]
+}
+
+// AdvisorySeverity maps GitHub advisory severities to finding severities
+func AdvisorySeverity(severity string) Severity {
+	switch severity {
+	case "critical", "CRITICAL":
+		return SeverityCritical
+	case "high", "HIGH":
+		return SeverityHigh
+	case "moderate", "medium", "MODERATE", "MEDIUM":
+		return SeverityMedium
+	case "low", "LOW":
+		return SeverityLow
+	default:
+		return SeverityInfo
+	}
+}
@@ -0,0 +1,206 @@
+package findings
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SARIF 2.1.0, the format GitHub code scanning ingests
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// Metadata keys locating a finding in the repository
+const (
+	MetadataPath = "path" // Repository-relative file, e.g. go.mod
+	MetadataLine = "line" // 1-based line in it
+)
+
+// SARIFOptions describes the tool and where findings without a location of
+// their own are reported
+type SARIFOptions struct {
+	ToolName       string // Defaults to keystone
+	ToolVersion    string
+	InformationURI string
+	// Path is the repository file findings without a path are reported
+	// against, e.g. the scanned manifest or Dockerfile. Code scanning
+	// rejects results without a location.
+	Path string
+}
+
+// sarifLevel maps severities to SARIF result levels
+var sarifLevel = map[Severity]string{
+	SeverityCritical: "error",
+	SeverityHigh:     "error",
+	SeverityMedium:   "warning",
+	SeverityLow:      "note",
+	SeverityInfo:     "note",
+}
+
+// securitySeverity maps severities to the CVSS-like scores GitHub shows as
+// critical, high, medium and low
+var securitySeverity = map[Severity]string{
+	SeverityCritical: "9.5",
+	SeverityHigh:     "8.0",
+	SeverityMedium:   "5.5",
+	SeverityLow:      "2.0",
+	SeverityInfo:     "0.0",
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string                 `json:"id"`
+	ShortDescription sarifMessage           `json:"shortDescription"`
+	FullDescription  *sarifMessage          `json:"fullDescription,omitempty"`
+	Properties       map[string]interface{} `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *struct {
+			StartLine int `json:"startLine"`
+		} `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+// SARIF encodes findings as a SARIF log with one run. Each finding's ID is
+// its fingerprint, so code scanning tracks it as the same alert across
+// uploads, and rules are the finding's source and rule.
+func SARIF(findings []Finding, options SARIFOptions) ([]byte, error) {
+	if options.ToolName == "" {
+		options.ToolName = "keystone"
+	}
+
+	rules := make(map[string]sarifRule)
+	results := make([]sarifResult, 0, len(findings))
+	for _, finding := range findings {
+		location, err := sarifLocationOf(finding, options.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		ruleID := finding.RuleID()
+		if _, found := rules[ruleID]; !found {
+			rule := sarifRule{
+				ID:               ruleID,
+				ShortDescription: sarifMessage{Text: finding.Title},
+				Properties: map[string]interface{}{
+					"security-severity": securitySeverity[finding.Severity],
+					"tags":              []string{"security", string(finding.Category)},
+				},
+			}
+			if finding.Description != "" {
+				rule.FullDescription = &sarifMessage{Text: finding.Description}
+			}
+			rules[ruleID] = rule
+		}
+
+		message := finding.Title
+		if finding.Component != "" {
+			message = fmt.Sprintf("%s (%s)", message, strings.TrimSpace(finding.Component+" "+finding.Version))
+		}
+		result := sarifResult{
+			RuleID:              ruleID,
+			Level:               sarifLevel[finding.Severity],
+			Message:             sarifMessage{Text: message},
+			Locations:           []sarifLocation{location},
+			PartialFingerprints: map[string]string{"keystoneFindingId/v1": finding.ID},
+		}
+		if finding.PURL != "" {
+			result.Properties = map[string]string{"purl": finding.PURL}
+		}
+		if result.Level == "" {
+			result.Level = "warning"
+		}
+		results = append(results, result)
+	}
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           options.ToolName,
+			Version:        options.ToolVersion,
+			InformationURI: options.InformationURI,
+			Rules:          make([]sarifRule, 0, len(rules)),
+		}},
+		Results: results,
+	}
+	for _, rule := range rules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool {
+		return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID
+	})
+
+	return json.Marshal(sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}})
+}
+
+// RuleID returns the finding's source and rule, e.g. github-advisory/GHSA-xxxx,
+// from its ID
+func (f Finding) RuleID() string {
+	parts := strings.SplitN(f.ID, ":", 3)
+	if len(parts) < 3 {
+		return f.Source
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// sarifLocationOf locates a finding at its path and line, or at the fallback
+// path
+func sarifLocationOf(finding Finding, fallback string) (sarifLocation, error) {
+	var location sarifLocation
+	path := finding.Metadata[MetadataPath]
+	if path == "" {
+		path = fallback
+	}
+	if path == "" {
+		return location, fmt.Errorf("finding %s has no path to report it against", finding.ID)
+	}
+	location.PhysicalLocation.ArtifactLocation.URI = strings.TrimPrefix(path, "./")
+	if line, err := strconv.Atoi(finding.Metadata[MetadataLine]); err == nil && line > 0 {
+		location.PhysicalLocation.Region = &struct {
+			StartLine int `json:"startLine"`
+		}{line}
+	}
+	return location, nil
+}
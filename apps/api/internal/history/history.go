@@ -0,0 +1,159 @@
+// Package history retains versioned snapshots of the data compliance is
+// evaluated against: vulnerability advisories, identity policies and trust
+// roots. Each version is kept from the time it took effect, so an evaluation
+// can be repeated with the data as it stood at any retained point in time.
+package history
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Snapshot kinds
+const (
+	KindAdvisory  = "advisory"   // Keyed by GHSA ID; data is the advisory JSON
+	KindPolicy    = "policy"     // Keyed by policy name; data is the policy YAML
+	KindTrustRoot = "trust_root" // Keyed by TUF mirror or "pinned"; data is trusted_root.json
+)
+
+// Snapshot is one version of a document
+type Snapshot struct {
+	Kind       string    `json:"kind"`
+	Key        string    `json:"key"`
+	Digest     string    `json:"digest"` // sha256:hex of Data
+	Data       []byte    `json:"-"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Store persists snapshots in SQLite
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a snapshot store on the migrated database
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Record stores data as the version of kind/key in effect from at, unless it
+// is identical to the version already in effect then. It reports whether a
+// snapshot was added.
+func (s *Store) Record(ctx context.Context, kind, key string, data []byte, at time.Time) (bool, error) {
+	return record(ctx, s.db, kind, key, data, at)
+}
+
+// RecordTx records a snapshot inside the caller's transaction, so the data
+// and its history are committed together
+func RecordTx(ctx context.Context, tx *sql.Tx, kind, key string, data []byte, at time.Time) (bool, error) {
+	return record(ctx, tx, kind, key, data, at)
+}
+
+// record adds a snapshot through the database or a transaction
+func record(ctx context.Context, q querier, kind, key string, data []byte, at time.Time) (bool, error) {
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	at = at.UTC()
+
+	current, err := snapshotAt(ctx, q, kind, key, at)
+	if err != nil {
+		return false, err
+	}
+	if current != nil && current.Digest == digest {
+		return false, nil
+	}
+
+	insertSQL := `INSERT INTO history_snapshots (kind, key, digest, data, recorded_at) VALUES (?, ?, ?, ?, ?)`
+	if _, err := q.ExecContext(ctx, insertSQL, kind, key, digest, data, at); err != nil {
+		return false, fmt.Errorf("failed to record %s snapshot %s: %w", kind, key, err)
+	}
+	return true, nil
+}
+
+// At returns the version of kind/key in effect at a time, or nil if none was
+// recorded by then
+func (s *Store) At(ctx context.Context, kind, key string, at time.Time) (*Snapshot, error) {
+	return snapshotAt(ctx, s.db, kind, key, at.UTC())
+}
+
+// snapshotAt returns the latest version of kind/key recorded by at
+func snapshotAt(ctx context.Context, q querier, kind, key string, at time.Time) (*Snapshot, error) {
+	query := `
+		SELECT kind, key, digest, data, recorded_at FROM history_snapshots
+		WHERE kind = ? AND key = ? AND recorded_at <= ?
+		ORDER BY recorded_at DESC, id DESC LIMIT 1
+	`
+
+	var snapshot Snapshot
+	err := q.QueryRowContext(ctx, query, kind, key, at).Scan(
+		&snapshot.Kind, &snapshot.Key, &snapshot.Digest, &snapshot.Data, &snapshot.RecordedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s snapshot %s: %w", kind, key, err)
+	}
+	return &snapshot, nil
+}
+
+// AllAt returns the version of every key of a kind in effect at a time,
+// ordered by key
+func (s *Store) AllAt(ctx context.Context, kind string, at time.Time) ([]Snapshot, error) {
+	query := `
+		SELECT s.kind, s.key, s.digest, s.data, s.recorded_at FROM history_snapshots s
+		WHERE s.kind = ? AND s.id = (
+			SELECT id FROM history_snapshots
+			WHERE kind = s.kind AND key = s.key AND recorded_at <= ?
+			ORDER BY recorded_at DESC, id DESC LIMIT 1
+		)
+		ORDER BY s.key
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, kind, at.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s snapshots: %w", kind, err)
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var snapshot Snapshot
+		if err := rows.Scan(&snapshot.Kind, &snapshot.Key, &snapshot.Digest, &snapshot.Data, &snapshot.RecordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan %s snapshot: %w", kind, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// Prune deletes versions superseded before the cutoff. The version in effect
+// at the cutoff is kept, so every time from the cutoff on can still be
+// evaluated.
+func (s *Store) Prune(ctx context.Context, before time.Time) (int64, error) {
+	deleteSQL := `
+		DELETE FROM history_snapshots
+		WHERE recorded_at < ? AND EXISTS (
+			SELECT 1 FROM history_snapshots newer
+			WHERE newer.kind = history_snapshots.kind AND newer.key = history_snapshots.key
+			AND newer.recorded_at <= ?
+			AND (newer.recorded_at > history_snapshots.recorded_at
+				OR (newer.recorded_at = history_snapshots.recorded_at AND newer.id > history_snapshots.id))
+		)
+	`
+
+	before = before.UTC()
+	result, err := s.db.ExecContext(ctx, deleteSQL, before, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune snapshots: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,111 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/sigv4"
+)
+
+// Object is an object listed in a bucket
+type Object struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// Bucket is the subset of an object store the ingester uses
+type Bucket interface {
+	// List returns every object whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	// Move copies an object to a new key, then deletes the original
+	Move(ctx context.Context, from, to string) error
+}
+
+// Credentials authenticate to the object stores
+type Credentials struct {
+	AWS         sigv4.Credentials
+	AWSRegion   string
+	S3Endpoint  string // Path-style S3-compatible endpoint, e.g. MinIO; defaults to AWS
+	GCSToken    string // OAuth access token; the metadata server's is used when empty
+	GCSEndpoint string // Overrides the Cloud Storage JSON API endpoint
+	HTTPClient  *http.Client
+}
+
+// CredentialsFromEnv reads the standard AWS variables, INGEST_S3_ENDPOINT,
+// GOOGLE_OAUTH_ACCESS_TOKEN and INGEST_GCS_ENDPOINT
+func CredentialsFromEnv() Credentials {
+	return Credentials{
+		AWS: sigv4.Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		AWSRegion:   os.Getenv("AWS_REGION"),
+		S3Endpoint:  os.Getenv("INGEST_S3_ENDPOINT"),
+		GCSToken:    os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+		GCSEndpoint: os.Getenv("INGEST_GCS_ENDPOINT"),
+	}
+}
+
+// OpenBucket opens the bucket named by an s3://bucket/prefix or
+// gs://bucket/prefix URL, returning it with the prefix
+func OpenBucket(source string, credentials Credentials) (Bucket, string, error) {
+	parsed, err := url.Parse(source)
+	if err != nil || parsed.Host == "" {
+		return nil, "", fmt.Errorf("drop bucket %q must be s3://bucket/prefix or gs://bucket/prefix", source)
+	}
+	prefix := strings.TrimPrefix(parsed.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if credentials.HTTPClient == nil {
+		credentials.HTTPClient = &http.Client{Timeout: time.Minute}
+	}
+
+	switch parsed.Scheme {
+	case "s3":
+		bucket, err := newS3Bucket(parsed.Host, credentials)
+		return bucket, prefix, err
+	case "gs":
+		return newGCSBucket(parsed.Host, credentials), prefix, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported drop bucket scheme %q; use s3 or gs", parsed.Scheme)
+	}
+}
+
+// maxObjectSize bounds the objects read from a bucket
+const maxObjectSize = 10 << 20
+
+// do sends a request and returns the response body, failing on error statuses
+func do(client *http.Client, req *http.Request, service string) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s is unreachable: %w", service, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxObjectSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", service, err)
+	}
+	if resp.StatusCode >= 300 {
+		if len(data) > 512 {
+			data = data[:512]
+		}
+		return nil, fmt.Errorf("%s returned status %d for %s %s: %s", service, resp.StatusCode,
+			req.Method, req.URL.Path, strings.TrimSpace(string(data)))
+	}
+	if len(data) > maxObjectSize {
+		return nil, fmt.Errorf("%s object %s exceeds %d bytes", service, req.URL.Path, maxObjectSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,162 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gcsMetadataTokenURL serves the default service account token on GCE and GKE
+const gcsMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// gcsBucket reads and moves objects through the Cloud Storage JSON API
+type gcsBucket struct {
+	name     string
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+func newGCSBucket(name string, credentials Credentials) *gcsBucket {
+	endpoint := credentials.GCSEndpoint
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	return &gcsBucket{
+		name:     name,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		token:    credentials.GCSToken,
+		client:   credentials.HTTPClient,
+	}
+}
+
+// List pages through objects.list
+func (b *gcsBucket) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	pageToken := ""
+	for {
+		query := url.Values{"prefix": {prefix}, "fields": {"items(name,size,updated),nextPageToken"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		data, err := b.call(ctx, http.MethodGet, "/storage/v1/b/"+url.PathEscape(b.name)+"/o?"+query.Encode(), nil, "")
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Items []struct {
+				Name    string    `json:"name"`
+				Size    string    `json:"size"` // uint64 encoded as a string
+				Updated time.Time `json:"updated"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("failed to decode Cloud Storage listing: %w", err)
+		}
+		for _, item := range page.Items {
+			size, _ := strconv.ParseInt(item.Size, 10, 64)
+			objects = append(objects, Object{Key: item.Name, Size: size, Modified: item.Updated})
+		}
+		if page.NextPageToken == "" {
+			return objects, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// Get downloads an object's media
+func (b *gcsBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	return b.call(ctx, http.MethodGet, b.objectPath(key)+"?alt=media", nil, "")
+}
+
+// Put uploads an object with a simple media upload
+func (b *gcsBucket) Put(ctx context.Context, key string, data []byte) error {
+	path := "/upload/storage/v1/b/" + url.PathEscape(b.name) + "/o?" + url.Values{"uploadType": {"media"}, "name": {key}}.Encode()
+	_, err := b.call(ctx, http.MethodPost, path, data, "application/json")
+	return err
+}
+
+// Move copies with objects.rewrite and deletes the original
+func (b *gcsBucket) Move(ctx context.Context, from, to string) error {
+	rewrite := b.objectPath(from) + "/rewriteTo/b/" + url.PathEscape(b.name) + "/o/" + url.PathEscape(to)
+	for token := ""; ; {
+		path := rewrite
+		if token != "" {
+			path += "?" + url.Values{"rewriteToken": {token}}.Encode()
+		}
+		data, err := b.call(ctx, http.MethodPost, path, nil, "")
+		if err != nil {
+			return err
+		}
+		// Large or cross-location copies take several calls
+		var resp struct {
+			Done         bool   `json:"done"`
+			RewriteToken string `json:"rewriteToken"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return fmt.Errorf("failed to decode Cloud Storage rewrite: %w", err)
+		}
+		if resp.Done || resp.RewriteToken == "" {
+			break
+		}
+		token = resp.RewriteToken
+	}
+	_, err := b.call(ctx, http.MethodDelete, b.objectPath(from), nil, "")
+	return err
+}
+
+// objectPath is the JSON API path of an object; names are escaped whole,
+// slashes included
+func (b *gcsBucket) objectPath(key string) string {
+	return "/storage/v1/b/" + url.PathEscape(b.name) + "/o/" + url.PathEscape(key)
+}
+
+// call sends an authorized request to the JSON API
+func (b *gcsBucket) call(ctx context.Context, method, path string, body []byte, contentType string) ([]byte, error) {
+	token, err := b.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return do(b.client, req, "Cloud Storage")
+}
+
+// accessToken returns the configured token, or the metadata server's. The
+// metadata server caches and refreshes its tokens, so each call asks it.
+func (b *gcsBucket) accessToken(ctx context.Context) (string, error) {
+	if b.token != "" {
+		return b.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcsMetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	data, err := do(b.client, req, "GCE metadata server")
+	if err != nil {
+		return "", fmt.Errorf("no access token configured: %w", err)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return "", fmt.Errorf("failed to decode metadata token: %w", err)
+	}
+	return token.AccessToken, nil
+}
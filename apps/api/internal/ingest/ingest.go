@@ -0,0 +1,240 @@
+// Package ingest imports attestation bundles and SBOMs from object-store drop
+// buckets, for pipelines that can only write files. Each poll verifies and
+// stores the files under the drop prefix, then moves them to an archive
+// prefix, or to a quarantine prefix with an error note when they are rejected.
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/index"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+// ErrorNoteSuffix is appended to a quarantined object's key to name the note
+// explaining why it was rejected
+const ErrorNoteSuffix = ".error.json"
+
+// TrustSource provides the trust root bundles are verified against, such as
+// trustroot.Manager
+type TrustSource interface {
+	TrustRoot(ctx context.Context) (attestation.TrustRoot, error)
+}
+
+// Config holds ingester configuration
+type Config struct {
+	// ArchivePrefix and QuarantinePrefix are relative to the drop prefix.
+	// Objects under them are not ingested.
+	ArchivePrefix    string
+	QuarantinePrefix string
+	Policy           attestation.IdentityPolicy // Bundles must satisfy it
+	Clock            clock.Clock                // Defaults to the system clock
+}
+
+// DefaultConfig returns the ingester defaults
+func DefaultConfig() Config {
+	return Config{
+		ArchivePrefix:    "archive/",
+		QuarantinePrefix: "quarantine/",
+	}
+}
+
+// Summary counts the outcome of a poll
+type Summary struct {
+	Archived    int `json:"archived"`
+	Quarantined int `json:"quarantined"`
+	Failed      int `json:"failed"` // Left in place after a transient error, to retry next poll
+}
+
+// ErrorNote is written beside a quarantined object
+type ErrorNote struct {
+	Object        string              `json:"object"`
+	Error         string              `json:"error"`
+	Code          string              `json:"code,omitempty"`
+	Report        *attestation.Report `json:"report,omitempty"`
+	QuarantinedAt time.Time           `json:"quarantined_at"`
+}
+
+// rejection is an error that quarantines the object, as opposed to a
+// transient failure that leaves it to be retried
+type rejection struct {
+	err    error
+	report *attestation.Report
+}
+
+func (r *rejection) Error() string { return r.err.Error() }
+
+// Ingester imports the files dropped under one bucket prefix. A prefix should
+// be polled by one ingester at a time; concurrent ingesters store each file
+// once but race to move it.
+type Ingester struct {
+	bucket Bucket
+	prefix string
+	trust  TrustSource
+	index  *index.Store
+	sboms  *sbom.Store
+	config Config
+	clock  clock.Clock
+}
+
+// New creates an ingester for the bucket prefix, indexing verified bundles and
+// storing SBOMs
+func New(bucket Bucket, prefix string, trust TrustSource, attestations *index.Store, sboms *sbom.Store, config Config) *Ingester {
+	if config.ArchivePrefix == "" {
+		config.ArchivePrefix = DefaultConfig().ArchivePrefix
+	}
+	if config.QuarantinePrefix == "" {
+		config.QuarantinePrefix = DefaultConfig().QuarantinePrefix
+	}
+	return &Ingester{
+		bucket: bucket,
+		prefix: prefix,
+		trust:  trust,
+		index:  attestations,
+		sboms:  sboms,
+		config: config,
+		clock:  clock.OrReal(config.Clock),
+	}
+}
+
+// Poll ingests every file currently under the drop prefix. Transient errors
+// leave the file for the next poll and are returned joined.
+func (i *Ingester) Poll(ctx context.Context) (*Summary, error) {
+	objects, err := i.bucket.List(ctx, i.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{}
+	var errs []error
+	for _, object := range objects {
+		relative := strings.TrimPrefix(object.Key, i.prefix)
+		if relative == "" || strings.HasSuffix(relative, "/") ||
+			strings.HasPrefix(relative, i.config.ArchivePrefix) || strings.HasPrefix(relative, i.config.QuarantinePrefix) {
+			continue
+		}
+
+		err := i.ingest(ctx, object)
+		var rejected *rejection
+		switch {
+		case err == nil:
+			err = i.bucket.Move(ctx, object.Key, i.prefix+i.config.ArchivePrefix+relative)
+			if err == nil {
+				summary.Archived++
+			}
+		case errors.As(err, &rejected):
+			err = i.quarantine(ctx, object.Key, relative, rejected)
+			if err == nil {
+				summary.Quarantined++
+			}
+		}
+		if err != nil {
+			summary.Failed++
+			errs = append(errs, fmt.Errorf("%s: %w", object.Key, err))
+		}
+	}
+	return summary, errors.Join(errs...)
+}
+
+// ingest verifies and stores one file
+func (i *Ingester) ingest(ctx context.Context, object Object) error {
+	if object.Size > maxObjectSize {
+		return &rejection{err: fmt.Errorf("file exceeds %d bytes", maxObjectSize)}
+	}
+	data, err := i.bucket.Get(ctx, object.Key)
+	if err != nil {
+		return err
+	}
+
+	var probe struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return &rejection{err: fmt.Errorf("file is neither a verification bundle nor a CycloneDX or SPDX SBOM: %w", err)}
+	}
+	if probe.MediaType == attestation.BundleMediaType {
+		return i.ingestBundle(ctx, object.Key, data)
+	}
+	if _, err := sbom.Decode(data); err != nil {
+		if errors.Is(err, sbom.ErrUnknownFormat) {
+			err = fmt.Errorf("file is neither a verification bundle nor a CycloneDX or SPDX SBOM")
+		}
+		return &rejection{err: err}
+	}
+	stored, added, err := i.sboms.Save(ctx, data, object.Key, i.clock.Now())
+	if err != nil {
+		return err
+	}
+	if added {
+		log.Printf("Ingested %s SBOM %s from %s", stored.Format, stored.Digest, object.Key)
+	}
+	return nil
+}
+
+// ingestBundle verifies a bundle against the server's trust root, ignoring
+// the one it carries, and indexes it
+func (i *Ingester) ingestBundle(ctx context.Context, key string, data []byte) error {
+	var bundle attestation.Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return &rejection{err: attestation.Wrap(attestation.CodeVerificationFailed, err, "Bundle is not valid JSON")}
+	}
+	trust, err := i.trust.TrustRoot(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := attestation.VerifyBundle(&bundle, trust, i.config.Policy)
+	if err != nil {
+		return &rejection{err: err, report: attestation.NewReport(&bundle, result)}
+	}
+	if err := i.index.RecordVerified(ctx, &bundle, result); err != nil {
+		return err
+	}
+	log.Printf("Ingested attestation %s about %s from %s", attestation.RecordID(bundle.Envelope.Signatures[0].Sig), result.Subject, key)
+	return nil
+}
+
+// quarantine moves a rejected file aside with a note explaining why
+func (i *Ingester) quarantine(ctx context.Context, key, relative string, rejected *rejection) error {
+	quarantined := i.prefix + i.config.QuarantinePrefix + relative
+	note, err := json.MarshalIndent(ErrorNote{
+		Object:        key,
+		Error:         rejected.Error(),
+		Code:          attestation.CodeOf(rejected.err),
+		Report:        rejected.report,
+		QuarantinedAt: i.clock.Now().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := i.bucket.Put(ctx, quarantined+ErrorNoteSuffix, note); err != nil {
+		return err
+	}
+	log.Printf("Quarantined %s: %v", key, rejected.err)
+	return i.bucket.Move(ctx, key, quarantined)
+}
+
+// Run polls every interval until the context is cancelled
+func (i *Ingester) Run(ctx context.Context, interval time.Duration) {
+	ticker := i.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if _, err := i.Poll(ctx); err != nil {
+				log.Printf("Drop bucket ingestion: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/sigv4"
+)
+
+// s3Bucket reads and moves objects through the S3 REST API
+type s3Bucket struct {
+	name        string
+	base        string // URL objects are addressed under
+	region      string
+	credentials sigv4.Credentials
+	client      *http.Client
+}
+
+// newS3Bucket addresses AWS buckets virtual-hosted style and buckets on a
+// configured S3-compatible endpoint path style
+func newS3Bucket(name string, credentials Credentials) (*s3Bucket, error) {
+	if credentials.AWS.AccessKeyID == "" || credentials.AWS.SecretAccessKey == "" {
+		return nil, fmt.Errorf("s3 drop buckets require AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	region := credentials.AWSRegion
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	base := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", name, region)
+	if credentials.S3Endpoint != "" {
+		base = strings.TrimSuffix(credentials.S3Endpoint, "/") + "/" + name
+	}
+	return &s3Bucket{name: name, base: base, region: region, credentials: credentials.AWS, client: credentials.HTTPClient}, nil
+}
+
+// listBucketResult is the ListObjectsV2 response
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages through ListObjectsV2
+func (b *s3Bucket) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		data, err := b.call(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page listBucketResult
+		if err := xml.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("failed to decode S3 listing: %w", err)
+		}
+		for _, content := range page.Contents {
+			objects = append(objects, Object{Key: content.Key, Size: content.Size, Modified: content.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// Get reads an object
+func (b *s3Bucket) Get(ctx context.Context, key string) ([]byte, error) {
+	return b.call(ctx, http.MethodGet, key, nil, nil, nil)
+}
+
+// Put writes an object
+func (b *s3Bucket) Put(ctx context.Context, key string, data []byte) error {
+	_, err := b.call(ctx, http.MethodPut, key, nil, data, map[string]string{"Content-Type": "application/json"})
+	return err
+}
+
+// Move copies with CopyObject and deletes the original
+func (b *s3Bucket) Move(ctx context.Context, from, to string) error {
+	source := "/" + b.name + "/" + sigv4.EscapePath(from)
+	if _, err := b.call(ctx, http.MethodPut, to, nil, nil, map[string]string{"X-Amz-Copy-Source": source}); err != nil {
+		return err
+	}
+	_, err := b.call(ctx, http.MethodDelete, from, nil, nil, nil)
+	return err
+}
+
+// call sends a SigV4-signed request for an object, or the bucket when key is empty
+func (b *s3Bucket) call(ctx context.Context, method, key string, query url.Values, body []byte, headers map[string]string) ([]byte, error) {
+	target, err := url.Parse(b.base + "/")
+	if err != nil {
+		return nil, err
+	}
+	target.Path += key
+	target.RawPath = sigv4.EscapePath(target.Path)
+	target.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	bodyHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(bodyHash[:]))
+	sigv4.Sign(req, body, b.credentials, b.region, "s3", time.Now())
+	return do(b.client, req, "S3")
+}
@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+	"github.com/salman-frs/keystone/apps/api/internal/metering"
+	"github.com/salman-frs/keystone/apps/api/internal/quota"
+	"github.com/salman-frs/keystone/apps/api/internal/slo"
+)
+
+// Job kinds executed by workers
+const (
+	KindScan         = "scan"
+	KindVerification = "verification"
+	KindAdvisorySync = "advisory_sync"
+)
+
+// kindMetrics maps job kinds to the usage metric counting their executions
+var kindMetrics = map[string]string{
+	KindScan:         metering.MetricScans,
+	KindVerification: metering.MetricVerifications,
+	KindAdvisorySync: metering.MetricAdvisorySyncs,
+}
+
+// kindOperations maps job kinds to the SLO operation their latency counts towards
+var kindOperations = map[string]string{
+	KindVerification: slo.OperationVerify,
+}
+
+// Admitter decides whether a tenant may run more metered work
+type Admitter interface {
+	Admit(ctx context.Context, metric string) error
+}
+
+// Admit checks a job kind against the admitter; kinds without a usage metric are always admitted
+func Admit(ctx context.Context, admitter Admitter, kind string) error {
+	metric, ok := kindMetrics[kind]
+	if !ok || admitter == nil {
+		return nil
+	}
+	return admitter.Admit(ctx, metric)
+}
+
+// StorageReporter is implemented by runner outputs that persisted data on behalf of the tenant
+type StorageReporter interface {
+	StorageBytes() int64
+}
+
+// DefaultQueueGroup is the queue group shared by all worker processes
+const DefaultQueueGroup = "keystone-workers"
+
+// Job is a unit of background work carried in a job.requested event
+type Job struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Tenant      string          `json:"tenant"`
+	Override    bool            `json:"quota_override,omitempty"` // Submitted by an administrator bypassing quotas
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	SubmittedAt time.Time       `json:"submitted_at"`
+}
+
+// Result is the payload of job.completed and job.failed events
+type Result struct {
+	JobID      string      `json:"job_id"`
+	Kind       string      `json:"kind"`
+	Worker     string      `json:"worker"`
+	StartedAt  time.Time   `json:"started_at"`
+	DurationMs int64       `json:"duration_ms"`
+	Output     interface{} `json:"output,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// DecodePayload unmarshals the job payload into v
+func (j Job) DecodePayload(v interface{}) error {
+	if len(j.Payload) == 0 {
+		return fmt.Errorf("job %s has no payload", j.ID)
+	}
+	if err := json.Unmarshal(j.Payload, v); err != nil {
+		return fmt.Errorf("invalid %s payload for job %s: %w", j.Kind, j.ID, err)
+	}
+	return nil
+}
+
+// Submit publishes a job for execution by a worker and returns it; usage is
+// attributed to the tenant carried by ctx
+func Submit(ctx context.Context, bus events.Bus, source, kind string, payload interface{}) (*Job, error) {
+	event, err := events.NewEvent(events.TypeJobRequested, source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		ID:          event.ID,
+		Kind:        kind,
+		Tenant:      metering.TenantFromContext(ctx),
+		Override:    quota.Overridden(ctx),
+		SubmittedAt: event.Time,
+	}
+	if payload != nil {
+		if job.Payload, err = json.Marshal(payload); err != nil {
+			return nil, fmt.Errorf("failed to encode %s payload: %w", kind, err)
+		}
+	}
+
+	if event.Data, err = json.Marshal(job); err != nil {
+		return nil, fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	if err := bus.Publish(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to submit %s job: %w", kind, err)
+	}
+
+	return job, nil
+}
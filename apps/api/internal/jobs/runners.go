@@ -0,0 +1,163 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/advisories"
+	"github.com/salman-frs/keystone/apps/api/internal/exceptions"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/pkgverify"
+	"github.com/salman-frs/keystone/apps/api/internal/plugins"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// AdvisorySyncPayload is the payload of an advisory_sync job
+type AdvisorySyncPayload struct {
+	Ecosystem string    `json:"ecosystem"`
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until,omitempty"`
+	// Incremental fetches what was updated since the ecosystem's last
+	// incremental sync instead of backfilling a range; Since is then only
+	// the starting point of the first run
+	Incremental bool `json:"incremental,omitempty"`
+}
+
+// ScanPayload is the payload of a scan job
+type ScanPayload struct {
+	Image   string `json:"image"`             // Digest reference, e.g. ghcr.io/acme/widgets@sha256:...
+	Trigger string `json:"trigger,omitempty"` // What asked for the scan, e.g. a webhook delivery
+}
+
+// VerificationPayload is the payload of a verification job
+type VerificationPayload struct {
+	SBOM  json.RawMessage `json:"sbom"`            // CycloneDX or SPDX JSON document
+	Scope string          `json:"scope,omitempty"` // Artifact whose approved exceptions apply
+}
+
+// VerificationOutput summarises a verification job
+type VerificationOutput struct {
+	Components int                 `json:"components"`
+	Verified   int                 `json:"verified"`
+	Findings   []findings.Finding  `json:"findings,omitempty"`
+	Waived     []exceptions.Waiver `json:"waived,omitempty"` // Findings accepted by an active exception
+
+	Gates          []plugins.GateResult `json:"gates,omitempty"`
+	Blocked        bool                 `json:"blocked,omitempty"`         // A plugin gate didn't allow the result
+	PluginFailures []plugins.Failure    `json:"plugin_failures,omitempty"` // Enrichers skipped because they failed
+}
+
+// count records one verified or unverified component
+func (o *VerificationOutput) count(verified bool) {
+	o.Components++
+	if verified {
+		o.Verified++
+	}
+}
+
+// AdvisorySyncRunner runs resumable advisory backfills; a rerun of the same
+// ecosystem and start date continues from the stored checkpoint. Incremental
+// syncs continue from the ecosystem's high-water mark.
+func AdvisorySyncRunner(client *github.Client, store *advisories.Store) Runner {
+	return func(ctx context.Context, job Job) (interface{}, error) {
+		var payload AdvisorySyncPayload
+		if err := job.DecodePayload(&payload); err != nil {
+			return nil, err
+		}
+		if payload.Incremental {
+			if payload.Ecosystem == "" {
+				return nil, fmt.Errorf("advisory_sync job %s requires ecosystem", job.ID)
+			}
+			config := advisories.DefaultSyncConfig(payload.Ecosystem)
+			config.Since = payload.Since
+			return advisories.NewSyncer(client, store, config).Run(ctx)
+		}
+		if payload.Ecosystem == "" || payload.Since.IsZero() {
+			return nil, fmt.Errorf("advisory_sync job %s requires ecosystem and since", job.ID)
+		}
+
+		config := advisories.DefaultBackfillConfig(payload.Ecosystem, payload.Since)
+		config.Until = payload.Until
+
+		return advisories.NewBackfiller(client, store, config).Run(ctx)
+	}
+}
+
+// Verifiers are the package verifiers a verification job runs; nil ones are skipped
+type Verifiers struct {
+	Maven *pkgverify.MavenVerifier
+	Npm   *pkgverify.NpmVerifier
+	PyPI  *pkgverify.PyPIVerifier
+
+	// Plugins enrich the findings and gate the result; nil runs none
+	Plugins *plugins.Host
+}
+
+// VerificationRunner verifies package signatures and provenance for every
+// supported component of an SBOM. When the job names a scope, findings
+// accepted by an approved, unexpired exception for it are reported as waived
+// instead. Plugin enrichers see every finding before exceptions apply; plugin
+// gates judge what remains.
+func VerificationRunner(verifiers Verifiers, accepted *exceptions.Store) Runner {
+	return func(ctx context.Context, job Job) (interface{}, error) {
+		var payload VerificationPayload
+		if err := job.DecodePayload(&payload); err != nil {
+			return nil, err
+		}
+
+		doc, err := sbom.Decode(payload.SBOM)
+		if err != nil {
+			return nil, fmt.Errorf("verification job %s: %w", job.ID, err)
+		}
+
+		output := &VerificationOutput{}
+		var found []findings.Finding
+		if verifiers.Maven != nil {
+			results, failed := verifiers.Maven.VerifyDocument(ctx, doc)
+			for _, result := range results {
+				output.count(result.Verified())
+			}
+			found = append(found, failed...)
+		}
+		if verifiers.Npm != nil {
+			results, failed := verifiers.Npm.VerifyDocument(ctx, doc)
+			for _, result := range results {
+				output.count(result.Verified())
+			}
+			found = append(found, failed...)
+		}
+		if verifiers.PyPI != nil {
+			results, failed := verifiers.PyPI.VerifyDocument(ctx, doc)
+			for _, result := range results {
+				output.count(result.Verified())
+			}
+			found = append(found, failed...)
+		}
+		if verifiers.Plugins != nil {
+			found, output.PluginFailures = verifiers.Plugins.Enrich(ctx, found)
+		}
+		output.Findings = found
+
+		if accepted != nil && payload.Scope != "" {
+			output.Findings, output.Waived, err = accepted.Apply(ctx, payload.Scope, found)
+			if err != nil {
+				return nil, fmt.Errorf("verification job %s: %w", job.ID, err)
+			}
+		}
+
+		if verifiers.Plugins != nil {
+			output.Gates = verifiers.Plugins.Evaluate(ctx, plugins.GateInput{
+				Scope:      payload.Scope,
+				Components: output.Components,
+				Verified:   output.Verified,
+				Findings:   output.Findings,
+			})
+			output.Blocked = !plugins.Allowed(output.Gates)
+		}
+
+		return output, nil
+	}
+}
@@ -0,0 +1,227 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+	"github.com/salman-frs/keystone/apps/api/internal/metering"
+	"github.com/salman-frs/keystone/apps/api/internal/quota"
+	"github.com/salman-frs/keystone/apps/api/internal/slo"
+)
+
+// Runner executes one kind of job and returns output for the completion event
+type Runner func(ctx context.Context, job Job) (interface{}, error)
+
+// WorkerConfig holds worker configuration
+type WorkerConfig struct {
+	Name        string // Reported in job results
+	QueueGroup  string
+	Concurrency int // Number of bus subscriptions, each handling one job at a time
+	JobTimeout  time.Duration
+}
+
+// DefaultWorkerConfig returns default worker configuration
+func DefaultWorkerConfig(name string) WorkerConfig {
+	return WorkerConfig{
+		Name:        name,
+		QueueGroup:  DefaultQueueGroup,
+		Concurrency: 4,
+		JobTimeout:  30 * time.Minute,
+	}
+}
+
+// Worker consumes job.requested events and runs them with registered runners
+type Worker struct {
+	bus      events.Bus
+	config   WorkerConfig
+	runners  map[string]Runner
+	meter    *metering.Meter
+	admitter Admitter
+	slos     *slo.Tracker
+
+	mutex    sync.Mutex
+	subs     []events.Subscription
+	ctx      context.Context
+	cancel   context.CancelFunc
+	inflight sync.WaitGroup
+}
+
+// NewWorker creates a new job worker
+func NewWorker(bus events.Bus, config WorkerConfig) *Worker {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	return &Worker{
+		bus:     bus,
+		config:  config,
+		runners: make(map[string]Runner),
+	}
+}
+
+// Register sets the runner for a job kind; it must be called before Start
+func (w *Worker) Register(kind string, runner Runner) {
+	w.runners[kind] = runner
+}
+
+// SetMeter enables per-tenant usage metering of executed jobs; it must be called before Start
+func (w *Worker) SetMeter(meter *metering.Meter) {
+	w.meter = meter
+}
+
+// SetAdmitter enables quota checks before jobs run, so work submitted
+// directly to the bus is held to the same limits as the API; it must be called before Start
+func (w *Worker) SetAdmitter(admitter Admitter) {
+	w.admitter = admitter
+}
+
+// SetSLOTracker records verification job latency against its objective; it
+// must be called before Start
+func (w *Worker) SetSLOTracker(tracker *slo.Tracker) {
+	w.slos = tracker
+}
+
+// Kinds returns the registered job kinds
+func (w *Worker) Kinds() []string {
+	kinds := make([]string, 0, len(w.runners))
+	for kind := range w.runners {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// Start subscribes to job requests in the worker queue group
+func (w *Worker) Start() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.cancel != nil {
+		return fmt.Errorf("worker already started")
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < w.config.Concurrency; i++ {
+		sub, err := w.bus.Subscribe(events.TypeJobRequested, w.handle, events.WithQueueGroup(w.config.QueueGroup))
+		if err != nil {
+			w.unsubscribeAll()
+			return fmt.Errorf("failed to subscribe to job requests: %w", err)
+		}
+		w.subs = append(w.subs, sub)
+	}
+
+	return nil
+}
+
+// Stop unsubscribes and waits for in-flight jobs; jobs still running when ctx expires are cancelled
+func (w *Worker) Stop(ctx context.Context) error {
+	w.mutex.Lock()
+	w.unsubscribeAll()
+	cancel := w.cancel
+	w.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if cancel != nil {
+			cancel()
+		}
+		<-done
+		return ctx.Err()
+	}
+
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+// unsubscribeAll removes every subscription; the caller must hold the lock
+func (w *Worker) unsubscribeAll() {
+	for _, sub := range w.subs {
+		if err := sub.Unsubscribe(); err != nil {
+			log.Printf("Failed to unsubscribe worker %s: %v", w.config.Name, err)
+		}
+	}
+	w.subs = nil
+}
+
+// handle runs a single job request and publishes its outcome
+func (w *Worker) handle(_ context.Context, event events.Event) error {
+	w.inflight.Add(1)
+	defer w.inflight.Done()
+
+	var job Job
+	if err := event.Decode(&job); err != nil {
+		return fmt.Errorf("invalid job request %s: %w", event.ID, err)
+	}
+
+	result := Result{
+		JobID:     job.ID,
+		Kind:      job.Kind,
+		Worker:    w.config.Name,
+		StartedAt: time.Now().UTC(),
+	}
+
+	jobCtx := metering.WithTenant(w.ctx, job.Tenant)
+	if job.Override {
+		jobCtx = quota.WithOverride(jobCtx)
+	}
+
+	var err error
+	runner, ok := w.runners[job.Kind]
+	if !ok {
+		err = fmt.Errorf("no runner registered for job kind %q", job.Kind)
+	} else if err = Admit(jobCtx, w.admitter, job.Kind); err == nil {
+		ctx, cancel := context.WithTimeout(jobCtx, w.config.JobTimeout)
+		result.Output, err = runner(ctx, job)
+		cancel()
+		w.meterJob(job, result.Output)
+		if operation, ok := kindOperations[job.Kind]; ok && w.slos != nil {
+			w.slos.Record(operation, time.Since(result.StartedAt), err)
+		}
+	}
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+
+	eventType := events.TypeJobCompleted
+	if err != nil {
+		eventType = events.TypeJobFailed
+		result.Error = err.Error()
+		log.Printf("Job %s (%s) failed: %v", job.ID, job.Kind, err)
+	}
+
+	outcome, encodeErr := events.NewEvent(eventType, w.config.Name, result)
+	if encodeErr != nil {
+		return encodeErr
+	}
+	// Publish even when shutting down so the submitter learns the job's fate
+	return w.bus.Publish(context.Background(), outcome)
+}
+
+// meterJob records the execution, and any storage it reported, against the job's tenant
+func (w *Worker) meterJob(job Job, output interface{}) {
+	if w.meter == nil {
+		return
+	}
+
+	// The job context may already be cancelled; usage must still be recorded
+	ctx := metering.WithTenant(context.Background(), job.Tenant)
+	if metric, ok := kindMetrics[job.Kind]; ok {
+		if err := w.meter.Record(ctx, metric, 1); err != nil {
+			log.Printf("Failed to meter job %s: %v", job.ID, err)
+		}
+	}
+	if reporter, ok := output.(StorageReporter); ok {
+		if err := w.meter.Record(ctx, metering.MetricStorageBytes, reporter.StorageBytes()); err != nil {
+			log.Printf("Failed to meter storage for job %s: %v", job.ID, err)
+		}
+	}
+}
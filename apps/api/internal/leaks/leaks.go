@@ -0,0 +1,103 @@
+// Package leaks turns GitHub secret scanning alerts into findings, and
+// correlates them with the identities that sign attestations. A credential
+// leaked from a repository whose workflows sign artifacts could be used to
+// push code those workflows then sign, so such leaks rank higher.
+package leaks
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// Source is the finding source of secret scanning alerts
+const Source = "github-secret-scanning"
+
+// Metadata keys set on leak findings, besides repository and workflow_ref
+const (
+	MetadataAlertURL = "alert_url"
+	MetadataValidity = "validity"
+)
+
+// Findings describes each open alert as a finding. Alerts in a repository
+// one of the identities signs from name its workflows and are ranked:
+//
+//   - CRITICAL when signing workflows share a repository with a credential
+//     that is still active or was leaked publicly
+//   - HIGH when they share it with any other leak, or the credential is active
+//   - MEDIUM otherwise
+func Findings(alerts []github.SecretScanningAlert, identities []attestation.CertificateIdentity) []findings.Finding {
+	signers := make(map[string][]string)
+	for _, identity := range identities {
+		if identity.Repository == "" {
+			continue
+		}
+		repository := strings.ToLower(identity.Repository)
+		signers[repository] = appendUnique(signers[repository], identity.WorkflowRef)
+	}
+
+	var found []findings.Finding
+	for _, alert := range alerts {
+		if alert.State != github.SecretAlertOpen {
+			continue
+		}
+		workflows, signs := signers[strings.ToLower(alert.Repository)]
+		found = append(found, leakFinding(alert, workflows, signs))
+	}
+	return found
+}
+
+// leakFinding describes an open alert
+func leakFinding(alert github.SecretScanningAlert, workflows []string, signs bool) findings.Finding {
+	active := alert.Validity == "active"
+	severity := findings.SeverityMedium
+	switch {
+	case signs && (active || alert.PubliclyLeaked):
+		severity = findings.SeverityCritical
+	case signs || active:
+		severity = findings.SeverityHigh
+	}
+
+	name := alert.SecretTypeDisplayName
+	if name == "" {
+		name = alert.SecretType
+	}
+	subject := alert.Repository + "#" + strconv.Itoa(alert.Number)
+	finding := findings.New(Source, findings.CategorySecret, severity, alert.SecretType, subject,
+		fmt.Sprintf("Leaked %s in %s", name, alert.Repository))
+	finding.Component = alert.Repository
+	finding.DetectedAt = alert.CreatedAt
+	finding.Metadata["repository"] = alert.Repository
+	finding.Metadata[MetadataAlertURL] = alert.HTMLURL
+	if alert.Validity != "" {
+		finding.Metadata[MetadataValidity] = alert.Validity
+	}
+	if signs {
+		finding.Description = fmt.Sprintf("%s signs attestations; revoke the credential and review what its workflows signed since %s.",
+			alert.Repository, alert.CreatedAt.Format("2006-01-02"))
+		if len(workflows) > 0 {
+			finding.Metadata["workflow_ref"] = strings.Join(workflows, ",")
+		}
+	}
+	return finding
+}
+
+// appendUnique adds a workflow to a sorted set of them
+func appendUnique(workflows []string, workflow string) []string {
+	if workflow == "" {
+		return workflows
+	}
+	i := sort.SearchStrings(workflows, workflow)
+	if i < len(workflows) && workflows[i] == workflow {
+		return workflows
+	}
+	workflows = append(workflows, "")
+	copy(workflows[i+1:], workflows[i:])
+	workflows[i] = workflow
+	return workflows
+}
@@ -0,0 +1,366 @@
+// Package loadtest measures verification throughput at scale. It signs
+// thousands of synthetic artifacts with a throwaway Sigstore, then replays
+// verification traffic against each combination of database, cache size and
+// concurrency, recording latency, result cache effectiveness and database
+// contention in a report.
+package loadtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/index"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/verifycache"
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+// Databases a scenario can run against. Keystone stores everything in SQLite,
+// so the comparison is between a WAL database on disk and a shared in-memory
+// one, which isolates the cost of disk I/O from lock contention.
+const (
+	DatabaseFile   = "sqlite-file"
+	DatabaseMemory = "sqlite-memory"
+)
+
+// Config describes the matrix of scenarios to run
+type Config struct {
+	Artifacts     int      // Synthetic signed artifacts
+	Verifications int      // Verification requests per scenario
+	HotFraction   float64  // Share of requests for the hottest tenth of artifacts, as pipelines re-verify recent releases
+	Concurrency   []int    // Parallel verifiers
+	L1MaxItems    []int    // In-memory cache sizes
+	Databases     []string // DatabaseFile and/or DatabaseMemory
+	Index         bool     // Record each verification in the attestation index, as uploads do
+	MigrationsDir string
+	Dir           string // Where file databases are created
+	Seed          int64  // Seeds the request sequence so runs are comparable
+}
+
+// DefaultConfig runs 20,000 verifications of 2,000 artifacts against both
+// databases, three cache sizes and three concurrency levels
+func DefaultConfig() Config {
+	return Config{
+		Artifacts:     2000,
+		Verifications: 20000,
+		HotFraction:   0.8,
+		Concurrency:   []int{1, 8, 32},
+		L1MaxItems:    []int{100, 1000, 10000},
+		Databases:     []string{DatabaseFile, DatabaseMemory},
+		Index:         true,
+		MigrationsDir: "internal/storage/migrations",
+		Seed:          1,
+	}
+}
+
+// Validate checks the matrix is runnable
+func (c Config) Validate() error {
+	if c.Artifacts <= 0 || c.Verifications <= 0 {
+		return fmt.Errorf("load test needs at least one artifact and verification")
+	}
+	if c.HotFraction < 0 || c.HotFraction > 1 {
+		return fmt.Errorf("hot fraction must be between 0 and 1")
+	}
+	if len(c.Concurrency) == 0 || len(c.L1MaxItems) == 0 || len(c.Databases) == 0 {
+		return fmt.Errorf("load test needs at least one concurrency, cache size and database")
+	}
+	for _, database := range c.Databases {
+		if database != DatabaseFile && database != DatabaseMemory {
+			return fmt.Errorf("unsupported database %q, expected %s or %s", database, DatabaseFile, DatabaseMemory)
+		}
+	}
+	return nil
+}
+
+// Scenario is one combination from the matrix
+type Scenario struct {
+	Name        string `json:"name"`
+	Database    string `json:"database"`
+	L1MaxItems  int    `json:"l1_max_items"`
+	Concurrency int    `json:"concurrency"`
+}
+
+// Scenarios expands the matrix, varying concurrency fastest
+func (c Config) Scenarios() []Scenario {
+	var scenarios []Scenario
+	for _, database := range c.Databases {
+		for _, items := range c.L1MaxItems {
+			for _, concurrency := range c.Concurrency {
+				scenarios = append(scenarios, Scenario{
+					Name:        fmt.Sprintf("%s/l1=%d/c=%d", database, items, concurrency),
+					Database:    database,
+					L1MaxItems:  items,
+					Concurrency: concurrency,
+				})
+			}
+		}
+	}
+	return scenarios
+}
+
+// Latencies summarises a latency distribution
+type Latencies struct {
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// summarize computes percentiles, sorting the samples in place
+func summarize(samples []time.Duration) Latencies {
+	if len(samples) == 0 {
+		return Latencies{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	at := func(p float64) time.Duration {
+		return samples[int(p*float64(len(samples)-1))]
+	}
+	return Latencies{P50: at(0.50), P95: at(0.95), P99: at(0.99), Max: samples[len(samples)-1]}
+}
+
+// Result is the outcome of one scenario
+type Result struct {
+	Scenario      Scenario      `json:"scenario"`
+	Verifications int           `json:"verifications"`
+	Failures      int           `json:"failures"`
+	Duration      time.Duration `json:"duration"`
+	Throughput    float64       `json:"throughput"` // Verifications per second
+	Latency       Latencies     `json:"latency"`
+	CacheHitRatio float64       `json:"cache_hit_ratio"` // Verifications answered from the result cache
+	L1HitRatio    float64       `json:"l1_hit_ratio"`
+	L2HitRatio    float64       `json:"l2_hit_ratio"`
+	Evictions     int64         `json:"evictions"`
+	IndexWrites   Latencies     `json:"index_writes"`
+	BusyErrors    int           `json:"busy_errors"` // Index writes that failed on a locked database
+}
+
+// Report is the outcome of a load test run
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Artifacts   int       `json:"artifacts"`
+	HotFraction float64   `json:"hot_fraction"`
+	Index       bool      `json:"index"`
+	CPUs        int       `json:"cpus"`
+	Results     []Result  `json:"results"`
+}
+
+// Run signs the synthetic artifacts once and runs every scenario of the matrix
+// against them. progress, when set, is called as each scenario completes.
+func Run(ctx context.Context, config Config, progress func(Result)) (*Report, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	sigstore, err := NewSigstore()
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := sigstore.Generate(config.Artifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Dir == "" {
+		dir, err := os.MkdirTemp("", "keystone-loadtest-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		config.Dir = dir
+	}
+
+	report := &Report{
+		GeneratedAt: time.Now().UTC(),
+		Artifacts:   config.Artifacts,
+		HotFraction: config.HotFraction,
+		Index:       config.Index,
+		CPUs:        runtime.GOMAXPROCS(0),
+	}
+	requests := requestSequence(config)
+	for _, scenario := range config.Scenarios() {
+		result, err := runScenario(ctx, config, scenario, sigstore.TrustRoot(), sigstore.Policy(), artifacts, requests)
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", scenario.Name, err)
+		}
+		report.Results = append(report.Results, *result)
+		if progress != nil {
+			progress(*result)
+		}
+	}
+	return report, nil
+}
+
+// requestSequence picks the artifact each request verifies, sending the hot
+// fraction of requests to the first tenth of artifacts
+func requestSequence(config Config) []int {
+	random := rand.New(rand.NewSource(config.Seed))
+	hot := config.Artifacts / 10
+	if hot == 0 {
+		hot = 1
+	}
+
+	requests := make([]int, config.Verifications)
+	for i := range requests {
+		if random.Float64() < config.HotFraction {
+			requests[i] = random.Intn(hot)
+		} else {
+			requests[i] = random.Intn(config.Artifacts)
+		}
+	}
+	return requests
+}
+
+// openDatabase opens a fresh, migrated database for the scenario
+func openDatabase(ctx context.Context, config Config, scenario Scenario) (*sql.DB, error) {
+	name := strings.NewReplacer("/", "-", "=", "").Replace(scenario.Name)
+
+	var db *sql.DB
+	var err error
+	if scenario.Database == DatabaseMemory {
+		// A shared cache keeps one in-memory database across the pool's connections
+		db, err = sql.Open("sqlite3", fmt.Sprintf("file:%s-%d?mode=memory&cache=shared&_busy_timeout=5000", name, time.Now().UnixNano()))
+	} else {
+		path := filepath.Join(config.Dir, name+".db")
+		// Each scenario starts from an empty database so earlier runs don't skew it
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			if err := os.Remove(path + suffix); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		db, err = storage.OpenDatabase(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.NewMigrationManager(db, config.MigrationsDir).MigrateWithLock(ctx, "loadtest", time.Minute); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// runScenario replays the request sequence with the scenario's concurrency
+func runScenario(ctx context.Context, config Config, scenario Scenario, trust attestation.TrustRoot, policy attestation.IdentityPolicy,
+	artifacts []Artifact, requests []int) (*Result, error) {
+	db, err := openDatabase(ctx, config, scenario)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	cacheConfig := cache.DefaultCacheConfig()
+	cacheConfig.L1MaxItems = scenario.L1MaxItems
+	hierCache, err := cache.NewHierarchicalCache(cacheConfig, db, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer hierCache.Close()
+	results := verifycache.New(verifycache.DefaultConfig(), hierCache)
+	attestations := index.NewStore(db)
+
+	var (
+		mutex       sync.Mutex
+		latencies   = make([]time.Duration, 0, len(requests))
+		indexWrites = make([]time.Duration, 0, len(requests))
+		failures    int
+		cached      int
+		busy        int
+	)
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < scenario.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				artifact := artifacts[i]
+				began := time.Now()
+				result, err := results.Verify(ctx, artifact.Digest, policy, func(ctx context.Context) (*attestation.VerificationResult, error) {
+					return attestation.VerifyBundle(artifact.Bundle, trust, policy)
+				})
+				elapsed := time.Since(began)
+
+				var write time.Duration
+				var writeErr error
+				if err == nil && config.Index {
+					began = time.Now()
+					writeErr = attestations.RecordVerified(ctx, artifact.Bundle, result)
+					write = time.Since(began)
+				}
+
+				mutex.Lock()
+				latencies = append(latencies, elapsed)
+				switch {
+				case err != nil:
+					failures++
+				case result.Cached:
+					cached++
+				}
+				if config.Index && err == nil {
+					indexWrites = append(indexWrites, write)
+				}
+				if isBusy(writeErr) {
+					busy++
+				} else if writeErr != nil {
+					failures++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, i := range requests {
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(queue)
+	wg.Wait()
+	duration := time.Since(start)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := hierCache.Stats()
+	result := &Result{
+		Scenario:      scenario,
+		Verifications: len(latencies),
+		Failures:      failures,
+		Duration:      duration,
+		Throughput:    float64(len(latencies)) / duration.Seconds(),
+		Latency:       summarize(latencies),
+		CacheHitRatio: float64(cached) / float64(len(latencies)),
+		L1HitRatio:    stats.L1Ratio,
+		L2HitRatio:    stats.L2Ratio,
+		Evictions:     stats.Metrics.Evictions,
+		IndexWrites:   summarize(indexWrites),
+		BusyErrors:    busy,
+	}
+	return result, nil
+}
+
+// isBusy reports whether a write failed because another connection held the database lock
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
@@ -0,0 +1,69 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// JSON renders the report for archiving as a CI artifact
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Markdown renders the report for a job summary
+func (r *Report) Markdown() string {
+	var buf bytes.Buffer
+	r.WriteMarkdown(&buf)
+	return buf.String()
+}
+
+// WriteMarkdown writes the report as Markdown, one table row per scenario
+func (r *Report) WriteMarkdown(w io.Writer) {
+	fmt.Fprint(w, "## Verification load test\n\n")
+	indexed := "without"
+	if r.Index {
+		indexed = "with"
+	}
+	fmt.Fprintf(w, "%d synthetic artifacts, %.0f%% of requests for the hottest tenth, %s attestation indexing, %d CPUs. Generated at %s.\n",
+		r.Artifacts, r.HotFraction*100, indexed, r.CPUs, r.GeneratedAt.UTC().Format(time.RFC3339))
+
+	fmt.Fprint(w, "\n| Scenario | Verifications/s | p50 | p95 | p99 | Result cache hits | L1 hits | L2 hits | Index write p95 | Busy | Failures |\n")
+	fmt.Fprint(w, "| --- | ---: | ---: | ---: | ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	for _, result := range r.Results {
+		fmt.Fprintf(w, "| `%s` | %.0f | %s | %s | %s | %.1f%% | %.1f%% | %.1f%% | %s | %d | %d |\n",
+			result.Scenario.Name, result.Throughput,
+			formatLatency(result.Latency.P50), formatLatency(result.Latency.P95), formatLatency(result.Latency.P99),
+			result.CacheHitRatio*100, result.L1HitRatio*100, result.L2HitRatio*100,
+			formatLatency(result.IndexWrites.P95), result.BusyErrors, result.Failures)
+	}
+
+	if best := r.fastest(); best != nil {
+		fmt.Fprintf(w, "\nHighest throughput: `%s` at %.0f verifications/s.\n", best.Scenario.Name, best.Throughput)
+	}
+}
+
+// fastest returns the scenario with the highest throughput
+func (r *Report) fastest() *Result {
+	var best *Result
+	for i := range r.Results {
+		if best == nil || r.Results[i].Throughput > best.Throughput {
+			best = &r.Results[i]
+		}
+	}
+	return best
+}
+
+// formatLatency rounds a latency to a readable precision
+func formatLatency(d time.Duration) string {
+	switch {
+	case d == 0:
+		return "—"
+	case d < time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	default:
+		return d.Round(10 * time.Microsecond).String()
+	}
+}
@@ -0,0 +1,234 @@
+package loadtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// Identity of the synthetic signer, which the load test policy requires
+const (
+	SyntheticIssuer     = attestation.GitHubActionsIssuer
+	SyntheticRepository = "keystone-loadtest/artifacts"
+	syntheticWorkflow   = "https://github.com/" + SyntheticRepository + "/.github/workflows/release.yml@refs/heads/main"
+)
+
+// Artifact is a synthetic signed artifact
+type Artifact struct {
+	Digest string // "sha256:<hex>" subject digest
+	Bundle *attestation.Bundle
+}
+
+// Sigstore is a throwaway Fulcio CA and Rekor log that sign synthetic
+// artifacts the same way the public instance would, so verifying them does
+// the same certificate, transparency log and signature work
+type Sigstore struct {
+	root     *x509.Certificate
+	rootKey  *ecdsa.PrivateKey
+	rekorKey *ecdsa.PrivateKey
+	logID    string
+	trust    attestation.TrustRoot
+	serial   int64
+}
+
+// NewSigstore creates a CA and log with fresh keys
+func NewSigstore() (*Sigstore, error) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "keystone-loadtest-fulcio"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, template, template, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create synthetic Fulcio root: %w", err)
+	}
+	root, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		return nil, err
+	}
+
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	rekorDER, err := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	logID := sha256.Sum256(rekorDER)
+
+	return &Sigstore{
+		root:     root,
+		rootKey:  rootKey,
+		rekorKey: rekorKey,
+		logID:    hex.EncodeToString(logID[:]),
+		trust: attestation.TrustRoot{
+			FulcioCertificates: []string{string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}))},
+			RekorPublicKeys:    []string{string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rekorDER}))},
+		},
+	}, nil
+}
+
+// TrustRoot returns the trust root synthetic bundles verify against
+func (s *Sigstore) TrustRoot() attestation.TrustRoot {
+	return s.trust
+}
+
+// Policy returns the identity policy synthetic bundles satisfy
+func (s *Sigstore) Policy() attestation.IdentityPolicy {
+	return attestation.IdentityPolicy{
+		Issuer:     SyntheticIssuer,
+		Repository: SyntheticRepository,
+		Branch:     "main",
+	}
+}
+
+// Generate signs n artifacts with distinct subjects, each with its own
+// short-lived certificate and log entry
+func (s *Sigstore) Generate(n int) ([]Artifact, error) {
+	artifacts := make([]Artifact, 0, n)
+	for i := 0; i < n; i++ {
+		content := sha256.Sum256([]byte(fmt.Sprintf("keystone-loadtest-artifact-%d", i)))
+		subject, err := attestation.NewSubject(fmt.Sprintf("ghcr.io/%s/artifact-%d", SyntheticRepository, i),
+			"sha256:"+hex.EncodeToString(content[:]))
+		if err != nil {
+			return nil, err
+		}
+		bundle, err := s.sign(subject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign synthetic artifact %d: %w", i, err)
+		}
+		artifacts = append(artifacts, Artifact{Digest: subject.String(), Bundle: bundle})
+	}
+	return artifacts, nil
+}
+
+// sign issues a certificate, signs provenance for the subject and logs it
+func (s *Sigstore) sign(subject attestation.Subject) (*attestation.Bundle, error) {
+	s.serial++
+	issuedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	san, err := url.Parse(syntheticWorkflow)
+	if err != nil {
+		return nil, err
+	}
+	extensions := []pkix.Extension{}
+	for n, value := range map[int]string{8: SyntheticIssuer, 12: "https://github.com/" + SyntheticRepository, 14: "refs/heads/main"} {
+		encoded, err := asn1.MarshalWithParams(value, "utf8")
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, n}, Value: encoded})
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(s.serial + 1),
+		NotBefore:       issuedAt,
+		NotAfter:        issuedAt.Add(10 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:            []*url.URL{san},
+		ExtraExtensions: extensions,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, template, s.root, &leafKey.PublicKey, s.rootKey)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		return nil, err
+	}
+
+	statement, err := attestation.NewProvenanceBuilder().Build([]attestation.Subject{subject}, attestation.BuildContext{
+		Repository:   SyntheticRepository,
+		Ref:          "refs/heads/main",
+		SHA:          subject.Digest["sha256"][:40],
+		WorkflowPath: ".github/workflows/release.yml",
+		EventName:    "push",
+		RunID:        fmt.Sprint(s.serial),
+		RunAttempt:   "1",
+		StartedOn:    issuedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := attestation.NewEnvelope(statement)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := envelope.DecodePayload()
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(attestation.PAE(envelope.PayloadType, payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, leafKey, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	envelope.Signatures = []attestation.EnvelopeSignature{{Sig: base64.StdEncoding.EncodeToString(sig)}}
+
+	entry, err := s.logEntry(payload, sig, leaf, issuedAt.Add(time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	return attestation.NewBundle(envelope, []*x509.Certificate{leaf}, entry, s.trust)
+}
+
+// logEntry records the payload and its signature in the synthetic log with a
+// signed entry timestamp
+func (s *Sigstore) logEntry(payload, sig []byte, leaf *x509.Certificate, integratedAt time.Time) (*attestation.TlogEntry, error) {
+	payloadHash := sha256.Sum256(payload)
+	verifier := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})
+	body := fmt.Sprintf(`{"apiVersion":"0.0.1","kind":"dsse","spec":{"payloadHash":{"algorithm":"sha256","value":"%s"},`+
+		`"signatures":[{"signature":"%s","verifier":"%s"}]}}`,
+		hex.EncodeToString(payloadHash[:]), base64.StdEncoding.EncodeToString(sig), base64.StdEncoding.EncodeToString(verifier))
+	entry := &attestation.TlogEntry{
+		UUID:           hex.EncodeToString(payloadHash[:]),
+		LogIndex:       s.serial,
+		LogID:          s.logID,
+		IntegratedTime: integratedAt.Unix(),
+		Body:           base64.StdEncoding.EncodeToString([]byte(body)),
+	}
+
+	canonical, err := json.Marshal(map[string]interface{}{
+		"body":           entry.Body,
+		"integratedTime": entry.IntegratedTime,
+		"logID":          entry.LogID,
+		"logIndex":       entry.LogIndex,
+	})
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(canonical)
+	set, err := ecdsa.SignASN1(rand.Reader, s.rekorKey, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	entry.SignedEntryTimestamp = base64.StdEncoding.EncodeToString(set)
+	return entry, nil
+}
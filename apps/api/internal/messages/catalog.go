@@ -0,0 +1,245 @@
+// Package messages renders policy violations and verification errors from a
+// catalog of stable message IDs. Results carry the ID and its parameters, so
+// automation keys off the ID while UIs and notifications render the text in
+// the reader's locale. English is built in; other locales are loaded from
+// YAML files and fall back to English for messages they don't translate.
+package messages
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLocale is the locale every message has text in
+const DefaultLocale = "en"
+
+// ID identifies a message independently of its wording, e.g.
+// "verification.issuer_mismatch". IDs are stable across releases.
+type ID string
+
+// Params are the named values a message's text refers to as {name}
+type Params map[string]string
+
+// Message is a machine-readable message. Text is filled in by Localize.
+type Message struct {
+	ID     ID     `json:"id"`
+	Params Params `json:"params,omitempty"`
+	Locale string `json:"locale,omitempty"`
+	Text   string `json:"text,omitempty"`
+}
+
+// New creates a message, dropping empty parameters
+func New(id ID, params Params) *Message {
+	message := &Message{ID: id}
+	for name, value := range params {
+		if value == "" {
+			continue
+		}
+		if message.Params == nil {
+			message.Params = make(Params)
+		}
+		message.Params[name] = value
+	}
+	return message
+}
+
+// Catalog holds message text by locale
+type Catalog struct {
+	locales map[string]map[ID]string
+}
+
+// Default returns a catalog with the built-in English text
+func Default() *Catalog {
+	english := make(map[ID]string, len(defaultText))
+	for id, text := range defaultText {
+		english[id] = text
+	}
+	return &Catalog{locales: map[string]map[ID]string{DefaultLocale: english}}
+}
+
+// Locales lists the catalog's locales
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.locales))
+	for locale := range c.locales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// translationFile is a locale's YAML file
+type translationFile struct {
+	Locale   string        `yaml:"locale"`
+	Messages map[ID]string `yaml:"messages"`
+}
+
+// LoadDir adds every *.yaml translation in dir to the catalog
+func (c *Catalog) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := c.LoadFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFile adds a translation to the catalog. Every message must be one the
+// catalog knows and refer to the same parameters as its English text, so a
+// translation can't silently drop the values automation relies on.
+func (c *Catalog) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var file translationFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid message catalog %s: %w", path, err)
+	}
+	locale := normalizeLocale(file.Locale)
+	if locale == "" {
+		locale = normalizeLocale(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	}
+	if locale == "" {
+		return fmt.Errorf("invalid message catalog %s: no locale", path)
+	}
+
+	english := c.locales[DefaultLocale]
+	for id, text := range file.Messages {
+		source, known := english[id]
+		if !known {
+			return fmt.Errorf("invalid message catalog %s: unknown message %q", path, id)
+		}
+		if want, got := placeholders(source), placeholders(text); strings.Join(want, ",") != strings.Join(got, ",") {
+			return fmt.Errorf("invalid message catalog %s: message %q uses parameters %v, expected %v", path, id, got, want)
+		}
+	}
+
+	if c.locales[locale] == nil {
+		c.locales[locale] = make(map[ID]string, len(file.Messages))
+	}
+	for id, text := range file.Messages {
+		c.locales[locale][id] = text
+	}
+	return nil
+}
+
+// Render returns the message's text in the locale, falling back to the
+// locale's base language and then to English. Messages the catalog doesn't
+// know render as their ID.
+func (c *Catalog) Render(locale string, message *Message) string {
+	if message == nil {
+		return ""
+	}
+	text, _ := c.lookup(locale, message.ID)
+	if text == "" {
+		return string(message.ID)
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		return message.Params[placeholder[1:len(placeholder)-1]]
+	})
+}
+
+// Localize fills in the message's text and the locale it was rendered in
+func (c *Catalog) Localize(locale string, message *Message) {
+	if message == nil {
+		return
+	}
+	_, message.Locale = c.lookup(locale, message.ID)
+	message.Text = c.Render(locale, message)
+}
+
+// lookup finds the message's text and the locale it was found in
+func (c *Catalog) lookup(locale string, id ID) (string, string) {
+	for _, candidate := range fallbacks(normalizeLocale(locale)) {
+		if text, found := c.locales[candidate][id]; found {
+			return text, candidate
+		}
+	}
+	return "", ""
+}
+
+// Negotiate picks the catalog locale best matching an Accept-Language header,
+// defaulting to English
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	type preference struct {
+		locale string
+		q      float64
+	}
+	var preferences []preference
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		locale := normalizeLocale(fields[0])
+		if locale == "" || locale == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if value, found := strings.CutPrefix(strings.TrimSpace(param), "q="); found {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			preferences = append(preferences, preference{locale, q})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].q > preferences[j].q })
+
+	for _, preference := range preferences {
+		// Every locale falls back to English, so only the preference itself and its language count
+		candidates := fallbacks(preference.locale)
+		for _, candidate := range candidates[:len(candidates)-1] {
+			if _, found := c.locales[candidate]; found {
+				return candidate
+			}
+		}
+	}
+	return DefaultLocale
+}
+
+// fallbacks lists the locales to try for a locale: itself, its base
+// language, then English
+func fallbacks(locale string) []string {
+	candidates := []string{}
+	if locale != "" {
+		candidates = append(candidates, locale)
+		if base, _, found := strings.Cut(locale, "-"); found {
+			candidates = append(candidates, base)
+		}
+	}
+	return append(candidates, DefaultLocale)
+}
+
+// normalizeLocale lowercases a BCP 47 tag and uses hyphens, e.g. pt_BR → pt-br
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+var placeholderPattern = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// placeholders returns the distinct parameter names a text refers to, sorted
+func placeholders(text string) []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, placeholder := range placeholderPattern.FindAllString(text, -1) {
+		name := placeholder[1 : len(placeholder)-1]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,205 @@
+package messages
+
+// Verification error messages, one per SIGN_ error code. Each takes the
+// code and, when known, the target being signed or verified.
+const (
+	OIDCTokenUnavailable   ID = "verification.oidc_token_unavailable"
+	OIDCURLUnavailable     ID = "verification.oidc_url_unavailable"
+	OIDCTokenRequestFailed ID = "verification.oidc_token_request_failed"
+	InvalidIssuer          ID = "verification.invalid_issuer"
+	InvalidAudience        ID = "verification.invalid_audience"
+	MissingSubject         ID = "verification.missing_subject"
+	InvalidTokenSignature  ID = "verification.invalid_token_signature"
+	TokenExpired           ID = "verification.token_expired"
+	ChecksumMismatch       ID = "verification.checksum_mismatch"
+	BlobDigestMismatch     ID = "verification.blob_digest_mismatch"
+	SubjectMismatch        ID = "verification.subject_mismatch"
+	TargetNotResolved      ID = "verification.target_not_resolved"
+	SigningFailed          ID = "verification.signing_failed"
+	RegistryPushFailed     ID = "verification.registry_push_failed"
+	KeyResidencyUnverified ID = "verification.key_residency_unverified"
+	TimestampFailed        ID = "verification.timestamp_failed"
+	PublicKeyExtraction    ID = "verification.public_key_extraction"
+	RekorEntryNotFound     ID = "verification.rekor_entry_not_found"
+	CertificateUntrusted   ID = "verification.certificate_untrusted"
+	RekorSETInvalid        ID = "verification.rekor_set_invalid"
+	TimestampInvalid       ID = "verification.timestamp_invalid"
+	TrustRootInvalid       ID = "verification.trust_root_invalid"
+	VerificationFailed     ID = "verification.failed"
+	AttestationNotFound    ID = "verification.attestation_not_found"
+	IssuerMismatch         ID = "verification.issuer_mismatch"
+	SANMismatch            ID = "verification.san_mismatch"
+	RepositoryMismatch     ID = "verification.repository_mismatch"
+	WorkflowMismatch       ID = "verification.workflow_mismatch"
+	BranchMismatch         ID = "verification.branch_mismatch"
+	ThresholdNotMet        ID = "verification.threshold_not_met"
+	NonCanonicalPayload    ID = "verification.non_canonical_payload"
+	IdentityChanged        ID = "verification.identity_changed"
+	SBOMSigningFailed      ID = "verification.sbom_signing_failed"
+	BuildLevelTooLow       ID = "verification.build_level_too_low"
+	NetworkTimeout         ID = "verification.network_timeout"
+	SigningUnavailable     ID = "verification.signing_unavailable"
+	PermissionDenied       ID = "verification.permission_denied"
+	WorkflowNotApproved    ID = "verification.workflow_not_approved"
+	WorkflowRefNotPinned   ID = "verification.workflow_ref_not_pinned"
+	RepositoryNotAllowed   ID = "verification.repository_not_allowed"
+	RefNotAllowed          ID = "verification.ref_not_allowed"
+	EnvironmentNotAllowed  ID = "verification.environment_not_allowed"
+	EventNotAllowed        ID = "verification.event_not_allowed"
+	FederationFailed       ID = "verification.federation_failed"
+)
+
+// Identity policy violations, one per policy rule. Each takes the rule, the
+// expected value and the actual value.
+const (
+	PolicyIssuer      ID = "policy.issuer"
+	PolicySAN         ID = "policy.san"
+	PolicyRepository  ID = "policy.repository"
+	PolicyWorkflowRef ID = "policy.workflow_ref"
+	PolicyBranch      ID = "policy.branch"
+)
+
+// Package verification failures, one per unverified status. Each takes the
+// ecosystem, package and version.
+const (
+	PackageUntrustedKey       ID = "package.untrusted_key"
+	PackageUnknownKey         ID = "package.unknown_key"
+	PackageInvalidSignature   ID = "package.invalid_signature"
+	PackageDigestMismatch     ID = "package.digest_mismatch"
+	PackageMissingSignature   ID = "package.missing_signature"
+	PackageMissingAttestation ID = "package.missing_attestation"
+	PackagePolicyViolation    ID = "package.policy_violation"
+	PackageFetchFailed        ID = "package.fetch_failed"
+)
+
+// codeIDs maps SIGN_ error codes to their messages
+var codeIDs = map[string]ID{
+	"SIGN_001": OIDCTokenUnavailable,
+	"SIGN_002": OIDCURLUnavailable,
+	"SIGN_003": OIDCTokenRequestFailed,
+	"SIGN_004": InvalidIssuer,
+	"SIGN_005": InvalidAudience,
+	"SIGN_006": MissingSubject,
+	"SIGN_007": InvalidTokenSignature,
+	"SIGN_008": TokenExpired,
+	"SIGN_011": ChecksumMismatch,
+	"SIGN_012": BlobDigestMismatch,
+	"SIGN_013": SubjectMismatch,
+	"SIGN_021": TargetNotResolved,
+	"SIGN_031": SigningFailed,
+	"SIGN_032": RegistryPushFailed,
+	"SIGN_033": KeyResidencyUnverified,
+	"SIGN_034": TimestampFailed,
+	"SIGN_041": PublicKeyExtraction,
+	"SIGN_042": RekorEntryNotFound,
+	"SIGN_045": CertificateUntrusted,
+	"SIGN_046": RekorSETInvalid,
+	"SIGN_047": TimestampInvalid,
+	"SIGN_048": TrustRootInvalid,
+	"SIGN_051": VerificationFailed,
+	"SIGN_052": AttestationNotFound,
+	"SIGN_053": IssuerMismatch,
+	"SIGN_054": SANMismatch,
+	"SIGN_055": RepositoryMismatch,
+	"SIGN_056": WorkflowMismatch,
+	"SIGN_057": BranchMismatch,
+	"SIGN_058": ThresholdNotMet,
+	"SIGN_059": NonCanonicalPayload,
+	"SIGN_060": IdentityChanged,
+	"SIGN_061": SBOMSigningFailed,
+	"SIGN_062": BuildLevelTooLow,
+	"SIGN_071": NetworkTimeout,
+	"SIGN_072": SigningUnavailable,
+	"SIGN_081": PermissionDenied,
+	"SIGN_082": WorkflowNotApproved,
+	"SIGN_083": WorkflowRefNotPinned,
+	"SIGN_084": RepositoryNotAllowed,
+	"SIGN_085": RefNotAllowed,
+	"SIGN_086": EnvironmentNotAllowed,
+	"SIGN_087": EventNotAllowed,
+	"SIGN_088": FederationFailed,
+}
+
+// ForCode returns the message for a SIGN_ error code, or the generic
+// verification failure for codes without one
+func ForCode(code, target string) *Message {
+	id, found := codeIDs[code]
+	if !found {
+		id = VerificationFailed
+	}
+	return New(id, Params{"code": code, "target": target})
+}
+
+// ForPolicyRule returns the violation message for an identity policy rule
+func ForPolicyRule(rule, expected, actual string) *Message {
+	return New(ID("policy."+rule), Params{"rule": rule, "expected": expected, "actual": actual})
+}
+
+// ForPackageStatus returns the message for a package that failed
+// verification with the status
+func ForPackageStatus(status, ecosystem, pkg, version string) *Message {
+	return New(ID("package."+status), Params{"ecosystem": ecosystem, "package": pkg, "version": version})
+}
+
+// defaultText is the built-in English catalog
+var defaultText = map[ID]string{
+	OIDCTokenUnavailable:   "The job has no OIDC token to sign with. Grant it the id-token: write permission.",
+	OIDCURLUnavailable:     "The job has no OIDC token service to request a token from. Grant it the id-token: write permission.",
+	OIDCTokenRequestFailed: "The OIDC provider didn't issue a token for signing.",
+	InvalidIssuer:          "The OIDC token was issued by an unexpected provider.",
+	InvalidAudience:        "The OIDC token was issued for a different audience than signing requires.",
+	MissingSubject:         "The OIDC token doesn't identify the job that requested it.",
+	InvalidTokenSignature:  "The OIDC token isn't signed by its issuer.",
+	TokenExpired:           "The OIDC token expired before signing finished.",
+	ChecksumMismatch:       "The signing tool's checksum doesn't match its published release.",
+	BlobDigestMismatch:     "The file's digest doesn't match the digest that was signed.",
+	SubjectMismatch:        "The attestation is about a different artifact than the one being verified.",
+	TargetNotResolved:      "The signing target couldn't be resolved to an immutable digest.",
+	SigningFailed:          "Signing failed.",
+	RegistryPushFailed:     "The attestation couldn't be pushed to the registry.",
+	KeyResidencyUnverified: "The signing key couldn't be shown to live in hardware.",
+	TimestampFailed:        "The timestamp authority didn't timestamp the signature.",
+	PublicKeyExtraction:    "The public key couldn't be read from the signing certificate.",
+	RekorEntryNotFound:     "The signature isn't recorded in the transparency log.",
+	CertificateUntrusted:   "The signing certificate doesn't chain to a trusted certificate authority.",
+	RekorSETInvalid:        "The transparency log's signed entry timestamp is invalid.",
+	TimestampInvalid:       "The signature's timestamp isn't from a trusted timestamp authority.",
+	TrustRootInvalid:       "The Sigstore trust root couldn't be loaded.",
+	VerificationFailed:     "Verification failed.",
+	AttestationNotFound:    "No attestation was found for the artifact.",
+	IssuerMismatch:         "The signer's OIDC issuer isn't the one the policy requires.",
+	SANMismatch:            "The signer's identity doesn't match the policy.",
+	RepositoryMismatch:     "The artifact was signed from a repository the policy doesn't allow.",
+	WorkflowMismatch:       "The artifact was signed by a workflow the policy doesn't allow.",
+	BranchMismatch:         "The artifact was signed from a branch the policy doesn't allow.",
+	ThresholdNotMet:        "Too few trusted signers signed the attestation.",
+	NonCanonicalPayload:    "The attestation's payload isn't in canonical form.",
+	IdentityChanged:        "The artifact was signed by a different identity than the one pinned for it.",
+	SBOMSigningFailed:      "The SBOM couldn't be signed.",
+	BuildLevelTooLow:       "The provenance doesn't meet the SLSA build level the policy requires.",
+	NetworkTimeout:         "A signing or verification service couldn't be reached.",
+	SigningUnavailable:     "Signing is unavailable in offline mode.",
+	PermissionDenied:       "The job isn't permitted to sign.",
+	WorkflowNotApproved:    "Signing is only allowed from approved reusable workflows.",
+	WorkflowRefNotPinned:   "The signing workflow isn't called at a pinned ref.",
+	RepositoryNotAllowed:   "Signing isn't allowed from this repository.",
+	RefNotAllowed:          "Signing isn't allowed from this branch or tag.",
+	EnvironmentNotAllowed:  "Signing is only allowed from approved deployment environments.",
+	EventNotAllowed:        "Signing isn't allowed for the event that triggered the workflow.",
+	FederationFailed:       "The cloud provider didn't exchange the job's OIDC token for credentials.",
+
+	PolicyIssuer:      "The signer's OIDC issuer is {actual}, but the policy requires {expected}.",
+	PolicySAN:         "The signer's identity {actual} doesn't match {expected}.",
+	PolicyRepository:  "The artifact was signed from {actual}, but the policy requires {expected}.",
+	PolicyWorkflowRef: "The artifact was signed by workflow {actual}, but the policy requires {expected}.",
+	PolicyBranch:      "The artifact was signed from {actual}, but the policy requires {expected}.",
+
+	PackageUntrustedKey:       "The {ecosystem} package {package}@{version} is signed with a key that isn't trusted.",
+	PackageUnknownKey:         "The {ecosystem} package {package}@{version} is signed with an unknown key.",
+	PackageInvalidSignature:   "The {ecosystem} package {package}@{version} has an invalid signature.",
+	PackageDigestMismatch:     "The {ecosystem} package {package}@{version} doesn't match the digest that was signed.",
+	PackageMissingSignature:   "The {ecosystem} package {package}@{version} isn't signed.",
+	PackageMissingAttestation: "The {ecosystem} package {package}@{version} was published without provenance.",
+	PackagePolicyViolation:    "The {ecosystem} package {package}@{version} was published by a workflow its publisher policy doesn't allow.",
+	PackageFetchFailed:        "The signature of the {ecosystem} package {package}@{version} couldn't be fetched.",
+}
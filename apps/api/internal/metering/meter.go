@@ -0,0 +1,157 @@
+package metering
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Metrics tracked per tenant
+const (
+	MetricGitHubRequests = "github_requests"
+	MetricScans          = "scans"
+	MetricVerifications  = "verifications"
+	MetricAdvisorySyncs  = "advisory_syncs"
+	MetricStorageBytes   = "storage_bytes"
+)
+
+// DefaultTenant is used when a request carries no tenant
+const DefaultTenant = "default"
+
+// bucketSize is the granularity of stored usage records
+const bucketSize = time.Hour
+
+type tenantKey struct{}
+
+// WithTenant returns a context attributing usage to tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant usage is attributed to
+func TenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+// Meter records usage into hourly per-tenant buckets
+type Meter struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewMeter creates a new usage meter
+func NewMeter(db *sql.DB) *Meter {
+	return &Meter{db: db, now: time.Now}
+}
+
+// Record adds quantity to the current bucket of a metric for the context's tenant
+func (m *Meter) Record(ctx context.Context, metric string, quantity int64) error {
+	if quantity == 0 {
+		return nil
+	}
+
+	upsertSQL := `
+		INSERT INTO usage_records (tenant_id, metric, period_start, quantity)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(tenant_id, metric, period_start) DO UPDATE SET
+			quantity = quantity + excluded.quantity,
+			updated_at = CURRENT_TIMESTAMP
+	`
+
+	period := m.now().UTC().Truncate(bucketSize)
+	if _, err := m.db.ExecContext(ctx, upsertSQL, TenantFromContext(ctx), metric, period, quantity); err != nil {
+		return fmt.Errorf("failed to record %s usage: %w", metric, err)
+	}
+	return nil
+}
+
+// Usage summarises a tenant's consumption over a time range
+type Usage struct {
+	Tenant  string           `json:"tenant"`
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	Totals  map[string]int64 `json:"totals"`
+	Buckets []Bucket         `json:"buckets"`
+}
+
+// Bucket is one hourly usage record
+type Bucket struct {
+	Metric      string    `json:"metric"`
+	PeriodStart time.Time `json:"period_start"`
+	Quantity    int64     `json:"quantity"`
+}
+
+// Usage returns the tenant's usage for buckets starting in [from, to)
+func (m *Meter) Usage(ctx context.Context, tenant string, from, to time.Time) (*Usage, error) {
+	query := `
+		SELECT metric, period_start, quantity
+		FROM usage_records
+		WHERE tenant_id = ? AND period_start >= ? AND period_start < ?
+		ORDER BY period_start, metric
+	`
+
+	from = from.UTC().Truncate(bucketSize)
+	to = to.UTC()
+	rows, err := m.db.QueryContext(ctx, query, tenant, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := &Usage{
+		Tenant:  tenant,
+		From:    from,
+		To:      to,
+		Totals:  make(map[string]int64),
+		Buckets: []Bucket{},
+	}
+	for rows.Next() {
+		var bucket Bucket
+		if err := rows.Scan(&bucket.Metric, &bucket.PeriodStart, &bucket.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan usage row: %w", err)
+		}
+		usage.Totals[bucket.Metric] += bucket.Quantity
+		usage.Buckets = append(usage.Buckets, bucket)
+	}
+
+	return usage, rows.Err()
+}
+
+// Total returns a tenant's consumption of one metric since a point in time, for quota checks
+func (m *Meter) Total(ctx context.Context, tenant, metric string, since time.Time) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM(quantity), 0)
+		FROM usage_records
+		WHERE tenant_id = ? AND metric = ? AND period_start >= ?
+	`
+
+	var total int64
+	err := m.db.QueryRowContext(ctx, query, tenant, metric, since.UTC().Truncate(bucketSize)).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to total %s usage: %w", metric, err)
+	}
+	return total, nil
+}
+
+// GitHubRequestHook returns a github.Config.OnRequest hook that meters API
+// calls. Conditional requests answered with 304 are free of GitHub's rate
+// limit, so they aren't metered.
+func (m *Meter) GitHubRequestHook() func(ctx context.Context, method, url string, statusCode int) {
+	return func(ctx context.Context, method, url string, statusCode int) {
+		if statusCode == http.StatusNotModified {
+			return
+		}
+		if err := m.Record(ctx, MetricGitHubRequests, 1); err != nil {
+			log.Printf("Failed to meter GitHub request: %v", err)
+		}
+	}
+}
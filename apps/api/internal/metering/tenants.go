@@ -0,0 +1,61 @@
+package metering
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TenantTokensEnv lists the bearer tokens that authenticate tenants, as
+// comma-separated tenant=token pairs
+const TenantTokensEnv = "KEYSTONE_TENANT_TOKENS"
+
+// TenantTokens maps each tenant to the bearer token that authenticates it
+type TenantTokens map[string]string
+
+// TenantTokensFromEnv parses KEYSTONE_TENANT_TOKENS; it is empty when unset
+func TenantTokensFromEnv() (TenantTokens, error) {
+	return ParseTenantTokens(os.Getenv(TenantTokensEnv))
+}
+
+// ParseTenantTokens parses comma-separated tenant=token pairs
+func ParseTenantTokens(value string) (TenantTokens, error) {
+	tokens := make(TenantTokens)
+	seen := make(map[string]string)
+	for i, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		tenant, token, found := strings.Cut(pair, "=")
+		tenant, token = strings.TrimSpace(tenant), strings.TrimSpace(token)
+		if !found || tenant == "" || token == "" {
+			// The entry may be a bare token, so it isn't echoed
+			return nil, fmt.Errorf("%s entry %d must be tenant=token", TenantTokensEnv, i+1)
+		}
+		if _, ok := tokens[tenant]; ok {
+			return nil, fmt.Errorf("%s lists tenant %q twice", TenantTokensEnv, tenant)
+		}
+		if other, ok := seen[token]; ok {
+			return nil, fmt.Errorf("%s gives tenants %q and %q the same token", TenantTokensEnv, other, tenant)
+		}
+		tokens[tenant] = token
+		seen[token] = tenant
+	}
+	return tokens, nil
+}
+
+// Tenant returns the tenant token authenticates, or "" when it matches none
+func (t TenantTokens) Tenant(token string) string {
+	if token == "" {
+		return ""
+	}
+	var match string
+	for tenant, candidate := range t {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			match = tenant
+		}
+	}
+	return match
+}
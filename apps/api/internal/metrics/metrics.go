@@ -0,0 +1,323 @@
+// Package metrics is a small registry of counters, gauges and histograms
+// served in the Prometheus text exposition format, so operators can scrape
+// Keystone's processes without a client library dependency
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency histogram bucket bounds in seconds, suited to
+// HTTP API calls
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Registry holds the metrics a process exposes
+type Registry struct {
+	mutex   sync.Mutex
+	metrics map[string]metric
+}
+
+// metric is a registered metric family
+type metric interface {
+	kind() string
+	help() string
+	write(w io.Writer, name string)
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// register returns the metric registered under name, registering create's
+// when there is none. Registering a name again with another kind panics.
+func (r *Registry) register(name string, create func() metric) metric {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	created := create()
+	if existing, found := r.metrics[name]; found {
+		if existing.kind() != created.kind() {
+			panic(fmt.Sprintf("metric %s is already registered as a %s", name, existing.kind()))
+		}
+		return existing
+	}
+	r.metrics[name] = created
+	return created
+}
+
+// Counter registers a counter with label names, or returns the one already
+// registered under name
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return r.register(name, func() metric {
+		return &CounterVec{family: newFamily(help, labels)}
+	}).(*CounterVec)
+}
+
+// Histogram registers a histogram with bucket upper bounds and label names,
+// or returns the one already registered under name
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return r.register(name, func() metric {
+		return &HistogramVec{family: newFamily(help, labels), buckets: buckets}
+	}).(*HistogramVec)
+}
+
+// GaugeFunc registers a gauge read from collect at each scrape, replacing
+// any collect function registered under name before. collect returns a
+// value per label set, keyed by the label values joined with "\xff", in the
+// order of labels; a gauge without labels is keyed by "".
+func (r *Registry) GaugeFunc(name, help string, collect func() map[string]float64, labels ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if existing, found := r.metrics[name]; found && existing.kind() != "gauge" {
+		panic(fmt.Sprintf("metric %s is already registered as a %s", name, existing.kind()))
+	}
+	r.metrics[name] = &gaugeFunc{family: newFamily(help, labels), collect: collect}
+}
+
+// Labels joins label values into a GaugeFunc key
+func Labels(values ...string) string {
+	return strings.Join(values, "\xff")
+}
+
+// WriteText writes every metric in the Prometheus text exposition format,
+// sorted by name
+func (r *Registry) WriteText(w io.Writer) {
+	r.mutex.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make(map[string]metric, len(r.metrics))
+	for name, m := range r.metrics {
+		metrics[name] = m
+	}
+	r.mutex.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		m := metrics[name]
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(m.help()), name, m.kind())
+		m.write(w, name)
+	}
+}
+
+// Handler serves the registry's metrics, e.g. at /metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// family holds a metric's help and label names, and its series by label
+// values
+type family struct {
+	helpText string
+	labels   []string
+	mutex    sync.Mutex
+	series   map[string]interface{}
+	order    []string // Series keys in creation order
+}
+
+func newFamily(help string, labels []string) family {
+	return family{helpText: help, labels: labels, series: make(map[string]interface{})}
+}
+
+func (f *family) help() string { return f.helpText }
+
+// get returns the series for label values, creating it with create
+func (f *family) get(values []string, create func() interface{}) interface{} {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric has labels %v, got %d values", f.labels, len(values)))
+	}
+	key := Labels(values...)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	series, found := f.series[key]
+	if !found {
+		series = create()
+		f.series[key] = series
+		f.order = append(f.order, key)
+	}
+	return series
+}
+
+// keys returns the series keys, sorted
+func (f *family) keys() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	keys := append([]string(nil), f.order...)
+	sort.Strings(keys)
+	return keys
+}
+
+// labelPairs renders label values as {name="value",...}, with extra pairs
+// appended
+func (f *family) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(f.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", f.labels[i], escapeLabel(value)))
+		}
+	}
+	pairs = append(pairs, extra...)
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	family
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	mutex sync.Mutex
+	value float64
+}
+
+// With returns the counter for label values, in the order of the labels
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.get(values, func() interface{} { return &Counter{} }).(*Counter)
+}
+
+// Inc adds one
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add adds a non-negative delta
+func (c *Counter) Add(delta float64) {
+	c.mutex.Lock()
+	c.value += delta
+	c.mutex.Unlock()
+}
+
+// Value returns the count
+func (c *Counter) Value() float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.value
+}
+
+func (c *CounterVec) kind() string { return "counter" }
+
+func (c *CounterVec) write(w io.Writer, name string) {
+	for _, key := range c.keys() {
+		c.mutex.Lock()
+		counter := c.series[key].(*Counter)
+		c.mutex.Unlock()
+		fmt.Fprintf(w, "%s%s %s\n", name, c.labelPairs(key), formatValue(counter.Value()))
+	}
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	family
+	buckets []float64
+}
+
+// Histogram counts observations into buckets
+type Histogram struct {
+	mutex  sync.Mutex
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// With returns the histogram for label values, in the order of the labels
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.get(values, func() interface{} {
+		return &Histogram{counts: make([]uint64, len(h.buckets))}
+	}).(*Histogram)
+}
+
+func (h *HistogramVec) kind() string { return "histogram" }
+
+func (h *HistogramVec) write(w io.Writer, name string) {
+	for _, key := range h.keys() {
+		h.mutex.Lock()
+		histogram := h.series[key].(*Histogram)
+		h.mutex.Unlock()
+
+		histogram.mutex.Lock()
+		cumulative := uint64(0)
+		for i, bound := range h.buckets {
+			cumulative += histogram.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, h.labelPairs(key, fmt.Sprintf("le=%q", formatValue(bound))), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, h.labelPairs(key, `le="+Inf"`), histogram.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, h.labelPairs(key), formatValue(histogram.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, h.labelPairs(key), histogram.count)
+		histogram.mutex.Unlock()
+	}
+}
+
+// observe records a value against the bucket bounds
+func (h *Histogram) observe(buckets []float64, value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.count++
+	h.sum += value
+	for i, bound := range buckets {
+		if value <= bound {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+// Observe records a value in the histogram for label values
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	h.With(values...).observe(h.buckets, value)
+}
+
+// gaugeFunc is a gauge read at each scrape
+type gaugeFunc struct {
+	family
+	collect func() map[string]float64
+}
+
+func (g *gaugeFunc) kind() string { return "gauge" }
+
+func (g *gaugeFunc) write(w io.Writer, name string) {
+	values := g.collect()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", name, g.labelPairs(key), formatValue(values[key]))
+	}
+}
+
+// formatValue renders a sample value as Prometheus expects
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escapeHelp escapes backslashes and newlines in help text
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+// escapeLabel escapes newlines in a label value; %q escapes the rest
+func escapeLabel(value string) string {
+	return strings.ReplaceAll(value, "\n", " ")
+}
@@ -0,0 +1,133 @@
+package pkgverify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// Keyring holds publisher PGP keys and the trust pins that decide which keys may sign which groups
+type Keyring struct {
+	entities openpgp.EntityList
+	pins     map[string]map[string]bool // group prefix -> trusted fingerprints
+	mutex    sync.RWMutex
+}
+
+// NewKeyring creates an empty keyring
+func NewKeyring() *Keyring {
+	return &Keyring{
+		pins: make(map[string]map[string]bool),
+	}
+}
+
+// AddArmoredKeys imports one or more ASCII-armored public keys
+func (k *Keyring) AddArmoredKeys(r io.Reader) error {
+	entities, err := openpgp.ReadArmoredKeyRing(r)
+	if err != nil {
+		return fmt.Errorf("failed to read armored key ring: %w", err)
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.entities = append(k.entities, entities...)
+	return nil
+}
+
+// LoadDir imports every *.asc key file in the given directory
+func (k *Keyring) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.asc"))
+	if err != nil {
+		return fmt.Errorf("failed to glob key files: %w", err)
+	}
+
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("failed to open key file %s: %w", file, err)
+		}
+		err = k.AddArmoredKeys(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to import key file %s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// Trust pins fingerprints as trusted signers for a group prefix.
+// The prefix "*" trusts the keys for every group.
+func (k *Keyring) Trust(groupPrefix string, fingerprints ...string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.pins[groupPrefix] == nil {
+		k.pins[groupPrefix] = make(map[string]bool)
+	}
+	for _, fp := range fingerprints {
+		k.pins[groupPrefix][normalizeFingerprint(fp)] = true
+	}
+}
+
+// IsTrusted returns true if the fingerprint is pinned for the group or one of its parent prefixes
+func (k *Keyring) IsTrusted(group, fingerprint string) bool {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	fingerprint = normalizeFingerprint(fingerprint)
+	for prefix, fingerprints := range k.pins {
+		if !fingerprints[fingerprint] {
+			continue
+		}
+		if prefix == "*" || group == prefix || strings.HasPrefix(group, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Fingerprint returns a digest of the imported keys and trust pins, which
+// changes whenever either does, e.g. to key cached verification results
+func (k *Keyring) Fingerprint() string {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	var lines []string
+	for _, entity := range k.entities {
+		lines = append(lines, "key "+strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)))
+	}
+	for prefix, fingerprints := range k.pins {
+		for fp, trusted := range fingerprints {
+			if trusted {
+				lines = append(lines, "pin "+prefix+" "+fp)
+			}
+		}
+	}
+	sort.Strings(lines)
+
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// entityList returns a snapshot of imported keys for signature checking
+func (k *Keyring) entityList() openpgp.EntityList {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	entities := make(openpgp.EntityList, len(k.entities))
+	copy(entities, k.entities)
+	return entities
+}
+
+// normalizeFingerprint uppercases and strips whitespace from a hex fingerprint
+func normalizeFingerprint(fp string) string {
+	return strings.ToUpper(strings.ReplaceAll(fp, " ", ""))
+}
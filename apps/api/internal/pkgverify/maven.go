@@ -0,0 +1,304 @@
+package pkgverify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/messages"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+// Status represents the outcome of a package signature check
+type Status string
+
+const (
+	StatusVerified         Status = "verified"
+	StatusUntrustedKey     Status = "untrusted_key"
+	StatusUnknownKey       Status = "unknown_key"
+	StatusInvalidSignature Status = "invalid_signature"
+	StatusDigestMismatch   Status = "digest_mismatch"
+	StatusMissingSignature Status = "missing_signature"
+	StatusFetchFailed      Status = "fetch_failed"
+)
+
+// ResultCache is the subset of the hierarchical cache used to memoize verification results
+type ResultCache interface {
+	Get(ctx context.Context, key string) (interface{}, bool)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+}
+
+// MavenCoordinates identifies a single artifact file in a Maven repository
+type MavenCoordinates struct {
+	GroupID    string `json:"group_id"`
+	ArtifactID string `json:"artifact_id"`
+	Version    string `json:"version"`
+	Classifier string `json:"classifier,omitempty"`
+	Extension  string `json:"extension"`
+}
+
+// MavenCoordinatesFromPURL converts a pkg:maven purl into repository coordinates
+func MavenCoordinatesFromPURL(purl *sbom.PackageURL) (MavenCoordinates, error) {
+	if purl.Type != "maven" {
+		return MavenCoordinates{}, fmt.Errorf("purl type %q is not maven", purl.Type)
+	}
+	if purl.Namespace == "" || purl.Version == "" {
+		return MavenCoordinates{}, fmt.Errorf("maven purl %s requires namespace and version", purl.String())
+	}
+
+	coords := MavenCoordinates{
+		GroupID:    purl.Namespace,
+		ArtifactID: purl.Name,
+		Version:    purl.Version,
+		Classifier: purl.Qualifiers["classifier"],
+		Extension:  purl.Qualifiers["type"],
+	}
+	if coords.Extension == "" {
+		coords.Extension = "jar"
+	}
+	return coords, nil
+}
+
+// Path returns the repository-relative path of the artifact file
+func (c MavenCoordinates) Path() string {
+	file := c.ArtifactID + "-" + c.Version
+	if c.Classifier != "" {
+		file += "-" + c.Classifier
+	}
+	file += "." + c.Extension
+
+	return strings.Join([]string{
+		strings.ReplaceAll(c.GroupID, ".", "/"),
+		c.ArtifactID,
+		c.Version,
+		file,
+	}, "/")
+}
+
+// String returns the Gradle-style group:artifact:version[:classifier]@extension notation
+func (c MavenCoordinates) String() string {
+	s := c.GroupID + ":" + c.ArtifactID + ":" + c.Version
+	if c.Classifier != "" {
+		s += ":" + c.Classifier
+	}
+	return s + "@" + c.Extension
+}
+
+// MavenResult records the signature verification outcome for one artifact
+type MavenResult struct {
+	Coordinates    MavenCoordinates `json:"coordinates"`
+	PURL           string           `json:"purl"`
+	Status         Status           `json:"status"`
+	KeyFingerprint string           `json:"key_fingerprint,omitempty"`
+	Error          string           `json:"error,omitempty"`
+	CheckedAt      time.Time        `json:"checked_at"`
+}
+
+// Verified returns true if the artifact carries a valid signature from a trusted key
+func (r MavenResult) Verified() bool {
+	return r.Status == StatusVerified
+}
+
+// MavenConfig holds Maven signature verifier configuration
+type MavenConfig struct {
+	RepositoryURL        string
+	CacheTTL             time.Duration // How long successful and definitive results are reused
+	MaxArtifactSize      int64         // Upper bound on artifact bytes downloaded for verification
+	CircuitBreakerConfig circuit.Config
+	Transport            http.RoundTripper // Optional, e.g. to report call outcomes to the offline detector
+}
+
+// DefaultMavenConfig returns a configuration targeting Maven Central
+func DefaultMavenConfig() MavenConfig {
+	return MavenConfig{
+		RepositoryURL:   "https://repo1.maven.org/maven2",
+		CacheTTL:        24 * time.Hour,
+		MaxArtifactSize: 256 << 20,
+		CircuitBreakerConfig: circuit.Config{
+			FailureThreshold:   5,
+			RecoveryTimeout:    5 * time.Minute,
+			SuccessThreshold:   3,
+			RequestTimeout:     60 * time.Second,
+			MaxConcurrentCalls: 10,
+		},
+	}
+}
+
+// MavenVerifier fetches and verifies PGP signatures for Maven artifacts referenced in SBOMs
+type MavenVerifier struct {
+	config   MavenConfig
+	keyring  *Keyring
+	cache    ResultCache
+	registry *registryClient
+}
+
+// NewMavenVerifier creates a verifier; cache may be nil to disable result caching
+func NewMavenVerifier(config MavenConfig, keyring *Keyring, cache ResultCache) *MavenVerifier {
+	return &MavenVerifier{
+		config:   config,
+		keyring:  keyring,
+		cache:    cache,
+		registry: newRegistryClient(config.CircuitBreakerConfig, config.Transport),
+	}
+}
+
+// VerifyComponent verifies the signature of a single SBOM component with a maven purl
+func (v *MavenVerifier) VerifyComponent(ctx context.Context, component sbom.Component) (MavenResult, error) {
+	purl, err := component.PackageURL()
+	if err != nil {
+		return MavenResult{}, err
+	}
+	coords, err := MavenCoordinatesFromPURL(purl)
+	if err != nil {
+		return MavenResult{}, err
+	}
+
+	// A result only holds for the SBOM digest and keyring it was checked with
+	cacheKey := "maven-sig:" + coords.String() + ":" + component.Hashes["sha256"] + ":" + v.keyring.Fingerprint()
+	if v.cache != nil {
+		if cached, found := v.cache.Get(ctx, cacheKey); found {
+			var result MavenResult
+			if decodeCached(cached, &result) == nil {
+				return result, nil
+			}
+		}
+	}
+
+	result := v.verify(ctx, coords, component)
+	result.PURL = component.PURL
+
+	// Transient fetch failures are not cached so the next run retries
+	if v.cache != nil && result.Status != StatusFetchFailed {
+		v.cache.Set(ctx, cacheKey, result, v.config.CacheTTL)
+	}
+
+	return result, nil
+}
+
+// verify downloads the artifact and its detached signature and checks both
+func (v *MavenVerifier) verify(ctx context.Context, coords MavenCoordinates, component sbom.Component) MavenResult {
+	result := MavenResult{
+		Coordinates: coords,
+		CheckedAt:   time.Now(),
+	}
+
+	artifactURL := strings.TrimRight(v.config.RepositoryURL, "/") + "/" + coords.Path()
+
+	signature, err := v.registry.fetch(ctx, artifactURL+".asc", "", 64<<10)
+	if errors.Is(err, errNotFound) {
+		result.Status = StatusMissingSignature
+		result.Error = "no .asc signature published for artifact"
+		return result
+	}
+	if err != nil {
+		result.Status = StatusFetchFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	artifact, err := v.registry.fetch(ctx, artifactURL, "", v.config.MaxArtifactSize)
+	if err != nil {
+		result.Status = StatusFetchFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	if expected, ok := component.Hashes["sha256"]; ok {
+		sum := sha256.Sum256(artifact)
+		if actual := hex.EncodeToString(sum[:]); actual != expected {
+			result.Status = StatusDigestMismatch
+			result.Error = fmt.Sprintf("artifact sha256 %s does not match SBOM digest %s", actual, expected)
+			return result
+		}
+	}
+
+	signer, err := openpgp.CheckArmoredDetachedSignature(
+		v.keyring.entityList(), bytes.NewReader(artifact), bytes.NewReader(signature), nil)
+	switch {
+	case errors.Is(err, pgperrors.ErrUnknownIssuer):
+		result.Status = StatusUnknownKey
+		result.Error = "signature was made by a key that is not in the keyring"
+		return result
+	case err != nil:
+		result.Status = StatusInvalidSignature
+		result.Error = err.Error()
+		return result
+	}
+
+	result.KeyFingerprint = strings.ToUpper(hex.EncodeToString(signer.PrimaryKey.Fingerprint))
+	if !v.keyring.IsTrusted(coords.GroupID, result.KeyFingerprint) {
+		result.Status = StatusUntrustedKey
+		result.Error = fmt.Sprintf("key %s is not trusted for group %s", result.KeyFingerprint, coords.GroupID)
+		return result
+	}
+
+	result.Status = StatusVerified
+	return result
+}
+
+// VerifyDocument verifies every maven component in the SBOM and returns findings for those that fail
+func (v *MavenVerifier) VerifyDocument(ctx context.Context, doc *sbom.Document) ([]MavenResult, []findings.Finding) {
+	var results []MavenResult
+	var found []findings.Finding
+
+	for _, component := range doc.ComponentsByType("maven") {
+		result, err := v.VerifyComponent(ctx, component)
+		if err != nil {
+			continue // Malformed purls are reported by SBOM validation
+		}
+		results = append(results, result)
+
+		if !result.Verified() {
+			found = append(found, MavenFinding(result))
+		}
+	}
+
+	return results, found
+}
+
+// MavenFinding converts an unverifiable result into a signature finding
+func MavenFinding(result MavenResult) findings.Finding {
+	severity := findings.SeverityMedium
+	switch result.Status {
+	case StatusInvalidSignature, StatusDigestMismatch:
+		severity = findings.SeverityHigh
+	case StatusFetchFailed:
+		severity = findings.SeverityLow
+	}
+
+	finding := findings.New("maven-pgp", findings.CategorySignature, severity,
+		string(result.Status), result.Coordinates.String(),
+		fmt.Sprintf("Maven artifact %s could not be verified (%s)", result.Coordinates.String(), result.Status))
+	finding.Description = result.Error
+	finding.Message = messages.ForPackageStatus(string(result.Status), "maven",
+		result.Coordinates.GroupID+":"+result.Coordinates.ArtifactID, result.Coordinates.Version)
+	finding.Component = result.Coordinates.GroupID + ":" + result.Coordinates.ArtifactID
+	finding.Version = result.Coordinates.Version
+	finding.PURL = result.PURL
+	if result.KeyFingerprint != "" {
+		finding.Metadata["key_fingerprint"] = result.KeyFingerprint
+	}
+
+	return finding
+}
+
+// decodeCached converts a cached value (struct from L1 or generic JSON from L2/L3) into target
+func decodeCached(value interface{}, target interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
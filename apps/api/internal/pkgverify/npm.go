@@ -0,0 +1,201 @@
+package pkgverify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+// DefaultNpmConfig returns a configuration targeting the public npm registry
+func DefaultNpmConfig() ProvenanceConfig {
+	return defaultProvenanceConfig("https://registry.npmjs.org")
+}
+
+// npmVersion is the part of a registry version document describing its tarball
+type npmVersion struct {
+	Dist struct {
+		Integrity    string `json:"integrity"` // sha512-<base64>
+		Attestations *struct {
+			URL string `json:"url"`
+		} `json:"attestations"`
+	} `json:"dist"`
+}
+
+// npmAttestations is the registry's attestations response for a package version
+type npmAttestations struct {
+	Attestations []struct {
+		PredicateType string          `json:"predicateType"`
+		Bundle        json.RawMessage `json:"bundle"`
+	} `json:"attestations"`
+}
+
+// NpmVerifier verifies the Sigstore provenance the npm registry publishes for
+// packages built with `npm publish --provenance`
+type NpmVerifier struct {
+	config   ProvenanceConfig
+	trust    TrustSource
+	cache    ResultCache
+	registry *registryClient
+}
+
+// NewNpmVerifier creates a verifier; cache may be nil to disable result caching
+func NewNpmVerifier(config ProvenanceConfig, trust TrustSource, cache ResultCache) *NpmVerifier {
+	return &NpmVerifier{
+		config:   config,
+		trust:    trust,
+		cache:    cache,
+		registry: newRegistryClient(config.CircuitBreakerConfig, config.Transport),
+	}
+}
+
+// npmPackageName returns the registry name of a pkg:npm purl, including its scope
+func npmPackageName(purl *sbom.PackageURL) string {
+	if purl.Namespace != "" {
+		return purl.Namespace + "/" + purl.Name
+	}
+	return purl.Name
+}
+
+// VerifyComponent verifies the provenance of a single SBOM component with an npm purl
+func (v *NpmVerifier) VerifyComponent(ctx context.Context, component sbom.Component) (ProvenanceResult, error) {
+	purl, err := component.PackageURL()
+	if err != nil {
+		return ProvenanceResult{}, err
+	}
+	if purl.Type != "npm" {
+		return ProvenanceResult{}, fmt.Errorf("purl type %q is not npm", purl.Type)
+	}
+	if purl.Version == "" {
+		return ProvenanceResult{}, fmt.Errorf("npm purl %s requires a version", purl.String())
+	}
+
+	name := npmPackageName(purl)
+	cacheKey := "npm-provenance:" + name + "@" + purl.Version
+	if v.cache != nil {
+		if cached, found := v.cache.Get(ctx, cacheKey); found {
+			var result ProvenanceResult
+			if decodeCached(cached, &result) == nil {
+				return result, nil
+			}
+		}
+	}
+
+	result := v.verify(ctx, name, purl.Version, component)
+	result.PURL = component.PURL
+
+	// Transient fetch failures are not cached so the next run retries
+	if v.cache != nil && result.Status != StatusFetchFailed {
+		v.cache.Set(ctx, cacheKey, result, v.config.CacheTTL)
+	}
+
+	return result, nil
+}
+
+// verify fetches the version's tarball digest and provenance bundle and checks
+// the bundle attests that tarball
+func (v *NpmVerifier) verify(ctx context.Context, name, version string, component sbom.Component) ProvenanceResult {
+	result := ProvenanceResult{
+		Ecosystem: EcosystemNpm,
+		Package:   name,
+		Version:   version,
+		CheckedAt: time.Now(),
+	}
+
+	registryURL := strings.TrimRight(v.config.RegistryURL, "/")
+	escaped := strings.Replace(name, "/", "%2f", 1)
+
+	var metadata npmVersion
+	data, err := v.registry.fetch(ctx, registryURL+"/"+escaped+"/"+version, "application/json", v.config.MaxResponseSize)
+	if errors.Is(err, errNotFound) {
+		result.fail(StatusMissingAttestation, "package version is not published in the registry")
+		return result
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &metadata)
+	}
+	if err != nil {
+		result.fail(StatusFetchFailed, "%v", err)
+		return result
+	}
+
+	digest, err := integrityHex(metadata.Dist.Integrity)
+	if err != nil {
+		result.fail(StatusFetchFailed, "%v", err)
+		return result
+	}
+	if expected, ok := component.Hashes["sha512"]; ok && expected != digest {
+		result.fail(StatusDigestMismatch, "registry tarball sha512 %s does not match SBOM digest %s", digest, expected)
+		return result
+	}
+	if metadata.Dist.Attestations == nil {
+		result.fail(StatusMissingAttestation, "package version was published without provenance")
+		return result
+	}
+
+	attestationsURL := metadata.Dist.Attestations.URL
+	if attestationsURL == "" {
+		attestationsURL = registryURL + "/-/npm/v1/attestations/" + escaped + "@" + version
+	}
+	var published npmAttestations
+	data, err = v.registry.fetch(ctx, attestationsURL, "application/json", v.config.MaxResponseSize)
+	if errors.Is(err, errNotFound) {
+		result.fail(StatusMissingAttestation, "registry has no attestations for the package version")
+		return result
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &published)
+	}
+	if err != nil {
+		result.fail(StatusFetchFailed, "%v", err)
+		return result
+	}
+
+	// The registry also publishes a publish attestation signed with its own
+	// key; only the Sigstore-signed SLSA provenance identifies the builder
+	for _, candidate := range published.Attestations {
+		if candidate.PredicateType != attestation.PredicateSLSAProvenanceV1 {
+			continue
+		}
+		bundle, err := attestation.ParseSigstoreBundle(candidate.Bundle)
+		if err != nil {
+			result.fail(StatusInvalidSignature, "%v", err)
+			return result
+		}
+		verifySigstoreBundle(ctx, v.trust, bundle, v.config.Policies.For(name), func(subject attestation.Subject) bool {
+			purl, err := sbom.ParsePackageURL(subject.Name)
+			return err == nil && purl.Type == "npm" && npmPackageName(purl) == name &&
+				purl.Version == version && strings.EqualFold(subject.Digest["sha512"], digest)
+		}, &result)
+		return result
+	}
+
+	result.fail(StatusMissingAttestation, "registry has no SLSA provenance for the package version")
+	return result
+}
+
+// integrityHex converts an npm sha512 subresource integrity string to hex
+func integrityHex(integrity string) (string, error) {
+	encoded, found := strings.CutPrefix(integrity, "sha512-")
+	if !found {
+		return "", fmt.Errorf("registry integrity %q is not a sha512 digest", integrity)
+	}
+	digest, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("registry integrity %q is not valid base64: %w", integrity, err)
+	}
+	return hex.EncodeToString(digest), nil
+}
+
+// VerifyDocument verifies every npm component in the SBOM and returns findings for those that fail
+func (v *NpmVerifier) VerifyDocument(ctx context.Context, doc *sbom.Document) ([]ProvenanceResult, []findings.Finding) {
+	return verifyComponents(ctx, doc.ComponentsByType("npm"), v.VerifyComponent)
+}
@@ -0,0 +1,228 @@
+package pkgverify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/messages"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+// Statuses specific to registry provenance
+const (
+	StatusMissingAttestation Status = "missing_attestation"
+	StatusPolicyViolation    Status = "policy_violation"
+)
+
+// Package ecosystems with registry provenance
+const (
+	EcosystemNpm  = "npm"
+	EcosystemPyPI = "pypi"
+)
+
+// TrustSource provides the Sigstore trust root provenance is verified against,
+// such as trustroot.Manager
+type TrustSource interface {
+	TrustRoot(ctx context.Context) (attestation.TrustRoot, error)
+}
+
+// PublisherPolicies maps package names to the identity their provenance must
+// be signed by. A key ending in "*" matches names with that prefix, e.g.
+// "@my-org/*"; the key "*" applies to every package.
+type PublisherPolicies map[string]attestation.IdentityPolicy
+
+// LoadPublisherPolicies reads publisher policies from YAML, keyed by package name
+func LoadPublisherPolicies(path string) (PublisherPolicies, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read publisher policies: %w", err)
+	}
+
+	var policies PublisherPolicies
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policies); err != nil {
+		return nil, fmt.Errorf("invalid publisher policies %s: %w", path, err)
+	}
+	return policies, nil
+}
+
+// For returns the policy of the exact package name, else of its longest
+// matching prefix. Packages without one only need valid Sigstore provenance.
+func (p PublisherPolicies) For(name string) attestation.IdentityPolicy {
+	if policy, ok := p[name]; ok {
+		return policy
+	}
+
+	var matched string
+	policy := attestation.IdentityPolicy{}
+	for key, candidate := range p {
+		prefix, isPrefix := strings.CutSuffix(key, "*")
+		if isPrefix && strings.HasPrefix(name, prefix) && len(key) > len(matched) {
+			matched, policy = key, candidate
+		}
+	}
+	return policy
+}
+
+// ProvenanceConfig holds registry provenance verifier configuration
+type ProvenanceConfig struct {
+	RegistryURL          string
+	Policies             PublisherPolicies
+	CacheTTL             time.Duration // How long successful and definitive results are reused
+	MaxResponseSize      int64         // Upper bound on metadata and attestation bytes downloaded
+	CircuitBreakerConfig circuit.Config
+	Transport            http.RoundTripper // Optional, e.g. to report call outcomes to the offline detector
+}
+
+func defaultProvenanceConfig(registryURL string) ProvenanceConfig {
+	return ProvenanceConfig{
+		RegistryURL:     registryURL,
+		CacheTTL:        24 * time.Hour,
+		MaxResponseSize: 16 << 20,
+		CircuitBreakerConfig: circuit.Config{
+			FailureThreshold:   5,
+			RecoveryTimeout:    5 * time.Minute,
+			SuccessThreshold:   3,
+			RequestTimeout:     30 * time.Second,
+			MaxConcurrentCalls: 10,
+		},
+	}
+}
+
+// ProvenanceResult records the provenance verification outcome for one package
+type ProvenanceResult struct {
+	Ecosystem   string    `json:"ecosystem"`
+	Package     string    `json:"package"`
+	Version     string    `json:"version"`
+	PURL        string    `json:"purl"`
+	Status      Status    `json:"status"`
+	Repository  string    `json:"repository,omitempty"`   // Source repository recorded in the signing certificate
+	WorkflowRef string    `json:"workflow_ref,omitempty"` // Workflow that published the package
+	Files       []string  `json:"files,omitempty"`        // Distribution files checked, for PyPI
+	Error       string    `json:"error,omitempty"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// Verified returns true if the package carries valid provenance satisfying its publisher policy
+func (r ProvenanceResult) Verified() bool {
+	return r.Status == StatusVerified
+}
+
+// String returns ecosystem:package@version
+func (r ProvenanceResult) String() string {
+	return r.Ecosystem + ":" + r.Package + "@" + r.Version
+}
+
+// fail sets a failed status and its explanation
+func (r *ProvenanceResult) fail(status Status, format string, args ...interface{}) {
+	r.Status = status
+	r.Error = fmt.Sprintf(format, args...)
+}
+
+// verifySigstoreBundle verifies a Sigstore bundle against the trust root and
+// policy and checks its statement names the expected subject and digest.
+// It returns the status to report and records the signer on the result.
+func verifySigstoreBundle(ctx context.Context, trust TrustSource, sigstoreBundle *attestation.SigstoreBundle,
+	policy attestation.IdentityPolicy, subject func(attestation.Subject) bool, result *ProvenanceResult) Status {
+	root, err := trust.TrustRoot(ctx)
+	if err != nil {
+		result.fail(StatusFetchFailed, "failed to load Sigstore trust root: %v", err)
+		return result.Status
+	}
+
+	bundle, err := sigstoreBundle.Bundle(root)
+	if err != nil {
+		result.fail(StatusInvalidSignature, "%v", err)
+		return result.Status
+	}
+
+	verification, err := attestation.VerifyBundle(bundle, root, policy)
+	if verification.Certificate != nil {
+		result.Repository = verification.Certificate.Repository
+		result.WorkflowRef = verification.Certificate.WorkflowRef
+	}
+	if err != nil {
+		status := StatusInvalidSignature
+		switch attestation.CodeOf(err) {
+		case attestation.CodeIssuerMismatch, attestation.CodeSANMismatch, attestation.CodeRepositoryMismatch,
+			attestation.CodeWorkflowMismatch, attestation.CodeBranchMismatch, attestation.CodeBuildLevelTooLow:
+			status = StatusPolicyViolation
+		}
+		result.fail(status, "%v", err)
+		return result.Status
+	}
+
+	statement, err := bundle.Envelope.Statement()
+	if err != nil {
+		result.fail(StatusInvalidSignature, "%v", err)
+		return result.Status
+	}
+	for _, s := range statement.Subject {
+		if subject(s) {
+			result.Status, result.Error = StatusVerified, ""
+			return result.Status
+		}
+	}
+	result.fail(StatusDigestMismatch, "provenance does not attest the published %s", result.String())
+	return result.Status
+}
+
+// verifyComponents verifies each component, skipping those with malformed purls
+func verifyComponents(ctx context.Context, components []sbom.Component,
+	verify func(context.Context, sbom.Component) (ProvenanceResult, error)) ([]ProvenanceResult, []findings.Finding) {
+	var results []ProvenanceResult
+	var found []findings.Finding
+
+	for _, component := range components {
+		result, err := verify(ctx, component)
+		if err != nil {
+			continue // Malformed purls are reported by SBOM validation
+		}
+		results = append(results, result)
+
+		if !result.Verified() {
+			found = append(found, ProvenanceFinding(result))
+		}
+	}
+
+	return results, found
+}
+
+// ProvenanceFinding converts an unverifiable result into a provenance finding
+func ProvenanceFinding(result ProvenanceResult) findings.Finding {
+	severity := findings.SeverityMedium
+	switch result.Status {
+	case StatusInvalidSignature, StatusDigestMismatch, StatusPolicyViolation:
+		severity = findings.SeverityHigh
+	case StatusFetchFailed:
+		severity = findings.SeverityLow
+	}
+
+	finding := findings.New(result.Ecosystem+"-provenance", findings.CategorySignature, severity,
+		string(result.Status), result.String(),
+		fmt.Sprintf("%s package %s@%s has no verifiable provenance (%s)", result.Ecosystem, result.Package, result.Version, result.Status))
+	finding.Description = result.Error
+	finding.Message = messages.ForPackageStatus(string(result.Status), result.Ecosystem, result.Package, result.Version)
+	finding.Component = result.Package
+	finding.Version = result.Version
+	finding.PURL = result.PURL
+	if result.Repository != "" {
+		finding.Metadata["repository"] = result.Repository
+	}
+	if result.WorkflowRef != "" {
+		finding.Metadata["workflow_ref"] = result.WorkflowRef
+	}
+
+	return finding
+}
@@ -0,0 +1,248 @@
+package pkgverify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+// pypiIntegrityMediaType is requested from PyPI's integrity API
+const pypiIntegrityMediaType = "application/vnd.pypi.integrity.v1+json"
+
+// DefaultPyPIConfig returns a configuration targeting pypi.org
+func DefaultPyPIConfig() ProvenanceConfig {
+	return defaultProvenanceConfig("https://pypi.org")
+}
+
+// pypiRelease is the part of PyPI's JSON API release document listing its files
+type pypiRelease struct {
+	URLs []pypiFile `json:"urls"`
+}
+
+// pypiFile is one distribution file of a release
+type pypiFile struct {
+	Filename string `json:"filename"`
+	Digests  struct {
+		SHA256 string `json:"sha256"`
+	} `json:"digests"`
+}
+
+// pypiProvenance is a PEP 740 provenance object for one distribution file
+type pypiProvenance struct {
+	AttestationBundles []struct {
+		Attestations []pypiAttestation `json:"attestations"`
+	} `json:"attestation_bundles"`
+}
+
+// pypiAttestation is a PEP 740 attestation: a DSSE envelope flattened to its
+// statement and single signature, with Sigstore verification material
+type pypiAttestation struct {
+	VerificationMaterial struct {
+		Certificate         string                          `json:"certificate"` // Base64 DER
+		TransparencyEntries []attestation.SigstoreTlogEntry `json:"transparency_entries"`
+	} `json:"verification_material"`
+	Envelope struct {
+		Statement string `json:"statement"` // Base64 in-toto statement
+		Signature string `json:"signature"` // Base64
+	} `json:"envelope"`
+}
+
+// sigstoreBundle reassembles the attestation as a Sigstore bundle
+func (a pypiAttestation) sigstoreBundle() *attestation.SigstoreBundle {
+	return &attestation.SigstoreBundle{
+		MediaType: attestation.SigstoreBundleMediaType + "+json;version=0.3",
+		VerificationMaterial: attestation.SigstoreVerificationMaterial{
+			Certificate: &attestation.SigstoreCertificate{RawBytes: a.VerificationMaterial.Certificate},
+			TlogEntries: a.VerificationMaterial.TransparencyEntries,
+		},
+		DSSEEnvelope: &attestation.Envelope{
+			PayloadType: attestation.PayloadTypeInToto,
+			Payload:     a.Envelope.Statement,
+			Signatures:  []attestation.EnvelopeSignature{{Sig: a.Envelope.Signature}},
+		},
+	}
+}
+
+// PyPIVerifier verifies the PEP 740 attestations PyPI publishes for
+// distributions uploaded by Trusted Publishers
+type PyPIVerifier struct {
+	config   ProvenanceConfig
+	trust    TrustSource
+	cache    ResultCache
+	registry *registryClient
+}
+
+// NewPyPIVerifier creates a verifier; cache may be nil to disable result caching
+func NewPyPIVerifier(config ProvenanceConfig, trust TrustSource, cache ResultCache) *PyPIVerifier {
+	return &PyPIVerifier{
+		config:   config,
+		trust:    trust,
+		cache:    cache,
+		registry: newRegistryClient(config.CircuitBreakerConfig, config.Transport),
+	}
+}
+
+var pypiNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// NormalizePyPIName returns the PEP 503 normalized form of a project name
+func NormalizePyPIName(name string) string {
+	return strings.ToLower(pypiNameSeparators.ReplaceAllString(name, "-"))
+}
+
+// VerifyComponent verifies the provenance of a single SBOM component with a
+// pypi purl. A file_name qualifier or SBOM sha256 selects the distribution
+// file; otherwise every file of the release must be attested.
+func (v *PyPIVerifier) VerifyComponent(ctx context.Context, component sbom.Component) (ProvenanceResult, error) {
+	purl, err := component.PackageURL()
+	if err != nil {
+		return ProvenanceResult{}, err
+	}
+	if purl.Type != "pypi" {
+		return ProvenanceResult{}, fmt.Errorf("purl type %q is not pypi", purl.Type)
+	}
+	if purl.Version == "" {
+		return ProvenanceResult{}, fmt.Errorf("pypi purl %s requires a version", purl.String())
+	}
+
+	name := NormalizePyPIName(purl.Name)
+	cacheKey := "pypi-provenance:" + name + "@" + purl.Version + ":" + purl.Qualifiers["file_name"] + ":" + component.Hashes["sha256"]
+	if v.cache != nil {
+		if cached, found := v.cache.Get(ctx, cacheKey); found {
+			var result ProvenanceResult
+			if decodeCached(cached, &result) == nil {
+				return result, nil
+			}
+		}
+	}
+
+	result := v.verify(ctx, name, purl.Version, purl.Qualifiers["file_name"], component)
+	result.PURL = component.PURL
+
+	// Transient fetch failures are not cached so the next run retries
+	if v.cache != nil && result.Status != StatusFetchFailed {
+		v.cache.Set(ctx, cacheKey, result, v.config.CacheTTL)
+	}
+
+	return result, nil
+}
+
+// verify lists the release's files and checks the provenance of those selected
+func (v *PyPIVerifier) verify(ctx context.Context, name, version, fileName string, component sbom.Component) ProvenanceResult {
+	result := ProvenanceResult{
+		Ecosystem: EcosystemPyPI,
+		Package:   name,
+		Version:   version,
+		CheckedAt: time.Now(),
+	}
+
+	indexURL := strings.TrimRight(v.config.RegistryURL, "/")
+	var release pypiRelease
+	data, err := v.registry.fetch(ctx, indexURL+"/pypi/"+url.PathEscape(name)+"/"+url.PathEscape(version)+"/json",
+		"application/json", v.config.MaxResponseSize)
+	if errors.Is(err, errNotFound) {
+		result.fail(StatusMissingAttestation, "release is not published on the index")
+		return result
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &release)
+	}
+	if err != nil {
+		result.fail(StatusFetchFailed, "%v", err)
+		return result
+	}
+
+	files := release.URLs
+	expected, hasDigest := component.Hashes["sha256"]
+	switch {
+	case fileName != "":
+		files = filterFiles(files, func(file pypiFile) bool { return file.Filename == fileName })
+		if len(files) == 0 {
+			result.fail(StatusMissingAttestation, "release has no distribution file %s", fileName)
+			return result
+		}
+		if hasDigest && !strings.EqualFold(files[0].Digests.SHA256, expected) {
+			result.fail(StatusDigestMismatch, "%s sha256 %s does not match SBOM digest %s", fileName, files[0].Digests.SHA256, expected)
+			return result
+		}
+	case hasDigest:
+		files = filterFiles(files, func(file pypiFile) bool { return strings.EqualFold(file.Digests.SHA256, expected) })
+		if len(files) == 0 {
+			result.fail(StatusDigestMismatch, "no distribution file of the release matches SBOM digest %s", expected)
+			return result
+		}
+	case len(files) == 0:
+		result.fail(StatusMissingAttestation, "release has no distribution files")
+		return result
+	}
+
+	for _, file := range files {
+		fileResult := result
+		v.verifyFile(ctx, indexURL, file, &fileResult)
+		result.Files = append(result.Files, file.Filename)
+		result.Repository, result.WorkflowRef = fileResult.Repository, fileResult.WorkflowRef
+		if !fileResult.Verified() {
+			result.fail(fileResult.Status, "%s: %s", file.Filename, fileResult.Error)
+			return result
+		}
+	}
+
+	result.Status = StatusVerified
+	return result
+}
+
+// verifyFile checks that one of the file's attestations is valid and attests its digest
+func (v *PyPIVerifier) verifyFile(ctx context.Context, indexURL string, file pypiFile, result *ProvenanceResult) {
+	var provenance pypiProvenance
+	data, err := v.registry.fetch(ctx, fmt.Sprintf("%s/integrity/%s/%s/%s/provenance", indexURL,
+		url.PathEscape(result.Package), url.PathEscape(result.Version), url.PathEscape(file.Filename)),
+		pypiIntegrityMediaType, v.config.MaxResponseSize)
+	if errors.Is(err, errNotFound) {
+		result.fail(StatusMissingAttestation, "file was uploaded without attestations")
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &provenance)
+	}
+	if err != nil {
+		result.fail(StatusFetchFailed, "%v", err)
+		return
+	}
+
+	policy := v.config.Policies.For(result.Package)
+	result.fail(StatusMissingAttestation, "provenance has no attestations")
+	for _, bundle := range provenance.AttestationBundles {
+		for _, candidate := range bundle.Attestations {
+			status := verifySigstoreBundle(ctx, v.trust, candidate.sigstoreBundle(), policy, func(subject attestation.Subject) bool {
+				return subject.Name == file.Filename && strings.EqualFold(subject.Digest["sha256"], file.Digests.SHA256)
+			}, result)
+			if status == StatusVerified || status == StatusFetchFailed {
+				return
+			}
+		}
+	}
+}
+
+// filterFiles returns the files matching the predicate
+func filterFiles(files []pypiFile, match func(pypiFile) bool) []pypiFile {
+	var matched []pypiFile
+	for _, file := range files {
+		if match(file) {
+			matched = append(matched, file)
+		}
+	}
+	return matched
+}
+
+// VerifyDocument verifies every pypi component in the SBOM and returns findings for those that fail
+func (v *PyPIVerifier) VerifyDocument(ctx context.Context, doc *sbom.Document) ([]ProvenanceResult, []findings.Finding) {
+	return verifyComponents(ctx, doc.ComponentsByType("pypi"), v.VerifyComponent)
+}
@@ -0,0 +1,74 @@
+package pkgverify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+)
+
+// errNotFound marks a 404 from the repository
+var errNotFound = errors.New("not found")
+
+// registryClient downloads files from a package registry through a circuit breaker
+type registryClient struct {
+	httpClient     *http.Client
+	circuitBreaker *circuit.Breaker
+}
+
+func newRegistryClient(config circuit.Config, transport http.RoundTripper) *registryClient {
+	return &registryClient{
+		httpClient:     &http.Client{Timeout: config.RequestTimeout, Transport: transport},
+		circuitBreaker: circuit.New(config),
+	}
+}
+
+// fetch downloads a registry file, requesting the given media type when set
+func (c *registryClient) fetch(ctx context.Context, url, accept string, limit int64) ([]byte, error) {
+	var data []byte
+
+	err := c.circuitBreaker.Call(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			data = nil
+			return nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("repository returned status %d for %s", resp.StatusCode, url)
+		}
+
+		data, err = io.ReadAll(io.LimitReader(resp.Body, limit+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) > limit {
+			return fmt.Errorf("%s exceeds maximum size of %d bytes", url, limit)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A 404 is reported outside the breaker so missing files don't trip it
+	if data == nil {
+		return nil, errNotFound
+	}
+	return data, nil
+}
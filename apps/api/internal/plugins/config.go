@@ -0,0 +1,88 @@
+package plugins
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+)
+
+// PluginConfig describes one plugin executable
+type PluginConfig struct {
+	Name         string            `yaml:"name"`
+	Command      string            `yaml:"command"`
+	Args         []string          `yaml:"args,omitempty"`
+	Env          map[string]string `yaml:"env,omitempty"`           // The only variables passed besides PATH; the worker's own environment is withheld
+	Timeout      time.Duration     `yaml:"timeout,omitempty"`       // Per call; the plugin is killed and restarted when exceeded
+	StartTimeout time.Duration     `yaml:"start_timeout,omitempty"` // For the handshake
+	FailClosed   bool              `yaml:"fail_closed,omitempty"`   // A failing gate blocks instead of allowing
+}
+
+// Config lists the plugins to run
+type Config struct {
+	Plugins []PluginConfig `yaml:"plugins"`
+
+	// Breaker guards each plugin; consecutive failures stop calls to it until
+	// it recovers
+	Breaker circuit.Config `yaml:"-"`
+}
+
+// DefaultBreakerConfig opens a plugin's circuit after 5 consecutive failures
+func DefaultBreakerConfig() circuit.Config {
+	return circuit.Config{
+		FailureThreshold:   5,
+		RecoveryTimeout:    time.Minute,
+		SuccessThreshold:   1,
+		RequestTimeout:     30 * time.Second,
+		MaxConcurrentCalls: 1,
+	}
+}
+
+// LoadConfig reads a plugin configuration file
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read plugin configuration: %w", err)
+	}
+
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("invalid plugin configuration %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid plugin configuration %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Validate checks every plugin has a unique name and a command
+func (c Config) Validate() error {
+	seen := make(map[string]bool, len(c.Plugins))
+	for i, plugin := range c.Plugins {
+		if plugin.Name == "" || plugin.Command == "" {
+			return fmt.Errorf("plugin %d needs a name and a command", i)
+		}
+		if seen[plugin.Name] {
+			return fmt.Errorf("duplicate plugin %q", plugin.Name)
+		}
+		seen[plugin.Name] = true
+	}
+	return nil
+}
+
+// withDefaults fills in unset timeouts
+func (p PluginConfig) withDefaults() PluginConfig {
+	if p.Timeout <= 0 {
+		p.Timeout = 30 * time.Second
+	}
+	if p.StartTimeout <= 0 {
+		p.StartTimeout = 10 * time.Second
+	}
+	return p
+}
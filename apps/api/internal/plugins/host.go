@@ -0,0 +1,352 @@
+// Package plugins runs third-party extensions as subprocesses speaking the
+// pkg/plugin protocol: finding enrichers, predicate generators and policy
+// gates, added without forking keystone.
+//
+// Plugins are sandboxed from the worker. Each runs in its own process group
+// and an empty working directory, with only PATH and its configured variables
+// in its environment, so it can't read the worker's credentials. Every call
+// has a timeout; a plugin that exceeds it is killed, and one that exits is
+// restarted on its next call. Each plugin sits behind its own circuit
+// breaker, so a crashing or hanging plugin costs a few failed calls rather
+// than every verification. A failing enricher leaves findings as they were,
+// and a failing gate allows unless it is configured to fail closed.
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/pipeline"
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/pkg/plugin"
+)
+
+// shutdownGrace is how long a plugin has to exit after being asked to
+const shutdownGrace = 5 * time.Second
+
+// Host runs the configured plugins
+type Host struct {
+	plugins []*managed
+}
+
+// managed is one plugin and its lifecycle
+type managed struct {
+	config  PluginConfig
+	breaker *circuit.Breaker
+
+	mutex     sync.Mutex
+	proc      *process
+	handshake plugin.Handshake
+	restarts  int
+	closed    bool
+}
+
+// New creates a host for the plugins; Start launches them
+func New(config Config) (*Host, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	breakerConfig := config.Breaker
+	if breakerConfig.FailureThreshold == 0 {
+		breakerConfig = DefaultBreakerConfig()
+	}
+
+	host := &Host{}
+	for _, pluginConfig := range config.Plugins {
+		pluginConfig = pluginConfig.withDefaults()
+		perPlugin := breakerConfig
+		// The breaker's own timeout only backs up the plugin's
+		if perPlugin.RequestTimeout < pluginConfig.Timeout+time.Second {
+			perPlugin.RequestTimeout = pluginConfig.Timeout + time.Second
+		}
+		host.plugins = append(host.plugins, &managed{config: pluginConfig, breaker: circuit.New(perPlugin)})
+	}
+	return host, nil
+}
+
+// Start launches every plugin and reads its capabilities. A plugin that
+// doesn't start is a configuration error, so Start fails rather than
+// running without it.
+func (h *Host) Start() error {
+	for _, m := range h.plugins {
+		m.mutex.Lock()
+		_, err := m.running()
+		m.mutex.Unlock()
+		if err != nil {
+			h.Close()
+			return err
+		}
+		for _, capability := range m.handshake.Capabilities {
+			log.Printf("Plugin %s %s provides %s %s", m.config.Name, m.handshake.Version, capability.Kind, capability.Name)
+		}
+	}
+	return nil
+}
+
+// Close shuts every plugin down
+func (h *Host) Close() {
+	var wg sync.WaitGroup
+	for _, m := range h.plugins {
+		m.mutex.Lock()
+		proc := m.proc
+		m.proc, m.closed = nil, true
+		m.mutex.Unlock()
+		if proc != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				proc.stop(shutdownGrace)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// running returns the plugin's process, starting it if it isn't running.
+// Callers hold the mutex.
+func (m *managed) running() (*process, error) {
+	if m.closed {
+		return nil, fmt.Errorf("plugin %s is shut down", m.config.Name)
+	}
+	if m.proc != nil && m.proc.alive() {
+		return m.proc, nil
+	}
+	if m.proc != nil {
+		m.restarts++
+		log.Printf("Restarting plugin %s (restart %d)", m.config.Name, m.restarts)
+	}
+	proc, err := startProcess(m.config)
+	if err != nil {
+		m.proc = nil
+		return nil, err
+	}
+	m.proc, m.handshake = proc, proc.handshake
+	return proc, nil
+}
+
+// capabilities lists the plugin's capabilities of a kind
+func (m *managed) capabilities(kind plugin.Kind) []plugin.Capability {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var matched []plugin.Capability
+	for _, capability := range m.handshake.Capabilities {
+		if capability.Kind == kind {
+			matched = append(matched, capability)
+		}
+	}
+	return matched
+}
+
+// call invokes a capability through the plugin's circuit breaker. A call
+// that times out kills the plugin, which is restarted on the next call.
+func (m *managed) call(ctx context.Context, method, capability string, params, result interface{}) error {
+	err := m.breaker.Call(ctx, func() error {
+		m.mutex.Lock()
+		proc, err := m.running()
+		m.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, m.config.Timeout)
+		defer cancel()
+		err = proc.call(callCtx, method, capability, params, result)
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			log.Printf("Plugin %s didn't answer %s %s within %s; killing it", m.config.Name, method, capability, m.config.Timeout)
+			proc.kill()
+			return fmt.Errorf("timed out after %s", m.config.Timeout)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("plugin %s %s %s: %w", m.config.Name, method, capability, err)
+	}
+	return nil
+}
+
+// qualified names a capability across plugins, e.g. "licenses/spdx-allowlist"
+func (m *managed) qualified(capability plugin.Capability) string {
+	return m.config.Name + "/" + capability.Name
+}
+
+// Failure records a plugin call that failed and was skipped
+type Failure struct {
+	Plugin     string `json:"plugin"`
+	Capability string `json:"capability"`
+	Error      string `json:"error"`
+}
+
+// Enrich passes the findings through every enricher in configuration order.
+// Enrichers that fail are skipped and reported; the findings they would have
+// enriched carry on unchanged.
+func (h *Host) Enrich(ctx context.Context, found []findings.Finding) ([]findings.Finding, []Failure) {
+	var failures []Failure
+	for _, m := range h.plugins {
+		for _, capability := range m.capabilities(plugin.KindEnricher) {
+			var params plugin.EnrichRequest
+			if err := convert(found, &params.Findings); err != nil {
+				failures = append(failures, Failure{m.config.Name, capability.Name, err.Error()})
+				continue
+			}
+			var resp plugin.EnrichResponse
+			err := m.call(ctx, plugin.MethodEnrich, capability.Name, params, &resp)
+			var enriched []findings.Finding
+			if err == nil {
+				err = convert(resp.Findings, &enriched)
+			}
+			if err != nil {
+				log.Printf("Skipping enricher %s: %v", m.qualified(capability), err)
+				failures = append(failures, Failure{m.config.Name, capability.Name, err.Error()})
+				continue
+			}
+			found = enriched
+		}
+	}
+	return found, failures
+}
+
+// GateInput is the verification result gates judge
+type GateInput struct {
+	Scope      string
+	Components int
+	Verified   int
+	Findings   []findings.Finding
+}
+
+// GateResult is one gate's verdict. Error is set when the gate failed, in
+// which case Allow reflects whether the plugin fails open or closed.
+type GateResult struct {
+	Plugin string `json:"plugin"`
+	Gate   string `json:"gate"`
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Evaluate asks every gate for a verdict
+func (h *Host) Evaluate(ctx context.Context, input GateInput) []GateResult {
+	params := plugin.EvaluateRequest{Scope: input.Scope, Components: input.Components, Verified: input.Verified}
+	convertErr := convert(input.Findings, &params.Findings)
+
+	var results []GateResult
+	for _, m := range h.plugins {
+		for _, capability := range m.capabilities(plugin.KindGate) {
+			result := GateResult{Plugin: m.config.Name, Gate: capability.Name}
+			var decision plugin.Decision
+			err := convertErr
+			if err == nil {
+				err = m.call(ctx, plugin.MethodEvaluate, capability.Name, params, &decision)
+			}
+			if err != nil {
+				log.Printf("Gate %s failed: %v", m.qualified(capability), err)
+				result.Allow = !m.config.FailClosed
+				result.Error = err.Error()
+			} else {
+				result.Allow, result.Reason = decision.Allow, decision.Reason
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// Allowed reports whether every gate allowed
+func Allowed(results []GateResult) bool {
+	for _, result := range results {
+		if !result.Allow {
+			return false
+		}
+	}
+	return true
+}
+
+// PredicateSteps returns an attestation pipeline step for every predicate
+// generator, named plugin/capability. Unlike enrichers and gates, a failing
+// generator fails the run: an attestation can't be partly generated.
+func (h *Host) PredicateSteps() []pipeline.Step {
+	var steps []pipeline.Step
+	for _, m := range h.plugins {
+		for _, capability := range m.capabilities(plugin.KindPredicate) {
+			m, capability := m, capability
+			steps = append(steps, pipeline.Predicate(m.qualified(capability), capability.PredicateType,
+				func(ctx context.Context, in pipeline.Input) (interface{}, error) {
+					params, err := generateRequest(in)
+					if err != nil {
+						return nil, err
+					}
+					var resp plugin.GenerateResponse
+					if err := m.call(ctx, plugin.MethodGenerate, capability.Name, params, &resp); err != nil {
+						return nil, attestation.Wrap(attestation.CodeSigningFailed, err, "Plugin predicate %s failed", m.qualified(capability))
+					}
+					return resp.Predicate, nil
+				}))
+		}
+	}
+	return steps
+}
+
+// generateRequest converts a pipeline step's input
+func generateRequest(in pipeline.Input) (plugin.GenerateRequest, error) {
+	params := plugin.GenerateRequest{Subject: plugin.Subject{Name: in.Subject.Name, Digest: in.Subject.Digest}}
+	var err error
+	if params.Build, err = json.Marshal(in.Build); err != nil {
+		return params, err
+	}
+	if len(in.Statements) > 0 {
+		params.Statements = make(map[string]json.RawMessage, len(in.Statements))
+		for name, statement := range in.Statements {
+			if params.Statements[name], err = json.Marshal(statement); err != nil {
+				return params, err
+			}
+		}
+	}
+	return params, nil
+}
+
+// Status describes a plugin for diagnostics
+type Status struct {
+	Name         string              `json:"name"`
+	Version      string              `json:"version,omitempty"`
+	Running      bool                `json:"running"`
+	Restarts     int                 `json:"restarts"`
+	Capabilities []plugin.Capability `json:"capabilities"`
+	CircuitOpen  bool                `json:"circuit_open"` // Calls are refused until the plugin recovers
+	Failures     int                 `json:"failures"`     // Consecutive failed calls
+}
+
+// Status reports every plugin's state
+func (h *Host) Status() []Status {
+	statuses := make([]Status, 0, len(h.plugins))
+	for _, m := range h.plugins {
+		stats := m.breaker.Stats()
+		m.mutex.Lock()
+		statuses = append(statuses, Status{
+			Name:         m.config.Name,
+			Version:      m.handshake.Version,
+			Running:      m.proc != nil && m.proc.alive(),
+			Restarts:     m.restarts,
+			Capabilities: m.handshake.Capabilities,
+			CircuitOpen:  stats.State == circuit.StateOpen,
+			Failures:     stats.FailureCount,
+		})
+		m.mutex.Unlock()
+	}
+	return statuses
+}
+
+// convert copies between keystone's findings and the protocol's, which share
+// a JSON encoding
+func convert(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
@@ -0,0 +1,247 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/plugin"
+)
+
+// errExited is returned for calls to a plugin whose process has exited
+var errExited = errors.New("plugin process exited")
+
+// process is a running plugin
+type process struct {
+	name      string
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	dir       string
+	handshake plugin.Handshake
+
+	writeMutex sync.Mutex
+	mutex      sync.Mutex
+	pending    map[uint64]chan plugin.Response
+	nextID     uint64
+	exited     chan struct{}
+	killed     atomic.Bool
+}
+
+// startProcess launches the plugin in an empty working directory with a
+// minimal environment and waits for its handshake
+func startProcess(config PluginConfig) (*process, error) {
+	dir, err := os.MkdirTemp("", "keystone-plugin-")
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(config.Command, config.Args...)
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + dir,
+		"TMPDIR=" + dir,
+		plugin.MagicCookieKey + "=" + plugin.MagicCookieValue,
+	}
+	for key, value := range config.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Stderr = &logWriter{prefix: fmt.Sprintf("plugin %s: ", config.Name)}
+	isolate(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to start plugin %s: %w", config.Name, err)
+	}
+
+	p := &process{
+		name:    config.Name,
+		cmd:     cmd,
+		stdin:   stdin,
+		dir:     dir,
+		pending: make(map[uint64]chan plugin.Response),
+		exited:  make(chan struct{}),
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), plugin.MaxMessageSize)
+	handshake := make(chan error, 1)
+	go func() {
+		switch {
+		case !scanner.Scan():
+			handshake <- fmt.Errorf("plugin exited before its handshake: %v", scanner.Err())
+		case json.Unmarshal(scanner.Bytes(), &p.handshake) != nil:
+			handshake <- fmt.Errorf("invalid handshake %q", scanner.Bytes())
+		default:
+			handshake <- nil
+		}
+		p.read(scanner)
+	}()
+
+	select {
+	case err = <-handshake:
+	case <-time.After(config.StartTimeout):
+		err = fmt.Errorf("no handshake within %s", config.StartTimeout)
+	}
+	if err == nil && p.handshake.Protocol != plugin.ProtocolVersion {
+		err = fmt.Errorf("plugin speaks protocol %d, expected %d", p.handshake.Protocol, plugin.ProtocolVersion)
+	}
+	if err != nil {
+		p.kill()
+		return nil, fmt.Errorf("plugin %s: %w", config.Name, err)
+	}
+	return p, nil
+}
+
+// read delivers responses until the plugin closes stdout, then fails the
+// calls still waiting and reaps the process
+func (p *process) read(scanner *bufio.Scanner) {
+	for scanner.Scan() {
+		var resp plugin.Response
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			log.Printf("plugin %s: ignoring invalid response: %v", p.name, err)
+			continue
+		}
+		p.mutex.Lock()
+		waiter, found := p.pending[resp.ID]
+		delete(p.pending, resp.ID)
+		p.mutex.Unlock()
+		if found {
+			waiter <- resp
+		}
+	}
+
+	p.cmd.Wait()
+	os.RemoveAll(p.dir)
+	close(p.exited)
+}
+
+// alive reports whether the process is still running and hasn't been killed
+func (p *process) alive() bool {
+	if p.killed.Load() {
+		return false
+	}
+	select {
+	case <-p.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// call sends a request and decodes the result into result
+func (p *process) call(ctx context.Context, method, capability string, params, result interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	waiter := make(chan plugin.Response, 1)
+	p.mutex.Lock()
+	p.nextID++
+	req := plugin.Request{ID: p.nextID, Method: method, Capability: capability, Params: data}
+	p.pending[req.ID] = waiter
+	p.mutex.Unlock()
+	defer func() {
+		p.mutex.Lock()
+		delete(p.pending, req.ID)
+		p.mutex.Unlock()
+	}()
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	p.writeMutex.Lock()
+	_, err = p.stdin.Write(append(line, '\n'))
+	p.writeMutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errExited, err)
+	}
+
+	select {
+	case resp := <-waiter:
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-p.exited:
+		return errExited
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop asks the plugin to shut down and kills it after the grace period
+func (p *process) stop(grace time.Duration) {
+	if !p.alive() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	p.call(ctx, plugin.MethodShutdown, "", nil, nil)
+	p.stdin.Close()
+	select {
+	case <-p.exited:
+	case <-ctx.Done():
+		p.kill()
+	}
+}
+
+// kill terminates the plugin and everything it started
+func (p *process) kill() {
+	p.killed.Store(true)
+	killTree(p.cmd)
+	p.stdin.Close()
+}
+
+// logWriter logs a plugin's stderr line by line
+type logWriter struct {
+	prefix string
+	buffer []byte
+	mutex  sync.Mutex
+}
+
+// Write logs every complete line
+func (w *logWriter) Write(data []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.buffer = append(w.buffer, data...)
+	for {
+		i := bytes.IndexByte(w.buffer, '\n')
+		if i < 0 {
+			break
+		}
+		log.Printf("%s%s", w.prefix, w.buffer[:i])
+		w.buffer = w.buffer[i+1:]
+	}
+	// Don't let a plugin that never ends a line grow the buffer without bound
+	if len(w.buffer) > 4096 {
+		log.Printf("%s%s", w.prefix, w.buffer)
+		w.buffer = nil
+	}
+	return len(data), nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package plugins
+
+import "os/exec"
+
+// isolate is a no-op where process groups aren't available
+func isolate(cmd *exec.Cmd) {}
+
+// killTree kills the plugin process
+func killTree(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,221 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/metering"
+)
+
+// Enforcer applies per-tenant quotas using metered usage and stored overrides
+type Enforcer struct {
+	db       *sql.DB
+	meter    *metering.Meter
+	defaults Limits
+	now      func() time.Time
+
+	mutex   sync.Mutex
+	windows map[string]*requestWindow
+}
+
+// requestWindow counts a tenant's API requests in the current minute
+type requestWindow struct {
+	start time.Time
+	count int64
+}
+
+// NewEnforcer creates a quota enforcer applying defaults to tenants without an override
+func NewEnforcer(db *sql.DB, meter *metering.Meter, defaults Limits) *Enforcer {
+	return &Enforcer{
+		db:       db,
+		meter:    meter,
+		defaults: defaults,
+		now:      time.Now,
+		windows:  make(map[string]*requestWindow),
+	}
+}
+
+// Limits returns the limits in effect for a tenant
+func (e *Enforcer) Limits(ctx context.Context, tenant string) (Limits, error) {
+	query := `
+		SELECT scans_per_day, verifications_per_day, tracked_artifacts, api_requests_per_minute
+		FROM quota_overrides
+		WHERE tenant_id = ?
+	`
+
+	var limits Limits
+	err := e.db.QueryRowContext(ctx, query, tenant).Scan(
+		&limits.ScansPerDay,
+		&limits.VerificationsPerDay,
+		&limits.TrackedArtifacts,
+		&limits.APIRequestsPerMinute,
+	)
+	if err == sql.ErrNoRows {
+		return e.defaults, nil
+	}
+	if err != nil {
+		return Limits{}, fmt.Errorf("failed to load quota override for %s: %w", tenant, err)
+	}
+	return limits, nil
+}
+
+// SetOverride replaces the default limits for a tenant
+func (e *Enforcer) SetOverride(ctx context.Context, tenant string, limits Limits) error {
+	upsertSQL := `
+		INSERT INTO quota_overrides (tenant_id, scans_per_day, verifications_per_day, tracked_artifacts, api_requests_per_minute)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id) DO UPDATE SET
+			scans_per_day = excluded.scans_per_day,
+			verifications_per_day = excluded.verifications_per_day,
+			tracked_artifacts = excluded.tracked_artifacts,
+			api_requests_per_minute = excluded.api_requests_per_minute,
+			updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := e.db.ExecContext(ctx, upsertSQL, tenant,
+		limits.ScansPerDay, limits.VerificationsPerDay, limits.TrackedArtifacts, limits.APIRequestsPerMinute)
+	if err != nil {
+		return fmt.Errorf("failed to store quota override for %s: %w", tenant, err)
+	}
+	return nil
+}
+
+// DeleteOverride restores the default limits for a tenant
+func (e *Enforcer) DeleteOverride(ctx context.Context, tenant string) error {
+	if _, err := e.db.ExecContext(ctx, "DELETE FROM quota_overrides WHERE tenant_id = ?", tenant); err != nil {
+		return fmt.Errorf("failed to delete quota override for %s: %w", tenant, err)
+	}
+	return nil
+}
+
+// AllowRequest counts an API request against the tenant's per-minute limit.
+// Windows are kept in memory, so each API replica enforces the limit independently.
+func (e *Enforcer) AllowRequest(ctx context.Context) error {
+	if Overridden(ctx) {
+		return nil
+	}
+
+	tenant := metering.TenantFromContext(ctx)
+	limits, err := e.Limits(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	if limits.APIRequestsPerMinute == 0 {
+		return nil
+	}
+
+	now := e.now()
+	start := now.Truncate(time.Minute)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	window, ok := e.windows[tenant]
+	if !ok || !window.start.Equal(start) {
+		window = &requestWindow{start: start}
+		e.windows[tenant] = window
+	}
+	if window.count >= limits.APIRequestsPerMinute {
+		return &ExceededError{
+			Tenant:     tenant,
+			Quota:      QuotaAPIRequestsPerMinute,
+			Limit:      limits.APIRequestsPerMinute,
+			Used:       window.count,
+			RetryAfter: start.Add(time.Minute).Sub(now),
+		}
+	}
+	window.count++
+	return nil
+}
+
+// Admit checks a unit of metered work against the tenant's daily limit for
+// that metric; metrics without a quota are always admitted
+func (e *Enforcer) Admit(ctx context.Context, metric string) error {
+	if Overridden(ctx) {
+		return nil
+	}
+
+	tenant := metering.TenantFromContext(ctx)
+	limits, err := e.Limits(ctx, tenant)
+	if err != nil {
+		return err
+	}
+
+	var quota string
+	var limit int64
+	switch metric {
+	case metering.MetricScans:
+		quota, limit = QuotaScansPerDay, limits.ScansPerDay
+	case metering.MetricVerifications:
+		quota, limit = QuotaVerificationsPerDay, limits.VerificationsPerDay
+	}
+	if limit == 0 {
+		return nil
+	}
+
+	// Usage is bucketed hourly, so the oldest bucket leaves the window at the next hour
+	now := e.now()
+	used, err := e.meter.Total(ctx, tenant, metric, now.Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	if used >= limit {
+		return &ExceededError{
+			Tenant:     tenant,
+			Quota:      quota,
+			Limit:      limit,
+			Used:       used,
+			RetryAfter: now.Truncate(time.Hour).Add(time.Hour).Sub(now),
+		}
+	}
+	return nil
+}
+
+// TrackArtifact records an artifact as tracked by the tenant, refusing new
+// artifacts once the tenant is at its limit
+func (e *Enforcer) TrackArtifact(ctx context.Context, artifact string) error {
+	tenant := metering.TenantFromContext(ctx)
+
+	result, err := e.db.ExecContext(ctx,
+		"UPDATE tracked_artifacts SET last_seen_at = CURRENT_TIMESTAMP WHERE tenant_id = ? AND artifact = ?",
+		tenant, artifact)
+	if err != nil {
+		return fmt.Errorf("failed to update tracked artifact: %w", err)
+	}
+	if updated, _ := result.RowsAffected(); updated > 0 {
+		return nil
+	}
+
+	if !Overridden(ctx) {
+		limits, err := e.Limits(ctx, tenant)
+		if err != nil {
+			return err
+		}
+		if limits.TrackedArtifacts > 0 {
+			var tracked int64
+			err := e.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tracked_artifacts WHERE tenant_id = ?", tenant).Scan(&tracked)
+			if err != nil {
+				return fmt.Errorf("failed to count tracked artifacts: %w", err)
+			}
+			if tracked >= limits.TrackedArtifacts {
+				return &ExceededError{
+					Tenant: tenant,
+					Quota:  QuotaTrackedArtifacts,
+					Limit:  limits.TrackedArtifacts,
+					Used:   tracked,
+				}
+			}
+		}
+	}
+
+	_, err = e.db.ExecContext(ctx,
+		"INSERT INTO tracked_artifacts (tenant_id, artifact) VALUES (?, ?) ON CONFLICT(tenant_id, artifact) DO NOTHING",
+		tenant, artifact)
+	if err != nil {
+		return fmt.Errorf("failed to track artifact: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Quota names reported in errors and responses
+const (
+	QuotaScansPerDay          = "scans_per_day"
+	QuotaVerificationsPerDay  = "verifications_per_day"
+	QuotaTrackedArtifacts     = "tracked_artifacts"
+	QuotaAPIRequestsPerMinute = "api_requests_per_minute"
+)
+
+// Limits are the quotas applied to a tenant; zero means unlimited
+type Limits struct {
+	ScansPerDay          int64 `json:"scans_per_day"`
+	VerificationsPerDay  int64 `json:"verifications_per_day"`
+	TrackedArtifacts     int64 `json:"tracked_artifacts"`
+	APIRequestsPerMinute int64 `json:"api_requests_per_minute"`
+}
+
+// DefaultLimits returns the limits applied to tenants without an override
+func DefaultLimits() Limits {
+	return Limits{
+		ScansPerDay:          500,
+		VerificationsPerDay:  500,
+		TrackedArtifacts:     1000,
+		APIRequestsPerMinute: 600,
+	}
+}
+
+// LimitsFromEnv overlays QUOTA_SCANS_PER_DAY, QUOTA_VERIFICATIONS_PER_DAY,
+// QUOTA_TRACKED_ARTIFACTS and QUOTA_API_REQUESTS_PER_MINUTE on the defaults
+func LimitsFromEnv() (Limits, error) {
+	limits := DefaultLimits()
+	for env, target := range map[string]*int64{
+		"QUOTA_SCANS_PER_DAY":           &limits.ScansPerDay,
+		"QUOTA_VERIFICATIONS_PER_DAY":   &limits.VerificationsPerDay,
+		"QUOTA_TRACKED_ARTIFACTS":       &limits.TrackedArtifacts,
+		"QUOTA_API_REQUESTS_PER_MINUTE": &limits.APIRequestsPerMinute,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			return Limits{}, fmt.Errorf("%s must be a non-negative integer, got %q", env, value)
+		}
+		*target = parsed
+	}
+	return limits, nil
+}
+
+// ExceededError reports a tenant over one of its quotas
+type ExceededError struct {
+	Tenant     string        `json:"tenant"`
+	Quota      string        `json:"quota"`
+	Limit      int64         `json:"limit"`
+	Used       int64         `json:"used"`
+	RetryAfter time.Duration `json:"-"` // Zero for quotas that do not replenish over time
+}
+
+// Error describes the exceeded quota
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("tenant %q exceeded quota %s (%d/%d)", e.Tenant, e.Quota, e.Used, e.Limit)
+}
+
+// Temporary reports whether the quota replenishes and the request may be retried later
+func (e *ExceededError) Temporary() bool {
+	return e.RetryAfter > 0
+}
+
+type overrideKey struct{}
+
+// WithOverride returns a context exempt from quota enforcement, for administrators
+func WithOverride(ctx context.Context) context.Context {
+	return context.WithValue(ctx, overrideKey{}, true)
+}
+
+// Overridden reports whether quotas are bypassed for ctx
+func Overridden(ctx context.Context) bool {
+	overridden, _ := ctx.Value(overrideKey{}).(bool)
+	return overridden
+}
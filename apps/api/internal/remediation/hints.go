@@ -0,0 +1,365 @@
+package remediation
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+)
+
+// DocsBaseURL is the root of the published user documentation
+const DocsBaseURL = "https://github.com/salman-frs/keystone/blob/main/user-docs"
+
+// Kind classifies a remediation hint
+type Kind string
+
+const (
+	KindCommand       Kind = "command"       // Run a command to produce the missing evidence
+	KindUpgrade       Kind = "upgrade"       // Upgrade a dependency to a fixed version
+	KindConfiguration Kind = "configuration" // Change workflow or platform configuration
+	KindDocumentation Kind = "documentation" // Read the linked documentation
+)
+
+// Hint is a single structured remediation suggestion
+type Hint struct {
+	Kind        Kind   `json:"kind"`
+	Summary     string `json:"summary"`
+	Command     string `json:"command,omitempty"`
+	DocURL      string `json:"doc_url,omitempty"`
+	Package     string `json:"package,omitempty"`
+	FromVersion string `json:"from_version,omitempty"`
+	ToVersion   string `json:"to_version,omitempty"`
+}
+
+// Context carries the details used to render exact commands
+type Context struct {
+	Target        string // Image reference or artifact the failure relates to
+	Identity      string // Expected certificate identity
+	Issuer        string // Expected OIDC issuer
+	PredicateType string // Attestation predicate type that was required
+	TUFMirror     string // Sigstore TUF repository of the configured environment
+}
+
+// defaultTUFMirror is used when no TUF mirror is known
+const defaultTUFMirror = "https://tuf-repo-cdn.sigstore.dev"
+
+// defaultIssuer is used when no expected issuer is known
+const defaultIssuer = "https://token.actions.githubusercontent.com"
+
+// ForErrorCode returns remediation hints for a SIGN_ error code
+func ForErrorCode(code string, c Context) []Hint {
+	target := c.Target
+	if target == "" {
+		target = "<image>"
+	}
+	issuer := c.Issuer
+	if issuer == "" {
+		issuer = defaultIssuer
+	}
+	mirror := c.TUFMirror
+	if mirror == "" {
+		mirror = defaultTUFMirror
+	}
+
+	switch code {
+	case "SIGN_001", "SIGN_002", "SIGN_081":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: "Grant the workflow permission to request an OIDC token",
+			Command: "permissions:\n  id-token: write\n  contents: read",
+			DocURL:  DocsBaseURL + "/external-services.md#sigstore-integration",
+		}}
+
+	case "SIGN_033":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: "Run signing on a self-hosted runner whose key helper returns attestation certificates chaining to the configured TPM or Secure Enclave root",
+			DocURL:  DocsBaseURL + "/external-services.md#sigstore-integration",
+		}}
+
+	case "SIGN_082", "SIGN_083":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: "Sign by calling an approved reusable workflow at one of its pinned refs",
+			Command: "jobs:\n  sign:\n    uses: <org>/<repo>/.github/workflows/<workflow>.yml@<pinned-ref>",
+			DocURL:  DocsBaseURL + "/external-services.md#sigstore-integration",
+		}}
+
+	case "SIGN_084", "SIGN_085", "SIGN_086", "SIGN_087":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: "Sign from a repository, ref, environment and event the signing constraints allow, or extend the constraints",
+			DocURL:  DocsBaseURL + "/external-services.md#signing-constraints",
+		}}
+
+	case "SIGN_088":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: "Trust the repository's OIDC tokens in the AWS role or GCP workload identity provider, matching its sub claim",
+			DocURL:  DocsBaseURL + "/external-services.md#cloud-credentials",
+		}}
+
+	case "SIGN_072":
+		return []Hint{{
+			Kind:    KindDocumentation,
+			Summary: "Wait for the OIDC issuer and Sigstore to come back online, or defer signing to a later job",
+			DocURL:  DocsBaseURL + "/external-services.md#signing-availability",
+		}}
+
+	case "SIGN_003", "SIGN_071":
+		return []Hint{{
+			Kind:    KindDocumentation,
+			Summary: "Check connectivity to the OIDC provider and Sigstore services, then retry",
+			DocURL:  DocsBaseURL + "/troubleshooting.md",
+		}}
+
+	case "SIGN_004", "SIGN_005", "SIGN_006", "SIGN_007", "SIGN_008":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: fmt.Sprintf("Request a fresh OIDC token from %s with audience \"sigstore\"", issuer),
+			DocURL:  DocsBaseURL + "/external-services.md#sigstore-integration",
+		}}
+
+	case "SIGN_011":
+		return []Hint{{
+			Kind:    KindCommand,
+			Summary: "Reinstall cosign from the official installer to restore a verified binary",
+			Command: "uses: sigstore/cosign-installer@v3",
+		}}
+
+	case "SIGN_012":
+		return []Hint{{
+			Kind:    KindCommand,
+			Summary: "Verify the artifact against the bundle published alongside it; a changed digest means the download is corrupt or was replaced",
+			Command: fmt.Sprintf("sha256sum %s", target),
+		}}
+
+	case "SIGN_013":
+		return []Hint{{
+			Kind:    KindCommand,
+			Summary: "Upload the attestation under the digest it was generated for; resolve the artifact's current digest and re-attest if it was rebuilt",
+			Command: fmt.Sprintf("crane digest %s", target),
+		}}
+
+	case "SIGN_021":
+		return []Hint{{
+			Kind:    KindCommand,
+			Summary: "Resolve the target to an immutable digest before signing",
+			Command: fmt.Sprintf("crane digest %s", target),
+		}}
+
+	case "SIGN_032":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: "Grant the workflow packages: write and authenticate to the registry before pushing attestations",
+			Command: fmt.Sprintf("crane auth login %s -u \"$GITHUB_ACTOR\" -p \"$GITHUB_TOKEN\"", registryHost(target)),
+		}}
+
+	case "SIGN_034", "SIGN_047":
+		return []Hint{{
+			Kind:    KindCommand,
+			Summary: "Re-sign with a reachable timestamp authority whose certificate chain is in the trust root",
+			Command: fmt.Sprintf("cosign sign --yes --timestamp-server-url https://freetsa.org/tsr %s", target),
+		}}
+
+	case "SIGN_048":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: "Refresh the Sigstore TUF root, or pin the trusted_root.json of your private Sigstore deployment with SIGSTORE_TRUSTED_ROOT",
+			Command: "cosign initialize --mirror " + mirror + " --root root.json",
+			DocURL:  DocsBaseURL + "/external-services.md#sigstore-integration",
+		}}
+
+	case "SIGN_031", "SIGN_041", "SIGN_042", "SIGN_045", "SIGN_046":
+		return []Hint{{
+			Kind:    KindCommand,
+			Summary: "Re-sign the artifact so a certificate and transparency log entry are recorded",
+			Command: fmt.Sprintf("cosign sign --yes %s", target),
+		}}
+
+	case "SIGN_051":
+		return []Hint{{
+			Kind:    KindCommand,
+			Summary: "Reproduce the verification locally to inspect the certificate identity",
+			Command: verifyCommand(target, c.Identity, issuer),
+			DocURL:  DocsBaseURL + "/external-services.md#sigstore-integration",
+		}}
+
+	case "SIGN_052":
+		return []Hint{attestCommand(target, c.PredicateType)}
+
+	case "SIGN_053", "SIGN_054", "SIGN_055", "SIGN_056", "SIGN_057":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: "Sign from the workflow, repository and branch the identity policy expects, or update the policy if the signer moved",
+			Command: verifyCommand(target, c.Identity, issuer),
+			DocURL:  DocsBaseURL + "/external-services.md#sigstore-integration",
+		}}
+
+	case "SIGN_058":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: "Have the missing signers sign the same statement and merge their envelopes before verifying, or lower the policy threshold if fewer approvals are intended",
+			DocURL:  DocsBaseURL + "/external-services.md#sigstore-integration",
+		}}
+
+	case "SIGN_059":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: "Re-sign the statement with a producer that encodes payloads as RFC 8785 canonical JSON, or drop require_canonical from the policy to accept it",
+			DocURL:  DocsBaseURL + "/external-services.md#sigstore-integration",
+		}}
+
+	case "SIGN_060":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: fmt.Sprintf("Confirm with the owners of %s that the signer change was intended, e.g. the release workflow moved, then reset its pinned identity; otherwise treat the artifact as compromised", target),
+			DocURL:  DocsBaseURL + "/external-services.md#sigstore-integration",
+		}}
+
+	case "SIGN_061":
+		return []Hint{{
+			Kind:    KindCommand,
+			Summary: "Check the SBOM is CycloneDX or SPDX JSON and the signing key is reachable, or attach it as a signed CycloneDX attestation instead",
+			Command: fmt.Sprintf("cosign attest --yes --type cyclonedx --predicate sbom.cdx.json %s", target),
+		}}
+
+	case "SIGN_062":
+		return []Hint{{
+			Kind:    KindConfiguration,
+			Summary: "Build on a hosted runner with a builder trusted for the required SLSA level, such as the slsa-github-generator reusable workflows, or add your builder to the policy's trusted_builders",
+			DocURL:  DocsBaseURL + "/external-services.md#sigstore-integration",
+		}}
+	}
+
+	return nil
+}
+
+// verifyCommand renders the cosign verify invocation matching the expected identity
+func verifyCommand(target, identity, issuer string) string {
+	identityFlag := `--certificate-identity-regexp="https://github.com/.*"`
+	if identity != "" {
+		identityFlag = fmt.Sprintf("--certificate-identity=%q", identity)
+	}
+	return fmt.Sprintf("cosign verify %s --certificate-oidc-issuer=%q %s", identityFlag, issuer, target)
+}
+
+// attestCommand renders the cosign attest invocation producing a missing predicate
+func attestCommand(target, predicateType string) Hint {
+	cosignType, predicateFile := "slsaprovenance1", "provenance.json"
+	switch {
+	case strings.Contains(predicateType, "cyclonedx"):
+		cosignType, predicateFile = "cyclonedx", "sbom.cdx.json"
+	case strings.Contains(predicateType, "spdx"):
+		cosignType, predicateFile = "spdxjson", "sbom.spdx.json"
+	case strings.Contains(predicateType, "vuln"):
+		cosignType, predicateFile = "vuln", "scan.json"
+	case strings.Contains(predicateType, "provenance/v0.2"):
+		cosignType = "slsaprovenance02"
+	case strings.Contains(predicateType, "scai/"):
+		// cosign has no short name for SCAI, so pass the predicate type URI
+		cosignType, predicateFile = predicateType, "scai.json"
+	}
+
+	summary := "Produce and attach the missing attestation"
+	if predicateType != "" {
+		summary = fmt.Sprintf("Produce and attach the missing %s attestation", predicateType)
+	}
+
+	return Hint{
+		Kind:    KindCommand,
+		Summary: summary,
+		Command: fmt.Sprintf("cosign attest --yes --type %s --predicate %s %s", cosignType, predicateFile, target),
+	}
+}
+
+// ForPolicyRule returns a documentation hint for a failed policy rule
+func ForPolicyRule(ruleID, message string) Hint {
+	summary := fmt.Sprintf("Policy rule %s failed", ruleID)
+	if message != "" {
+		summary = fmt.Sprintf("%s: %s", summary, message)
+	}
+	return Hint{
+		Kind:    KindDocumentation,
+		Summary: summary,
+		DocURL:  fmt.Sprintf("%s/security/README.md#%s", DocsBaseURL, anchor(ruleID)),
+	}
+}
+
+// ForFinding returns remediation hints for a finding, suggesting an upgrade when a fixed version is known
+func ForFinding(f findings.Finding) []Hint {
+	fixed := f.Metadata["fixed_version"]
+	if f.Category != findings.CategoryVulnerability || fixed == "" {
+		return nil
+	}
+
+	hint := Hint{
+		Kind:        KindUpgrade,
+		Summary:     fmt.Sprintf("Upgrade %s from %s to %s", f.Component, f.Version, fixed),
+		Package:     f.Component,
+		FromVersion: f.Version,
+		ToVersion:   fixed,
+		Command:     upgradeCommand(f.PURL, f.Component, fixed),
+	}
+	return []Hint{hint}
+}
+
+// upgradeCommand renders the package manager command for the component's ecosystem
+func upgradeCommand(purl, component, version string) string {
+	ecosystem := ""
+	if rest, found := strings.CutPrefix(purl, "pkg:"); found {
+		ecosystem, _, _ = strings.Cut(rest, "/")
+	}
+
+	switch ecosystem {
+	case "golang":
+		if !strings.HasPrefix(version, "v") {
+			version = "v" + version
+		}
+		return fmt.Sprintf("go get %s@%s && go mod tidy", component, version)
+	case "npm":
+		return fmt.Sprintf("npm install %s@%s", component, version)
+	case "pypi":
+		return fmt.Sprintf("pip install '%s==%s'", component, version)
+	case "maven":
+		return fmt.Sprintf("mvn versions:use-dep-version -Dincludes=%s -DdepVersion=%s", component, version)
+	case "cargo":
+		return fmt.Sprintf("cargo update -p %s --precise %s", component, version)
+	}
+	return ""
+}
+
+// anchor converts a rule ID into a markdown heading anchor
+func anchor(ruleID string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "-", "_", "-", ".", "").Replace(ruleID))
+}
+
+// Render writes hints in a human readable form for CLI output
+func Render(w io.Writer, hints []Hint) {
+	if len(hints) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "Remediation:")
+	for i, hint := range hints {
+		fmt.Fprintf(w, "  %d. %s\n", i+1, hint.Summary)
+		if hint.Command != "" {
+			for _, line := range strings.Split(hint.Command, "\n") {
+				fmt.Fprintf(w, "       %s\n", line)
+			}
+		}
+		if hint.DocURL != "" {
+			fmt.Fprintf(w, "     See: %s\n", hint.DocURL)
+		}
+	}
+}
+
+// registryHost returns the registry host of an image reference
+func registryHost(target string) string {
+	host, _, found := strings.Cut(target, "/")
+	if !found || !strings.ContainsAny(host, ".:") {
+		return "docker.io"
+	}
+	return host
+}
@@ -0,0 +1,203 @@
+package sbom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Format identifies the SBOM document format
+type Format string
+
+const (
+	FormatCycloneDX Format = "cyclonedx"
+	FormatSPDX      Format = "spdx"
+)
+
+// ErrUnknownFormat is returned when a document is neither CycloneDX nor SPDX JSON
+var ErrUnknownFormat = errors.New("unknown SBOM format")
+
+// Component represents a software component listed in an SBOM
+type Component struct {
+	Name    string            `json:"name"`
+	Group   string            `json:"group,omitempty"`
+	Version string            `json:"version,omitempty"`
+	PURL    string            `json:"purl,omitempty"`
+	Hashes  map[string]string `json:"hashes,omitempty"`
+}
+
+// PackageURL parses the component's purl, if present
+func (c Component) PackageURL() (*PackageURL, error) {
+	if c.PURL == "" {
+		return nil, fmt.Errorf("component %s has no purl", c.Name)
+	}
+	return ParsePackageURL(c.PURL)
+}
+
+// Document is the format-neutral view of a decoded SBOM
+type Document struct {
+	Format      Format      `json:"format"`
+	SpecVersion string      `json:"spec_version"`
+	Name        string      `json:"name,omitempty"`
+	Components  []Component `json:"components"`
+}
+
+// cycloneDXComponent mirrors the subset of the CycloneDX component schema we consume
+type cycloneDXComponent struct {
+	Name    string `json:"name"`
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	PURL    string `json:"purl"`
+	Hashes  []struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	} `json:"hashes"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXDocument struct {
+	BOMFormat   string `json:"bomFormat"`
+	SpecVersion string `json:"specVersion"`
+	Metadata    struct {
+		Component *cycloneDXComponent `json:"component"`
+	} `json:"metadata"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type spdxDocument struct {
+	SPDXVersion string `json:"spdxVersion"`
+	Name        string `json:"name"`
+	Packages    []struct {
+		Name         string `json:"name"`
+		VersionInfo  string `json:"versionInfo"`
+		ExternalRefs []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+		Checksums []struct {
+			Algorithm     string `json:"algorithm"`
+			ChecksumValue string `json:"checksumValue"`
+		} `json:"checksums"`
+	} `json:"packages"`
+}
+
+// Decode parses a CycloneDX or SPDX JSON document, detecting the format from its content
+func Decode(data []byte) (*Document, error) {
+	var probe struct {
+		BOMFormat   string `json:"bomFormat"`
+		SPDXVersion string `json:"spdxVersion"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse SBOM: %w", err)
+	}
+
+	switch {
+	case strings.EqualFold(probe.BOMFormat, "CycloneDX"):
+		return decodeCycloneDX(data)
+	case probe.SPDXVersion != "":
+		return decodeSPDX(data)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// decodeCycloneDX flattens nested CycloneDX components into a single list
+func decodeCycloneDX(data []byte) (*Document, error) {
+	var raw cycloneDXDocument
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse CycloneDX document: %w", err)
+	}
+
+	doc := &Document{
+		Format:      FormatCycloneDX,
+		SpecVersion: raw.SpecVersion,
+	}
+	if raw.Metadata.Component != nil {
+		doc.Name = raw.Metadata.Component.Name
+	}
+
+	var walk func(components []cycloneDXComponent)
+	walk = func(components []cycloneDXComponent) {
+		for _, c := range components {
+			component := Component{
+				Name:    c.Name,
+				Group:   c.Group,
+				Version: c.Version,
+				PURL:    c.PURL,
+			}
+			for _, hash := range c.Hashes {
+				if component.Hashes == nil {
+					component.Hashes = make(map[string]string)
+				}
+				component.Hashes[normalizeAlgorithm(hash.Alg)] = strings.ToLower(hash.Content)
+			}
+			doc.Components = append(doc.Components, component)
+			walk(c.Components)
+		}
+	}
+	walk(raw.Components)
+
+	return doc, nil
+}
+
+// decodeSPDX maps SPDX packages to components using their purl external references
+func decodeSPDX(data []byte) (*Document, error) {
+	var raw spdxDocument
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse SPDX document: %w", err)
+	}
+
+	doc := &Document{
+		Format:      FormatSPDX,
+		SpecVersion: strings.TrimPrefix(raw.SPDXVersion, "SPDX-"),
+		Name:        raw.Name,
+	}
+
+	for _, pkg := range raw.Packages {
+		component := Component{
+			Name:    pkg.Name,
+			Version: pkg.VersionInfo,
+		}
+		for _, ref := range pkg.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				component.PURL = ref.ReferenceLocator
+				break
+			}
+		}
+		if component.PURL != "" {
+			if purl, err := ParsePackageURL(component.PURL); err == nil {
+				component.Group = purl.Namespace
+			}
+		}
+		for _, checksum := range pkg.Checksums {
+			if component.Hashes == nil {
+				component.Hashes = make(map[string]string)
+			}
+			component.Hashes[normalizeAlgorithm(checksum.Algorithm)] = strings.ToLower(checksum.ChecksumValue)
+		}
+		doc.Components = append(doc.Components, component)
+	}
+
+	return doc, nil
+}
+
+// normalizeAlgorithm maps "SHA-256"/"SHA256" style names to "sha256"
+func normalizeAlgorithm(alg string) string {
+	return strings.ToLower(strings.ReplaceAll(alg, "-", ""))
+}
+
+// ComponentsByType returns components whose purl type matches (e.g. "maven", "npm")
+func (d *Document) ComponentsByType(purlType string) []Component {
+	var matched []Component
+	for _, component := range d.Components {
+		purl, err := component.PackageURL()
+		if err != nil {
+			continue
+		}
+		if purl.Type == purlType {
+			matched = append(matched, component)
+		}
+	}
+	return matched
+}
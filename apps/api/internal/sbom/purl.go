@@ -0,0 +1,173 @@
+package sbom
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// PackageURL represents a parsed package URL (purl) identifier
+type PackageURL struct {
+	Type       string            `json:"type"`
+	Namespace  string            `json:"namespace,omitempty"`
+	Name       string            `json:"name"`
+	Version    string            `json:"version,omitempty"`
+	Qualifiers map[string]string `json:"qualifiers,omitempty"`
+	Subpath    string            `json:"subpath,omitempty"`
+}
+
+// ParsePackageURL parses a purl string (pkg:type/namespace/name@version?qualifiers#subpath)
+func ParsePackageURL(raw string) (*PackageURL, error) {
+	scheme, rest, found := strings.Cut(raw, ":")
+	if !found || !strings.EqualFold(scheme, "pkg") {
+		return nil, fmt.Errorf("invalid purl %q: missing pkg scheme", raw)
+	}
+
+	purl := &PackageURL{}
+
+	// Subpath and qualifiers are split from the right as defined by the spec
+	if idx := strings.LastIndex(rest, "#"); idx >= 0 {
+		subpath, err := unescapePath(strings.Trim(rest[idx+1:], "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid purl %q: bad subpath: %w", raw, err)
+		}
+		purl.Subpath = subpath
+		rest = rest[:idx]
+	}
+
+	if idx := strings.LastIndex(rest, "?"); idx >= 0 {
+		qualifiers, err := parseQualifiers(rest[idx+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid purl %q: %w", raw, err)
+		}
+		purl.Qualifiers = qualifiers
+		rest = rest[:idx]
+	}
+
+	rest = strings.TrimLeft(rest, "/")
+	typ, rest, found := strings.Cut(rest, "/")
+	if !found || typ == "" {
+		return nil, fmt.Errorf("invalid purl %q: missing type", raw)
+	}
+	purl.Type = strings.ToLower(typ)
+
+	rest = strings.TrimRight(rest, "/")
+	if idx := strings.LastIndex(rest, "@"); idx >= 0 && idx > strings.LastIndex(rest, "/") {
+		version, err := url.PathUnescape(rest[idx+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid purl %q: bad version: %w", raw, err)
+		}
+		purl.Version = version
+		rest = rest[:idx]
+	}
+
+	namespace, name := "", rest
+	if idx := strings.LastIndex(rest, "/"); idx >= 0 {
+		namespace, name = rest[:idx], rest[idx+1:]
+	}
+
+	var err error
+	if purl.Name, err = url.PathUnescape(name); err != nil {
+		return nil, fmt.Errorf("invalid purl %q: bad name: %w", raw, err)
+	}
+	if purl.Name == "" {
+		return nil, fmt.Errorf("invalid purl %q: missing name", raw)
+	}
+	if purl.Namespace, err = unescapePath(namespace); err != nil {
+		return nil, fmt.Errorf("invalid purl %q: bad namespace: %w", raw, err)
+	}
+
+	return purl, nil
+}
+
+// String renders the purl in canonical form
+func (p *PackageURL) String() string {
+	var b strings.Builder
+	b.WriteString("pkg:")
+	b.WriteString(p.Type)
+	b.WriteString("/")
+	if p.Namespace != "" {
+		b.WriteString(escapePath(p.Namespace))
+		b.WriteString("/")
+	}
+	b.WriteString(escapeSegment(p.Name))
+	if p.Version != "" {
+		b.WriteString("@")
+		b.WriteString(escapeSegment(p.Version))
+	}
+
+	if len(p.Qualifiers) > 0 {
+		keys := make([]string, 0, len(p.Qualifiers))
+		for key := range p.Qualifiers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(keys))
+		for _, key := range keys {
+			pairs = append(pairs, key+"="+url.QueryEscape(p.Qualifiers[key]))
+		}
+		b.WriteString("?")
+		b.WriteString(strings.Join(pairs, "&"))
+	}
+
+	if p.Subpath != "" {
+		b.WriteString("#")
+		b.WriteString(escapePath(p.Subpath))
+	}
+
+	return b.String()
+}
+
+// parseQualifiers parses the key=value&key=value qualifier section
+func parseQualifiers(raw string) (map[string]string, error) {
+	qualifiers := make(map[string]string)
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("malformed qualifier %q", pair)
+		}
+		decoded, err := url.QueryUnescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("malformed qualifier %q: %w", pair, err)
+		}
+		if decoded != "" {
+			qualifiers[strings.ToLower(key)] = decoded
+		}
+	}
+	return qualifiers, nil
+}
+
+// unescapePath percent-decodes each segment of a slash separated path
+func unescapePath(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			return "", err
+		}
+		segments[i] = decoded
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// escapePath percent-encodes each segment of a slash separated path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = escapeSegment(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escapeSegment percent-encodes a single segment, including the '@' separator
+func escapeSegment(segment string) string {
+	return strings.ReplaceAll(url.PathEscape(segment), "@", "%40")
+}
@@ -0,0 +1,90 @@
+package sbom
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// StoredDocument is an SBOM kept with the bytes it was ingested as
+type StoredDocument struct {
+	Digest         string    `json:"digest"` // sha256:hex of Data
+	Name           string    `json:"name,omitempty"`
+	Format         Format    `json:"format"`
+	SpecVersion    string    `json:"spec_version"`
+	ComponentCount int       `json:"component_count"`
+	Data           []byte    `json:"-"`
+	Source         string    `json:"source"`
+	IngestedAt     time.Time `json:"ingested_at"`
+}
+
+// Store persists SBOM documents in SQLite
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates an SBOM store on the migrated database
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Save decodes and stores an SBOM, keyed by the digest of its bytes. Saving
+// the same document again keeps the first copy and reports false.
+func (s *Store) Save(ctx context.Context, data []byte, source string, at time.Time) (*StoredDocument, bool, error) {
+	doc, err := Decode(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	sum := sha256.Sum256(data)
+	stored := &StoredDocument{
+		Digest:         "sha256:" + hex.EncodeToString(sum[:]),
+		Name:           doc.Name,
+		Format:         doc.Format,
+		SpecVersion:    doc.SpecVersion,
+		ComponentCount: len(doc.Components),
+		Data:           data,
+		Source:         source,
+		IngestedAt:     at.UTC(),
+	}
+
+	insertSQL := `
+		INSERT INTO sbom_documents (digest, name, format, spec_version, component_count, data, source, ingested_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(digest) DO NOTHING
+	`
+	result, err := s.db.ExecContext(ctx, insertSQL, stored.Digest, stored.Name, string(stored.Format),
+		stored.SpecVersion, stored.ComponentCount, stored.Data, stored.Source, stored.IngestedAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to store SBOM %s: %w", stored.Digest, err)
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	return stored, added > 0, nil
+}
+
+// Get returns a stored SBOM by digest, or nil if there is none
+func (s *Store) Get(ctx context.Context, digest string) (*StoredDocument, error) {
+	query := `
+		SELECT digest, COALESCE(name, ''), format, COALESCE(spec_version, ''), component_count, data, source, ingested_at
+		FROM sbom_documents WHERE digest = ?
+	`
+
+	var doc StoredDocument
+	var format string
+	err := s.db.QueryRowContext(ctx, query, digest).Scan(&doc.Digest, &doc.Name, &format, &doc.SpecVersion,
+		&doc.ComponentCount, &doc.Data, &doc.Source, &doc.IngestedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SBOM %s: %w", digest, err)
+	}
+	doc.Format = Format(format)
+	return &doc, nil
+}
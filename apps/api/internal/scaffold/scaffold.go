@@ -0,0 +1,244 @@
+// Package scaffold inspects a repository adopting Keystone and generates its
+// starter files: a GitHub Actions workflow that builds, generates an SBOM,
+// scans, signs and verifies, a default verification policy and a keystone.yaml
+package scaffold
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// Generated file paths, relative to the repository root
+const (
+	WorkflowPath = ".github/workflows/keystone.yml"
+	PolicyPath   = ".keystone/policy.yaml"
+	ConfigPath   = "keystone.yaml"
+)
+
+// ErrFileExists is returned by Write when a generated file is already present
+var ErrFileExists = errors.New("file already exists")
+
+// Languages detected by Inspect
+const (
+	LanguageGo      = "go"
+	LanguageNode    = "node"
+	LanguagePython  = "python"
+	LanguageJava    = "java"
+	LanguageGeneric = "generic"
+)
+
+// Project is what Inspect learned about a repository
+type Project struct {
+	Name          string // Repository name
+	Owner         string // GitHub owner, empty when no GitHub remote was found
+	Language      string
+	Dockerfile    bool   // A Dockerfile at the root; the workflow then builds and signs an image
+	DefaultBranch string // Branch the workflow runs on and the policy accepts
+}
+
+// Repository returns owner/name, or "" when the owner is unknown
+func (p *Project) Repository() string {
+	if p.Owner == "" {
+		return ""
+	}
+	return p.Owner + "/" + p.Name
+}
+
+// File is a generated file
+type File struct {
+	Path    string // Relative to the repository root, slash separated
+	Content []byte
+}
+
+// languageMarkers map build files to languages, checked in order
+var languageMarkers = []struct {
+	file     string
+	language string
+}{
+	{"go.mod", LanguageGo},
+	{"package.json", LanguageNode},
+	{"pyproject.toml", LanguagePython},
+	{"requirements.txt", LanguagePython},
+	{"pom.xml", LanguageJava},
+	{"build.gradle", LanguageJava},
+}
+
+// Inspect detects the language, container build and GitHub remote of the
+// repository at dir. The default branch is assumed to be main; callers that
+// can ask GitHub should overwrite it.
+func Inspect(dir string) (*Project, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	project := &Project{
+		Name:          filepath.Base(abs),
+		Language:      LanguageGeneric,
+		DefaultBranch: "main",
+	}
+	for _, marker := range languageMarkers {
+		if exists(filepath.Join(abs, marker.file)) {
+			project.Language = marker.language
+			break
+		}
+	}
+	project.Dockerfile = exists(filepath.Join(abs, "Dockerfile"))
+
+	if remote := originURL(filepath.Join(abs, ".git", "config")); remote != "" {
+		if owner, name, ok := ParseGitHubRemote(remote); ok {
+			project.Owner, project.Name = owner, name
+		}
+	}
+	return project, nil
+}
+
+// ParseGitHubRemote extracts owner and repository from a github.com remote
+// URL in HTTPS, SSH or scp-like form
+func ParseGitHubRemote(remote string) (owner, name string, ok bool) {
+	remote = strings.TrimSpace(remote)
+	var path string
+	switch {
+	case strings.HasPrefix(remote, "git@github.com:"):
+		path = strings.TrimPrefix(remote, "git@github.com:")
+	case strings.HasPrefix(remote, "https://github.com/"),
+		strings.HasPrefix(remote, "ssh://git@github.com/"),
+		strings.HasPrefix(remote, "git://github.com/"):
+		_, path, _ = strings.Cut(remote, "github.com/")
+	default:
+		return "", "", false
+	}
+
+	owner, name, found := strings.Cut(strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git"), "/")
+	if !found || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", false
+	}
+	return owner, name, true
+}
+
+// originURL reads the origin remote's URL from a git config file, without
+// needing git installed
+func originURL(configPath string) string {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return ""
+	}
+
+	inOrigin := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inOrigin = line == `[remote "origin"]`
+			continue
+		}
+		if key, value, found := strings.Cut(line, "="); inOrigin && found && strings.TrimSpace(key) == "url" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// templateData is what the file templates render from
+type templateData struct {
+	*Project
+	Build        []string // Shell commands building and testing the project
+	Setup        string   // Setup action and its inputs, empty when none is needed
+	Issuer       string
+	WorkflowPath string
+	PolicyPath   string
+}
+
+// buildSteps are the setup action and commands per language
+var buildSteps = map[string]struct {
+	setup    string
+	commands []string
+}{
+	LanguageGo: {
+		setup:    "actions/setup-go@v5\n        with:\n          go-version-file: go.mod",
+		commands: []string{"go build ./...", "go test ./..."},
+	},
+	LanguageNode: {
+		setup:    "actions/setup-node@v4\n        with:\n          node-version: '22'\n          cache: 'npm'",
+		commands: []string{"npm ci", "npm run build --if-present", "npm test --if-present"},
+	},
+	LanguagePython: {
+		setup:    "actions/setup-python@v5\n        with:\n          python-version: '3.12'",
+		commands: []string{"pip install -r requirements.txt || pip install .", "if [ -d tests ]; then pip install pytest && python -m pytest; fi"},
+	},
+	LanguageJava: {
+		setup:    "actions/setup-java@v4\n        with:\n          distribution: temurin\n          java-version: '21'",
+		commands: []string{"if [ -f pom.xml ]; then mvn -B verify; else ./gradlew build; fi"},
+	},
+}
+
+// Generate renders the workflow, policy and keystone.yaml for a project
+func Generate(project *Project) ([]File, error) {
+	data := templateData{
+		Project:      project,
+		Issuer:       attestation.GitHubActionsIssuer,
+		WorkflowPath: WorkflowPath,
+		PolicyPath:   PolicyPath,
+	}
+	if steps, ok := buildSteps[project.Language]; ok {
+		data.Setup = steps.setup
+		data.Build = steps.commands
+	}
+
+	files := []File{
+		{Path: WorkflowPath},
+		{Path: PolicyPath},
+		{Path: ConfigPath},
+	}
+	for i, tmpl := range []*template.Template{workflowTemplate, policyTemplate, configTemplate} {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", files[i].Path, err)
+		}
+		files[i].Content = buf.Bytes()
+	}
+	return files, nil
+}
+
+// Write writes generated files under dir. Unless force is set, nothing is
+// written when any of the files already exists.
+func Write(dir string, files []File, force bool) error {
+	if !force {
+		for _, file := range files {
+			if exists(filepath.Join(dir, filepath.FromSlash(file.Path))) {
+				return fmt.Errorf("%w: %s", ErrFileExists, file.Path)
+			}
+		}
+	}
+
+	for _, file := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, file.Content, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
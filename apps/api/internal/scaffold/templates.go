@@ -0,0 +1,246 @@
+package scaffold
+
+import "text/template"
+
+// Templates use [[ ]] delimiters so GitHub Actions ${{ }} expressions pass
+// through untouched
+func parse(name, text string) *template.Template {
+	return template.Must(template.New(name).Delims("[[", "]]").Parse(text))
+}
+
+var workflowTemplate = parse("workflow", `# Generated by keystone init. Builds [[.Name]], generates a CycloneDX SBOM,
+# scans it for vulnerabilities, then on pushes to [[.DefaultBranch]] signs the
+# [[if .Dockerfile]]image[[else]]SBOM[[end]] keylessly with Sigstore and verifies the signature against
+# [[.PolicyPath]].
+name: Keystone
+
+on:
+  push:
+    branches: [ [[.DefaultBranch]] ]
+  pull_request:
+    branches: [ [[.DefaultBranch]] ]
+
+permissions:
+  contents: read
+
+jobs:
+  build:
+    runs-on: ubuntu-latest
+[[- if .Dockerfile]]
+    permissions:
+      contents: read
+      packages: write
+    outputs:
+      image: ${{ steps.image.outputs.name }}
+      digest: ${{ steps.push.outputs.digest }}
+[[- end]]
+    steps:
+      - name: Checkout code
+        uses: actions/checkout@v4
+[[- if .Setup]]
+
+      - name: Set up toolchain
+        uses: [[.Setup]]
+[[- end]]
+[[- if .Build]]
+
+      - name: Build and test
+        run: |
+[[- range .Build]]
+          [[.]]
+[[- end]]
+[[- end]]
+[[- if .Dockerfile]]
+
+      - name: Image name
+        id: image
+        run: echo "name=ghcr.io/${GITHUB_REPOSITORY,,}" >> "$GITHUB_OUTPUT"
+
+      - name: Log in to GHCR
+        if: github.event_name == 'push'
+        uses: docker/login-action@v3
+        with:
+          registry: ghcr.io
+          username: ${{ github.actor }}
+          password: ${{ secrets.GITHUB_TOKEN }}
+
+      - name: Build and push image
+        id: push
+        uses: docker/build-push-action@v6
+        with:
+          context: .
+          push: ${{ github.event_name == 'push' }}
+          tags: ${{ steps.image.outputs.name }}:${{ github.sha }}
+[[- end]]
+
+  sbom:
+    runs-on: ubuntu-latest
+    needs: build
+[[- if .Dockerfile]]
+    if: github.event_name == 'push'
+[[- end]]
+    steps:
+      - name: Checkout code
+        uses: actions/checkout@v4
+
+      - name: Install Syft
+        run: curl -sSfL https://raw.githubusercontent.com/anchore/syft/main/install.sh | sh -s -- -b /usr/local/bin
+
+      - name: Generate SBOM
+        run: syft [[if .Dockerfile]]"${{ needs.build.outputs.image }}@${{ needs.build.outputs.digest }}"[[else]]dir:.[[end]] -o cyclonedx-json=sbom.cdx.json
+
+      - name: Upload SBOM
+        uses: actions/upload-artifact@v4
+        with:
+          name: sbom
+          path: sbom.cdx.json
+
+  scan:
+    runs-on: ubuntu-latest
+    needs: sbom
+    steps:
+      - name: Download SBOM
+        uses: actions/download-artifact@v4
+        with:
+          name: sbom
+
+      - name: Install Grype
+        run: curl -sSfL https://raw.githubusercontent.com/anchore/grype/main/install.sh | sh -s -- -b /usr/local/bin
+
+      - name: Scan SBOM
+        run: grype sbom:sbom.cdx.json --fail-on high
+
+  sign:
+    runs-on: ubuntu-latest
+    needs: [build, scan]
+    if: github.event_name == 'push'
+    permissions:
+      contents: read
+      id-token: write
+[[- if .Dockerfile]]
+      packages: write
+[[- end]]
+    steps:
+      - name: Download SBOM
+        uses: actions/download-artifact@v4
+        with:
+          name: sbom
+
+      - name: Install Cosign
+        uses: sigstore/cosign-installer@v3
+[[- if .Dockerfile]]
+
+      - name: Log in to GHCR
+        uses: docker/login-action@v3
+        with:
+          registry: ghcr.io
+          username: ${{ github.actor }}
+          password: ${{ secrets.GITHUB_TOKEN }}
+
+      - name: Sign image and attest SBOM
+        env:
+          IMAGE: ${{ needs.build.outputs.image }}@${{ needs.build.outputs.digest }}
+        run: |
+          cosign sign --yes "$IMAGE"
+          cosign attest --yes --type cyclonedx --predicate sbom.cdx.json "$IMAGE"
+[[- else]]
+
+      - name: Sign SBOM
+        run: cosign sign-blob --yes --bundle sbom.cdx.json.bundle sbom.cdx.json
+
+      - name: Upload signature bundle
+        uses: actions/upload-artifact@v4
+        with:
+          name: sbom-bundle
+          path: sbom.cdx.json.bundle
+[[- end]]
+
+  verify:
+    runs-on: ubuntu-latest
+    needs: [build, sign]
+    if: github.event_name == 'push'
+[[- if .Dockerfile]]
+    permissions:
+      contents: read
+      packages: read
+[[- end]]
+    steps:
+      - name: Install Cosign
+        uses: sigstore/cosign-installer@v3
+[[- if not .Dockerfile]]
+
+      - name: Download SBOM and bundle
+        uses: actions/download-artifact@v4
+        with:
+          pattern: sbom*
+          merge-multiple: true
+[[- end]]
+
+      # Matches the identity in [[.PolicyPath]]: this workflow, in this
+      # repository, on [[.DefaultBranch]]
+      - name: Verify signature
+        env:
+          IDENTITY: ${{ github.server_url }}/${{ github.repository }}/[[.WorkflowPath]]@refs/heads/[[.DefaultBranch]]
+[[- if .Dockerfile]]
+          IMAGE: ${{ needs.build.outputs.image }}@${{ needs.build.outputs.digest }}
+[[- end]]
+        run: |
+[[- if .Dockerfile]]
+          cosign verify --certificate-identity "$IDENTITY" \
+            --certificate-oidc-issuer [[.Issuer]] "$IMAGE"
+          cosign verify-attestation --type cyclonedx --certificate-identity "$IDENTITY" \
+            --certificate-oidc-issuer [[.Issuer]] "$IMAGE"
+[[- else]]
+          cosign verify-blob --bundle sbom.cdx.json.bundle --certificate-identity "$IDENTITY" \
+            --certificate-oidc-issuer [[.Issuer]] sbom.cdx.json
+[[- end]]
+`)
+
+var policyTemplate = parse("policy", `# Keystone verification policy, generated by keystone init. Attestations are
+# accepted only when signed keylessly by the Keystone workflow of this
+# repository on its default branch. Empty fields are not enforced.
+issuer: [[.Issuer]]
+[[- if .Repository]]
+repository: [[.Repository]]
+[[- else]]
+# repository: owner/name
+[[- end]]
+workflow_ref: [[.WorkflowPath]]
+branch: [[.DefaultBranch]]
+# Reject payloads that are not RFC 8785 canonical JSON. Keystone always signs
+# canonical payloads; enable this once every producer does too.
+require_canonical: false
+`)
+
+var configTemplate = parse("config", `# Keystone configuration, generated by keystone init
+version: 1
+
+project:
+  name: [[.Name]]
+[[- if .Repository]]
+  repository: [[.Repository]]
+[[- end]]
+  language: [[.Language]]
+  default_branch: [[.DefaultBranch]]
+
+artifact:
+[[- if .Dockerfile]]
+  type: image
+  dockerfile: Dockerfile
+[[- else]]
+  type: sbom
+[[- end]]
+
+sbom:
+  format: cyclonedx-json
+
+scan:
+  fail_on: high
+
+sign:
+  mode: keyless
+  issuer: [[.Issuer]]
+
+policy: [[.PolicyPath]]
+workflow: [[.WorkflowPath]]
+`)
@@ -0,0 +1,135 @@
+// Package secret keeps credentials such as OIDC tokens and GitHub tokens out
+// of logs, error messages, cache entries and audit records
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// Redacted replaces a credential wherever it would be printed or stored
+const Redacted = "[REDACTED]"
+
+// String holds a credential. Printing, logging or marshaling it yields
+// Redacted; Reveal returns the value where it's actually sent, and Release
+// overwrites it once it's no longer needed. A nil String is empty.
+//
+// Go strings can't be overwritten, so the value is kept as bytes. Strings
+// returned by Reveal are copies, and callers shouldn't retain them.
+type String struct {
+	mutex sync.RWMutex
+	value []byte
+}
+
+// New wraps a credential
+func New(value string) *String {
+	return &String{value: []byte(value)}
+}
+
+// Reveal returns the credential, or an empty string once released
+func (s *String) Reveal() string {
+	if s == nil {
+		return ""
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return string(s.value)
+}
+
+// Empty reports whether there is no credential, or it was released
+func (s *String) Empty() bool {
+	if s == nil {
+		return true
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.value) == 0
+}
+
+// Release zeroes the credential. Later calls to Reveal return an empty
+// string.
+func (s *String) Release() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.value {
+		s.value[i] = 0
+	}
+	s.value = nil
+}
+
+// String implements fmt.Stringer
+func (s *String) String() string {
+	return Redacted
+}
+
+// GoString implements fmt.GoStringer, for %#v
+func (s *String) GoString() string {
+	return Redacted
+}
+
+// Format implements fmt.Formatter, so no verb prints the credential
+func (s *String) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, Redacted)
+}
+
+// MarshalJSON implements json.Marshaler. The credential is never encoded, so
+// a String can't leak into a cache entry, event or audit record.
+func (s *String) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Redacted)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s *String) MarshalText() ([]byte, error) {
+	return []byte(Redacted), nil
+}
+
+// patterns match credentials embedded in text, with their replacement
+var patterns = []struct {
+	match   *regexp.Regexp
+	replace string
+}{
+	// JWTs, e.g. OIDC identity tokens; unsigned tokens have no signature
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), Redacted},
+	// GitHub personal access, OAuth, app installation and refresh tokens
+	{regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,})\b`), Redacted},
+	// Authorization header values, keeping the scheme
+	{regexp.MustCompile(`(?i)\b(bearer|authorization:\s*token)\s+[A-Za-z0-9._~+/=-]+`), "$1 " + Redacted},
+}
+
+// Scrub replaces credentials in text with Redacted, e.g. in an error message
+// that quotes a service's response
+func Scrub(text string) string {
+	for _, pattern := range patterns {
+		text = pattern.match.ReplaceAllString(text, pattern.replace)
+	}
+	return text
+}
+
+// Contains reports whether text holds a credential Scrub would redact
+func Contains(text string) bool {
+	for _, pattern := range patterns {
+		if pattern.match.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Is reports whether value is a credential, either wrapped in a String or a
+// bare string Scrub would redact
+func Is(value interface{}) bool {
+	switch v := value.(type) {
+	case *String:
+		return true
+	case string:
+		return Contains(v)
+	case []byte:
+		return Contains(string(v))
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package siem
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+)
+
+// Message formats
+const (
+	FormatRFC5424 = "rfc5424" // Event fields as RFC 5424 structured data
+	FormatCEF     = "cef"     // ArcSight Common Event Format in the syslog message
+)
+
+// facilityLogAudit is the RFC 5424 "log audit" facility
+const facilityLogAudit = 13
+
+// sdID names Keystone's structured data element. 32473 is the private
+// enterprise number RFC 5612 reserves for documentation.
+const sdID = "keystone@32473"
+
+// eventKind describes how an event type is presented to a SIEM
+type eventKind struct {
+	name     string // Human-readable event name
+	severity int    // RFC 5424 severity, 0 (emergency) to 7 (debug)
+	cef      int    // CEF severity, 0 to 10
+}
+
+// eventKinds covers the audit and security events exported by default; other
+// types are sent as informational
+var eventKinds = map[string]eventKind{
+	events.TypeIdentityMisuse:     {"Signing identity used outside Keystone", 2, 10},
+	events.TypeIdentityChanged:    {"Pinned signing identity changed", 3, 8},
+	events.TypeVerificationFailed: {"Attestation verification failed", 4, 7},
+	events.TypeSLOBurnRate:        {"SLO burn rate alert", 4, 5},
+	events.TypeJobFailed:          {"Job failed", 4, 4},
+	events.TypeAdminAction:        {"Administrative action", 5, 4},
+	events.TypeModeChanged:        {"Operating mode changed", 5, 4},
+	events.TypeScanCompleted:      {"Scan completed", 6, 3},
+}
+
+// DefaultEventTypes are exported when no event types are configured
+var DefaultEventTypes = []string{
+	events.TypeIdentityMisuse,
+	events.TypeIdentityChanged,
+	events.TypeVerificationFailed,
+	events.TypeSLOBurnRate,
+	events.TypeAdminAction,
+	events.TypeModeChanged,
+}
+
+// kindOf returns how an event type is presented
+func kindOf(eventType string) eventKind {
+	if kind, ok := eventKinds[eventType]; ok {
+		return kind
+	}
+	return eventKind{name: eventType, severity: 6, cef: 3}
+}
+
+// field is one flattened top-level field of an event payload
+type field struct {
+	name  string
+	value string
+}
+
+// payloadFields flattens the top level of an event payload, sorted by name.
+// Nested values are kept as JSON.
+func payloadFields(event events.Event) []field {
+	var payload map[string]json.RawMessage
+	if len(event.Data) == 0 || json.Unmarshal(event.Data, &payload) != nil {
+		return nil
+	}
+
+	fields := make([]field, 0, len(payload))
+	for name, raw := range payload {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		fields = append(fields, field{name: name, value: value})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return fields
+}
+
+// Format renders an event as an RFC 5424 syslog message. In CEF format the
+// CEF record is the message and there is no structured data.
+func (e *Exporter) Format(event events.Event) []byte {
+	kind := kindOf(event.Type)
+	structured, message := e.structuredData(event), kind.name
+	if e.config.Format == FormatCEF {
+		structured, message = "-", e.cef(event, kind)
+	}
+
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s - %s %s %s",
+		facilityLogAudit*8+kind.severity,
+		event.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(e.config.Hostname, 255),
+		headerField(e.config.AppName, 48),
+		headerField(event.Type, 32),
+		structured,
+		message,
+	))
+}
+
+// structuredData renders the event as one SD-ELEMENT
+func (e *Exporter) structuredData(event events.Event) string {
+	var b strings.Builder
+	b.WriteString("[" + sdID)
+	params := append([]field{{"id", event.ID}, {"source", event.Source}}, payloadFields(event)...)
+	for _, param := range params {
+		fmt.Fprintf(&b, ` %s="%s"`, sdName(param.name), sdEscape(param.value))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// cef renders the event as a CEF record
+func (e *Exporter) cef(event events.Event, kind eventKind) string {
+	extension := []field{
+		{"rt", strconv.FormatInt(event.Time.UnixMilli(), 10)},
+		{"externalId", event.ID},
+		{"deviceProcessName", event.Source},
+	}
+	if e.config.Hostname != "" {
+		extension = append(extension, field{"dvchost", e.config.Hostname})
+	}
+	extension = append(extension, payloadFields(event)...)
+
+	parts := make([]string, 0, len(extension))
+	for _, f := range extension {
+		parts = append(parts, cefKey(f.name)+"="+cefValueEscaper.Replace(f.value))
+	}
+	return fmt.Sprintf("CEF:0|Keystone|Keystone|%s|%s|%s|%d|%s",
+		cefHeaderEscaper.Replace(e.config.Version),
+		cefHeaderEscaper.Replace(event.Type),
+		cefHeaderEscaper.Replace(kind.name),
+		kind.cef,
+		strings.Join(parts, " "))
+}
+
+// headerField makes a value a valid syslog header field: printable ASCII
+// without spaces, truncated, or - when empty
+func headerField(value string, max int) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+	if len(cleaned) > max {
+		cleaned = cleaned[:max]
+	}
+	if cleaned == "" {
+		return "-"
+	}
+	return cleaned
+}
+
+// sdName makes a payload field name a valid SD-NAME
+func sdName(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+	if len(cleaned) > 32 {
+		cleaned = cleaned[:32]
+	}
+	return cleaned
+}
+
+// sdEscape escapes a PARAM-VALUE
+func sdEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// cefKey makes a payload field name a valid CEF extension key
+func cefKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
@@ -0,0 +1,209 @@
+// Package siem exports audit and security events from the event bus to a
+// syslog collector, as RFC 5424 structured data or CEF records over TCP or
+// TLS, so SIEMs such as Splunk and QRadar ingest them without a custom
+// integration.
+package siem
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+)
+
+// Message framings (RFC 6587)
+const (
+	FramingOctetCounting = "octet-counting" // Length-prefixed, as RFC 5425 requires over TLS
+	FramingNewline       = "newline"        // Newline-terminated, for collectors that expect it
+)
+
+// Config holds exporter configuration
+type Config struct {
+	Address    string      // Collector host:port
+	TLS        *tls.Config // Nil sends over plain TCP
+	Format     string      // FormatRFC5424 or FormatCEF
+	Framing    string      // FramingOctetCounting or FramingNewline
+	Hostname   string      // Reported as the syslog hostname and CEF dvchost
+	AppName    string
+	Version    string   // Reported as the CEF device version
+	EventTypes []string // Defaults to DefaultEventTypes
+	QueueGroup string   // Exporters in one group share the events, so each is sent once
+	Timeout    time.Duration
+}
+
+// DefaultConfig returns the exporter defaults for a collector address
+func DefaultConfig(address string) Config {
+	hostname, _ := os.Hostname()
+	return Config{
+		Address:    address,
+		Format:     FormatRFC5424,
+		Framing:    FramingOctetCounting,
+		Hostname:   hostname,
+		AppName:    "keystone",
+		Version:    "1.0",
+		EventTypes: DefaultEventTypes,
+		QueueGroup: "siem-exporter",
+		Timeout:    10 * time.Second,
+	}
+}
+
+// Validate checks the format and framing are known
+func (c Config) Validate() error {
+	if c.Address == "" {
+		return fmt.Errorf("syslog collector address is required")
+	}
+	if c.Format != FormatRFC5424 && c.Format != FormatCEF {
+		return fmt.Errorf("unknown syslog format %q; use %s or %s", c.Format, FormatRFC5424, FormatCEF)
+	}
+	if c.Framing != FramingOctetCounting && c.Framing != FramingNewline {
+		return fmt.Errorf("unknown syslog framing %q; use %s or %s", c.Framing, FramingOctetCounting, FramingNewline)
+	}
+	return nil
+}
+
+// TLSConfig trusts the CA certificates in caFile, or the system roots when
+// it is empty
+func TLSConfig(caFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read syslog CA: %w", err)
+	}
+	config.RootCAs = x509.NewCertPool()
+	if !config.RootCAs.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return config, nil
+}
+
+// Exporter sends bus events to a syslog collector over one connection,
+// reconnecting when a write fails
+type Exporter struct {
+	config Config
+	mutex  sync.Mutex
+	conn   net.Conn
+}
+
+// NewExporter creates an exporter; it connects on the first event
+func NewExporter(config Config) (*Exporter, error) {
+	defaults := DefaultConfig(config.Address)
+	if config.Format == "" {
+		config.Format = defaults.Format
+	}
+	if config.Framing == "" {
+		config.Framing = defaults.Framing
+	}
+	if config.AppName == "" {
+		config.AppName = defaults.AppName
+	}
+	if config.Version == "" {
+		config.Version = defaults.Version
+	}
+	if len(config.EventTypes) == 0 {
+		config.EventTypes = defaults.EventTypes
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Exporter{config: config}, nil
+}
+
+// Run exports the configured event types until the context is cancelled
+func (e *Exporter) Run(ctx context.Context, bus events.Bus) error {
+	var opts []events.SubscribeOption
+	if e.config.QueueGroup != "" {
+		opts = append(opts, events.WithQueueGroup(e.config.QueueGroup))
+	}
+
+	var subs []events.Subscription
+	defer func() {
+		for _, sub := range subs {
+			sub.Unsubscribe()
+		}
+		e.Close()
+	}()
+	for _, eventType := range e.config.EventTypes {
+		sub, err := bus.Subscribe(eventType, e.Export, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %w", eventType, err)
+		}
+		subs = append(subs, sub)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// Export formats an event and sends it to the collector
+func (e *Exporter) Export(ctx context.Context, event events.Event) error {
+	message := e.Format(event)
+	var frame []byte
+	if e.config.Framing == FramingNewline {
+		frame = append(message, '\n')
+	} else {
+		frame = append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+	}
+	return e.send(ctx, frame)
+}
+
+// send writes a frame, reconnecting once if the connection was dropped
+func (e *Exporter) send(ctx context.Context, frame []byte) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if e.conn == nil {
+			if e.conn, err = e.dial(ctx); err != nil {
+				return err
+			}
+		}
+		e.conn.SetWriteDeadline(time.Now().Add(e.config.Timeout))
+		if _, err = e.conn.Write(frame); err == nil {
+			return nil
+		}
+		e.conn.Close()
+		e.conn = nil
+	}
+	return fmt.Errorf("failed to send to syslog collector %s: %w", e.config.Address, err)
+}
+
+// dial connects to the collector over TCP or TLS
+func (e *Exporter) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: e.config.Timeout}
+	var conn net.Conn
+	var err error
+	if e.config.TLS != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: e.config.TLS}).DialContext(ctx, "tcp", e.config.Address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", e.config.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("syslog collector %s is unreachable: %w", e.config.Address, err)
+	}
+	return conn, nil
+}
+
+// Close closes the connection to the collector
+func (e *Exporter) Close() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
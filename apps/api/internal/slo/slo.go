@@ -0,0 +1,287 @@
+// Package slo tracks latency service level objectives for key operations and
+// raises multiwindow burn-rate alerts on the event bus when an objective's
+// error budget is being spent too quickly
+package slo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+)
+
+// Operations with default objectives
+const (
+	OperationVerify = "verify"
+	OperationSign   = "sign"
+)
+
+// Alert severities
+const (
+	SeverityPage   = "page"
+	SeverityTicket = "ticket"
+)
+
+// bucketSize is the granularity traffic is counted at
+const bucketSize = time.Minute
+
+// Objective requires a fraction of an operation's requests to succeed within a latency
+type Objective struct {
+	Operation string        `json:"operation"`
+	Latency   time.Duration `json:"latency"` // Slower requests count against the error budget
+	Target    float64       `json:"target"`  // Fraction of good requests, e.g. 0.99
+}
+
+// ErrorBudget returns the fraction of requests allowed to be bad
+func (o Objective) ErrorBudget() float64 {
+	return 1 - o.Target
+}
+
+// AlertRule fires when the burn rate exceeds BurnRate over both windows: the
+// long window shows the budget is really being spent, and the short window
+// that it still is, so alerts resolve soon after recovery
+type AlertRule struct {
+	Severity    string        `json:"severity"`
+	LongWindow  time.Duration `json:"long_window"`
+	ShortWindow time.Duration `json:"short_window"`
+	BurnRate    float64       `json:"burn_rate"`
+	MinRequests int64         `json:"min_requests"` // Requests needed in the short window, so one slow call can't page
+}
+
+// Config holds the objectives and alert rules to track
+type Config struct {
+	Objectives []Objective
+	Rules      []AlertRule
+	Clock      clock.Clock // Defaults to the system clock
+}
+
+// DefaultConfig tracks verification within 10s and signing within 30s for
+// 99% of requests, paging when 2% of a 30-day budget burns in an hour and
+// opening a ticket when 5% burns in six hours
+func DefaultConfig() Config {
+	return Config{
+		Objectives: []Objective{
+			{Operation: OperationVerify, Latency: 10 * time.Second, Target: 0.99},
+			{Operation: OperationSign, Latency: 30 * time.Second, Target: 0.99},
+		},
+		Rules: []AlertRule{
+			{Severity: SeverityPage, LongWindow: time.Hour, ShortWindow: 5 * time.Minute, BurnRate: 14.4, MinRequests: 10},
+			{Severity: SeverityTicket, LongWindow: 6 * time.Hour, ShortWindow: 30 * time.Minute, BurnRate: 6, MinRequests: 10},
+		},
+	}
+}
+
+// Alert is a burn-rate rule firing for an objective
+type Alert struct {
+	Objective     Objective `json:"objective"`
+	Rule          AlertRule `json:"rule"`
+	LongBurnRate  float64   `json:"long_burn_rate"`
+	ShortBurnRate float64   `json:"short_burn_rate"`
+}
+
+// key identifies an alert across evaluations
+func (a Alert) key() string {
+	return a.Objective.Operation + "/" + a.Rule.Severity + "/" + a.Rule.LongWindow.String()
+}
+
+// Tracker counts good and bad requests per operation in one-minute buckets
+// covering the longest alert window
+type Tracker struct {
+	config  Config
+	clock   clock.Clock
+	buckets int
+
+	mutex  sync.Mutex
+	counts map[string][]bucket
+	firing map[string]Alert
+}
+
+// bucket counts one minute of requests
+type bucket struct {
+	minute int64
+	total  int64
+	bad    int64
+}
+
+// NewTracker creates an SLO tracker
+func NewTracker(config Config) *Tracker {
+	longest := bucketSize
+	for _, rule := range config.Rules {
+		if rule.LongWindow > longest {
+			longest = rule.LongWindow
+		}
+	}
+
+	t := &Tracker{
+		config:  config,
+		clock:   clock.OrReal(config.Clock),
+		buckets: int(longest / bucketSize),
+		counts:  make(map[string][]bucket),
+		firing:  make(map[string]Alert),
+	}
+	for _, objective := range config.Objectives {
+		t.counts[objective.Operation] = make([]bucket, t.buckets)
+	}
+	return t
+}
+
+// Record counts a request; it is bad if it failed or exceeded the objective's
+// latency. Operations without an objective are ignored.
+func (t *Tracker) Record(operation string, duration time.Duration, err error) {
+	objective, ok := t.objective(operation)
+	if !ok {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	minute := t.clock.Now().Unix() / int64(bucketSize/time.Second)
+	counts := t.counts[operation]
+	b := &counts[int(minute%int64(len(counts)))]
+	if b.minute != minute {
+		*b = bucket{minute: minute}
+	}
+	b.total++
+	if err != nil || duration > objective.Latency {
+		b.bad++
+	}
+}
+
+// Start times an operation; call the returned function with its outcome
+func (t *Tracker) Start(operation string) func(err error) {
+	started := t.clock.Now()
+	return func(err error) {
+		t.Record(operation, t.clock.Since(started), err)
+	}
+}
+
+// BurnRate returns how fast the operation spent its error budget over the
+// window: 1 spends exactly the budget over the SLO period. It also returns the
+// number of requests counted.
+func (t *Tracker) BurnRate(operation string, window time.Duration) (float64, int64) {
+	objective, ok := t.objective(operation)
+	if !ok || objective.ErrorBudget() <= 0 {
+		return 0, 0
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	oldest := t.clock.Now().Unix()/int64(bucketSize/time.Second) - int64(window/bucketSize)
+	var total, bad int64
+	for _, b := range t.counts[operation] {
+		if b.minute > oldest {
+			total += b.total
+			bad += b.bad
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(bad) / float64(total) / objective.ErrorBudget(), total
+}
+
+// Evaluate returns the alerts currently firing
+func (t *Tracker) Evaluate() []Alert {
+	var alerts []Alert
+	for _, objective := range t.config.Objectives {
+		for _, rule := range t.config.Rules {
+			long, _ := t.BurnRate(objective.Operation, rule.LongWindow)
+			short, requests := t.BurnRate(objective.Operation, rule.ShortWindow)
+			if long >= rule.BurnRate && short >= rule.BurnRate && requests >= rule.MinRequests {
+				alerts = append(alerts, Alert{Objective: objective, Rule: rule, LongBurnRate: long, ShortBurnRate: short})
+			}
+		}
+	}
+	return alerts
+}
+
+// Notify evaluates the rules and publishes an SLO burn-rate event for each
+// alert that started or stopped firing since the last call
+func (t *Tracker) Notify(ctx context.Context, bus events.Bus, source string) error {
+	current := make(map[string]Alert)
+	for _, alert := range t.Evaluate() {
+		current[alert.key()] = alert
+	}
+
+	t.mutex.Lock()
+	var changes []events.SLOBurnRate
+	for key, alert := range current {
+		if _, ok := t.firing[key]; !ok {
+			changes = append(changes, burnRateEvent(alert, true))
+		}
+	}
+	for key, alert := range t.firing {
+		if _, ok := current[key]; !ok {
+			changes = append(changes, burnRateEvent(alert, false))
+		}
+	}
+	previous := t.firing
+	t.firing = current
+	t.mutex.Unlock()
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Operation+changes[i].Severity < changes[j].Operation+changes[j].Severity
+	})
+	for _, change := range changes {
+		event, err := events.NewEvent(events.TypeSLOBurnRate, source, change)
+		if err == nil {
+			err = bus.Publish(ctx, event)
+		}
+		if err != nil {
+			// Retry the transition on the next evaluation
+			t.mutex.Lock()
+			t.firing = previous
+			t.mutex.Unlock()
+			return fmt.Errorf("failed to publish SLO alert for %s: %w", change.Operation, err)
+		}
+	}
+	return nil
+}
+
+// Run calls Notify every interval until the context is cancelled
+func (t *Tracker) Run(ctx context.Context, bus events.Bus, source string, interval time.Duration) {
+	ticker := t.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if err := t.Notify(ctx, bus, source); err != nil {
+				log.Printf("SLO alerting: %v", err)
+			}
+		}
+	}
+}
+
+func (t *Tracker) objective(operation string) (Objective, bool) {
+	for _, objective := range t.config.Objectives {
+		if objective.Operation == operation {
+			return objective, true
+		}
+	}
+	return Objective{}, false
+}
+
+func burnRateEvent(alert Alert, firing bool) events.SLOBurnRate {
+	return events.SLOBurnRate{
+		Operation:     alert.Objective.Operation,
+		Severity:      alert.Rule.Severity,
+		Firing:        firing,
+		Latency:       alert.Objective.Latency.String(),
+		Target:        alert.Objective.Target,
+		LongWindow:    alert.Rule.LongWindow.String(),
+		ShortWindow:   alert.Rule.ShortWindow.String(),
+		Threshold:     alert.Rule.BurnRate,
+		LongBurnRate:  alert.LongBurnRate,
+		ShortBurnRate: alert.ShortBurnRate,
+	}
+}
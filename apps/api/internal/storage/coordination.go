@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// DefaultDatabasePath returns DATABASE_PATH or a local keystone.db
+func DefaultDatabasePath() string {
+	if path := os.Getenv("DATABASE_PATH"); path != "" {
+		return path
+	}
+	return "keystone.db"
+}
+
+// OpenDatabase opens a SQLite database configured for access from several processes
+func OpenDatabase(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// MigrateWithLock applies pending migrations while holding the migration lock, so
+// API and worker processes sharing a database never race on the same migration.
+// A lock older than staleAfter is assumed to belong to a crashed process and is taken over.
+func (m *MigrationManager) MigrateWithLock(ctx context.Context, owner string, staleAfter time.Duration) error {
+	if err := m.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize migrations: %w", err)
+	}
+
+	if err := m.acquireLock(ctx, owner, staleAfter); err != nil {
+		return err
+	}
+	defer m.releaseLock(owner)
+
+	return m.Migrate()
+}
+
+// acquireLock polls until the single lock row can be claimed for owner
+func (m *MigrationManager) acquireLock(ctx context.Context, owner string, staleAfter time.Duration) error {
+	claimSQL := fmt.Sprintf(`
+		INSERT INTO %s_lock (id, owner, acquired_at)
+		VALUES (1, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			owner = excluded.owner,
+			acquired_at = excluded.acquired_at
+		WHERE acquired_at < ?
+	`, m.tableName)
+
+	for {
+		now := time.Now().UTC()
+		result, err := m.db.ExecContext(ctx, claimSQL, owner, now, now.Add(-staleAfter))
+		if err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if claimed, _ := result.RowsAffected(); claimed > 0 {
+			return nil
+		}
+
+		select {
+		case <-time.After(250 * time.Millisecond):
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for migration lock: %w", ctx.Err())
+		}
+	}
+}
+
+// releaseLock removes the lock row if it is still held by owner
+func (m *MigrationManager) releaseLock(owner string) {
+	releaseSQL := fmt.Sprintf("DELETE FROM %s_lock WHERE id = 1 AND owner = ?", m.tableName)
+	if _, err := m.db.Exec(releaseSQL, owner); err != nil {
+		log.Printf("Failed to release migration lock for %s: %v", owner, err)
+	}
+}
+
+// WaitForSchema blocks until every migration on disk has been applied, for processes
+// that share the database but leave migrating to another process
+func (m *MigrationManager) WaitForSchema(ctx context.Context, interval time.Duration) error {
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	if len(migrations) == 0 {
+		return nil
+	}
+	target := migrations[len(migrations)-1].Version
+
+	for {
+		// The tracking table may not exist until the migrating process initializes it
+		version, err := m.GetCurrentVersion()
+		if err == nil && version >= target {
+			return nil
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for schema version %d (current %d): %w", target, version, ctx.Err())
+		}
+	}
+}
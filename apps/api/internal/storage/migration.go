@@ -4,7 +4,6 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -54,7 +53,20 @@ func (m *MigrationManager) Initialize() error {
 		)
 	`, m.tableName)
 
-	_, err := m.db.Exec(createTableSQL)
+	if _, err := m.db.Exec(createTableSQL); err != nil {
+		return err
+	}
+
+	// Single-row lock so processes sharing the database migrate one at a time
+	createLockSQL := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s_lock (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			owner TEXT NOT NULL,
+			acquired_at DATETIME NOT NULL
+		)
+	`, m.tableName)
+
+	_, err := m.db.Exec(createLockSQL)
 	return err
 }
 
@@ -84,8 +96,16 @@ func (m *MigrationManager) LoadMigrations() ([]Migration, error) {
 
 // parseMigrationFile parses a migration file and extracts up/down SQL
 func (m *MigrationManager) parseMigrationFile(filePath string) (Migration, error) {
-	filename := filepath.Base(filePath)
-	
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return Migration{}, fmt.Errorf("failed to read migration file: %w", err)
+	}
+
+	return ParseMigration(filepath.Base(filePath), content)
+}
+
+// ParseMigration parses a migration from its filename (001_name.sql) and content
+func ParseMigration(filename string, content []byte) (Migration, error) {
 	// Parse version from filename (format: 001_migration_name.sql)
 	parts := strings.SplitN(filename, "_", 2)
 	if len(parts) < 2 {
@@ -93,22 +113,17 @@ func (m *MigrationManager) parseMigrationFile(filePath string) (Migration, error
 	}
 
 	version, err := strconv.Atoi(parts[0])
-	if err != nil {
+	if err != nil || version < 1 {
 		return Migration{}, fmt.Errorf("invalid version in filename: %s", filename)
 	}
 
 	name := strings.TrimSuffix(parts[1], ".sql")
 
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return Migration{}, fmt.Errorf("failed to read migration file: %w", err)
-	}
-
 	// Calculate checksum
-	checksum := m.calculateChecksum(content)
+	checksum := calculateChecksum(content)
 
 	// Parse up and down SQL sections
-	upSQL, downSQL, description := m.parseMigrationContent(string(content))
+	upSQL, downSQL, description := parseMigrationContent(string(content))
 
 	return Migration{
 		Version:     version,
@@ -121,7 +136,7 @@ func (m *MigrationManager) parseMigrationFile(filePath string) (Migration, error
 }
 
 // parseMigrationContent parses migration file content for up/down SQL and description
-func (m *MigrationManager) parseMigrationContent(content string) (upSQL, downSQL, description string) {
+func parseMigrationContent(content string) (upSQL, downSQL, description string) {
 	lines := strings.Split(content, "\n")
 	var currentSection string
 	var upLines, downLines, descLines []string
@@ -165,7 +180,7 @@ func (m *MigrationManager) parseMigrationContent(content string) (upSQL, downSQL
 }
 
 // calculateChecksum calculates SHA256 checksum of migration content
-func (m *MigrationManager) calculateChecksum(content []byte) string {
+func calculateChecksum(content []byte) string {
 	hash := sha256.Sum256(content)
 	return fmt.Sprintf("%x", hash)
 }
@@ -0,0 +1,48 @@
+-- Description: Add GitHub advisory store and sync checkpoint tracking
+
+-- +migrate Up
+CREATE TABLE github_advisories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ghsa_id TEXT UNIQUE NOT NULL,
+    cve_id TEXT,
+    ecosystem TEXT NOT NULL, -- 'go', 'npm', 'pip', 'maven', ...
+    severity TEXT NOT NULL,
+    summary TEXT,
+    published_at DATETIME,
+    advisory_updated_at DATETIME,
+    withdrawn_at DATETIME,
+    raw_data TEXT, -- JSON blob of original advisory
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE sync_checkpoints (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id TEXT UNIQUE NOT NULL, -- e.g. 'backfill:go:2020-01-01'
+    job_type TEXT NOT NULL, -- 'backfill', 'incremental'
+    ecosystem TEXT NOT NULL,
+    window_start DATETIME NOT NULL, -- Start of the window currently being fetched
+    range_end DATETIME NOT NULL, -- End of the overall sync range
+    cursor TEXT, -- Pagination cursor within the current window
+    fetched_count INTEGER NOT NULL DEFAULT 0,
+    request_count INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL, -- 'running', 'waiting', 'interrupted', 'completed', 'failed'
+    last_error TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_github_advisories_cve_id ON github_advisories(cve_id);
+CREATE INDEX idx_github_advisories_ecosystem ON github_advisories(ecosystem);
+CREATE INDEX idx_github_advisories_published ON github_advisories(published_at);
+CREATE INDEX idx_sync_checkpoints_status ON sync_checkpoints(status);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_sync_checkpoints_status;
+DROP INDEX IF EXISTS idx_github_advisories_published;
+DROP INDEX IF EXISTS idx_github_advisories_ecosystem;
+DROP INDEX IF EXISTS idx_github_advisories_cve_id;
+
+DROP TABLE IF EXISTS sync_checkpoints;
+DROP TABLE IF EXISTS github_advisories;
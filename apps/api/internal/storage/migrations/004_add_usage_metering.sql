@@ -0,0 +1,21 @@
+-- Description: Add per-tenant usage metering for chargeback and quotas
+
+-- +migrate Up
+CREATE TABLE usage_records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT NOT NULL,
+    metric TEXT NOT NULL, -- 'github_requests', 'scans', 'verifications', 'advisory_syncs', 'storage_bytes'
+    period_start DATETIME NOT NULL, -- Start of the hourly bucket
+    quantity INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, metric, period_start)
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_usage_records_tenant_period ON usage_records(tenant_id, period_start);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_usage_records_tenant_period;
+
+DROP TABLE IF EXISTS usage_records;
@@ -0,0 +1,30 @@
+-- Description: Add per-tenant quota overrides and tracked artifact accounting
+
+-- +migrate Up
+CREATE TABLE quota_overrides (
+    tenant_id TEXT PRIMARY KEY,
+    scans_per_day INTEGER NOT NULL DEFAULT 0, -- 0 means unlimited
+    verifications_per_day INTEGER NOT NULL DEFAULT 0,
+    tracked_artifacts INTEGER NOT NULL DEFAULT 0,
+    api_requests_per_minute INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE tracked_artifacts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT NOT NULL,
+    artifact TEXT NOT NULL, -- Image reference or artifact name
+    first_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, artifact)
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_tracked_artifacts_tenant ON tracked_artifacts(tenant_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_tracked_artifacts_tenant;
+
+DROP TABLE IF EXISTS tracked_artifacts;
+DROP TABLE IF EXISTS quota_overrides;
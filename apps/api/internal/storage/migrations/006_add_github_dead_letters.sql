@@ -0,0 +1,20 @@
+-- Description: Add a dead-letter store for GitHub requests that exhausted their retries
+
+-- +migrate Up
+CREATE TABLE github_dead_letters (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    request_id TEXT NOT NULL, -- ID the request was enqueued with
+    priority INTEGER NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_error TEXT NOT NULL,
+    enqueued_at DATETIME NOT NULL,
+    failed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_github_dead_letters_failed_at ON github_dead_letters(failed_at);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_github_dead_letters_failed_at;
+
+DROP TABLE IF EXISTS github_dead_letters;
@@ -0,0 +1,20 @@
+-- Description: Store runtime profile bundles captured on demand for diagnosis
+
+-- +migrate Up
+CREATE TABLE diagnostic_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    host TEXT NOT NULL, -- Process the profiles were captured from
+    duration_seconds INTEGER NOT NULL, -- Length of the CPU profile
+    size INTEGER NOT NULL,
+    data BLOB NOT NULL, -- gzipped tar of pprof profiles and runtime stats
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_diagnostic_profiles_created_at ON diagnostic_profiles(created_at);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_diagnostic_profiles_created_at;
+
+DROP TABLE IF EXISTS diagnostic_profiles;
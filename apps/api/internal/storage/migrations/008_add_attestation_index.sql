@@ -0,0 +1,28 @@
+-- Description: Index generated and verified attestations by subject digest
+
+-- +migrate Up
+CREATE TABLE attestation_index (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    attestation_id TEXT NOT NULL, -- SHA-256 of the signature, as in attestation records
+    subject_name TEXT NOT NULL,
+    subject_digest TEXT NOT NULL, -- alg:hex
+    predicate_type TEXT NOT NULL,
+    identity TEXT NOT NULL DEFAULT '',
+    issuer TEXT NOT NULL DEFAULT '',
+    rekor_uuid TEXT NOT NULL DEFAULT '',
+    generated_at DATETIME, -- Set when this deployment signed the attestation
+    verified_at DATETIME, -- Last successful verification
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(attestation_id, subject_digest)
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_attestation_index_subject_digest ON attestation_index(subject_digest);
+CREATE INDEX idx_attestation_index_predicate_type ON attestation_index(predicate_type);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_attestation_index_predicate_type;
+DROP INDEX IF EXISTS idx_attestation_index_subject_digest;
+
+DROP TABLE IF EXISTS attestation_index;
@@ -0,0 +1,16 @@
+-- Description: Track how far the Rekor identity monitor has scanned each log
+
+-- +migrate Up
+CREATE TABLE rekor_monitor_checkpoints (
+    log_url TEXT PRIMARY KEY,
+    next_index INTEGER NOT NULL, -- First log index not yet scanned
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Look up whether a Rekor entry is one this deployment generated
+CREATE INDEX idx_attestation_index_rekor_uuid ON attestation_index(rekor_uuid);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_attestation_index_rekor_uuid;
+
+DROP TABLE IF EXISTS rekor_monitor_checkpoints;
@@ -0,0 +1,14 @@
+-- Description: Pin the first verified signer identity of each artifact for trust-on-first-use verification
+
+-- +migrate Up
+CREATE TABLE identity_pins (
+    scope TEXT PRIMARY KEY, -- Artifact the identity is pinned for, e.g. ghcr.io/owner/app
+    issuer TEXT NOT NULL,
+    identity TEXT NOT NULL, -- Certificate SAN without the workflow ref
+    attestation_id TEXT NOT NULL, -- Attestation the identity was first seen on
+    first_seen DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS identity_pins;
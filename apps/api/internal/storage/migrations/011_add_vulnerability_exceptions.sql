@@ -0,0 +1,48 @@
+-- Description: Add vulnerability exceptions with multi-party approval and an audit trail
+
+-- +migrate Up
+CREATE TABLE vulnerability_exceptions (
+    id TEXT PRIMARY KEY,
+    scope TEXT NOT NULL, -- Artifact or repository the finding is accepted for
+    finding_id TEXT NOT NULL, -- Deterministic finding ID, source:rule:subject
+    severity TEXT NOT NULL, -- Highest severity the acceptance covers
+    reason TEXT NOT NULL,
+    requested_by TEXT NOT NULL,
+    status TEXT NOT NULL, -- 'pending', 'approved', 'rejected', 'revoked'
+    required_approvals INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    decided_at DATETIME
+);
+
+-- Approvers eligible to sign off, resolved from the owners when requested
+CREATE TABLE vulnerability_exception_approvers (
+    exception_id TEXT NOT NULL,
+    approver TEXT NOT NULL,
+    decision TEXT, -- NULL until decided, then 'approved' or 'rejected'
+    comment TEXT,
+    decided_at DATETIME,
+    PRIMARY KEY (exception_id, approver),
+    FOREIGN KEY (exception_id) REFERENCES vulnerability_exceptions(id)
+);
+
+CREATE TABLE vulnerability_exception_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    exception_id TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL, -- 'requested', 'approved', 'rejected', 'revoked', 'activated'
+    detail TEXT,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (exception_id) REFERENCES vulnerability_exceptions(id)
+);
+
+CREATE INDEX idx_vulnerability_exceptions_scope ON vulnerability_exceptions(scope, status);
+CREATE INDEX idx_vulnerability_exception_audit_exception ON vulnerability_exception_audit(exception_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_vulnerability_exception_audit_exception;
+DROP INDEX IF EXISTS idx_vulnerability_exceptions_scope;
+
+DROP TABLE IF EXISTS vulnerability_exception_audit;
+DROP TABLE IF EXISTS vulnerability_exception_approvers;
+DROP TABLE IF EXISTS vulnerability_exceptions;
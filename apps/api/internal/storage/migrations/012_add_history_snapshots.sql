@@ -0,0 +1,20 @@
+-- Description: Retain snapshots of advisories, policies and trust roots for point-in-time evaluation
+
+-- +migrate Up
+CREATE TABLE history_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL, -- 'advisory', 'policy', 'trust_root'
+    key TEXT NOT NULL, -- GHSA ID, policy name or TUF mirror
+    digest TEXT NOT NULL, -- SHA-256 of data, to skip unchanged snapshots
+    data BLOB NOT NULL,
+    recorded_at DATETIME NOT NULL -- When this version took effect
+);
+
+CREATE INDEX idx_history_snapshots_kind_key ON history_snapshots(kind, key, recorded_at);
+CREATE INDEX idx_history_snapshots_kind_time ON history_snapshots(kind, recorded_at);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_history_snapshots_kind_time;
+DROP INDEX IF EXISTS idx_history_snapshots_kind_key;
+
+DROP TABLE IF EXISTS history_snapshots;
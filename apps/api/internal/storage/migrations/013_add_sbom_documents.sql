@@ -0,0 +1,20 @@
+-- Description: Store SBOM documents ingested from object-store drop buckets
+
+-- +migrate Up
+CREATE TABLE sbom_documents (
+    digest TEXT PRIMARY KEY, -- sha256:hex of the document as ingested
+    name TEXT,
+    format TEXT NOT NULL, -- 'cyclonedx' or 'spdx'
+    spec_version TEXT,
+    component_count INTEGER NOT NULL DEFAULT 0,
+    data BLOB NOT NULL,
+    source TEXT NOT NULL, -- Object the document was ingested from
+    ingested_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_sbom_documents_name ON sbom_documents(name, ingested_at);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_sbom_documents_name;
+
+DROP TABLE IF EXISTS sbom_documents;
@@ -0,0 +1,7 @@
+-- Description: Record the SLSA build level of verified provenance in the attestation index
+
+-- +migrate Up
+ALTER TABLE attestation_index ADD COLUMN build_level INTEGER; -- 0-3, NULL for other predicate types
+
+-- +migrate Down
+ALTER TABLE attestation_index DROP COLUMN build_level;
@@ -0,0 +1,166 @@
+package webhooks
+
+import (
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// Event names, as GitHub sends them in the X-GitHub-Event header
+const (
+	EventPing            = "ping"
+	EventPush            = "push"
+	EventRelease         = "release"
+	EventPackage         = "package"
+	EventRegistryPackage = "registry_package" // The same payload as package, for GitHub Packages registries
+	EventWorkflowRun     = "workflow_run"
+)
+
+// Envelope holds what every event carries
+type Envelope struct {
+	Delivery     string     `json:"-"` // The X-GitHub-Delivery ID, unique per delivery and kept on redelivery
+	Action       string     `json:"action,omitempty"`
+	Repository   Repository `json:"repository"`
+	Sender       Account    `json:"sender"`
+	Installation *struct {
+		ID int64 `json:"id"`
+	} `json:"installation,omitempty"` // Set for GitHub App webhooks
+}
+
+// Repository is the repository an event concerns. Unlike the REST API's,
+// push payloads give its timestamps as Unix times, so they're left out.
+type Repository struct {
+	ID            int64   `json:"id"`
+	Name          string  `json:"name"`
+	FullName      string  `json:"full_name"` // owner/repo
+	HTMLURL       string  `json:"html_url"`
+	DefaultBranch string  `json:"default_branch"`
+	Private       bool    `json:"private"`
+	Owner         Account `json:"owner"`
+}
+
+// Account is a user, bot or organization
+type Account struct {
+	Login string `json:"login"`
+	Type  string `json:"type"` // User, Bot or Organization
+}
+
+// Commit is a commit in a push
+type Commit struct {
+	ID        string    `json:"id"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+	URL       string    `json:"url"`
+	Added     []string  `json:"added"`
+	Removed   []string  `json:"removed"`
+	Modified  []string  `json:"modified"`
+}
+
+// PushEvent is one or more commits pushed to a branch or tag
+type PushEvent struct {
+	Envelope
+	Ref        string   `json:"ref"` // e.g. refs/heads/main
+	Before     string   `json:"before"`
+	After      string   `json:"after"` // All zeros when the ref was deleted
+	Created    bool     `json:"created"`
+	Deleted    bool     `json:"deleted"`
+	Forced     bool     `json:"forced"`
+	Commits    []Commit `json:"commits"`
+	HeadCommit *Commit  `json:"head_commit"`
+}
+
+// ReleaseEvent is a release being published, edited or deleted
+type ReleaseEvent struct {
+	Envelope
+	Release github.Release `json:"release"`
+}
+
+// Release actions
+const (
+	ReleasePublished = "published"
+	ReleaseCreated   = "created"
+	ReleaseDeleted   = "deleted"
+)
+
+// PackageEvent is a package version being published or updated
+type PackageEvent struct {
+	Envelope
+	Package struct {
+		ID             int64   `json:"id"`
+		Name           string  `json:"name"`
+		Namespace      string  `json:"namespace"`
+		PackageType    string  `json:"package_type"` // container, npm, maven, ...
+		HTMLURL        string  `json:"html_url"`
+		Owner          Account `json:"owner"`
+		PackageVersion struct {
+			ID                int64  `json:"id"`
+			Version           string `json:"version"`     // The manifest digest for containers
+			PackageURL        string `json:"package_url"` // e.g. ghcr.io/acme/widgets:v1.0.0
+			ContainerMetadata struct {
+				Tag struct {
+					Name   string `json:"name"`
+					Digest string `json:"digest"`
+				} `json:"tag"`
+			} `json:"container_metadata"`
+		} `json:"package_version"`
+	} `json:"package"`
+}
+
+// Package actions
+const (
+	PackagePublished = "published"
+	PackageUpdated   = "updated"
+)
+
+// Image returns the digest reference of a published container image, e.g.
+// ghcr.io/acme/widgets@sha256:..., or "" for other packages
+func (e *PackageEvent) Image() string {
+	version := e.Package.PackageVersion
+	if e.Package.PackageType != "container" || version.PackageURL == "" {
+		return ""
+	}
+	digest := version.ContainerMetadata.Tag.Digest
+	if digest == "" {
+		digest = version.Version
+	}
+	repository := version.PackageURL
+	// Drop the tag or digest the URL ends with
+	if at := strings.LastIndexByte(repository, '@'); at >= 0 {
+		repository = repository[:at]
+	} else if colon := strings.LastIndexByte(repository, ':'); colon > strings.LastIndexByte(repository, '/') {
+		repository = repository[:colon]
+	}
+	return repository + "@" + digest
+}
+
+// WorkflowRunEvent is a workflow run being requested or completing
+type WorkflowRunEvent struct {
+	Envelope
+	WorkflowRun struct {
+		ID         int64     `json:"id"`
+		Name       string    `json:"name"`
+		Path       string    `json:"path"` // e.g. .github/workflows/release.yml
+		Event      string    `json:"event"`
+		Status     string    `json:"status"`
+		Conclusion string    `json:"conclusion"` // Set once completed, e.g. success or failure
+		HeadBranch string    `json:"head_branch"`
+		HeadSHA    string    `json:"head_sha"`
+		RunAttempt int       `json:"run_attempt"`
+		HTMLURL    string    `json:"html_url"`
+		CreatedAt  time.Time `json:"created_at"`
+		UpdatedAt  time.Time `json:"updated_at"`
+	} `json:"workflow_run"`
+	Workflow struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+		Path string `json:"path"`
+	} `json:"workflow"`
+}
+
+// Workflow run actions
+const (
+	WorkflowRunRequested  = "requested"
+	WorkflowRunInProgress = "in_progress"
+	WorkflowRunCompleted  = "completed"
+)
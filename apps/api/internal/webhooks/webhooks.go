@@ -0,0 +1,184 @@
+// Package webhooks receives GitHub webhook deliveries: it checks each
+// delivery's X-Hub-Signature-256 against the shared secret, decodes push,
+// release, package and workflow_run events into typed structs and passes
+// them to the handlers registered for them, e.g. to rescan a newly published
+// image.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// SecretEnv is the environment variable holding the webhook secret
+const SecretEnv = "KEYSTONE_WEBHOOK_SECRET"
+
+// MaxPayloadSize is the largest payload GitHub delivers
+const MaxPayloadSize = 25 << 20
+
+// signaturePrefix starts the X-Hub-Signature-256 header's value
+const signaturePrefix = "sha256="
+
+// ErrInvalidSignature is returned for deliveries not signed with the secret
+var ErrInvalidSignature = errors.New("webhook signature doesn't match the payload")
+
+// Verify checks an X-Hub-Signature-256 header: the payload's HMAC-SHA256
+// under the secret, hex encoded after "sha256="
+func Verify(secret, payload []byte, signature string) error {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Sign returns the X-Hub-Signature-256 header GitHub sends with a payload
+func Sign(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Handler types, one per event
+type (
+	PushHandler        func(ctx context.Context, event *PushEvent) error
+	ReleaseHandler     func(ctx context.Context, event *ReleaseEvent) error
+	PackageHandler     func(ctx context.Context, event *PackageEvent) error
+	WorkflowRunHandler func(ctx context.Context, event *WorkflowRunEvent) error
+)
+
+// Receiver serves GitHub webhook deliveries. Handlers are registered before
+// it starts serving, and run in the order registered.
+type Receiver struct {
+	secret []byte
+
+	push        []PushHandler
+	release     []ReleaseHandler
+	pkg         []PackageHandler
+	workflowRun []WorkflowRunHandler
+}
+
+// NewReceiver creates a receiver accepting deliveries signed with the secret
+func NewReceiver(secret string) (*Receiver, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("webhook secret is required; unsigned deliveries could come from anyone")
+	}
+	return &Receiver{secret: []byte(secret)}, nil
+}
+
+// OnPush registers a handler for push events
+func (r *Receiver) OnPush(handler PushHandler) { r.push = append(r.push, handler) }
+
+// OnRelease registers a handler for release events
+func (r *Receiver) OnRelease(handler ReleaseHandler) { r.release = append(r.release, handler) }
+
+// OnPackage registers a handler for package and registry_package events
+func (r *Receiver) OnPackage(handler PackageHandler) { r.pkg = append(r.pkg, handler) }
+
+// OnWorkflowRun registers a handler for workflow_run events
+func (r *Receiver) OnWorkflowRun(handler WorkflowRunHandler) {
+	r.workflowRun = append(r.workflowRun, handler)
+}
+
+// ServeHTTP verifies and dispatches a delivery. A failed handler fails the
+// delivery with a 500, so it can be redelivered from GitHub's settings.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, req.Body, MaxPayloadSize))
+	if err != nil {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := Verify(r.secret, payload, req.Header.Get("X-Hub-Signature-256")); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	event := req.Header.Get("X-GitHub-Event")
+	if event == "" {
+		http.Error(w, "X-GitHub-Event header is required", http.StatusBadRequest)
+		return
+	}
+
+	delivery := req.Header.Get("X-GitHub-Delivery")
+	handled, err := r.Dispatch(req.Context(), event, delivery, payload)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr) || errors.As(err, &typeErr):
+		http.Error(w, fmt.Sprintf("invalid %s payload: %v", event, err), http.StatusBadRequest)
+	case err != nil:
+		log.Printf("Webhook delivery %s (%s) failed: %v", delivery, event, err)
+		http.Error(w, "handler failed", http.StatusInternalServerError)
+	case !handled:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Dispatch decodes a verified payload and runs the event's handlers,
+// reporting whether any ran. Events nothing handles, including ping, are
+// acknowledged without being decoded.
+func (r *Receiver) Dispatch(ctx context.Context, event, delivery string, payload []byte) (bool, error) {
+	switch event {
+	case EventPush:
+		return dispatch(ctx, delivery, payload, r.push)
+	case EventRelease:
+		return dispatch(ctx, delivery, payload, r.release)
+	case EventPackage, EventRegistryPackage:
+		return dispatch(ctx, delivery, payload, r.pkg)
+	case EventWorkflowRun:
+		return dispatch(ctx, delivery, payload, r.workflowRun)
+	default:
+		return false, nil
+	}
+}
+
+// envelope lets dispatch set the delivery ID on any event
+type envelope interface {
+	setDelivery(string)
+}
+
+func (e *Envelope) setDelivery(delivery string) { e.Delivery = delivery }
+
+// dispatch decodes the payload as an E and runs the handlers on it in turn,
+// stopping at the first failure
+func dispatch[E any, P interface {
+	*E
+	envelope
+}, H ~func(context.Context, P) error](ctx context.Context, delivery string, payload []byte, handlers []H) (bool, error) {
+	if len(handlers) == 0 {
+		return false, nil
+	}
+	event := P(new(E))
+	if err := json.Unmarshal(payload, event); err != nil {
+		return false, err
+	}
+	event.setDelivery(delivery)
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
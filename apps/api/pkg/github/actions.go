@@ -0,0 +1,246 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Workflow run statuses
+const (
+	WorkflowRunQueued     = "queued"
+	WorkflowRunInProgress = "in_progress"
+	WorkflowRunCompleted  = "completed"
+)
+
+// ErrArtifactExpired is returned downloading an artifact past its retention
+var ErrArtifactExpired = errors.New("workflow artifact expired")
+
+// WorkflowRun is an attempt of a GitHub Actions workflow run
+type WorkflowRun struct {
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	WorkflowID   int64      `json:"workflow_id"`
+	Path         string     `json:"path"` // e.g. .github/workflows/release.yml
+	HeadBranch   string     `json:"head_branch"`
+	HeadSHA      string     `json:"head_sha"`
+	Event        string     `json:"event"`
+	Status       string     `json:"status"`
+	Conclusion   string     `json:"conclusion"` // Empty until completed
+	RunNumber    int        `json:"run_number"`
+	RunAttempt   int        `json:"run_attempt"`
+	HTMLURL      string     `json:"html_url"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	RunStartedAt *time.Time `json:"run_started_at"`
+	Actor        struct {
+		Login string `json:"login"`
+	} `json:"actor"`
+	Repository struct {
+		ID       int64  `json:"id"`
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	HeadRepository struct {
+		ID       int64  `json:"id"`
+		FullName string `json:"full_name"`
+	} `json:"head_repository"` // The fork a pull request run built, if any
+}
+
+// WorkflowPath returns the path of the run's workflow file, without the ref
+// GitHub appends for reusable and dynamic workflows
+func (r WorkflowRun) WorkflowPath() string {
+	path, _, _ := strings.Cut(r.Path, "@")
+	return path
+}
+
+// WorkflowRunQuery holds filters for listing a repository's workflow runs
+type WorkflowRunQuery struct {
+	Workflow string // Workflow file name or ID, e.g. release.yml; all workflows when empty
+	Branch   string
+	Event    string // push, pull_request, release, ...
+	Status   string // A status or conclusion, e.g. completed or success
+	HeadSHA  string
+	Created  string // Date or range, e.g. >=2024-03-01
+	PerPage  int
+	MaxPages int // Pages fetched at most; the configured MaxPages when zero
+}
+
+// Artifact is a file set a workflow run uploaded
+type Artifact struct {
+	ID                 int64      `json:"id"`
+	Name               string     `json:"name"`
+	SizeInBytes        int64      `json:"size_in_bytes"`
+	Digest             string     `json:"digest"` // sha256:<hex> of the zip, for artifacts uploaded with upload-artifact v4
+	ArchiveDownloadURL string     `json:"archive_download_url"`
+	Expired            bool       `json:"expired"`
+	CreatedAt          time.Time  `json:"created_at"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+	WorkflowRun        struct {
+		ID         int64  `json:"id"`
+		HeadBranch string `json:"head_branch"`
+		HeadSHA    string `json:"head_sha"`
+	} `json:"workflow_run"`
+}
+
+// ListWorkflowRuns fetches a repository's workflow runs matching the query,
+// newest first, following pages up to the query's MaxPages
+func (c *Client) ListWorkflowRuns(ctx context.Context, owner, repo string, query WorkflowRunQuery) ([]WorkflowRun, error) {
+	requestURL := fmt.Sprintf("%s/repos/%s/%s/actions/runs", c.config.BaseURL, owner, repo)
+	if query.Workflow != "" {
+		requestURL = fmt.Sprintf("%s/repos/%s/%s/actions/workflows/%s/runs", c.config.BaseURL, owner, repo, url.PathEscape(query.Workflow))
+	}
+	params := url.Values{}
+	if query.Branch != "" {
+		params.Set("branch", query.Branch)
+	}
+	if query.Event != "" {
+		params.Set("event", query.Event)
+	}
+	if query.Status != "" {
+		params.Set("status", query.Status)
+	}
+	if query.HeadSHA != "" {
+		params.Set("head_sha", query.HeadSHA)
+	}
+	if query.Created != "" {
+		params.Set("created", query.Created)
+	}
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+	maxPages := query.MaxPages
+	if maxPages == 0 {
+		maxPages = c.config.MaxPages
+	}
+
+	runs := []WorkflowRun{}
+	options := PageOptions{PerPage: query.PerPage, MaxPages: maxPages, ItemsKey: "workflow_runs"}
+	if err := c.Paginate(requestURL, options).All(ctx, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// GetWorkflowRun fetches a workflow run's latest attempt
+func (c *Client) GetWorkflowRun(ctx context.Context, owner, repo string, runID int64) (*WorkflowRun, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/actions/runs/%d", c.config.BaseURL, owner, repo, runID)
+	return doRequest[WorkflowRun](ctx, c, "GET", url, nil, http.StatusOK)
+}
+
+// GetWorkflowRunAttempt fetches an attempt of a workflow run; re-runs get
+// attempts after the first
+func (c *Client) GetWorkflowRunAttempt(ctx context.Context, owner, repo string, runID int64, attempt int) (*WorkflowRun, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/actions/runs/%d/attempts/%d", c.config.BaseURL, owner, repo, runID, attempt)
+	return doRequest[WorkflowRun](ctx, c, "GET", url, nil, http.StatusOK)
+}
+
+// ListWorkflowRunArtifacts fetches the artifacts a workflow run uploaded,
+// following pages up to the configured MaxPages
+func (c *Client) ListWorkflowRunArtifacts(ctx context.Context, owner, repo string, runID int64) ([]Artifact, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/actions/runs/%d/artifacts", c.config.BaseURL, owner, repo, runID)
+
+	artifacts := []Artifact{}
+	options := PageOptions{PerPage: MaxPerPage, MaxPages: c.config.MaxPages, ItemsKey: "artifacts"}
+	if err := c.Paginate(url, options).All(ctx, &artifacts); err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
+
+// DownloadArtifact streams an artifact's zip archive. The caller closes the
+// returned reader; archive/zip needs it buffered, or io.ReaderAt, to list
+// the files.
+func (c *Client) DownloadArtifact(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/actions/artifacts/%d/zip", c.config.BaseURL, owner, repo, id)
+
+	// GitHub redirects to storage that takes no credentials; the HTTP
+	// client drops the Authorization header on leaving the API's host
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusGone:
+		resp.Body.Close()
+		return nil, fmt.Errorf("artifact %d: %w", id, ErrArtifactExpired)
+	default:
+		resp.Body.Close()
+		return nil, &StatusError{Method: "GET", URL: url, StatusCode: resp.StatusCode}
+	}
+}
+
+// RunClaim is what provenance claims about the workflow run that built an
+// artifact, e.g. from its github_run_id and github_run_attempt parameters
+type RunClaim struct {
+	RunID        int64
+	RunAttempt   int    // The first attempt when zero
+	HeadSHA      string // Checked when set
+	WorkflowPath string // Checked when set, e.g. .github/workflows/release.yml
+	Event        string // Checked when set
+}
+
+// ParseRunClaim parses a claimed run ID and attempt, as provenance records
+// them
+func ParseRunClaim(runID, attempt string) (RunClaim, error) {
+	id, err := strconv.ParseInt(runID, 10, 64)
+	if err != nil || id <= 0 {
+		return RunClaim{}, fmt.Errorf("invalid workflow run ID %q", runID)
+	}
+	claim := RunClaim{RunID: id}
+	if attempt != "" {
+		if claim.RunAttempt, err = strconv.Atoi(attempt); err != nil || claim.RunAttempt <= 0 {
+			return RunClaim{}, fmt.Errorf("invalid workflow run attempt %q", attempt)
+		}
+	}
+	return claim, nil
+}
+
+// RunMismatchError is returned when a workflow run doesn't match what was
+// claimed about it
+type RunMismatchError struct {
+	RunID      int64
+	RunAttempt int
+	Fields     []string // Each field that differs, with the claimed and actual values
+}
+
+func (e *RunMismatchError) Error() string {
+	return fmt.Sprintf("workflow run %d attempt %d doesn't match its claim: %s", e.RunID, e.RunAttempt, strings.Join(e.Fields, "; "))
+}
+
+// VerifyRunClaim fetches the claimed run attempt from the repository and
+// checks it matches the claim, so provenance can't cite a run that didn't
+// build the commit or workflow it says. The run is returned even when it
+// doesn't match; a run that doesn't exist in the repository is a
+// *StatusError with status 404.
+func (c *Client) VerifyRunClaim(ctx context.Context, owner, repo string, claim RunClaim) (*WorkflowRun, error) {
+	attempt := claim.RunAttempt
+	if attempt == 0 {
+		attempt = 1
+	}
+	run, err := c.GetWorkflowRunAttempt(ctx, owner, repo, claim.RunID, attempt)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	mismatch := func(field, claimed, actual string) {
+		if claimed != "" && claimed != actual {
+			fields = append(fields, fmt.Sprintf("%s %q, actually %q", field, claimed, actual))
+		}
+	}
+	mismatch("head_sha", claim.HeadSHA, run.HeadSHA)
+	mismatch("workflow", claim.WorkflowPath, run.WorkflowPath())
+	mismatch("event", claim.Event, run.Event)
+	if len(fields) > 0 {
+		return run, &RunMismatchError{RunID: claim.RunID, RunAttempt: attempt, Fields: fields}
+	}
+	return run, nil
+}
@@ -0,0 +1,331 @@
+// Package actionscache stores cache entries in the GitHub Actions cache
+// service, so the hierarchical cache's L3 level is shared by the jobs of a
+// repository. It implements cache.L3CacheClient.
+//
+// The service's entries are immutable and expire only when unused for a
+// week, so each Set saves a new entry under the key's prefix and Get restores
+// the newest entry with that prefix. Entries carry their own expiry, and
+// Delete saves a tombstone.
+package actionscache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+)
+
+// Environment variables the Actions runner sets for the cache service. Only
+// actions see them; a run step must export them, e.g. with
+// crazy-max/ghaction-github-runtime.
+const (
+	CacheURLEnv     = "ACTIONS_CACHE_URL"
+	RuntimeTokenEnv = "ACTIONS_RUNTIME_TOKEN"
+)
+
+// DefaultChunkSize is how much of an entry each upload or download request
+// carries
+const DefaultChunkSize = 32 << 20
+
+const (
+	apiVersion     = "6.0-preview.1"
+	maxKeyLength   = 512 // The service's limit
+	maxScopedKey   = 256 // Longer keys are hashed, leaving room for the prefix and timestamp
+	entryHeaderLen = 9   // Kind and expiry
+)
+
+// Entry kinds, the first byte of a stored entry
+const (
+	kindValue     byte = 1
+	kindTombstone byte = 2
+)
+
+// ErrNotFound is returned by Get for a key with no live entry
+var ErrNotFound = errors.New("actions cache entry not found")
+
+// ErrNoCacheService is returned by ConfigFromEnv outside an Actions job
+var ErrNoCacheService = errors.New("no actions cache service: " + CacheURLEnv + " and " + RuntimeTokenEnv + " are unset")
+
+// Config configures the cache client
+type Config struct {
+	URL        string         // The cache service root, from ACTIONS_CACHE_URL
+	Token      *secret.String // The runtime token, from ACTIONS_RUNTIME_TOKEN
+	Scope      string         // Keys are only visible within their scope; "keystone" when empty
+	ChunkSize  int            // DefaultChunkSize when zero
+	HTTPClient *http.Client   // http.DefaultClient when nil
+	Clock      clock.Clock    // Real time when nil
+}
+
+// ConfigFromEnv returns a config for the Actions job's cache service, or
+// ErrNoCacheService outside one
+func ConfigFromEnv() (Config, error) {
+	cacheURL, token := os.Getenv(CacheURLEnv), os.Getenv(RuntimeTokenEnv)
+	if cacheURL == "" || token == "" {
+		return Config{}, ErrNoCacheService
+	}
+	return Config{URL: cacheURL, Token: secret.New(token)}, nil
+}
+
+// Client reads and writes entries in the Actions cache service
+type Client struct {
+	config     Config
+	httpClient *http.Client
+	clock      clock.Clock
+	version    string // Hash of the scope; the service only restores entries saved with the same version
+}
+
+// NewClient creates a cache client
+func NewClient(config Config) *Client {
+	if !strings.HasSuffix(config.URL, "/") {
+		config.URL += "/"
+	}
+	if config.Scope == "" {
+		config.Scope = "keystone"
+	}
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = DefaultChunkSize
+	}
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	version := sha256.Sum256([]byte("keystone-l3|" + config.Scope))
+	return &Client{
+		config:     config,
+		httpClient: httpClient,
+		clock:      clock.OrReal(config.Clock),
+		version:    hex.EncodeToString(version[:]),
+	}
+}
+
+// Get returns the data most recently set for key, or ErrNotFound if there is
+// none, it expired or it was deleted
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	location, err := c.lookup(ctx, c.prefix(key))
+	if err != nil {
+		return nil, err
+	}
+	entry, err := c.download(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	if len(entry) < entryHeaderLen {
+		return nil, fmt.Errorf("actions cache entry for %s is truncated", key)
+	}
+	expiry := int64(binary.BigEndian.Uint64(entry[1:entryHeaderLen]))
+	if entry[0] != kindValue || c.clock.Now().UnixNano() >= expiry {
+		return nil, ErrNotFound
+	}
+	return entry[entryHeaderLen:], nil
+}
+
+// Set saves data for key until ttl passes; a ttl of zero keeps it until the
+// service evicts it
+func (c *Client) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	expiry := int64(math.MaxInt64)
+	if ttl > 0 {
+		expiry = c.clock.Now().Add(ttl).UnixNano()
+	}
+	return c.save(ctx, key, kindValue, expiry, data)
+}
+
+// Delete hides the data set for key; entries can't be removed, so a
+// tombstone supersedes them
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.save(ctx, key, kindTombstone, 0, nil)
+}
+
+// save stores an entry as a new cache entry under the key's prefix
+func (c *Client) save(ctx context.Context, key string, kind byte, expiry int64, data []byte) error {
+	entry := make([]byte, entryHeaderLen, entryHeaderLen+len(data))
+	entry[0] = kind
+	binary.BigEndian.PutUint64(entry[1:], uint64(expiry))
+	entry = append(entry, data...)
+
+	id, err := c.reserve(ctx, c.prefix(key)+strconv.FormatInt(c.clock.Now().UnixNano(), 10), len(entry))
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(entry); start += c.config.ChunkSize {
+		end := min(start+c.config.ChunkSize, len(entry))
+		if err := c.upload(ctx, id, start, entry[start:end]); err != nil {
+			return err
+		}
+	}
+	return c.commit(ctx, id, len(entry))
+}
+
+// prefix returns the service key prefix of a cache key's entries. Keys are
+// escaped, so no key's prefix is a prefix of another's, and overlong keys
+// are hashed.
+func (c *Client) prefix(key string) string {
+	escaped := url.QueryEscape(key)
+	if len(escaped) > maxScopedKey {
+		sum := sha256.Sum256([]byte(key))
+		escaped = "sha256-" + hex.EncodeToString(sum[:])
+	}
+	return c.config.Scope + "/" + escaped + "/"
+}
+
+// lookup returns the download location of the newest entry with the key
+// prefix
+func (c *Client) lookup(ctx context.Context, prefix string) (string, error) {
+	params := url.Values{"keys": {prefix}, "version": {c.version}}
+	resp, err := c.do(ctx, "GET", "cache?"+params.Encode(), nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent, http.StatusNotFound:
+		return "", ErrNotFound
+	default:
+		return "", fmt.Errorf("actions cache lookup returned status %d", resp.StatusCode)
+	}
+	var found struct {
+		CacheKey        string `json:"cacheKey"`
+		ArchiveLocation string `json:"archiveLocation"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&found); err != nil {
+		return "", err
+	}
+	if found.ArchiveLocation == "" {
+		return "", ErrNotFound
+	}
+	return found.ArchiveLocation, nil
+}
+
+// download fetches an entry from its location a chunk at a time
+func (c *Client) download(ctx context.Context, location string) ([]byte, error) {
+	var entry []byte
+	for {
+		req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
+		if err != nil {
+			return nil, err
+		}
+		// The location is pre-signed storage, which takes no credentials
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", len(entry), len(entry)+c.config.ChunkSize-1))
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		chunk, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			// Storage ignoring the range sends it all
+			return chunk, nil
+		case http.StatusPartialContent:
+		case http.StatusRequestedRangeNotSatisfiable:
+			return entry, nil
+		default:
+			return nil, fmt.Errorf("actions cache download returned status %d", resp.StatusCode)
+		}
+		entry = append(entry, chunk...)
+		// Content-Range: bytes <start>-<end>/<size>
+		_, size, _ := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		if total, err := strconv.Atoi(size); (err == nil && len(entry) >= total) || len(chunk) == 0 {
+			return entry, nil
+		}
+	}
+}
+
+// reserve reserves a new entry of size bytes under key, returning its ID
+func (c *Client) reserve(ctx context.Context, key string, size int) (int64, error) {
+	if len(key) > maxKeyLength {
+		return 0, fmt.Errorf("actions cache key %s is longer than %d characters", key, maxKeyLength)
+	}
+	body, err := json.Marshal(map[string]interface{}{"key": key, "version": c.version, "cacheSize": size})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.do(ctx, "POST", "caches", bytes.NewReader(body), http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusConflict:
+		return 0, fmt.Errorf("actions cache key %s is already reserved", key)
+	default:
+		return 0, fmt.Errorf("actions cache reservation returned status %d", resp.StatusCode)
+	}
+	var reserved struct {
+		CacheID int64 `json:"cacheId"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reserved); err != nil {
+		return 0, err
+	}
+	return reserved.CacheID, nil
+}
+
+// upload sends a chunk of a reserved entry starting at offset start
+func (c *Client) upload(ctx context.Context, id int64, start int, chunk []byte) error {
+	header := http.Header{
+		"Content-Type":  {"application/octet-stream"},
+		"Content-Range": {fmt.Sprintf("bytes %d-%d/*", start, start+len(chunk)-1)},
+	}
+	resp, err := c.do(ctx, "PATCH", fmt.Sprintf("caches/%d", id), bytes.NewReader(chunk), header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("actions cache upload returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// commit finalizes an uploaded entry, making it restorable
+func (c *Client) commit(ctx context.Context, id int64, size int) error {
+	body, err := json.Marshal(map[string]int{"size": size})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, "POST", fmt.Sprintf("caches/%d", id), bytes.NewReader(body), http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("actions cache commit returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// do sends an authenticated request to the cache service's API
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.config.URL+"_apis/artifactcache/"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json;api-version="+apiVersion)
+	req.Header.Set("Authorization", "Bearer "+c.config.Token.Reveal())
+	return c.httpClient.Do(req)
+}
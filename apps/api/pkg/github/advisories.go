@@ -0,0 +1,82 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// AdvisoryQuery holds filters for the global advisories endpoint
+type AdvisoryQuery struct {
+	Ecosystem string // go, npm, pip, maven, ...
+	Published string // Date or range, e.g. 2020-01-01..2020-01-31
+	Updated   string // Date or range for updated_at, e.g. >=2024-03-01T10:00:00Z
+	Sort      string // published (the default) or updated
+	Direction string // desc (the default) or asc
+	PerPage   int
+	After     string // Cursor returned by a previous page
+}
+
+// AdvisoryPage is one page of global advisories
+type AdvisoryPage struct {
+	Advisories []Advisory
+	NextCursor string // Empty when there are no more pages
+}
+
+// ListAdvisories fetches a single page of global security advisories using cursor pagination
+func (c *Client) ListAdvisories(ctx context.Context, query AdvisoryQuery) (*AdvisoryPage, error) {
+	params := url.Values{}
+	if query.Ecosystem != "" {
+		params.Set("ecosystem", query.Ecosystem)
+	}
+	if query.Published != "" {
+		params.Set("published", query.Published)
+	}
+	if query.Updated != "" {
+		params.Set("updated", query.Updated)
+	}
+	if query.Sort != "" {
+		params.Set("sort", query.Sort)
+	}
+	if query.Direction != "" {
+		params.Set("direction", query.Direction)
+	}
+	if query.PerPage > 0 {
+		params.Set("per_page", strconv.Itoa(query.PerPage))
+	}
+	if query.After != "" {
+		params.Set("after", query.After)
+	}
+
+	requestURL := fmt.Sprintf("%s/advisories?%s", c.config.BaseURL, params.Encode())
+
+	resp, err := c.makeRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("advisories API returned status %d", resp.StatusCode)
+	}
+
+	page := &AdvisoryPage{}
+	if err := json.NewDecoder(resp.Body).Decode(&page.Advisories); err != nil {
+		return nil, err
+	}
+	page.NextCursor = nextCursor(resp.Header.Get("Link"))
+
+	return page, nil
+}
+
+// nextCursor extracts the "after" cursor from the rel="next" entry of a Link header
+func nextCursor(link string) string {
+	next, err := url.Parse(linkURL(link, "next"))
+	if err != nil {
+		return ""
+	}
+	return next.Query().Get("after")
+}
@@ -0,0 +1,314 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+)
+
+// CredentialKind is what a client authenticates as
+type CredentialKind string
+
+const (
+	CredentialApp           CredentialKind = "app"          // A GitHub App installation
+	CredentialPAT           CredentialKind = "pat"          // A personal access token
+	CredentialWorkflowToken CredentialKind = "github-token" // The GITHUB_TOKEN of an Actions job
+)
+
+// Environment variables ConfigFromEnv selects credentials from, in order of
+// precedence
+const (
+	AppIDEnv             = "KEYSTONE_GITHUB_APP_ID"
+	AppInstallationIDEnv = "KEYSTONE_GITHUB_APP_INSTALLATION_ID"
+	AppPrivateKeyEnv     = "KEYSTONE_GITHUB_APP_PRIVATE_KEY"      // PEM-encoded RSA key
+	AppPrivateKeyPathEnv = "KEYSTONE_GITHUB_APP_PRIVATE_KEY_PATH" // File holding it, instead
+	PATEnv               = "KEYSTONE_GITHUB_PAT"                  // Comma-separated to pool several accounts' tokens
+	WorkflowTokenEnv     = "GITHUB_TOKEN"
+)
+
+// ErrNoCredentials is returned by ConfigFromEnv when no credentials are set
+var ErrNoCredentials = errors.New("no GitHub credentials: set GITHUB_TOKEN, " + PATEnv + " or the " + AppIDEnv + " app settings")
+
+// workflowTokenRateLimitThreshold is the rate limit threshold of GITHUB_TOKEN,
+// which is limited to 1000 requests an hour per repository
+const workflowTokenRateLimitThreshold = 200
+
+// appJWTLifetime is how long app JWTs are valid; GitHub allows 10 minutes
+const appJWTLifetime = 9 * time.Minute
+
+// installationTokenRefreshMargin is how long before expiry an installation
+// token is replaced, so no request starts with one about to lapse
+const installationTokenRefreshMargin = 5 * time.Minute
+
+// AppConfig identifies a GitHub App installation to authenticate as
+type AppConfig struct {
+	AppID          int64
+	InstallationID int64
+	PrivateKey     *rsa.PrivateKey
+	Permissions    map[string]string // Narrows the installation token, e.g. {"security_events": "read"}; the installation's when empty
+}
+
+// ConfigFromEnv returns DefaultConfig authenticated with the job's
+// credentials: a GitHub App installation when its settings are set, then a
+// personal access token, then the workflow's GITHUB_TOKEN. It returns
+// ErrNoCredentials when none are set. The API root is taken from
+// KEYSTONE_GITHUB_API_URL or GITHUB_API_URL, for GitHub Enterprise Server.
+func ConfigFromEnv() (Config, error) {
+	config, err := credentialsFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+	if config.BaseURL, err = baseURLFromEnv(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// credentialsFromEnv returns DefaultConfig with the job's credentials
+func credentialsFromEnv() (Config, error) {
+	if os.Getenv(AppIDEnv) != "" {
+		app, err := appConfigFromEnv()
+		if err != nil {
+			return Config{}, err
+		}
+		config := DefaultConfig("")
+		config.App = app
+		return config, nil
+	}
+	if tokens := splitTokens(os.Getenv(PATEnv)); len(tokens) > 0 {
+		config := DefaultConfig(tokens[0])
+		for _, token := range tokens[1:] {
+			config.Tokens = append(config.Tokens, secret.New(token))
+		}
+		return config, nil
+	}
+	if token := os.Getenv(WorkflowTokenEnv); token != "" {
+		config := DefaultConfig(token)
+		config.TokenKind = CredentialWorkflowToken
+		config.RateLimitThreshold = workflowTokenRateLimitThreshold
+		return config, nil
+	}
+	return Config{}, ErrNoCredentials
+}
+
+// splitTokens splits a comma-separated list of tokens, dropping empty ones
+func splitTokens(value string) []string {
+	var tokens []string
+	for _, token := range strings.Split(value, ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// appConfigFromEnv reads the GitHub App settings
+func appConfigFromEnv() (*AppConfig, error) {
+	appID, err := strconv.ParseInt(os.Getenv(AppIDEnv), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", AppIDEnv, err)
+	}
+	installationID, err := strconv.ParseInt(os.Getenv(AppInstallationIDEnv), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", AppInstallationIDEnv, err)
+	}
+
+	keyPEM := []byte(os.Getenv(AppPrivateKeyEnv))
+	if len(keyPEM) == 0 {
+		path := os.Getenv(AppPrivateKeyPathEnv)
+		if path == "" {
+			return nil, fmt.Errorf("%s or %s must be set", AppPrivateKeyEnv, AppPrivateKeyPathEnv)
+		}
+		if keyPEM, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read app private key: %w", err)
+		}
+	}
+	key, err := ParseAppPrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &AppConfig{AppID: appID, InstallationID: installationID, PrivateKey: key}, nil
+}
+
+// ParseAppPrivateKey parses the PEM-encoded RSA key GitHub generates for an
+// app, in PKCS #1 or PKCS #8 form
+func ParseAppPrivateKey(keyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("app private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse app private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("app private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// AppJWT returns a JWT authenticating as the app itself, for the app
+// endpoints that mint installation tokens. It's backdated a minute to allow
+// for clock drift.
+func AppJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign app JWT: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// installationTokens mints installation tokens and caches the current one
+// until shortly before it expires
+type installationTokens struct {
+	config  AppConfig
+	baseURL string
+	client  *http.Client
+	clock   clock.Clock
+
+	mutex  sync.Mutex
+	token  *secret.String
+	expiry time.Time
+}
+
+// get returns the cached installation token, or mints a new one
+func (t *installationTokens) get(ctx context.Context) (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.token.Empty() && t.expiry.Sub(t.clock.Now()) > installationTokenRefreshMargin {
+		return t.token.Reveal(), nil
+	}
+	token, expiry, err := t.mint(ctx)
+	if err != nil {
+		return "", err
+	}
+	t.token.Release()
+	t.token, t.expiry = secret.New(token), expiry
+	return token, nil
+}
+
+// mint requests a new installation token with an app JWT
+func (t *installationTokens) mint(ctx context.Context) (string, time.Time, error) {
+	appJWT, err := AppJWT(t.config.AppID, t.config.PrivateKey, t.clock.Now())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var body []byte
+	if len(t.config.Permissions) > 0 {
+		if body, err = json.Marshal(map[string]interface{}{"permissions": t.config.Permissions}); err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", strings.TrimSuffix(t.baseURL, "/"), t.config.InstallationID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+appJWT)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", time.Time{}, fmt.Errorf("installation token API returned status %d for installation %d", resp.StatusCode, t.config.InstallationID)
+	}
+	var minted struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&minted); err != nil {
+		return "", time.Time{}, err
+	}
+	if minted.Token == "" {
+		return "", time.Time{}, fmt.Errorf("installation token API returned no token for installation %d", t.config.InstallationID)
+	}
+	return minted.Token, minted.ExpiresAt, nil
+}
+
+// release zeroes the cached installation token
+func (t *installationTokens) release() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.token.Release()
+	t.token, t.expiry = nil, time.Time{}
+}
+
+// CredentialKind reports what the client authenticates as
+func (c *Client) CredentialKind() CredentialKind {
+	if c.app != nil {
+		return CredentialApp
+	}
+	if c.config.TokenKind != "" {
+		return c.config.TokenKind
+	}
+	return CredentialPAT
+}
+
+// authorize sets the request's Authorization header from the client's
+// credentials, returning the pooled token used, if any, so its rate limit
+// can be recorded
+func (c *Client) authorize(ctx context.Context, req *http.Request) (*pooledToken, error) {
+	token := c.config.Token.Reveal()
+	var pooled *pooledToken
+	switch {
+	case c.app != nil:
+		var err error
+		if token, err = c.app.get(ctx); err != nil {
+			return nil, fmt.Errorf("github app authentication failed: %w", err)
+		}
+	case c.tokens != nil:
+		if pooled = c.tokens.pick(); pooled != nil {
+			token = pooled.token.Reveal()
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	return pooled, nil
+}
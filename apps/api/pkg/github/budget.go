@@ -0,0 +1,184 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Consumer names a subsystem sharing the client's rate limit
+type Consumer string
+
+// Consumers budgeted by Keystone's subsystems
+const (
+	ConsumerAdvisorySync       Consumer = "advisory-sync"        // Advisory backfills and incremental syncs
+	ConsumerAttestationChecks  Consumer = "attestation-checks"   // Check runs and commit statuses reporting verification
+	ConsumerPullRequestReports Consumer = "pull-request-reports" // Sticky pull request report comments
+)
+
+// ErrBudgetExhausted is returned for a request whose consumer has spent its
+// share of the rate limit window, while the rest is reserved for others
+var ErrBudgetExhausted = errors.New("rate limit budget exhausted")
+
+// BudgetError is returned when a consumer's request is refused, with when
+// the next window's budget is allotted
+type BudgetError struct {
+	Consumer Consumer
+	Reset    time.Time
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%s: %s until %s", ErrBudgetExhausted, e.Consumer, e.Reset.UTC().Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrBudgetExhausted) hold
+func (e *BudgetError) Is(target error) bool {
+	return target == ErrBudgetExhausted
+}
+
+// BudgetUsage is a consumer's budget in the current rate limit window
+type BudgetUsage struct {
+	Consumer Consumer `json:"consumer"`
+	Share    float64  `json:"share"`
+	Allotted int      `json:"allotted"` // Requests reserved for the consumer this window
+	Spent    int      `json:"spent"`
+}
+
+// BudgetManager reserves shares of each rate limit window for consumers, so
+// one consumer, such as a bulk advisory sync, can't starve the others.
+//
+// When a window starts, each consumer is allotted its share of the requests
+// remaining. With a token pool, that's the requests every token has left, and
+// the window lasts until the first token's resets. A consumer spends its
+// allotment first, then may borrow what isn't reserved: requests nobody was
+// allotted, and allotments other consumers have already spent. Requests made without a consumer, or for one
+// without a share, aren't budgeted.
+type BudgetManager struct {
+	shares map[Consumer]float64
+
+	mutex    sync.Mutex
+	window   time.Time // Reset of the window allotted
+	allotted map[Consumer]int
+	spent    map[Consumer]int
+}
+
+// NewBudgetManager creates a budget manager from each consumer's share of the
+// rate limit, between 0 and 1. The shares may total at most 1.
+func NewBudgetManager(shares map[Consumer]float64) (*BudgetManager, error) {
+	total := 0.0
+	for consumer, share := range shares {
+		if share <= 0 || share > 1 {
+			return nil, fmt.Errorf("rate limit share of %s must be between 0 and 1, got %g", consumer, share)
+		}
+		total += share
+	}
+	if total > 1+1e-9 {
+		return nil, fmt.Errorf("rate limit shares total %g; they may total at most 1", total)
+	}
+	return &BudgetManager{
+		shares:   shares,
+		allotted: make(map[Consumer]int),
+		spent:    make(map[Consumer]int),
+	}, nil
+}
+
+// ParseBudgets parses consumer=share pairs separated by commas, e.g.
+// advisory-sync=0.3,attestation-checks=0.5
+func ParseBudgets(value string) (map[Consumer]float64, error) {
+	shares := make(map[Consumer]float64)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		consumer, share, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid rate limit budget %q; expected consumer=share", pair)
+		}
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(share), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit share for %s: %w", consumer, err)
+		}
+		shares[Consumer(strings.TrimSpace(consumer))] = parsed
+	}
+	return shares, nil
+}
+
+// spend charges a request to the consumer, or refuses it with a
+// *BudgetError. rateLimit is the client's quota, combined across a token
+// pool; nil allows every request until responses report it.
+func (b *BudgetManager) spend(consumer Consumer, rateLimit *RateLimit) error {
+	if b == nil || rateLimit == nil {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// A window earlier than the one allotted is a stale report, not a new window
+	if rateLimit.Reset.After(b.window) {
+		b.window = rateLimit.Reset
+		for budgeted, share := range b.shares {
+			b.allotted[budgeted] = int(share * float64(rateLimit.Remaining))
+			b.spent[budgeted] = 0
+		}
+	}
+	if _, budgeted := b.shares[consumer]; !budgeted {
+		return nil
+	}
+
+	if b.spent[consumer] < b.allotted[consumer] {
+		b.spent[consumer]++
+		return nil
+	}
+	reserved := 0
+	for other := range b.shares {
+		if other != consumer {
+			reserved += max(0, b.allotted[other]-b.spent[other])
+		}
+	}
+	if rateLimit.Remaining > reserved {
+		b.spent[consumer]++
+		return nil
+	}
+	return &BudgetError{Consumer: consumer, Reset: rateLimit.Reset}
+}
+
+// Usage returns each consumer's budget in the current window, by consumer
+func (b *BudgetManager) Usage() []BudgetUsage {
+	if b == nil {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	usage := make([]BudgetUsage, 0, len(b.shares))
+	for consumer, share := range b.shares {
+		usage = append(usage, BudgetUsage{
+			Consumer: consumer,
+			Share:    share,
+			Allotted: b.allotted[consumer],
+			Spent:    b.spent[consumer],
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Consumer < usage[j].Consumer })
+	return usage
+}
+
+// consumerKey is the context key of the consumer making requests
+type consumerKey struct{}
+
+// WithConsumer attributes the requests made with the context to a consumer,
+// charging them to its budget
+func WithConsumer(ctx context.Context, consumer Consumer) context.Context {
+	return context.WithValue(ctx, consumerKey{}, consumer)
+}
+
+// ConsumerFrom returns the consumer requests made with the context are
+// attributed to, if any
+func ConsumerFrom(ctx context.Context) Consumer {
+	consumer, _ := ctx.Value(consumerKey{}).(Consumer)
+	return consumer
+}
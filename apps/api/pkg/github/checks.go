@@ -0,0 +1,198 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// MaxCheckAnnotations is how many annotations GitHub accepts per check run
+// request. Further annotations are sent in later updates, which append them.
+const MaxCheckAnnotations = 50
+
+// Check run statuses
+const (
+	CheckRunQueued     = "queued"
+	CheckRunInProgress = "in_progress"
+	CheckRunCompleted  = "completed"
+)
+
+// Check run conclusions, set once a run completes
+const (
+	CheckConclusionSuccess        = "success"
+	CheckConclusionFailure        = "failure"
+	CheckConclusionNeutral        = "neutral"
+	CheckConclusionActionRequired = "action_required"
+)
+
+// Annotation levels
+const (
+	AnnotationNotice  = "notice"
+	AnnotationWarning = "warning"
+	AnnotationFailure = "failure"
+)
+
+// CheckRun is a check run on a commit
+type CheckRun struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	HeadSHA     string     `json:"head_sha"`
+	Status      string     `json:"status"`
+	Conclusion  string     `json:"conclusion"`
+	ExternalID  string     `json:"external_id"`
+	HTMLURL     string     `json:"html_url"`
+	DetailsURL  string     `json:"details_url"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	Output      struct {
+		Title            string `json:"title"`
+		Summary          string `json:"summary"`
+		AnnotationsCount int    `json:"annotations_count"`
+	} `json:"output"`
+}
+
+// CheckRunOptions creates or updates a check run. Name and HeadSHA are only
+// sent on creation.
+type CheckRunOptions struct {
+	Name        string          `json:"name,omitempty"`
+	HeadSHA     string          `json:"head_sha,omitempty"`
+	Status      string          `json:"status,omitempty"`
+	Conclusion  string          `json:"conclusion,omitempty"` // Required when Status is completed
+	ExternalID  string          `json:"external_id,omitempty"`
+	DetailsURL  string          `json:"details_url,omitempty"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	Output      *CheckRunOutput `json:"output,omitempty"`
+}
+
+// CheckRunOutput is what a check run shows on the pull request's Checks tab
+type CheckRunOutput struct {
+	Title       string            `json:"title"`
+	Summary     string            `json:"summary"` // Markdown, at most 65535 characters
+	Text        string            `json:"text,omitempty"`
+	Annotations []CheckAnnotation `json:"annotations,omitempty"`
+}
+
+// CheckAnnotation flags a line of a file in the pull request's diff
+type CheckAnnotation struct {
+	Path            string `json:"path"` // Repository-relative
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Title           string `json:"title,omitempty"`
+	Message         string `json:"message"`
+	RawDetails      string `json:"raw_details,omitempty"`
+}
+
+// CreateCheckRun creates a check run. Annotations beyond the first
+// MaxCheckAnnotations are added by updating the run.
+func (c *Client) CreateCheckRun(ctx context.Context, owner, repo string, options CheckRunOptions) (*CheckRun, error) {
+	if options.Name == "" || options.HeadSHA == "" {
+		return nil, fmt.Errorf("check run name and head SHA are required")
+	}
+	first, rest := splitAnnotations(options)
+	url := fmt.Sprintf("%s/repos/%s/%s/check-runs", c.config.BaseURL, owner, repo)
+
+	run, err := c.sendCheckRun(ctx, "POST", url, http.StatusCreated, first)
+	if err != nil {
+		return nil, err
+	}
+	return c.appendAnnotations(ctx, owner, repo, run, options.Output, rest)
+}
+
+// UpdateCheckRun updates a check run, e.g. to complete it with a conclusion.
+// Annotations are appended to those the run already has.
+func (c *Client) UpdateCheckRun(ctx context.Context, owner, repo string, id int64, options CheckRunOptions) (*CheckRun, error) {
+	options.HeadSHA = ""
+	first, rest := splitAnnotations(options)
+	url := fmt.Sprintf("%s/repos/%s/%s/check-runs/%d", c.config.BaseURL, owner, repo, id)
+
+	run, err := c.sendCheckRun(ctx, "PATCH", url, http.StatusOK, first)
+	if err != nil {
+		return nil, err
+	}
+	return c.appendAnnotations(ctx, owner, repo, run, options.Output, rest)
+}
+
+// FindCheckRun returns the latest check run with the name on a commit, or
+// nil if there is none
+func (c *Client) FindCheckRun(ctx context.Context, owner, repo, sha, name string) (*CheckRun, error) {
+	requestURL := fmt.Sprintf("%s/repos/%s/%s/commits/%s/check-runs?check_name=%s&filter=latest",
+		c.config.BaseURL, owner, repo, url.PathEscape(sha), url.QueryEscape(name))
+
+	resp, err := c.makeRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("check runs API returned status %d for %s", resp.StatusCode, sha)
+	}
+
+	var list struct {
+		CheckRuns []CheckRun `json:"check_runs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	for i := range list.CheckRuns {
+		if list.CheckRuns[i].Name == name {
+			return &list.CheckRuns[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// sendCheckRun creates or updates a check run
+func (c *Client) sendCheckRun(ctx context.Context, method, url string, want int, options CheckRunOptions) (*CheckRun, error) {
+	run, err := doRequest[CheckRun](ctx, c, method, url, options, want)
+	if err != nil {
+		if statusErr, ok := err.(*StatusError); ok && statusErr.StatusCode == http.StatusUnprocessableEntity {
+			return nil, fmt.Errorf("check runs API rejected the check run; GitHub Apps alone can write check runs: %w", err)
+		}
+		return nil, err
+	}
+	return run, nil
+}
+
+// appendAnnotations sends annotations past the first batch, a batch per update
+func (c *Client) appendAnnotations(ctx context.Context, owner, repo string, run *CheckRun, output *CheckRunOutput, annotations []CheckAnnotation) (*CheckRun, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/check-runs/%d", c.config.BaseURL, owner, repo, run.ID)
+	for len(annotations) > 0 {
+		batch := annotations
+		if len(batch) > MaxCheckAnnotations {
+			batch = batch[:MaxCheckAnnotations]
+		}
+		annotations = annotations[len(batch):]
+
+		// Updates need the output's title and summary to carry annotations
+		var err error
+		run, err = c.sendCheckRun(ctx, "PATCH", url, http.StatusOK, CheckRunOptions{Output: &CheckRunOutput{
+			Title:       output.Title,
+			Summary:     output.Summary,
+			Text:        output.Text,
+			Annotations: batch,
+		}})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return run, nil
+}
+
+// splitAnnotations limits options to the first batch of annotations and
+// returns the rest
+func splitAnnotations(options CheckRunOptions) (CheckRunOptions, []CheckAnnotation) {
+	if options.Output == nil || len(options.Output.Annotations) <= MaxCheckAnnotations {
+		return options, nil
+	}
+	output := *options.Output
+	rest := output.Annotations[MaxCheckAnnotations:]
+	output.Annotations = output.Annotations[:MaxCheckAnnotations]
+	options.Output = &output
+	return options, rest
+}
@@ -3,14 +3,22 @@ package github
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
+	neturl "net/url"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/metrics"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
 )
 
 // RateLimit represents GitHub API rate limit information
@@ -30,22 +38,42 @@ type RateLimitResponse struct {
 
 // Config holds the GitHub client configuration
 type Config struct {
-	Token                string
-	BaseURL              string
+	Token                *secret.String
+	TokenKind            CredentialKind // What Token is; CredentialPAT when empty
+	Tokens               []*secret.String // More tokens of the same kind, rotated with Token by requests remaining
+	App                  *AppConfig     // Authenticates as an app installation instead of with Token
+	BaseURL              string         // REST API root; see APIRoot for GitHub Enterprise Server
+	APIVersion           string         // REST API version requested in X-GitHub-Api-Version; the server's default when empty
 	RateLimitThreshold   int           // Stop at this many remaining requests (80% buffer)
 	BackoffBase          time.Duration // Base time for exponential backoff
 	MaxBackoff           time.Duration // Maximum backoff time
+	MaxPages             int           // Pages collected by list methods at most; unlimited when zero
+	Cache                Cache         // Keeps GET responses for conditional requests; unconditional when nil
+	CacheTTL             time.Duration // How long responses are kept; DefaultConditionalCacheTTL when zero
+	SecondaryCooldown    time.Duration // Pause after a secondary rate limit without Retry-After; DefaultSecondaryCooldown when zero
+	Retry                RetryPolicy            // Retries failed requests; none when zero
+	EndpointRetries      map[string]RetryPolicy // Replaces Retry for URLs containing the key, e.g. "/advisories"; the longest key wins
+	RetryBudget          *circuit.Budget        // Shared cap on retries across clients; nil allows every retry the policy does
+	Budget               *BudgetManager         // Reserves shares of the rate limit for consumers; see WithConsumer
 	CircuitBreakerConfig circuit.Config
+	OnRequest            func(ctx context.Context, method, url string, statusCode int) // Called after each API request, e.g. for usage metering
+	Transport            http.RoundTripper                                             // Optional, e.g. to report call outcomes to the offline detector
+	Middleware           []Middleware                                                  // Wraps Transport, first listed outermost; see Chain
+	Clock                clock.Clock                                                   // Times rate limit backoff; defaults to the system clock
+	Metrics              *metrics.Registry                                             // Receives request counts, latencies, rate limit and breaker state; none when nil
 }
 
 // DefaultConfig returns a default GitHub client configuration
 func DefaultConfig(token string) Config {
 	return Config{
-		Token:              token,
-		BaseURL:            "https://api.github.com",
+		Token:              secret.New(token),
+		BaseURL:            DefaultBaseURL,
+		APIVersion:         DefaultAPIVersion,
 		RateLimitThreshold: 1000, // 20% of 5000 requests/hour
 		BackoffBase:        2 * time.Second,
 		MaxBackoff:         60 * time.Second,
+		MaxPages:           50,
+		Retry:              DefaultRetryPolicy(),
 		CircuitBreakerConfig: circuit.Config{
 			FailureThreshold:   5,
 			RecoveryTimeout:    5 * time.Minute,
@@ -56,20 +84,76 @@ func DefaultConfig(token string) Config {
 	}
 }
 
+// ErrRateLimitExceeded is returned for a 403 the retry policy didn't retry,
+// typically a primary rate limit with no requests remaining
+var ErrRateLimitExceeded = errors.New("rate limit exceeded")
+
 // Client provides GitHub API access with rate limiting and circuit breaker
 type Client struct {
 	config        Config
 	httpClient    *http.Client
 	circuitBreaker *circuit.Breaker
-	lastRateLimit *RateLimit
+	clock         clock.Clock
+	app           *installationTokens
+	tokens        *tokenPool // Set when Config.Tokens pools several tokens
+	cacheHits     int64 // Conditional requests answered with 304, read atomically
+	retries       int64 // Attempts repeated under the retry policy, read atomically
+
+	rateLimitMutex sync.Mutex
+	lastRateLimit  *RateLimit
+
+	throttleMutex sync.Mutex
+	cooldownUntil time.Time // Requests pause until then after a secondary rate limit
+	secondaryHits int64
+
+	versionMutex      sync.Mutex
+	apiVersion        string // Cleared when the server rejects it
+	enterpriseVersion string
 }
 
 // NewClient creates a new GitHub client
 func NewClient(config Config) *Client {
-	return &Client{
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	breakerConfig := config.CircuitBreakerConfig
+	if breakerConfig.Clock == nil {
+		breakerConfig.Clock = config.Clock
+	}
+	client := &Client{
 		config:         config,
-		httpClient:     &http.Client{Timeout: 30 * time.Second},
-		circuitBreaker: circuit.New(config.CircuitBreakerConfig),
+		httpClient:     &http.Client{Timeout: 30 * time.Second, Transport: config.Transport},
+		circuitBreaker: circuit.New(breakerConfig),
+		clock:          clock.OrReal(config.Clock),
+		apiVersion:     config.APIVersion,
+	}
+	middleware := config.Middleware
+	if config.Metrics != nil {
+		// Innermost, so latencies are GitHub's alone
+		middleware = append(middleware[:len(middleware):len(middleware)], client.instrument(config.Metrics))
+	}
+	if len(middleware) > 0 {
+		client.httpClient.Transport = Chain(config.Transport, middleware...)
+	}
+	if config.App != nil {
+		client.app = &installationTokens{
+			config:  *config.App,
+			baseURL: config.BaseURL,
+			client:  client.httpClient,
+			clock:   client.clock,
+		}
+	} else if len(config.Tokens) > 0 {
+		client.tokens = newTokenPool(append([]*secret.String{config.Token}, config.Tokens...), client.clock)
+	}
+	return client
+}
+
+// Close releases the client's tokens; requests made after are unauthenticated
+func (c *Client) Close() {
+	c.config.Token.Release()
+	if c.app != nil {
+		c.app.release()
+	}
+	if c.tokens != nil {
+		c.tokens.release()
 	}
 }
 
@@ -84,7 +168,10 @@ func (c *Client) GetRateLimit(ctx context.Context) (*RateLimit, error) {
 			return err
 		}
 
-		req.Header.Set("Authorization", "token "+c.config.Token)
+		token, err := c.authorize(ctx, req)
+		if err != nil {
+			return err
+		}
 		req.Header.Set("Accept", "application/vnd.github.v3+json")
 
 		resp, err := c.httpClient.Do(req)
@@ -103,7 +190,7 @@ func (c *Client) GetRateLimit(ctx context.Context) (*RateLimit, error) {
 		}
 
 		rateLimit = &rateLimitResp.Resources.Core
-		c.lastRateLimit = rateLimit
+		c.recordRateLimit(token, rateLimit)
 		return nil
 	})
 
@@ -112,14 +199,15 @@ func (c *Client) GetRateLimit(ctx context.Context) (*RateLimit, error) {
 
 // shouldBackoff checks if we should back off based on rate limiting
 func (c *Client) shouldBackoff() (bool, time.Duration) {
-	if c.lastRateLimit == nil {
+	rateLimit := c.rateLimit()
+	if rateLimit == nil {
 		return false, 0
 	}
 
 	// Check if we're approaching the rate limit threshold
-	if c.lastRateLimit.Remaining <= c.config.RateLimitThreshold {
+	if rateLimit.Remaining <= c.config.RateLimitThreshold {
 		// Calculate exponential backoff
-		factor := float64(c.config.RateLimitThreshold - c.lastRateLimit.Remaining)
+		factor := float64(c.config.RateLimitThreshold - rateLimit.Remaining)
 		backoffDuration := time.Duration(math.Pow(2, factor/100)) * c.config.BackoffBase
 		
 		if backoffDuration > c.config.MaxBackoff {
@@ -132,15 +220,99 @@ func (c *Client) shouldBackoff() (bool, time.Duration) {
 	return false, 0
 }
 
-// makeRequest executes an HTTP request with rate limiting and circuit breaker protection
+// makeRequest executes an HTTP request with rate limiting and circuit breaker
+// protection. With a cache configured, GETs of previously fetched URLs are
+// conditional and a 304 is answered from the cache.
 func (c *Client) makeRequest(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+	return c.makeRequestWithHeader(ctx, method, url, body, nil)
+}
+
+// makeRequestWithHeader makes a request with headers replacing the defaults,
+// e.g. an Accept asking for a release asset's content. Failed attempts are
+// retried as the URL's RetryPolicy and the shared RetryBudget allow; a body
+// that can't be rewound is only sent once.
+func (c *Client) makeRequestWithHeader(ctx context.Context, method, url string, body io.Reader, header http.Header) (*http.Response, error) {
+	policy := c.retryPolicy(url)
+	seeker, rewindable := body.(io.Seeker)
+	c.config.RetryBudget.RecordRequest()
+	for attempt := 1; ; attempt++ {
+		if err := c.config.Budget.spend(ConsumerFrom(ctx), c.quota()); err != nil {
+			return nil, err
+		}
+		resp, err := c.send(ctx, method, url, body, header)
+		if err == nil {
+			return resp, nil
+		}
+
+		var failed *responseError
+		var unreachable *neturl.Error
+		statusCode, responseHeader := 0, http.Header(nil)
+		switch {
+		case ctx.Err() != nil:
+			return nil, err
+		case errors.As(err, &failed):
+			statusCode, responseHeader, err = failed.statusCode, failed.header, failed.err
+		case !errors.As(err, &unreachable):
+			// Circuit breaker and secondary rate limit errors aren't retried
+			return nil, err
+		}
+
+		delay, retry := policy.retryDelay(attempt, method, statusCode, responseHeader, c.clock.Now())
+		if !retry || (body != nil && !rewindable) {
+			return nil, err
+		}
+		// Fail fast rather than add to the load on a struggling upstream
+		if budgetErr := c.config.RetryBudget.TryRetry(); budgetErr != nil {
+			return nil, fmt.Errorf("%w after: %v", budgetErr, err)
+		}
+		atomic.AddInt64(&c.retries, 1)
+		select {
+		case <-c.clock.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if rewindable {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// responseError is an attempt the API answered with a status treated as a
+// failure, keeping what the retry policy needs of the response
+type responseError struct {
+	err        error
+	statusCode int
+	header     http.Header
+}
+
+func (e *responseError) Error() string { return e.err.Error() }
+
+func (e *responseError) Unwrap() error { return e.err }
+
+// send makes a single attempt at a request
+func (c *Client) send(ctx context.Context, method, url string, body io.Reader, header http.Header) (*http.Response, error) {
 	var resp *http.Response
-	
+	var cached *cachedResponse
+	if c.cacheable(method, url) {
+		cached = c.lookupResponse(ctx, url)
+	}
+
+	if err := c.awaitCooldown(ctx); err != nil {
+		return nil, err
+	}
+
+	// Secondary limits are GitHub throttling a healthy API, so they're
+	// reported after the call rather than counted as breaker failures
+	var throttled, versionRejected bool
+	version := c.APIVersion()
 	err := c.circuitBreaker.Call(ctx, func() error {
-		// Check rate limit before making request
-		if shouldBackoff, backoffDuration := c.shouldBackoff(); shouldBackoff {
+		// Check rate limit before making request. A 304 doesn't count against
+		// the rate limit, so conditional requests skip the backoff.
+		if shouldBackoff, backoffDuration := c.shouldBackoff(); shouldBackoff && cached == nil {
 			select {
-			case <-time.After(backoffDuration):
+			case <-c.clock.After(backoffDuration):
 				// Continue after backoff
 			case <-ctx.Done():
 				return ctx.Err()
@@ -152,11 +324,23 @@ func (c *Client) makeRequest(ctx context.Context, method, url string, body io.Re
 			return err
 		}
 
-		req.Header.Set("Authorization", "token "+c.config.Token)
+		token, err := c.authorize(ctx, req)
+		if err != nil {
+			return err
+		}
 		req.Header.Set("Accept", "application/vnd.github.v3+json")
+		if version != "" {
+			req.Header.Set("X-GitHub-Api-Version", version)
+		}
 		if body != nil {
 			req.Header.Set("Content-Type", "application/json")
 		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		if cached != nil {
+			cached.condition(req)
+		}
 
 		resp, err = c.httpClient.Do(req)
 		if err != nil {
@@ -164,35 +348,71 @@ func (c *Client) makeRequest(ctx context.Context, method, url string, body io.Re
 		}
 
 		// Update rate limit from response headers
-		c.updateRateLimitFromHeaders(resp.Header)
+		c.updateRateLimitFromHeaders(token, resp.Header)
+		c.recordVersion(resp)
+
+		if c.config.OnRequest != nil {
+			c.config.OnRequest(ctx, method, url, resp.StatusCode)
+		}
+
+		if cooldown, secondary := c.secondaryRateLimited(resp); secondary {
+			resp.Body.Close()
+			c.startCooldown(cooldown)
+			throttled = true
+			return nil
+		}
+		if c.versionRejected(version, resp) {
+			resp.Body.Close()
+			versionRejected = true
+			return nil
+		}
 
-		// Handle rate limit exceeded
+		// Handle rate limit exceeded; the retry policy honours Retry-After
 		if resp.StatusCode == http.StatusForbidden {
-			if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
-				if seconds, err := strconv.Atoi(retryAfter); err == nil {
-					select {
-					case <-time.After(time.Duration(seconds) * time.Second):
-						// Continue after retry delay
-					case <-ctx.Done():
-						return ctx.Err()
-					}
-				}
-			}
-			return fmt.Errorf("rate limit exceeded")
+			resp.Body.Close()
+			return &responseError{err: ErrRateLimitExceeded, statusCode: resp.StatusCode, header: resp.Header}
 		}
 
 		if resp.StatusCode >= 500 {
-			return fmt.Errorf("server error: %d", resp.StatusCode)
+			resp.Body.Close()
+			return &responseError{err: fmt.Errorf("server error: %d", resp.StatusCode), statusCode: resp.StatusCode, header: resp.Header}
 		}
 
 		return nil
 	})
+	if err != nil {
+		// The call may have been abandoned on cancellation while still
+		// writing resp, so only a completed call's response is read
+		return nil, err
+	}
+	if throttled {
+		return nil, ErrSecondaryRateLimit
+	}
+	if versionRejected {
+		// Retry with the server's default version, rewinding the body
+		if seeker, ok := body.(io.Seeker); body == nil || ok {
+			if ok {
+				if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+					return nil, err
+				}
+			}
+			return c.send(ctx, method, url, body, header)
+		}
+		return nil, fmt.Errorf("GitHub API rejected version %s", version)
+	}
 
-	return resp, err
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		atomic.AddInt64(&c.cacheHits, 1)
+		return cached.replay(resp), nil
+	}
+	if c.cacheable(method, url) && resp.StatusCode == http.StatusOK {
+		resp = c.storeResponse(ctx, url, resp)
+	}
+	return resp, nil
 }
 
 // updateRateLimitFromHeaders updates rate limit info from response headers
-func (c *Client) updateRateLimitFromHeaders(headers http.Header) {
+func (c *Client) updateRateLimitFromHeaders(token *pooledToken, headers http.Header) {
 	limitStr := headers.Get("X-RateLimit-Limit")
 	remainingStr := headers.Get("X-RateLimit-Remaining")
 	resetStr := headers.Get("X-RateLimit-Reset")
@@ -207,78 +427,110 @@ func (c *Client) updateRateLimitFromHeaders(headers http.Header) {
 	resetUnix, _ := strconv.ParseInt(resetStr, 10, 64)
 	used, _ := strconv.Atoi(usedStr)
 
-	c.lastRateLimit = &RateLimit{
+	c.recordRateLimit(token, &RateLimit{
 		Limit:     limit,
 		Remaining: remaining,
 		Reset:     time.Unix(resetUnix, 0),
 		Used:      used,
-	}
+	})
 }
 
-// GetSecurityAdvisories fetches security advisories from GitHub
-func (c *Client) GetSecurityAdvisories(ctx context.Context, perPage int) ([]map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/advisories?per_page=%d", c.config.BaseURL, perPage)
-	
-	resp, err := c.makeRequest(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
+// recordRateLimit records the rate limit reported for a request's token. With
+// a token pool, backoff follows the token the next request would use.
+func (c *Client) recordRateLimit(token *pooledToken, rateLimit *RateLimit) {
+	if token != nil {
+		rateLimit = c.tokens.update(token, rateLimit)
 	}
-	defer resp.Body.Close()
+	c.rateLimitMutex.Lock()
+	c.lastRateLimit = rateLimit
+	c.rateLimitMutex.Unlock()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("advisories API returned status %d", resp.StatusCode)
-	}
+// rateLimit returns the last rate limit recorded, nil before any response
+// reported one
+func (c *Client) rateLimit() *RateLimit {
+	c.rateLimitMutex.Lock()
+	defer c.rateLimitMutex.Unlock()
+	return c.lastRateLimit
+}
 
-	var advisories []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&advisories); err != nil {
-		return nil, err
+// quota returns the rate limit budgets are allotted from: with a token pool,
+// the pool's combined quota, whose window doesn't move as requests rotate
+// among tokens
+func (c *Client) quota() *RateLimit {
+	if c.tokens != nil {
+		return c.tokens.total()
 	}
-
-	return advisories, nil
+	return c.rateLimit()
 }
 
-// GetRepositoryAdvisories fetches security advisories for a specific repository
-func (c *Client) GetRepositoryAdvisories(ctx context.Context, owner, repo string) ([]map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/security-advisories", c.config.BaseURL, owner, repo)
-	
-	resp, err := c.makeRequest(ctx, "GET", url, nil)
+// GetSecurityAdvisories fetches security advisories from GitHub, following
+// pages of perPage advisories up to the configured MaxPages
+func (c *Client) GetSecurityAdvisories(ctx context.Context, perPage int) ([]Advisory, error) {
+	url := fmt.Sprintf("%s/advisories", c.config.BaseURL)
+
+	var advisories []Advisory
+	err := c.Paginate(url, PageOptions{PerPage: perPage, MaxPages: c.config.MaxPages}).All(ctx, &advisories)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return advisories, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("repository advisories API returned status %d", resp.StatusCode)
-	}
+// GetRepositoryAdvisories fetches security advisories for a specific
+// repository, following pages up to the configured MaxPages
+func (c *Client) GetRepositoryAdvisories(ctx context.Context, owner, repo string) ([]Advisory, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/security-advisories", c.config.BaseURL, owner, repo)
 
-	var advisories []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&advisories); err != nil {
+	var advisories []Advisory
+	err := c.Paginate(url, PageOptions{PerPage: MaxPerPage, MaxPages: c.config.MaxPages}).All(ctx, &advisories)
+	if err != nil {
 		return nil, err
 	}
-
 	return advisories, nil
 }
 
 // GetRepository fetches repository information
-func (c *Client) GetRepository(ctx context.Context, owner, repo string) (map[string]interface{}, error) {
+func (c *Client) GetRepository(ctx context.Context, owner, repo string) (*Repository, error) {
 	url := fmt.Sprintf("%s/repos/%s/%s", c.config.BaseURL, owner, repo)
-	
-	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	return doRequest[Repository](ctx, c, "GET", url, nil, http.StatusOK)
+}
+
+// doRequest makes a request, with body JSON-encoded unless nil, and decodes a
+// response with the wanted status as a T
+func doRequest[T any](ctx context.Context, c *Client, method, url string, body interface{}, want int) (*T, error) {
+	var resp *http.Response
+	var err error
+	if body == nil {
+		resp, err = c.makeRequest(ctx, method, url, nil)
+	} else {
+		resp, err = c.sendJSON(ctx, method, url, body)
+	}
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("repository API returned status %d", resp.StatusCode)
+	if resp.StatusCode != want {
+		return nil, &StatusError{Method: method, URL: url, StatusCode: resp.StatusCode}
 	}
 
-	var repository map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&repository); err != nil {
-		return nil, err
+	result := new(T)
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("failed to decode %s %s: %w", method, url, err)
 	}
+	return result, nil
+}
 
-	return repository, nil
+// StatusError is returned when the API answers with an unexpected status
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("GitHub API returned status %d for %s %s", e.StatusCode, e.Method, e.URL)
 }
 
 // Stats returns client statistics including circuit breaker state
@@ -286,13 +538,30 @@ type Stats struct {
 	CircuitBreakerState circuit.State
 	LastRateLimit       *RateLimit
 	CircuitBreakerStats circuit.Stats
+	CacheHits           int64 // Conditional requests answered from the cache
+	Retries             int64 // Attempts repeated under the retry policy
+	SecondaryRateLimit  SecondaryRateLimit
+	TokenRateLimits     []*RateLimit // Per pooled token, Token first; nil until a response reports it
+	Budgets             []BudgetUsage
 }
 
 // Stats returns current client statistics
 func (c *Client) Stats() Stats {
 	return Stats{
 		CircuitBreakerState: c.circuitBreaker.State(),
-		LastRateLimit:       c.lastRateLimit,
+		LastRateLimit:       c.rateLimit(),
 		CircuitBreakerStats: c.circuitBreaker.Stats(),
+		CacheHits:           atomic.LoadInt64(&c.cacheHits),
+		Retries:             atomic.LoadInt64(&c.retries),
+		SecondaryRateLimit:  c.SecondaryRateLimit(),
+		TokenRateLimits:     c.tokenRateLimits(),
+		Budgets:             c.config.Budget.Usage(),
+	}
+}
+// tokenRateLimits returns the rate limit of each pooled token
+func (c *Client) tokenRateLimits() []*RateLimit {
+	if c.tokens == nil {
+		return nil
 	}
-}
\ No newline at end of file
+	return c.tokens.rateLimits()
+}
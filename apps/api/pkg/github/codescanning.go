@@ -0,0 +1,221 @@
+package github
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// MaxSARIFSize is the largest gzip-compressed SARIF upload GitHub accepts
+const MaxSARIFSize = 10 << 20
+
+// DefaultSARIFPollInterval is how often WaitForSARIF checks on an upload
+const DefaultSARIFPollInterval = 5 * time.Second
+
+// SARIF upload processing states
+const (
+	SARIFPending  = "pending"
+	SARIFComplete = "complete"
+	SARIFFailed   = "failed"
+)
+
+// CodeScanningAlertQuery holds filters for listing code scanning alerts
+type CodeScanningAlertQuery struct {
+	State    string // open, closed, dismissed or fixed
+	Ref      string // e.g. refs/heads/main; the default branch when empty
+	ToolName string // e.g. keystone
+	Severity string // critical, high, medium, low, warning, note or error
+	PerPage  int
+}
+
+// CodeScanningAlert is an alert in a repository's Security tab
+type CodeScanningAlert struct {
+	Number    int       `json:"number"`
+	State     string    `json:"state"`
+	HTMLURL   string    `json:"html_url"`
+	CreatedAt time.Time `json:"created_at"`
+	Rule      struct {
+		ID                    string `json:"id"`
+		Severity              string `json:"severity"`
+		SecuritySeverityLevel string `json:"security_severity_level"`
+		Description           string `json:"description"`
+	} `json:"rule"`
+	Tool struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"tool"`
+	MostRecentInstance struct {
+		Ref      string `json:"ref"`
+		Location struct {
+			Path      string `json:"path"`
+			StartLine int    `json:"start_line"`
+		} `json:"location"`
+	} `json:"most_recent_instance"`
+}
+
+// SARIFUpload is a SARIF log to upload for analysis of a commit
+type SARIFUpload struct {
+	CommitSHA   string
+	Ref         string // e.g. refs/heads/main or refs/pull/42/head
+	SARIF       []byte // The uncompressed log
+	CheckoutURI string // Where the repository was checked out when scanned, if results use absolute paths
+	StartedAt   time.Time
+	ToolName    string // Overrides the tool named in the log
+}
+
+// SARIFStatus is the processing state of a SARIF upload
+type SARIFStatus struct {
+	ID               string   `json:"-"`
+	URL              string   `json:"-"`
+	ProcessingStatus string   `json:"processing_status"`
+	AnalysesURL      string   `json:"analyses_url"`
+	Errors           []string `json:"errors"`
+}
+
+// ListCodeScanningAlerts fetches a repository's code scanning alerts,
+// following pages up to the configured MaxPages
+func (c *Client) ListCodeScanningAlerts(ctx context.Context, owner, repo string, query CodeScanningAlertQuery) ([]CodeScanningAlert, error) {
+	params := url.Values{}
+	if query.State != "" {
+		params.Set("state", query.State)
+	}
+	if query.Ref != "" {
+		params.Set("ref", query.Ref)
+	}
+	if query.ToolName != "" {
+		params.Set("tool_name", query.ToolName)
+	}
+	if query.Severity != "" {
+		params.Set("severity", query.Severity)
+	}
+	requestURL := fmt.Sprintf("%s/repos/%s/%s/code-scanning/alerts", c.config.BaseURL, owner, repo)
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+
+	perPage := query.PerPage
+	if perPage == 0 {
+		perPage = MaxPerPage
+	}
+	alerts := []CodeScanningAlert{}
+	err := c.Paginate(requestURL, PageOptions{PerPage: perPage, MaxPages: c.config.MaxPages}).All(ctx, &alerts)
+	if err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// UploadSARIF gzips and base64-encodes a SARIF log and uploads it. GitHub
+// processes uploads asynchronously; GetSARIFUpload or WaitForSARIF report
+// how the analysis went.
+func (c *Client) UploadSARIF(ctx context.Context, owner, repo string, upload SARIFUpload) (*SARIFStatus, error) {
+	if upload.CommitSHA == "" || upload.Ref == "" {
+		return nil, fmt.Errorf("SARIF upload commit SHA and ref are required")
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(upload.SARIF); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	if compressed.Len() > MaxSARIFSize {
+		return nil, fmt.Errorf("SARIF log is %d bytes compressed, over GitHub's %d byte limit", compressed.Len(), MaxSARIFSize)
+	}
+
+	body := map[string]string{
+		"commit_sha": upload.CommitSHA,
+		"ref":        upload.Ref,
+		"sarif":      base64.StdEncoding.EncodeToString(compressed.Bytes()),
+	}
+	if upload.CheckoutURI != "" {
+		body["checkout_uri"] = upload.CheckoutURI
+	}
+	if !upload.StartedAt.IsZero() {
+		body["started_at"] = upload.StartedAt.UTC().Format(time.RFC3339)
+	}
+	if upload.ToolName != "" {
+		body["tool_name"] = upload.ToolName
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/%s/code-scanning/sarifs", c.config.BaseURL, owner, repo)
+	resp, err := c.sendJSON(ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("code scanning SARIF API returned status 404; code scanning may be disabled for %s/%s", owner, repo)
+	case http.StatusRequestEntityTooLarge:
+		return nil, fmt.Errorf("code scanning SARIF API rejected the upload as too large")
+	default:
+		return nil, fmt.Errorf("code scanning SARIF API returned status %d", resp.StatusCode)
+	}
+
+	var accepted struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&accepted); err != nil {
+		return nil, err
+	}
+	return &SARIFStatus{ID: accepted.ID, URL: accepted.URL, ProcessingStatus: SARIFPending}, nil
+}
+
+// GetSARIFUpload fetches the processing state of a SARIF upload
+func (c *Client) GetSARIFUpload(ctx context.Context, owner, repo, id string) (*SARIFStatus, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/code-scanning/sarifs/%s", c.config.BaseURL, owner, repo, id)
+
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("code scanning SARIF API returned status %d for upload %s", resp.StatusCode, id)
+	}
+
+	status := &SARIFStatus{ID: id, URL: url}
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// WaitForSARIF polls a SARIF upload until GitHub has finished processing it,
+// returning an error listing GitHub's complaints if processing failed
+func (c *Client) WaitForSARIF(ctx context.Context, owner, repo, id string, interval time.Duration) (*SARIFStatus, error) {
+	if interval <= 0 {
+		interval = DefaultSARIFPollInterval
+	}
+	for {
+		status, err := c.GetSARIFUpload(ctx, owner, repo, id)
+		if err != nil {
+			return nil, err
+		}
+		switch status.ProcessingStatus {
+		case SARIFComplete:
+			return status, nil
+		case SARIFFailed:
+			return status, fmt.Errorf("SARIF upload %s failed processing: %v", id, status.Errors)
+		}
+
+		select {
+		case <-c.clock.After(interval):
+		case <-ctx.Done():
+			return status, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MaxCommentBody is the longest comment body GitHub accepts
+const MaxCommentBody = 65536
+
+// IssueComment is a comment on an issue or pull request
+type IssueComment struct {
+	ID      int64  `json:"id"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	User    struct {
+		Login string `json:"login"`
+		Type  string `json:"type"` // User or Bot
+	} `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListIssueComments fetches the comments on an issue or pull request, oldest
+// first, following pages up to the configured MaxPages
+func (c *Client) ListIssueComments(ctx context.Context, owner, repo string, number int) ([]IssueComment, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/comments", c.config.BaseURL, owner, repo, number)
+
+	comments := []IssueComment{}
+	err := c.Paginate(url, PageOptions{PerPage: MaxPerPage, MaxPages: c.config.MaxPages}).All(ctx, &comments)
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// CreateIssueComment comments on an issue or pull request
+func (c *Client) CreateIssueComment(ctx context.Context, owner, repo string, number int, body string) (*IssueComment, error) {
+	if len(body) > MaxCommentBody {
+		return nil, fmt.Errorf("comment is %d bytes, over GitHub's %d byte limit", len(body), MaxCommentBody)
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/comments", c.config.BaseURL, owner, repo, number)
+	return doRequest[IssueComment](ctx, c, "POST", url, map[string]string{"body": body}, http.StatusCreated)
+}
+
+// UpdateIssueComment replaces a comment's body
+func (c *Client) UpdateIssueComment(ctx context.Context, owner, repo string, id int64, body string) (*IssueComment, error) {
+	if len(body) > MaxCommentBody {
+		return nil, fmt.Errorf("comment is %d bytes, over GitHub's %d byte limit", len(body), MaxCommentBody)
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/comments/%d", c.config.BaseURL, owner, repo, id)
+	return doRequest[IssueComment](ctx, c, "PATCH", url, map[string]string{"body": body}, http.StatusOK)
+}
@@ -0,0 +1,141 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultConditionalCacheTTL is how long a response is kept for revalidation
+const DefaultConditionalCacheTTL = 24 * time.Hour
+
+// maxCachedBody bounds the responses kept for conditional requests
+const maxCachedBody = 1 << 20
+
+// conditionalCacheKeyPrefix namespaces the client's entries in a shared cache
+const conditionalCacheKeyPrefix = "github:conditional:"
+
+// uncachedPaths are endpoints whose responses are never cached: secret
+// scanning quotes credentials in forms the cache may not recognise, and
+// release assets can be far larger than anything else the API serves
+var uncachedPaths = []string{"/secret-scanning/", "/releases/assets/"}
+
+// cachedHeaders are the response headers replayed on a cache hit; rate limit
+// headers come from the 304 itself
+var cachedHeaders = []string{"Content-Type", "Link", "ETag", "Last-Modified"}
+
+// Cache stores the responses conditional requests revalidate. A
+// *cache.HierarchicalCache satisfies it.
+type Cache interface {
+	Get(ctx context.Context, key string) (interface{}, bool)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+}
+
+// cachedResponse is a GET response kept with its validators
+type cachedResponse struct {
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	Header       http.Header `json:"header"`
+	Body         string      `json:"body"` // A string rather than bytes, so the cache can spot credentials in it
+}
+
+// cacheable reports whether a request's response is kept for revalidation
+func (c *Client) cacheable(method, url string) bool {
+	if method != "GET" || c.config.Cache == nil {
+		return false
+	}
+	for _, path := range uncachedPaths {
+		if strings.Contains(url, path) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupResponse returns the cached response for a URL, if any. Entries are
+// stored as JSON strings so they survive the cache's L2 and L3 round trips.
+func (c *Client) lookupResponse(ctx context.Context, url string) *cachedResponse {
+	value, found := c.config.Cache.Get(ctx, conditionalCacheKeyPrefix+url)
+	if !found {
+		return nil
+	}
+	document, _ := value.(string)
+	var cached cachedResponse
+	if json.Unmarshal([]byte(document), &cached) != nil || (cached.ETag == "" && cached.LastModified == "") {
+		return nil
+	}
+	return &cached
+}
+
+// condition makes the request conditional on the cached response's validators
+func (r *cachedResponse) condition(req *http.Request) {
+	if r.ETag != "" {
+		req.Header.Set("If-None-Match", r.ETag)
+	}
+	if r.LastModified != "" {
+		req.Header.Set("If-Modified-Since", r.LastModified)
+	}
+}
+
+// replay answers a 304 with the cached response, keeping the 304's headers
+// for anything the cache doesn't hold
+func (r *cachedResponse) replay(notModified *http.Response) *http.Response {
+	notModified.Body.Close()
+	header := notModified.Header.Clone()
+	for name, values := range r.Header {
+		header[name] = values
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       notModified.Request,
+	}
+}
+
+// storeResponse caches a successful GET response carrying a validator and
+// returns it with its body restored. Responses too large to keep, or that the
+// cache refuses, e.g. for quoting a credential, are passed through.
+func (c *Client) storeResponse(ctx context.Context, url string, resp *http.Response) *http.Response {
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return resp
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBody+1))
+	if err != nil || len(body) > maxCachedBody {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	cached := cachedResponse{ETag: etag, LastModified: lastModified, Header: make(http.Header), Body: string(body)}
+	for _, name := range cachedHeaders {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			cached.Header[name] = values
+		}
+	}
+	document, err := json.Marshal(cached)
+	if err != nil {
+		return resp
+	}
+	ttl := c.config.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultConditionalCacheTTL
+	}
+	c.config.Cache.Set(ctx, conditionalCacheKeyPrefix+url, string(document), ttl)
+	return resp
+}
@@ -0,0 +1,244 @@
+package github
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dead-letter errors
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	ErrNotRequeueable     = errors.New("dead letter cannot be requeued by this process")
+)
+
+// DeadLetter is a request that failed permanently after exhausting its retries
+type DeadLetter struct {
+	ID          int64     `json:"id"`
+	RequestID   string    `json:"request_id"`
+	Priority    Priority  `json:"priority"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	EnqueuedAt  time.Time `json:"enqueued_at"`
+	FailedAt    time.Time `json:"failed_at"`
+	Requeueable bool      `json:"requeueable"` // Whether this process still holds the request's function
+}
+
+// DeadLetterStore persists dead letters in SQLite so they outlive the process
+type DeadLetterStore struct {
+	db *sql.DB
+}
+
+// NewDeadLetterStore creates a dead-letter store on the migrated database
+func NewDeadLetterStore(db *sql.DB) *DeadLetterStore {
+	return &DeadLetterStore{db: db}
+}
+
+// Add records a dead letter and returns its ID
+func (s *DeadLetterStore) Add(ctx context.Context, letter DeadLetter) (int64, error) {
+	insertSQL := `
+		INSERT INTO github_dead_letters (request_id, priority, attempts, last_error, enqueued_at, failed_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := s.db.ExecContext(ctx, insertSQL, letter.RequestID, letter.Priority, letter.Attempts,
+		letter.LastError, letter.EnqueuedAt.UTC(), letter.FailedAt.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to store dead letter %s: %w", letter.RequestID, err)
+	}
+	return result.LastInsertId()
+}
+
+// List returns up to limit dead letters, most recent first
+func (s *DeadLetterStore) List(ctx context.Context, limit int) ([]DeadLetter, error) {
+	query := `
+		SELECT id, request_id, priority, attempts, last_error, enqueued_at, failed_at
+		FROM github_dead_letters
+		ORDER BY failed_at DESC, id DESC
+		LIMIT ?
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	letters := []DeadLetter{}
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+// Get returns one dead letter
+func (s *DeadLetterStore) Get(ctx context.Context, id int64) (DeadLetter, error) {
+	query := `
+		SELECT id, request_id, priority, attempts, last_error, enqueued_at, failed_at
+		FROM github_dead_letters
+		WHERE id = ?
+	`
+
+	letter, err := scanDeadLetter(s.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return DeadLetter{}, ErrDeadLetterNotFound
+	}
+	return letter, err
+}
+
+// Delete removes one dead letter
+func (s *DeadLetterStore) Delete(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM github_dead_letters WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete dead letter %d: %w", id, err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// Purge removes every dead letter and returns how many there were
+func (s *DeadLetterStore) Purge(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM github_dead_letters")
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dead letters: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Count returns the number of stored dead letters
+func (s *DeadLetterStore) Count(ctx context.Context) (int64, error) {
+	var count int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM github_dead_letters").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+	return count, nil
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeadLetter(row rowScanner) (DeadLetter, error) {
+	var letter DeadLetter
+	err := row.Scan(&letter.ID, &letter.RequestID, &letter.Priority, &letter.Attempts,
+		&letter.LastError, &letter.EnqueuedAt, &letter.FailedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		err = fmt.Errorf("failed to scan dead letter: %w", err)
+	}
+	return letter, err
+}
+
+// DeadLetterHandler serves the queue's dead letters for operators:
+//
+//	GET    /                 lists dead letters (?limit=, default 100)
+//	POST   /{id}/requeue     enqueues the request again
+//	DELETE /{id}             discards one dead letter
+//	DELETE /                 purges every dead letter
+//
+// Mount it under a prefix with http.StripPrefix and behind admin
+// authentication.
+func (q *Queue) DeadLetterHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q.deadLetters == nil {
+			writeJSONError(w, http.StatusNotFound, "dead-letter store is not configured")
+			return
+		}
+
+		path := strings.Trim(r.URL.Path, "/")
+		switch {
+		case path == "" && r.Method == http.MethodGet:
+			q.handleListDeadLetters(w, r)
+		case path == "" && r.Method == http.MethodDelete:
+			purged, err := q.PurgeDeadLetters(r.Context())
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]int64{"purged": purged})
+		case strings.HasSuffix(path, "/requeue") && r.Method == http.MethodPost:
+			id, ok := parseDeadLetterID(w, strings.TrimSuffix(path, "/requeue"))
+			if !ok {
+				return
+			}
+			if _, err := q.Requeue(r.Context(), id); err != nil {
+				writeDeadLetterError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusAccepted, map[string]int64{"requeued": id})
+		case r.Method == http.MethodDelete:
+			id, ok := parseDeadLetterID(w, path)
+			if !ok {
+				return
+			}
+			if err := q.DiscardDeadLetter(r.Context(), id); err != nil {
+				writeDeadLetterError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+}
+
+func (q *Queue) handleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+
+	letters, err := q.DeadLetters(r.Context(), limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, letters)
+}
+
+func parseDeadLetterID(w http.ResponseWriter, value string) (int64, bool) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "not found")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeDeadLetterError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrDeadLetterNotFound):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrNotRequeueable):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	default:
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,52 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+// RepositorySBOM is a repository's dependency graph exported as SPDX
+type RepositorySBOM struct {
+	*sbom.Document
+	Data []byte // The SPDX JSON document, as sbom.Store.Save takes it
+}
+
+// GetRepositorySBOM exports a repository's dependency graph as an SPDX
+// document. The dependency graph must be enabled for the repository.
+func (c *Client) GetRepositorySBOM(ctx context.Context, owner, repo string) (*RepositorySBOM, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/dependency-graph/sbom", c.config.BaseURL, owner, repo)
+
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("dependency graph SBOM API returned status 404; the dependency graph may be disabled for %s/%s", owner, repo)
+	default:
+		return nil, fmt.Errorf("dependency graph SBOM API returned status %d", resp.StatusCode)
+	}
+
+	var export struct {
+		SBOM json.RawMessage `json:"sbom"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&export); err != nil {
+		return nil, err
+	}
+	if len(export.SBOM) == 0 {
+		return nil, fmt.Errorf("dependency graph SBOM API returned no document for %s/%s", owner, repo)
+	}
+
+	doc, err := sbom.Decode(export.SBOM)
+	if err != nil {
+		return nil, fmt.Errorf("dependency graph SBOM for %s/%s: %w", owner, repo, err)
+	}
+	return &RepositorySBOM{Document: doc, Data: export.SBOM}, nil
+}
@@ -0,0 +1,68 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Dependency change types
+const (
+	DependencyAdded   = "added"
+	DependencyRemoved = "removed"
+)
+
+// DependencyVulnerability is an advisory affecting a changed dependency
+type DependencyVulnerability struct {
+	Severity        string `json:"severity"` // critical, high, moderate or low
+	AdvisoryGHSAID  string `json:"advisory_ghsa_id"`
+	AdvisorySummary string `json:"advisory_summary"`
+	AdvisoryURL     string `json:"advisory_url"`
+}
+
+// DependencyChange is a dependency added or removed between two commits
+type DependencyChange struct {
+	ChangeType          string                    `json:"change_type"`
+	Manifest            string                    `json:"manifest"` // Repository path of the manifest declaring it
+	Ecosystem           string                    `json:"ecosystem"`
+	Name                string                    `json:"name"`
+	Version             string                    `json:"version"`
+	PackageURL          string                    `json:"package_url"`
+	License             string                    `json:"license"` // SPDX expression; empty when unknown
+	SourceRepositoryURL string                    `json:"source_repository_url"`
+	Scope               string                    `json:"scope"` // runtime, development or unknown
+	Vulnerabilities     []DependencyVulnerability `json:"vulnerabilities"`
+}
+
+// CompareDependencies returns the dependencies added and removed between
+// two commits, such as a pull request's base and head, with the advisories
+// affecting each
+func (c *Client) CompareDependencies(ctx context.Context, owner, repo, base, head string) ([]DependencyChange, error) {
+	if base == "" || head == "" {
+		return nil, fmt.Errorf("dependency review base and head are required")
+	}
+	basehead := url.PathEscape(base) + "..." + url.PathEscape(head)
+	url := fmt.Sprintf("%s/repos/%s/%s/dependency-graph/compare/%s", c.config.BaseURL, owner, repo, basehead)
+
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("dependency review API returned status 404; %s or %s may not exist, or the dependency graph is disabled", base, head)
+	default:
+		return nil, fmt.Errorf("dependency review API returned status %d", resp.StatusCode)
+	}
+
+	changes := []DependencyChange{}
+	if err := json.NewDecoder(resp.Body).Decode(&changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
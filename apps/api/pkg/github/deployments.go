@@ -0,0 +1,196 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Deployment status states
+const (
+	DeploymentStatePending    = "pending"
+	DeploymentStateQueued     = "queued"
+	DeploymentStateInProgress = "in_progress"
+	DeploymentStateSuccess    = "success"
+	DeploymentStateFailure    = "failure"
+	DeploymentStateError      = "error"
+	DeploymentStateInactive   = "inactive"
+)
+
+// Environment is a deployment environment of a repository
+type Environment struct {
+	ID              int64     `json:"id"`
+	Name            string    `json:"name"`
+	HTMLURL         string    `json:"html_url"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	ProtectionRules []struct {
+		ID        int64  `json:"id"`
+		Type      string `json:"type"` // required_reviewers, wait_timer or branch_policy
+		WaitTimer int    `json:"wait_timer,omitempty"`
+	} `json:"protection_rules"`
+	DeploymentBranchPolicy *struct {
+		ProtectedBranches    bool `json:"protected_branches"`
+		CustomBranchPolicies bool `json:"custom_branch_policies"`
+	} `json:"deployment_branch_policy"` // Nil when any branch may deploy
+}
+
+// Deployment is a request to deploy a ref to an environment
+type Deployment struct {
+	ID          int64           `json:"id"`
+	SHA         string          `json:"sha"`
+	Ref         string          `json:"ref"`
+	Task        string          `json:"task"`
+	Environment string          `json:"environment"`
+	Description string          `json:"description"`
+	Payload     json.RawMessage `json:"payload"`
+	Creator     struct {
+		Login string `json:"login"`
+	} `json:"creator"`
+	StatusesURL string    `json:"statuses_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DeploymentOptions creates a deployment
+type DeploymentOptions struct {
+	Ref         string      `json:"ref"` // Branch, tag or SHA
+	Task        string      `json:"task,omitempty"`
+	Environment string      `json:"environment,omitempty"` // production when empty
+	Description string      `json:"description,omitempty"`
+	Payload     interface{} `json:"payload,omitempty"`
+	// AutoMerge merges the default branch into ref first; GitHub's default
+	// is to, so it's sent as false unless set
+	AutoMerge bool `json:"auto_merge"`
+	// RequiredContexts are the commit statuses that must be successful on
+	// ref; all of them when nil, none when it points to an empty slice
+	RequiredContexts      *[]string `json:"required_contexts,omitempty"`
+	TransientEnvironment  bool      `json:"transient_environment,omitempty"`
+	ProductionEnvironment bool      `json:"production_environment,omitempty"`
+}
+
+// DeploymentQuery holds filters for listing a repository's deployments
+type DeploymentQuery struct {
+	SHA         string
+	Ref         string
+	Task        string
+	Environment string
+}
+
+// DeploymentStatus is a state of a deployment. Statuses accumulate; the
+// latest is the deployment's state.
+type DeploymentStatus struct {
+	ID             int64      `json:"id,omitempty"`
+	State          string     `json:"state"`
+	Description    string     `json:"description,omitempty"`
+	LogURL         string     `json:"log_url,omitempty"`         // The verification report
+	EnvironmentURL string     `json:"environment_url,omitempty"` // Where the deployment is served
+	Environment    string     `json:"environment,omitempty"`     // Moves the deployment to another environment
+	AutoInactive   *bool      `json:"auto_inactive,omitempty"`   // Whether success marks earlier deployments to the environment inactive; true when nil
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+}
+
+// ErrDeploymentConflict is returned creating a deployment whose required
+// commit statuses aren't all successful, or whose auto-merge failed
+var ErrDeploymentConflict = errors.New("deployment conflict")
+
+// ListEnvironments fetches a repository's deployment environments,
+// following pages up to the configured MaxPages
+func (c *Client) ListEnvironments(ctx context.Context, owner, repo string) ([]Environment, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/environments", c.config.BaseURL, owner, repo)
+
+	environments := []Environment{}
+	options := PageOptions{PerPage: MaxPerPage, MaxPages: c.config.MaxPages, ItemsKey: "environments"}
+	if err := c.Paginate(url, options).All(ctx, &environments); err != nil {
+		return nil, err
+	}
+	return environments, nil
+}
+
+// GetEnvironment fetches a deployment environment with its protection rules
+func (c *Client) GetEnvironment(ctx context.Context, owner, repo, name string) (*Environment, error) {
+	requestURL := fmt.Sprintf("%s/repos/%s/%s/environments/%s", c.config.BaseURL, owner, repo, url.PathEscape(name))
+	return doRequest[Environment](ctx, c, "GET", requestURL, nil, http.StatusOK)
+}
+
+// CreateDeployment creates a deployment of a ref. A deployment whose
+// required statuses aren't successful returns ErrDeploymentConflict.
+func (c *Client) CreateDeployment(ctx context.Context, owner, repo string, options DeploymentOptions) (*Deployment, error) {
+	if options.Ref == "" {
+		return nil, fmt.Errorf("deployment ref is required")
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/deployments", c.config.BaseURL, owner, repo)
+
+	deployment, err := doRequest[Deployment](ctx, c, "POST", url, options, http.StatusCreated)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusConflict:
+			return nil, fmt.Errorf("%w: %s's required statuses aren't all successful", ErrDeploymentConflict, options.Ref)
+		case http.StatusAccepted:
+			// The default branch was merged into ref; deploy again to deploy the merge
+			return nil, fmt.Errorf("%w: the default branch was merged into %s", ErrDeploymentConflict, options.Ref)
+		}
+	}
+	return deployment, err
+}
+
+// ListDeployments fetches a repository's deployments matching the query,
+// newest first, following pages up to the configured MaxPages
+func (c *Client) ListDeployments(ctx context.Context, owner, repo string, query DeploymentQuery) ([]Deployment, error) {
+	params := url.Values{}
+	if query.SHA != "" {
+		params.Set("sha", query.SHA)
+	}
+	if query.Ref != "" {
+		params.Set("ref", query.Ref)
+	}
+	if query.Task != "" {
+		params.Set("task", query.Task)
+	}
+	if query.Environment != "" {
+		params.Set("environment", query.Environment)
+	}
+	requestURL := fmt.Sprintf("%s/repos/%s/%s/deployments", c.config.BaseURL, owner, repo)
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+
+	deployments := []Deployment{}
+	err := c.Paginate(requestURL, PageOptions{PerPage: MaxPerPage, MaxPages: c.config.MaxPages}).All(ctx, &deployments)
+	if err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+// CreateDeploymentStatus adds a status to a deployment
+func (c *Client) CreateDeploymentStatus(ctx context.Context, owner, repo string, id int64, status DeploymentStatus) (*DeploymentStatus, error) {
+	switch status.State {
+	case DeploymentStatePending, DeploymentStateQueued, DeploymentStateInProgress, DeploymentStateSuccess,
+		DeploymentStateFailure, DeploymentStateError, DeploymentStateInactive:
+	default:
+		return nil, fmt.Errorf("invalid deployment status state %q", status.State)
+	}
+	status.ID, status.CreatedAt = 0, nil
+	status.Description = shorten(status.Description, MaxStatusDescription)
+
+	url := fmt.Sprintf("%s/repos/%s/%s/deployments/%d/statuses", c.config.BaseURL, owner, repo, id)
+	return doRequest[DeploymentStatus](ctx, c, "POST", url, status, http.StatusCreated)
+}
+
+// ListDeploymentStatuses fetches a deployment's statuses, newest first
+func (c *Client) ListDeploymentStatuses(ctx context.Context, owner, repo string, id int64) ([]DeploymentStatus, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/deployments/%d/statuses", c.config.BaseURL, owner, repo, id)
+
+	statuses := []DeploymentStatus{}
+	err := c.Paginate(url, PageOptions{PerPage: MaxPerPage, MaxPages: c.config.MaxPages}).All(ctx, &statuses)
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
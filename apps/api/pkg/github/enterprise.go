@@ -0,0 +1,113 @@
+package github
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// DefaultBaseURL is the root of the github.com REST API
+const DefaultBaseURL = "https://api.github.com"
+
+// DefaultAPIVersion is the REST API version requests ask for
+const DefaultAPIVersion = "2022-11-28"
+
+// Environment variables naming the API root. Actions sets GITHUB_API_URL to
+// the root of the server a workflow runs on.
+const (
+	APIURLEnv         = "KEYSTONE_GITHUB_API_URL"
+	WorkflowAPIURLEnv = "GITHUB_API_URL"
+)
+
+// enterprisePathPrefix is where GitHub Enterprise Server serves the REST API
+const enterprisePathPrefix = "/api/v3"
+
+// APIRoot returns the REST API root of a GitHub server, given either the
+// root itself or the server's URL. github.com maps to api.github.com, and a
+// GitHub Enterprise Server host without a path to its /api/v3 prefix.
+func APIRoot(serverURL string) (string, error) {
+	root, err := url.Parse(strings.TrimSpace(serverURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid GitHub API URL %q: %w", serverURL, err)
+	}
+	if (root.Scheme != "https" && root.Scheme != "http") || root.Host == "" {
+		return "", fmt.Errorf("invalid GitHub API URL %q: expected an http(s) URL", serverURL)
+	}
+
+	root.Path = strings.TrimSuffix(root.Path, "/")
+	root.RawQuery, root.Fragment = "", ""
+	switch {
+	case strings.EqualFold(root.Host, "github.com"):
+		return DefaultBaseURL, nil
+	case strings.EqualFold(root.Host, "api.github.com"):
+	case root.Path == "" || root.Path == "/api":
+		root.Path = enterprisePathPrefix
+	}
+	return root.String(), nil
+}
+
+// IsEnterprise reports whether an API root is a GitHub Enterprise Server's
+func IsEnterprise(baseURL string) bool {
+	root, err := url.Parse(baseURL)
+	return err == nil && strings.HasPrefix(root.Path, enterprisePathPrefix)
+}
+
+// baseURLFromEnv returns the configured API root, or github.com's
+func baseURLFromEnv() (string, error) {
+	for _, name := range []string{APIURLEnv, WorkflowAPIURLEnv} {
+		if value := os.Getenv(name); value != "" {
+			return APIRoot(value)
+		}
+	}
+	return DefaultBaseURL, nil
+}
+
+// APIVersion returns the REST API version requests ask for; empty once the
+// server turned it down, so requests get the server's default
+func (c *Client) APIVersion() string {
+	c.versionMutex.Lock()
+	defer c.versionMutex.Unlock()
+	return c.apiVersion
+}
+
+// EnterpriseVersion returns the GitHub Enterprise Server release the API
+// last reported, e.g. 3.12.1; empty for github.com
+func (c *Client) EnterpriseVersion() string {
+	c.versionMutex.Lock()
+	defer c.versionMutex.Unlock()
+	return c.enterpriseVersion
+}
+
+// recordVersion notes the server release a response reports
+func (c *Client) recordVersion(resp *http.Response) {
+	if version := resp.Header.Get("X-GitHub-Enterprise-Version"); version != "" {
+		c.versionMutex.Lock()
+		c.enterpriseVersion = version
+		c.versionMutex.Unlock()
+	}
+}
+
+// versionRejected reports whether a response turned down the requested API
+// version, which servers predating it do, and stops asking for it. The
+// request can then be retried with the server's default version.
+func (c *Client) versionRejected(requested string, resp *http.Response) bool {
+	if requested == "" || resp.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body = io.NopCloser(strings.NewReader(string(body)))
+	message := strings.ToLower(string(body))
+	if !strings.Contains(message, "api version") && !strings.Contains(message, "x-github-api-version") {
+		return false
+	}
+
+	c.versionMutex.Lock()
+	defer c.versionMutex.Unlock()
+	if c.apiVersion == requested {
+		c.apiVersion = ""
+	}
+	return true
+}
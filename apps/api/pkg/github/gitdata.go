@@ -0,0 +1,149 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Tree entry types
+const (
+	TreeEntryBlob   = "blob"
+	TreeEntryTree   = "tree"
+	TreeEntryCommit = "commit" // A submodule
+)
+
+// ErrFileNotFound is returned fetching a file that doesn't exist at the ref,
+// e.g. a repository without a .keystone/policy.yaml
+var ErrFileNotFound = errors.New("file not found")
+
+// FileContent is a file in a repository at a ref
+type FileContent struct {
+	Path    string
+	SHA     string // The blob SHA
+	Size    int64
+	Content []byte
+}
+
+// Tree is a repository's directory tree at a commit
+type Tree struct {
+	SHA       string      `json:"sha"`
+	Truncated bool        `json:"truncated"` // The tree had more entries than GitHub returns at once
+	Entries   []TreeEntry `json:"tree"`
+}
+
+// TreeEntry is a file, directory or submodule in a tree
+type TreeEntry struct {
+	Path string `json:"path"` // Relative to the tree's root
+	Mode string `json:"mode"` // e.g. 100644, or 100755 for executables
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+	Size int64  `json:"size,omitempty"` // Blobs only
+}
+
+// Files returns the blobs in dir, or in its subdirectories for a recursive
+// tree, e.g. Files(".github/workflows") for the workflow files
+func (t *Tree) Files(dir string) []TreeEntry {
+	prefix := strings.Trim(dir, "/") + "/"
+	if prefix == "/" {
+		prefix = ""
+	}
+	var files []TreeEntry
+	for _, entry := range t.Entries {
+		if entry.Type == TreeEntryBlob && strings.HasPrefix(entry.Path, prefix) {
+			files = append(files, entry)
+		}
+	}
+	return files
+}
+
+// GetFileContent fetches a file at a ref: a commit SHA, branch or tag. The
+// default branch is used when ref is empty; pin a SHA to read the file a
+// build actually used. A missing file returns ErrFileNotFound.
+//
+// With a cache configured, refetching a file is a conditional request GitHub
+// doesn't count against the rate limit.
+func (c *Client) GetFileContent(ctx context.Context, owner, repo, filePath, ref string) (*FileContent, error) {
+	requestURL := fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.config.BaseURL, owner, repo, escapePath(strings.TrimPrefix(filePath, "/")))
+	if ref != "" {
+		requestURL += "?ref=" + url.QueryEscape(ref)
+	}
+
+	type contents struct {
+		Type     string `json:"type"`
+		Path     string `json:"path"`
+		SHA      string `json:"sha"`
+		Size     int64  `json:"size"`
+		Encoding string `json:"encoding"`
+		Content  string `json:"content"`
+	}
+	file, err := doRequest[contents](ctx, c, "GET", requestURL, nil, http.StatusOK)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", filePath, ErrFileNotFound)
+	}
+	if err != nil {
+		// A directory's listing is an array, which doesn't decode
+		return nil, err
+	}
+	if file.Type != "file" {
+		return nil, fmt.Errorf("%s is a %s, not a file", filePath, file.Type)
+	}
+
+	// Files over 1 MB come without content; their blob has it
+	if file.Encoding == "none" {
+		blobURL := fmt.Sprintf("%s/repos/%s/%s/git/blobs/%s", c.config.BaseURL, owner, repo, file.SHA)
+		blob, err := doRequest[contents](ctx, c, "GET", blobURL, nil, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		file.Encoding, file.Content = blob.Encoding, blob.Content
+	}
+	if file.Encoding != "base64" {
+		return nil, fmt.Errorf("%s has unsupported encoding %q", filePath, file.Encoding)
+	}
+	// GitHub wraps the base64 at 60 characters
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", filePath, err)
+	}
+	return &FileContent{Path: file.Path, SHA: file.SHA, Size: file.Size, Content: content}, nil
+}
+
+// GetTree fetches the tree of a commit SHA, branch or tree SHA; recursive
+// includes every subdirectory's entries. GitHub truncates very large
+// recursive trees, reported by Tree.Truncated.
+func (c *Client) GetTree(ctx context.Context, owner, repo, sha string, recursive bool) (*Tree, error) {
+	requestURL := fmt.Sprintf("%s/repos/%s/%s/git/trees/%s", c.config.BaseURL, owner, repo, url.PathEscape(sha))
+	if recursive {
+		requestURL += "?recursive=1"
+	}
+	return doRequest[Tree](ctx, c, "GET", requestURL, nil, http.StatusOK)
+}
+
+// GetWorkflowFiles fetches the workflow files of a repository at a ref, by
+// path, e.g. to compare a run's workflow with the one provenance names
+func (c *Client) GetWorkflowFiles(ctx context.Context, owner, repo, ref string) (map[string][]byte, error) {
+	tree, err := c.GetTree(ctx, owner, repo, ref, true)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	for _, entry := range tree.Files(".github/workflows") {
+		// Workflows in subdirectories aren't run
+		if ext := path.Ext(entry.Path); path.Dir(entry.Path) != ".github/workflows" || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+		file, err := c.GetFileContent(ctx, owner, repo, entry.Path, ref)
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Path] = file.Content
+	}
+	return files, nil
+}
@@ -0,0 +1,102 @@
+// Package githubtest provides a harness for testing code built on the GitHub
+// client and request queue: a stub API server, a client and queue wired to it,
+// and a fake clock that drives backoff, retries and batching without sleeping
+package githubtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// Epoch is the fake clock's starting time
+var Epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// awaitTimeout bounds how long Await waits in real time before failing the test
+const awaitTimeout = 10 * time.Second
+
+// Harness is a GitHub client and started queue talking to a stub server, all
+// timed by a fake clock
+type Harness struct {
+	Server *httptest.Server
+	Clock  *clock.Fake
+	Client *github.Client
+	Queue  *github.Queue
+
+	mux      *http.ServeMux
+	mutex    sync.Mutex
+	requests map[string]int
+}
+
+// New starts a harness; the queue config's clock is replaced with the fake
+// clock. The queue and server are stopped when the test finishes.
+func New(t testing.TB, config github.QueueConfig) *Harness {
+	t.Helper()
+
+	h := &Harness{
+		Clock:    clock.NewFake(Epoch),
+		mux:      http.NewServeMux(),
+		requests: make(map[string]int),
+	}
+	h.Server = httptest.NewServer(http.HandlerFunc(h.serve))
+
+	clientConfig := github.DefaultConfig("test-token")
+	clientConfig.BaseURL = h.Server.URL
+	clientConfig.Clock = h.Clock
+	h.Client = github.NewClient(clientConfig)
+
+	config.Clock = h.Clock
+	h.Queue = github.NewQueue(h.Client, config)
+	h.Queue.Start()
+
+	t.Cleanup(func() {
+		h.Queue.Stop()
+		h.Server.Close()
+	})
+	return h
+}
+
+// Handle registers a stub for an API path, e.g. /repos/owner/repo
+func (h *Harness) Handle(pattern string, handler http.HandlerFunc) {
+	h.mux.HandleFunc(pattern, handler)
+}
+
+// Requests returns how many requests the server has received for a path
+func (h *Harness) Requests(path string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.requests[path]
+}
+
+func (h *Harness) serve(w http.ResponseWriter, r *http.Request) {
+	h.mutex.Lock()
+	h.requests[r.URL.Path]++
+	h.mutex.Unlock()
+	h.mux.ServeHTTP(w, r)
+}
+
+// Await advances the fake clock in steps until the queued request completes
+// and returns its result. The test fails if it doesn't complete within ten
+// seconds of real time.
+func (h *Harness) Await(t testing.TB, result <-chan error, step time.Duration) error {
+	t.Helper()
+
+	deadline := time.Now().Add(awaitTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-result:
+			return err
+		default:
+		}
+		h.Clock.Advance(step)
+		// Let the goroutines woken by the advance run before the next step
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("queued request did not complete within %s", awaitTimeout)
+	return nil
+}
@@ -0,0 +1,123 @@
+package github
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/salman-frs/keystone/apps/api/internal/metrics"
+)
+
+// Metric names registered by a client with Config.Metrics
+const (
+	MetricRequests            = "keystone_github_requests_total"
+	MetricRequestDuration     = "keystone_github_request_duration_seconds"
+	MetricRateLimitRemaining  = "keystone_github_rate_limit_remaining"
+	MetricCircuitBreakerState = "keystone_github_circuit_breaker_state"
+)
+
+// instrument registers the client's metrics, returning the middleware that
+// records each request. Clients sharing a registry add up their requests;
+// the gauges report the client created last.
+func (c *Client) instrument(registry *metrics.Registry) Middleware {
+	requests := registry.Counter(MetricRequests,
+		"GitHub API requests by method, endpoint and status code; code is error when no response arrived",
+		"method", "endpoint", "code")
+	durations := registry.Histogram(MetricRequestDuration,
+		"GitHub API request latency in seconds, up to the response headers",
+		metrics.DefaultBuckets, "method", "endpoint")
+	registry.GaugeFunc(MetricRateLimitRemaining,
+		"Requests left in the current GitHub rate limit window",
+		func() map[string]float64 {
+			rateLimit := c.rateLimit()
+			if rateLimit == nil {
+				return nil
+			}
+			return map[string]float64{"": float64(rateLimit.Remaining)}
+		})
+	registry.GaugeFunc(MetricCircuitBreakerState,
+		"GitHub circuit breaker state: 0 closed, 1 open, 2 half-open",
+		func() map[string]float64 {
+			return map[string]float64{"": float64(c.circuitBreaker.State())}
+		})
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			endpoint := c.endpoint(req)
+			start := c.clock.Now()
+			resp, err := next.RoundTrip(req)
+			durations.Observe(c.clock.Since(start).Seconds(), req.Method, endpoint)
+
+			code := "error"
+			if err == nil {
+				code = strconv.Itoa(resp.StatusCode)
+			}
+			requests.With(req.Method, endpoint, code).Inc()
+			return resp, err
+		})
+	}
+}
+
+var (
+	// idSegment matches numeric IDs and hex SHAs and digests
+	idSegment = regexp.MustCompile(`^([0-9]+|[0-9a-f]{40}|[0-9a-f]{64}|sha256:[0-9a-f]{64})$`)
+
+	// namedSegments are the path segments naming something, by the segment
+	// before them, replaced by a placeholder so labels don't grow with every
+	// repository and branch. A trailing "*" takes the rest of the path too.
+	namedSegments = map[string]string{
+		"repos":        "{owner}/{repo}",
+		"orgs":         "{org}",
+		"users":        "{user}",
+		"enterprises":  "{enterprise}",
+		"branches":     "{branch}",
+		"environments": "{environment}",
+		"commits":      "{ref}",
+		"compare":      "{basehead}",
+		"trees":        "{sha}",
+		"blobs":        "{sha}",
+		"tags":         "{tag}",
+		"packages":     "{package_type}/{package}",
+		"contents":     "{path}*",
+		"ref":          "{ref}*",
+		"refs":         "{ref}*",
+	}
+)
+
+// endpoint returns a request's path as a metric label, with identifying
+// segments replaced, e.g. /repos/{owner}/{repo}/commits/{ref}/check-runs.
+// Requests outside the API, such as artifact downloads redirected to
+// storage, are "other".
+func (c *Client) endpoint(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if base, err := req.URL.Parse(c.config.BaseURL); err == nil {
+		if req.URL.Host != base.Host || !strings.HasPrefix(path, base.EscapedPath()) {
+			return "other"
+		}
+		path = strings.TrimPrefix(path, strings.TrimSuffix(base.EscapedPath(), "/"))
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var label []string
+	for i := 0; i < len(segments); i++ {
+		segment := segments[i]
+		if idSegment.MatchString(segment) {
+			label = append(label, "{id}")
+			continue
+		}
+		label = append(label, segment)
+
+		placeholder, named := namedSegments[segment]
+		if !named || i+1 == len(segments) {
+			continue
+		}
+		if strings.HasSuffix(placeholder, "*") {
+			label = append(label, strings.TrimSuffix(placeholder, "*"))
+			break
+		}
+		label = append(label, placeholder)
+		i += strings.Count(placeholder, "/") + 1
+	}
+	return "/" + strings.Join(label, "/")
+}
@@ -0,0 +1,51 @@
+package github
+
+import "net/http"
+
+// Middleware wraps the transport every request the client makes goes
+// through, including retries and installation token requests. It sees each
+// request once the client has set its headers, and each response before the
+// client reads it, so it can log, measure, record or add headers without
+// changing the client.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to http.RoundTripper, for writing
+// middleware
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wraps transport in the middleware; the first listed is outermost,
+// so it sees requests first and responses last. A nil transport is
+// http.DefaultTransport.
+func Chain(transport http.RoundTripper, middleware ...Middleware) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		transport = middleware[i](transport)
+	}
+	return transport
+}
+
+// Use adds middleware outside any already in place, so it sees requests
+// first. It must be called before the client makes requests.
+func (c *Client) Use(middleware ...Middleware) {
+	c.httpClient.Transport = Chain(c.httpClient.Transport, middleware...)
+}
+
+// SetHeader returns middleware setting a header on every request, replacing
+// the client's value, e.g. a User-Agent naming the deployment
+func SetHeader(name, value string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			// A RoundTripper mustn't modify the request it's given
+			req = req.Clone(req.Context())
+			req.Header.Set(name, value)
+			return next.RoundTrip(req)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package github
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Advisory is a GitHub security advisory, from the global advisory database
+// or a repository's own advisories
+type Advisory struct {
+	GHSAID          string                  `json:"ghsa_id"`
+	CVEID           string                  `json:"cve_id,omitempty"`
+	URL             string                  `json:"url,omitempty"`
+	HTMLURL         string                  `json:"html_url,omitempty"`
+	Type            string                  `json:"type,omitempty"` // reviewed, unreviewed or malware
+	Summary         string                  `json:"summary"`
+	Description     string                  `json:"description,omitempty"`
+	Severity        string                  `json:"severity"` // critical, high, medium or low
+	Identifiers     []AdvisoryIdentifier    `json:"identifiers,omitempty"`
+	References      []string                `json:"references,omitempty"`
+	PublishedAt     *time.Time              `json:"published_at,omitempty"`
+	UpdatedAt       *time.Time              `json:"updated_at,omitempty"`
+	WithdrawnAt     *time.Time              `json:"withdrawn_at,omitempty"`
+	Vulnerabilities []AdvisoryVulnerability `json:"vulnerabilities,omitempty"`
+	CVSS            *struct {
+		Score        float64 `json:"score"`
+		VectorString string  `json:"vector_string"`
+	} `json:"cvss,omitempty"`
+	CWEs []struct {
+		CWEID string `json:"cwe_id"`
+		Name  string `json:"name"`
+	} `json:"cwes,omitempty"`
+
+	// Raw is the advisory as GitHub served it, fields this struct lacks
+	// included, so stored copies keep everything
+	Raw json.RawMessage `json:"-"`
+}
+
+// AdvisoryIdentifier is one of an advisory's IDs, e.g. its GHSA or CVE ID
+type AdvisoryIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// AdvisoryVulnerability is a package and the versions an advisory affects
+type AdvisoryVulnerability struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	VulnerableVersionRange string         `json:"vulnerable_version_range"`
+	FirstPatchedVersion    PatchedVersion `json:"first_patched_version"`
+	VulnerableFunctions    []string       `json:"vulnerable_functions,omitempty"`
+}
+
+// PatchedVersion is the first version fixing a vulnerability. GitHub serves
+// it as a string, or as an object with an identifier on older endpoints.
+type PatchedVersion string
+
+// UnmarshalJSON accepts either form
+func (v *PatchedVersion) UnmarshalJSON(data []byte) error {
+	var version string
+	if json.Unmarshal(data, &version) == nil {
+		*v = PatchedVersion(version)
+		return nil
+	}
+	var object struct {
+		Identifier string `json:"identifier"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*v = PatchedVersion(object.Identifier)
+	return nil
+}
+
+// UnmarshalJSON decodes an advisory and keeps the document in Raw
+func (a *Advisory) UnmarshalJSON(data []byte) error {
+	type advisory Advisory // Without this method, so decoding doesn't recurse
+	var decoded advisory
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*a = Advisory(decoded)
+	a.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// Document returns the advisory as GitHub served it, with its keys sorted so
+// an unchanged advisory always encodes the same. Advisories that weren't
+// decoded from a response are encoded from their fields.
+func (a Advisory) Document() ([]byte, error) {
+	if len(a.Raw) == 0 {
+		type advisory Advisory
+		return json.Marshal(advisory(a))
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(a.Raw, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// Repository is a GitHub repository
+type Repository struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"` // owner/repo
+	Description   string `json:"description"`
+	HTMLURL       string `json:"html_url"`
+	DefaultBranch string `json:"default_branch"`
+	Private       bool   `json:"private"`
+	Visibility    string `json:"visibility"` // public, private or internal
+	Archived      bool   `json:"archived"`
+	Fork          bool   `json:"fork"`
+	Owner         struct {
+		Login string `json:"login"`
+		Type  string `json:"type"` // User or Organization
+	} `json:"owner"`
+	PushedAt *time.Time `json:"pushed_at,omitempty"`
+}
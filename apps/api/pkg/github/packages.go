@@ -0,0 +1,195 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AttestationTagSuffixes end the digest-addressed tags cosign-compatible
+// tools give attestations, signatures and SBOMs, e.g. sha256-<hex>.att
+var AttestationTagSuffixes = []string{".att", ".sig", ".sbom"}
+
+// PackageVersion is a version of a GitHub Packages container, i.e. a
+// manifest pushed to ghcr.io
+type PackageVersion struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"` // The manifest digest, e.g. sha256:...
+	HTMLURL   string    `json:"html_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Metadata  struct {
+		PackageType string `json:"package_type"`
+		Container   struct {
+			Tags []string `json:"tags"`
+		} `json:"container"`
+	} `json:"metadata"`
+}
+
+// Tags returns the version's tags
+func (v PackageVersion) Tags() []string {
+	return v.Metadata.Container.Tags
+}
+
+// IsAttestation reports whether the version is tagged, and only tagged, as
+// an attestation, signature or SBOM of another manifest
+func (v PackageVersion) IsAttestation() bool {
+	if len(v.Tags()) == 0 {
+		return false
+	}
+	for _, tag := range v.Tags() {
+		if !strings.HasPrefix(tag, "sha256-") || !hasAttestationSuffix(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasAttestationSuffix(tag string) bool {
+	for _, suffix := range AttestationTagSuffixes {
+		if strings.HasSuffix(tag, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RetentionPolicy selects container versions to delete. Versions tagged
+// anything but an attestation tag, such as releases, are always kept.
+type RetentionPolicy struct {
+	// MaxAge expires attestation versions last updated longer ago; 0 keeps
+	// them all
+	MaxAge time.Duration
+	// UntaggedAge expires untagged versions last updated longer ago; 0
+	// keeps them all. Multi-platform images and OCI 1.1 referrers are
+	// untagged too, so this should comfortably exceed MaxAge.
+	UntaggedAge time.Duration
+	DryRun      bool // List the expired versions without deleting them
+}
+
+// Expired reports whether the policy deletes the version as of now
+func (p RetentionPolicy) Expired(version PackageVersion, now time.Time) bool {
+	age := now.Sub(version.UpdatedAt)
+	switch {
+	case len(version.Tags()) == 0:
+		return p.UntaggedAge > 0 && age > p.UntaggedAge
+	case version.IsAttestation():
+		return p.MaxAge > 0 && age > p.MaxAge
+	default:
+		return false
+	}
+}
+
+// ListPackageVersions fetches a container package's versions, newest first,
+// following pages up to the configured MaxPages. The package name is the
+// repository path under the owner, e.g. widgets or widgets/api.
+func (c *Client) ListPackageVersions(ctx context.Context, owner, name string) ([]PackageVersion, error) {
+	packageURL, err := c.packageURL(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	return c.listPackageVersions(ctx, packageURL)
+}
+
+// listPackageVersions fetches the versions of the package at packageURL
+func (c *Client) listPackageVersions(ctx context.Context, packageURL string) ([]PackageVersion, error) {
+	versions := []PackageVersion{}
+	err := c.Paginate(packageURL+"/versions", PageOptions{PerPage: MaxPerPage, MaxPages: c.config.MaxPages}).All(ctx, &versions)
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetPackageVersion fetches a container package version
+func (c *Client) GetPackageVersion(ctx context.Context, owner, name string, id int64) (*PackageVersion, error) {
+	packageURL, err := c.packageURL(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	return doRequest[PackageVersion](ctx, c, "GET", fmt.Sprintf("%s/versions/%d", packageURL, id), nil, http.StatusOK)
+}
+
+// DeletePackageVersion deletes a container package version. GitHub refuses
+// to delete the last version of a package with more than 5000 downloads.
+func (c *Client) DeletePackageVersion(ctx context.Context, owner, name string, id int64) error {
+	packageURL, err := c.packageURL(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+	return c.deletePackageVersion(ctx, packageURL, name, id)
+}
+
+// deletePackageVersion deletes a version of the package at packageURL
+func (c *Client) deletePackageVersion(ctx context.Context, packageURL, name string, id int64) error {
+	url := fmt.Sprintf("%s/versions/%d", packageURL, id)
+
+	resp, err := c.makeRequest(ctx, "DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotFound:
+		return nil
+	case http.StatusBadRequest:
+		return fmt.Errorf("packages API refused to delete version %d of %s; it may be the last version of a popular package", id, name)
+	default:
+		return &StatusError{Method: "DELETE", URL: url, StatusCode: resp.StatusCode}
+	}
+}
+
+// PrunePackageVersions deletes the container versions the policy expires,
+// returning them. Deletion stops at the first failure.
+func (c *Client) PrunePackageVersions(ctx context.Context, owner, name string, policy RetentionPolicy) ([]PackageVersion, error) {
+	packageURL, err := c.packageURL(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := c.listPackageVersions(ctx, packageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.clock.Now()
+	var expired []PackageVersion
+	for _, version := range versions {
+		if !policy.Expired(version, now) {
+			continue
+		}
+		if !policy.DryRun {
+			if err := c.deletePackageVersion(ctx, packageURL, name, version.ID); err != nil {
+				return expired, err
+			}
+		}
+		expired = append(expired, version)
+	}
+	return expired, nil
+}
+
+// packageURL returns the API URL of an owner's container package. Packages
+// are addressed under /orgs or /users depending on the owner's type.
+func (c *Client) packageURL(ctx context.Context, owner, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("package name is required")
+	}
+	scope := "orgs"
+	account, err := doRequest[struct {
+		Type string `json:"type"`
+	}](ctx, c, "GET", fmt.Sprintf("%s/users/%s", c.config.BaseURL, url.PathEscape(owner)), nil, http.StatusOK)
+	var statusErr *StatusError
+	switch {
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("package owner %s not found", owner)
+	case err != nil:
+		return "", err
+	case account.Type == "User":
+		scope = "users"
+	}
+	return fmt.Sprintf("%s/%s/%s/packages/container/%s", c.config.BaseURL, scope, url.PathEscape(owner), url.PathEscape(name)), nil
+}
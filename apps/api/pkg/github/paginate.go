@@ -0,0 +1,192 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MaxPerPage is the largest page GitHub serves
+const MaxPerPage = 100
+
+// PageOptions controls how a list endpoint is paged
+type PageOptions struct {
+	PerPage  int    // Items per page, up to MaxPerPage; GitHub's default of 30 when zero
+	MaxPages int    // Pages fetched at most; unlimited when zero
+	ItemsKey string // Field holding the items of endpoints wrapping them in an object, e.g. workflow_runs
+}
+
+// Paginator walks a list endpoint, following the rel="next" entry of each
+// response's Link header. Next steps through items one at a time; All
+// collects them.
+//
+//	pages := client.Paginate(url, github.PageOptions{PerPage: 100})
+//	for pages.Next(ctx) {
+//		var advisory Advisory
+//		if err := pages.Decode(&advisory); err != nil { ... }
+//	}
+//	if err := pages.Err(); err != nil { ... }
+type Paginator struct {
+	client  *Client
+	options PageOptions
+	next    string // URL of the next page; empty once the last has been fetched
+	pages   int
+
+	items []json.RawMessage
+	item  json.RawMessage
+	err   error
+}
+
+// Paginate returns a paginator over the list endpoint at requestURL. The
+// per_page parameter is added when PerPage is set.
+func (c *Client) Paginate(requestURL string, options PageOptions) *Paginator {
+	if options.PerPage > MaxPerPage {
+		options.PerPage = MaxPerPage
+	}
+	p := &Paginator{client: c, options: options, next: requestURL}
+	if options.PerPage > 0 {
+		p.next, p.err = setQuery(requestURL, "per_page", strconv.Itoa(options.PerPage))
+	}
+	return p
+}
+
+// Next advances to the next item, fetching the next page once the current
+// one is used up. It returns false after the last item or on an error,
+// which Err reports.
+func (p *Paginator) Next(ctx context.Context) bool {
+	for len(p.items) == 0 {
+		if p.err != nil || !p.more() {
+			p.item = nil
+			return false
+		}
+		p.items, p.err = p.fetch(ctx)
+	}
+	p.item, p.items = p.items[0], p.items[1:]
+	return true
+}
+
+// Decode decodes the current item into v
+func (p *Paginator) Decode(v interface{}) error {
+	if p.item == nil {
+		return fmt.Errorf("paginator has no current item")
+	}
+	return json.Unmarshal(p.item, v)
+}
+
+// Err returns the error that stopped the paginator, if any
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+// Pages returns how many pages have been fetched
+func (p *Paginator) Pages() int {
+	return p.pages
+}
+
+// All fetches the remaining pages and decodes their items into v, a pointer
+// to a slice
+func (p *Paginator) All(ctx context.Context, v interface{}) error {
+	items := p.items
+	p.items = nil
+	for p.err == nil && p.more() {
+		var page []json.RawMessage
+		if page, p.err = p.fetch(ctx); p.err == nil {
+			items = append(items, page...)
+		}
+	}
+	if p.err != nil {
+		return p.err
+	}
+
+	all, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	if items == nil {
+		all = []byte("[]")
+	}
+	return json.Unmarshal(all, v)
+}
+
+// more reports whether another page may be fetched
+func (p *Paginator) more() bool {
+	return p.next != "" && (p.options.MaxPages <= 0 || p.pages < p.options.MaxPages)
+}
+
+// fetch fetches the next page and records the page after it
+func (p *Paginator) fetch(ctx context.Context) ([]json.RawMessage, error) {
+	resp, err := p.client.makeRequest(ctx, "GET", p.next, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list API returned status %d", resp.StatusCode)
+	}
+
+	var items []json.RawMessage
+	if p.options.ItemsKey == "" {
+		err = json.NewDecoder(resp.Body).Decode(&items)
+	} else {
+		var wrapper map[string]json.RawMessage
+		if err = json.NewDecoder(resp.Body).Decode(&wrapper); err == nil && wrapper[p.options.ItemsKey] != nil {
+			err = json.Unmarshal(wrapper[p.options.ItemsKey], &items)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p.pages++
+	p.next = linkURL(resp.Header.Get("Link"), "next")
+	if p.next != "" && !p.client.sameOrigin(p.next) {
+		// The token is only ever sent to the configured API
+		return nil, fmt.Errorf("next page %s is outside %s", p.next, p.client.config.BaseURL)
+	}
+	return items, nil
+}
+
+// sameOrigin reports whether a URL is served by the configured API, on the
+// same host and under the same path prefix, e.g. a GitHub Enterprise
+// Server's /api/v3
+func (c *Client) sameOrigin(rawURL string) bool {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	base, err := url.Parse(c.config.BaseURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(target.Scheme, base.Scheme) && strings.EqualFold(target.Host, base.Host) &&
+		strings.HasPrefix(target.Path, base.Path+"/")
+}
+
+// linkURL returns the target of a Link header entry with the given rel
+func linkURL(link, rel string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, found := strings.Cut(part, ";")
+		if !found || !strings.Contains(params, `rel="`+rel+`"`) {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(target), "<>")
+	}
+	return ""
+}
+
+// setQuery sets a query parameter of a URL
+func setQuery(rawURL, key, value string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	query.Set(key, value)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
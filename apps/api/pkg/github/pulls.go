@@ -0,0 +1,229 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FileChange is a file to create or replace on a branch
+type FileChange struct {
+	Path    string // Repository-relative, slash separated
+	Content []byte
+}
+
+// PullRequestChange describes files to commit to a new branch and the pull
+// request proposing them
+type PullRequestChange struct {
+	Base    string // Branch the pull request targets; the repository default when empty
+	Head    string // Branch created for the change; must not exist yet
+	Title   string
+	Body    string
+	Message string // Commit message for each file; defaults to the title
+	Files   []FileChange
+}
+
+// PullRequest is an opened pull request
+type PullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Head    string `json:"-"`
+	Base    string `json:"-"`
+}
+
+// OpenPullRequest creates the head branch from the base branch, commits each
+// file to it and opens a pull request
+func (c *Client) OpenPullRequest(ctx context.Context, owner, repo string, change PullRequestChange) (*PullRequest, error) {
+	if change.Head == "" || change.Title == "" {
+		return nil, fmt.Errorf("pull request head branch and title are required")
+	}
+	if change.Message == "" {
+		change.Message = change.Title
+	}
+
+	base := change.Base
+	if base == "" {
+		var err error
+		if base, err = c.DefaultBranch(ctx, owner, repo); err != nil {
+			return nil, err
+		}
+	}
+
+	sha, err := c.GetBranchSHA(ctx, owner, repo, base)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.CreateBranch(ctx, owner, repo, change.Head, sha); err != nil {
+		return nil, err
+	}
+	for _, file := range change.Files {
+		if err := c.PutFile(ctx, owner, repo, change.Head, change.Message, file); err != nil {
+			return nil, err
+		}
+	}
+	return c.CreatePullRequest(ctx, owner, repo, base, change.Head, change.Title, change.Body)
+}
+
+// DefaultBranch returns the repository's default branch
+func (c *Client) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	repository, err := c.GetRepository(ctx, owner, repo)
+	if err != nil {
+		return "", err
+	}
+	if repository.DefaultBranch == "" {
+		return "", fmt.Errorf("repository %s/%s has no default branch", owner, repo)
+	}
+	return repository.DefaultBranch, nil
+}
+
+// GetBranchSHA returns the commit SHA a branch points to
+func (c *Client) GetBranchSHA(ctx context.Context, owner, repo, branch string) (string, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/ref/heads/%s", c.config.BaseURL, owner, repo, branch)
+
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("git ref API returned status %d for branch %s", resp.StatusCode, branch)
+	}
+
+	var ref struct {
+		Object struct {
+			SHA string `json:"sha"`
+		} `json:"object"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ref); err != nil {
+		return "", err
+	}
+	return ref.Object.SHA, nil
+}
+
+// CreateBranch creates a branch pointing at a commit
+func (c *Client) CreateBranch(ctx context.Context, owner, repo, branch, sha string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/refs", c.config.BaseURL, owner, repo)
+
+	resp, err := c.sendJSON(ctx, "POST", url, map[string]string{
+		"ref": "refs/heads/" + branch,
+		"sha": sha,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return nil
+	case http.StatusUnprocessableEntity:
+		return fmt.Errorf("branch %s already exists", branch)
+	default:
+		return fmt.Errorf("git refs API returned status %d", resp.StatusCode)
+	}
+}
+
+// PutFile commits a file to a branch, replacing it if it already exists there
+func (c *Client) PutFile(ctx context.Context, owner, repo, branch, message string, file FileChange) error {
+	contentsURL := fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.config.BaseURL, owner, repo, escapePath(file.Path))
+
+	existing, err := c.fileSHA(ctx, contentsURL, branch)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]string{
+		"message": message,
+		"content": base64.StdEncoding.EncodeToString(file.Content),
+		"branch":  branch,
+	}
+	if existing != "" {
+		body["sha"] = existing
+	}
+
+	resp, err := c.sendJSON(ctx, "PUT", contentsURL, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("contents API returned status %d for %s", resp.StatusCode, file.Path)
+	}
+	return nil
+}
+
+// CreatePullRequest opens a pull request from head into base
+func (c *Client) CreatePullRequest(ctx context.Context, owner, repo, base, head, title, body string) (*PullRequest, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls", c.config.BaseURL, owner, repo)
+
+	resp, err := c.sendJSON(ctx, "POST", url, map[string]string{
+		"title": title,
+		"body":  body,
+		"head":  head,
+		"base":  base,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("pulls API returned status %d", resp.StatusCode)
+	}
+
+	pull := &PullRequest{Head: head, Base: base}
+	if err := json.NewDecoder(resp.Body).Decode(pull); err != nil {
+		return nil, err
+	}
+	return pull, nil
+}
+
+// fileSHA returns the blob SHA of a file on a branch, or "" if it doesn't exist
+func (c *Client) fileSHA(ctx context.Context, contentsURL, branch string) (string, error) {
+	resp, err := c.makeRequest(ctx, "GET", contentsURL+"?ref="+url.QueryEscape(branch), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return "", nil
+	case http.StatusOK:
+	default:
+		return "", fmt.Errorf("contents API returned status %d", resp.StatusCode)
+	}
+
+	var content struct {
+		SHA string `json:"sha"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&content); err != nil {
+		return "", err
+	}
+	return content.SHA, nil
+}
+
+// sendJSON makes a request with a JSON-encoded body
+func (c *Client) sendJSON(ctx context.Context, method, url string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return c.makeRequest(ctx, method, url, bytes.NewReader(data))
+}
+
+// escapePath escapes each segment of a repository path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
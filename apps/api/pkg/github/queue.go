@@ -2,8 +2,15 @@ package github
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
 )
 
 // Priority levels for request queue
@@ -23,6 +30,7 @@ type Request struct {
 	Fn       func(ctx context.Context) error
 	Result   chan error
 	Created  time.Time
+	ctx      context.Context // The enqueuing caller's context
 }
 
 // Queue implements a priority-based request queue for batch operations
@@ -32,45 +40,75 @@ type Queue struct {
 	workers       int
 	shutdown      chan struct{}
 	wg            sync.WaitGroup
-	maxRetries    int
-	retryDelay    time.Duration
 	batchSize     int
 	batchInterval time.Duration
+	maxDuration   time.Duration
+	clock         clock.Clock
+	deadLetters   *DeadLetterStore
+
+	parkedMutex sync.Mutex
+	parked      map[int64]parkedRequest // Dead-lettered requests this process can requeue
+	maxParked   int
+	parkedTTL   time.Duration
+
+	deadLettered atomic.Int64
+	requeued     atomic.Int64
+	purged       atomic.Int64
 }
 
-// QueueConfig holds queue configuration
+// QueueConfig holds queue configuration. Failed API calls are retried by the
+// client, under its RetryPolicy and RetryBudget, not by the queue.
 type QueueConfig struct {
 	Workers       int
-	MaxRetries    int
-	RetryDelay    time.Duration
 	BatchSize     int
 	BatchInterval time.Duration
 	QueueSize     int
+	MaxDuration   time.Duration    // Upper bound on a request and its retries, within the caller's deadline
+	Clock         clock.Clock      // Times batching and cool-downs; defaults to the system clock
+	DeadLetters   *DeadLetterStore // Keeps requests that failed transiently; nil drops them
+	MaxParked     int              // Dead-lettered requests held for requeueing; the oldest are let go first
+	ParkedTTL     time.Duration    // How long a dead-lettered request can be requeued
 }
 
 // DefaultQueueConfig returns default queue configuration
 func DefaultQueueConfig() QueueConfig {
 	return QueueConfig{
 		Workers:       5,
-		MaxRetries:    3,
-		RetryDelay:    5 * time.Second,
 		BatchSize:     10,
 		BatchInterval: 1 * time.Second,
 		QueueSize:     1000,
+		MaxDuration:   30 * time.Second,
+		MaxParked:     1000,
+		ParkedTTL:     24 * time.Hour,
 	}
 }
 
 // NewQueue creates a new request queue
 func NewQueue(client *Client, config QueueConfig) *Queue {
+	defaults := DefaultQueueConfig()
+	if config.MaxDuration <= 0 {
+		config.MaxDuration = defaults.MaxDuration
+	}
+	if config.MaxParked <= 0 {
+		config.MaxParked = defaults.MaxParked
+	}
+	if config.ParkedTTL <= 0 {
+		config.ParkedTTL = defaults.ParkedTTL
+	}
+
 	q := &Queue{
 		client:        client,
 		queues:        make(map[Priority]chan *Request),
 		workers:       config.Workers,
 		shutdown:      make(chan struct{}),
-		maxRetries:    config.MaxRetries,
-		retryDelay:    config.RetryDelay,
 		batchSize:     config.BatchSize,
 		batchInterval: config.BatchInterval,
+		maxDuration:   config.MaxDuration,
+		clock:         clock.OrReal(config.Clock),
+		deadLetters:   config.DeadLetters,
+		parked:        make(map[int64]parkedRequest),
+		maxParked:     config.MaxParked,
+		parkedTTL:     config.ParkedTTL,
 	}
 
 	// Initialize priority queues
@@ -109,7 +147,8 @@ func (q *Queue) Enqueue(ctx context.Context, id string, priority Priority, fn fu
 		Priority: priority,
 		Fn:       fn,
 		Result:   make(chan error, 1),
-		Created:  time.Now(),
+		Created:  q.clock.Now(),
+		ctx:      ctx,
 	}
 
 	select {
@@ -132,7 +171,7 @@ func (q *Queue) worker(id int) {
 	defer q.wg.Done()
 
 	batch := make([]*Request, 0, q.batchSize)
-	ticker := time.NewTicker(q.batchInterval)
+	ticker := q.clock.NewTicker(q.batchInterval)
 	defer ticker.Stop()
 
 	for {
@@ -144,7 +183,7 @@ func (q *Queue) worker(id int) {
 			}
 			return
 
-		case <-ticker.C:
+		case <-ticker.C():
 			// Process batch on interval
 			if len(batch) > 0 {
 				q.processBatch(batch)
@@ -156,7 +195,10 @@ func (q *Queue) worker(id int) {
 			req := q.getNextRequest()
 			if req == nil {
 				// No requests available, wait a bit
-				time.Sleep(100 * time.Millisecond)
+				select {
+				case <-q.clock.After(100 * time.Millisecond):
+				case <-q.shutdown:
+				}
 				continue
 			}
 
@@ -198,51 +240,222 @@ func (q *Queue) processBatch(batch []*Request) {
 	}
 }
 
-// processRequest processes a single request with retries
+// processRequest processes a single request. It runs under the enqueuing
+// caller's context, so a request nobody is waiting on any more stops instead
+// of spending API quota.
+//
+// The client retries failed calls itself, so the queue only runs a request
+// again after a secondary rate limit, which GitHub rejects before acting on:
+// once the cool-down is over, as often as the client's RetryPolicy allows and
+// charged to its RetryBudget.
 func (q *Queue) processRequest(req *Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	policy := q.client.config.Retry
+	attempts := 0
+	for {
+		// Requests stay queued through a secondary rate limit cool-down, so
+		// their time limit starts once requests resume
+		if err := q.client.awaitCooldown(req.ctx); err != nil {
+			req.Result <- err
+			return
+		}
+
+		attempts++
+		err := q.run(req)
+		if err == nil || req.ctx.Err() != nil || !q.transient(err) {
+			req.Result <- err
+			return
+		}
+		if !errors.Is(err, ErrSecondaryRateLimit) || attempts >= policy.MaxAttempts {
+			// Keep the request for operators to inspect and requeue
+			q.deadLetter(req, attempts, err)
+			req.Result <- err
+			return
+		}
+		if budgetErr := q.client.config.RetryBudget.TryRetry(); budgetErr != nil {
+			err = fmt.Errorf("%w after: %v", budgetErr, err)
+			q.deadLetter(req, attempts, err)
+			req.Result <- err
+			return
+		}
+	}
+}
+
+// run calls a request's function once, within the queue's time limit
+func (q *Queue) run(req *Request) error {
+	ctx, cancel := context.WithTimeout(req.ctx, q.maxDuration)
 	defer cancel()
 
-	var lastErr error
-	
-	for attempt := 0; attempt <= q.maxRetries; attempt++ {
-		if attempt > 0 {
-			// Wait before retry
-			select {
-			case <-time.After(q.retryDelay * time.Duration(attempt)):
-			case <-ctx.Done():
-				req.Result <- ctx.Err()
-				return
+	// The caller may have given up while the request was queued
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := req.Fn(ctx)
+	// A cancelled or expired request isn't worth retrying
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// deadLetter persists a request that failed transiently
+func (q *Queue) deadLetter(req *Request, attempts int, err error) {
+	q.deadLettered.Add(1)
+	if q.deadLetters == nil {
+		return
+	}
+
+	id, storeErr := q.deadLetters.Add(context.WithoutCancel(req.ctx), DeadLetter{
+		RequestID:  req.ID,
+		Priority:   req.Priority,
+		Attempts:   attempts,
+		LastError:  err.Error(),
+		EnqueuedAt: req.Created,
+		FailedAt:   q.clock.Now(),
+	})
+	if storeErr != nil {
+		log.Printf("Failed to dead-letter GitHub request %s: %v", req.ID, storeErr)
+		return
+	}
+
+	q.park(id, req)
+}
+
+// parkedRequest is a dead-lettered request held for requeueing
+type parkedRequest struct {
+	req      *Request
+	parkedAt time.Time
+}
+
+// park holds a dead-lettered request for requeueing. Requests hold their
+// caller's context and function, so only the most recent MaxParked are kept,
+// each for ParkedTTL; the rest stay in the store to inspect and discard.
+func (q *Queue) park(id int64, req *Request) {
+	q.parkedMutex.Lock()
+	defer q.parkedMutex.Unlock()
+
+	q.expireParked()
+	for len(q.parked) >= q.maxParked {
+		oldest := int64(-1)
+		for candidate, parked := range q.parked {
+			if oldest < 0 || parked.parkedAt.Before(q.parked[oldest].parkedAt) ||
+				(parked.parkedAt.Equal(q.parked[oldest].parkedAt) && candidate < oldest) {
+				oldest = candidate
 			}
 		}
+		delete(q.parked, oldest)
+	}
+	q.parked[id] = parkedRequest{req: req, parkedAt: q.clock.Now()}
+}
 
-		// Execute the request function
-		lastErr = req.Fn(ctx)
-		if lastErr == nil {
-			req.Result <- nil
-			return
-		}
+// unpark takes a parked request
+func (q *Queue) unpark(id int64) (*Request, bool) {
+	q.parkedMutex.Lock()
+	defer q.parkedMutex.Unlock()
+
+	q.expireParked()
+	parked, ok := q.parked[id]
+	delete(q.parked, id)
+	return parked.req, ok
+}
 
-		// Check if error is retryable
-		if !q.isRetryableError(lastErr) {
-			break
+// expireParked lets go of requests parked longer than ParkedTTL; callers
+// hold parkedMutex
+func (q *Queue) expireParked() {
+	now := q.clock.Now()
+	for id, parked := range q.parked {
+		if now.Sub(parked.parkedAt) >= q.parkedTTL {
+			delete(q.parked, id)
 		}
 	}
+}
+
+// DeadLetters lists up to limit dead letters, most recent first
+func (q *Queue) DeadLetters(ctx context.Context, limit int) ([]DeadLetter, error) {
+	if q.deadLetters == nil {
+		return []DeadLetter{}, nil
+	}
+	letters, err := q.deadLetters.List(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	q.parkedMutex.Lock()
+	defer q.parkedMutex.Unlock()
+	q.expireParked()
+	for i := range letters {
+		_, letters[i].Requeueable = q.parked[letters[i].ID]
+	}
+	return letters, nil
+}
+
+// Requeue removes a dead letter and enqueues its request again, detached
+// from the original caller, who has already received the failure. Requests
+// dead-lettered by another process, or before a restart, can only be
+// inspected and discarded since their functions aren't persisted.
+func (q *Queue) Requeue(ctx context.Context, id int64) (<-chan error, error) {
+	if q.deadLetters == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	if _, err := q.deadLetters.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	req, ok := q.unpark(id)
+	if !ok {
+		return nil, ErrNotRequeueable
+	}
 
-	req.Result <- lastErr
+	if err := q.deadLetters.Delete(ctx, id); err != nil {
+		q.park(id, req)
+		return nil, err
+	}
+
+	q.requeued.Add(1)
+	return q.Enqueue(context.WithoutCancel(req.ctx), req.ID, req.Priority, req.Fn), nil
 }
 
-// isRetryableError determines if an error is retryable
-func (q *Queue) isRetryableError(err error) bool {
-	if err == nil {
-		return false
+// DiscardDeadLetter deletes one dead letter
+func (q *Queue) DiscardDeadLetter(ctx context.Context, id int64) error {
+	if q.deadLetters == nil {
+		return ErrDeadLetterNotFound
 	}
+	if err := q.deadLetters.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	q.parkedMutex.Lock()
+	delete(q.parked, id)
+	q.parkedMutex.Unlock()
+	q.purged.Add(1)
+	return nil
+}
+
+// PurgeDeadLetters deletes every dead letter and returns how many there were
+func (q *Queue) PurgeDeadLetters(ctx context.Context) (int64, error) {
+	if q.deadLetters == nil {
+		return 0, nil
+	}
+	purged, err := q.deadLetters.Purge(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	q.parkedMutex.Lock()
+	q.parked = make(map[int64]parkedRequest)
+	q.parkedMutex.Unlock()
+	q.purged.Add(purged)
+	return purged, nil
+}
 
-	// Retry on circuit breaker errors and rate limit errors
-	return err == circuit.ErrCircuitOpen || 
-		   err == circuit.ErrTooManyCalls ||
-		   err == ErrRequestTimeout ||
-		   err.Error() == "rate limit exceeded"
+// transient reports whether an error is transient: the request may
+// succeed when requeued later, so it's dead-lettered rather than dropped
+func (q *Queue) transient(err error) bool {
+	return errors.Is(err, circuit.ErrCircuitOpen) ||
+		errors.Is(err, circuit.ErrTooManyCalls) ||
+		errors.Is(err, circuit.ErrRequestTimeout) ||
+		errors.Is(err, circuit.ErrRetryBudgetExhausted) ||
+		errors.Is(err, ErrSecondaryRateLimit) ||
+		errors.Is(err, ErrRateLimitExceeded)
 }
 
 // Stats returns queue statistics
@@ -250,6 +463,9 @@ type QueueStats struct {
 	QueueLengths map[Priority]int
 	WorkerCount  int
 	TotalQueued  int
+	DeadLettered int64 // Requests that exhausted their retries since the queue started
+	Requeued     int64 // Dead letters enqueued again
+	Purged       int64 // Dead letters discarded
 }
 
 // Stats returns current queue statistics
@@ -257,6 +473,9 @@ func (q *Queue) Stats() QueueStats {
 	stats := QueueStats{
 		QueueLengths: make(map[Priority]int),
 		WorkerCount:  q.workers,
+		DeadLettered: q.deadLettered.Load(),
+		Requeued:     q.requeued.Load(),
+		Purged:       q.purged.Load(),
 	}
 
 	for priority, queue := range q.queues {
@@ -0,0 +1,208 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Release is a GitHub release
+type Release struct {
+	ID              int64          `json:"id"`
+	TagName         string         `json:"tag_name"`
+	TargetCommitish string         `json:"target_commitish"`
+	Name            string         `json:"name"`
+	Body            string         `json:"body"`
+	Draft           bool           `json:"draft"`
+	Prerelease      bool           `json:"prerelease"`
+	HTMLURL         string         `json:"html_url"`
+	UploadURL       string         `json:"upload_url"` // A URI template, e.g. https://uploads.github.com/repos/o/r/releases/1/assets{?name,label}
+	CreatedAt       time.Time      `json:"created_at"`
+	PublishedAt     *time.Time     `json:"published_at"`
+	Assets          []ReleaseAsset `json:"assets"`
+}
+
+// ReleaseAsset is a file attached to a release
+type ReleaseAsset struct {
+	ID                 int64     `json:"id"`
+	Name               string    `json:"name"`
+	Label              string    `json:"label"`
+	ContentType        string    `json:"content_type"`
+	Size               int64     `json:"size"`
+	State              string    `json:"state"` // uploaded once complete
+	URL                string    `json:"url"`
+	BrowserDownloadURL string    `json:"browser_download_url"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// ReleaseOptions creates a release
+type ReleaseOptions struct {
+	TagName              string `json:"tag_name"`
+	TargetCommitish      string `json:"target_commitish,omitempty"` // Branch or SHA the tag is created from if it doesn't exist; defaults to the default branch
+	Name                 string `json:"name,omitempty"`
+	Body                 string `json:"body,omitempty"`
+	Draft                bool   `json:"draft,omitempty"`
+	Prerelease           bool   `json:"prerelease,omitempty"`
+	GenerateReleaseNotes bool   `json:"generate_release_notes,omitempty"`
+}
+
+// AssetUpload is a file to attach to a release, e.g. a signed SBOM,
+// verification bundle or report
+type AssetUpload struct {
+	Name        string // File name, unique within the release
+	Label       string // Shown instead of the name, if set
+	ContentType string // Guessed from the name's extension when empty
+	Content     []byte
+}
+
+// CreateRelease creates a release, and its tag if it doesn't exist yet
+func (c *Client) CreateRelease(ctx context.Context, owner, repo string, options ReleaseOptions) (*Release, error) {
+	if options.TagName == "" {
+		return nil, fmt.Errorf("release tag name is required")
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/releases", c.config.BaseURL, owner, repo)
+
+	release, err := doRequest[Release](ctx, c, "POST", url, options, http.StatusCreated)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnprocessableEntity {
+		return nil, fmt.Errorf("releases API rejected release %s; it may already exist: %w", options.TagName, err)
+	}
+	return release, err
+}
+
+// ListReleases fetches a repository's releases, newest first, following
+// pages up to the configured MaxPages. Drafts are only listed for tokens
+// with push access.
+func (c *Client) ListReleases(ctx context.Context, owner, repo string) ([]Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases", c.config.BaseURL, owner, repo)
+
+	releases := []Release{}
+	err := c.Paginate(url, PageOptions{PerPage: MaxPerPage, MaxPages: c.config.MaxPages}).All(ctx, &releases)
+	if err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// GetReleaseByTag fetches the published release for a tag
+func (c *Client) GetReleaseByTag(ctx context.Context, owner, repo, tag string) (*Release, error) {
+	requestURL := fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", c.config.BaseURL, owner, repo, url.PathEscape(tag))
+	return doRequest[Release](ctx, c, "GET", requestURL, nil, http.StatusOK)
+}
+
+// UploadReleaseAsset attaches a file to a release. The release must not
+// already have an asset with the same name.
+func (c *Client) UploadReleaseAsset(ctx context.Context, owner, repo string, release *Release, asset AssetUpload) (*ReleaseAsset, error) {
+	if asset.Name == "" {
+		return nil, fmt.Errorf("release asset name is required")
+	}
+	// Drop the template's {?name,label} expansion
+	uploadURL, _, _ := strings.Cut(release.UploadURL, "{")
+	if uploadURL == "" {
+		return nil, fmt.Errorf("release %s has no upload URL", release.TagName)
+	}
+	params := url.Values{"name": {asset.Name}}
+	if asset.Label != "" {
+		params.Set("label", asset.Label)
+	}
+	contentType := asset.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(asset.Name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	resp, err := c.makeRequestWithHeader(ctx, "POST", uploadURL+"?"+params.Encode(), bytes.NewReader(asset.Content),
+		http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusUnprocessableEntity:
+		return nil, fmt.Errorf("release %s already has an asset named %s", release.TagName, asset.Name)
+	default:
+		return nil, fmt.Errorf("release assets API returned status %d for %s", resp.StatusCode, asset.Name)
+	}
+
+	uploaded := &ReleaseAsset{}
+	if err := json.NewDecoder(resp.Body).Decode(uploaded); err != nil {
+		return nil, err
+	}
+	return uploaded, nil
+}
+
+// DownloadReleaseAsset streams a release asset's content. The caller closes
+// the returned reader.
+func (c *Client) DownloadReleaseAsset(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases/assets/%d", c.config.BaseURL, owner, repo, id)
+
+	// GitHub redirects to storage that takes no credentials; the HTTP
+	// client drops the Authorization header on leaving the API's host
+	resp, err := c.makeRequestWithHeader(ctx, "GET", url, nil, http.Header{"Accept": {"application/octet-stream"}})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{Method: "GET", URL: url, StatusCode: resp.StatusCode}
+	}
+	return resp.Body, nil
+}
+
+// DeleteReleaseAsset removes an asset from its release
+func (c *Client) DeleteReleaseAsset(ctx context.Context, owner, repo string, id int64) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases/assets/%d", c.config.BaseURL, owner, repo, id)
+
+	resp, err := c.makeRequest(ctx, "DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return &StatusError{Method: "DELETE", URL: url, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// AttachReleaseAssets uploads files to a tag's release, replacing assets of
+// the same name, so a re-run release workflow attaches fresh SBOMs, bundles
+// and reports rather than failing
+func (c *Client) AttachReleaseAssets(ctx context.Context, owner, repo, tag string, assets []AssetUpload) ([]ReleaseAsset, error) {
+	release, err := c.GetReleaseByTag(ctx, owner, repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]int64, len(release.Assets))
+	for _, asset := range release.Assets {
+		existing[asset.Name] = asset.ID
+	}
+
+	uploaded := make([]ReleaseAsset, 0, len(assets))
+	for _, asset := range assets {
+		if id, found := existing[asset.Name]; found {
+			if err := c.DeleteReleaseAsset(ctx, owner, repo, id); err != nil {
+				return uploaded, err
+			}
+		}
+		attached, err := c.UploadReleaseAsset(ctx, owner, repo, release, asset)
+		if err != nil {
+			return uploaded, err
+		}
+		uploaded = append(uploaded, *attached)
+	}
+	return uploaded, nil
+}
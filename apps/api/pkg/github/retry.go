@@ -0,0 +1,160 @@
+package github
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRetryableStatuses are the statuses retried by default: server errors
+// GitHub returns while an incident or deployment is in progress
+var DefaultRetryableStatuses = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy decides whether and when a failed request is tried again. It
+// applies to every call the client makes; Config.EndpointRetries overrides
+// it for particular endpoints.
+//
+// Requests are retried after a server error in RetryableStatuses or a
+// failure to reach the API, waiting BaseDelay, then twice as long after each
+// further failure, up to MaxDelay. Primary rate limits are retried only when
+// GitHub's Retry-After fits within MaxDelay. POST and PATCH requests, which
+// GitHub may have acted on before failing, are retried only after a rate
+// limit, which GitHub rejects before acting. Secondary rate limits pause the
+// whole client instead; see ErrSecondaryRateLimit.
+type RetryPolicy struct {
+	MaxAttempts       int            // Attempts in all, the first included; 0 or 1 disables retries
+	BaseDelay         time.Duration  // Wait before the first retry
+	MaxDelay          time.Duration  // Longest wait before a retry, Retry-After included
+	Jitter            float64        // Fraction of each wait drawn at random, from 0 to 1, so clients retrying together spread out
+	RetryableStatuses []int          // DefaultRetryableStatuses when nil
+	Random            func() float64 // Draws jitter from [0, 1); rand.Float64 when nil
+}
+
+// DefaultRetryPolicy returns the retry policy of DefaultConfig
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.5,
+	}
+}
+
+// Backoff returns how long to wait before retrying after the given failed
+// attempt, counting from 1: BaseDelay doubled for each attempt after the
+// first, capped at MaxDelay, with the Jitter fraction of it drawn at random
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		random := p.Random
+		if random == nil {
+			random = rand.Float64
+		}
+		delay -= time.Duration(jitter * random() * float64(delay))
+	}
+	return delay
+}
+
+// Retryable reports whether a response status is worth retrying for the
+// method. Rate limits are judged by their Retry-After instead.
+func (p RetryPolicy) Retryable(method string, statusCode int) bool {
+	if !idempotent(method) {
+		return false
+	}
+	statuses := p.RetryableStatuses
+	if statuses == nil {
+		statuses = DefaultRetryableStatuses
+	}
+	for _, status := range statuses {
+		if status == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay decides whether to retry after the given failed attempt, and
+// how long to wait first. statusCode is 0 when the API wasn't reached.
+func (p RetryPolicy) retryDelay(attempt int, method string, statusCode int, header http.Header, now time.Time) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	retryAfter, hasRetryAfter := parseRetryAfter(header, now)
+	switch {
+	case statusCode == 0:
+		if !idempotent(method) {
+			return 0, false
+		}
+	case statusCode == http.StatusForbidden || statusCode == http.StatusTooManyRequests:
+		// A rate limit: retry only when GitHub says when, and soon enough
+		if !hasRetryAfter || (p.MaxDelay > 0 && retryAfter > p.MaxDelay) {
+			return 0, false
+		}
+		return retryAfter, true
+	case !p.Retryable(method, statusCode):
+		return 0, false
+	}
+
+	delay := p.Backoff(attempt)
+	if hasRetryAfter && retryAfter > delay {
+		if p.MaxDelay > 0 && retryAfter > p.MaxDelay {
+			return 0, false
+		}
+		delay = retryAfter
+	}
+	return delay, true
+}
+
+// idempotent reports whether repeating a request has the same effect as
+// making it once
+func idempotent(method string) bool {
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter reads a Retry-After header, given in seconds or as an HTTP
+// date
+func parseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// retryPolicy returns the policy for a URL: the EndpointRetries entry with
+// the longest key the URL contains, or the client's Retry policy
+func (c *Client) retryPolicy(url string) RetryPolicy {
+	policy, matched := c.config.Retry, ""
+	for key, endpoint := range c.config.EndpointRetries {
+		longer := len(key) > len(matched) || (len(key) == len(matched) && key < matched)
+		if longer && strings.Contains(url, key) {
+			policy, matched = endpoint, key
+		}
+	}
+	return policy
+}
@@ -0,0 +1,196 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Rule types
+const (
+	RuleRequiredStatusChecks = "required_status_checks"
+	RuleRequiredSignatures   = "required_signatures"
+	RulePullRequest          = "pull_request"
+	RuleNonFastForward       = "non_fast_forward"
+)
+
+// Ruleset enforcement levels; evaluate rulesets only report what they would
+// block
+const (
+	EnforcementActive   = "active"
+	EnforcementEvaluate = "evaluate"
+	EnforcementDisabled = "disabled"
+)
+
+// Ruleset is a repository or organization ruleset
+type Ruleset struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	Target       string `json:"target"`      // branch, tag or push
+	SourceType   string `json:"source_type"` // Repository or Organization
+	Source       string `json:"source"`      // owner/repo or the organization
+	Enforcement  string `json:"enforcement"`
+	BypassActors []struct {
+		ActorID    int64  `json:"actor_id"`
+		ActorType  string `json:"actor_type"`  // RepositoryRole, Team, Integration, OrganizationAdmin, ...
+		BypassMode string `json:"bypass_mode"` // always or pull_request
+	} `json:"bypass_actors"`
+	Conditions json.RawMessage `json:"conditions"` // Ref name and repository conditions; omitted in lists
+	Rules      []Rule          `json:"rules"`      // Omitted in lists
+}
+
+// Rule is a rule of a ruleset, or one in force on a branch
+type Rule struct {
+	Type       string          `json:"type"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+	// Set for the rules in force on a branch
+	RulesetSourceType string `json:"ruleset_source_type,omitempty"`
+	RulesetSource     string `json:"ruleset_source,omitempty"`
+	RulesetID         int64  `json:"ruleset_id,omitempty"`
+}
+
+// StatusCheckParameters are the parameters of a required_status_checks rule
+type StatusCheckParameters struct {
+	RequiredStatusChecks []struct {
+		Context       string `json:"context"`
+		IntegrationID int64  `json:"integration_id,omitempty"` // The app that must set it, if any
+	} `json:"required_status_checks"`
+	StrictRequiredStatusChecksPolicy bool `json:"strict_required_status_checks_policy"` // Branches must be up to date before merging
+}
+
+// StatusChecks decodes the parameters of a required_status_checks rule
+func (r Rule) StatusChecks() (*StatusCheckParameters, error) {
+	if r.Type != RuleRequiredStatusChecks {
+		return nil, fmt.Errorf("%s rule has no status checks", r.Type)
+	}
+	params := &StatusCheckParameters{}
+	if err := json.Unmarshal(r.Parameters, params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// ListRepositoryRulesets fetches the rulesets of a repository, including
+// those its organization applies to it, following pages up to the
+// configured MaxPages
+func (c *Client) ListRepositoryRulesets(ctx context.Context, owner, repo string) ([]Ruleset, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/rulesets?includes_parents=true", c.config.BaseURL, owner, repo)
+	return c.listRulesets(ctx, url)
+}
+
+// GetRepositoryRuleset fetches a ruleset in force on a repository, with its
+// conditions and rules
+func (c *Client) GetRepositoryRuleset(ctx context.Context, owner, repo string, id int64) (*Ruleset, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/rulesets/%d?includes_parents=true", c.config.BaseURL, owner, repo, id)
+	return doRequest[Ruleset](ctx, c, "GET", url, nil, http.StatusOK)
+}
+
+// ListOrganizationRulesets fetches an organization's rulesets, following
+// pages up to the configured MaxPages
+func (c *Client) ListOrganizationRulesets(ctx context.Context, org string) ([]Ruleset, error) {
+	url := fmt.Sprintf("%s/orgs/%s/rulesets", c.config.BaseURL, org)
+	return c.listRulesets(ctx, url)
+}
+
+// GetOrganizationRuleset fetches an organization ruleset, with its
+// conditions and rules
+func (c *Client) GetOrganizationRuleset(ctx context.Context, org string, id int64) (*Ruleset, error) {
+	url := fmt.Sprintf("%s/orgs/%s/rulesets/%d", c.config.BaseURL, org, id)
+	return doRequest[Ruleset](ctx, c, "GET", url, nil, http.StatusOK)
+}
+
+// listRulesets fetches a list of rulesets
+func (c *Client) listRulesets(ctx context.Context, url string) ([]Ruleset, error) {
+	rulesets := []Ruleset{}
+	err := c.Paginate(url, PageOptions{PerPage: MaxPerPage, MaxPages: c.config.MaxPages}).All(ctx, &rulesets)
+	if err != nil {
+		return nil, err
+	}
+	return rulesets, nil
+}
+
+// GetBranchRules fetches the rules active rulesets enforce on a branch,
+// whichever repository or organization ruleset they come from. Rules of
+// rulesets only evaluating aren't included. A branch name may be given as a
+// ref, e.g. refs/heads/main.
+func (c *Client) GetBranchRules(ctx context.Context, owner, repo, branch string) ([]Rule, error) {
+	branch = strings.TrimPrefix(branch, "refs/heads/")
+	requestURL := fmt.Sprintf("%s/repos/%s/%s/rules/branches/%s", c.config.BaseURL, owner, repo, url.PathEscape(branch))
+
+	rules := []Rule{}
+	err := c.Paginate(requestURL, PageOptions{PerPage: MaxPerPage, MaxPages: c.config.MaxPages}).All(ctx, &rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// BranchRequirement is the protection a policy requires of the branch an
+// artifact was built from
+type BranchRequirement struct {
+	RequiredChecks []string // Status check contexts that must be required, e.g. Keystone
+	SignedCommits  bool     // Commits must be signed
+	PullRequests   bool     // Changes must go through pull requests
+	NoForcePushes  bool     // History can't be rewritten
+}
+
+// UnenforcedError is returned when a branch's rules don't enforce what a
+// policy requires
+type UnenforcedError struct {
+	Branch  string
+	Missing []string // Each requirement no active rule enforces
+}
+
+func (e *UnenforcedError) Error() string {
+	return fmt.Sprintf("branch %s doesn't enforce %s", e.Branch, strings.Join(e.Missing, ", "))
+}
+
+// VerifyBranchRules checks that active rulesets enforce the requirement on
+// a branch, e.g. the one named in provenance, returning an
+// *UnenforcedError listing what isn't. Only rulesets are consulted, not
+// classic branch protection.
+func (c *Client) VerifyBranchRules(ctx context.Context, owner, repo, branch string, requirement BranchRequirement) error {
+	rules, err := c.GetBranchRules(ctx, owner, repo, branch)
+	if err != nil {
+		return err
+	}
+
+	enforced := make(map[string]bool)
+	checks := make(map[string]bool)
+	for _, rule := range rules {
+		enforced[rule.Type] = true
+		if rule.Type != RuleRequiredStatusChecks {
+			continue
+		}
+		params, err := rule.StatusChecks()
+		if err != nil {
+			return fmt.Errorf("ruleset %d: %w", rule.RulesetID, err)
+		}
+		for _, check := range params.RequiredStatusChecks {
+			checks[check.Context] = true
+		}
+	}
+
+	var missing []string
+	for _, check := range requirement.RequiredChecks {
+		if !checks[check] {
+			missing = append(missing, fmt.Sprintf("required check %q", check))
+		}
+	}
+	if requirement.SignedCommits && !enforced[RuleRequiredSignatures] {
+		missing = append(missing, "signed commits")
+	}
+	if requirement.PullRequests && !enforced[RulePullRequest] {
+		missing = append(missing, "pull requests")
+	}
+	if requirement.NoForcePushes && !enforced[RuleNonFastForward] {
+		missing = append(missing, "blocked force pushes")
+	}
+	if len(missing) > 0 {
+		return &UnenforcedError{Branch: strings.TrimPrefix(branch, "refs/heads/"), Missing: missing}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSecondaryCooldown is how long requests pause after a secondary rate
+// limit without a Retry-After; GitHub asks for at least a minute
+const DefaultSecondaryCooldown = time.Minute
+
+// ErrSecondaryRateLimit is returned when GitHub throttled a request for
+// making too many requests at once, or while the cool-down it asked for lasts
+// longer than the request's deadline
+var ErrSecondaryRateLimit = errors.New("secondary rate limit exceeded")
+
+// secondaryLimitMarkers are how GitHub's error messages name secondary
+// limits, which older responses called abuse detection
+var secondaryLimitMarkers = []string{"secondary rate limit", "abuse detection"}
+
+// SecondaryRateLimit is the state of the client's secondary rate limit
+// cool-down
+type SecondaryRateLimit struct {
+	CoolingDown bool      `json:"cooling_down"`
+	Until       time.Time `json:"until,omitempty"` // When requests resume
+	Hits        int64     `json:"hits"`            // Secondary limits hit since the client was created
+}
+
+// secondaryRateLimited reports whether a response is a secondary rate limit,
+// returning the cool-down GitHub asked for. Primary limits are 403s or 429s
+// with no requests remaining; secondary ones name themselves in the message
+// or carry a Retry-After.
+func (c *Client) secondaryRateLimited(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body = io.NopCloser(strings.NewReader(string(body)))
+	message := strings.ToLower(string(body))
+	named := false
+	for _, marker := range secondaryLimitMarkers {
+		named = named || strings.Contains(message, marker)
+	}
+	retryAfter := resp.Header.Get("Retry-After")
+	if !named && (retryAfter == "" || resp.Header.Get("X-RateLimit-Remaining") == "0") {
+		return 0, false
+	}
+
+	cooldown := c.config.SecondaryCooldown
+	if cooldown <= 0 {
+		cooldown = DefaultSecondaryCooldown
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		cooldown = time.Duration(seconds) * time.Second
+	}
+	return cooldown, true
+}
+
+// startCooldown pauses every request made through the client, by any
+// goroutine or queue worker, for the cool-down
+func (c *Client) startCooldown(cooldown time.Duration) {
+	c.throttleMutex.Lock()
+	defer c.throttleMutex.Unlock()
+	c.secondaryHits++
+	if until := c.clock.Now().Add(cooldown); until.After(c.cooldownUntil) {
+		c.cooldownUntil = until
+	}
+}
+
+// awaitCooldown waits out a secondary rate limit cool-down. Requests whose
+// deadline falls within it fail right away with ErrSecondaryRateLimit.
+func (c *Client) awaitCooldown(ctx context.Context) error {
+	for {
+		c.throttleMutex.Lock()
+		wait := c.cooldownUntil.Sub(c.clock.Now())
+		c.throttleMutex.Unlock()
+		if wait <= 0 {
+			return nil
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return ErrSecondaryRateLimit
+		}
+
+		select {
+		case <-c.clock.After(wait):
+			// The cool-down may have been extended meanwhile
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// SecondaryRateLimit returns the client's secondary rate limit state
+func (c *Client) SecondaryRateLimit() SecondaryRateLimit {
+	c.throttleMutex.Lock()
+	defer c.throttleMutex.Unlock()
+	return SecondaryRateLimit{
+		CoolingDown: c.clock.Now().Before(c.cooldownUntil),
+		Until:       c.cooldownUntil,
+		Hits:        c.secondaryHits,
+	}
+}
@@ -0,0 +1,138 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Secret scanning alert states and resolutions
+const (
+	SecretAlertOpen     = "open"
+	SecretAlertResolved = "resolved"
+
+	SecretResolutionFalsePositive = "false_positive"
+	SecretResolutionWontFix       = "wont_fix"
+	SecretResolutionRevoked       = "revoked"
+	SecretResolutionUsedInTests   = "used_in_tests"
+)
+
+// SecretScanningAlertQuery holds filters for listing secret scanning alerts
+type SecretScanningAlertQuery struct {
+	State      string // open or resolved
+	SecretType string // Comma-separated, e.g. github_personal_access_token
+	Resolution string // Comma-separated resolutions of resolved alerts
+	PerPage    int
+}
+
+// SecretScanningAlert is a leaked credential GitHub found in a repository.
+// The secret itself is deliberately not decoded, so it can't end up in logs,
+// caches or findings.
+type SecretScanningAlert struct {
+	Number                   int        `json:"number"`
+	State                    string     `json:"state"`
+	HTMLURL                  string     `json:"html_url"`
+	CreatedAt                time.Time  `json:"created_at"`
+	Repository               string     `json:"-"` // owner/repo the alert was fetched from
+	SecretType               string     `json:"secret_type"`
+	SecretTypeDisplayName    string     `json:"secret_type_display_name"`
+	Validity                 string     `json:"validity"` // active, inactive or unknown
+	PubliclyLeaked           bool       `json:"publicly_leaked"`
+	Resolution               string     `json:"resolution,omitempty"`
+	ResolutionComment        string     `json:"resolution_comment,omitempty"`
+	ResolvedAt               *time.Time `json:"resolved_at,omitempty"`
+	PushProtectionBypassed   bool       `json:"push_protection_bypassed"`
+	PushProtectionBypassedAt *time.Time `json:"push_protection_bypassed_at,omitempty"`
+}
+
+// SecretScanningAlertUpdate changes an alert's state. Resolving an alert
+// requires a resolution.
+type SecretScanningAlertUpdate struct {
+	State             string `json:"state"`
+	Resolution        string `json:"resolution,omitempty"`
+	ResolutionComment string `json:"resolution_comment,omitempty"`
+}
+
+// ListSecretScanningAlerts fetches a repository's secret scanning alerts,
+// following pages up to the configured MaxPages
+func (c *Client) ListSecretScanningAlerts(ctx context.Context, owner, repo string, query SecretScanningAlertQuery) ([]SecretScanningAlert, error) {
+	params := url.Values{}
+	if query.State != "" {
+		params.Set("state", query.State)
+	}
+	if query.SecretType != "" {
+		params.Set("secret_type", query.SecretType)
+	}
+	if query.Resolution != "" {
+		params.Set("resolution", query.Resolution)
+	}
+	requestURL := fmt.Sprintf("%s/repos/%s/%s/secret-scanning/alerts", c.config.BaseURL, owner, repo)
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+
+	perPage := query.PerPage
+	if perPage == 0 {
+		perPage = MaxPerPage
+	}
+	alerts := []SecretScanningAlert{}
+	err := c.Paginate(requestURL, PageOptions{PerPage: perPage, MaxPages: c.config.MaxPages}).All(ctx, &alerts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range alerts {
+		alerts[i].Repository = owner + "/" + repo
+	}
+	return alerts, nil
+}
+
+// GetSecretScanningAlert fetches a single secret scanning alert
+func (c *Client) GetSecretScanningAlert(ctx context.Context, owner, repo string, number int) (*SecretScanningAlert, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/secret-scanning/alerts/%d", c.config.BaseURL, owner, repo, number)
+
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("secret scanning API returned status %d for alert %d", resp.StatusCode, number)
+	}
+	return decodeSecretAlert(resp, owner, repo)
+}
+
+// UpdateSecretScanningAlert resolves or reopens a secret scanning alert
+func (c *Client) UpdateSecretScanningAlert(ctx context.Context, owner, repo string, number int, update SecretScanningAlertUpdate) (*SecretScanningAlert, error) {
+	if update.State == SecretAlertResolved && update.Resolution == "" {
+		return nil, fmt.Errorf("resolving secret scanning alert %d requires a resolution", number)
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/secret-scanning/alerts/%d", c.config.BaseURL, owner, repo, number)
+
+	resp, err := c.sendJSON(ctx, "PATCH", url, update)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return decodeSecretAlert(resp, owner, repo)
+	case http.StatusUnprocessableEntity:
+		return nil, fmt.Errorf("secret scanning API rejected state %q with resolution %q for alert %d", update.State, update.Resolution, number)
+	default:
+		return nil, fmt.Errorf("secret scanning API returned status %d for alert %d", resp.StatusCode, number)
+	}
+}
+
+// decodeSecretAlert decodes an alert response
+func decodeSecretAlert(resp *http.Response, owner, repo string) (*SecretScanningAlert, error) {
+	alert := &SecretScanningAlert{Repository: owner + "/" + repo}
+	if err := json.NewDecoder(resp.Body).Decode(alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
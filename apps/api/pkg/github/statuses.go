@@ -0,0 +1,58 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxStatusDescription is the longest commit status description GitHub
+// accepts; longer descriptions are shortened
+const MaxStatusDescription = 140
+
+// Commit status states
+const (
+	CommitStatePending = "pending"
+	CommitStateSuccess = "success"
+	CommitStateFailure = "failure"
+	CommitStateError   = "error"
+)
+
+// CommitStatus is a status on a commit. Statuses with the same context
+// replace each other, and branch protection can require a context.
+type CommitStatus struct {
+	ID          int64      `json:"id,omitempty"`
+	State       string     `json:"state"`
+	Context     string     `json:"context,omitempty"` // Defaults to "default"
+	Description string     `json:"description,omitempty"`
+	TargetURL   string     `json:"target_url,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// CreateCommitStatus sets a status on a commit. Unlike check runs, statuses
+// only need the statuses permission, which any token with repository write
+// access has.
+func (c *Client) CreateCommitStatus(ctx context.Context, owner, repo, sha string, status CommitStatus) (*CommitStatus, error) {
+	switch status.State {
+	case CommitStatePending, CommitStateSuccess, CommitStateFailure, CommitStateError:
+	default:
+		return nil, fmt.Errorf("invalid commit status state %q", status.State)
+	}
+	status.ID, status.CreatedAt = 0, nil
+	status.Description = shorten(status.Description, MaxStatusDescription)
+
+	requestURL := fmt.Sprintf("%s/repos/%s/%s/statuses/%s", c.config.BaseURL, owner, repo, url.PathEscape(sha))
+	return doRequest[CommitStatus](ctx, c, "POST", requestURL, status, http.StatusCreated)
+}
+
+// shorten cuts s to at most max characters, ending it with an ellipsis
+func shorten(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
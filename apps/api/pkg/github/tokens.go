@@ -0,0 +1,152 @@
+package github
+
+import (
+	"math"
+	"sync"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+)
+
+// tokenPool rotates requests among several tokens, each with its own rate
+// limit, preferring the token with the most requests remaining. Tokens of
+// different accounts multiply the hourly quota of long jobs such as advisory
+// syncs.
+type tokenPool struct {
+	clock clock.Clock
+
+	mutex  sync.Mutex
+	tokens []*pooledToken
+	next   int // Where the search starts, so tokens with equal quota take turns
+}
+
+// pooledToken is a token and the rate limit its last response reported
+type pooledToken struct {
+	token     *secret.String
+	rateLimit *RateLimit // nil until a response reports it
+}
+
+// newTokenPool pools the tokens that aren't empty
+func newTokenPool(tokens []*secret.String, clock clock.Clock) *tokenPool {
+	pool := &tokenPool{clock: clock}
+	for _, token := range tokens {
+		if !token.Empty() {
+			pool.tokens = append(pool.tokens, &pooledToken{token: token})
+		}
+	}
+	return pool
+}
+
+// pick returns the token with the most requests remaining, counting the
+// request against it until its response reports the actual quota, so
+// concurrent requests spread across tokens
+func (p *tokenPool) pick() *pooledToken {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.tokens) == 0 {
+		return nil
+	}
+
+	var best *pooledToken
+	bestRemaining, bestIndex := math.MinInt, 0
+	for i := range p.tokens {
+		index := (p.next + i) % len(p.tokens)
+		if remaining := p.remaining(p.tokens[index]); remaining > bestRemaining {
+			best, bestRemaining, bestIndex = p.tokens[index], remaining, index
+		}
+	}
+	p.next = (bestIndex + 1) % len(p.tokens)
+	if best.rateLimit != nil && best.rateLimit.Remaining > 0 {
+		best.rateLimit.Remaining--
+	}
+	return best
+}
+
+// update records the rate limit a response reported for a token and returns
+// the quota of the token the next request would use
+func (p *tokenPool) update(token *pooledToken, rateLimit *RateLimit) *RateLimit {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	token.rateLimit = rateLimit
+	return p.best()
+}
+
+// best returns a copy of the rate limit of the token with the most requests
+// remaining, or nil while a token's quota is still unknown
+func (p *tokenPool) best() *RateLimit {
+	var best *pooledToken
+	for _, token := range p.tokens {
+		if token.rateLimit == nil {
+			return nil
+		}
+		if best == nil || p.remaining(token) > p.remaining(best) {
+			best = token
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	rateLimit := *best.rateLimit
+	rateLimit.Remaining = p.remaining(best)
+	return &rateLimit
+}
+
+// total returns the pool's combined quota: the requests every token has left,
+// out of their combined limits, until the earliest of their windows resets.
+// It's nil while a token's quota is still unknown.
+func (p *tokenPool) total() *RateLimit {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var total *RateLimit
+	for _, token := range p.tokens {
+		if token.rateLimit == nil {
+			return nil
+		}
+		if total == nil {
+			total = &RateLimit{Reset: token.rateLimit.Reset}
+		} else if token.rateLimit.Reset.Before(total.Reset) {
+			total.Reset = token.rateLimit.Reset
+		}
+		total.Limit += token.rateLimit.Limit
+		total.Remaining += p.remaining(token)
+		total.Used += token.rateLimit.Used
+	}
+	return total
+}
+
+// remaining returns the requests a token has left. A token not used yet is
+// assumed to have its full quota, as is one whose window has reset.
+func (p *tokenPool) remaining(token *pooledToken) int {
+	switch {
+	case token.rateLimit == nil:
+		return math.MaxInt
+	case !p.clock.Now().Before(token.rateLimit.Reset):
+		return token.rateLimit.Limit
+	default:
+		return token.rateLimit.Remaining
+	}
+}
+
+// rateLimits returns a copy of each token's last reported rate limit, in
+// pool order
+func (p *tokenPool) rateLimits() []*RateLimit {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	rateLimits := make([]*RateLimit, len(p.tokens))
+	for i, token := range p.tokens {
+		if token.rateLimit != nil {
+			rateLimit := *token.rateLimit
+			rateLimits[i] = &rateLimit
+		}
+	}
+	return rateLimits
+}
+
+// release zeroes the pooled tokens
+func (p *tokenPool) release() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, token := range p.tokens {
+		token.token.Release()
+	}
+}
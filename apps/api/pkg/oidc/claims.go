@@ -0,0 +1,196 @@
+package oidc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// Audience is the aud claim, which is a string or a list of strings
+type Audience []string
+
+// UnmarshalJSON accepts both forms of the claim
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// MarshalJSON writes a single audience as a string, as issuers do
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// Contains reports whether the token was issued for the audience
+func (a Audience) Contains(audience string) bool {
+	for _, value := range a {
+		if value == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims are the claims of a GitHub Actions OIDC token
+// (https://docs.github.com/en/actions/security-for-github-actions/security-hardening-your-deployments/about-security-hardening-with-openid-connect)
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Audience  Audience `json:"aud"`
+	Subject   string   `json:"sub"`
+	JWTID     string   `json:"jti,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+
+	Actor                string `json:"actor,omitempty"`
+	ActorID              string `json:"actor_id,omitempty"`
+	Repository           string `json:"repository,omitempty"`
+	RepositoryID         string `json:"repository_id,omitempty"`
+	RepositoryOwner      string `json:"repository_owner,omitempty"`
+	RepositoryOwnerID    string `json:"repository_owner_id,omitempty"`
+	RepositoryVisibility string `json:"repository_visibility,omitempty"`
+	Ref                  string `json:"ref,omitempty"`
+	RefType              string `json:"ref_type,omitempty"`
+	RefProtected         string `json:"ref_protected,omitempty"`
+	SHA                  string `json:"sha,omitempty"`
+	BaseRef              string `json:"base_ref,omitempty"`
+	HeadRef              string `json:"head_ref,omitempty"`
+	Environment          string `json:"environment,omitempty"`
+	EventName            string `json:"event_name,omitempty"`
+	Workflow             string `json:"workflow,omitempty"`
+	WorkflowRef          string `json:"workflow_ref,omitempty"`
+	WorkflowSHA          string `json:"workflow_sha,omitempty"`
+	JobWorkflowRef       string `json:"job_workflow_ref,omitempty"`
+	JobWorkflowSHA       string `json:"job_workflow_sha,omitempty"`
+	RunID                string `json:"run_id,omitempty"`
+	RunNumber            string `json:"run_number,omitempty"`
+	RunAttempt           string `json:"run_attempt,omitempty"`
+	RunnerEnvironment    string `json:"runner_environment,omitempty"`
+
+	// Raw holds every claim, including those of other issuers, for claim
+	// mappings and issuer profiles
+	Raw attestation.Claims `json:"-"`
+}
+
+// Expiry returns when the token expires
+func (c *Claims) Expiry() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// SubjectClaim decomposes the sub claim into its repository, ref,
+// environment or pull request qualifier
+func (c *Claims) SubjectClaim() (*attestation.SubjectClaim, error) {
+	return attestation.ParseSubjectClaim(c.Subject)
+}
+
+// Header is a JWT's JOSE header
+type Header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// Token is a decoded JWT
+type Token struct {
+	Header    Header
+	Claims    *Claims
+	Signature []byte
+	signed    string // header.payload, the input the signature covers
+}
+
+// Parse decodes a JWT without verifying its signature. Use a Verifier for
+// tokens from an untrusted source.
+func Parse(raw string) (*Token, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	if len(parts) != 3 {
+		return nil, attestation.Errorf(attestation.CodeOIDCTokenRequestFailed, "OIDC token is not a JWT")
+	}
+
+	token := &Token{signed: parts[0] + "." + parts[1]}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err == nil {
+		err = json.Unmarshal(header, &token.Header)
+	}
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeOIDCTokenRequestFailed, err, "OIDC token header is not valid")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeOIDCTokenRequestFailed, err, "OIDC token payload is not valid base64")
+	}
+	token.Claims = &Claims{}
+	if err := json.Unmarshal(payload, token.Claims); err != nil {
+		return nil, attestation.Wrap(attestation.CodeOIDCTokenRequestFailed, err, "OIDC token payload is not valid JSON")
+	}
+	if err := json.Unmarshal(payload, &token.Claims.Raw); err != nil {
+		return nil, attestation.Wrap(attestation.CodeOIDCTokenRequestFailed, err, "OIDC token payload is not valid JSON")
+	}
+
+	if token.Signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return nil, attestation.Wrap(attestation.CodeOIDCTokenRequestFailed, err, "OIDC token signature is not valid base64")
+	}
+	return token, nil
+}
+
+// DefaultLeeway is the clock drift between CI runners and keystone tolerated
+// when checking a token's exp, nbf and iat claims
+const DefaultLeeway = time.Minute
+
+// ValidateClaims checks the token was issued by the issuer for the audience,
+// identifies its subject and is valid at now, tolerating DefaultLeeway of
+// clock drift
+func ValidateClaims(claims *Claims, issuer, audience string, now time.Time) error {
+	return ValidateClaimsWithLeeway(claims, issuer, audience, now, DefaultLeeway)
+}
+
+// ValidateClaimsWithLeeway is ValidateClaims with the given tolerance for
+// clock drift. A token is accepted until leeway after it expires, from leeway
+// before its nbf, and only if it wasn't issued more than leeway in the future.
+func ValidateClaimsWithLeeway(claims *Claims, issuer, audience string, now time.Time, leeway time.Duration) error {
+	if leeway < 0 {
+		leeway = 0
+	}
+	if strings.TrimSuffix(claims.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return attestation.Errorf(attestation.CodeInvalidIssuer, "Invalid OIDC issuer claim: expected %s, got %s", issuer, claims.Issuer)
+	}
+	if audience != "" && !claims.Audience.Contains(audience) {
+		return attestation.Errorf(attestation.CodeInvalidAudience, "Invalid OIDC audience claim: expected %s, got %s",
+			audience, strings.Join(claims.Audience, ", "))
+	}
+	if claims.Subject == "" {
+		return attestation.Errorf(attestation.CodeMissingSubject, "Missing OIDC subject claim")
+	}
+	if claims.ExpiresAt == 0 {
+		return attestation.Errorf(attestation.CodeTokenExpired, "OIDC token has no expiry")
+	}
+	if !now.Before(claims.Expiry().Add(leeway)) {
+		return attestation.Errorf(attestation.CodeTokenExpired, "OIDC token expired at %s", formatClaimTime(claims.ExpiresAt))
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-leeway)) {
+		return attestation.Errorf(attestation.CodeTokenExpired, "OIDC token is not valid until %s", formatClaimTime(claims.NotBefore))
+	}
+	if claims.IssuedAt != 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(leeway)) {
+		return attestation.Errorf(attestation.CodeTokenExpired, "OIDC token was issued in the future, at %s; check the runner's clock", formatClaimTime(claims.IssuedAt))
+	}
+	return nil
+}
+
+// formatClaimTime formats a NumericDate claim
+func formatClaimTime(seconds int64) string {
+	return time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,416 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+)
+
+// GitHubIssuer issues GitHub Actions OIDC tokens
+const GitHubIssuer = attestation.GitHubActionsIssuer
+
+// VerifierConfig holds token verification configuration
+type VerifierConfig struct {
+	Issuer             string        // Tokens must be issued by it
+	Audience           string        // Tokens must be issued for it; any audience when empty
+	JWKSURL            string        // Discovered from the issuer's OpenID configuration when empty
+	CacheTTL           time.Duration // How long a fetched key set is reused
+	MinRefreshInterval time.Duration // Bounds key set refetches for tokens with unknown key IDs
+	Leeway             time.Duration // Tolerated clock drift; DefaultLeeway when zero, none when negative
+	Transport          http.RoundTripper
+	Clock              clock.Clock // Defaults to the system clock
+}
+
+// DefaultVerifierConfig verifies GitHub Actions tokens for the audience
+func DefaultVerifierConfig(audience string) VerifierConfig {
+	return VerifierConfig{
+		Issuer:             GitHubIssuer,
+		Audience:           audience,
+		CacheTTL:           time.Hour,
+		MinRefreshInterval: time.Minute,
+	}
+}
+
+// Environment variables overriding the issuer tokens are verified against
+// and where its keys are fetched from
+const (
+	IssuerEnv  = "KEYSTONE_OIDC_ISSUER"
+	JWKSURLEnv = "KEYSTONE_OIDC_JWKS_URL"
+)
+
+// EnterpriseIssuer returns the Actions token issuer of a GitHub Enterprise
+// Server, given its URL
+func EnterpriseIssuer(serverURL string) string {
+	return strings.TrimSuffix(serverURL, "/") + "/_services/token"
+}
+
+// IssuerFromEnv returns KEYSTONE_OIDC_ISSUER, or the Actions token issuer of
+// the server GITHUB_SERVER_URL names, defaulting to github.com's
+func IssuerFromEnv() string {
+	if issuer := os.Getenv(IssuerEnv); issuer != "" {
+		return issuer
+	}
+	server := strings.TrimSuffix(os.Getenv("GITHUB_SERVER_URL"), "/")
+	if server == "" || strings.EqualFold(server, "https://github.com") {
+		return GitHubIssuer
+	}
+	return EnterpriseIssuer(server)
+}
+
+// VerifierConfigFromEnv returns DefaultVerifierConfig for the issuer
+// IssuerFromEnv names, fetching keys from KEYSTONE_OIDC_JWKS_URL when set
+func VerifierConfigFromEnv(audience string) VerifierConfig {
+	config := DefaultVerifierConfig(audience)
+	config.Issuer = IssuerFromEnv()
+	config.JWKSURL = os.Getenv(JWKSURLEnv)
+	return config
+}
+
+// JSONWebKey is one key of a JWKS document
+type JSONWebKey struct {
+	KeyType   string   `json:"kty"`
+	KeyID     string   `json:"kid"`
+	Algorithm string   `json:"alg,omitempty"`
+	Use       string   `json:"use,omitempty"`
+	N         string   `json:"n,omitempty"`
+	E         string   `json:"e,omitempty"`
+	Curve     string   `json:"crv,omitempty"`
+	X         string   `json:"x,omitempty"`
+	Y         string   `json:"y,omitempty"`
+	X5C       []string `json:"x5c,omitempty"`
+}
+
+// JSONWebKeySet is a JWKS document
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// PublicKey decodes the key
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %s has an invalid modulus: %w", k.KeyID, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %s has an invalid exponent: %w", k.KeyID, err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %s has an invalid exponent", k.KeyID)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("key %s uses unsupported curve %q", k.KeyID, k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("key %s has an invalid x coordinate: %w", k.KeyID, err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("key %s has an invalid y coordinate: %w", k.KeyID, err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("key %s is not on curve %s", k.KeyID, k.Curve)
+		}
+		return key, nil
+	}
+
+	if len(k.X5C) > 0 {
+		der, err := base64.StdEncoding.DecodeString(k.X5C[0])
+		if err != nil {
+			return nil, fmt.Errorf("key %s has an invalid certificate: %w", k.KeyID, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("key %s has an invalid certificate: %w", k.KeyID, err)
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("key %s has unsupported type %q", k.KeyID, k.KeyType)
+}
+
+// Verifier verifies OIDC tokens against the issuer's published signing keys.
+// Key sets are cached in memory; a token signed with a key ID the cached set
+// lacks triggers a refetch, so key rotation is picked up without waiting for
+// the cache to expire. They are never shared through the result cache, whose
+// Redis and Actions cache tiers can be written by other processes.
+type Verifier struct {
+	config     VerifierConfig
+	httpClient *http.Client
+	clock      clock.Clock
+
+	mutex       sync.Mutex
+	keys        map[string]signingKey
+	fetchedAt   time.Time
+	lastRefresh time.Time
+}
+
+// signingKey is a published key with the algorithm the issuer declared for it
+type signingKey struct {
+	key       crypto.PublicKey
+	algorithm string // Empty when the key doesn't declare one
+}
+
+// NewVerifier creates a verifier
+func NewVerifier(config VerifierConfig) *Verifier {
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = time.Hour
+	}
+	return &Verifier{
+		config:     config,
+		httpClient: &http.Client{Timeout: 30 * time.Second, Transport: config.Transport},
+		clock:      clock.OrReal(config.Clock),
+	}
+}
+
+// Verify checks the token's signature and claims and returns its claims
+func (v *Verifier) Verify(ctx context.Context, raw string) (*Claims, error) {
+	token, err := Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := v.key(ctx, token.Header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.algorithm != "" && key.algorithm != token.Header.Algorithm {
+		return nil, attestation.Errorf(attestation.CodeInvalidTokenSignature,
+			"OIDC token is signed with %s, but key %q is published for %s", token.Header.Algorithm, token.Header.KeyID, key.algorithm)
+	}
+	if err := verifySignature(token.Header.Algorithm, key.key, []byte(token.signed), token.Signature); err != nil {
+		return nil, attestation.Wrap(attestation.CodeInvalidTokenSignature, err, "OIDC token signature is not valid")
+	}
+
+	leeway := v.config.Leeway
+	if leeway == 0 {
+		leeway = DefaultLeeway
+	}
+	if err := ValidateClaimsWithLeeway(token.Claims, v.config.Issuer, v.config.Audience, v.clock.Now(), leeway); err != nil {
+		return nil, err
+	}
+	return token.Claims, nil
+}
+
+// key returns the signing key with the ID, refetching the key set once when
+// the ID is unknown
+func (v *Verifier) key(ctx context.Context, kid string) (signingKey, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	now := v.clock.Now()
+	if v.keys == nil || now.Sub(v.fetchedAt) >= v.config.CacheTTL {
+		if err := v.load(ctx); err != nil {
+			return signingKey{}, err
+		}
+	}
+	if key, found := v.lookup(kid); found {
+		return key, nil
+	}
+
+	// An unknown key ID usually means the issuer rotated its keys. Refetching
+	// is bounded so tokens with made-up key IDs can't hammer the issuer.
+	if v.lastRefresh.IsZero() || now.Sub(v.lastRefresh) >= v.config.MinRefreshInterval {
+		v.lastRefresh = now
+		if err := v.load(ctx); err != nil {
+			return signingKey{}, err
+		}
+		if key, found := v.lookup(kid); found {
+			return key, nil
+		}
+	}
+	return signingKey{}, attestation.Errorf(attestation.CodeInvalidTokenSignature, "OIDC token is signed with key %q, which %s doesn't publish", kid, v.config.Issuer)
+}
+
+// lookup finds a key by ID; tokens without one match a set of a single key
+func (v *Verifier) lookup(kid string) (signingKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, found := v.keys[kid]
+	return key, found
+}
+
+// load fetches the key set from the issuer
+func (v *Verifier) load(ctx context.Context) error {
+	document, err := v.fetchKeySet(ctx)
+	if err != nil {
+		return err
+	}
+
+	var set JSONWebKeySet
+	if err := json.Unmarshal(document, &set); err != nil {
+		return attestation.Wrap(attestation.CodeOIDCTokenRequestFailed, err, "OIDC issuer %s published an invalid key set", v.config.Issuer)
+	}
+	keys := make(map[string]signingKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue // Keys of unsupported types can't have signed a token we accept
+		}
+		keys[jwk.KeyID] = signingKey{key: key, algorithm: jwk.Algorithm}
+	}
+	v.keys = keys
+	v.fetchedAt = v.clock.Now()
+	return nil
+}
+
+// fetchKeySet downloads the key set, discovering its URL from the issuer's
+// OpenID configuration unless it is configured
+func (v *Verifier) fetchKeySet(ctx context.Context) ([]byte, error) {
+	jwksURL := v.config.JWKSURL
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		data, err := v.get(ctx, strings.TrimSuffix(v.config.Issuer, "/")+"/.well-known/openid-configuration")
+		if err == nil {
+			err = json.Unmarshal(data, &discovery)
+		}
+		if err != nil {
+			return nil, attestation.Wrap(attestation.CodeNetworkTimeout, err, "Failed to discover the signing keys of OIDC issuer %s", v.config.Issuer)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, attestation.Errorf(attestation.CodeOIDCTokenRequestFailed, "OIDC issuer %s doesn't publish a jwks_uri", v.config.Issuer)
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	data, err := v.get(ctx, jwksURL)
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeNetworkTimeout, err, "Failed to fetch the signing keys of OIDC issuer %s", v.config.Issuer)
+	}
+	return data, nil
+}
+
+// get fetches a small JSON document
+func (v *Verifier) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// verifySignature checks a JWS signature with the algorithm the header names
+func verifySignature(algorithm string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch algorithm {
+	case "RS256", "ES256", "PS256":
+		hash = crypto.SHA256
+	case "RS384", "ES384", "PS384":
+		hash = crypto.SHA384
+	case "RS512", "ES512", "PS512":
+		hash = crypto.SHA512
+	default:
+		// Includes "none", which must never be accepted
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	digest := digestOf(hash, signed)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(algorithm, "PS") {
+			return rsa.VerifyPSS(key, hash, digest, signature, nil)
+		}
+		if !strings.HasPrefix(algorithm, "RS") {
+			return fmt.Errorf("algorithm %s doesn't match an RSA key", algorithm)
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature)
+
+	case *ecdsa.PublicKey:
+		// RFC 7518 §3.4 pins each ES algorithm to one curve
+		if curve := ecdsaCurve(algorithm); curve == nil || key.Curve != curve {
+			return fmt.Errorf("algorithm %s doesn't match an EC key on curve %s", algorithm, key.Curve.Params().Name)
+		}
+		// JWS encodes ECDSA signatures as fixed-width r || s
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("ECDSA signature has length %d, expected %d", len(signature), 2*size)
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("ECDSA signature does not verify")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported key type %T", key)
+}
+
+// ecdsaCurve returns the curve an ES algorithm signs with, or nil for other
+// algorithms
+func ecdsaCurve(algorithm string) elliptic.Curve {
+	switch algorithm {
+	case "ES256":
+		return elliptic.P256()
+	case "ES384":
+		return elliptic.P384()
+	case "ES512":
+		return elliptic.P521()
+	default:
+		return nil
+	}
+}
+
+// digestOf hashes data with a SHA-2 hash
+func digestOf(hash crypto.Hash, data []byte) []byte {
+	switch hash {
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	default:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+}
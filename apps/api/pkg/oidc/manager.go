@@ -0,0 +1,290 @@
+package oidc
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+)
+
+// DefaultRefreshThreshold is how long before expiry a token is replaced.
+// Actions tokens live for about 15 minutes, so signing never starts with
+// less than 10 minutes left.
+const DefaultRefreshThreshold = 10 * time.Minute
+
+// TokenManager serves cached tokens wherever a token source is expected
+var _ attestation.TokenSource = (*TokenManager)(nil)
+
+// AudienceRule is how tokens for one audience are refreshed and validated
+type AudienceRule struct {
+	RefreshThreshold time.Duration // Overrides the manager's threshold
+	Issuer           string        // Tokens must be issued by it; unchecked when empty
+	Verifier         *Verifier     // Verifies signature and claims; takes precedence over Issuer
+
+	// Constraints restrict which pipelines' tokens are accepted for signing
+	Constraints *attestation.SigningConstraints
+	// Allowlist restricts signing to approved reusable workflows at pinned refs
+	Allowlist *attestation.WorkflowAllowlist
+}
+
+// Environment variables naming the JSON files of the signing audience's rule
+const (
+	SigningConstraintsEnv = "KEYSTONE_SIGNING_CONSTRAINTS"
+	WorkflowAllowlistEnv  = "KEYSTONE_WORKFLOW_ALLOWLIST"
+)
+
+// ManagerConfigFromEnv returns a configuration for the sigstore audience whose
+// tokens must satisfy the signing constraints and workflow allowlist in the
+// files KEYSTONE_SIGNING_CONSTRAINTS and KEYSTONE_WORKFLOW_ALLOWLIST name;
+// either is unchecked when unset
+func ManagerConfigFromEnv() (ManagerConfig, error) {
+	var rule AudienceRule
+	if path := os.Getenv(SigningConstraintsEnv); path != "" {
+		constraints, err := attestation.LoadSigningConstraints(path)
+		if err != nil {
+			return ManagerConfig{}, err
+		}
+		rule.Constraints = constraints
+	}
+	if path := os.Getenv(WorkflowAllowlistEnv); path != "" {
+		allowlist, err := attestation.LoadWorkflowAllowlist(path)
+		if err != nil {
+			return ManagerConfig{}, err
+		}
+		rule.Allowlist = allowlist
+	}
+	return ManagerConfig{Audience: "sigstore", Audiences: map[string]AudienceRule{"sigstore": rule}}, nil
+}
+
+// Availability lists the services a capability needs that are down. An
+// *cache.OfflineDetector monitoring the OIDC issuer satisfies it.
+type Availability interface {
+	Unavailable(capability cache.Capability) []string
+}
+
+// ManagerConfig holds token manager configuration
+type ManagerConfig struct {
+	Audience         string                  // Audience of GetValidToken; "sigstore" when empty
+	RefreshThreshold time.Duration           // Tokens with less time left are refreshed
+	Audiences        map[string]AudienceRule // Per-audience rules, e.g. for registries and cloud providers
+	Availability     Availability            // Fails signing tokens fast while signing services are down; unchecked when nil
+	Clock            clock.Clock             // Defaults to the system clock
+}
+
+// TokenManager caches a token per audience and requests a new one before it
+// expires, so long-running jobs can sign, push to registries and exchange
+// tokens with cloud providers at any point without tracking token lifetimes
+type TokenManager struct {
+	source    attestation.TokenSource
+	audience  string
+	threshold time.Duration
+	rules     map[string]AudienceRule
+	available Availability
+	clock     clock.Clock
+
+	mutex   sync.Mutex
+	entries map[string]*tokenEntry
+}
+
+// tokenEntry is one audience's cached token. Its own mutex lets audiences
+// refresh concurrently.
+type tokenEntry struct {
+	mutex  sync.Mutex
+	token  *secret.String
+	expiry time.Time
+}
+
+// replace swaps the cached token, zeroing the one it replaces
+func (e *tokenEntry) replace(token *secret.String, expiry time.Time) {
+	e.token.Release()
+	e.token, e.expiry = token, expiry
+}
+
+// NewTokenManager creates a manager requesting tokens from source
+func NewTokenManager(source attestation.TokenSource, config ManagerConfig) *TokenManager {
+	if config.Audience == "" {
+		config.Audience = "sigstore"
+	}
+	if config.RefreshThreshold <= 0 {
+		config.RefreshThreshold = DefaultRefreshThreshold
+	}
+	return &TokenManager{
+		source:    source,
+		audience:  config.Audience,
+		threshold: config.RefreshThreshold,
+		rules:     config.Audiences,
+		available: config.Availability,
+		clock:     clock.OrReal(config.Clock),
+		entries:   make(map[string]*tokenEntry),
+	}
+}
+
+// GetValidToken returns a valid token for the manager's default audience
+func (m *TokenManager) GetValidToken(ctx context.Context) (string, error) {
+	return m.Token(ctx, m.audience)
+}
+
+// Token returns the audience's cached token, or a new one once the cached
+// token has less than the refresh threshold left. When a refresh fails the
+// cached token is returned for as long as it hasn't expired. Tokens for the
+// default audience sign, so they fail with SIGN_072 while the OIDC issuer or
+// Sigstore is down rather than leave signing to time out.
+func (m *TokenManager) Token(ctx context.Context, audience string) (string, error) {
+	if audience == m.audience {
+		if err := m.signingAvailable(); err != nil {
+			return "", err
+		}
+	}
+
+	entry := m.entry(audience)
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	now := m.clock.Now()
+	if !entry.token.Empty() && entry.expiry.Sub(now) >= m.thresholdFor(audience) {
+		return entry.token.Reveal(), nil
+	}
+
+	token, expiry, err := m.request(ctx, audience)
+	if err != nil {
+		if !entry.token.Empty() && now.Before(entry.expiry) {
+			return entry.token.Reveal(), nil
+		}
+		return "", err
+	}
+	entry.replace(secret.New(token), expiry)
+	return token, nil
+}
+
+// Audiences lists the audiences with a cached token
+func (m *TokenManager) Audiences() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	audiences := make([]string, 0, len(m.entries))
+	for audience := range m.entries {
+		audiences = append(audiences, audience)
+	}
+	sort.Strings(audiences)
+	return audiences
+}
+
+// Expiry returns when the audience's cached token expires, or the zero time
+// without one
+func (m *TokenManager) Expiry(audience string) time.Time {
+	entry := m.entry(audience)
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	return entry.expiry
+}
+
+// Invalidate drops and zeroes the cached tokens of the audiences, or of every
+// audience when none are named, e.g. after a service rejected a token or
+// once a job is done signing
+func (m *TokenManager) Invalidate(audiences ...string) {
+	m.mutex.Lock()
+	if len(audiences) == 0 {
+		for audience := range m.entries {
+			audiences = append(audiences, audience)
+		}
+	}
+	dropped := make([]*tokenEntry, 0, len(audiences))
+	for _, audience := range audiences {
+		if entry, found := m.entries[audience]; found {
+			dropped = append(dropped, entry)
+			delete(m.entries, audience)
+		}
+	}
+	m.mutex.Unlock()
+
+	// A refresh in progress holds the entry's lock; release after it's done
+	for _, entry := range dropped {
+		entry.mutex.Lock()
+		entry.replace(nil, time.Time{})
+		entry.mutex.Unlock()
+	}
+}
+
+// entry returns the audience's cache entry, creating it on first use
+func (m *TokenManager) entry(audience string) *tokenEntry {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, found := m.entries[audience]
+	if !found {
+		entry = &tokenEntry{}
+		m.entries[audience] = entry
+	}
+	return entry
+}
+
+// signingAvailable fails when a service signing needs is down
+func (m *TokenManager) signingAvailable() error {
+	if m.available == nil {
+		return nil
+	}
+	if down := m.available.Unavailable(cache.CapabilitySigning); len(down) > 0 {
+		return attestation.Errorf(attestation.CodeSigningUnavailable, "Signing unavailable in offline mode: %s unreachable", strings.Join(down, ", "))
+	}
+	return nil
+}
+
+// thresholdFor returns the audience's refresh threshold
+func (m *TokenManager) thresholdFor(audience string) time.Duration {
+	if rule, found := m.rules[audience]; found && rule.RefreshThreshold > 0 {
+		return rule.RefreshThreshold
+	}
+	return m.threshold
+}
+
+// request fetches a token for the audience, validates it against the
+// audience's rule and reads its expiry. Tokens come straight from the issuer,
+// so without a verifier their claims are read without checking the signature.
+func (m *TokenManager) request(ctx context.Context, audience string) (string, time.Time, error) {
+	token, err := m.source.Token(ctx, audience)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	rule := m.rules[audience]
+	var claims *Claims
+	if rule.Verifier != nil {
+		if claims, err = rule.Verifier.Verify(ctx, token); err != nil {
+			return "", time.Time{}, err
+		}
+	} else {
+		parsed, err := Parse(token)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		claims = parsed.Claims
+		if rule.Issuer != "" {
+			if err := ValidateClaims(claims, rule.Issuer, audience, m.clock.Now()); err != nil {
+				return "", time.Time{}, err
+			}
+		}
+	}
+
+	if len(claims.Audience) > 0 && !claims.Audience.Contains(audience) {
+		return "", time.Time{}, attestation.Errorf(attestation.CodeInvalidAudience, "OIDC token requested for %s was issued for %s", audience, claims.Audience[0])
+	}
+	if claims.ExpiresAt == 0 {
+		return "", time.Time{}, attestation.Errorf(attestation.CodeTokenExpired, "OIDC token has no expiry")
+	}
+	if rule.Constraints != nil {
+		if err := rule.Constraints.Authorize(claims.Raw); err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	if rule.Allowlist != nil {
+		if err := rule.Allowlist.Authorize(claims.Raw); err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	return token, claims.Expiry(), nil
+}
@@ -0,0 +1,191 @@
+// Package oidc requests OIDC identity tokens for keyless signing from the
+// GitHub Actions token service. A job granted "id-token: write" receives the
+// service's URL and a bearer token in ACTIONS_ID_TOKEN_REQUEST_URL and
+// ACTIONS_ID_TOKEN_REQUEST_TOKEN, and exchanges them for a short-lived JWT
+// for the audience it signs with.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+)
+
+// Variables GitHub Actions exposes to jobs with the id-token: write permission
+const (
+	RequestURLEnv   = "ACTIONS_ID_TOKEN_REQUEST_URL"
+	RequestTokenEnv = "ACTIONS_ID_TOKEN_REQUEST_TOKEN"
+)
+
+// maxResponseSize bounds the token response; tokens are a few kilobytes
+const maxResponseSize = 64 << 10
+
+// Config holds the token service configuration
+type Config struct {
+	RequestURL   string         // Token service URL, including its api-version query
+	RequestToken *secret.String // Bearer token authorizing the request
+	UserAgent    string
+	Timeout      time.Duration
+	Transport    http.RoundTripper // Optional, e.g. to inject faults in tests
+}
+
+// DefaultConfig reads the token service from the job's environment
+func DefaultConfig() Config {
+	return Config{
+		RequestURL:   strings.TrimSpace(os.Getenv(RequestURLEnv)),
+		RequestToken: secret.New(strings.TrimSpace(os.Getenv(RequestTokenEnv))),
+		UserAgent:    "keystone-attestation-service/1.0",
+		Timeout:      30 * time.Second,
+	}
+}
+
+// Validate checks the job can request tokens. The service must be reached
+// over HTTPS, except on a loopback address for local testing.
+func (c Config) Validate() error {
+	if c.RequestToken.Empty() {
+		return attestation.Errorf(attestation.CodeOIDCTokenUnavailable,
+			"OIDC token request token not available; grant the job the id-token: write permission (%s is not set)", RequestTokenEnv)
+	}
+	if c.RequestURL == "" {
+		return attestation.Errorf(attestation.CodeOIDCURLUnavailable,
+			"OIDC token request URL not available; grant the job the id-token: write permission (%s is not set)", RequestURLEnv)
+	}
+	parsed, err := url.Parse(c.RequestURL)
+	if err != nil || parsed.Host == "" {
+		return attestation.Errorf(attestation.CodeOIDCURLUnavailable, "OIDC token request URL %q is not a valid URL", c.RequestURL)
+	}
+	if parsed.Scheme != "https" && !(parsed.Scheme == "http" && isLoopback(parsed.Hostname())) {
+		return attestation.Errorf(attestation.CodeOIDCURLUnavailable, "OIDC token request URL must use HTTPS, got %q", parsed.Scheme)
+	}
+	return nil
+}
+
+// isLoopback reports whether host names the local machine
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// TokenResponse is the token service's response
+type TokenResponse struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Client requests identity tokens from the token service. It implements
+// attestation.TokenSource.
+type Client struct {
+	config     Config
+	httpClient *http.Client
+}
+
+var _ attestation.TokenSource = (*Client)(nil)
+
+// NewClient creates a client, failing when the job can't request tokens
+func NewClient(config Config) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	return &Client{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout, Transport: config.Transport},
+	}, nil
+}
+
+// Close releases the request token; the client can't request tokens after
+func (c *Client) Close() {
+	c.config.RequestToken.Release()
+}
+
+// FromEnvironment creates a client for the running GitHub Actions job
+func FromEnvironment() (*Client, error) {
+	return NewClient(DefaultConfig())
+}
+
+// Token requests a token for the audience and returns the JWT
+func (c *Client) Token(ctx context.Context, audience string) (string, error) {
+	response, err := c.Request(ctx, audience)
+	if err != nil {
+		return "", err
+	}
+	return response.Value, nil
+}
+
+// Request requests a token for the audience, which the service defaults to
+// the repository owner's URL when empty
+func (c *Client) Request(ctx context.Context, audience string) (*TokenResponse, error) {
+	requestURL, err := url.Parse(c.config.RequestURL)
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeOIDCURLUnavailable, err, "OIDC token request URL is not valid")
+	}
+	if audience != "" {
+		query := requestURL.Query()
+		query.Set("audience", audience)
+		requestURL.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeOIDCTokenRequestFailed, err, "Failed to create OIDC token request")
+	}
+	req.Header.Set("Authorization", "bearer "+c.config.RequestToken.Reveal())
+	req.Header.Set("Accept", "application/json")
+	if c.config.UserAgent != "" {
+		req.Header.Set("User-Agent", c.config.UserAgent)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(ctxErr, context.DeadlineExceeded) {
+			return nil, attestation.Wrap(attestation.CodeOIDCTokenRequestFailed, ctxErr, "OIDC token request was cancelled")
+		}
+		return nil, attestation.Wrap(attestation.CodeNetworkTimeout, err, "Network connectivity failure requesting OIDC token")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, attestation.Wrap(attestation.CodeNetworkTimeout, err, "Failed to read OIDC token response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, attestation.Errorf(attestation.CodeOIDCTokenRequestFailed,
+			"OIDC token acquisition failed with status %d%s", resp.StatusCode, serviceMessage(body))
+	}
+
+	var token TokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, attestation.Wrap(attestation.CodeOIDCTokenRequestFailed, err, "Failed to decode OIDC token response")
+	}
+	if token.Value == "" {
+		return nil, attestation.Errorf(attestation.CodeOIDCTokenRequestFailed, "OIDC token response has no token")
+	}
+	return &token, nil
+}
+
+// serviceMessage formats the message of an error response, if it has one
+func serviceMessage(body []byte) string {
+	var response struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &response) != nil || response.Message == "" {
+		return ""
+	}
+	return fmt.Sprintf(": %s", secret.Scrub(response.Message))
+}
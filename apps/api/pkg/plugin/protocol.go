@@ -0,0 +1,135 @@
+// Package plugin is the protocol between keystone and the plugins that extend
+// it with custom finding enrichers, predicate generators and policy gates.
+//
+// Plugins are separate executables, in the style of hashicorp/go-plugin:
+// keystone starts each one as a subprocess, reads a handshake from its
+// stdout, then exchanges newline-delimited JSON requests and responses over
+// its stdin and stdout. A plugin's stderr is keystone's log. Plugins written
+// in Go call Serve; any other language only needs to speak the protocol.
+package plugin
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProtocolVersion is the protocol revision; keystone refuses plugins
+// announcing another one
+const ProtocolVersion = 1
+
+// MagicCookieKey and MagicCookieValue are set in a plugin's environment by
+// keystone. They tell a plugin binary that it was started by keystone rather
+// than run by hand, in which case it should print usage and exit.
+const (
+	MagicCookieKey   = "KEYSTONE_PLUGIN_MAGIC_COOKIE"
+	MagicCookieValue = "6d1c3c7a5b0e4f1f9b8a2e7d4c0f3a91"
+)
+
+// Kind is the kind of extension a capability provides
+type Kind string
+
+const (
+	KindEnricher  Kind = "enricher"  // Adds context to findings
+	KindPredicate Kind = "predicate" // Generates an attestation predicate
+	KindGate      Kind = "gate"      // Allows or blocks a verification result
+)
+
+// Methods a plugin answers
+const (
+	MethodEnrich   = "enrich"
+	MethodGenerate = "generate"
+	MethodEvaluate = "evaluate"
+	MethodShutdown = "shutdown"
+)
+
+// Capability is one extension a plugin provides
+type Capability struct {
+	Kind          Kind   `json:"kind"`
+	Name          string `json:"name"`
+	PredicateType string `json:"predicate_type,omitempty"` // Predicate generators only
+}
+
+// Handshake is the first line a plugin writes to stdout
+type Handshake struct {
+	Protocol     int          `json:"protocol"`
+	Name         string       `json:"name"`
+	Version      string       `json:"version,omitempty"`
+	Capabilities []Capability `json:"capabilities"`
+}
+
+// Request is a call from keystone; Capability names the extension it is for
+type Request struct {
+	ID         uint64          `json:"id"`
+	Method     string          `json:"method"`
+	Capability string          `json:"capability,omitempty"`
+	Params     json.RawMessage `json:"params,omitempty"`
+}
+
+// Response answers the request with the same ID, with a result or an error
+type Response struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Finding is a security finding as keystone reports it
+type Finding struct {
+	ID          string            `json:"id"`
+	Source      string            `json:"source"`
+	Category    string            `json:"category"`
+	Severity    string            `json:"severity"`
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	Component   string            `json:"component,omitempty"`
+	Version     string            `json:"version,omitempty"`
+	PURL        string            `json:"purl,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Message     json.RawMessage   `json:"message,omitempty"` // Catalog message; pass it through unchanged
+	DetectedAt  time.Time         `json:"detected_at"`
+}
+
+// EnrichRequest asks an enricher to add context to findings
+type EnrichRequest struct {
+	Findings []Finding `json:"findings"`
+}
+
+// EnrichResponse returns the enriched findings. Enrichers may change
+// severities, descriptions and metadata, and add or drop findings.
+type EnrichResponse struct {
+	Findings []Finding `json:"findings"`
+}
+
+// Subject is the artifact an attestation is about
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// GenerateRequest asks a predicate generator for a predicate about the
+// subject. Build is keystone's build context and Statements the statements
+// generated by the steps the generator depends on, by step name.
+type GenerateRequest struct {
+	Subject    Subject                    `json:"subject"`
+	Build      json.RawMessage            `json:"build,omitempty"`
+	Statements map[string]json.RawMessage `json:"statements,omitempty"`
+}
+
+// GenerateResponse holds the generated predicate, attested under the
+// capability's predicate type
+type GenerateResponse struct {
+	Predicate json.RawMessage `json:"predicate"`
+}
+
+// EvaluateRequest asks a gate to judge a verification result
+type EvaluateRequest struct {
+	Scope      string    `json:"scope,omitempty"`
+	Components int       `json:"components"`
+	Verified   int       `json:"verified"`
+	Findings   []Finding `json:"findings,omitempty"` // Findings not waived by an exception
+}
+
+// Decision is a gate's verdict
+type Decision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,197 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// MaxMessageSize bounds one request or response line
+const MaxMessageSize = 16 << 20
+
+// Enricher adds context to findings
+type Enricher interface {
+	Enrich(ctx context.Context, findings []Finding) ([]Finding, error)
+}
+
+// EnricherFunc adapts a function to an Enricher
+type EnricherFunc func(ctx context.Context, findings []Finding) ([]Finding, error)
+
+// Enrich calls f
+func (f EnricherFunc) Enrich(ctx context.Context, findings []Finding) ([]Finding, error) {
+	return f(ctx, findings)
+}
+
+// PredicateGenerator generates predicates of one type
+type PredicateGenerator interface {
+	PredicateType() string
+	Generate(ctx context.Context, req GenerateRequest) (interface{}, error)
+}
+
+// Gate allows or blocks verification results
+type Gate interface {
+	Evaluate(ctx context.Context, req EvaluateRequest) (Decision, error)
+}
+
+// GateFunc adapts a function to a Gate
+type GateFunc func(ctx context.Context, req EvaluateRequest) (Decision, error)
+
+// Evaluate calls f
+func (f GateFunc) Evaluate(ctx context.Context, req EvaluateRequest) (Decision, error) {
+	return f(ctx, req)
+}
+
+// Plugin is what a plugin binary serves, by capability name
+type Plugin struct {
+	Name       string
+	Version    string
+	Enrichers  map[string]Enricher
+	Generators map[string]PredicateGenerator
+	Gates      map[string]Gate
+}
+
+// ErrNotLaunched is returned by Serve when the binary wasn't started by keystone
+var ErrNotLaunched = errors.New("this binary is a keystone plugin and is started by keystone, not run directly")
+
+// Serve runs the plugin over stdin and stdout until keystone shuts it down
+// or closes stdin
+func Serve(p Plugin) error {
+	if os.Getenv(MagicCookieKey) != MagicCookieValue {
+		return ErrNotLaunched
+	}
+	return ServeIO(context.Background(), p, os.Stdin, os.Stdout)
+}
+
+// ServeIO runs the plugin over the reader and writer. Requests are handled
+// concurrently; responses may be written in any order.
+func ServeIO(ctx context.Context, p Plugin, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var writeMutex sync.Mutex
+	write := func(v interface{}) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		_, err = out.Write(append(data, '\n'))
+		return err
+	}
+
+	if err := write(p.handshake()); err != nil {
+		return fmt.Errorf("failed to write handshake: %w", err)
+	}
+
+	var handlers sync.WaitGroup
+	defer handlers.Wait()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), MaxMessageSize)
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return fmt.Errorf("invalid request: %w", err)
+		}
+		if req.Method == MethodShutdown {
+			cancel()
+			handlers.Wait()
+			return write(Response{ID: req.ID})
+		}
+
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			resp := Response{ID: req.ID}
+			result, err := p.handle(ctx, req)
+			if err == nil {
+				resp.Result, err = json.Marshal(result)
+			}
+			if err != nil {
+				resp.Error = err.Error()
+			}
+			write(resp)
+		}()
+	}
+	return scanner.Err()
+}
+
+// handshake announces the plugin's capabilities in a stable order
+func (p Plugin) handshake() Handshake {
+	handshake := Handshake{Protocol: ProtocolVersion, Name: p.Name, Version: p.Version, Capabilities: []Capability{}}
+	for name := range p.Enrichers {
+		handshake.Capabilities = append(handshake.Capabilities, Capability{Kind: KindEnricher, Name: name})
+	}
+	for name, generator := range p.Generators {
+		handshake.Capabilities = append(handshake.Capabilities, Capability{Kind: KindPredicate, Name: name, PredicateType: generator.PredicateType()})
+	}
+	for name := range p.Gates {
+		handshake.Capabilities = append(handshake.Capabilities, Capability{Kind: KindGate, Name: name})
+	}
+	sort.Slice(handshake.Capabilities, func(i, j int) bool {
+		a, b := handshake.Capabilities[i], handshake.Capabilities[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return handshake
+}
+
+// handle dispatches a request to the capability it names
+func (p Plugin) handle(ctx context.Context, req Request) (interface{}, error) {
+	switch req.Method {
+	case MethodEnrich:
+		enricher, found := p.Enrichers[req.Capability]
+		if !found {
+			return nil, fmt.Errorf("no enricher %q", req.Capability)
+		}
+		var params EnrichRequest
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		enriched, err := enricher.Enrich(ctx, params.Findings)
+		if err != nil {
+			return nil, err
+		}
+		return EnrichResponse{Findings: enriched}, nil
+
+	case MethodGenerate:
+		generator, found := p.Generators[req.Capability]
+		if !found {
+			return nil, fmt.Errorf("no predicate generator %q", req.Capability)
+		}
+		var params GenerateRequest
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		predicate, err := generator.Generate(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(predicate)
+		if err != nil {
+			return nil, err
+		}
+		return GenerateResponse{Predicate: data}, nil
+
+	case MethodEvaluate:
+		gate, found := p.Gates[req.Capability]
+		if !found {
+			return nil, fmt.Errorf("no gate %q", req.Capability)
+		}
+		var params EvaluateRequest
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return gate.Evaluate(ctx, params)
+	}
+	return nil, fmt.Errorf("unknown method %q", req.Method)
+}
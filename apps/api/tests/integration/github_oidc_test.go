@@ -2,6 +2,7 @@ package integration
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,80 +13,17 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-)
-
-// GitHubOIDCClient represents the GitHub OIDC integration client
-type GitHubOIDCClient struct {
-	tokenURL     string
-	requestToken string
-	httpClient   *http.Client
-}
-
-// OIDCTokenResponse represents the response from GitHub OIDC token endpoint
-type OIDCTokenResponse struct {
-	Value string `json:"value"`
-	Count int    `json:"count"`
-}
-
-// OIDCClaims represents the decoded OIDC token claims
-type OIDCClaims struct {
-	Issuer     string `json:"iss"`
-	Audience   string `json:"aud"`
-	Subject    string `json:"sub"`
-	Actor      string `json:"actor"`
-	Repository string `json:"repository"`
-	Ref        string `json:"ref"`
-	SHA        string `json:"sha"`
-	RunID      string `json:"run_id"`
-	RunNumber  string `json:"run_number"`
-	Workflow   string `json:"workflow"`
-	IssuedAt   int64  `json:"iat"`
-	ExpiresAt  int64  `json:"exp"`
-}
-
-func NewGitHubOIDCClient(tokenURL, requestToken string) *GitHubOIDCClient {
-	return &GitHubOIDCClient{
-		tokenURL:     tokenURL,
-		requestToken: requestToken,
-		httpClient:   &http.Client{Timeout: 30 * time.Second},
-	}
-}
-
-func (c *GitHubOIDCClient) GetOIDCToken(audience string) (*OIDCTokenResponse, error) {
-	url := fmt.Sprintf("%s&audience=%s", c.tokenURL, audience)
-	
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("SIGN_003: Failed to create OIDC token request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("bearer %s", c.requestToken))
-	req.Header.Set("User-Agent", "keystone-attestation-service/1.0")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("SIGN_071: Network connectivity timeout: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("SIGN_003: OIDC token acquisition failed with status %d", resp.StatusCode)
-	}
 
-	var tokenResp OIDCTokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return nil, fmt.Errorf("SIGN_003: Failed to decode OIDC token response: %w", err)
-	}
-
-	return &tokenResp, nil
-}
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+	"github.com/salman-frs/keystone/apps/api/pkg/oidc"
+)
 
 // TestGitHubOIDCIntegration tests the complete GitHub OIDC workflow integration
 func TestGitHubOIDCIntegration(t *testing.T) {
 	tests := []struct {
 		name           string
 		audience       string
-		mockResponse   OIDCTokenResponse
+		mockResponse   oidc.TokenResponse
 		mockStatusCode int
 		expectError    bool
 		errorCode      string
@@ -93,7 +31,7 @@ func TestGitHubOIDCIntegration(t *testing.T) {
 		{
 			name:     "successful_oidc_token_acquisition",
 			audience: "sigstore",
-			mockResponse: OIDCTokenResponse{
+			mockResponse: oidc.TokenResponse{
 				Value: "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.mock.signature",
 				Count: 1,
 			},
@@ -133,10 +71,11 @@ func TestGitHubOIDCIntegration(t *testing.T) {
 			defer mockServer.Close()
 
 			// Create OIDC client
-			client := NewGitHubOIDCClient(mockServer.URL, "mock-request-token")
+			client, err := oidc.NewClient(oidc.Config{RequestURL: mockServer.URL, RequestToken: secret.New("mock-request-token")})
+			require.NoError(t, err)
 
 			// Test OIDC token acquisition
-			tokenResp, err := client.GetOIDCToken(tt.audience)
+			tokenResp, err := client.Request(context.Background(), tt.audience)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -154,9 +93,9 @@ func TestGitHubOIDCIntegration(t *testing.T) {
 
 // TestOIDCTokenValidation tests token claims validation and verification
 func TestOIDCTokenValidation(t *testing.T) {
-	validClaims := OIDCClaims{
+	validClaims := oidc.Claims{
 		Issuer:     "https://token.actions.githubusercontent.com",
-		Audience:   "sigstore",
+		Audience:   oidc.Audience{"sigstore"},
 		Subject:    "repo:owner/repo:ref:refs/heads/main",
 		Actor:      "username",
 		Repository: "owner/repo",
@@ -171,7 +110,7 @@ func TestOIDCTokenValidation(t *testing.T) {
 
 	tests := []struct {
 		name        string
-		claims      OIDCClaims
+		claims      oidc.Claims
 		expectValid bool
 		errorCode   string
 	}{
@@ -182,7 +121,7 @@ func TestOIDCTokenValidation(t *testing.T) {
 		},
 		{
 			name: "invalid_issuer",
-			claims: func() OIDCClaims {
+			claims: func() oidc.Claims {
 				c := validClaims
 				c.Issuer = "https://invalid.issuer.com"
 				return c
@@ -192,9 +131,9 @@ func TestOIDCTokenValidation(t *testing.T) {
 		},
 		{
 			name: "invalid_audience",
-			claims: func() OIDCClaims {
+			claims: func() oidc.Claims {
 				c := validClaims
-				c.Audience = "invalid"
+				c.Audience = oidc.Audience{"invalid"}
 				return c
 			}(),
 			expectValid: false,
@@ -202,7 +141,7 @@ func TestOIDCTokenValidation(t *testing.T) {
 		},
 		{
 			name: "missing_subject",
-			claims: func() OIDCClaims {
+			claims: func() oidc.Claims {
 				c := validClaims
 				c.Subject = ""
 				return c
@@ -212,7 +151,7 @@ func TestOIDCTokenValidation(t *testing.T) {
 		},
 		{
 			name: "expired_token",
-			claims: func() OIDCClaims {
+			claims: func() oidc.Claims {
 				c := validClaims
 				c.ExpiresAt = time.Now().Add(-1 * time.Hour).Unix()
 				return c
@@ -224,7 +163,7 @@ func TestOIDCTokenValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateOIDCClaims(&tt.claims)
+			err := oidc.ValidateClaims(&tt.claims, oidc.GitHubIssuer, "sigstore", time.Now())
 
 			if tt.expectValid {
 				assert.NoError(t, err)
@@ -236,59 +175,46 @@ func TestOIDCTokenValidation(t *testing.T) {
 	}
 }
 
-func validateOIDCClaims(claims *OIDCClaims) error {
-	expectedIssuer := "https://token.actions.githubusercontent.com"
-	expectedAudience := "sigstore"
-
-	if claims.Issuer != expectedIssuer {
-		return fmt.Errorf("SIGN_004: Invalid OIDC issuer claim: expected %s, got %s", expectedIssuer, claims.Issuer)
-	}
-
-	if claims.Audience != expectedAudience {
-		return fmt.Errorf("SIGN_005: Invalid OIDC audience claim: expected %s, got %s", expectedAudience, claims.Audience)
-	}
-
-	if claims.Subject == "" {
-		return fmt.Errorf("SIGN_006: Missing OIDC subject claim")
-	}
-
-	if claims.ExpiresAt < time.Now().Unix() {
-		return fmt.Errorf("SIGN_008: OIDC token has expired")
-	}
-
-	return nil
-}
-
 // TestOIDCTokenRefresh tests token refresh and expiration handling
 func TestOIDCTokenRefresh(t *testing.T) {
-	t.Run("token_refresh_before_expiration", func(t *testing.T) {
-		// Simulate token that expires in 5 minutes
-		expiresAt := time.Now().Add(5 * time.Minute).Unix()
-		refreshThreshold := 10 * time.Minute // Refresh if less than 10 minutes remaining
-
-		claims := &OIDCClaims{
-			ExpiresAt: expiresAt,
-		}
-
-		timeUntilExpiry := time.Unix(claims.ExpiresAt, 0).Sub(time.Now())
-		shouldRefresh := timeUntilExpiry < refreshThreshold
+	issued := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issued++
+		payload, _ := json.Marshal(map[string]interface{}{
+			"sub": "repo:owner/repo:ref:refs/heads/main",
+			"exp": time.Now().Add(15 * time.Minute).Unix(),
+			"jti": fmt.Sprint(issued),
+		})
+		json.NewEncoder(w).Encode(oidc.TokenResponse{
+			Value: "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".c2lnbmF0dXJl",
+			Count: 1,
+		})
+	}))
+	defer mockServer.Close()
 
-		assert.True(t, shouldRefresh, "Token should be refreshed when close to expiration")
-	})
+	client, err := oidc.NewClient(oidc.Config{RequestURL: mockServer.URL, RequestToken: secret.New("mock-request-token")})
+	require.NoError(t, err)
 
 	t.Run("token_still_valid", func(t *testing.T) {
-		// Simulate token that expires in 12 minutes
-		expiresAt := time.Now().Add(12 * time.Minute).Unix()
-		refreshThreshold := 10 * time.Minute
-
-		claims := &OIDCClaims{
-			ExpiresAt: expiresAt,
-		}
-
-		timeUntilExpiry := time.Unix(claims.ExpiresAt, 0).Sub(time.Now())
-		shouldRefresh := timeUntilExpiry < refreshThreshold
+		// 15 minutes left, refreshed when less than 10 minutes remain
+		manager := oidc.NewTokenManager(client, oidc.ManagerConfig{RefreshThreshold: 10 * time.Minute})
+		first, err := manager.GetValidToken(context.Background())
+		require.NoError(t, err)
+		second, err := manager.GetValidToken(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, first, second, "Token should not be refreshed when still valid")
+	})
 
-		assert.False(t, shouldRefresh, "Token should not be refreshed when still valid")
+	t.Run("token_refresh_before_expiration", func(t *testing.T) {
+		// 15 minutes left, refreshed when less than 20 minutes remain
+		manager := oidc.NewTokenManager(client, oidc.ManagerConfig{RefreshThreshold: 20 * time.Minute})
+		first, err := manager.GetValidToken(context.Background())
+		require.NoError(t, err)
+		second, err := manager.GetValidToken(context.Background())
+		require.NoError(t, err)
+
+		assert.NotEqual(t, first, second, "Token should be refreshed when close to expiration")
 	})
 }
 
@@ -300,7 +226,7 @@ func TestOIDCRetryLogic(t *testing.T) {
 	// Mock server that fails twice then succeeds
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		retryAttempts++
-		
+
 		if retryAttempts <= 2 {
 			// Simulate transient failure
 			w.WriteHeader(http.StatusServiceUnavailable)
@@ -309,22 +235,22 @@ func TestOIDCRetryLogic(t *testing.T) {
 
 		// Succeed on third attempt
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(OIDCTokenResponse{
+		json.NewEncoder(w).Encode(oidc.TokenResponse{
 			Value: "success.token.value",
 			Count: 1,
 		})
 	}))
 	defer mockServer.Close()
 
-	client := NewGitHubOIDCClient(mockServer.URL, "mock-request-token")
+	client, err := oidc.NewClient(oidc.Config{RequestURL: mockServer.URL, RequestToken: secret.New("mock-request-token")})
+	require.NoError(t, err)
 
 	// Implement retry logic
-	var tokenResp *OIDCTokenResponse
-	var err error
+	var tokenResp *oidc.TokenResponse
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		tokenResp, err = client.GetOIDCToken("sigstore")
-		
+		tokenResp, err = client.Request(context.Background(), "sigstore")
+
 		if err == nil {
 			break
 		}
@@ -417,7 +343,7 @@ func TestEnvironmentVariableValidation(t *testing.T) {
 	// Save original environment
 	originalToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
 	originalURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
-	
+
 	defer func() {
 		os.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", originalToken)
 		os.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", originalURL)
@@ -467,7 +393,7 @@ func TestEnvironmentVariableValidation(t *testing.T) {
 			}
 
 			// Validate environment
-			err := validateEnvironment()
+			err := oidc.DefaultConfig().Validate()
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -478,17 +404,3 @@ func TestEnvironmentVariableValidation(t *testing.T) {
 		})
 	}
 }
-
-func validateEnvironment() error {
-	token := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
-	if token == "" {
-		return fmt.Errorf("SIGN_001: OIDC token request token not available")
-	}
-
-	url := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
-	if url == "" {
-		return fmt.Errorf("SIGN_002: OIDC token request URL not available")
-	}
-
-	return nil
-}
\ No newline at end of file
@@ -0,0 +1,262 @@
+package advisories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/advisories"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// advisoryServer fakes the global advisories API with two monthly windows:
+// January spans two pages, February a single page.
+type advisoryServer struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	remaining int
+	reset     time.Time
+	requests  []string
+	onRequest func(r *http.Request)
+}
+
+func newAdvisoryServer(t *testing.T) *advisoryServer {
+	s := &advisoryServer{remaining: 4000, reset: time.Now().Add(30 * time.Minute)}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		remaining, reset, hook := s.remaining, s.reset, s.onRequest
+		s.mutex.Unlock()
+
+		if r.URL.Path == "/rate_limit" {
+			var body github.RateLimitResponse
+			body.Resources.Core = github.RateLimit{Limit: 5000, Remaining: remaining, Reset: reset}
+			json.NewEncoder(w).Encode(body)
+			return
+		}
+
+		s.mutex.Lock()
+		s.requests = append(s.requests, r.URL.Query().Get("published")+"|"+r.URL.Query().Get("after"))
+		s.mutex.Unlock()
+		if hook != nil {
+			hook(r)
+		}
+
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		query := r.URL.Query()
+		var ids []string
+		switch {
+		case strings.HasPrefix(query.Get("published"), "2024-01") && query.Get("after") == "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/advisories?after=page2>; rel="next"`, s.URL))
+			ids = []string{"GHSA-aaaa-0001", "GHSA-aaaa-0002"}
+		case strings.HasPrefix(query.Get("published"), "2024-01"):
+			ids = []string{"GHSA-aaaa-0003"}
+		case strings.HasPrefix(query.Get("published"), "2024-02"):
+			ids = []string{"GHSA-bbbb-0001"}
+		}
+
+		page := make([]map[string]interface{}, 0, len(ids))
+		for _, id := range ids {
+			page = append(page, map[string]interface{}{
+				"ghsa_id":      id,
+				"severity":     "high",
+				"summary":      "test advisory " + id,
+				"published_at": "2024-01-15T10:00:00Z",
+			})
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *advisoryServer) setRateLimit(remaining int, reset time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.remaining, s.reset = remaining, reset
+}
+
+func (s *advisoryServer) setHook(hook func(r *http.Request)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onRequest = hook
+}
+
+func (s *advisoryServer) requestLog() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func newStore(t *testing.T) *advisories.Store {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	manager := storage.NewMigrationManager(db, "../../../internal/storage/migrations")
+	require.NoError(t, manager.Initialize())
+	require.NoError(t, manager.Migrate())
+
+	return advisories.NewStore(db)
+}
+
+func newBackfiller(server *advisoryServer, store *advisories.Store) *advisories.Backfiller {
+	clientConfig := github.DefaultConfig("test-token")
+	clientConfig.BaseURL = server.URL
+	clientConfig.RateLimitThreshold = 0
+
+	config := advisories.DefaultBackfillConfig("go", time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC))
+	config.Until = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	config.ResetBuffer = 10 * time.Millisecond
+
+	return advisories.NewBackfiller(github.NewClient(clientConfig), store, config)
+}
+
+func TestBackfillRun(t *testing.T) {
+	server := newAdvisoryServer(t)
+	store := newStore(t)
+	backfiller := newBackfiller(server, store)
+
+	assert.Equal(t, "backfill:go:2024-01-01", backfiller.JobID())
+
+	cp, err := backfiller.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, advisories.StatusCompleted, cp.Status)
+	assert.Equal(t, 4, cp.FetchedCount)
+	assert.Equal(t, 3, cp.RequestCount)
+	assert.Equal(t, []string{
+		"2024-01-01..2024-01-31|",
+		"2024-01-01..2024-01-31|page2",
+		"2024-02-01..2024-02-29|",
+	}, server.requestLog())
+
+	count, err := store.CountAdvisories(context.Background(), "go")
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	t.Run("completed_job_is_not_rerun", func(t *testing.T) {
+		cp, err := backfiller.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, advisories.StatusCompleted, cp.Status)
+		assert.Len(t, server.requestLog(), 3)
+	})
+}
+
+func TestBackfillResumesAfterInterrupt(t *testing.T) {
+	server := newAdvisoryServer(t)
+	store := newStore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Interrupt the job while the second page of January is in flight
+	server.setHook(func(r *http.Request) {
+		if r.URL.Query().Get("after") == "page2" {
+			cancel()
+			<-r.Context().Done()
+		}
+	})
+
+	cp, err := newBackfiller(server, store).Run(ctx)
+	require.Error(t, err)
+	assert.Equal(t, advisories.StatusInterrupted, cp.Status)
+
+	saved, err := store.LoadCheckpoint(context.Background(), "backfill:go:2024-01-01")
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, advisories.StatusInterrupted, saved.Status)
+	assert.Equal(t, "page2", saved.Cursor)
+	assert.Equal(t, 2, saved.FetchedCount)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), saved.WindowStart.UTC())
+
+	server.setHook(nil)
+	cp, err = newBackfiller(server, store).Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, advisories.StatusCompleted, cp.Status)
+	assert.Equal(t, 4, cp.FetchedCount)
+
+	// The first page is not fetched again on resume
+	assert.Equal(t, []string{
+		"2024-01-01..2024-01-31|",
+		"2024-01-01..2024-01-31|page2",
+		"2024-01-01..2024-01-31|page2",
+		"2024-02-01..2024-02-29|",
+	}, server.requestLog())
+}
+
+func TestBackfillWaitsForRateLimitReset(t *testing.T) {
+	server := newAdvisoryServer(t)
+	store := newStore(t)
+	backfiller := newBackfiller(server, store)
+
+	// The first response leaves the quota at the reserve, forcing a wait for reset
+	server.setRateLimit(1000, time.Now().Add(time.Second))
+	var once sync.Once
+	server.setHook(func(r *http.Request) {
+		once.Do(func() { go server.setRateLimit(5000, time.Now().Add(time.Hour)) })
+	})
+
+	start := time.Now()
+	cp, err := backfiller.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, advisories.StatusCompleted, cp.Status)
+	assert.Equal(t, 4, cp.FetchedCount)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	t.Run("cancelled_while_waiting", func(t *testing.T) {
+		server := newAdvisoryServer(t)
+		server.setRateLimit(10, time.Now().Add(time.Hour))
+		backfiller := newBackfiller(server, newStore(t))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		cp, err := backfiller.Run(ctx)
+		require.Error(t, err)
+		assert.Equal(t, advisories.StatusInterrupted, cp.Status)
+		assert.Equal(t, 1, cp.RequestCount)
+	})
+}
+
+func TestBackfillPlan(t *testing.T) {
+	server := newAdvisoryServer(t)
+	backfiller := newBackfiller(server, newStore(t))
+
+	plan, err := backfiller.Plan(context.Background())
+	require.NoError(t, err)
+	assert.False(t, plan.Resuming)
+	assert.Equal(t, 2, plan.TotalWindows)
+	assert.Equal(t, 2, plan.RemainingWindows)
+	assert.Equal(t, 3, plan.EstimatedRequests)
+	assert.Equal(t, 3000, plan.UsableNow)
+	assert.Equal(t, 1, plan.RateLimitWindows)
+
+	t.Run("quota_exhausted_spans_windows", func(t *testing.T) {
+		server.setRateLimit(1001, time.Now().Add(30*time.Minute))
+
+		plan, err := backfiller.Plan(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, plan.UsableNow)
+		assert.Equal(t, 2, plan.RateLimitWindows)
+		assert.InDelta(t, (30 * time.Minute).Seconds(), plan.EstimatedDuration.Seconds(), 5)
+	})
+}
@@ -0,0 +1,63 @@
+package advisories
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/advisories"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"1.2.3-rc.1", "1.2.3", -1},
+		{"1.2.3-rc.2", "1.2.3-rc.10", -1},
+		{"1.2.3+build", "1.2.3", 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, advisories.CompareVersions(tt.a, tt.b), "%s vs %s", tt.a, tt.b)
+	}
+}
+
+func TestInRange(t *testing.T) {
+	assert.True(t, advisories.InRange("1.1.0", ">= 1.0.0, < 1.2.3"))
+	assert.False(t, advisories.InRange("1.2.3", ">= 1.0.0, < 1.2.3"))
+	assert.True(t, advisories.InRange("0.9", "<= 0.9"))
+	assert.True(t, advisories.InRange("2.0.0", "= 2.0.0"))
+	assert.False(t, advisories.InRange("2.0.0", ""))
+}
+
+func TestAffects(t *testing.T) {
+	var advisory github.Advisory
+	require.NoError(t, json.Unmarshal([]byte(`{"ghsa_id":"GHSA-xxxx-0001","vulnerabilities":[{
+		"package":{"ecosystem":"go","name":"golang.org/x/net"},
+		"vulnerable_version_range":"< 0.17.0",
+		"first_patched_version":"0.17.0"}]}`), &advisory))
+
+	purl, err := sbom.ParsePackageURL("pkg:golang/golang.org/x/net@v0.15.0")
+	require.NoError(t, err)
+	affected, patched := advisories.Affects(advisory, purl)
+	assert.True(t, affected)
+	assert.Equal(t, "0.17.0", patched)
+
+	purl, err = sbom.ParsePackageURL("pkg:golang/golang.org/x/net@v0.17.0")
+	require.NoError(t, err)
+	affected, _ = advisories.Affects(advisory, purl)
+	assert.False(t, affected)
+
+	purl, err = sbom.ParsePackageURL("pkg:npm/net@0.1.0")
+	require.NoError(t, err)
+	affected, _ = advisories.Affects(advisory, purl)
+	assert.False(t, affected)
+}
@@ -0,0 +1,212 @@
+package advisories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/advisories"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// updatedServer fakes the global advisories API sorted by updated_at
+// ascending, honouring the updated filter and paging by per_page
+type updatedServer struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	updated   map[string]time.Time
+	queries   []string
+	onRequest func(r *http.Request)
+}
+
+func newUpdatedServer(t *testing.T) *updatedServer {
+	s := &updatedServer{updated: make(map[string]time.Time)}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		s.mutex.Lock()
+		s.queries = append(s.queries, query.Get("updated")+"|"+query.Get("after"))
+		hook := s.onRequest
+		var ids []string
+		since := time.Time{}
+		if bound := strings.TrimPrefix(query.Get("updated"), ">="); bound != "" {
+			since, _ = time.Parse(time.RFC3339, bound)
+		}
+		for id, updated := range s.updated {
+			if !updated.Before(since) {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return s.updated[ids[i]].Before(s.updated[ids[j]]) })
+		s.mutex.Unlock()
+		if hook != nil {
+			hook(r)
+		}
+
+		assert.Equal(t, "updated", query.Get("sort"))
+		assert.Equal(t, "asc", query.Get("direction"))
+		assert.Equal(t, "go", query.Get("ecosystem"))
+
+		perPage, _ := strconv.Atoi(query.Get("per_page"))
+		offset, _ := strconv.Atoi(query.Get("after"))
+		end := min(len(ids), offset+perPage)
+		if end < len(ids) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/advisories?after=%d>; rel="next"`, s.URL, end))
+		}
+
+		page := make([]map[string]interface{}, 0, end-offset)
+		s.mutex.Lock()
+		for _, id := range ids[offset:end] {
+			page = append(page, map[string]interface{}{
+				"ghsa_id":      id,
+				"severity":     "high",
+				"summary":      "test advisory " + id,
+				"published_at": "2024-01-15T10:00:00Z",
+				"updated_at":   s.updated[id].Format(time.RFC3339),
+			})
+		}
+		s.mutex.Unlock()
+		json.NewEncoder(w).Encode(page)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *updatedServer) update(id string, at time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.updated[id] = at
+}
+
+func (s *updatedServer) setHook(hook func(r *http.Request)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onRequest = hook
+}
+
+func (s *updatedServer) queryLog() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+func newSyncer(server *updatedServer, store *advisories.Store) *advisories.Syncer {
+	clientConfig := github.DefaultConfig("test-token")
+	clientConfig.BaseURL = server.URL
+	clientConfig.RateLimitThreshold = 0
+
+	config := advisories.DefaultSyncConfig("go")
+	config.PerPage = 2
+	return advisories.NewSyncer(github.NewClient(clientConfig), store, config)
+}
+
+func TestIncrementalSync(t *testing.T) {
+	server := newUpdatedServer(t)
+	store := newStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	server.update("GHSA-aaaa-0001", base)
+	server.update("GHSA-aaaa-0002", base.Add(time.Hour))
+	server.update("GHSA-aaaa-0003", base.Add(2*time.Hour))
+
+	syncer := newSyncer(server, store)
+	assert.Equal(t, "incremental:go", syncer.JobID())
+
+	cp, err := syncer.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, advisories.StatusCompleted, cp.Status)
+	assert.Equal(t, 3, cp.FetchedCount)
+	assert.Equal(t, 2, cp.RequestCount)
+	assert.Equal(t, []string{"|", "|2"}, server.queryLog())
+
+	mark, err := store.HighWaterMark(ctx, "go")
+	require.NoError(t, err)
+	assert.Equal(t, base.Add(2*time.Hour), mark.UTC())
+
+	count, err := store.CountAdvisories(ctx, "go")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	t.Run("fetches_only_updates_since_the_mark", func(t *testing.T) {
+		server.update("GHSA-aaaa-0001", base.Add(3*time.Hour))
+		server.update("GHSA-bbbb-0001", base.Add(4*time.Hour))
+
+		cp, err := newSyncer(server, store).Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, advisories.StatusCompleted, cp.Status)
+		// The advisory at the mark is fetched again, as the bound is inclusive
+		assert.Equal(t, 3, cp.FetchedCount)
+		assert.Equal(t, []string{">=2024-03-01T12:00:00Z|", ">=2024-03-01T12:00:00Z|2"}, server.queryLog()[2:])
+
+		mark, err := store.HighWaterMark(ctx, "go")
+		require.NoError(t, err)
+		assert.Equal(t, base.Add(4*time.Hour), mark.UTC())
+
+		count, err := store.CountAdvisories(ctx, "go")
+		require.NoError(t, err)
+		assert.Equal(t, 4, count)
+	})
+
+	t.Run("nothing_new", func(t *testing.T) {
+		cp, err := newSyncer(server, store).Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, cp.FetchedCount)
+		assert.Equal(t, base.Add(4*time.Hour), cp.RangeEnd.UTC())
+	})
+}
+
+func TestIncrementalSyncResumesAfterInterrupt(t *testing.T) {
+	server := newUpdatedServer(t)
+	store := newStore(t)
+
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i := 1; i <= 3; i++ {
+		server.update(fmt.Sprintf("GHSA-aaaa-000%d", i), base.Add(time.Duration(i)*time.Hour))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server.setHook(func(r *http.Request) {
+		if r.URL.Query().Get("after") == "2" {
+			cancel()
+			<-r.Context().Done()
+		}
+	})
+
+	cp, err := newSyncer(server, store).Run(ctx)
+	require.Error(t, err)
+	assert.Equal(t, advisories.StatusInterrupted, cp.Status)
+
+	saved, err := store.LoadCheckpoint(context.Background(), "incremental:go")
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, "2", saved.Cursor)
+	// The first page advanced the mark, but the query keeps its lower bound
+	assert.Equal(t, base.Add(2*time.Hour), saved.RangeEnd.UTC())
+	assert.True(t, saved.WindowStart.IsZero())
+
+	server.setHook(nil)
+	cp, err = newSyncer(server, store).Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, advisories.StatusCompleted, cp.Status)
+	assert.Equal(t, base.Add(3*time.Hour), cp.RangeEnd.UTC())
+	assert.Equal(t, []string{"|", "|2", "|2"}, server.queryLog())
+
+	count, err := store.CountAdvisories(context.Background(), "go")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
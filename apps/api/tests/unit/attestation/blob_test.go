@@ -0,0 +1,89 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+func writeBlob(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestSignBlobs(t *testing.T) {
+	tarball := writeBlob(t, "keystone_linux_amd64.tar.gz", "release tarball")
+	binary := writeBlob(t, "keystone", "binary")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer := &attestation.CryptoSigner{Signer: key, ID: "release-key"}
+
+	envelope, subjects, err := attestation.SignBlobs(context.Background(), signer,
+		attestation.NewProvenanceBuilder(), testBuildContext(), tarball, binary)
+	require.NoError(t, err)
+	require.Len(t, subjects, 2)
+	assert.Equal(t, "keystone_linux_amd64.tar.gz", subjects[0].Name)
+	// sha256("binary")
+	assert.Equal(t, "9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd", subjects[1].Digest["sha256"])
+	require.NoError(t, attestation.VerifyEnvelopeKey(envelope, &key.PublicKey))
+
+	statement, err := envelope.Statement()
+	require.NoError(t, err)
+	assert.Equal(t, subjects, statement.Subject)
+
+	_, _, err = attestation.SignBlobs(context.Background(), signer, attestation.NewProvenanceBuilder(), testBuildContext(),
+		filepath.Join(t.TempDir(), "missing"))
+	assert.Equal(t, attestation.CodeTargetNotResolved, attestation.CodeOf(err))
+}
+
+func TestVerifyBlob(t *testing.T) {
+	path := writeBlob(t, "keystone_linux_amd64.tar.gz", "release tarball")
+	blob, err := attestation.DigestFile(path)
+	require.NoError(t, err)
+
+	fixture := newBundleFixtureFor(t, blob)
+	bundlePath := attestation.BlobBundlePath(path)
+	require.NoError(t, fixture.bundle(t).WriteFile(bundlePath))
+	bundle, err := attestation.ReadBundle(bundlePath)
+	require.NoError(t, err)
+	policy := attestation.IdentityPolicy{Issuer: testIssuer, Repository: "owner/repo"}
+
+	result, err := attestation.VerifyBlob(bundle, blob, fixture.trust, policy)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, "keystone_linux_amd64.tar.gz", result.Subject)
+
+	// A renamed download still verifies by digest
+	renamed, err := attestation.DigestFile(writeBlob(t, "keystone.tgz", "release tarball"))
+	require.NoError(t, err)
+	_, err = attestation.VerifyBlob(bundle, renamed, fixture.trust, policy)
+	require.NoError(t, err)
+
+	tampered, err := attestation.DigestFile(writeBlob(t, "keystone_linux_amd64.tar.gz", "backdoored tarball"))
+	require.NoError(t, err)
+	result, err = attestation.VerifyBlob(bundle, tampered, fixture.trust, policy)
+	assert.Equal(t, attestation.CodeBlobDigestMismatch, attestation.CodeOf(err))
+	assert.False(t, result.Valid)
+	require.NotEmpty(t, result.Remediation)
+	assert.Contains(t, result.Remediation[0].Command, "sha256sum keystone_linux_amd64.tar.gz")
+
+	// The identity policy applies as for images
+	_, err = attestation.VerifyBlob(bundle, blob, fixture.trust, attestation.IdentityPolicy{Repository: "owner/fork"})
+	assert.Equal(t, attestation.CodeRepositoryMismatch, attestation.CodeOf(err))
+
+	// So does the configured trust root, whatever root the bundle carries
+	other := newBundleFixtureFor(t, blob)
+	_, err = attestation.VerifyBlob(bundle, blob, other.trust, policy)
+	assert.Equal(t, attestation.CodeCertificateUntrusted, attestation.CodeOf(err))
+}
@@ -0,0 +1,166 @@
+package attestation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+const generatorWorkflow = "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml@refs/tags/v1.10.0"
+
+// upgradedProvenance builds Keystone's provenance for the test build, with the
+// builder ID and run details adjusted by edit
+func upgradedProvenance(t *testing.T, edit func(*attestation.ProvenanceV1)) *attestation.UpgradedStatement {
+	statement, err := attestation.NewProvenanceBuilder().Build([]attestation.Subject{testSubject(t)}, testBuildContext())
+	require.NoError(t, err)
+	upgraded, err := attestation.UpgradeStatement(statement)
+	require.NoError(t, err)
+
+	predicate := upgraded.Predicate.(attestation.ProvenanceV1)
+	if edit != nil {
+		edit(&predicate)
+	}
+	upgraded.Predicate = predicate
+	return upgraded
+}
+
+// unmet lists the requirements an assessment did not meet
+func unmet(assessment *attestation.BuildLevelAssessment) []string {
+	var names []string
+	for _, requirement := range assessment.Requirements {
+		if !requirement.Met {
+			names = append(names, requirement.Requirement)
+		}
+	}
+	return names
+}
+
+func TestClassifyBuildLevel(t *testing.T) {
+	github := &attestation.CertificateIdentity{Issuer: testIssuer, SAN: testWorkflow}
+
+	t.Run("workflow signed on a hosted runner is L2", func(t *testing.T) {
+		assessment := attestation.ClassifyBuildLevel(upgradedProvenance(t, nil), github, nil)
+
+		assert.Equal(t, attestation.BuildLevel2, assessment.Level)
+		assert.Equal(t, "L2", assessment.String())
+		assert.Equal(t, attestation.BuildTypeGitHubWorkflowV1, assessment.BuildType)
+		assert.Equal(t, []string{"isolated_builder", "unforgeable_provenance"}, unmet(assessment))
+	})
+
+	t.Run("trusted isolated builder signing its own provenance is L3", func(t *testing.T) {
+		statement := upgradedProvenance(t, func(p *attestation.ProvenanceV1) {
+			p.RunDetails.Builder.ID = generatorWorkflow
+		})
+		generator := &attestation.CertificateIdentity{Issuer: testIssuer, SAN: generatorWorkflow}
+
+		assessment := attestation.ClassifyBuildLevel(statement, generator, nil)
+		assert.Equal(t, attestation.BuildLevel3, assessment.Level)
+		assert.Empty(t, unmet(assessment))
+	})
+
+	t.Run("trusted builder ID claimed by another signer is not L3", func(t *testing.T) {
+		statement := upgradedProvenance(t, func(p *attestation.ProvenanceV1) {
+			p.RunDetails.Builder.ID = generatorWorkflow
+		})
+
+		assessment := attestation.ClassifyBuildLevel(statement, github, nil)
+		assert.Equal(t, attestation.BuildLevel2, assessment.Level)
+		assert.Equal(t, []string{"unforgeable_provenance"}, unmet(assessment))
+	})
+
+	t.Run("unpinned dependencies are not L3", func(t *testing.T) {
+		statement := upgradedProvenance(t, func(p *attestation.ProvenanceV1) {
+			p.RunDetails.Builder.ID = generatorWorkflow
+			p.BuildDefinition.ResolvedDependencies = append(p.BuildDefinition.ResolvedDependencies,
+				attestation.ResourceDescriptor{URI: "https://example.com/tool.tar.gz"})
+		})
+		generator := &attestation.CertificateIdentity{Issuer: testIssuer, SAN: generatorWorkflow}
+
+		assessment := attestation.ClassifyBuildLevel(statement, generator, nil)
+		assert.Equal(t, attestation.BuildLevel2, assessment.Level)
+		assert.Equal(t, []string{"complete_parameters"}, unmet(assessment))
+	})
+
+	t.Run("self-hosted runner is L1", func(t *testing.T) {
+		statement := upgradedProvenance(t, func(p *attestation.ProvenanceV1) {
+			p.BuildDefinition.InternalParameters["oidc_claims"] = map[string]string{
+				"keystone.oidc.runner_environment": "self-hosted",
+			}
+		})
+
+		assessment := attestation.ClassifyBuildLevel(statement, github, nil)
+		assert.Equal(t, attestation.BuildLevel1, assessment.Level)
+		assert.Contains(t, unmet(assessment), "hosted_builder")
+	})
+
+	t.Run("developer signature is L1", func(t *testing.T) {
+		developer := &attestation.CertificateIdentity{Issuer: "https://github.com/login/oauth", SAN: "dev@example.com"}
+
+		assessment := attestation.ClassifyBuildLevel(upgradedProvenance(t, nil), developer, nil)
+		assert.Equal(t, attestation.BuildLevel1, assessment.Level)
+	})
+
+	t.Run("policy builders replace the defaults", func(t *testing.T) {
+		builders := []attestation.TrustedBuilder{{
+			ID:     "https://github.com/owner/repo/.github/workflows/*",
+			Level:  attestation.BuildLevel3,
+			Signer: "https://github.com/owner/repo/.github/workflows/release.yml@*",
+		}}
+
+		assessment := attestation.ClassifyBuildLevel(upgradedProvenance(t, nil), github, builders)
+		assert.Equal(t, attestation.BuildLevel3, assessment.Level)
+	})
+
+	t.Run("missing builder ID is L0", func(t *testing.T) {
+		statement := upgradedProvenance(t, func(p *attestation.ProvenanceV1) {
+			p.RunDetails.Builder.ID = ""
+		})
+
+		assessment := attestation.ClassifyBuildLevel(statement, github, nil)
+		assert.Equal(t, attestation.BuildLevel0, assessment.Level)
+	})
+
+	t.Run("other predicates are L0", func(t *testing.T) {
+		upgraded, err := attestation.UpgradeStatement(&attestation.Statement{
+			Type:          attestation.StatementTypeV1,
+			Subject:       []attestation.Subject{testSubject(t)},
+			PredicateType: attestation.PredicateCycloneDX,
+			Predicate:     map[string]interface{}{},
+		})
+		require.NoError(t, err)
+
+		assessment := attestation.ClassifyBuildLevel(upgraded, github, nil)
+		assert.Equal(t, attestation.BuildLevel0, assessment.Level)
+		assert.Equal(t, []string{"provenance"}, unmet(assessment))
+	})
+}
+
+func TestVerifyBundleEnforcesMinimumBuildLevel(t *testing.T) {
+	fixture := newBundleFixture(t)
+
+	result, err := attestation.VerifyBundle(fixture.bundle(t), fixture.trust, attestation.IdentityPolicy{})
+	require.NoError(t, err)
+	require.NotNil(t, result.BuildLevel)
+	assert.Equal(t, attestation.BuildLevel2, result.BuildLevel.Level)
+	assert.NotContains(t, checkStatuses(result), attestation.CheckBuildLevel)
+
+	result, err = attestation.VerifyBundle(fixture.bundle(t), fixture.trust, attestation.IdentityPolicy{MinBuildLevel: 2})
+	require.NoError(t, err)
+	assert.Equal(t, attestation.CheckPassed, checkStatuses(result)[attestation.CheckBuildLevel])
+
+	result, err = attestation.VerifyBundle(fixture.bundle(t), fixture.trust, attestation.IdentityPolicy{MinBuildLevel: 3})
+	require.Error(t, err)
+	assert.Equal(t, attestation.CodeBuildLevelTooLow, attestation.CodeOf(err))
+	assert.Contains(t, err.Error(), "isolated_builder")
+	assert.Equal(t, attestation.CheckFailed, checkStatuses(result)[attestation.CheckBuildLevel])
+	assert.Contains(t, result.Policy, attestation.PolicyResult{
+		Rule: "build_level", Expected: "L3", Actual: "L2", ErrorCode: attestation.CodeBuildLevelTooLow,
+	})
+	assert.NotEmpty(t, result.Remediation)
+
+	report := attestation.NewReport(fixture.bundle(t), result)
+	assert.Contains(t, report.Markdown(), "### SLSA build level: L2")
+}
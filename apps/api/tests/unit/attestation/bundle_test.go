@@ -0,0 +1,327 @@
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// bundleFixture is a signed envelope with a Fulcio-style chain and Rekor entry
+type bundleFixture struct {
+	root      *x509.Certificate
+	leaf      *x509.Certificate
+	leafKey   *ecdsa.PrivateKey
+	envelope  *attestation.Envelope
+	entry     *attestation.TlogEntry
+	trust     attestation.TrustRoot
+	issuedAt  time.Time
+	signedSET func(entry *attestation.TlogEntry) string
+}
+
+func pemCertificate(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+func newBundleFixture(t *testing.T) *bundleFixture {
+	return newBundleFixtureFor(t, testSubject(t))
+}
+
+// newBundleFixtureFor signs provenance naming the given subjects
+func newBundleFixtureFor(t *testing.T, subjects ...attestation.Subject) *bundleFixture {
+	// Fulcio certificates are short lived; issue one that has already expired
+	issuedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-fulcio-root"},
+		NotBefore:             issuedAt.Add(-24 * time.Hour),
+		NotAfter:              issuedAt.Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	root, err := x509.ParseCertificate(rootDER)
+	require.NoError(t, err)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	san, err := url.Parse(testWorkflow)
+	require.NoError(t, err)
+	oid := func(n int) asn1.ObjectIdentifier { return asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, n} }
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    issuedAt,
+		NotAfter:     issuedAt.Add(10 * time.Minute),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:         []*url.URL{san},
+		ExtraExtensions: []pkix.Extension{
+			derExtension(t, oid(8), testIssuer),
+			derExtension(t, oid(12), "https://github.com/owner/repo"),
+			derExtension(t, oid(14), "refs/heads/main"),
+		},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, root, &leafKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(leafDER)
+	require.NoError(t, err)
+
+	statement, err := attestation.NewProvenanceBuilder().Build(subjects, testBuildContext())
+	require.NoError(t, err)
+	envelope, err := attestation.NewEnvelope(statement)
+	require.NoError(t, err)
+	payload, err := envelope.DecodePayload()
+	require.NoError(t, err)
+	digest := sha256.Sum256(attestation.PAE(envelope.PayloadType, payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, leafKey, digest[:])
+	require.NoError(t, err)
+	envelope.Signatures = []attestation.EnvelopeSignature{{Sig: base64.StdEncoding.EncodeToString(sig)}}
+
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rekorDER, err := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+	require.NoError(t, err)
+	logID := sha256.Sum256(rekorDER)
+
+	payloadHash := sha256.Sum256(payload)
+	body := dsseEntryBody(payloadHash[:], sig, leaf)
+
+	signedSET := func(entry *attestation.TlogEntry) string {
+		canonical, err := json.Marshal(map[string]interface{}{
+			"body":           entry.Body,
+			"integratedTime": entry.IntegratedTime,
+			"logID":          entry.LogID,
+			"logIndex":       entry.LogIndex,
+		})
+		require.NoError(t, err)
+		setDigest := sha256.Sum256(canonical)
+		set, err := ecdsa.SignASN1(rand.Reader, rekorKey, setDigest[:])
+		require.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(set)
+	}
+
+	entry := &attestation.TlogEntry{
+		LogIndex:       42,
+		LogID:          hex.EncodeToString(logID[:]),
+		IntegratedTime: issuedAt.Add(time.Minute).Unix(),
+		Body:           base64.StdEncoding.EncodeToString([]byte(body)),
+	}
+	entry.SignedEntryTimestamp = signedSET(entry)
+
+	return &bundleFixture{
+		root:     root,
+		leaf:     leaf,
+		leafKey:  leafKey,
+		envelope: envelope,
+		entry:    entry,
+		trust: attestation.TrustRoot{
+			FulcioCertificates: []string{pemCertificate(root)},
+			RekorPublicKeys:    []string{string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rekorDER}))},
+		},
+		issuedAt:  issuedAt,
+		signedSET: signedSET,
+	}
+}
+
+// dsseEntryBody returns a Rekor dsse entry recording a payload hash and one
+// signature with the certificate that made it
+func dsseEntryBody(payloadHash, sig []byte, cert *x509.Certificate) string {
+	return fmt.Sprintf(`{"apiVersion":"0.0.1","kind":"dsse","spec":{"payloadHash":{"algorithm":"sha256","value":"%s"},`+
+		`"signatures":[{"signature":"%s","verifier":"%s"}]}}`,
+		hex.EncodeToString(payloadHash), base64.StdEncoding.EncodeToString(sig),
+		base64.StdEncoding.EncodeToString([]byte(pemCertificate(cert))))
+}
+
+func (f *bundleFixture) bundle(t *testing.T) *attestation.Bundle {
+	entry := *f.entry
+	bundle, err := attestation.NewBundle(f.envelope, []*x509.Certificate{f.leaf}, &entry, f.trust)
+	require.NoError(t, err)
+	return bundle
+}
+
+func TestBundleRoundTripVerifiesOffline(t *testing.T) {
+	fixture := newBundleFixture(t)
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	require.NoError(t, fixture.bundle(t).WriteFile(path))
+
+	bundle, err := attestation.ReadBundle(path)
+	require.NoError(t, err)
+
+	result, err := attestation.VerifyBundle(bundle, fixture.trust, attestation.IdentityPolicy{
+		Issuer:     testIssuer,
+		Repository: "owner/repo",
+		Branch:     "main",
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.True(t, result.RekorVerified)
+	assert.Equal(t, testWorkflow, result.Identity)
+	assert.Equal(t, testIssuer, result.Issuer)
+	assert.Equal(t, "ghcr.io/owner/repo", result.Subject)
+}
+
+func TestVerifyBundleFailures(t *testing.T) {
+	fixture := newBundleFixture(t)
+
+	tests := []struct {
+		name   string
+		mutate func(b *attestation.Bundle)
+		trust  func(trust *attestation.TrustRoot)
+		policy attestation.IdentityPolicy
+		code   string
+	}{
+		{
+			name: "untrusted_root",
+			trust: func(trust *attestation.TrustRoot) {
+				other := newBundleFixture(t)
+				trust.FulcioCertificates = []string{pemCertificate(other.root)}
+			},
+			code: attestation.CodeCertificateUntrusted,
+		},
+		{
+			name:   "integrated_after_certificate_expiry",
+			mutate: func(b *attestation.Bundle) { b.TlogEntry.IntegratedTime = fixture.issuedAt.Add(time.Hour).Unix() },
+			code:   attestation.CodeCertificateUntrusted,
+		},
+		{
+			name:   "tampered_log_index",
+			mutate: func(b *attestation.Bundle) { b.TlogEntry.LogIndex++ },
+			code:   attestation.CodeRekorSETInvalid,
+		},
+		{
+			name: "entry_for_another_envelope",
+			mutate: func(b *attestation.Bundle) {
+				b.TlogEntry.Body = base64.StdEncoding.EncodeToString([]byte(`{"kind":"dsse","spec":{}}`))
+				b.TlogEntry.SignedEntryTimestamp = fixture.signedSET(b.TlogEntry)
+			},
+			code: attestation.CodeRekorEntryNotFound,
+		},
+		{
+			name:   "identity_policy",
+			policy: attestation.IdentityPolicy{Repository: "owner/fork"},
+			code:   attestation.CodeRepositoryMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle := fixture.bundle(t)
+			if tt.mutate != nil {
+				tt.mutate(bundle)
+			}
+			trust := fixture.trust
+			if tt.trust != nil {
+				tt.trust(&trust)
+			}
+
+			result, err := attestation.VerifyBundle(bundle, trust, tt.policy)
+			require.Error(t, err)
+			assert.Equal(t, tt.code, attestation.CodeOf(err))
+			assert.False(t, result.Valid)
+			assert.Equal(t, tt.code, result.ErrorCode)
+		})
+	}
+}
+
+func TestVerifyBundleBindsTlogEntryToSignature(t *testing.T) {
+	fixture := newBundleFixture(t)
+	other := newBundleFixture(t)
+
+	payload, err := fixture.envelope.DecodePayload()
+	require.NoError(t, err)
+	payloadHash := sha256.Sum256(payload)
+	sig, err := base64.StdEncoding.DecodeString(fixture.envelope.Signatures[0].Sig)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: fixture.leaf.RawSubjectPublicKeyInfo})
+
+	// The payload is public, so another signer can log it under their own key
+	digest := sha256.Sum256(attestation.PAE(fixture.envelope.PayloadType, payload))
+	replayedSig, err := ecdsa.SignASN1(rand.Reader, other.leafKey, digest[:])
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		body  string
+		valid bool
+	}{
+		{name: "dsse_with_certificate", body: dsseEntryBody(payloadHash[:], sig, fixture.leaf), valid: true},
+		{
+			name: "intoto_with_public_key",
+			body: fmt.Sprintf(`{"apiVersion":"0.0.2","kind":"intoto","spec":{"content":{"envelope":{"payloadType":"%s",`+
+				`"signatures":[{"sig":"%s","publicKey":"%s"}]},"payloadHash":{"algorithm":"sha256","value":"%s"}}}}`,
+				fixture.envelope.PayloadType, base64.StdEncoding.EncodeToString([]byte(fixture.envelope.Signatures[0].Sig)),
+				base64.StdEncoding.EncodeToString(keyPEM), hex.EncodeToString(payloadHash[:])),
+			valid: true,
+		},
+		{name: "replayed_from_another_signer", body: dsseEntryBody(payloadHash[:], replayedSig, other.leaf)},
+		{name: "another_signature_with_this_certificate", body: dsseEntryBody(payloadHash[:], replayedSig, fixture.leaf)},
+		{name: "this_signature_with_another_certificate", body: dsseEntryBody(payloadHash[:], sig, other.leaf)},
+		{
+			name: "hashedrekord",
+			body: fmt.Sprintf(`{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":"%s"}},`+
+				`"signature":{"content":"%s","publicKey":{"content":"%s"}}}}`,
+				hex.EncodeToString(payloadHash[:]), base64.StdEncoding.EncodeToString(sig),
+				base64.StdEncoding.EncodeToString([]byte(pemCertificate(fixture.leaf)))),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle := fixture.bundle(t)
+			bundle.TlogEntry.Body = base64.StdEncoding.EncodeToString([]byte(tt.body))
+			bundle.TlogEntry.SignedEntryTimestamp = fixture.signedSET(bundle.TlogEntry)
+
+			result, err := attestation.VerifyBundle(bundle, fixture.trust, attestation.IdentityPolicy{
+				Issuer:     testIssuer,
+				Repository: "owner/repo",
+			})
+			if tt.valid {
+				require.NoError(t, err)
+				assert.True(t, result.RekorVerified)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, attestation.CodeRekorEntryNotFound, attestation.CodeOf(err))
+			assert.False(t, result.RekorVerified)
+		})
+	}
+}
+
+func TestVerifyBundleIgnoresEmbeddedTrustRoot(t *testing.T) {
+	trusted := newBundleFixture(t)
+
+	// An attacker signs with their own CA and Rekor key and ships them as the
+	// bundle's trust root
+	attacker := newBundleFixture(t)
+	forged := attacker.bundle(t)
+	_, err := attestation.VerifyBundle(forged, attacker.trust, attestation.IdentityPolicy{Issuer: testIssuer})
+	require.NoError(t, err, "the forgery is self-consistent")
+
+	result, err := attestation.VerifyBundle(forged, trusted.trust, attestation.IdentityPolicy{Issuer: testIssuer})
+	require.Error(t, err)
+	assert.Equal(t, attestation.CodeCertificateUntrusted, attestation.CodeOf(err))
+	assert.False(t, result.Valid)
+}
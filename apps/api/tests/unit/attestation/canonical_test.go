@@ -0,0 +1,62 @@
+package attestation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	t.Run("rfc8785_example", func(t *testing.T) {
+		input := []byte(`{
+			"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+			"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+			"literals": [null, true, false]
+		}`)
+		var value interface{}
+		require.NoError(t, json.Unmarshal(input, &value))
+
+		canonical, err := attestation.CanonicalJSON(value)
+		require.NoError(t, err)
+		assert.Equal(t, `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`, string(canonical))
+		assert.True(t, attestation.IsCanonical(canonical))
+		assert.False(t, attestation.IsCanonical(input))
+	})
+
+	t.Run("keys_sorted_by_utf16", func(t *testing.T) {
+		value := map[string]int{"\u20ac": 1, "\r": 2, "\ufb33": 3, "1": 4, "\U0001F600": 5, "\u0080": 6, "\u00f6": 7}
+		canonical, err := attestation.CanonicalJSON(value)
+		require.NoError(t, err)
+		assert.Equal(t, "{\"\\r\":2,\"1\":4,\"\u0080\":6,\"\u00f6\":7,\"\u20ac\":1,\"\U0001F600\":5,\"\ufb33\":3}", string(canonical))
+	})
+
+	t.Run("duplicate_keys_are_not_canonical", func(t *testing.T) {
+		assert.False(t, attestation.IsCanonical([]byte(`{"a":1,"a":1}`)))
+		assert.False(t, attestation.IsCanonical([]byte(`{"a":1} `)))
+	})
+}
+
+func TestEnvelopePayloadIsCanonical(t *testing.T) {
+	statement, err := attestation.NewProvenanceBuilder().Build([]attestation.Subject{testSubject(t)}, testBuildContext())
+	require.NoError(t, err)
+
+	envelope, err := attestation.NewEnvelope(statement)
+	require.NoError(t, err)
+	require.NoError(t, envelope.VerifyCanonical())
+
+	// Encoding the same statement again yields the same payload
+	again, err := attestation.NewEnvelope(statement)
+	require.NoError(t, err)
+	assert.Equal(t, envelope.Payload, again.Payload)
+
+	pretty := &attestation.Envelope{
+		PayloadType: attestation.PayloadTypeInToto,
+		Payload:     base64.StdEncoding.EncodeToString([]byte(`{"_type": "https://in-toto.io/Statement/v1"}`)),
+	}
+	assert.Equal(t, attestation.CodeNonCanonicalPayload, attestation.CodeOf(pretty.VerifyCanonical()))
+}
@@ -0,0 +1,253 @@
+package attestation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+func testClaims() attestation.Claims {
+	return attestation.Claims{
+		"iss":                "https://token.actions.githubusercontent.com",
+		"aud":                "sigstore",
+		"repository":         "owner/repo",
+		"ref":                "refs/heads/main",
+		"ref_type":           "branch",
+		"environment":        "production",
+		"job_workflow_ref":   "owner/repo/.github/workflows/release.yml@refs/heads/main",
+		"runner_environment": "github-hosted",
+	}
+}
+
+func TestDecodeClaims(t *testing.T) {
+	payload, err := json.Marshal(testClaims())
+	require.NoError(t, err)
+	token := "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+
+	claims, err := attestation.DecodeClaims(token)
+	require.NoError(t, err)
+	assert.Equal(t, "production", claims["environment"])
+
+	_, err = attestation.DecodeClaims("not-a-jwt")
+	assert.Equal(t, attestation.CodeOIDCTokenRequestFailed, attestation.CodeOf(err))
+}
+
+func TestClaimMapperDefaults(t *testing.T) {
+	mapper, err := attestation.NewClaimMapper(attestation.DefaultClaimMappings())
+	require.NoError(t, err)
+
+	metadata := &attestation.SigningMetadata{}
+	values, err := mapper.Apply(metadata, testClaims())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"keystone.oidc.environment":        "production",
+		"keystone.oidc.ref_type":           "branch",
+		"keystone.oidc.job_workflow_ref":   "owner/repo/.github/workflows/release.yml@refs/heads/main",
+		"keystone.oidc.runner_environment": "github-hosted",
+	}, metadata.Annotations)
+	assert.Equal(t, values.Annotations, values.Predicate)
+
+	// Claims absent from the token are omitted rather than rendered as placeholders
+	claims := testClaims()
+	delete(claims, "environment")
+	values, err = mapper.Map(claims)
+	require.NoError(t, err)
+	assert.NotContains(t, values.Annotations, "keystone.oidc.environment")
+}
+
+func TestClaimMapperTemplates(t *testing.T) {
+	mapper, err := attestation.NewClaimMapper([]attestation.ClaimMapping{
+		{Key: "org.deploy-target", Template: "{{.repository}}:{{.environment}}", Predicate: true},
+		{Key: "org.branch", Template: `{{if eq .ref_type "branch"}}{{.ref}}{{end}}`},
+		{Key: "org.team", Template: "{{.team}}", Required: true},
+	})
+	require.NoError(t, err)
+
+	_, err = mapper.Map(testClaims())
+	require.Error(t, err)
+	assert.Equal(t, attestation.CodeMissingSubject, attestation.CodeOf(err))
+	assert.Contains(t, err.Error(), "org.team")
+
+	claims := testClaims()
+	claims["team"] = "platform"
+	values, err := mapper.Map(claims)
+	require.NoError(t, err)
+	assert.Equal(t, "owner/repo:production", values.Annotations["org.deploy-target"])
+	assert.Equal(t, "refs/heads/main", values.Annotations["org.branch"])
+	assert.Equal(t, map[string]string{"org.deploy-target": "owner/repo:production"}, values.Predicate)
+}
+
+func TestClaimMapperInvalidConfig(t *testing.T) {
+	_, err := attestation.NewClaimMapper([]attestation.ClaimMapping{{Key: "a", Template: "{{.unclosed"}})
+	assert.Error(t, err)
+
+	_, err = attestation.NewClaimMapper([]attestation.ClaimMapping{{Key: "a"}, {Key: "a"}})
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "claims.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"key":"org.env","template":"{{.environment}}","predicate":true}]`), 0o600))
+	mappings, err := attestation.LoadClaimMappings(path)
+	require.NoError(t, err)
+	assert.Equal(t, []attestation.ClaimMapping{{Key: "org.env", Template: "{{.environment}}", Predicate: true}}, mappings)
+}
+
+func TestProvenanceWithClaims(t *testing.T) {
+	claims := map[string]string{"keystone.oidc.environment": "production"}
+	subjects := []attestation.Subject{testSubject(t)}
+
+	statement, err := attestation.NewProvenanceBuilder(attestation.WithClaims(claims)).Build(subjects, testBuildContext())
+	require.NoError(t, err)
+	v1 := statement.Predicate.(attestation.ProvenanceV1)
+	assert.Equal(t, claims, v1.BuildDefinition.InternalParameters["oidc_claims"])
+
+	statement, err = attestation.NewProvenanceBuilder(
+		attestation.WithProvenanceVersion(attestation.PredicateSLSAProvenanceV02),
+		attestation.WithClaims(claims),
+	).Build(subjects, testBuildContext())
+	require.NoError(t, err)
+	v02 := statement.Predicate.(attestation.ProvenanceV02)
+	assert.Equal(t, claims, v02.Invocation.Environment["oidc_claims"])
+}
+
+func TestWorkflowAllowlistAuthorize(t *testing.T) {
+	allowlist := &attestation.WorkflowAllowlist{Workflows: []attestation.ApprovedWorkflow{{
+		Workflow: "org/signing/.github/workflows/sign.yml",
+		Refs:     []string{"refs/tags/v2.1.0", "0123456789abcdef0123456789abcdef01234567"},
+		Callers:  []string{"org/*"},
+	}}}
+	require.NoError(t, allowlist.Validate())
+
+	claims := func(jobWorkflowRef, repository string) attestation.Claims {
+		return attestation.Claims{"job_workflow_ref": jobWorkflowRef, "repository": repository}
+	}
+
+	tests := []struct {
+		name   string
+		claims attestation.Claims
+		code   string
+	}{
+		{"pinned_tag", claims("org/signing/.github/workflows/sign.yml@refs/tags/v2.1.0", "org/api"), ""},
+		{"pinned_sha", claims("org/signing/.github/workflows/sign.yml@0123456789abcdef0123456789abcdef01234567", "org/web"), ""},
+		{"unpinned_branch", claims("org/signing/.github/workflows/sign.yml@refs/heads/main", "org/api"), attestation.CodeWorkflowRefNotPinned},
+		{"ad_hoc_workflow", claims("org/api/.github/workflows/release.yml@refs/heads/main", "org/api"), attestation.CodeWorkflowNotApproved},
+		{"foreign_caller", claims("org/signing/.github/workflows/sign.yml@refs/tags/v2.1.0", "fork/api"), attestation.CodePermissionDenied},
+		{"missing_claim", attestation.Claims{"repository": "org/api"}, attestation.CodeWorkflowNotApproved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := allowlist.Authorize(tt.claims)
+			if tt.code == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.code, attestation.CodeOf(err))
+		})
+	}
+}
+
+func TestLoadWorkflowAllowlist(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "allowlist.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{"workflows":[{"workflow":"org/signing/.github/workflows/sign.yml","refs":["refs/tags/v1"]}]}`), 0o600))
+	allowlist, err := attestation.LoadWorkflowAllowlist(valid)
+	require.NoError(t, err)
+	assert.Len(t, allowlist.Workflows, 1)
+
+	unpinned := filepath.Join(dir, "unpinned.json")
+	require.NoError(t, os.WriteFile(unpinned, []byte(`{"workflows":[{"workflow":"org/signing/.github/workflows/sign.yml"}]}`), 0o600))
+	_, err = attestation.LoadWorkflowAllowlist(unpinned)
+	assert.Error(t, err)
+
+	withRef := filepath.Join(dir, "with-ref.json")
+	require.NoError(t, os.WriteFile(withRef, []byte(`{"workflows":[{"workflow":"org/signing/.github/workflows/sign.yml@v1","refs":["v1"]}]}`), 0o600))
+	_, err = attestation.LoadWorkflowAllowlist(withRef)
+	assert.Error(t, err)
+}
+
+func TestSigningConstraintsAuthorize(t *testing.T) {
+	constraints := &attestation.SigningConstraints{
+		Repositories: []string{"org/*"},
+		Branches:     []string{"main", "release/*"},
+		Tags:         []string{"v*"},
+		Environments: []string{"production"},
+		WorkflowRefs: []string{"org/signing/.github/workflows/sign.yml@refs/tags/v*"},
+		EventNames:   []string{"push", "release"},
+	}
+	require.NoError(t, constraints.Validate())
+
+	claims := func(override map[string]interface{}) attestation.Claims {
+		claims := attestation.Claims{
+			"repository":       "Org/API",
+			"ref":              "refs/heads/main",
+			"environment":      "production",
+			"job_workflow_ref": "org/signing/.github/workflows/sign.yml@refs/tags/v2.1.0",
+			"event_name":       "push",
+		}
+		for name, value := range override {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		claims attestation.Claims
+		code   string
+	}{
+		{"allowed_branch", claims(nil), ""},
+		{"allowed_release_branch", claims(map[string]interface{}{"ref": "refs/heads/release/2.1"}), ""},
+		{"allowed_tag", claims(map[string]interface{}{"ref": "refs/tags/v2.1.0", "event_name": "release"}), ""},
+		{"foreign_repository", claims(map[string]interface{}{"repository": "fork/api"}), attestation.CodeRepositoryNotAllowed},
+		{"feature_branch", claims(map[string]interface{}{"ref": "refs/heads/feature/x"}), attestation.CodeRefNotAllowed},
+		{"branch_named_like_tag", claims(map[string]interface{}{"ref": "refs/heads/v2"}), attestation.CodeRefNotAllowed},
+		{"pull_request_ref", claims(map[string]interface{}{"ref": "refs/pull/7/merge"}), attestation.CodeRefNotAllowed},
+		{"staging_environment", claims(map[string]interface{}{"environment": "staging"}), attestation.CodeEnvironmentNotAllowed},
+		{"no_environment", claims(map[string]interface{}{"environment": nil}), attestation.CodeEnvironmentNotAllowed},
+		{"unpinned_workflow", claims(map[string]interface{}{"job_workflow_ref": "org/signing/.github/workflows/sign.yml@refs/heads/main"}), attestation.CodeWorkflowNotApproved},
+		{"pull_request_event", claims(map[string]interface{}{"event_name": "pull_request_target"}), attestation.CodeEventNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := constraints.Authorize(tt.claims)
+			if tt.code == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.code, attestation.CodeOf(err))
+		})
+	}
+
+	// Empty constraints allow any pipeline
+	assert.NoError(t, (&attestation.SigningConstraints{}).Authorize(attestation.Claims{}))
+}
+
+func TestLoadSigningConstraints(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "constraints.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{"repositories":["org/*"],"branches":["main"],"event_names":["push"]}`), 0o600))
+	constraints, err := attestation.LoadSigningConstraints(valid)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main"}, constraints.Branches)
+
+	badPattern := filepath.Join(dir, "bad-pattern.json")
+	require.NoError(t, os.WriteFile(badPattern, []byte(`{"branches":["[main"]}`), 0o600))
+	_, err = attestation.LoadSigningConstraints(badPattern)
+	assert.Error(t, err)
+
+	noRef := filepath.Join(dir, "no-ref.json")
+	require.NoError(t, os.WriteFile(noRef, []byte(`{"workflow_refs":["org/signing/.github/workflows/sign.yml"]}`), 0o600))
+	_, err = attestation.LoadSigningConstraints(noRef)
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/compliance"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/history"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+// fixtureTrustedRoot encodes the fixture's trust root as trusted_root.json
+func fixtureTrustedRoot(t *testing.T, fixture *bundleFixture) []byte {
+	block, _ := pem.Decode([]byte(fixture.trust.RekorPublicKeys[0]))
+	require.NotNil(t, block)
+	data, err := json.Marshal(map[string]interface{}{
+		"mediaType": "application/vnd.dev.sigstore.trustedroot+json;version=0.1",
+		"tlogs":     []interface{}{map[string]interface{}{"publicKey": map[string][]byte{"rawBytes": block.Bytes}}},
+		"certificateAuthorities": []interface{}{map[string]interface{}{"certChain": map[string]interface{}{
+			"certificates": []map[string][]byte{{"rawBytes": fixture.root.Raw}},
+		}}},
+	})
+	require.NoError(t, err)
+	return data
+}
+
+func TestComplianceAtPointInTime(t *testing.T) {
+	ctx := context.Background()
+	fixture := newBundleFixture(t)
+	store := history.NewStore(migratedDB(t))
+	retained := fixture.issuedAt.Add(-24 * time.Hour)
+
+	_, err := store.Record(ctx, history.KindTrustRoot, "pinned", fixtureTrustedRoot(t, fixture), retained)
+	require.NoError(t, err)
+	require.NoError(t, compliance.RetainPolicy(ctx, store, compliance.UploadPolicyKey,
+		attestation.IdentityPolicy{Issuer: testIssuer, Repository: "owner/repo"}, retained))
+
+	// The advisory was published after the attestation was made
+	advisory, err := json.Marshal(map[string]interface{}{
+		"ghsa_id":  "GHSA-xxxx-0001",
+		"severity": "critical",
+		"summary":  "Request smuggling",
+		"vulnerabilities": []interface{}{map[string]interface{}{
+			"package":                  map[string]interface{}{"ecosystem": "go", "name": "golang.org/x/net"},
+			"vulnerable_version_range": "< 0.17.0",
+			"first_patched_version":    "0.17.0",
+		}},
+	})
+	require.NoError(t, err)
+	published := fixture.issuedAt.Add(30 * time.Minute)
+	_, err = store.Record(ctx, history.KindAdvisory, "GHSA-xxxx-0001", advisory, published)
+	require.NoError(t, err)
+
+	doc := &sbom.Document{Components: []sbom.Component{{Name: "net", PURL: "pkg:golang/golang.org/x/net@v0.15.0"}}}
+	query := compliance.Query{Bundle: fixture.bundle(t), SBOM: doc, TrustKey: "pinned"}
+	evaluator := compliance.NewEvaluator(store)
+
+	query.At = fixture.issuedAt.Add(10 * time.Minute)
+	report, err := evaluator.Evaluate(ctx, query)
+	require.NoError(t, err)
+	assert.True(t, report.Compliant)
+	assert.Equal(t, attestation.CheckPassed, checkStatuses(report.Verification)[attestation.CheckSignedBefore])
+	assert.Zero(t, report.Advisories)
+	assert.Equal(t, "pinned", report.TrustRoot.Key)
+	assert.True(t, retained.Equal(report.Policy.RecordedAt))
+
+	query.At = published.Add(time.Minute)
+	report, err = evaluator.Evaluate(ctx, query)
+	require.NoError(t, err)
+	assert.False(t, report.Compliant)
+	assert.True(t, report.Verification.Valid)
+	require.Len(t, report.Vulnerabilities, 1)
+	assert.Equal(t, findings.SeverityCritical, report.Vulnerabilities[0].Severity)
+	assert.Equal(t, "0.17.0", report.Vulnerabilities[0].Metadata["fixed_version"])
+}
+
+func TestComplianceRejectsLaterSignatures(t *testing.T) {
+	ctx := context.Background()
+	fixture := newBundleFixture(t)
+	store := history.NewStore(migratedDB(t))
+	retained := fixture.issuedAt.Add(-24 * time.Hour)
+
+	_, err := store.Record(ctx, history.KindTrustRoot, "pinned", fixtureTrustedRoot(t, fixture), retained)
+	require.NoError(t, err)
+	require.NoError(t, compliance.RetainPolicy(ctx, store, compliance.UploadPolicyKey, attestation.IdentityPolicy{}, retained))
+
+	evaluator := compliance.NewEvaluator(store)
+	report, err := evaluator.Evaluate(ctx, compliance.Query{At: fixture.issuedAt.Add(-time.Hour), Bundle: fixture.bundle(t), TrustKey: "pinned"})
+	require.NoError(t, err)
+	assert.False(t, report.Compliant)
+	assert.Equal(t, attestation.CodeAttestationNotFound, report.Verification.ErrorCode)
+	assert.Equal(t, attestation.CheckFailed, checkStatuses(report.Verification)[attestation.CheckSignedBefore])
+
+	// Nothing was retained that far back
+	_, err = evaluator.Evaluate(ctx, compliance.Query{At: retained.Add(-time.Hour), Bundle: fixture.bundle(t), TrustKey: "pinned"})
+	assert.ErrorContains(t, err, "no trust root")
+}
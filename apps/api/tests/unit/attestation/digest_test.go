@@ -0,0 +1,152 @@
+package attestation
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+func TestDigestPathComputesEachAlgorithm(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keystone_linux_amd64.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o644))
+
+	subject, err := attestation.DigestPath(path, attestation.DigestSHA256, attestation.DigestSHA512)
+	require.NoError(t, err)
+	assert.Equal(t, "keystone_linux_amd64.tar.gz", subject.Name)
+	assert.Equal(t, attestation.DigestSet{
+		"sha256": "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		"sha512": "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043",
+	}, subject.Digest)
+
+	_, err = attestation.DigestPath(path, "md5")
+	assert.Equal(t, attestation.CodeTargetNotResolved, attestation.CodeOf(err))
+}
+
+// writeTree creates files under dir, in the order given
+func writeTree(t *testing.T, dir string, files map[string]string, order []string) {
+	for _, name := range order {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(files[name]), 0o644))
+	}
+}
+
+func TestDigestDirectoryIsContentAddressed(t *testing.T) {
+	files := map[string]string{"bin/app": "binary", "README.md": "docs", "lib/a/b.txt": "b"}
+	first := filepath.Join(t.TempDir(), "dist")
+	second := filepath.Join(t.TempDir(), "dist")
+	writeTree(t, first, files, []string{"bin/app", "README.md", "lib/a/b.txt"})
+	writeTree(t, second, files, []string{"lib/a/b.txt", "README.md", "bin/app"})
+
+	a, err := attestation.DigestPath(first, attestation.DigestSHA256, attestation.DigestSHA512)
+	require.NoError(t, err)
+	b, err := attestation.DigestDirectory(second, attestation.DigestSHA256, attestation.DigestSHA512)
+	require.NoError(t, err)
+	assert.Equal(t, "dist", a.Name)
+	assert.Equal(t, a.Digest, b.Digest)
+	assert.Len(t, a.Digest["sha512"], 128)
+
+	// Content, executable bits, renames and symlink targets all change the digest
+	digest := func() string {
+		subject, err := attestation.DigestDirectory(second)
+		require.NoError(t, err)
+		return subject.Digest["sha256"]
+	}
+	seen := map[string]bool{a.Digest["sha256"]: true}
+	for _, change := range []func(){
+		func() { require.NoError(t, os.WriteFile(filepath.Join(second, "README.md"), []byte("Docs"), 0o644)) },
+		func() { require.NoError(t, os.Chmod(filepath.Join(second, "bin/app"), 0o755)) },
+		func() { require.NoError(t, os.Rename(filepath.Join(second, "lib/a"), filepath.Join(second, "lib/c"))) },
+		func() { require.NoError(t, os.Symlink("../README.md", filepath.Join(second, "bin/readme"))) },
+	} {
+		change()
+		current := digest()
+		assert.False(t, seen[current], "each change yields a new digest")
+		seen[current] = true
+	}
+}
+
+// ociLayout writes an OCI image layout with one tagged manifest, returning
+// the manifest digest
+func ociLayout(t *testing.T, dir string) string {
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	sum := sha256.Sum256(manifest)
+	value := hex.EncodeToString(sum[:])
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "blobs", "sha256", value), manifest, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0o644))
+	index := `{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:` +
+		value + `","size":78,"annotations":{"org.opencontainers.image.ref.name":"ghcr.io/owner/app:v1"}}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.json"), []byte(index), 0o644))
+	return value
+}
+
+// tarLayout archives a layout directory, gzipped if requested
+func tarLayout(t *testing.T, dir, archivePath string, compress bool) {
+	f, err := os.Create(archivePath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var w io.Writer = f
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+	archive := tar.NewWriter(w)
+	require.NoError(t, filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, _ := filepath.Rel(dir, path)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, archive.WriteHeader(&tar.Header{Name: "./" + filepath.ToSlash(name), Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err = archive.Write(data)
+		return err
+	}))
+	require.NoError(t, archive.Close())
+	if gz != nil {
+		require.NoError(t, gz.Close())
+	}
+}
+
+func TestDigestOCILayoutDirectoryAndArchives(t *testing.T) {
+	root := t.TempDir()
+	layout := filepath.Join(root, "image")
+	digest := ociLayout(t, layout)
+	tarLayout(t, layout, filepath.Join(root, "image.tar"), false)
+	tarLayout(t, layout, filepath.Join(root, "image.tar.gz"), true)
+
+	for _, path := range []string{layout, filepath.Join(root, "image.tar"), filepath.Join(root, "image.tar.gz")} {
+		subjects, err := attestation.DigestOCILayout(path)
+		require.NoError(t, err, path)
+		require.Len(t, subjects, 1)
+		assert.Equal(t, "ghcr.io/owner/app:v1", subjects[0].Name)
+		assert.Equal(t, digest, subjects[0].Digest["sha256"])
+	}
+}
+
+func TestDigestOCILayoutRejectsTamperedManifests(t *testing.T) {
+	layout := t.TempDir()
+	digest := ociLayout(t, layout)
+	require.NoError(t, os.WriteFile(filepath.Join(layout, "blobs", "sha256", digest), []byte(`{"schemaVersion":2}`), 0o644))
+
+	_, err := attestation.DigestOCILayout(layout)
+	assert.Equal(t, attestation.CodeTargetNotResolved, attestation.CodeOf(err))
+	assert.ErrorContains(t, err, "hashes to")
+
+	_, err = attestation.DigestOCILayout(t.TempDir())
+	assert.ErrorContains(t, err, "no index.json")
+}
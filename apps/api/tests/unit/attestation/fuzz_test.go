@@ -0,0 +1,60 @@
+package attestation
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+func FuzzParseEnvelope(f *testing.F) {
+	statement, err := attestation.NewProvenanceBuilder().Build([]attestation.Subject{{
+		Name:   "ghcr.io/owner/repo",
+		Digest: attestation.DigestSet{"sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+	}}, testBuildContext())
+	if err != nil {
+		f.Fatal(err)
+	}
+	envelope, err := attestation.NewEnvelope(statement)
+	if err != nil {
+		f.Fatal(err)
+	}
+	envelope.Signatures = append(envelope.Signatures, attestation.EnvelopeSignature{KeyID: "key", Sig: "c2ln"})
+	signed, err := json.Marshal(envelope)
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add(signed)
+	f.Add([]byte(`{"payloadType":"application/vnd.in-toto+json","payload":"e30=","signatures":[]}`))
+	f.Add([]byte(`{"payloadType":"application/vnd.in-toto+json","payload":"bnVsbA==","signatures":null}`))
+	f.Add([]byte(`{"payloadType":"text/plain","payload":"!!","signatures":[{"sig":"%%"}]}`))
+	f.Add([]byte(`{}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		envelope, err := attestation.ParseEnvelope(data)
+		if err != nil {
+			return
+		}
+
+		payload, err := envelope.DecodePayload()
+		if err != nil {
+			t.Fatalf("parsed envelope has undecodable payload: %v", err)
+		}
+		attestation.PAE(envelope.PayloadType, payload)
+		envelope.Statement()
+
+		encoded, err := json.Marshal(envelope)
+		if err != nil {
+			t.Fatalf("parsed envelope does not encode: %v", err)
+		}
+		reparsed, err := attestation.ParseEnvelope(encoded)
+		if err != nil {
+			t.Fatalf("re-encoded envelope does not parse: %v", err)
+		}
+		if !reflect.DeepEqual(envelope, reparsed) {
+			t.Fatalf("envelope changed when re-encoded: %+v != %+v", envelope, reparsed)
+		}
+	})
+}
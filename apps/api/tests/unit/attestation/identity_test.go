@@ -0,0 +1,167 @@
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+const (
+	testIssuer   = "https://token.actions.githubusercontent.com"
+	testWorkflow = "https://github.com/owner/repo/.github/workflows/release.yml@refs/heads/main"
+)
+
+func derExtension(t *testing.T, oid asn1.ObjectIdentifier, value string) pkix.Extension {
+	encoded, err := asn1.MarshalWithParams(value, "utf8")
+	require.NoError(t, err)
+	return pkix.Extension{Id: oid, Value: encoded}
+}
+
+// testFulcioCertificate issues a self-signed certificate carrying Fulcio's GitHub Actions extensions
+func testFulcioCertificate(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	san, err := url.Parse(testWorkflow)
+	require.NoError(t, err)
+
+	oid := func(n int) asn1.ObjectIdentifier { return asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, n} }
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(10 * time.Minute),
+		URIs:         []*url.URL{san},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oid(1), Value: []byte(testIssuer)},
+			derExtension(t, oid(8), testIssuer),
+			derExtension(t, oid(9), testWorkflow),
+			derExtension(t, oid(12), "https://github.com/owner/repo"),
+			derExtension(t, oid(14), "refs/heads/main"),
+		},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestParseCertificateIdentity(t *testing.T) {
+	cert, _ := testFulcioCertificate(t)
+
+	identity := attestation.ParseCertificateIdentity(cert)
+	assert.Equal(t, testIssuer, identity.Issuer)
+	assert.Equal(t, testWorkflow, identity.SAN)
+	assert.Equal(t, "owner/repo", identity.Repository)
+	assert.Equal(t, "owner/repo/.github/workflows/release.yml@refs/heads/main", identity.WorkflowRef)
+	assert.Equal(t, "refs/heads/main", identity.Ref)
+}
+
+func TestIdentityPolicyVerify(t *testing.T) {
+	cert, _ := testFulcioCertificate(t)
+
+	tests := []struct {
+		name   string
+		policy attestation.IdentityPolicy
+		code   string
+	}{
+		{"empty_policy", attestation.IdentityPolicy{}, ""},
+		{"full_match", attestation.IdentityPolicy{
+			Issuer:      testIssuer,
+			SANRegexp:   `https://github\.com/owner/repo/.*`,
+			Repository:  "Owner/Repo",
+			WorkflowRef: ".github/workflows/release.yml",
+			Branch:      "main",
+		}, ""},
+		{"pinned_workflow", attestation.IdentityPolicy{WorkflowRef: "owner/repo/.github/workflows/release.yml@refs/heads/main"}, ""},
+		{"issuer", attestation.IdentityPolicy{Issuer: "https://accounts.google.com"}, attestation.CodeIssuerMismatch},
+		{"san_is_anchored", attestation.IdentityPolicy{SANRegexp: `github\.com/owner/repo`}, attestation.CodeSANMismatch},
+		{"invalid_san_pattern", attestation.IdentityPolicy{SANRegexp: `(`}, attestation.CodeSANMismatch},
+		{"san_alternation", attestation.IdentityPolicy{SANRegexp: `https://github\.com/owner/repo/.*|https://github\.com/other/.*`}, ""},
+		{"san_alternation_is_anchored", attestation.IdentityPolicy{SANRegexp: `https://github\.com/owner/repo|evil`}, attestation.CodeSANMismatch},
+		{"repository", attestation.IdentityPolicy{Repository: "owner/fork"}, attestation.CodeRepositoryMismatch},
+		{"workflow", attestation.IdentityPolicy{WorkflowRef: ".github/workflows/ci.yml"}, attestation.CodeWorkflowMismatch},
+		{"workflow_from_root", attestation.IdentityPolicy{WorkflowRef: "/.github/workflows/release.yml"}, ""},
+		{"workflow_file_name_only", attestation.IdentityPolicy{WorkflowRef: "release.yml"}, attestation.CodeWorkflowMismatch},
+		{"workflow_path_suffix", attestation.IdentityPolicy{WorkflowRef: "workflows/release.yml"}, attestation.CodeWorkflowMismatch},
+		{"workflow_ref", attestation.IdentityPolicy{WorkflowRef: "owner/repo/.github/workflows/release.yml@refs/tags/v1"}, attestation.CodeWorkflowMismatch},
+		{"branch", attestation.IdentityPolicy{Branch: "refs/heads/develop"}, attestation.CodeBranchMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.policy.VerifyCertificate(cert)
+			if tt.code == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tt.code, attestation.CodeOf(err))
+		})
+	}
+}
+
+func TestIdentityPolicyReusableWorkflow(t *testing.T) {
+	// owner/app called a reusable workflow that lives in another repository
+	identity := &attestation.CertificateIdentity{
+		Repository:  "owner/app",
+		WorkflowRef: "evil-org/x/.github/workflows/release.yml@refs/heads/main",
+	}
+
+	policy := attestation.IdentityPolicy{WorkflowRef: ".github/workflows/release.yml"}
+	assert.Equal(t, attestation.CodeWorkflowMismatch, attestation.CodeOf(policy.Verify(identity)),
+		"a relative path only names the caller's own workflows")
+	policy.WorkflowRef = "evil-org/x/.github/workflows/release.yml"
+	assert.NoError(t, policy.Verify(identity))
+
+	identity.WorkflowRef = "owner/app/.github/workflows/release.yml@refs/heads/main"
+	policy.WorkflowRef = ".github/workflows/release.yml"
+	assert.NoError(t, policy.Verify(identity))
+}
+
+func TestIdentityPolicyVerifyEnvelope(t *testing.T) {
+	cert, key := testFulcioCertificate(t)
+	statement, err := attestation.NewProvenanceBuilder().Build([]attestation.Subject{testSubject(t)}, testBuildContext())
+	require.NoError(t, err)
+	envelope, err := attestation.NewEnvelope(statement)
+	require.NoError(t, err)
+
+	policy := attestation.IdentityPolicy{Issuer: testIssuer, Repository: "owner/repo", Branch: "main"}
+
+	_, err = policy.VerifyEnvelope(envelope, cert)
+	assert.Equal(t, attestation.CodeVerificationFailed, attestation.CodeOf(err), "unsigned envelope")
+
+	payload, err := envelope.DecodePayload()
+	require.NoError(t, err)
+	digest := sha256.Sum256(attestation.PAE(envelope.PayloadType, payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	envelope.Signatures = append(envelope.Signatures, attestation.EnvelopeSignature{Sig: base64.StdEncoding.EncodeToString(sig)})
+
+	identity, err := policy.VerifyEnvelope(envelope, cert)
+	require.NoError(t, err)
+	assert.Equal(t, "owner/repo", identity.Repository)
+
+	other, _ := testFulcioCertificate(t)
+	_, err = policy.VerifyEnvelope(envelope, other)
+	assert.Equal(t, attestation.CodeVerificationFailed, attestation.CodeOf(err), "signature from another key")
+
+	policy.Branch = "release"
+	_, err = policy.VerifyEnvelope(envelope, cert)
+	assert.Equal(t, attestation.CodeBranchMismatch, attestation.CodeOf(err))
+}
@@ -0,0 +1,83 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/index"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+func TestAttestationIndex(t *testing.T) {
+	ctx := context.Background()
+	db, err := storage.OpenDatabase(filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, storage.NewMigrationManager(db, "../../../internal/storage/migrations").MigrateWithLock(ctx, "test", time.Minute))
+	store := index.NewStore(db)
+
+	subject := testSubject(t)
+	statement, err := attestation.NewProvenanceBuilder().Build([]attestation.Subject{subject}, testBuildContext())
+	require.NoError(t, err)
+	envelope, err := attestation.NewEnvelope(statement)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer := &attestation.CryptoSigner{Signer: key, ID: "release-key"}
+	record, err := attestation.NewKeySignedRecord(ctx, subject.String(), envelope, signer,
+		attestation.SigningMetadata{Issuer: "https://token.actions.githubusercontent.com"})
+	require.NoError(t, err)
+	require.NoError(t, store.RecordGenerated(ctx, record, statement))
+
+	digest := "sha256:" + subject.Digest["sha256"]
+	entries, err := store.ForSubject(ctx, digest, "")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, record.ID, entries[0].AttestationID)
+	assert.Equal(t, attestation.PredicateSLSAProvenanceV1, entries[0].PredicateType)
+	assert.Equal(t, "release-key", entries[0].Identity)
+	assert.NotNil(t, entries[0].GeneratedAt)
+	assert.Nil(t, entries[0].VerifiedAt)
+
+	// A failed verification is not indexed
+	bundle := &attestation.Bundle{Envelope: envelope, TlogEntry: &attestation.TlogEntry{UUID: "24296fb24b8ad77a"}}
+	require.NoError(t, store.RecordVerified(ctx, bundle, &attestation.VerificationResult{Valid: false}))
+	entries, err = store.ForSubject(ctx, digest, "")
+	require.NoError(t, err)
+	assert.Nil(t, entries[0].VerifiedAt)
+
+	// Verifying the same attestation adds to its entry rather than duplicating it
+	result := &attestation.VerificationResult{Valid: true, VerifiedAt: time.Now(),
+		BuildLevel: &attestation.BuildLevelAssessment{Level: attestation.BuildLevel2}}
+	require.NoError(t, store.RecordVerified(ctx, bundle, result))
+	entries, err = store.ForSubject(ctx, digest, "")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "release-key", entries[0].Identity)
+	require.NotNil(t, entries[0].BuildLevel)
+	assert.Equal(t, attestation.BuildLevel2, *entries[0].BuildLevel)
+	assert.Equal(t, "24296fb24b8ad77a", entries[0].RekorUUID)
+	assert.NotNil(t, entries[0].GeneratedAt)
+	assert.NotNil(t, entries[0].VerifiedAt)
+
+	known, err := store.HasRekorEntry(ctx, "24296fb24b8ad77a")
+	require.NoError(t, err)
+	assert.True(t, known)
+	known, err = store.HasRekorEntry(ctx, "c0ffee")
+	require.NoError(t, err)
+	assert.False(t, known)
+
+	entries, err = store.ForSubject(ctx, digest, "https://cyclonedx.org/bom")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,82 @@
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/index"
+	"github.com/salman-frs/keystone/apps/api/internal/ingest"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+// memoryBucket is an in-memory drop bucket
+type memoryBucket map[string][]byte
+
+func (b memoryBucket) List(_ context.Context, prefix string) ([]ingest.Object, error) {
+	var objects []ingest.Object
+	for key, data := range b {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, ingest.Object{Key: key, Size: int64(len(data))})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (b memoryBucket) Get(_ context.Context, key string) ([]byte, error) { return b[key], nil }
+
+func (b memoryBucket) Put(_ context.Context, key string, data []byte) error {
+	b[key] = data
+	return nil
+}
+
+func (b memoryBucket) Move(_ context.Context, from, to string) error {
+	b[to] = b[from]
+	delete(b, from)
+	return nil
+}
+
+// fixedTrust serves one trust root
+type fixedTrust attestation.TrustRoot
+
+func (f fixedTrust) TrustRoot(context.Context) (attestation.TrustRoot, error) {
+	return attestation.TrustRoot(f), nil
+}
+
+func TestIngestVerifiesBundlesAgainstTheServerTrustRoot(t *testing.T) {
+	fixture := newBundleFixture(t)
+	trusted, err := json.Marshal(fixture.bundle(t))
+	require.NoError(t, err)
+	// Verifies only against the root it carries
+	untrusted, err := json.Marshal(newBundleFixture(t).bundle(t))
+	require.NoError(t, err)
+
+	bucket := memoryBucket{"drops/trusted.json": trusted, "drops/untrusted.json": untrusted}
+	db := migratedDB(t)
+	config := ingest.DefaultConfig()
+	config.Policy = attestation.IdentityPolicy{Issuer: testIssuer, Repository: "owner/repo"}
+	ingester := ingest.New(bucket, "drops/", fixedTrust(fixture.trust), index.NewStore(db), sbom.NewStore(db), config)
+
+	summary, err := ingester.Poll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, ingest.Summary{Archived: 1, Quarantined: 1}, *summary)
+	assert.Contains(t, bucket, "drops/archive/trusted.json")
+	assert.Contains(t, bucket, "drops/quarantine/untrusted.json")
+
+	var note ingest.ErrorNote
+	require.NoError(t, json.Unmarshal(bucket["drops/quarantine/untrusted.json"+ingest.ErrorNoteSuffix], &note))
+	assert.Equal(t, attestation.CodeCertificateUntrusted, note.Code)
+
+	entries, err := index.NewStore(db).ForSubject(context.Background(), "sha256:"+testSubject(t).Digest["sha256"], "")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, testIssuer, entries[0].Issuer)
+	assert.NotNil(t, entries[0].VerifiedAt)
+}
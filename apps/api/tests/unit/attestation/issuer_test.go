@@ -0,0 +1,240 @@
+package attestation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+func gitLabClaims() attestation.Claims {
+	return attestation.Claims{
+		"iss":               "https://gitlab.com",
+		"aud":               "sigstore",
+		"sub":               "project_path:group/sub/project:ref_type:branch:ref:main",
+		"project_path":      "group/sub/project",
+		"ref":               "main",
+		"ref_type":          "branch",
+		"ci_config_ref_uri": "gitlab.com/group/sub/project//.gitlab-ci.yml@refs/heads/main",
+		"environment":       "production",
+	}
+}
+
+func encodeToken(t *testing.T, claims attestation.Claims) string {
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+}
+
+func TestIssuerProfileGitHubIdentity(t *testing.T) {
+	profile, err := attestation.LookupIssuerProfile("")
+	require.NoError(t, err)
+	assert.Equal(t, attestation.IssuerProfileGitHub, profile.Name)
+
+	identity, err := profile.Identity(testClaims())
+	require.NoError(t, err)
+	assert.Equal(t, attestation.GitHubActionsIssuer, identity.Issuer)
+	assert.Equal(t, "owner/repo", identity.Repository)
+	assert.Equal(t, "refs/heads/main", identity.Ref)
+	assert.Equal(t, testWorkflow, identity.SAN)
+	assert.Equal(t, "owner/repo/.github/workflows/release.yml@refs/heads/main", identity.WorkflowRef)
+}
+
+func TestIssuerProfileGitLabIdentity(t *testing.T) {
+	profile, err := attestation.LookupIssuerProfile("GitLab")
+	require.NoError(t, err)
+
+	identity, err := profile.Identity(gitLabClaims())
+	require.NoError(t, err)
+	assert.Equal(t, attestation.GitLabIssuer, identity.Issuer)
+	assert.Equal(t, "group/sub/project", identity.Repository)
+	assert.Equal(t, "refs/heads/main", identity.Ref)
+	assert.Equal(t, "https://gitlab.com/group/sub/project//.gitlab-ci.yml@refs/heads/main", identity.SAN)
+
+	// Tags expand to full refs, and the identity satisfies a GitLab policy
+	claims := gitLabClaims()
+	claims["ref"], claims["ref_type"] = "v1.2.0", "tag"
+	identity, err = profile.Identity(claims)
+	require.NoError(t, err)
+	assert.Equal(t, "refs/tags/v1.2.0", identity.Ref)
+
+	policy := attestation.IdentityPolicy{Repository: "group/sub/project", WorkflowRef: ".gitlab-ci.yml"}
+	_, err = profile.VerifyToken(encodeToken(t, gitLabClaims()), policy)
+	assert.NoError(t, err)
+	policy.Branch = "release"
+	_, err = profile.VerifyToken(encodeToken(t, gitLabClaims()), policy)
+	assert.Equal(t, attestation.CodeBranchMismatch, attestation.CodeOf(err))
+
+	values, err := mustMapper(t, profile.ClaimMappings).Map(gitLabClaims())
+	require.NoError(t, err)
+	assert.Equal(t, "gitlab.com/group/sub/project//.gitlab-ci.yml@refs/heads/main", values.Annotations["keystone.oidc.ci_config_ref_uri"])
+}
+
+func TestIssuerProfileRejectsForeignTokens(t *testing.T) {
+	gitlab, err := attestation.LookupIssuerProfile(attestation.IssuerProfileGitLab)
+	require.NoError(t, err)
+
+	_, err = gitlab.Identity(testClaims())
+	assert.Equal(t, attestation.CodeIssuerMismatch, attestation.CodeOf(err))
+
+	claims := gitLabClaims()
+	delete(claims, "project_path")
+	_, err = gitlab.Identity(claims)
+	assert.Equal(t, attestation.CodeMissingSubject, attestation.CodeOf(err))
+
+	claims = gitLabClaims()
+	claims["ref_type"] = "merge_request"
+	_, err = gitlab.Identity(claims)
+	assert.Equal(t, attestation.CodeMissingSubject, attestation.CodeOf(err))
+
+	_, err = attestation.LookupIssuerProfile("bitbucket")
+	assert.ErrorContains(t, err, "unknown OIDC issuer profile")
+}
+
+func TestIssuerProfileBuildkiteIdentity(t *testing.T) {
+	profile, err := attestation.LookupIssuerProfile(attestation.IssuerProfileBuildkite)
+	require.NoError(t, err)
+
+	claims := attestation.Claims{
+		"iss":               attestation.BuildkiteIssuer,
+		"aud":               "sigstore",
+		"organization_slug": "acme",
+		"pipeline_slug":     "payments",
+		"build_branch":      "main",
+		"build_tag":         nil,
+	}
+	identity, err := profile.Identity(claims)
+	require.NoError(t, err)
+	assert.Equal(t, "https://buildkite.com/acme/payments", identity.SAN)
+	assert.Equal(t, "acme/payments", identity.Repository)
+	assert.Equal(t, "refs/heads/main", identity.Ref)
+
+	claims["build_tag"] = "v2.0.0"
+	identity, err = profile.Identity(claims)
+	require.NoError(t, err)
+	assert.Equal(t, "refs/tags/v2.0.0", identity.Ref)
+}
+
+func TestIssuerProfileCircleCIIdentity(t *testing.T) {
+	profile, err := attestation.LookupIssuerProfile(attestation.IssuerProfileCircleCI)
+	require.NoError(t, err)
+
+	claims := attestation.Claims{
+		"iss":                          "https://oidc.circleci.com/org/0ad3ad3c-b3e4-4b35-8a8a-a1f0e2d5a3c1",
+		"aud":                          []interface{}{"0ad3ad3c-b3e4-4b35-8a8a-a1f0e2d5a3c1", "sigstore"},
+		"oidc.circleci.com/project-id": "5d2c7b4e",
+		"oidc.circleci.com/pipeline-definition-id": "9f1e",
+		"oidc.circleci.com/vcs-origin":             "github.com/acme/payments",
+		"oidc.circleci.com/vcs-ref":                "refs/heads/main",
+	}
+	identity, err := profile.Identity(claims)
+	require.NoError(t, err)
+	// The policy's issuer defaults to the organization's
+	assert.Equal(t, "https://oidc.circleci.com/org/0ad3ad3c-b3e4-4b35-8a8a-a1f0e2d5a3c1", identity.Issuer)
+	assert.Equal(t, "https://circleci.com/api/v2/projects/5d2c7b4e/pipeline-definitions/9f1e", identity.SAN)
+	assert.Equal(t, "acme/payments", identity.Repository)
+	assert.Equal(t, "refs/heads/main", identity.Ref)
+
+	_, err = profile.VerifyToken(encodeToken(t, claims), attestation.IdentityPolicy{Repository: "acme/payments", Branch: "main"})
+	assert.NoError(t, err)
+
+	claims["iss"] = "https://oidc.circleci.com/org/a/b"
+	_, err = profile.Identity(claims)
+	assert.Equal(t, attestation.CodeIssuerMismatch, attestation.CodeOf(err))
+
+	t.Setenv("SIGSTORE_OIDC_PROFILE", "circleci")
+	_, err = attestation.SigstoreConfigFromEnv()
+	assert.ErrorContains(t, err, "SIGSTORE_OIDC_ISSUER")
+}
+
+func TestIssuerProfileCloudBuildIdentity(t *testing.T) {
+	profile, err := attestation.LookupIssuerProfile(attestation.IssuerProfileCloudBuild)
+	require.NoError(t, err)
+
+	claims := attestation.Claims{
+		"iss":            attestation.GoogleIssuer,
+		"aud":            "sigstore",
+		"email":          "builder@acme.iam.gserviceaccount.com",
+		"email_verified": true,
+	}
+	identity, err := profile.Identity(claims)
+	require.NoError(t, err)
+	assert.Equal(t, "builder@acme.iam.gserviceaccount.com", identity.SAN)
+	assert.Empty(t, identity.Repository)
+
+	claims["email_verified"] = false
+	_, err = profile.Identity(claims)
+	assert.Equal(t, attestation.CodeMissingSubject, attestation.CodeOf(err))
+}
+
+func TestIssuerProfileRequiresSigstoreAudience(t *testing.T) {
+	profile, err := attestation.LookupIssuerProfile(attestation.IssuerProfileGitHub)
+	require.NoError(t, err)
+
+	claims := testClaims()
+	claims["aud"] = "https://github.com/owner"
+	_, err = profile.Identity(claims)
+	assert.Equal(t, attestation.CodeInvalidAudience, attestation.CodeOf(err))
+
+	delete(claims, "aud")
+	_, err = profile.Identity(claims)
+	assert.Equal(t, attestation.CodeInvalidAudience, attestation.CodeOf(err))
+
+	claims = testClaims()
+	delete(claims, "job_workflow_ref")
+	_, err = profile.Identity(claims)
+	assert.Equal(t, attestation.CodeMissingSubject, attestation.CodeOf(err))
+}
+
+func TestIssuerProfileToken(t *testing.T) {
+	gitlab, err := attestation.LookupIssuerProfile(attestation.IssuerProfileGitLab)
+	require.NoError(t, err)
+
+	t.Setenv("SIGSTORE_ID_TOKEN", "")
+	_, err = gitlab.Token()
+	assert.Equal(t, attestation.CodeOIDCTokenUnavailable, attestation.CodeOf(err))
+
+	t.Setenv("SIGSTORE_ID_TOKEN", "header.payload.sig\n")
+	token, err := gitlab.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "header.payload.sig", token)
+
+	github, err := attestation.LookupIssuerProfile(attestation.IssuerProfileGitHub)
+	require.NoError(t, err)
+	_, err = github.Token()
+	assert.Equal(t, attestation.CodeOIDCTokenUnavailable, attestation.CodeOf(err))
+}
+
+func TestSigstoreConfigIssuerProfile(t *testing.T) {
+	t.Setenv("SIGSTORE_OIDC_PROFILE", "gitlab")
+	config, err := attestation.SigstoreConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, attestation.GitLabIssuer, config.ApplyIssuer(attestation.IdentityPolicy{}).Issuer)
+
+	// A self-managed GitLab server issues tokens from its own URL
+	t.Setenv("SIGSTORE_OIDC_ISSUER", "https://gitlab.example.com")
+	config, err = attestation.SigstoreConfigFromEnv()
+	require.NoError(t, err)
+	profile, err := config.IssuerProfile()
+	require.NoError(t, err)
+	assert.Equal(t, "https://gitlab.example.com", profile.Issuer)
+
+	claims := gitLabClaims()
+	claims["iss"] = "https://gitlab.example.com"
+	_, err = profile.Identity(claims)
+	assert.NoError(t, err)
+
+	t.Setenv("SIGSTORE_OIDC_PROFILE", "jenkins")
+	_, err = attestation.SigstoreConfigFromEnv()
+	assert.ErrorContains(t, err, "unknown OIDC issuer profile")
+}
+
+func mustMapper(t *testing.T, mappings []attestation.ClaimMapping) *attestation.ClaimMapper {
+	mapper, err := attestation.NewClaimMapper(mappings)
+	require.NoError(t, err)
+	return mapper
+}
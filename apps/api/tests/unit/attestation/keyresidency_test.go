@@ -0,0 +1,224 @@
+package attestation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// TestKeyHelperProcess is not a real test: it acts as the hardware key helper
+// when the test binary is re-executed by CommandKeyProvider
+func TestKeyHelperProcess(t *testing.T) {
+	dir := os.Getenv("KEYSTONE_TEST_KEY_HELPER")
+	if dir == "" {
+		t.Skip("helper process")
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	if err := runKeyHelper(dir, args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// runKeyHelper emulates a TPM helper: keys and the attestation CA live in dir
+func runKeyHelper(dir string, args []string) error {
+	caKey, err := readKey(filepath.Join(dir, "ca.key"))
+	if err != nil {
+		return err
+	}
+	caCert, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(caCert)
+	ca, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "generate":
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "signing.key"), der, 0o600); err != nil {
+			return err
+		}
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(time.Now().UnixNano()),
+			Subject:      pkix.Name{CommonName: "tpm-attested-key"},
+			NotBefore:    time.Now().Add(-time.Minute),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		certDER, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			return err
+		}
+		publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if err != nil {
+			return err
+		}
+
+		return json.NewEncoder(os.Stdout).Encode(attestation.KeyResidencyAttestation{
+			Residency:    attestation.ResidencyTPM2,
+			KeyID:        "handle-0x81000001",
+			PublicKey:    string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+			Format:       attestation.AttestationFormatX509Chain,
+			Certificates: []string{string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))},
+		})
+
+	case "sign":
+		key, err := readKey(filepath.Join(dir, "signing.key"))
+		if err != nil {
+			return err
+		}
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		digest, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(input)))
+		if err != nil {
+			return err
+		}
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest)
+		if err != nil {
+			return err
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(sig))
+		return nil
+	}
+	return fmt.Errorf("unknown subcommand %q", args[0])
+}
+
+func readKey(path string) (*ecdsa.PrivateKey, error) {
+	der, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseECPrivateKey(der)
+}
+
+// newTPMProvider prepares a helper directory with an attestation CA and returns
+// a provider re-executing the test binary as the helper, plus the CA pool
+func newTPMProvider(t *testing.T) (*attestation.CommandKeyProvider, *x509.CertPool) {
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-tpm-manufacturer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(caKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.key"), keyDER, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600))
+
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	return &attestation.CommandKeyProvider{
+		Command:  os.Args[0],
+		Args:     []string{"-test.run=TestKeyHelperProcess", "--"},
+		Env:      []string{"KEYSTONE_TEST_KEY_HELPER=" + dir},
+		Hardware: attestation.ResidencyTPM2,
+	}, roots
+}
+
+func testToken(t *testing.T, subject string) string {
+	payload, err := json.Marshal(map[string]string{"sub": subject})
+	require.NoError(t, err)
+	return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+}
+
+func TestCommandKeyProviderHardwareKey(t *testing.T) {
+	provider, roots := newTPMProvider(t)
+	subject := "repo:owner/repo:ref:refs/heads/main"
+
+	req, signer, err := attestation.NewCertificateRequest(context.Background(), provider, testToken(t, subject))
+	require.NoError(t, err)
+	assert.Equal(t, "ECDSA", req.PublicKeyRequest.PublicKey.Algorithm)
+	require.NotNil(t, req.KeyResidency)
+	assert.Equal(t, attestation.ResidencyTPM2, req.KeyResidency.Residency)
+
+	// The proof of possession is made by the hardware key over the token subject
+	proof, err := base64.StdEncoding.DecodeString(req.PublicKeyRequest.ProofOfPossession)
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(subject))
+	assert.True(t, ecdsa.VerifyASN1(signer.Public().(*ecdsa.PublicKey), digest[:], proof))
+
+	require.NoError(t, attestation.VerifyKeyResidency(req.KeyResidency, roots))
+
+	metadata := &attestation.SigningMetadata{}
+	attestation.RecordKeyResidency(metadata, req.KeyResidency, true)
+	assert.Equal(t, attestation.ResidencyTPM2, metadata.Annotations[attestation.AnnotationKeyResidency])
+	assert.Equal(t, req.KeyResidency.Digest(), metadata.Annotations[attestation.AnnotationKeyAttestationDigest])
+	assert.Equal(t, "true", metadata.Annotations[attestation.AnnotationKeyResidencyVerified])
+
+	_, err = signer.Sign(rand.Reader, digest[:], crypto.SHA512)
+	assert.Error(t, err, "hardware keys only sign SHA-256 digests")
+}
+
+func TestVerifyKeyResidencyRejects(t *testing.T) {
+	provider, roots := newTPMProvider(t)
+	_, att, err := provider.Generate(context.Background())
+	require.NoError(t, err)
+
+	_, otherRoots := newTPMProvider(t)
+	err = attestation.VerifyKeyResidency(att, otherRoots)
+	assert.Equal(t, attestation.CodeKeyResidencyUnverified, attestation.CodeOf(err), "untrusted manufacturer")
+
+	_, software, err := attestation.SoftwareKeyProvider{}.Generate(context.Background())
+	require.NoError(t, err)
+	err = attestation.VerifyKeyResidency(software, roots)
+	assert.Equal(t, attestation.CodeKeyResidencyUnverified, attestation.CodeOf(err), "software key")
+
+	swapped := *att
+	swapped.PublicKey = software.PublicKey
+	err = attestation.VerifyKeyResidency(&swapped, roots)
+	assert.Equal(t, attestation.CodeKeyResidencyUnverified, attestation.CodeOf(err), "evidence for another key")
+
+	provider.Hardware = attestation.ResidencySecureEnclave
+	_, _, err = provider.Generate(context.Background())
+	assert.Equal(t, attestation.CodeKeyResidencyUnverified, attestation.CodeOf(err), "unexpected residency")
+}
@@ -0,0 +1,317 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/kms"
+)
+
+// fakeKMS emulates the signing APIs of each backend over one ECDSA P-256 key
+type fakeKMS struct {
+	key *ecdsa.PrivateKey
+}
+
+func (f *fakeKMS) publicDER(t *testing.T) []byte {
+	der, err := x509.MarshalPKIXPublicKey(&f.key.PublicKey)
+	require.NoError(t, err)
+	return der
+}
+
+func (f *fakeKMS) publicPEM(t *testing.T) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: f.publicDER(t)}))
+}
+
+func (f *fakeKMS) sign(t *testing.T, digest []byte) []byte {
+	sig, err := ecdsa.SignASN1(rand.Reader, f.key, digest)
+	require.NoError(t, err)
+	return sig
+}
+
+func decodeBody(t *testing.T, r *http.Request) map[string]interface{} {
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	return body
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeKMS) aws(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/us-east-1/kms/aws4_request")
+		body := decodeBody(t, r)
+		assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/abcd", body["KeyId"])
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			writeJSON(w, map[string]string{"PublicKey": base64.StdEncoding.EncodeToString(f.publicDER(t))})
+		case "TrentService.Sign":
+			assert.Equal(t, "DIGEST", body["MessageType"])
+			assert.Equal(t, "ECDSA_SHA_256", body["SigningAlgorithm"])
+			digest, err := base64.StdEncoding.DecodeString(body["Message"].(string))
+			require.NoError(t, err)
+			writeJSON(w, map[string]string{"Signature": base64.StdEncoding.EncodeToString(f.sign(t, digest))})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}
+}
+
+func (f *fakeKMS) gcp(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gcp-token", r.Header.Get("Authorization"))
+		name := "/v1/projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+
+		switch r.URL.Path {
+		case name + "/publicKey":
+			writeJSON(w, map[string]string{"pem": f.publicPEM(t), "algorithm": "EC_SIGN_P256_SHA256"})
+		case name + ":asymmetricSign":
+			body := decodeBody(t, r)
+			digest, err := base64.StdEncoding.DecodeString(body["digest"].(map[string]interface{})["sha256"].(string))
+			require.NoError(t, err)
+			writeJSON(w, map[string]string{"signature": base64.StdEncoding.EncodeToString(f.sign(t, digest))})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func (f *fakeKMS) azure(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer azure-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "7.4", r.URL.Query().Get("api-version"))
+
+		switch r.URL.Path {
+		case "/keys/signing":
+			writeJSON(w, map[string]interface{}{"key": map[string]string{
+				"kid": "https://vault.vault.azure.net/keys/signing/v3",
+				"kty": "EC",
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(f.key.X.FillBytes(make([]byte, 32))),
+				"y":   base64.RawURLEncoding.EncodeToString(f.key.Y.FillBytes(make([]byte, 32))),
+			}})
+		case "/keys/signing/v3/sign":
+			body := decodeBody(t, r)
+			assert.Equal(t, "ES256", body["alg"])
+			digest, err := base64.RawURLEncoding.DecodeString(body["value"].(string))
+			require.NoError(t, err)
+			rs, ss, err := ecdsa.Sign(rand.Reader, f.key, digest)
+			require.NoError(t, err)
+			raw := append(rs.FillBytes(make([]byte, 32)), ss.FillBytes(make([]byte, 32))...)
+			writeJSON(w, map[string]string{"kid": "signing/v3", "value": base64.RawURLEncoding.EncodeToString(raw)})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func (f *fakeKMS) vault(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/transit/keys/signing":
+			writeJSON(w, map[string]interface{}{"data": map[string]interface{}{
+				"latest_version": 2,
+				"keys":           map[string]interface{}{"2": map[string]string{"public_key": f.publicPEM(t)}},
+			}})
+		case "/v1/transit/sign/signing/sha2-256":
+			body := decodeBody(t, r)
+			assert.Equal(t, true, body["prehashed"])
+			digest, err := base64.StdEncoding.DecodeString(body["input"].(string))
+			require.NoError(t, err)
+			writeJSON(w, map[string]interface{}{"data": map[string]string{
+				"signature": "vault:v2:" + base64.StdEncoding.EncodeToString(f.sign(t, digest)),
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func TestKMSSigners(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	fake := &fakeKMS{key: key}
+
+	tests := []struct {
+		backend string
+		handler http.HandlerFunc
+		config  kms.Config
+	}{
+		{kms.BackendAWS, fake.aws(t), kms.Config{
+			KeyRef:             "awskms:///arn:aws:kms:us-east-1:123456789012:key/abcd",
+			AWSAccessKeyID:     "AKID",
+			AWSSecretAccessKey: "secret",
+		}},
+		{kms.BackendGCP, fake.gcp(t), kms.Config{
+			KeyRef: "gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1",
+			Token:  "gcp-token",
+		}},
+		{kms.BackendAzure, fake.azure(t), kms.Config{
+			KeyRef: "azurekms://vault.vault.azure.net/signing",
+			Token:  "azure-token",
+		}},
+		{kms.BackendVault, fake.vault(t), kms.Config{
+			KeyRef: "hashivault://signing",
+			Token:  "vault-token",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			tt.config.Endpoint = server.URL
+
+			require.True(t, tt.config.Enabled())
+			signer, err := kms.New(tt.config)
+			require.NoError(t, err)
+			assert.Equal(t, tt.backend, signer.Backend())
+
+			statement, err := attestation.NewProvenanceBuilder().Build([]attestation.Subject{testSubject(t)}, testBuildContext())
+			require.NoError(t, err)
+			envelope, err := attestation.NewEnvelope(statement)
+			require.NoError(t, err)
+
+			record, err := attestation.NewKeySignedRecord(context.Background(), "ghcr.io/owner/repo@sha256:abc", envelope, signer, attestation.SigningMetadata{})
+			require.NoError(t, err)
+			assert.Equal(t, statement.PredicateType, record.Type)
+			assert.Equal(t, tt.backend, record.Metadata.Annotations[attestation.AnnotationSigningBackend])
+			assert.Equal(t, tt.config.KeyRef, record.Metadata.Annotations[attestation.AnnotationSigningKeyID])
+			assert.Equal(t, tt.config.KeyRef, envelope.Signatures[0].KeyID)
+
+			// The record carries the public key in place of a certificate
+			block, _ := pem.Decode([]byte(record.Certificate))
+			require.NotNil(t, block)
+			publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+			require.NoError(t, err)
+			require.NoError(t, attestation.VerifyEnvelopeKey(envelope, publicKey))
+		})
+	}
+}
+
+func TestKMSConfig(t *testing.T) {
+	assert.False(t, kms.Config{}.Enabled())
+	_, err := kms.New(kms.Config{})
+	assert.Error(t, err)
+
+	_, err = kms.New(kms.Config{KeyRef: "pkcs11://token"})
+	assert.Error(t, err, "unknown backend")
+
+	_, err = kms.New(kms.Config{KeyRef: "gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k", Token: "t"})
+	assert.Error(t, err, "crypto key version required")
+
+	_, err = kms.New(kms.Config{KeyRef: "awskms:///alias/signing", AWSAccessKeyID: "a", AWSSecretAccessKey: "s"})
+	assert.Error(t, err, "region required for aliases")
+
+	_, err = kms.New(kms.Config{KeyRef: "hashivault://signing"})
+	assert.Error(t, err, "vault address and token required")
+}
+
+func TestKMSPermissionDenied(t *testing.T) {
+	fake := &fakeKMS{}
+	server := httptest.NewServer(fake.vault(t))
+	defer server.Close()
+
+	signer, err := kms.New(kms.Config{KeyRef: "hashivault://signing", Endpoint: server.URL, Token: "wrong"})
+	require.NoError(t, err)
+
+	statement, err := attestation.NewProvenanceBuilder().Build([]attestation.Subject{testSubject(t)}, testBuildContext())
+	require.NoError(t, err)
+	envelope, err := attestation.NewEnvelope(statement)
+	require.NoError(t, err)
+
+	_, err = attestation.NewKeySignedRecord(context.Background(), "target", envelope, signer, attestation.SigningMetadata{})
+	assert.Equal(t, attestation.CodePermissionDenied, attestation.CodeOf(err))
+
+	// A rejected signature is not a valid signature for another key
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	envelope.Signatures = []attestation.EnvelopeSignature{{Sig: base64.StdEncoding.EncodeToString(big.NewInt(1).Bytes())}}
+	assert.Equal(t, attestation.CodeVerificationFailed, attestation.CodeOf(attestation.VerifyEnvelopeKey(envelope, &other.PublicKey)))
+}
+
+func TestStaticKeySigning(t *testing.T) {
+	privatePEM, publicPEM, err := kms.GenerateKeyPair([]byte("hunter2"))
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "cosign.key")
+	require.NoError(t, os.WriteFile(keyFile, privatePEM, 0o600))
+	t.Setenv("TEST_COSIGN_KEY", string(privatePEM))
+
+	block, _ := pem.Decode(publicPEM)
+	require.NotNil(t, block)
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	require.NoError(t, err)
+
+	for _, keyRef := range []string{keyFile, "env://TEST_COSIGN_KEY"} {
+		t.Run(keyRef, func(t *testing.T) {
+			config := kms.Config{KeyRef: keyRef, KeyPassword: "hunter2", SkipTlogUpload: true}
+			require.Equal(t, kms.BackendStatic, config.Backend())
+			signer, err := kms.New(config)
+			require.NoError(t, err)
+
+			statement, err := attestation.NewProvenanceBuilder().Build([]attestation.Subject{testSubject(t)}, testBuildContext())
+			require.NoError(t, err)
+			envelope, err := attestation.NewEnvelope(statement)
+			require.NoError(t, err)
+
+			metadata := attestation.SigningMetadata{}
+			config.Annotate(&metadata)
+			record, err := attestation.NewKeySignedRecord(context.Background(), "target", envelope, signer, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, kms.BackendStatic, record.Metadata.Annotations[attestation.AnnotationSigningBackend])
+			assert.Equal(t, "skipped", record.Metadata.Annotations[attestation.AnnotationTlogUpload])
+			require.NoError(t, attestation.VerifyEnvelopeKey(envelope, publicKey))
+		})
+	}
+
+	_, err = kms.New(kms.Config{KeyRef: keyFile, KeyPassword: "wrong"})
+	assert.ErrorContains(t, err, "wrong password")
+	_, err = kms.New(kms.Config{KeyRef: "env://TEST_MISSING_KEY"})
+	assert.Error(t, err)
+}
+
+func TestLoadUnencryptedPrivateKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sec1, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	loaded, err := kms.LoadPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}), nil)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(loaded))
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	loaded, err = kms.LoadPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), nil)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(loaded))
+
+	_, err = kms.LoadPrivateKey([]byte("not a key"), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,222 @@
+package attestation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/monitor"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+// fakeRekor serves the log info and entry retrieval endpoints of Rekor
+type fakeRekor struct {
+	mutex   sync.Mutex
+	entries []map[string]interface{}
+}
+
+func (f *fakeRekor) add(uuid, body string, integrated time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.entries = append(f.entries, map[string]interface{}{
+		"uuid":           uuid,
+		"body":           base64.StdEncoding.EncodeToString([]byte(body)),
+		"integratedTime": integrated.Unix(),
+		"logIndex":       int64(len(f.entries)),
+	})
+}
+
+func (f *fakeRekor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch r.URL.Path {
+	case "/api/v1/log":
+		// The first entries live in a frozen shard
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"treeSize":       len(f.entries) - 1,
+			"inactiveShards": []map[string]interface{}{{"treeSize": 1}},
+		})
+	case "/api/v1/log/entries/retrieve":
+		var request struct {
+			LogIndexes []int64 `json:"logIndexes"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		if len(request.LogIndexes) > 10 {
+			http.Error(w, "too many indexes", http.StatusBadRequest)
+			return
+		}
+		response := []map[string]interface{}{}
+		for _, i := range request.LogIndexes {
+			entry := f.entries[i]
+			response = append(response, map[string]interface{}{entry["uuid"].(string): entry})
+		}
+		json.NewEncoder(w).Encode(response)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func hashedRekordBody(key string) string {
+	return `{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{"signature":{"publicKey":{"content":"` +
+		base64.StdEncoding.EncodeToString([]byte(key)) + `"}}}}`
+}
+
+func dsseBody(key string) string {
+	return `{"apiVersion":"0.0.1","kind":"dsse","spec":{"signatures":[{"verifier":"` +
+		base64.StdEncoding.EncodeToString([]byte(key)) + `"}]}}`
+}
+
+// migratedDB opens a database with every migration applied
+func migratedDB(t *testing.T) *sql.DB {
+	db, err := storage.OpenDatabase(filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, storage.NewMigrationManager(db, "../../../internal/storage/migrations").MigrateWithLock(context.Background(), "test", time.Minute))
+	return db
+}
+
+func newMonitorStore(t *testing.T) *monitor.Store {
+	return monitor.NewStore(migratedDB(t))
+}
+
+func TestRekorMonitorAlertsOnUnexpectedEntries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	fake := clock.NewFake(now)
+	cert := pemCertificate(newBundleFixture(t).leaf)
+	publicKey := "-----BEGIN PUBLIC KEY-----\nMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE\n-----END PUBLIC KEY-----\n"
+
+	rekor := &fakeRekor{}
+	rekor.add("entry-before-first-poll", hashedRekordBody(cert), now.Add(-time.Hour))
+	server := httptest.NewServer(rekor)
+	defer server.Close()
+
+	config := monitor.DefaultConfig(server.URL)
+	config.Watch = []attestation.IdentityPolicy{{Issuer: testIssuer, Repository: "owner/repo"}}
+	config.Clock = fake
+	known := func(_ context.Context, uuid string) (bool, error) { return uuid == "keystone-entry", nil }
+	m, err := monitor.New(monitor.NewRekorClient(server.URL), newMonitorStore(t), known, config)
+	require.NoError(t, err)
+
+	// The first poll starts from the end of the log
+	alerts, err := m.Poll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	for i := 0; i < 12; i++ {
+		rekor.add("unrelated", hashedRekordBody(publicKey), now.Add(-time.Hour))
+	}
+	rekor.add("keystone-entry", hashedRekordBody(cert), now.Add(-time.Hour))
+	rekor.add("stolen-token", dsseBody(cert), now.Add(-time.Hour))
+	rekor.add("too-recent", hashedRekordBody(cert), now)
+
+	bus := events.NewMemoryBus()
+	defer bus.Close()
+	received := make(chan events.Event, 4)
+	_, err = bus.Subscribe(events.TypeIdentityMisuse, func(_ context.Context, event events.Event) error {
+		received <- event
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, m.Notify(ctx, bus, "worker-1"))
+	var alert events.IdentityMisuse
+	select {
+	case event := <-received:
+		require.NoError(t, json.Unmarshal(event.Data, &alert))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no identity misuse event published")
+	}
+	assert.Equal(t, "stolen-token", alert.UUID)
+	assert.Equal(t, int64(14), alert.LogIndex)
+	assert.Equal(t, "dsse", alert.Kind)
+	assert.Equal(t, testWorkflow, alert.Identity)
+	assert.Equal(t, "owner/repo", alert.Repository)
+	assert.Equal(t, server.URL, alert.LogURL)
+
+	// The recent entry is scanned once it has settled
+	fake.Advance(3 * time.Minute)
+	alerts, err = m.Poll(ctx)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "too-recent", alerts[0].UUID)
+
+	alerts, err = m.Poll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+}
+
+// racingLog runs another poll while this monitor is fetching entries
+type racingLog struct {
+	monitor.Log
+	race func()
+}
+
+func (l *racingLog) Entries(ctx context.Context, indexes []int64) ([]monitor.LogEntry, error) {
+	if l.race != nil {
+		l.race()
+		l.race = nil
+	}
+	return l.Log.Entries(ctx, indexes)
+}
+
+func TestRekorMonitorAlertsOnceAcrossWorkers(t *testing.T) {
+	ctx := context.Background()
+	cert := pemCertificate(newBundleFixture(t).leaf)
+	rekor := &fakeRekor{}
+	rekor.add("first", hashedRekordBody(cert), time.Now().Add(-time.Hour))
+	server := httptest.NewServer(rekor)
+	defer server.Close()
+
+	store := newMonitorStore(t)
+	config := monitor.DefaultConfig(server.URL)
+	config.Watch = []attestation.IdentityPolicy{{Repository: "owner/repo"}}
+	unknown := func(context.Context, string) (bool, error) { return false, nil }
+	first, err := monitor.New(monitor.NewRekorClient(server.URL), store, unknown, config)
+	require.NoError(t, err)
+	racing := &racingLog{Log: monitor.NewRekorClient(server.URL)}
+	second, err := monitor.New(racing, store, unknown, config)
+	require.NoError(t, err)
+
+	_, err = first.Poll(ctx)
+	require.NoError(t, err)
+	rekor.add("second", hashedRekordBody(cert), time.Now().Add(-time.Hour))
+
+	// Both scan the new entry, but the first advances the checkpoint while
+	// the second is still scanning
+	var firstAlerts []events.IdentityMisuse
+	racing.race = func() {
+		firstAlerts, err = first.Poll(ctx)
+		require.NoError(t, err)
+	}
+	secondAlerts, err := second.Poll(ctx)
+	require.NoError(t, err)
+
+	require.Len(t, firstAlerts, 1)
+	assert.Equal(t, "second", firstAlerts[0].UUID)
+	assert.Empty(t, secondAlerts)
+}
+
+func TestRekorMonitorRequiresConstrainedIdentities(t *testing.T) {
+	config := monitor.DefaultConfig("https://rekor.sigstore.dev")
+	_, err := monitor.New(nil, nil, nil, config)
+	assert.ErrorContains(t, err, "at least one identity")
+
+	config.Watch = []attestation.IdentityPolicy{{}}
+	_, err = monitor.New(nil, nil, nil, config)
+	assert.ErrorContains(t, err, "no constraints")
+}
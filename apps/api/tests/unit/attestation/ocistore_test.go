@@ -0,0 +1,176 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/ocistore"
+)
+
+// pushImage stores a minimal image manifest tagged "latest" and returns its descriptor
+func pushImage(t *testing.T, store *memory.Store) ocispec.Descriptor {
+	ctx := context.Background()
+	layerData := []byte("image layer")
+	layer := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, layerData)
+	require.NoError(t, store.Push(ctx, layer, bytes.NewReader(layerData)))
+
+	image, err := oras.PackManifest(ctx, store, oras.PackManifestVersion1_1, "application/vnd.test.image",
+		oras.PackManifestOptions{Layers: []ocispec.Descriptor{layer}})
+	require.NoError(t, err)
+	require.NoError(t, store.Tag(ctx, image, "latest"))
+	return image
+}
+
+func envelopeFor(t *testing.T, image ocispec.Descriptor, predicateType string) *attestation.Envelope {
+	subject, err := attestation.NewSubject("registry.example.com/app", image.Digest.String())
+	require.NoError(t, err)
+
+	envelope, err := attestation.NewEnvelope(&attestation.Statement{
+		Type:          attestation.StatementTypeV1,
+		Subject:       []attestation.Subject{subject},
+		PredicateType: predicateType,
+		Predicate:     map[string]interface{}{},
+	})
+	require.NoError(t, err)
+	return envelope
+}
+
+func TestOCIStorePush(t *testing.T) {
+	ctx := context.Background()
+	registry := memory.New()
+	image := pushImage(t, registry)
+	store := ocistore.New(registry)
+
+	result, err := store.Push(ctx, "latest", envelopeFor(t, image, attestation.PredicateSLSAProvenanceV1))
+	require.NoError(t, err)
+	assert.Equal(t, image.Digest, result.Subject.Digest)
+	assert.Equal(t, "sha256-"+image.Digest.Encoded()+".att", result.Tag)
+	assert.Equal(t, ocistore.MediaTypeDSSEEnvelope, result.Envelope.MediaType)
+
+	t.Run("manifest_is_a_referrer_of_the_image", func(t *testing.T) {
+		referrers, err := registry.Predecessors(ctx, image)
+		require.NoError(t, err)
+		require.Len(t, referrers, 1)
+		assert.Equal(t, result.Manifest.Digest, referrers[0].Digest)
+
+		data, err := content.FetchAll(ctx, registry, result.Manifest)
+		require.NoError(t, err)
+		var manifest ocispec.Manifest
+		require.NoError(t, json.Unmarshal(data, &manifest))
+		assert.Equal(t, ocistore.ArtifactTypeAttestation, manifest.ArtifactType)
+		assert.Equal(t, attestation.PredicateSLSAProvenanceV1, manifest.Annotations[ocistore.AnnotationPredicateType])
+		assert.Contains(t, manifest.Annotations, ocispec.AnnotationCreated)
+	})
+
+	t.Run("envelope_round_trip", func(t *testing.T) {
+		envelopes, err := store.FetchEnvelopes(ctx, result.Manifest)
+		require.NoError(t, err)
+		require.Len(t, envelopes, 1)
+
+		statement, err := envelopes[0].Statement()
+		require.NoError(t, err)
+		assert.Equal(t, attestation.PredicateSLSAProvenanceV1, statement.PredicateType)
+	})
+
+	t.Run("tag_accumulates_envelopes", func(t *testing.T) {
+		_, err := store.Push(ctx, "latest", envelopeFor(t, image, "https://cyclonedx.org/bom"))
+		require.NoError(t, err)
+		// Pushing an identical envelope again must not duplicate the layer
+		_, err = store.Push(ctx, "latest", envelopeFor(t, image, "https://cyclonedx.org/bom"))
+		require.NoError(t, err)
+
+		tagged, err := registry.Resolve(ctx, result.Tag)
+		require.NoError(t, err)
+		envelopes, err := store.FetchEnvelopes(ctx, tagged)
+		require.NoError(t, err)
+		assert.Len(t, envelopes, 2)
+	})
+
+	t.Run("statement_for_other_image_rejected", func(t *testing.T) {
+		other := image
+		other.Digest = "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+		_, err := store.Push(ctx, "latest", envelopeFor(t, other, attestation.PredicateSLSAProvenanceV1))
+		require.Error(t, err)
+		assert.Equal(t, attestation.CodeTargetNotResolved, attestation.CodeOf(err))
+	})
+
+	t.Run("unknown_subject", func(t *testing.T) {
+		_, err := store.Push(ctx, "missing", envelopeFor(t, image, attestation.PredicateSLSAProvenanceV1))
+		assert.Equal(t, attestation.CodeTargetNotResolved, attestation.CodeOf(err))
+	})
+}
+
+func TestOCIStoreDiscover(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("referrers_grouped_by_predicate_type", func(t *testing.T) {
+		registry := memory.New()
+		image := pushImage(t, registry)
+		store := ocistore.New(registry)
+
+		for _, predicateType := range []string{
+			attestation.PredicateSLSAProvenanceV1,
+			"https://cyclonedx.org/bom",
+			"https://cosign.sigstore.dev/attestation/vuln/v1",
+		} {
+			_, err := store.Push(ctx, "latest", envelopeFor(t, image, predicateType))
+			require.NoError(t, err)
+		}
+
+		discovery, err := store.Discover(ctx, "latest")
+		require.NoError(t, err)
+		assert.Equal(t, image.Digest, discovery.Subject.Digest)
+		assert.Equal(t, []string{
+			"https://cosign.sigstore.dev/attestation/vuln/v1",
+			"https://cyclonedx.org/bom",
+			attestation.PredicateSLSAProvenanceV1,
+		}, discovery.PredicateTypes())
+
+		// Envelopes reachable through both the referrers API and the tag are reported once
+		provenance := discovery.Find(attestation.PredicateSLSAProvenanceV1)
+		require.Len(t, provenance, 1)
+		assert.Equal(t, ocistore.SourceReferrers, provenance[0].Source)
+		assert.Empty(t, discovery.Find("https://spdx.dev/Document"))
+	})
+
+	t.Run("cosign_tag_fallback", func(t *testing.T) {
+		registry := memory.New()
+		image := pushImage(t, registry)
+
+		// Simulate an attestation written by cosign: no subject, only the .att tag
+		envelope, err := json.Marshal(envelopeFor(t, image, "https://spdx.dev/Document"))
+		require.NoError(t, err)
+		layer := content.NewDescriptorFromBytes(ocistore.MediaTypeDSSEEnvelope, envelope)
+		require.NoError(t, registry.Push(ctx, layer, bytes.NewReader(envelope)))
+		manifest, err := oras.PackManifest(ctx, registry, oras.PackManifestVersion1_1, ocistore.ArtifactTypeAttestation,
+			oras.PackManifestOptions{Layers: []ocispec.Descriptor{layer}})
+		require.NoError(t, err)
+		require.NoError(t, registry.Tag(ctx, manifest, ocistore.AttestationTag(image.Digest)))
+
+		discovery, err := ocistore.New(registry).Discover(ctx, "latest")
+		require.NoError(t, err)
+		sboms := discovery.Find("https://spdx.dev/Document")
+		require.Len(t, sboms, 1)
+		assert.Equal(t, ocistore.SourceTag, sboms[0].Source)
+	})
+
+	t.Run("no_attestations", func(t *testing.T) {
+		registry := memory.New()
+		pushImage(t, registry)
+
+		discovery, err := ocistore.New(registry).Discover(ctx, "latest")
+		require.NoError(t, err)
+		assert.Empty(t, discovery.ByPredicateType)
+	})
+}
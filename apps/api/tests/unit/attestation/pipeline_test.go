@@ -0,0 +1,185 @@
+package attestation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content/memory"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/ocistore"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/pipeline"
+)
+
+// countingPublisher records pushes without a registry
+type countingPublisher struct {
+	mutex      sync.Mutex
+	references []string
+}
+
+func (p *countingPublisher) Push(_ context.Context, subjectRef string, _ *attestation.Envelope) (*ocistore.PushResult, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.references = append(p.references, subjectRef)
+	return &ocistore.PushResult{}, nil
+}
+
+func TestPipelineGeneratesSignsAndPushesBatch(t *testing.T) {
+	ctx := context.Background()
+	registry := memory.New()
+	image := pushImage(t, registry)
+	subject, err := attestation.NewSubject("registry.example.com/app", image.Digest.String())
+	require.NoError(t, err)
+	// The in-memory registry only resolves tags; remote registries resolve the digest reference
+	require.NoError(t, registry.Tag(ctx, image, "registry.example.com/app@"+image.Digest.String()))
+
+	signer, _ := newParty(t, "build-system")
+	sbom := map[string]interface{}{"bomFormat": "CycloneDX", "specVersion": "1.5"}
+
+	var scanInput *attestation.Statement
+	p, err := pipeline.New(signer, ocistore.New(registry), pipeline.Config{},
+		pipeline.Generator("provenance", attestation.NewProvenanceBuilder()),
+		pipeline.Predicate("sbom", attestation.PredicateCycloneDX, func(context.Context, pipeline.Input) (interface{}, error) {
+			return sbom, nil
+		}),
+		pipeline.Predicate("scan", attestation.PredicateVulnsV01, func(_ context.Context, in pipeline.Input) (interface{}, error) {
+			scanInput = in.Statements["sbom"]
+			return map[string]interface{}{"scanner": map[string]interface{}{"uri": "pkg:github/anchore/grype"}}, nil
+		}, "sbom"),
+	)
+	require.NoError(t, err)
+
+	result, err := p.Run(ctx, subject, testBuildContext())
+	require.NoError(t, err)
+
+	require.NotNil(t, scanInput, "the scan step receives the SBOM statement")
+	assert.Equal(t, attestation.PredicateCycloneDX, scanInput.PredicateType)
+
+	require.Len(t, result.Steps, 3)
+	for i, name := range []string{"provenance", "sbom", "scan"} {
+		step := result.Steps[i]
+		assert.Equal(t, name, step.Name)
+		require.NotNil(t, step.Envelope)
+		require.Len(t, step.Envelope.Signatures, 1)
+		assert.NoError(t, attestation.VerifyEnvelopeKey(step.Envelope, signer.Signer.Public()))
+		assert.NoError(t, step.Envelope.VerifyCanonical())
+		require.NotNil(t, step.Push)
+		assert.Equal(t, image.Digest, step.Push.Subject.Digest)
+	}
+
+	discovery, err := ocistore.New(registry).Discover(ctx, "latest")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		attestation.PredicateSLSAProvenanceV1,
+		attestation.PredicateCycloneDX,
+		attestation.PredicateVulnsV01,
+	}, discovery.PredicateTypes())
+}
+
+func TestPipelineRunsIndependentStepsConcurrently(t *testing.T) {
+	signer, _ := newParty(t, "build-system")
+
+	// Each independent step waits for the other to start, so they only
+	// finish if they run at the same time
+	var started sync.WaitGroup
+	started.Add(2)
+	barrier := func(context.Context, pipeline.Input) (interface{}, error) {
+		started.Done()
+		waited := make(chan struct{})
+		go func() { started.Wait(); close(waited) }()
+		select {
+		case <-waited:
+			return map[string]interface{}{}, nil
+		case <-time.After(5 * time.Second):
+			return nil, errors.New("steps did not run concurrently")
+		}
+	}
+
+	var received []string
+	var mutex sync.Mutex
+	p, err := pipeline.New(signer, nil, pipeline.Config{},
+		pipeline.Predicate("sbom", attestation.PredicateCycloneDX, barrier),
+		pipeline.Predicate("spdx", attestation.PredicateSPDX, barrier),
+		pipeline.Predicate("scan", attestation.PredicateVulnsV01, func(_ context.Context, in pipeline.Input) (interface{}, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			for name := range in.Statements {
+				received = append(received, name)
+			}
+			return map[string]interface{}{}, nil
+		}, "sbom", "spdx"),
+	)
+	require.NoError(t, err)
+
+	result, err := p.Run(context.Background(), testSubject(t), testBuildContext())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"sbom", "spdx"}, received)
+	assert.Nil(t, result.Steps[0].Push)
+}
+
+func TestPipelineFailureSignsAndPushesNothing(t *testing.T) {
+	signer, _ := newParty(t, "build-system")
+	publisher := &countingPublisher{}
+
+	var cancelled bool
+	slowStarted := make(chan struct{})
+	p, err := pipeline.New(signer, publisher, pipeline.Config{},
+		pipeline.Predicate("slow", attestation.PredicateSPDX, func(ctx context.Context, _ pipeline.Input) (interface{}, error) {
+			close(slowStarted)
+			select {
+			case <-ctx.Done():
+				cancelled = true
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+				return map[string]interface{}{}, nil
+			}
+		}),
+		pipeline.Predicate("sbom", attestation.PredicateCycloneDX, func(context.Context, pipeline.Input) (interface{}, error) {
+			<-slowStarted
+			return nil, errors.New("syft exited with status 1")
+		}),
+	)
+	require.NoError(t, err)
+
+	_, err = p.Run(context.Background(), testSubject(t), testBuildContext())
+	assert.ErrorContains(t, err, "step sbom: syft exited with status 1")
+	assert.True(t, cancelled, "the failure cancels steps still running")
+	assert.Empty(t, publisher.references)
+}
+
+func TestPipelineRejectsStatementsAboutOtherSubjects(t *testing.T) {
+	signer, _ := newParty(t, "build-system")
+	other, err := attestation.NewSubject("ghcr.io/owner/other", "sha256:1111111111111111111111111111111111111111111111111111111111111111")
+	require.NoError(t, err)
+
+	p, err := pipeline.New(signer, nil, pipeline.Config{}, pipeline.Step{
+		Name: "stale",
+		Generate: func(_ context.Context, in pipeline.Input) (*attestation.Statement, error) {
+			return attestation.NewProvenanceBuilder().Build([]attestation.Subject{other}, in.Build)
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = p.Run(context.Background(), testSubject(t), testBuildContext())
+	assert.Equal(t, attestation.CodeTargetNotResolved, attestation.CodeOf(err))
+}
+
+func TestPipelineValidatesSteps(t *testing.T) {
+	signer, _ := newParty(t, "build-system")
+	provenance := pipeline.Generator("provenance", attestation.NewProvenanceBuilder())
+
+	_, err := pipeline.New(signer, nil, pipeline.Config{}, provenance, provenance)
+	assert.ErrorContains(t, err, "duplicate step")
+
+	_, err = pipeline.New(signer, nil, pipeline.Config{},
+		pipeline.Generator("scai", attestation.NewSCAIBuilder(), "provenance"), provenance)
+	assert.ErrorContains(t, err, "not an earlier step")
+
+	_, err = pipeline.New(nil, nil, pipeline.Config{}, provenance)
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package attestation
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+func testBuildContext() attestation.BuildContext {
+	return attestation.BuildContext{
+		Repository:   "owner/repo",
+		Ref:          "refs/heads/main",
+		SHA:          "0123456789abcdef0123456789abcdef01234567",
+		WorkflowPath: ".github/workflows/release.yml",
+		EventName:    "push",
+		RunID:        "12345",
+		RunAttempt:   "2",
+		StartedOn:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func testSubject(t *testing.T) attestation.Subject {
+	subject, err := attestation.NewSubject("ghcr.io/owner/repo",
+		"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08")
+	require.NoError(t, err)
+	return subject
+}
+
+func TestProvenanceBuilderVersions(t *testing.T) {
+	subjects := []attestation.Subject{testSubject(t)}
+
+	t.Run("v1_default", func(t *testing.T) {
+		statement, err := attestation.NewProvenanceBuilder().Build(subjects, testBuildContext())
+		require.NoError(t, err)
+		assert.Equal(t, attestation.StatementTypeV1, statement.Type)
+		assert.Equal(t, attestation.PredicateSLSAProvenanceV1, statement.PredicateType)
+
+		predicate := statement.Predicate.(attestation.ProvenanceV1)
+		assert.Equal(t, "https://github.com/owner/repo/.github/workflows/release.yml@refs/heads/main", predicate.RunDetails.Builder.ID)
+		assert.Equal(t, "https://github.com/owner/repo/actions/runs/12345/attempts/2", predicate.RunDetails.Metadata.InvocationID)
+		assert.Equal(t, "git+https://github.com/owner/repo@refs/heads/main", predicate.BuildDefinition.ResolvedDependencies[0].URI)
+	})
+
+	t.Run("v02_selected_by_option", func(t *testing.T) {
+		builder := attestation.NewProvenanceBuilder(
+			attestation.WithProvenanceVersion(attestation.PredicateSLSAProvenanceV02),
+			attestation.WithExternalParameters(map[string]interface{}{"platform": "linux/amd64"}),
+		)
+		statement, err := builder.Build(subjects, testBuildContext())
+		require.NoError(t, err)
+		assert.Equal(t, attestation.StatementTypeV01, statement.Type)
+		assert.Equal(t, attestation.PredicateSLSAProvenanceV02, statement.PredicateType)
+		assert.Equal(t, subjects, statement.Subject)
+
+		data, err := json.Marshal(statement)
+		require.NoError(t, err)
+
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		predicate := decoded["predicate"].(map[string]interface{})
+		invocation := predicate["invocation"].(map[string]interface{})
+		assert.Equal(t, ".github/workflows/release.yml", invocation["configSource"].(map[string]interface{})["entryPoint"])
+		assert.Equal(t, "linux/amd64", invocation["parameters"].(map[string]interface{})["platform"])
+		assert.Contains(t, predicate, "materials")
+		assert.NotContains(t, predicate["metadata"], "buildFinishedOn")
+	})
+
+	t.Run("unsupported_version", func(t *testing.T) {
+		_, err := attestation.NewProvenanceBuilder(attestation.WithProvenanceVersion("https://slsa.dev/provenance/v9")).
+			Build(subjects, testBuildContext())
+		assert.Error(t, err)
+	})
+}
+
+func TestProvenanceSubjectValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		digest string
+	}{
+		{"commit_sha_as_digest", "sha256:0123456789abcdef0123456789abcdef01234567"},
+		{"missing_algorithm", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		{"unknown_algorithm", "md5:098f6bcd4621d373cade4e832627b4f6"},
+		{"non_hex", "sha256:zz86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := attestation.NewSubject("artifact", tt.digest)
+			require.Error(t, err)
+			assert.Equal(t, attestation.CodeTargetNotResolved, attestation.CodeOf(err))
+		})
+	}
+
+	t.Run("empty_subjects_rejected_for_every_version", func(t *testing.T) {
+		for _, version := range []string{attestation.PredicateSLSAProvenanceV1, attestation.PredicateSLSAProvenanceV02} {
+			_, err := attestation.NewProvenanceBuilder(attestation.WithProvenanceVersion(version)).Build(nil, testBuildContext())
+			assert.Error(t, err)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/messages"
+)
+
+func checkStatuses(result *attestation.VerificationResult) map[string]attestation.CheckStatus {
+	statuses := make(map[string]attestation.CheckStatus)
+	for _, check := range result.Checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+func TestVerifyBundleRecordsEveryCheck(t *testing.T) {
+	fixture := newBundleFixture(t)
+	bundle := fixture.bundle(t)
+
+	result, err := attestation.VerifyBundle(bundle, fixture.trust, attestation.IdentityPolicy{
+		Issuer:     testIssuer,
+		Repository: "owner/repo",
+		Branch:     "main",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]attestation.CheckStatus{
+		attestation.CheckBundle:           attestation.CheckPassed,
+		attestation.CheckTimestamps:       attestation.CheckSkipped,
+		attestation.CheckCertificateChain: attestation.CheckPassed,
+		attestation.CheckTransparencyLog:  attestation.CheckPassed,
+		attestation.CheckSignature:        attestation.CheckPassed,
+		attestation.CheckCanonicalPayload: attestation.CheckSkipped,
+		attestation.CheckIdentityPolicy:   attestation.CheckPassed,
+	}, checkStatuses(result))
+	assert.Len(t, result.Checks, 7)
+
+	require.NotNil(t, result.Certificate)
+	assert.Equal(t, "owner/repo", result.Certificate.Repository)
+	require.Len(t, result.Policy, 3)
+	for _, rule := range result.Policy {
+		assert.True(t, rule.Passed, rule.Rule)
+	}
+}
+
+func TestVerifyBundleStopsAtFirstFailedCheck(t *testing.T) {
+	fixture := newBundleFixture(t)
+	bundle := fixture.bundle(t)
+	bundle.TlogEntry.LogIndex++
+
+	result, err := attestation.VerifyBundle(bundle, fixture.trust, attestation.IdentityPolicy{})
+	require.Error(t, err)
+
+	last := result.Checks[len(result.Checks)-1]
+	assert.Equal(t, attestation.CheckTransparencyLog, last.Name)
+	assert.Equal(t, attestation.CheckFailed, last.Status)
+	assert.Equal(t, attestation.CodeRekorSETInvalid, last.ErrorCode)
+	assert.NotContains(t, checkStatuses(result), attestation.CheckSignature)
+}
+
+func TestPolicyEvaluateReportsEveryConstraint(t *testing.T) {
+	policy := attestation.IdentityPolicy{
+		Issuer:     testIssuer,
+		Repository: "owner/fork",
+		Branch:     "release",
+	}
+	identity := &attestation.CertificateIdentity{
+		Issuer:     testIssuer,
+		Repository: "owner/repo",
+		Ref:        "refs/heads/main",
+	}
+
+	results := policy.Evaluate(identity)
+	require.Len(t, results, 3)
+	assert.True(t, results[0].Passed)
+	assert.Equal(t, attestation.PolicyResult{
+		Rule:      "repository",
+		Expected:  "owner/fork",
+		Actual:    "owner/repo",
+		ErrorCode: attestation.CodeRepositoryMismatch,
+		Message:   messages.ForPolicyRule("repository", "owner/fork", "owner/repo"),
+	}, results[1])
+	assert.Equal(t, "refs/heads/release", results[2].Expected)
+	assert.Equal(t, attestation.CodeBranchMismatch, results[2].ErrorCode)
+
+	// Verify still reports the first failure
+	assert.Equal(t, attestation.CodeRepositoryMismatch, attestation.CodeOf(policy.Verify(identity)))
+}
+
+func TestReportRendersPassingVerification(t *testing.T) {
+	fixture := newBundleFixture(t)
+	bundle := fixture.bundle(t)
+	result, err := attestation.VerifyBundle(bundle, fixture.trust, attestation.IdentityPolicy{Issuer: testIssuer})
+	require.NoError(t, err)
+
+	report := attestation.NewReport(bundle, result)
+	assert.Nil(t, report.Failure)
+	require.NotNil(t, report.Rekor)
+	assert.Equal(t, int64(42), report.Rekor.LogIndex)
+	assert.True(t, report.Rekor.Verified)
+
+	data, err := report.JSON()
+	require.NoError(t, err)
+	var decoded attestation.Report
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, report.Checks, decoded.Checks)
+
+	markdown := report.Markdown()
+	assert.Contains(t, markdown, "## ✅ Verification passed: `ghcr.io/owner/repo`")
+	assert.Contains(t, markdown, "| Certificate chain | ✅ passed |")
+	assert.Contains(t, markdown, "| Canonical payload | ➖ skipped | Not required by the policy |")
+	assert.Contains(t, markdown, "| Repository | `owner/repo` |")
+	assert.Contains(t, markdown, "| issuer | `"+testIssuer+"` | `"+testIssuer+"` | ✅ passed |")
+	assert.Contains(t, markdown, "| Log index | 42 |")
+	assert.NotContains(t, markdown, "### Remediation")
+}
+
+func TestReportRendersFailureWithCodeAndRemediation(t *testing.T) {
+	fixture := newBundleFixture(t)
+	bundle := fixture.bundle(t)
+	result, err := attestation.VerifyBundle(bundle, fixture.trust, attestation.IdentityPolicy{Repository: "owner/fork"})
+	require.Error(t, err)
+
+	report := attestation.NewReport(bundle, result)
+	require.NotNil(t, report.Failure)
+	assert.Equal(t, attestation.CodeRepositoryMismatch, report.Failure.Code)
+	assert.NotEmpty(t, report.Failure.Remediation)
+
+	markdown := report.Markdown()
+	assert.Contains(t, markdown, "## ❌ Verification failed")
+	assert.Contains(t, markdown, "**"+attestation.CodeRepositoryMismatch+"**")
+	assert.Contains(t, markdown, "| Identity policy | ❌ failed | `"+attestation.CodeRepositoryMismatch+"`")
+	assert.Contains(t, markdown, "| repository | `owner/fork` | `owner/repo` | ❌ failed |")
+	assert.Contains(t, markdown, "### Remediation")
+}
+
+func TestVerifyThresholdRecordsCheck(t *testing.T) {
+	build, buildTrust := newParty(t, "build-system")
+	_, reviewerTrust := newParty(t, "security-reviewer")
+	policy := attestation.ThresholdPolicy{Threshold: 1, Signers: []attestation.TrustedSigner{buildTrust, reviewerTrust}}
+
+	statement, err := attestation.NewProvenanceBuilder().Build([]attestation.Subject{testSubject(t)}, testBuildContext())
+	require.NoError(t, err)
+	envelope, err := attestation.NewEnvelope(statement)
+	require.NoError(t, err)
+	require.NoError(t, attestation.CoSign(context.Background(), envelope, build))
+
+	result, err := attestation.VerifyThreshold(envelope, policy)
+	require.NoError(t, err)
+	assert.Equal(t, []attestation.Check{{
+		Name:   attestation.CheckThreshold,
+		Status: attestation.CheckPassed,
+		Detail: "Signed by build-system (1 of 1 required)",
+	}}, result.Checks)
+}
+
+func TestReportLocalizesMessages(t *testing.T) {
+	fixture := newBundleFixture(t)
+	bundle := fixture.bundle(t)
+	result, err := attestation.VerifyBundle(bundle, fixture.trust, attestation.IdentityPolicy{Repository: "owner/fork"})
+	require.Error(t, err)
+	require.NotNil(t, result.Message)
+	assert.Equal(t, messages.RepositoryMismatch, result.Message.ID)
+
+	report := attestation.NewReport(bundle, result)
+	report.Localize(messages.Default(), "en-GB")
+	assert.Equal(t, "en", report.Failure.Localized.Locale)
+	assert.Equal(t, "The artifact was signed from a repository the policy doesn't allow.", report.Failure.Localized.Text)
+	assert.Empty(t, result.Message.Text, "the result's own message is left unrendered")
+
+	markdown := report.Markdown()
+	assert.Contains(t, markdown, "**"+attestation.CodeRepositoryMismatch+"**: The artifact was signed from a repository the policy doesn't allow.")
+	assert.Contains(t, markdown, "> The artifact was signed from owner/repo, but the policy requires owner/fork.")
+}
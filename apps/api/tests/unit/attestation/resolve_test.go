@@ -0,0 +1,163 @@
+package attestation
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content/memory"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/ocistore"
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+)
+
+const resolvedDigest = "sha256:" + "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+// newManifestServer serves HEAD requests for one tag, requiring basic auth
+func newManifestServer(t *testing.T, repository, tag, username, password string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != username || pass != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/v2/"+repository+"/manifests/"+tag {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		w.Header().Set("Docker-Content-Digest", resolvedDigest)
+		w.Header().Set("Content-Length", "512")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResolveDigest(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("digest_reference_is_used_as_is", func(t *testing.T) {
+		subject, err := ocistore.ResolveDigest(ctx, "ghcr.io/acme/app@"+resolvedDigest, ocistore.RemoteOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "ghcr.io/acme/app", subject.Name)
+		assert.Equal(t, strings.TrimPrefix(resolvedDigest, "sha256:"), subject.Digest["sha256"])
+	})
+
+	t.Run("tag_resolves_with_static_credentials", func(t *testing.T) {
+		server := newManifestServer(t, "acme/app", "v1.2.0", "actor", "token")
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		subject, err := ocistore.ResolveDigest(ctx, host+"/acme/app:v1.2.0", ocistore.RemoteOptions{
+			Username:  "actor",
+			Password:  "token",
+			PlainHTTP: true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, host+"/acme/app", subject.Name)
+		assert.Equal(t, strings.TrimPrefix(resolvedDigest, "sha256:"), subject.Digest["sha256"])
+	})
+
+	t.Run("tag_resolves_with_credential_source", func(t *testing.T) {
+		server := newManifestServer(t, "acme/app", "v1.2.0", "AWS", "ecr-password")
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		var asked string
+		source := credentialFunc(func(ctx context.Context, registry string) (string, string, error) {
+			asked = registry
+			return "AWS", "ecr-password", nil
+		})
+		subject, err := ocistore.ResolveDigest(ctx, host+"/acme/app:v1.2.0", ocistore.RemoteOptions{Credentials: source, PlainHTTP: true})
+		require.NoError(t, err)
+		assert.Equal(t, host, asked)
+		assert.Equal(t, host+"/acme/app", subject.Name)
+	})
+
+	t.Run("tag_resolves_with_docker_config_credentials", func(t *testing.T) {
+		server := newManifestServer(t, "acme/app", "latest", "docker-user", "docker-pass")
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		dir := t.TempDir()
+		auth := base64.StdEncoding.EncodeToString([]byte("docker-user:docker-pass"))
+		config := `{"auths":{"` + host + `":{"auth":"` + auth + `"}}}`
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600))
+		t.Setenv("DOCKER_CONFIG", dir)
+
+		subject, err := ocistore.ResolveDigest(ctx, host+"/acme/app", ocistore.RemoteOptions{PlainHTTP: true})
+		require.NoError(t, err)
+		assert.Equal(t, strings.TrimPrefix(resolvedDigest, "sha256:"), subject.Digest["sha256"])
+	})
+
+	t.Run("unknown_tag_is_not_resolved", func(t *testing.T) {
+		server := newManifestServer(t, "acme/app", "v1.2.0", "actor", "token")
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		_, err := ocistore.ResolveDigest(ctx, host+"/acme/app:missing", ocistore.RemoteOptions{
+			Username:  "actor",
+			Password:  "token",
+			PlainHTTP: true,
+		})
+		assert.Equal(t, attestation.CodeTargetNotResolved, attestation.CodeOf(err))
+	})
+
+	t.Run("invalid_reference", func(t *testing.T) {
+		_, err := ocistore.ResolveDigest(ctx, "app:latest", ocistore.RemoteOptions{})
+		assert.Equal(t, attestation.CodeTargetNotResolved, attestation.CodeOf(err))
+	})
+}
+
+func TestResolveSubjectFromStore(t *testing.T) {
+	registry := memory.New()
+	image := pushImage(t, registry)
+
+	subject, err := ocistore.New(registry).ResolveSubject(context.Background(), "registry.example.com/app", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, image.Digest.Encoded(), subject.Digest["sha256"])
+}
+
+// credentialFunc adapts a function to ocistore.CredentialSource
+type credentialFunc func(ctx context.Context, registry string) (string, string, error)
+
+func (f credentialFunc) Credential(ctx context.Context, registry string) (string, string, error) {
+	return f(ctx, registry)
+}
+
+func TestRemoteOptionsFromEnv(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "ghs_token")
+	t.Setenv("GITHUB_ACTOR", "octocat")
+
+	opts := ocistore.RemoteOptionsFromEnv(ocistore.GHCRRegistry)
+	assert.Equal(t, "octocat", opts.Username)
+	assert.Equal(t, "ghs_token", opts.Password)
+
+	assert.Empty(t, ocistore.RemoteOptionsFromEnv("registry.example.com").Password)
+}
+
+func TestResolveDigestRetryBudget(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	budget := circuit.NewBudget(circuit.BudgetConfig{Ratio: 0, MinRetries: 0, Window: time.Minute})
+	_, err := ocistore.ResolveDigest(context.Background(), host+"/acme/app:v1", ocistore.RemoteOptions{
+		PlainHTTP:   true,
+		RetryBudget: budget,
+	})
+	assert.ErrorIs(t, err, circuit.ErrRetryBudgetExhausted)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, int64(1), budget.Stats().Rejected)
+}
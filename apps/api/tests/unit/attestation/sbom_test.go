@@ -0,0 +1,86 @@
+package attestation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+const (
+	cycloneDXDocument = `{"bomFormat": "CycloneDX", "specVersion": "1.5",
+  "components": [{"name": "lodash", "version": "4.17.21", "purl": "pkg:npm/lodash@4.17.21"}]}`
+	spdxDocument = `{"spdxVersion": "SPDX-2.3", "name": "app",
+  "packages": [{"name": "lodash", "versionInfo": "4.17.21"}]}`
+)
+
+func TestSignSBOMKeepsTheDocumentAsIs(t *testing.T) {
+	signer, _ := newParty(t, "build-system")
+
+	for document, payloadType := range map[string]string{
+		cycloneDXDocument: attestation.PayloadTypeCycloneDX,
+		spdxDocument:      attestation.PayloadTypeSPDX,
+	} {
+		envelope, err := attestation.SignSBOM(context.Background(), []byte(document), signer)
+		require.NoError(t, err)
+		assert.Equal(t, payloadType, envelope.PayloadType)
+		payload, err := envelope.DecodePayload()
+		require.NoError(t, err)
+		assert.Equal(t, document, string(payload))
+
+		decoded, err := attestation.VerifySBOM(envelope, signer.Signer.Public())
+		require.NoError(t, err)
+		require.Len(t, decoded.Components, 1)
+		assert.Equal(t, "lodash", decoded.Components[0].Name)
+	}
+}
+
+func TestSignSBOMRejectsOtherDocuments(t *testing.T) {
+	signer, _ := newParty(t, "build-system")
+
+	_, err := attestation.SignSBOM(context.Background(), []byte(`{"_type": "https://in-toto.io/Statement/v1"}`), signer)
+	assert.Equal(t, attestation.CodeSBOMSigningFailed, attestation.CodeOf(err))
+	assert.ErrorIs(t, err, sbom.ErrUnknownFormat)
+}
+
+func TestVerifySBOMRejectsWrongKeyAndRetypedEnvelopes(t *testing.T) {
+	signer, _ := newParty(t, "build-system")
+	other, _ := newParty(t, "security-reviewer")
+	envelope, err := attestation.SignSBOM(context.Background(), []byte(cycloneDXDocument), signer)
+	require.NoError(t, err)
+
+	_, err = attestation.VerifySBOM(envelope, other.Signer.Public())
+	assert.Equal(t, attestation.CodeVerificationFailed, attestation.CodeOf(err))
+
+	envelope.PayloadType = attestation.PayloadTypeSPDX
+	_, err = attestation.VerifySBOM(envelope, signer.Signer.Public())
+	assert.ErrorContains(t, err, "does not match its cyclonedx document")
+}
+
+func TestSBOMFileRoundTrip(t *testing.T) {
+	signer, _ := newParty(t, "build-system")
+	path := filepath.Join(t.TempDir(), "sbom.cdx.json")
+	require.NoError(t, os.WriteFile(path, []byte(cycloneDXDocument), 0o644))
+
+	_, err := attestation.VerifySBOMFile(path, signer.Signer.Public())
+	assert.Equal(t, attestation.CodeAttestationNotFound, attestation.CodeOf(err))
+
+	_, err = attestation.SignSBOMFile(context.Background(), path, signer)
+	require.NoError(t, err)
+	assert.FileExists(t, attestation.SBOMEnvelopePath(path))
+
+	decoded, err := attestation.VerifySBOMFile(path, signer.Signer.Public())
+	require.NoError(t, err)
+	assert.Equal(t, sbom.FormatCycloneDX, decoded.Format)
+
+	// Any change to the published file, even whitespace, fails verification
+	require.NoError(t, os.WriteFile(path, []byte(cycloneDXDocument+"\n"), 0o644))
+	_, err = attestation.VerifySBOMFile(path, signer.Signer.Public())
+	assert.Equal(t, attestation.CodeBlobDigestMismatch, attestation.CodeOf(err))
+}
@@ -0,0 +1,74 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+func TestSCAIBuilder(t *testing.T) {
+	subjects := []attestation.Subject{testSubject(t)}
+
+	t.Run("attribute_report", func(t *testing.T) {
+		rebuild := attestation.ResourceDescriptor{
+			Name:   "rebuild.intoto.jsonl",
+			Digest: attestation.DigestSet{"sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		}
+		builder := attestation.NewSCAIBuilder(attestation.WithAttributes(
+			attestation.HardenedBuildAttribute("-fstack-protector-strong", "-D_FORTIFY_SOURCE=3"),
+			attestation.ReproducibleAttribute(rebuild),
+		))
+
+		statement, err := builder.Build(subjects, testBuildContext())
+		require.NoError(t, err)
+		assert.Equal(t, attestation.StatementTypeV1, statement.Type)
+		assert.Equal(t, attestation.PredicateSCAIV02, statement.PredicateType)
+		assert.Equal(t, subjects, statement.Subject)
+
+		data, err := json.Marshal(statement.Predicate)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"attributes": [
+				{"attribute": "HARDENED_BUILD", "conditions": {"flags": ["-fstack-protector-strong", "-D_FORTIFY_SOURCE=3"]}},
+				{"attribute": "REPRODUCIBLE", "evidence": {"name": "rebuild.intoto.jsonl", "digest": {"sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}}}
+			],
+			"producer": {"uri": "https://github.com/owner/repo/.github/workflows/release.yml@refs/heads/main"}
+		}`, string(data))
+	})
+
+	t.Run("requires_attributes", func(t *testing.T) {
+		_, err := attestation.NewSCAIBuilder().Build(subjects, testBuildContext())
+		assert.Equal(t, attestation.CodeSigningFailed, attestation.CodeOf(err))
+	})
+
+	t.Run("rejects_empty_evidence", func(t *testing.T) {
+		builder := attestation.NewSCAIBuilder(attestation.WithAttributes(
+			attestation.ReproducibleAttribute(attestation.ResourceDescriptor{Name: "rebuild"}),
+		))
+		_, err := builder.Build(subjects, testBuildContext())
+		assert.Equal(t, attestation.CodeSigningFailed, attestation.CodeOf(err))
+	})
+
+	t.Run("signs_blobs", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		signer := &attestation.CryptoSigner{Signer: key, ID: "release-key"}
+
+		builder := attestation.NewSCAIBuilder(attestation.WithAttributes(attestation.HardenedBuildAttribute("-fPIE")))
+		envelope, _, err := attestation.SignBlobs(context.Background(), signer, builder, testBuildContext(),
+			writeBlob(t, "keystone", "binary"))
+		require.NoError(t, err)
+
+		statement, err := envelope.Statement()
+		require.NoError(t, err)
+		assert.Equal(t, attestation.PredicateSCAIV02, statement.PredicateType)
+	})
+}
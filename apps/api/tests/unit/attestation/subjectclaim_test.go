@@ -0,0 +1,92 @@
+package attestation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+func TestParseSubjectClaim(t *testing.T) {
+	tests := []struct {
+		sub         string
+		kind        attestation.SubjectKind
+		repository  string
+		ref         string
+		environment string
+	}{
+		{"repo:octo-org/app:ref:refs/heads/main", attestation.SubjectRef, "octo-org/app", "refs/heads/main", ""},
+		{"repo:octo-org/app:ref:refs/tags/v1.2.0", attestation.SubjectRef, "octo-org/app", "refs/tags/v1.2.0", ""},
+		{"repo:octo-org/app:environment:production", attestation.SubjectEnvironment, "octo-org/app", "", "production"},
+		{"repo:octo-org/app:pull_request", attestation.SubjectPullRequest, "octo-org/app", "", ""},
+		{"repo:octo-org/app:context:prod:job_workflow_ref:octo-org/ci/.github/workflows/sign.yml@refs/heads/main", attestation.SubjectCustom, "octo-org/app", "", ""},
+		{"project_path:group/project:ref_type:branch:ref:main", attestation.SubjectCustom, "", "main", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sub, func(t *testing.T) {
+			subject, err := attestation.ParseSubjectClaim(tt.sub)
+			require.NoError(t, err)
+			assert.Equal(t, tt.kind, subject.Kind)
+			assert.Equal(t, tt.repository, subject.Repository)
+			assert.Equal(t, tt.ref, subject.Ref)
+			assert.Equal(t, tt.environment, subject.Environment)
+			assert.Equal(t, tt.sub, subject.String())
+		})
+	}
+
+	subject, err := attestation.ParseSubjectClaim("repo:octo-org/app:ref:refs/heads/release/2.1")
+	require.NoError(t, err)
+	assert.Equal(t, "octo-org", subject.Owner())
+	assert.Equal(t, "app", subject.Name())
+	branch, isBranch := subject.Branch()
+	assert.True(t, isBranch)
+	assert.Equal(t, "release/2.1", branch)
+	_, isTag := subject.Tag()
+	assert.False(t, isTag)
+	assert.False(t, subject.PullRequest())
+
+	pullRequest, err := attestation.ParseSubjectClaim("repo:octo-org/app:pull_request")
+	require.NoError(t, err)
+	assert.True(t, pullRequest.PullRequest())
+
+	for _, malformed := range []string{"", "123456789", "repo:octo-org/app:ref", "repo:octo-org:ref:refs/heads/main", "repo:a/b:repo:c/d", "repo:a/b::x"} {
+		_, err := attestation.ParseSubjectClaim(malformed)
+		assert.Equal(t, attestation.CodeMissingSubject, attestation.CodeOf(err), malformed)
+	}
+}
+
+func TestIssuerProfileIdentityFromSubject(t *testing.T) {
+	profile, err := attestation.LookupIssuerProfile("")
+	require.NoError(t, err)
+
+	claims := testClaims()
+	delete(claims, "environment")
+	claims["sub"] = "repo:owner/repo:environment:staging"
+	identity, err := profile.Identity(claims)
+	require.NoError(t, err)
+	assert.Equal(t, "staging", identity.Environment)
+
+	claims["sub"] = "repo:attacker/repo:environment:staging"
+	_, err = profile.Identity(claims)
+	assert.Equal(t, attestation.CodeMissingSubject, attestation.CodeOf(err))
+}
+
+func TestSigningConstraintsUseSubject(t *testing.T) {
+	constraints := &attestation.SigningConstraints{
+		Repositories: []string{"org/*"},
+		Environments: []string{"production"},
+	}
+	assert.NoError(t, constraints.Authorize(attestation.Claims{"sub": "repo:org/api:environment:production"}))
+
+	err := constraints.Authorize(attestation.Claims{"sub": "repo:org/api:environment:staging"})
+	assert.Equal(t, attestation.CodeEnvironmentNotAllowed, attestation.CodeOf(err))
+
+	err = constraints.Authorize(attestation.Claims{"sub": "repo:fork/api:environment:production"})
+	assert.Equal(t, attestation.CodeRepositoryNotAllowed, attestation.CodeOf(err))
+
+	// Dedicated claims take precedence over the subject
+	err = constraints.Authorize(attestation.Claims{"sub": "repo:org/api:environment:production", "environment": "staging"})
+	assert.Equal(t, attestation.CodeEnvironmentNotAllowed, attestation.CodeOf(err))
+}
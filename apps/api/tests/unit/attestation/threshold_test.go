@@ -0,0 +1,130 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+// newParty returns a signer and the trusted signer entry for its key
+func newParty(t *testing.T, name string) (*attestation.CryptoSigner, attestation.TrustedSigner) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	return &attestation.CryptoSigner{Signer: key, ID: name},
+		attestation.TrustedSigner{Name: name, PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))}
+}
+
+func TestThresholdSigning(t *testing.T) {
+	ctx := context.Background()
+	build, buildTrust := newParty(t, "build-system")
+	reviewer, reviewerTrust := newParty(t, "security-reviewer")
+	outsider, _ := newParty(t, "outsider")
+	policy := attestation.ThresholdPolicy{Threshold: 2, Signers: []attestation.TrustedSigner{buildTrust, reviewerTrust}}
+
+	statement, err := attestation.NewProvenanceBuilder().Build([]attestation.Subject{testSubject(t)}, testBuildContext())
+	require.NoError(t, err)
+	newEnvelope := func() *attestation.Envelope {
+		envelope, err := attestation.NewEnvelope(statement)
+		require.NoError(t, err)
+		return envelope
+	}
+
+	t.Run("accumulated_signatures_meet_the_threshold", func(t *testing.T) {
+		envelope := newEnvelope()
+		require.NoError(t, attestation.CoSign(ctx, envelope, build))
+		require.NoError(t, attestation.CoSign(ctx, envelope, build)) // Retried step
+		require.Len(t, envelope.Signatures, 1)
+
+		result, err := attestation.VerifyThreshold(envelope, policy)
+		assert.Equal(t, attestation.CodeThresholdNotMet, attestation.CodeOf(err))
+		assert.False(t, result.Valid)
+		assert.Contains(t, result.ErrorMessage, "missing security-reviewer")
+		assert.NotEmpty(t, result.Remediation)
+
+		require.NoError(t, attestation.CoSign(ctx, envelope, reviewer))
+		result, err = attestation.VerifyThreshold(envelope, policy)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, []string{"build-system", "security-reviewer"}, result.Signers)
+	})
+
+	t.Run("separately_signed_envelopes_merge", func(t *testing.T) {
+		fromBuild, fromReviewer := newEnvelope(), newEnvelope()
+		require.NoError(t, attestation.SignEnvelope(ctx, fromBuild, build))
+		require.NoError(t, attestation.SignEnvelope(ctx, fromReviewer, reviewer))
+
+		merged, err := attestation.MergeEnvelopes(fromBuild, fromReviewer, fromBuild)
+		require.NoError(t, err)
+		assert.Len(t, merged.Signatures, 2)
+
+		result, err := attestation.VerifyThreshold(merged, policy)
+		require.NoError(t, err)
+		assert.Len(t, result.Signers, 2)
+	})
+
+	t.Run("untrusted_signatures_do_not_count", func(t *testing.T) {
+		envelope := newEnvelope()
+		require.NoError(t, attestation.CoSign(ctx, envelope, build))
+		require.NoError(t, attestation.CoSign(ctx, envelope, outsider))
+
+		result, err := attestation.VerifyThreshold(envelope, policy)
+		assert.Equal(t, attestation.CodeThresholdNotMet, attestation.CodeOf(err))
+		assert.Equal(t, []string{"build-system"}, result.Signers)
+
+		oneOfTwo := policy
+		oneOfTwo.Threshold = 1
+		_, err = attestation.VerifyThreshold(envelope, oneOfTwo)
+		assert.NoError(t, err)
+	})
+
+	t.Run("envelopes_over_different_payloads_do_not_merge", func(t *testing.T) {
+		other, err := attestation.NewEnvelope(&attestation.Statement{
+			Type:          attestation.StatementTypeV1,
+			Subject:       []attestation.Subject{testSubject(t)},
+			PredicateType: "https://example.com/other",
+			Predicate:     map[string]interface{}{},
+		})
+		require.NoError(t, err)
+
+		_, err = attestation.MergeEnvelopes(newEnvelope(), other)
+		assert.Equal(t, attestation.CodeSigningFailed, attestation.CodeOf(err))
+	})
+}
+
+func TestThresholdPolicyValidate(t *testing.T) {
+	_, trusted := newParty(t, "build-system")
+	_, other := newParty(t, "security-reviewer")
+	// The same key spelled differently, under another name
+	block, _ := pem.Decode([]byte(trusted.PublicKey))
+	block.Headers = map[string]string{"Comment": "build key"}
+	alias := attestation.TrustedSigner{Name: "release-manager", PublicKey: string(pem.EncodeToMemory(block))}
+
+	for name, policy := range map[string]attestation.ThresholdPolicy{
+		"zero_threshold":   {Threshold: 0, Signers: []attestation.TrustedSigner{trusted}},
+		"unreachable":      {Threshold: 2, Signers: []attestation.TrustedSigner{trusted}},
+		"duplicate_signer": {Threshold: 1, Signers: []attestation.TrustedSigner{trusted, trusted}},
+		"duplicate_key":    {Threshold: 2, Signers: []attestation.TrustedSigner{trusted, other, alias}},
+		"unnamed_signer":   {Threshold: 1, Signers: []attestation.TrustedSigner{{PublicKey: trusted.PublicKey}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, attestation.CodeThresholdNotMet, attestation.CodeOf(policy.Validate()))
+		})
+	}
+
+	invalid := attestation.ThresholdPolicy{Threshold: 1, Signers: []attestation.TrustedSigner{{Name: "build-system", PublicKey: "not a key"}}}
+	assert.Equal(t, attestation.CodePublicKeyExtraction, attestation.CodeOf(invalid.Validate()))
+	valid := attestation.ThresholdPolicy{Threshold: 2, Signers: []attestation.TrustedSigner{trusted, other}}
+	assert.NoError(t, valid.Validate())
+}
@@ -0,0 +1,299 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+var (
+	testOIDSHA256      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	testOIDECDSASHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type testMessageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type testTimeStampReq struct {
+	Version        int
+	MessageImprint testMessageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional,default:false"`
+}
+
+type testTSTInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint testMessageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+type testSignerInfo struct {
+	Version int
+	SID     struct {
+		Issuer asn1.RawValue
+		Serial *big.Int
+	}
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type testSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo struct {
+		EContentType asn1.ObjectIdentifier
+		EContent     asn1.RawValue
+	}
+	Certificates asn1.RawValue
+	SignerInfos  []testSignerInfo `asn1:"set"`
+}
+
+// fakeTSA is an RFC 3161 timestamp authority with its own root
+type fakeTSA struct {
+	root    *x509.Certificate
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	genTime time.Time
+	tamper  func(tst *testTSTInfo)
+}
+
+func newFakeTSA(t *testing.T, genTime time.Time) *fakeTSA {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-tsa-root"},
+		NotBefore:             genTime.Add(-24 * time.Hour),
+		NotAfter:              genTime.Add(10 * 365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	root, err := x509.ParseCertificate(rootDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test-tsa"},
+		NotBefore:    genTime.Add(-time.Hour),
+		NotAfter:     genTime.Add(5 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, root, &key.PublicKey, rootKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certDER)
+	require.NoError(t, err)
+
+	return &fakeTSA{root: root, cert: cert, key: key, genTime: genTime}
+}
+
+func (f *fakeTSA) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(f.root)
+	return pool
+}
+
+func contextTagged(inner []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: inner}
+}
+
+// token builds a CMS SignedData timestamp token for a request
+func (f *fakeTSA) token(t *testing.T, req testTimeStampReq) []byte {
+	tst := testTSTInfo{
+		Version:        1,
+		Policy:         asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 4146, 2, 3},
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   big.NewInt(7),
+		GenTime:        f.genTime.UTC(),
+		Nonce:          req.Nonce,
+	}
+	if f.tamper != nil {
+		f.tamper(&tst)
+	}
+	content, err := asn1.Marshal(tst)
+	require.NoError(t, err)
+	contentDigest := sha256.Sum256(content)
+
+	contentType, err := asn1.Marshal(struct {
+		Type   asn1.ObjectIdentifier
+		Values []asn1.ObjectIdentifier `asn1:"set"`
+	}{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}, []asn1.ObjectIdentifier{{1, 2, 840, 113549, 1, 9, 16, 1, 4}}})
+	require.NoError(t, err)
+	messageDigest, err := asn1.Marshal(struct {
+		Type   asn1.ObjectIdentifier
+		Values [][]byte `asn1:"set"`
+	}{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}, [][]byte{contentDigest[:]}})
+	require.NoError(t, err)
+	signedAttrs, err := asn1.MarshalWithParams([]asn1.RawValue{{FullBytes: contentType}, {FullBytes: messageDigest}}, "set")
+	require.NoError(t, err)
+
+	attrsDigest := sha256.Sum256(signedAttrs)
+	signature, err := ecdsa.SignASN1(rand.Reader, f.key, attrsDigest[:])
+	require.NoError(t, err)
+
+	signer := testSignerInfo{
+		Version:            1,
+		DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: testOIDSHA256},
+		SignedAttrs:        asn1.RawValue{FullBytes: append([]byte{0xa0}, signedAttrs[1:]...)},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: testOIDECDSASHA256},
+		Signature:          signature,
+	}
+	signer.SID.Issuer = asn1.RawValue{FullBytes: f.cert.RawIssuer}
+	signer.SID.Serial = f.cert.SerialNumber
+
+	eContent, err := asn1.Marshal(content)
+	require.NoError(t, err)
+	sd := testSignedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: testOIDSHA256}},
+		Certificates:     contextTagged(f.cert.Raw),
+		SignerInfos:      []testSignerInfo{signer},
+	}
+	sd.EncapContentInfo.EContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	sd.EncapContentInfo.EContent = contextTagged(eContent)
+	sdDER, err := asn1.Marshal(sd)
+	require.NoError(t, err)
+
+	token, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}, contextTagged(sdDER)})
+	require.NoError(t, err)
+	return token
+}
+
+// server serves timestamp responses over HTTP
+func (f *fakeTSA) server(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/timestamp-query", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req testTimeStampReq
+		_, err = asn1.Unmarshal(body, &req)
+		require.NoError(t, err)
+
+		resp, err := asn1.Marshal(struct {
+			Status struct{ Status int }
+			Token  asn1.RawValue
+		}{Token: asn1.RawValue{FullBytes: f.token(t, req)}})
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		_, _ = w.Write(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTimestampAuthorityRoundTrip(t *testing.T) {
+	genTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	tsa := newFakeTSA(t, genTime)
+	signature := []byte("envelope-signature")
+
+	token, err := attestation.NewTimestampAuthority(tsa.server(t).URL).Timestamp(context.Background(), signature)
+	require.NoError(t, err)
+
+	ts, err := attestation.VerifyTimestamp(token, signature, tsa.roots())
+	require.NoError(t, err)
+	assert.True(t, genTime.Equal(ts.Time))
+	assert.Equal(t, int64(7), ts.SerialNumber.Int64())
+
+	_, err = attestation.VerifyTimestamp(token, []byte("other-signature"), tsa.roots())
+	assert.Equal(t, attestation.CodeTimestampInvalid, attestation.CodeOf(err), "different signature")
+
+	_, err = attestation.VerifyTimestamp(token, signature, newFakeTSA(t, genTime).roots())
+	assert.Equal(t, attestation.CodeTimestampInvalid, attestation.CodeOf(err), "untrusted authority")
+}
+
+func TestTimestampAuthorityErrors(t *testing.T) {
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, _ := asn1.Marshal(struct{ Status struct{ Status int } }{Status: struct{ Status int }{Status: 2}})
+		_, _ = w.Write(resp)
+	}))
+	defer rejecting.Close()
+	_, err := attestation.NewTimestampAuthority(rejecting.URL).Timestamp(context.Background(), []byte("sig"))
+	assert.Equal(t, attestation.CodeTimestampFailed, attestation.CodeOf(err))
+
+	// A replayed response with a different nonce is rejected
+	tsa := newFakeTSA(t, time.Now())
+	tsa.tamper = func(tst *testTSTInfo) { tst.Nonce = big.NewInt(1) }
+	_, err = attestation.NewTimestampAuthority(tsa.server(t).URL).Timestamp(context.Background(), []byte("sig"))
+	assert.Equal(t, attestation.CodeTimestampFailed, attestation.CodeOf(err))
+}
+
+func TestVerifyBundleWithTimestamp(t *testing.T) {
+	fixture := newBundleFixture(t)
+	tsa := newFakeTSA(t, fixture.issuedAt.Add(30*time.Second))
+
+	trust := fixture.trust
+	trust.TimestampAuthorities = []string{pemCertificate(tsa.root)}
+	withTimestamp := func(t *testing.T) *attestation.Bundle {
+		bundle := fixture.bundle(t)
+		require.NoError(t, bundle.AddTimestamp(context.Background(), attestation.NewTimestampAuthority(tsa.server(t).URL)))
+		return bundle
+	}
+
+	// The timestamp, not the integrated time, vouches for certificate validity
+	bundle := withTimestamp(t)
+	bundle.TlogEntry.IntegratedTime = fixture.issuedAt.Add(time.Hour).Unix()
+	bundle.TlogEntry.SignedEntryTimestamp = fixture.signedSET(bundle.TlogEntry)
+	result, err := attestation.VerifyBundle(bundle, trust, attestation.IdentityPolicy{Issuer: testIssuer})
+	require.NoError(t, err)
+	assert.True(t, result.TimestampVerified)
+	assert.True(t, result.RekorVerified)
+
+	// Without a transparency log entry the timestamp alone suffices
+	bundle = withTimestamp(t)
+	bundle.TlogEntry = nil
+	result, err = attestation.VerifyBundle(bundle, trust, attestation.IdentityPolicy{Issuer: testIssuer})
+	require.NoError(t, err)
+	assert.False(t, result.RekorVerified)
+
+	bundle = withTimestamp(t)
+	otherTSA := fixture.trust
+	otherTSA.TimestampAuthorities = []string{pemCertificate(newFakeTSA(t, time.Now()).root)}
+	_, err = attestation.VerifyBundle(bundle, otherTSA, attestation.IdentityPolicy{})
+	assert.Equal(t, attestation.CodeTimestampInvalid, attestation.CodeOf(err))
+
+	bundle = withTimestamp(t)
+	bundle.Timestamps = append(bundle.Timestamps, base64.StdEncoding.EncodeToString([]byte("garbage")))
+	_, err = attestation.VerifyBundle(bundle, trust, attestation.IdentityPolicy{})
+	assert.Equal(t, attestation.CodeTimestampInvalid, attestation.CodeOf(err))
+
+	// A timestamp after the certificate expired does not extend its validity
+	late := newFakeTSA(t, fixture.issuedAt.Add(time.Hour))
+	bundle = fixture.bundle(t)
+	lateTrust := fixture.trust
+	lateTrust.TimestampAuthorities = []string{pemCertificate(late.root)}
+	require.NoError(t, bundle.AddTimestamp(context.Background(), attestation.NewTimestampAuthority(late.server(t).URL)))
+	_, err = attestation.VerifyBundle(bundle, lateTrust, attestation.IdentityPolicy{})
+	assert.Equal(t, attestation.CodeCertificateUntrusted, attestation.CodeOf(err))
+}
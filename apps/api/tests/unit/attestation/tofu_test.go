@@ -0,0 +1,113 @@
+package attestation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/tofu"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+)
+
+// verifiedResult verifies a fixture bundle signed by the release workflow at ref
+func verifiedResult(t *testing.T) *attestation.VerificationResult {
+	fixture := newBundleFixture(t)
+	result, err := attestation.VerifyBundle(fixture.bundle(t), fixture.trust, attestation.IdentityPolicy{})
+	require.NoError(t, err)
+	return result
+}
+
+func lastCheck(result *attestation.VerificationResult) attestation.Check {
+	return result.Checks[len(result.Checks)-1]
+}
+
+func TestTOFUPinsFirstIdentityAcrossRefs(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	pins := tofu.NewStore(migratedDB(t), tofu.Config{Mode: tofu.ModeEnforce, Clock: fake})
+
+	first := verifiedResult(t)
+	pin, err := pins.Check(ctx, "ghcr.io/owner/repo", first, "first")
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/owner/repo/.github/workflows/release.yml", pin.Identity)
+	assert.Equal(t, testIssuer, pin.Issuer)
+	assert.Equal(t, attestation.CheckPassed, lastCheck(first).Status)
+	assert.Contains(t, lastCheck(first).Detail, "on first use")
+
+	// The same workflow signing a release tag still matches
+	fake.Advance(time.Hour)
+	release := verifiedResult(t)
+	release.Certificate.SAN = "https://github.com/owner/repo/.github/workflows/release.yml@refs/tags/v1.2.0"
+	pin, err = pins.Check(ctx, "ghcr.io/owner/repo", release, "release")
+	require.NoError(t, err)
+	assert.True(t, release.Valid)
+	assert.Equal(t, "first", pin.AttestationID)
+
+	stored, err := pins.Get(ctx, "ghcr.io/owner/repo")
+	require.NoError(t, err)
+	assert.Equal(t, fake.Now(), stored.LastSeen.UTC())
+}
+
+func TestTOFUEnforceRejectsChangedIdentity(t *testing.T) {
+	ctx := context.Background()
+	pins := tofu.NewStore(migratedDB(t), tofu.Config{Mode: tofu.ModeEnforce})
+	_, err := pins.Check(ctx, "ghcr.io/owner/repo", verifiedResult(t), "first")
+	require.NoError(t, err)
+
+	forked := verifiedResult(t)
+	forked.Certificate.SAN = "https://github.com/attacker/repo/.github/workflows/release.yml@refs/heads/main"
+	_, err = pins.Check(ctx, "ghcr.io/owner/repo", forked, "forked")
+	assert.Equal(t, attestation.CodeIdentityChanged, attestation.CodeOf(err))
+	assert.False(t, forked.Valid)
+	assert.Equal(t, attestation.CodeIdentityChanged, forked.ErrorCode)
+	assert.NotEmpty(t, forked.Remediation)
+	assert.Equal(t, attestation.CheckFailed, lastCheck(forked).Status)
+
+	// Other artifacts have their own pins, and a reset re-pins on next use
+	_, err = pins.Check(ctx, "ghcr.io/attacker/repo", verifiedResult(t), "other")
+	require.NoError(t, err)
+	require.NoError(t, pins.Reset(ctx, "ghcr.io/owner/repo"))
+	repinned := verifiedResult(t)
+	repinned.Certificate.SAN = forked.Certificate.SAN
+	_, err = pins.Check(ctx, "ghcr.io/owner/repo", repinned, "repinned")
+	require.NoError(t, err)
+}
+
+func TestTOFUWarnKeepsResultValid(t *testing.T) {
+	ctx := context.Background()
+	pins := tofu.NewStore(migratedDB(t), tofu.Config{Mode: tofu.ModeWarn})
+	_, err := pins.Check(ctx, "ghcr.io/owner/repo", verifiedResult(t), "first")
+	require.NoError(t, err)
+
+	changed := verifiedResult(t)
+	changed.Certificate.Issuer = "https://gitlab.com"
+	pin, err := pins.Check(ctx, "ghcr.io/owner/repo", changed, "changed")
+	assert.Equal(t, attestation.CodeIdentityChanged, attestation.CodeOf(err))
+	assert.Equal(t, testIssuer, pin.Issuer)
+	assert.True(t, changed.Valid)
+	assert.Equal(t, attestation.CodeIdentityChanged, lastCheck(changed).ErrorCode)
+}
+
+func TestTOFUOffAndUnpinnableResults(t *testing.T) {
+	ctx := context.Background()
+	db := migratedDB(t)
+
+	off := tofu.NewStore(db, tofu.Config{})
+	result := verifiedResult(t)
+	pin, err := off.Check(ctx, "ghcr.io/owner/repo", result, "first")
+	require.NoError(t, err)
+	assert.Nil(t, pin)
+	assert.NotEqual(t, attestation.CheckIdentityPin, lastCheck(result).Name)
+
+	keySigned := &attestation.VerificationResult{Valid: true}
+	_, err = tofu.NewStore(db, tofu.Config{Mode: tofu.ModeEnforce}).Check(ctx, "ghcr.io/owner/repo", keySigned, "key")
+	require.NoError(t, err)
+	assert.Equal(t, attestation.CheckSkipped, lastCheck(keySigned).Status)
+
+	_, err = tofu.ParseMode("strict")
+	assert.Error(t, err)
+}
@@ -0,0 +1,291 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/trustroot"
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+)
+
+// fakeTUF is a TUF repository whose roles are all signed by one rotating key
+type fakeTUF struct {
+	t        *testing.T
+	mutex    sync.Mutex
+	files    map[string][]byte
+	requests int32
+	expires  string
+}
+
+func newFakeTUF(t *testing.T) *fakeTUF {
+	return &fakeTUF{t: t, files: make(map[string][]byte), expires: time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)}
+}
+
+func (f *fakeTUF) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&f.requests, 1)
+	f.mutex.Lock()
+	data, ok := f.files[strings.TrimPrefix(r.URL.Path, "/")]
+	f.mutex.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(data)
+}
+
+func (f *fakeTUF) put(name string, data []byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.files[name] = data
+}
+
+// tufKey returns a key ID and its root metadata entry
+func (f *fakeTUF) tufKey(key *ecdsa.PrivateKey) (string, map[string]interface{}) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(f.t, err)
+	entry := map[string]interface{}{
+		"keytype": "ecdsa",
+		"scheme":  "ecdsa-sha2-nistp256",
+		"keyval":  map[string]interface{}{"public": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))},
+	}
+	id := sha256.Sum256(der)
+	return hex.EncodeToString(id[:]), entry
+}
+
+// sign wraps signed in a metadata envelope. encoding/json sorts map keys and
+// emits no whitespace; canonical JSON differs only in leaving the newlines of
+// PEM keys unescaped.
+func (f *fakeTUF) sign(signed map[string]interface{}, keys ...*ecdsa.PrivateKey) []byte {
+	payload, err := json.Marshal(signed)
+	require.NoError(f.t, err)
+	digest := sha256.Sum256([]byte(strings.ReplaceAll(string(payload), `\n`, "\n")))
+
+	signatures := []map[string]string{}
+	for _, key := range keys {
+		id, _ := f.tufKey(key)
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(f.t, err)
+		signatures = append(signatures, map[string]string{"keyid": id, "sig": hex.EncodeToString(sig)})
+	}
+	data, err := json.Marshal(map[string]interface{}{"signed": json.RawMessage(payload), "signatures": signatures})
+	require.NoError(f.t, err)
+	return data
+}
+
+func (f *fakeTUF) root(version int, key *ecdsa.PrivateKey) map[string]interface{} {
+	id, entry := f.tufKey(key)
+	role := map[string]interface{}{"keyids": []string{id}, "threshold": 1}
+	return map[string]interface{}{
+		"_type":               "root",
+		"version":             version,
+		"expires":             f.expires,
+		"consistent_snapshot": true,
+		"keys":                map[string]interface{}{id: entry},
+		"roles":               map[string]interface{}{"root": role, "timestamp": role, "snapshot": role, "targets": role},
+	}
+}
+
+// publish writes targets, snapshot and timestamp metadata for a trusted root
+func (f *fakeTUF) publish(version int, key *ecdsa.PrivateKey, target []byte) {
+	digest := sha256.Sum256(target)
+	hexDigest := hex.EncodeToString(digest[:])
+	f.put("targets/"+hexDigest+"."+trustroot.TrustedRootTarget, target)
+
+	targets := f.sign(map[string]interface{}{
+		"_type": "targets", "version": version, "expires": f.expires,
+		"targets": map[string]interface{}{trustroot.TrustedRootTarget: map[string]interface{}{
+			"length": len(target), "hashes": map[string]string{"sha256": hexDigest},
+		}},
+	}, key)
+	f.put(strconv.Itoa(version)+".targets.json", targets)
+
+	snapshot := f.sign(map[string]interface{}{
+		"_type": "snapshot", "version": version, "expires": f.expires,
+		"meta": map[string]interface{}{"targets.json": map[string]interface{}{"version": version}},
+	}, key)
+	f.put(strconv.Itoa(version)+".snapshot.json", snapshot)
+
+	f.put("timestamp.json", f.sign(map[string]interface{}{
+		"_type": "timestamp", "version": version, "expires": f.expires,
+		"meta": map[string]interface{}{"snapshot.json": map[string]interface{}{"version": version}},
+	}, key))
+}
+
+// testTrustedRoot builds a trusted_root.json with one CA, log and TSA
+func testTrustedRoot(t *testing.T) []byte {
+	cert, key := testFulcioCertificate(t)
+	logKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	authority := map[string]interface{}{"certChain": map[string]interface{}{
+		"certificates": []map[string][]byte{{"rawBytes": cert.Raw}},
+	}}
+	data, err := json.Marshal(map[string]interface{}{
+		"mediaType":              "application/vnd.dev.sigstore.trustedroot+json;version=0.1",
+		"tlogs":                  []interface{}{map[string]interface{}{"publicKey": map[string][]byte{"rawBytes": logKey}}},
+		"certificateAuthorities": []interface{}{authority},
+		"timestampAuthorities":   []interface{}{authority},
+	})
+	require.NoError(t, err)
+	return data
+}
+
+func newTestCache(t *testing.T) *cache.HierarchicalCache {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	hierCache, err := cache.NewHierarchicalCache(cache.DefaultCacheConfig(), db, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { hierCache.Close() })
+	return hierCache
+}
+
+func newTUFKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func TestTrustRootFollowsTUF(t *testing.T) {
+	repo := newFakeTUF(t)
+	server := httptest.NewServer(repo)
+	defer server.Close()
+
+	// The repository rotated from key v1 to key v2 after the client's root
+	v1, v2 := newTUFKey(t), newTUFKey(t)
+	initialRoot := repo.sign(repo.root(1, v1), v1)
+	repo.put("2.root.json", repo.sign(repo.root(2, v2), v1, v2))
+	target := testTrustedRoot(t)
+	repo.publish(1, v2, target)
+
+	config := trustroot.DefaultConfig(initialRoot)
+	config.Mirror = server.URL
+	manager, err := trustroot.NewManager(config, newTestCache(t))
+	require.NoError(t, err)
+
+	trust, err := manager.TrustRoot(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, trust.FulcioCertificates, 1)
+	assert.Len(t, trust.RekorPublicKeys, 1)
+	assert.Len(t, trust.TimestampAuthorities, 1)
+
+	// Served from the cache until the refresh interval passes
+	requests := atomic.LoadInt32(&repo.requests)
+	_, err = manager.TrustRoot(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, requests, atomic.LoadInt32(&repo.requests))
+
+	// A refresh resumes from the rotated root
+	repo.publish(2, v2, target)
+	require.NoError(t, manager.Refresh(context.Background()))
+}
+
+func TestTrustRootRejectsTampering(t *testing.T) {
+	v1 := newTUFKey(t)
+	attacker := newTUFKey(t)
+
+	tests := []struct {
+		name    string
+		publish func(repo *fakeTUF, target []byte)
+	}{
+		{"target hash mismatch", func(repo *fakeTUF, target []byte) {
+			repo.publish(1, v1, target)
+			digest := sha256.Sum256(target)
+			repo.put("targets/"+hex.EncodeToString(digest[:])+"."+trustroot.TrustedRootTarget, append([]byte(" "), target[1:]...))
+		}},
+		{"timestamp signed by unknown key", func(repo *fakeTUF, target []byte) {
+			repo.publish(1, attacker, target)
+		}},
+		{"root rotation without old key", func(repo *fakeTUF, target []byte) {
+			repo.put("2.root.json", repo.sign(repo.root(2, attacker), attacker))
+			repo.publish(1, attacker, target)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeTUF(t)
+			server := httptest.NewServer(repo)
+			defer server.Close()
+			tt.publish(repo, testTrustedRoot(t))
+
+			config := trustroot.DefaultConfig(repo.sign(repo.root(1, v1), v1))
+			config.Mirror = server.URL
+			manager, err := trustroot.NewManager(config, newTestCache(t))
+			require.NoError(t, err)
+
+			_, err = manager.TrustRoot(context.Background())
+			assert.Equal(t, attestation.CodeTrustRootInvalid, attestation.CodeOf(err))
+		})
+	}
+}
+
+func TestPinnedTrustRoot(t *testing.T) {
+	config := trustroot.Config{PinnedRoot: testTrustedRoot(t)}
+	manager, err := trustroot.NewManager(config, nil)
+	require.NoError(t, err)
+
+	trust, err := manager.TrustRoot(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, trust.FulcioCertificates, 1)
+
+	_, err = trustroot.NewManager(trustroot.Config{PinnedRoot: []byte(`{"mediaType":"text/plain"}`)}, nil)
+	assert.Equal(t, attestation.CodeTrustRootInvalid, attestation.CodeOf(err))
+	_, err = trustroot.NewManager(trustroot.Config{}, nil)
+	assert.Equal(t, attestation.CodeTrustRootInvalid, attestation.CodeOf(err))
+}
+
+func TestSigstoreConfigFromEnv(t *testing.T) {
+	t.Setenv("SIGSTORE_ENV", attestation.SigstoreStaging)
+	t.Setenv("SIGSTORE_REKOR_URL", "https://rekor.internal.example.com/")
+
+	config, err := attestation.SigstoreConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "https://fulcio.sigstage.dev/api/v2/configuration", config.FulcioConfigurationURL())
+	assert.Equal(t, "https://rekor.internal.example.com", config.RekorURL)
+	assert.Equal(t, attestation.GitHubActionsIssuer, config.ApplyIssuer(attestation.IdentityPolicy{}).Issuer)
+
+	tufConfig, err := trustroot.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "https://tuf-repo-cdn.sigstage.dev", tufConfig.Mirror)
+
+	// A self-hosted instance has no default endpoints
+	t.Setenv("SIGSTORE_ENV", attestation.SigstoreCustom)
+	_, err = attestation.SigstoreConfigFromEnv()
+	assert.ErrorContains(t, err, "Fulcio URL is not configured")
+
+	t.Setenv("SIGSTORE_FULCIO_URL", "fulcio.internal.example.com")
+	t.Setenv("SIGSTORE_OIDC_ISSUER", "https://ghe.example.com/_services/token")
+	_, err = attestation.SigstoreConfigFromEnv()
+	assert.ErrorContains(t, err, "not an absolute http(s) URL")
+
+	t.Setenv("SIGSTORE_FULCIO_URL", "https://fulcio.internal.example.com")
+	_, err = trustroot.ConfigFromEnv()
+	assert.Equal(t, attestation.CodeTrustRootInvalid, attestation.CodeOf(err))
+
+	t.Setenv("SIGSTORE_ENV", "qa")
+	_, err = attestation.SigstoreConfigFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package attestation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+func TestUpgradeStatement(t *testing.T) {
+	subjects := []attestation.Subject{testSubject(t)}
+
+	t.Run("slsa_v02_to_v1", func(t *testing.T) {
+		statement, err := attestation.NewProvenanceBuilder(
+			attestation.WithProvenanceVersion(attestation.PredicateSLSAProvenanceV02),
+			attestation.WithExternalParameters(map[string]interface{}{"platform": "linux/amd64"}),
+			attestation.WithClaims(map[string]string{"runner_environment": "github-hosted"}),
+		).Build(subjects, testBuildContext())
+		require.NoError(t, err)
+		envelope, err := attestation.NewEnvelope(statement)
+		require.NoError(t, err)
+
+		upgraded, err := envelope.UpgradedStatement()
+		require.NoError(t, err)
+		assert.True(t, upgraded.Upgraded())
+		assert.Equal(t, attestation.StatementTypeV1, upgraded.Type)
+		assert.Equal(t, attestation.PredicateSLSAProvenanceV1, upgraded.PredicateType)
+		assert.Equal(t, subjects, upgraded.Subject)
+
+		predicate := upgraded.Predicate.(attestation.ProvenanceV1)
+		assert.Equal(t, attestation.BuildTypeGenericV02, predicate.BuildDefinition.BuildType)
+		assert.Equal(t, "linux/amd64", predicate.BuildDefinition.ExternalParameters["platform"])
+		assert.Equal(t, ".github/workflows/release.yml",
+			predicate.BuildDefinition.ExternalParameters["configSource"].(map[string]interface{})["entryPoint"])
+		assert.Contains(t, predicate.BuildDefinition.InternalParameters, "oidc_claims")
+		assert.Equal(t, "git+https://github.com/owner/repo@refs/heads/main", predicate.BuildDefinition.ResolvedDependencies[0].URI)
+		assert.Equal(t, "https://github.com/owner/repo/.github/workflows/release.yml@refs/heads/main", predicate.RunDetails.Builder.ID)
+		assert.Equal(t, "https://github.com/owner/repo/actions/runs/12345/attempts/2", predicate.RunDetails.Metadata.InvocationID)
+		require.NotNil(t, predicate.RunDetails.Metadata.StartedOn)
+
+		// Both the statement and the predicate were upgraded, and the
+		// conversions point back at the payload that was signed
+		payload, err := envelope.DecodePayload()
+		require.NoError(t, err)
+		digest := sha256.Sum256(payload)
+		require.Len(t, upgraded.Conversions, 2)
+		assert.Equal(t, attestation.StatementTypeV01, upgraded.Conversions[0].From)
+		assert.Equal(t, attestation.PredicateSLSAProvenanceV02, upgraded.Conversions[1].From)
+		assert.Equal(t, attestation.PredicateSLSAProvenanceV1, upgraded.Conversions[1].To)
+		assert.Equal(t, hex.EncodeToString(digest[:]), upgraded.Conversions[1].OriginalDigest["sha256"])
+
+		// The original statement is left untouched
+		original, err := envelope.Statement()
+		require.NoError(t, err)
+		assert.Equal(t, attestation.PredicateSLSAProvenanceV02, original.PredicateType)
+	})
+
+	t.Run("v1_is_current", func(t *testing.T) {
+		statement, err := attestation.NewProvenanceBuilder().Build(subjects, testBuildContext())
+		require.NoError(t, err)
+		envelope, err := attestation.NewEnvelope(statement)
+		require.NoError(t, err)
+
+		upgraded, err := envelope.UpgradedStatement()
+		require.NoError(t, err)
+		assert.False(t, upgraded.Upgraded())
+		assert.IsType(t, attestation.ProvenanceV1{}, upgraded.Predicate)
+	})
+
+	t.Run("other_predicates_keep_their_type", func(t *testing.T) {
+		upgraded, err := attestation.UpgradeStatement(&attestation.Statement{
+			Type:          attestation.StatementTypeV01,
+			Subject:       subjects,
+			PredicateType: "https://cyclonedx.org/bom",
+			Predicate:     map[string]interface{}{"bomFormat": "CycloneDX"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, attestation.StatementTypeV1, upgraded.Type)
+		assert.Equal(t, "https://cyclonedx.org/bom", upgraded.PredicateType)
+		assert.Len(t, upgraded.Conversions, 1)
+	})
+
+	t.Run("unknown_statement_type", func(t *testing.T) {
+		_, err := attestation.UpgradeStatement(&attestation.Statement{Type: "https://in-toto.io/Statement/v9"})
+		assert.Equal(t, attestation.CodeVerificationFailed, attestation.CodeOf(err))
+	})
+}
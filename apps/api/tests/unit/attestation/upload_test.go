@@ -0,0 +1,68 @@
+package attestation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+)
+
+func TestVerifyUploadChecksSubjectAndSignature(t *testing.T) {
+	fixture := newBundleFixture(t)
+	bundle := fixture.bundle(t)
+
+	result, err := attestation.VerifyUpload(bundle, testSubject(t), fixture.trust, attestation.IdentityPolicy{Repository: "owner/repo"})
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, attestation.CheckSubjectDigest, result.Checks[0].Name)
+	assert.Equal(t, attestation.CheckPassed, result.Checks[0].Status)
+	assert.Equal(t, map[string]attestation.CheckStatus{
+		attestation.CheckSubjectDigest:    attestation.CheckPassed,
+		attestation.CheckBundle:           attestation.CheckPassed,
+		attestation.CheckTimestamps:       attestation.CheckSkipped,
+		attestation.CheckCertificateChain: attestation.CheckPassed,
+		attestation.CheckTransparencyLog:  attestation.CheckPassed,
+		attestation.CheckSignature:        attestation.CheckPassed,
+		attestation.CheckCanonicalPayload: attestation.CheckSkipped,
+		attestation.CheckIdentityPolicy:   attestation.CheckPassed,
+	}, checkStatuses(result))
+}
+
+func TestVerifyUploadRejectsOtherSubjects(t *testing.T) {
+	fixture := newBundleFixture(t)
+	other, err := attestation.NewSubject("ghcr.io/owner/other", "sha256:1111111111111111111111111111111111111111111111111111111111111111")
+	require.NoError(t, err)
+
+	result, err := attestation.VerifyUpload(fixture.bundle(t), other, fixture.trust, attestation.IdentityPolicy{})
+	assert.Equal(t, attestation.CodeSubjectMismatch, attestation.CodeOf(err))
+	assert.False(t, result.Valid)
+	assert.Equal(t, attestation.CodeSubjectMismatch, result.ErrorCode)
+	assert.NotEmpty(t, result.Remediation)
+	require.Len(t, result.Checks, 1)
+	assert.Equal(t, attestation.CheckFailed, result.Checks[0].Status)
+}
+
+func TestVerifyUploadIgnoresTheBundledTrustRoot(t *testing.T) {
+	// The bundle verifies against the root it carries, but not the server's
+	bundle := newBundleFixture(t).bundle(t)
+	server := newBundleFixture(t).trust
+
+	result, err := attestation.VerifyUpload(bundle, testSubject(t), server, attestation.IdentityPolicy{})
+	assert.Equal(t, attestation.CodeCertificateUntrusted, attestation.CodeOf(err))
+	assert.Equal(t, attestation.CheckFailed, checkStatuses(result)[attestation.CheckCertificateChain])
+}
+
+func TestVerifyUploadRejectsBrokenEnvelopes(t *testing.T) {
+	fixture := newBundleFixture(t)
+	bundle := fixture.bundle(t)
+	bundle.Envelope.Signatures[0].Sig = "AAAA"
+
+	_, err := attestation.VerifyUpload(bundle, testSubject(t), fixture.trust, attestation.IdentityPolicy{})
+	assert.Error(t, err)
+	assert.NotEmpty(t, attestation.CodeOf(err))
+
+	_, err = attestation.VerifyUpload(nil, testSubject(t), fixture.trust, attestation.IdentityPolicy{})
+	assert.Equal(t, attestation.CodeVerificationFailed, attestation.CodeOf(err))
+}
@@ -0,0 +1,110 @@
+package attestation
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/trustroot"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/verifycache"
+)
+
+func TestVerificationResultCache(t *testing.T) {
+	ctx := context.Background()
+	hierCache := newTestCache(t)
+	results := verifycache.New(verifycache.DefaultConfig(), hierCache)
+
+	calls := 0
+	valid := func(context.Context) (*attestation.VerificationResult, error) {
+		calls++
+		return &attestation.VerificationResult{Valid: true, Subject: "ghcr.io/owner/repo"}, nil
+	}
+	policy := attestation.IdentityPolicy{Repository: "owner/repo"}
+	const digest = "sha256:abc"
+
+	result, err := results.Verify(ctx, digest, policy, valid)
+	require.NoError(t, err)
+	assert.False(t, result.Cached)
+
+	result, err = results.Verify(ctx, digest, policy, valid)
+	require.NoError(t, err)
+	assert.True(t, result.Cached)
+	assert.Equal(t, "ghcr.io/owner/repo", result.Subject)
+	assert.Equal(t, 1, calls)
+
+	// A different policy is verified separately
+	_, err = results.Verify(ctx, digest, attestation.IdentityPolicy{Repository: "owner/other"}, valid)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// Failures are not cached
+	failures := 0
+	failing := func(context.Context) (*attestation.VerificationResult, error) {
+		failures++
+		err := attestation.Errorf(attestation.CodeAttestationNotFound, "no attestation")
+		return &attestation.VerificationResult{ErrorCode: attestation.CodeOf(err)}, err
+	}
+	for i := 0; i < 2; i++ {
+		_, err = results.Verify(ctx, "sha256:def", policy, failing)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 2, failures)
+
+	require.NoError(t, results.Invalidate(ctx))
+	result, err = results.Verify(ctx, digest, policy, valid)
+	require.NoError(t, err)
+	assert.False(t, result.Cached)
+	assert.Equal(t, 3, calls)
+}
+
+func TestVerificationCacheInvalidatedOnTrustRootRotation(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeTUF(t)
+	server := httptest.NewServer(repo)
+	defer server.Close()
+
+	key := newTUFKey(t)
+	repo.publish(1, key, testTrustedRoot(t))
+	config := trustroot.DefaultConfig(repo.sign(repo.root(1, key), key))
+	config.Mirror = server.URL
+	hierCache := newTestCache(t)
+	manager, err := trustroot.NewManager(config, hierCache)
+	require.NoError(t, err)
+
+	results := verifycache.New(verifycache.DefaultConfig(), hierCache)
+	results.Watch(manager)
+
+	calls := 0
+	verify := func(ctx context.Context) (*attestation.VerificationResult, error) {
+		if _, err := manager.TrustRoot(ctx); err != nil {
+			return nil, err
+		}
+		calls++
+		return &attestation.VerificationResult{Valid: true}, nil
+	}
+	policy := attestation.IdentityPolicy{Repository: "owner/repo"}
+
+	for i := 0; i < 2; i++ {
+		_, err = results.Verify(ctx, "sha256:abc", policy, verify)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, calls)
+
+	// Refreshing an unchanged trust root keeps results
+	require.NoError(t, manager.Refresh(ctx))
+	_, err = results.Verify(ctx, "sha256:abc", policy, verify)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	// A rotated trust root discards them
+	repo.publish(2, key, testTrustedRoot(t))
+	require.NoError(t, manager.Refresh(ctx))
+	result, err := results.Verify(ctx, "sha256:abc", policy, verify)
+	require.NoError(t, err)
+	assert.False(t, result.Cached)
+	assert.Equal(t, 2, calls)
+}
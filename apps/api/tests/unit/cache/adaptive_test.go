@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+)
+
+func testAdaptiveConfig() cache.AdaptiveConfig {
+	config := cache.DefaultAdaptiveConfig()
+	config.Window = 4
+	return config
+}
+
+func TestAdaptiveTTLLengthensStableNamespaces(t *testing.T) {
+	adaptive := cache.NewAdaptiveTTL(testAdaptiveConfig())
+
+	ttl := adaptive.TTL("cve:CVE-2021-44228", map[string]string{"severity": "critical"}, 2*time.Hour)
+	assert.Equal(t, 2*time.Hour, ttl)
+
+	// Published CVEs come back unchanged on every refresh
+	for i := 0; i < 80; i++ {
+		adaptive.ObserveGet("cve:CVE-2021-44228", i%2 == 0)
+		ttl = adaptive.TTL("cve:CVE-2021-44228", map[string]string{"severity": "critical"}, 2*time.Hour)
+	}
+	assert.Equal(t, 7*24*time.Hour, ttl, "grows to the namespace staleness tolerance")
+
+	report := adaptive.Report()
+	require.Len(t, report, 1)
+	assert.Equal(t, "cve", report[0].Namespace)
+	assert.Equal(t, 7*24*time.Hour, report[0].TTL)
+	assert.Equal(t, 0.0, report[0].ChangeRatio)
+	assert.InDelta(t, 0.5, report[0].HitRatio, 0.01)
+	assert.Contains(t, report[0].Reason, "at bound")
+}
+
+func TestAdaptiveTTLShortensVolatileNamespaces(t *testing.T) {
+	adaptive := cache.NewAdaptiveTTL(testAdaptiveConfig())
+
+	var ttl time.Duration
+	for i := 0; i < 40; i++ {
+		ttl = adaptive.TTL("ratelimit:core", map[string]int{"remaining": 5000 - i}, 5*time.Minute)
+	}
+	assert.Equal(t, 10*time.Second, ttl, "shrinks to the namespace minimum")
+
+	// Namespaces without configured bounds stay within [ttl/4, ttl*4]
+	for i := 0; i < 40; i++ {
+		ttl = adaptive.TTL(fmt.Sprintf("scan-%d", i%2), i, time.Hour)
+	}
+	assert.Equal(t, 15*time.Minute, ttl)
+
+	report := adaptive.Report()
+	require.Len(t, report, 2)
+	assert.Equal(t, "default", report[0].Namespace)
+	assert.Equal(t, "ratelimit", report[1].Namespace)
+	assert.Equal(t, 1.0, report[1].ChangeRatio)
+}
+
+func TestAdaptiveTTLHoldsAtHitTarget(t *testing.T) {
+	adaptive := cache.NewAdaptiveTTL(testAdaptiveConfig())
+
+	adaptive.TTL("advisories:npm", []string{"GHSA-1"}, 10*time.Minute)
+	for i := 0; i < 100; i++ {
+		adaptive.ObserveGet("advisories:npm", true)
+	}
+	var ttl time.Duration
+	for i := 0; i < 8; i++ {
+		ttl = adaptive.TTL("advisories:npm", []string{"GHSA-1"}, 10*time.Minute)
+	}
+	assert.Equal(t, 10*time.Minute, ttl, "no need to risk staleness once hits are high")
+	assert.Equal(t, "stable: hit ratio target reached", adaptive.Report()[0].Reason)
+}
+
+func TestHierarchicalCacheReportsAdaptiveTTLs(t *testing.T) {
+	config := cache.DefaultCacheConfig()
+	adaptive := testAdaptiveConfig()
+	config.Adaptive = &adaptive
+	hierCache := newHierarchicalCache(t, config)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, hierCache.Set(ctx, "ratelimit:core", map[string]int{"remaining": i}, 5*time.Minute))
+		_, found := hierCache.Get(ctx, "ratelimit:core")
+		assert.True(t, found)
+	}
+
+	stats := hierCache.Stats()
+	require.Len(t, stats.TTLs, 1)
+	assert.Equal(t, "ratelimit", stats.TTLs[0].Namespace)
+	assert.Less(t, stats.TTLs[0].TTL, 5*time.Minute)
+	assert.Equal(t, 1.0, stats.TTLs[0].HitRatio)
+}
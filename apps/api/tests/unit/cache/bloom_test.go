@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+)
+
+func TestBloomFilter(t *testing.T) {
+	filter := cache.NewBloomFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		filter.Add(fmt.Sprintf("cve:CVE-2024-%d", i))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		require.True(t, filter.MayContain(fmt.Sprintf("cve:CVE-2024-%d", i)))
+		if filter.MayContain(fmt.Sprintf("ghsa:GHSA-%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 300, "false positive rate near the 1%% target")
+	assert.InDelta(t, 0.01, filter.FalsePositiveRate(), 0.01)
+
+	data, err := filter.MarshalBinary()
+	require.NoError(t, err)
+	restored := &cache.BloomFilter{}
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.True(t, restored.MayContain("cve:CVE-2024-42"))
+	assert.Error(t, restored.UnmarshalBinary([]byte("garbage")))
+}
+
+func TestL2FilterSkipsGuaranteedMisses(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	config := cache.DefaultCacheConfig()
+	config.Adaptive = nil
+	filter := cache.DefaultBloomConfig()
+	config.L2Filter = &filter
+	hierCache, err := cache.NewHierarchicalCache(config, db, nil)
+	require.NoError(t, err)
+
+	_, found := hierCache.Get(ctx, "cve:CVE-2024-0001")
+	assert.False(t, found)
+	assert.Equal(t, int64(1), hierCache.Stats().Metrics.L2FilterSkips)
+
+	require.NoError(t, hierCache.Set(ctx, "cve:CVE-2024-0001", "stored", time.Hour))
+	require.NoError(t, hierCache.Close())
+
+	// Written after the snapshot, as by a process that crashed before saving one
+	_, err = db.Exec(`INSERT INTO cache_entries (key, value, expires_at, size) VALUES (?, ?, ?, ?)`,
+		"cve:CVE-2024-0002", `"late"`, time.Now().Add(time.Hour), 6)
+	require.NoError(t, err)
+
+	// A restarted cache restores the snapshot and replays newer writes, so
+	// stored keys still reach L2 from an empty L1
+	restarted, err := cache.NewHierarchicalCache(config, db, nil)
+	require.NoError(t, err)
+	defer restarted.Close()
+
+	value, found := restarted.Get(ctx, "cve:CVE-2024-0001")
+	require.True(t, found)
+	assert.Equal(t, "stored", value)
+	value, found = restarted.Get(ctx, "cve:CVE-2024-0002")
+	require.True(t, found)
+	assert.Equal(t, "late", value)
+
+	_, found = restarted.Get(ctx, "cve:CVE-2024-9999")
+	assert.False(t, found)
+	stats := restarted.Stats()
+	assert.Equal(t, int64(2), stats.Metrics.L2Hits)
+	assert.Equal(t, int64(1), stats.Metrics.L2FilterSkips)
+}
+
+func TestDefaultCacheSeesOtherWritersToL2(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	// As in the API and worker processes, which share one SQLite cache
+	config := cache.DefaultCacheConfig()
+	config.Adaptive = nil
+	writer, err := cache.NewHierarchicalCache(config, db, nil)
+	require.NoError(t, err)
+	defer writer.Close()
+	reader, err := cache.NewHierarchicalCache(config, db, nil)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	require.NoError(t, writer.Set(ctx, "cve:CVE-2024-0001", "stored", time.Hour))
+	value, found := reader.Get(ctx, "cve:CVE-2024-0001")
+	require.True(t, found)
+	assert.Equal(t, "stored", value)
+	assert.Equal(t, int64(0), reader.Stats().Metrics.L2FilterSkips)
+}
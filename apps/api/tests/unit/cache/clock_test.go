@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+)
+
+func TestHierarchicalCacheExpiresOnFakeClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	config := cache.DefaultCacheConfig()
+	config.Adaptive = nil
+	config.Clock = fake
+	hierCache := newHierarchicalCache(t, config)
+	ctx := context.Background()
+
+	require.NoError(t, hierCache.Set(ctx, "advisory:GHSA-1", "cached", time.Hour))
+
+	fake.Advance(59 * time.Minute)
+	value, found := hierCache.Get(ctx, "advisory:GHSA-1")
+	require.True(t, found)
+	assert.Equal(t, "cached", value)
+
+	// Past the TTL both L1 and L2 treat the entry as expired
+	fake.Advance(2 * time.Minute)
+	_, found = hierCache.Get(ctx, "advisory:GHSA-1")
+	assert.False(t, found)
+}
+
+func TestHierarchicalCacheRefusesCredentials(t *testing.T) {
+	hierCache := newHierarchicalCache(t, cache.DefaultCacheConfig())
+	ctx := context.Background()
+
+	err := hierCache.Set(ctx, "oidc-token:sigstore", "eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiJyZXBvIn0.c2ln", time.Hour)
+	assert.ErrorIs(t, err, cache.ErrSecretValue)
+	_, found := hierCache.Get(ctx, "oidc-token:sigstore")
+	assert.False(t, found)
+
+	assert.ErrorIs(t, hierCache.Set(ctx, "github-token", secret.New("ghs_token"), time.Hour), cache.ErrSecretValue)
+}
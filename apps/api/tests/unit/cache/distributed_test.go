@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+)
+
+func TestHashRingDistribution(t *testing.T) {
+	ring := cache.NewHashRing(100)
+	ring.Add("a", "b", "c")
+	assert.Equal(t, []string{"a", "b", "c"}, ring.Nodes())
+
+	owners := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("cve:CVE-2024-%d", i)
+		owners[key] = ring.Owner(key)
+		counts[owners[key]]++
+	}
+	for node, count := range counts {
+		assert.Greater(t, count, 600, "node %s owns too few keys", node)
+	}
+
+	// Only the removed node's keys move
+	ring.Remove("b")
+	for key, owner := range owners {
+		if owner != "b" {
+			assert.Equal(t, owner, ring.Owner(key))
+		} else {
+			assert.NotEqual(t, "b", ring.Owner(key))
+		}
+	}
+
+	assert.Equal(t, "", cache.NewHashRing(10).Owner("key"))
+}
+
+// replica is a cache serving its peer endpoints over HTTP
+type replica struct {
+	server  *httptest.Server
+	handler atomic.Value
+	down    atomic.Bool // Partitions the replica from its peers
+	cache   *cache.HierarchicalCache
+	loads   int32
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func newReplicas(t *testing.T, count int) []*replica {
+	replicas := make([]*replica, count)
+	for i := range replicas {
+		r := &replica{}
+		r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			handler, ok := r.handler.Load().(http.Handler)
+			if !ok || r.down.Load() {
+				http.Error(w, "starting", http.StatusServiceUnavailable)
+				return
+			}
+			handler.ServeHTTP(w, req)
+		}))
+		t.Cleanup(r.server.Close)
+		replicas[i] = r
+	}
+
+	for _, r := range replicas {
+		distributed := cache.DefaultDistributedConfig(r.server.URL, replicas[0].server.URL)
+		distributed.Secret = "peer-secret"
+		distributed.GossipInterval = 20 * time.Millisecond
+		distributed.DeadAfter = 300 * time.Millisecond
+		distributed.HotKeyThreshold = 0
+		r := r
+		distributed.HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if r.down.Load() {
+				return nil, errors.New("partitioned")
+			}
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+
+		config := cache.DefaultCacheConfig()
+		config.Distributed = &distributed
+		r.cache = newHierarchicalCache(t, config)
+		r.handler.Store(r.cache.PeerHandler())
+		r.cache.RegisterLoader("cve", func(ctx context.Context, key string) (interface{}, error) {
+			atomic.AddInt32(&r.loads, 1)
+			return map[string]interface{}{"id": key}, nil
+		}, cache.LoaderOptions{TTL: time.Hour})
+	}
+
+	for _, r := range replicas {
+		r := r
+		require.Eventually(t, func() bool { return len(r.cache.Members()) == count }, 5*time.Second, 10*time.Millisecond)
+	}
+	return replicas
+}
+
+// ownedBy returns a key in namespace the replica owns
+func ownedBy(t *testing.T, replicas []*replica, owner *replica, namespace string) string {
+	ring := cache.NewHashRing(100)
+	for _, r := range replicas {
+		ring.Add(r.server.URL)
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("%s:%d", namespace, i)
+		if ring.Owner(key) == owner.server.URL {
+			return key
+		}
+	}
+	t.Fatal("no key found for owner")
+	return ""
+}
+
+func TestDistributedL1RoutesToOwner(t *testing.T) {
+	replicas := newReplicas(t, 2)
+	ctx := context.Background()
+	key := ownedBy(t, replicas, replicas[1], "cve")
+
+	// The non-owner asks the owner, which loads the key once for both
+	value, found := replicas[0].cache.Get(ctx, key)
+	require.True(t, found)
+	assert.Equal(t, key, value.(map[string]interface{})["id"])
+	value, found = replicas[1].cache.Get(ctx, key)
+	require.True(t, found)
+	assert.Equal(t, key, value.(map[string]interface{})["id"])
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&replicas[0].loads))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&replicas[1].loads))
+	assert.Equal(t, int64(1), replicas[0].cache.Stats().Metrics.PeerHits)
+
+	// Writes of hot keys reach the owner's L1
+	key = ownedBy(t, replicas, replicas[1], "advisories")
+	require.NoError(t, replicas[0].cache.Set(ctx, key, "fresh", time.Hour))
+	value, found = replicas[1].cache.Get(ctx, key)
+	require.True(t, found)
+	assert.Equal(t, "fresh", value)
+}
+
+func TestDistributedL1Membership(t *testing.T) {
+	replicas := newReplicas(t, 3)
+
+	// A replica that stops gossiping leaves the ring
+	replicas[2].down.Store(true)
+	for _, r := range replicas[:2] {
+		r := r
+		assert.Eventually(t, func() bool { return len(r.cache.Members()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	}
+
+	// Peers must present the shared secret
+	resp, err := http.Get(replicas[0].server.URL + "/cache/v1/keys?key=cve:1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+)
+
+func newHierarchicalCache(t *testing.T, config cache.CacheConfig) *cache.HierarchicalCache {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	hierCache, err := cache.NewHierarchicalCache(config, db, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { hierCache.Close() })
+	return hierCache
+}
+
+func TestReadThroughLoader(t *testing.T) {
+	hierCache := newHierarchicalCache(t, cache.DefaultCacheConfig())
+	ctx := context.Background()
+
+	var calls int32
+	hierCache.RegisterLoader("cve:", func(ctx context.Context, key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return map[string]interface{}{"id": key}, nil
+	}, cache.LoaderOptions{TTL: time.Hour})
+
+	value, found := hierCache.Get(ctx, "cve:CVE-2024-0001")
+	require.True(t, found)
+	assert.Equal(t, "cve:CVE-2024-0001", value.(map[string]interface{})["id"])
+
+	_, found = hierCache.Get(ctx, "cve:CVE-2024-0001")
+	assert.True(t, found)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "second lookup is served from cache")
+
+	// Namespaces without a loader still miss
+	_, found = hierCache.Get(ctx, "ghsa:GHSA-xxxx")
+	assert.False(t, found)
+	_, err := hierCache.Load(ctx, "ghsa:GHSA-xxxx")
+	assert.ErrorIs(t, err, cache.ErrCacheMiss)
+
+	stats := hierCache.Stats()
+	assert.Equal(t, int64(1), stats.Metrics.Loads)
+}
+
+func TestReadThroughLoaderErrors(t *testing.T) {
+	hierCache := newHierarchicalCache(t, cache.DefaultCacheConfig())
+	failure := errors.New("nvd unavailable")
+	hierCache.RegisterLoader("cve", func(ctx context.Context, key string) (interface{}, error) {
+		return nil, failure
+	}, cache.LoaderOptions{})
+
+	_, err := hierCache.Load(context.Background(), "cve:CVE-2024-0001")
+	assert.ErrorIs(t, err, failure)
+	_, found := hierCache.Get(context.Background(), "cve:CVE-2024-0001")
+	assert.False(t, found)
+	assert.Equal(t, int64(2), hierCache.Stats().Metrics.LoadErrors)
+}
+
+func TestReadThroughLoaderSingleflight(t *testing.T) {
+	hierCache := newHierarchicalCache(t, cache.DefaultCacheConfig())
+
+	var calls int32
+	release := make(chan struct{})
+	hierCache.RegisterLoader("cve", func(ctx context.Context, key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "loaded", nil
+	}, cache.LoaderOptions{TTL: time.Hour})
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = hierCache.Get(context.Background(), "cve:CVE-2024-0001")
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "concurrent misses share one load")
+	for _, result := range results {
+		assert.Equal(t, "loaded", result)
+	}
+}
+
+func TestReadThroughStaleWhileRevalidate(t *testing.T) {
+	config := cache.DefaultCacheConfig()
+	config.Adaptive = nil
+	hierCache := newHierarchicalCache(t, config)
+	ctx := context.Background()
+
+	var version int32
+	refreshed := make(chan struct{}, 1)
+	hierCache.RegisterLoader("advisories", func(ctx context.Context, key string) (interface{}, error) {
+		v := atomic.AddInt32(&version, 1)
+		if v > 1 {
+			select {
+			case refreshed <- struct{}{}:
+			default:
+			}
+		}
+		return float64(v), nil
+	}, cache.LoaderOptions{TTL: 50 * time.Millisecond, StaleWhileRevalidate: time.Hour})
+
+	value, err := hierCache.Load(ctx, "advisories:npm")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), value)
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The expired value is served immediately while a refresh runs
+	value, err = hierCache.Load(ctx, "advisories:npm")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), value)
+
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stale value was not refreshed")
+	}
+	assert.Eventually(t, func() bool {
+		value, _ := hierCache.Get(ctx, "advisories:npm")
+		return value == float64(2)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, hierCache.Stats().Metrics.StaleHits, int64(1))
+}
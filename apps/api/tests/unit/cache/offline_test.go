@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+)
+
+func TestOfflineDetectorPassiveSignals(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	detector := cache.NewOfflineDetector(db, nil)
+
+	var failing atomic.Bool
+	failing.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	github := &http.Client{Transport: detector.Transport("github", nil)}
+	nvd := &http.Client{Transport: detector.Transport("nvd", nil)}
+	registry := &http.Client{Transport: detector.Transport("registry", nil)}
+
+	// The probe may succeed while the endpoints clients use fail
+	for i := 0; i < 3; i++ {
+		resp, err := github.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	status := detector.GetServiceStatus()["github"]
+	assert.False(t, status.IsAvailable)
+	assert.Equal(t, 3, status.ErrorCount)
+	assert.Equal(t, "HTTP 502", status.LastError)
+	assert.Equal(t, cache.LimitedMode, detector.GetMode())
+
+	// Features degrade independently
+	assert.False(t, detector.Available(cache.CapabilityAdvisoryFetch))
+	assert.True(t, detector.Available(cache.CapabilityScanning))
+	assert.True(t, detector.Available(cache.CapabilitySigning))
+
+	for i := 0; i < 3; i++ {
+		resp, err := nvd.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.True(t, detector.IsOffline())
+	assert.False(t, detector.Capabilities()[cache.CapabilityScanning])
+
+	// Calls to services without a probe are tracked too
+	resp, err := registry.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 1, detector.GetServiceStatus()["registry"].ErrorCount)
+
+	// Successful calls restore the mode
+	failing.Store(false)
+	for _, client := range []*http.Client{github, nvd} {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.True(t, detector.IsOnline())
+	assert.Equal(t, "online", detector.GetMode().String())
+	assert.Zero(t, detector.GetServiceStatus()["github"].ErrorCount)
+}
+
+func TestOfflineDetectorOIDCIssuer(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	detector := cache.NewOfflineDetector(db, nil)
+	detector.SetOIDCIssuer("https://ghes.example.com/_services/token/")
+
+	// Only the issuer's probe fails; nothing leaves the test
+	probed := make(chan string, 8)
+	detector.WrapProbes(func(service string, base http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			status := http.StatusOK
+			if service == "oidc" {
+				probed <- req.URL.String()
+				status = http.StatusServiceUnavailable
+			}
+			return &http.Response{StatusCode: status, Body: http.NoBody, Request: req}, nil
+		})
+	})
+	detector.Start()
+	defer detector.Stop()
+
+	assert.Equal(t, "https://ghes.example.com/_services/token/.well-known/openid-configuration", <-probed)
+	require.Eventually(t, func() bool {
+		return detector.GetServiceStatus()["oidc"].ErrorCount == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, detector.Unavailable(cache.CapabilitySigning))
+
+	// Failed token requests count toward the threshold too
+	detector.ReportCall("oidc", errors.New("connection refused"))
+	detector.ReportCall("oidc", errors.New("connection refused"))
+	assert.False(t, detector.Available(cache.CapabilitySigning))
+	assert.Equal(t, []string{"oidc"}, detector.Unavailable(cache.CapabilitySigning))
+	assert.Empty(t, detector.Unavailable(cache.CapabilityScanning))
+	assert.True(t, detector.IsOnline(), "the issuer isn't critical to scanning")
+
+	detector.ReportCall("oidc", nil)
+	assert.True(t, detector.Available(cache.CapabilitySigning))
+}
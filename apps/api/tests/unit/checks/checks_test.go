@@ -0,0 +1,218 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/checks"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func result() checks.Result {
+	vulnerable := findings.New("github-advisory", findings.CategoryVulnerability, findings.SeverityHigh, "GHSA-xxxx-0001", "pkg:golang/golang.org/x/net@v0.15.0", "HTTP/2 rapid reset")
+	vulnerable.Metadata[findings.MetadataPath] = "go.mod"
+	vulnerable.Metadata[findings.MetadataLine] = "7"
+	license := findings.New("github-dependency-review", findings.CategoryPolicy, findings.SeverityLow, "unknown-license", "pkg:npm/left-pad@1.3.0", "left-pad 1.3.0 has no known license")
+
+	return checks.Result{
+		Reports: []*attestation.Report{
+			{Subject: "ghcr.io/acme/widgets", Valid: true, Policy: []attestation.PolicyResult{
+				{Rule: "issuer", Expected: "https://token.actions.githubusercontent.com", Actual: "https://token.actions.githubusercontent.com", Passed: true},
+				{Rule: "repository", Expected: "acme/widgets", Actual: "acme/gadgets"},
+			}},
+			{Subject: "widgets.tar.gz", Failure: &attestation.ReportFailure{Code: "SIGN_001", Message: "signature mismatch"}},
+		},
+		Findings: []findings.Finding{license, vulnerable},
+		Path:     "package.json",
+	}
+}
+
+func TestOutput(t *testing.T) {
+	result := result()
+	assert.Equal(t, github.CheckConclusionFailure, result.Conclusion(findings.SeverityHigh))
+
+	output := result.Output(findings.SeverityHigh)
+	assert.Equal(t, "1 attestation failed verification, 1 finding HIGH or worse", output.Title)
+	assert.Contains(t, output.Summary, "| `widgets.tar.gz` | ❌ failed | `SIGN_001` signature mismatch |")
+	assert.Contains(t, output.Summary, "1 of 2 rules passed.")
+	assert.Contains(t, output.Summary, "| `ghcr.io/acme/widgets` | repository | `acme/widgets` | `acme/gadgets` |")
+	assert.Contains(t, output.Summary, "| vulnerability | 0 | 1 | 0 | 0 | 0 |")
+
+	// Most severe first; findings without a path land on the result's
+	require.Len(t, output.Annotations, 2)
+	assert.Equal(t, github.CheckAnnotation{
+		Path: "go.mod", StartLine: 7, EndLine: 7, AnnotationLevel: github.AnnotationFailure,
+		Title: "HIGH: github-advisory/GHSA-xxxx-0001", Message: "HTTP/2 rapid reset",
+	}, output.Annotations[0])
+	assert.Equal(t, "package.json", output.Annotations[1].Path)
+	assert.Equal(t, github.AnnotationNotice, output.Annotations[1].AnnotationLevel)
+
+	clean := checks.Result{Reports: []*attestation.Report{{Subject: "widgets.tar.gz", Valid: true}}}
+	assert.Equal(t, github.CheckConclusionSuccess, clean.Conclusion(findings.SeverityHigh))
+	assert.Equal(t, "1 attestation verified, 0 findings", clean.Output(findings.SeverityHigh).Title)
+}
+
+func TestPublishCompletesStartedRun(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	status := github.CheckRunInProgress
+	harness.Handle("/repos/acme/widgets/commits/abc123/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"check_runs":[{"id":7,"name":"keystone","status":"` + status + `"}]}`))
+	})
+	harness.Handle("/repos/acme/widgets/check-runs/7", func(w http.ResponseWriter, r *http.Request) {
+		var body github.CheckRunOptions
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, github.CheckRunCompleted, body.Status)
+		assert.Equal(t, github.CheckConclusionFailure, body.Conclusion)
+		w.Write([]byte(`{"id":7,"status":"completed","conclusion":"failure"}`))
+	})
+	harness.Handle("/repos/acme/widgets/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":8,"status":"completed"}`))
+	})
+
+	reporter := checks.NewReporter(harness.Client)
+	run, err := reporter.Publish(context.Background(), "acme", "widgets", "abc123", result())
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), run.ID)
+
+	// A completed run isn't touched again; a new run supersedes it
+	status = github.CheckRunCompleted
+	run, err = reporter.Publish(context.Background(), "acme", "widgets", "abc123", result())
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), run.ID)
+	assert.Equal(t, 1, harness.Requests("/repos/acme/widgets/check-runs/7"))
+}
+
+func TestStatusReporter(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	var statuses []github.CommitStatus
+	harness.Handle("/repos/acme/widgets/statuses/abc123", func(w http.ResponseWriter, r *http.Request) {
+		var status github.CommitStatus
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&status))
+		statuses = append(statuses, status)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(status)
+	})
+
+	reporter := checks.NewStatusReporter(harness.Client)
+	reporter.TargetURL = "https://github.com/acme/widgets/actions/runs/1"
+	_, err := reporter.Start(context.Background(), "acme", "widgets", "abc123")
+	require.NoError(t, err)
+	_, err = reporter.Publish(context.Background(), "acme", "widgets", "abc123", result())
+	require.NoError(t, err)
+
+	require.Len(t, statuses, 2)
+	assert.Equal(t, github.CommitStatePending, statuses[0].State)
+	assert.Equal(t, github.CommitStatus{
+		State:       github.CommitStateFailure,
+		Context:     checks.DefaultName,
+		Description: "1 attestation failed verification, 1 finding HIGH or worse",
+		TargetURL:   "https://github.com/acme/widgets/actions/runs/1",
+	}, statuses[1])
+
+	// Findings below the bar pass
+	reporter.FailOn = findings.SeverityCritical
+	clean := checks.Result{Findings: result().Findings}
+	status, err := reporter.Publish(context.Background(), "acme", "widgets", "abc123", clean)
+	require.NoError(t, err)
+	assert.Equal(t, github.CommitStateSuccess, status.State)
+}
+
+func TestDeploymentGate(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	var statuses []github.DeploymentStatus
+	harness.Handle("/repos/acme/widgets/deployments/9/statuses", func(w http.ResponseWriter, r *http.Request) {
+		var status github.DeploymentStatus
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&status))
+		statuses = append(statuses, status)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(status)
+	})
+
+	gate := checks.NewDeploymentGate(harness.Client)
+	gate.LogURL = "https://github.com/acme/widgets/actions/runs/1"
+	_, err := gate.Start(context.Background(), "acme", "widgets", 9)
+	require.NoError(t, err)
+	_, err = gate.Publish(context.Background(), "acme", "widgets", 9, result())
+	require.NoError(t, err)
+
+	require.Len(t, statuses, 2)
+	assert.Equal(t, github.DeploymentStateInProgress, statuses[0].State)
+	assert.Equal(t, github.DeploymentStatus{
+		State:       github.DeploymentStateFailure,
+		Description: "1 attestation failed verification, 1 finding HIGH or worse",
+		LogURL:      "https://github.com/acme/widgets/actions/runs/1",
+	}, statuses[1])
+
+	// Only a passing result marks the deployment successful
+	status, err := gate.Publish(context.Background(), "acme", "widgets", 9, checks.Result{})
+	require.NoError(t, err)
+	assert.Equal(t, github.DeploymentStateSuccess, status.State)
+}
+
+func TestCommentReporterSticky(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	var comments []github.IssueComment
+	harness.Handle("/repos/acme/widgets/issues/42/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			comments = append(comments, github.IssueComment{ID: int64(100 + len(comments)), Body: body["body"]})
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(comments[len(comments)-1])
+			return
+		}
+		json.NewEncoder(w).Encode(comments)
+	})
+	harness.Handle("/repos/acme/widgets/issues/comments/101", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PATCH", r.Method)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		comments[1].Body = body["body"]
+		json.NewEncoder(w).Encode(comments[1])
+	})
+	comments = append(comments, github.IssueComment{ID: 100, Body: "LGTM"})
+
+	reporter := checks.NewCommentReporter(harness.Client)
+	first, err := reporter.Publish(context.Background(), "acme", "widgets", 42, result())
+	require.NoError(t, err)
+	assert.Equal(t, int64(101), first.ID)
+
+	// Later runs edit the same comment, and leave an unchanged one alone
+	fixed := result()
+	fixed.Findings = fixed.Findings[:1]
+	fixed.Baseline = result().Findings
+	second, err := reporter.Publish(context.Background(), "acme", "widgets", 42, fixed)
+	require.NoError(t, err)
+	assert.Equal(t, int64(101), second.ID)
+	assert.Contains(t, second.Body, "0 vulnerabilities introduced, 1 vulnerability fixed.")
+	_, err = reporter.Publish(context.Background(), "acme", "widgets", 42, fixed)
+	require.NoError(t, err)
+
+	assert.Len(t, comments, 2)
+	assert.Equal(t, 1, harness.Requests("/repos/acme/widgets/issues/comments/101"))
+}
+
+func TestCommentBody(t *testing.T) {
+	reporter := checks.NewCommentReporter(nil)
+	body := reporter.Body(result())
+	assert.True(t, strings.HasPrefix(body, checks.DefaultMarker))
+	assert.Contains(t, body, "❌ **Failed**: 1 attestation failed verification, 1 finding HIGH or worse.")
+	assert.Contains(t, body, "1 vulnerability found.")
+	assert.Contains(t, body, "| HIGH | github-advisory/GHSA-xxxx-0001 | `pkg:golang/golang.org/x/net@v0.15.0` | HTTP/2 rapid reset |")
+	assert.Contains(t, body, "### Attestations")
+
+	// Vulnerabilities already on the base branch aren't news
+	unchanged := result()
+	unchanged.Baseline = unchanged.Findings
+	assert.Contains(t, reporter.Body(unchanged), "No change from the base branch.")
+}
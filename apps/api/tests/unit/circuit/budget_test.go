@@ -0,0 +1,41 @@
+package circuit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+)
+
+func TestRetryBudget(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	budget := circuit.NewBudget(circuit.BudgetConfig{Ratio: 0.5, MinRetries: 1, Window: 10 * time.Second, Clock: fake})
+
+	for i := 0; i < 4; i++ {
+		budget.RecordRequest()
+	}
+	// 50% of four requests plus the floor of one
+	for i := 0; i < 3; i++ {
+		require.NoError(t, budget.TryRetry())
+	}
+	assert.ErrorIs(t, budget.TryRetry(), circuit.ErrRetryBudgetExhausted)
+	assert.Equal(t, circuit.BudgetStats{Requests: 4, Retries: 3, Rejected: 1}, budget.Stats())
+
+	t.Run("allowance_recovers_as_the_window_slides", func(t *testing.T) {
+		fake.Advance(5 * time.Second)
+		assert.ErrorIs(t, budget.TryRetry(), circuit.ErrRetryBudgetExhausted)
+
+		fake.Advance(6 * time.Second)
+		assert.NoError(t, budget.TryRetry())
+	})
+
+	t.Run("nil_budget_allows_every_retry", func(t *testing.T) {
+		var unlimited *circuit.Budget
+		unlimited.RecordRequest()
+		assert.NoError(t, unlimited.TryRetry())
+	})
+}
@@ -0,0 +1,70 @@
+package depreview
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/depreview"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+func TestLicensePolicyAllows(t *testing.T) {
+	policy := depreview.LicensePolicy{Allow: []string{"MIT", "Apache-2.0", "BSD-3-Clause"}, Deny: []string{"BSD-3-Clause"}}
+
+	tests := []struct {
+		expression string
+		allowed    bool
+		known      bool
+	}{
+		{"MIT", true, true},
+		{"mit", true, true},
+		{"GPL-3.0-only", false, true},
+		{"GPL-3.0-only OR Apache-2.0", true, true},
+		{"MIT AND GPL-3.0-only", false, true},
+		{"(MIT AND Apache-2.0) OR GPL-2.0-only", true, true},
+		{"Apache-2.0 WITH LLVM-exception", true, true},
+		{"BSD-3-Clause", false, true},
+		{"", false, false},
+		{"NOASSERTION", false, false},
+	}
+	for _, test := range tests {
+		allowed, known := policy.Allows(test.expression)
+		assert.Equal(t, test.allowed, allowed, test.expression)
+		assert.Equal(t, test.known, known, test.expression)
+	}
+
+	allowed, known := depreview.LicensePolicy{Deny: []string{"AGPL-3.0-only"}}.Allows("")
+	assert.True(t, allowed, "unknown licenses only matter with an allowlist")
+	assert.False(t, known)
+}
+
+func TestFindings(t *testing.T) {
+	changes := []github.DependencyChange{
+		{
+			ChangeType: github.DependencyAdded, Manifest: "go.mod", Name: "golang.org/x/net", Version: "0.1.0",
+			PackageURL: "pkg:golang/golang.org/x/net@0.1.0", License: "BSD-3-Clause", Scope: "runtime",
+			Vulnerabilities: []github.DependencyVulnerability{{Severity: "high", AdvisoryGHSAID: "GHSA-qppj-fm5r-hxr3", AdvisorySummary: "HTTP/2 rapid reset"}},
+		},
+		{ChangeType: github.DependencyAdded, Manifest: "package.json", Name: "copyleft", Version: "1.0.0", PackageURL: "pkg:npm/copyleft@1.0.0", License: "GPL-3.0-only"},
+		{ChangeType: github.DependencyAdded, Manifest: "package.json", Name: "mystery", Version: "2.0.0", PackageURL: "pkg:npm/mystery@2.0.0"},
+		{ChangeType: github.DependencyRemoved, Name: "old", Version: "1.0.0", License: "GPL-3.0-only"},
+	}
+
+	found := depreview.Findings(changes, depreview.LicensePolicy{Allow: []string{"MIT", "BSD-3-Clause"}})
+	require.Len(t, found, 3)
+
+	assert.Equal(t, findings.CategoryVulnerability, found[0].Category)
+	assert.Equal(t, findings.SeverityHigh, found[0].Severity)
+	assert.Equal(t, "github-dependency-review/GHSA-qppj-fm5r-hxr3", found[0].RuleID())
+	assert.Equal(t, "go.mod", found[0].Metadata[findings.MetadataPath])
+
+	assert.Equal(t, findings.CategoryPolicy, found[1].Category)
+	assert.Equal(t, findings.SeverityHigh, found[1].Severity)
+	assert.Contains(t, found[1].Title, "GPL-3.0-only")
+
+	assert.Equal(t, "github-dependency-review/unknown-license", found[2].RuleID())
+	assert.Equal(t, findings.SeverityLow, found[2].Severity)
+}
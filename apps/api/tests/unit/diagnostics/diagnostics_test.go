@@ -0,0 +1,161 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/diagnostics"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+const migrationsDir = "../../../internal/storage/migrations"
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := storage.OpenDatabase(filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, storage.NewMigrationManager(db, migrationsDir).MigrateWithLock(context.Background(), "test", time.Minute))
+	return db
+}
+
+func newProfiler(t *testing.T, retain int) (*diagnostics.Profiler, *clock.Fake) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	config := diagnostics.DefaultConfig()
+	config.Retain = retain
+	config.Clock = fake
+	return diagnostics.NewProfiler(diagnostics.NewStore(openDB(t)), config), fake
+}
+
+// capture runs a capture through the handler, advancing the fake clock past
+// the CPU profile once the profiler is waiting on it
+func capture(t *testing.T, handler http.Handler, fake *clock.Fake) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/?seconds=10", nil))
+	}()
+	fake.BlockUntil(1)
+	fake.Advance(10 * time.Second)
+	<-done
+	return recorder
+}
+
+func TestProfileBundle(t *testing.T) {
+	profiler, fake := newProfiler(t, 20)
+	handler := profiler.Handler()
+
+	recorder := capture(t, handler, fake)
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	var bundle diagnostics.Bundle
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&bundle))
+	assert.Equal(t, "profile-20240101T000010Z.tar.gz", bundle.Name)
+	assert.Equal(t, int64(10), bundle.Duration)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/"+strconv.FormatInt(bundle.ID, 10), nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/gzip", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, int(bundle.Size), recorder.Body.Len())
+
+	gz, err := gzip.NewReader(recorder.Body)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		files[header.Name], err = io.ReadAll(tr)
+		require.NoError(t, err)
+	}
+	assert.Len(t, files, 4)
+	assert.NotEmpty(t, files["cpu.pprof"])
+	assert.NotEmpty(t, files["heap.pprof"])
+	assert.NotEmpty(t, files["goroutine.pprof"])
+
+	var stats diagnostics.RuntimeStats
+	require.NoError(t, json.Unmarshal(files["runtime.json"], &stats))
+	assert.NotEmpty(t, stats.GoVersion)
+	assert.Positive(t, stats.Goroutines)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/999", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestProfileCaptureLimits(t *testing.T) {
+	t.Run("one_capture_at_a_time", func(t *testing.T) {
+		profiler, fake := newProfiler(t, 20)
+
+		result := make(chan error, 1)
+		go func() {
+			_, err := profiler.Capture(context.Background(), 10*time.Second)
+			result <- err
+		}()
+		fake.BlockUntil(1)
+
+		_, err := profiler.Capture(context.Background(), 10*time.Second)
+		assert.ErrorIs(t, err, diagnostics.ErrCaptureInProgress)
+
+		fake.Advance(10 * time.Second)
+		require.NoError(t, <-result)
+	})
+
+	t.Run("duration_limit", func(t *testing.T) {
+		profiler, _ := newProfiler(t, 20)
+		recorder := httptest.NewRecorder()
+		profiler.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/?seconds=3600", nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("cancelled_capture_is_discarded", func(t *testing.T) {
+		profiler, fake := newProfiler(t, 20)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		result := make(chan error, 1)
+		go func() {
+			_, err := profiler.Capture(ctx, 10*time.Second)
+			result <- err
+		}()
+		fake.BlockUntil(1)
+		cancel()
+		assert.ErrorIs(t, <-result, context.Canceled)
+
+		recorder := httptest.NewRecorder()
+		profiler.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.JSONEq(t, "[]", recorder.Body.String())
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		profiler, fake := newProfiler(t, 2)
+		handler := profiler.Handler()
+		for i := 1; i <= 3; i++ {
+			require.Equal(t, http.StatusCreated, capture(t, handler, fake).Code)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		var bundles []diagnostics.Bundle
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&bundles))
+		require.Len(t, bundles, 2)
+		assert.Equal(t, "profile-20240101T000030Z.tar.gz", bundles[0].Name)
+	})
+}
@@ -0,0 +1,218 @@
+package exceptions
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/exceptions"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+const migrationsDir = "../../../internal/storage/migrations"
+
+const scope = "ghcr.io/acme/payments"
+
+func newStore(t *testing.T, fake *clock.Fake) *exceptions.Store {
+	db, err := storage.OpenDatabase(filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, storage.NewMigrationManager(db, migrationsDir).MigrateWithLock(context.Background(), "test", time.Minute))
+
+	owners := &exceptions.OwnershipMap{Owners: []exceptions.OwnershipRule{
+		{Scope: "ghcr.io/acme/*", Approvers: []string{"alice", "bob"}},
+		{Scope: scope, Approvers: []string{"bob", "carol"}},
+	}}
+	config := exceptions.DefaultConfig()
+	config.Clock = fake
+	return exceptions.NewStore(db, owners, config)
+}
+
+func vulnerability(severity findings.Severity) findings.Finding {
+	return findings.New("osv", findings.CategoryVulnerability, severity, "CVE-2024-0001", "pkg:golang/example.com/lib@v1.0.0", "Example vulnerability")
+}
+
+func request(severity findings.Severity) exceptions.Request {
+	return exceptions.Request{
+		Scope:       scope,
+		FindingID:   vulnerability(severity).ID,
+		Severity:    severity,
+		Reason:      "Not reachable from the payment service",
+		RequestedBy: "alice",
+	}
+}
+
+func actions(t *testing.T, store *exceptions.Store, id string) []string {
+	entries, err := store.Audit(context.Background(), id)
+	require.NoError(t, err)
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, entry.Actor+":"+entry.Action)
+	}
+	return actions
+}
+
+func TestExceptionBelowThresholdTakesEffectImmediately(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, clock.NewFake(time.Now()))
+
+	exception, err := store.Request(ctx, request(findings.SeverityMedium))
+	require.NoError(t, err)
+	assert.Equal(t, exceptions.StatusApproved, exception.Status)
+	assert.Empty(t, exception.Approvers)
+
+	remaining, waived, err := store.Apply(ctx, scope, []findings.Finding{vulnerability(findings.SeverityMedium)})
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	require.Len(t, waived, 1)
+	assert.Equal(t, exception.ID, waived[0].ExceptionID)
+	assert.Equal(t, []string{"alice:requested", "alice:activated"}, actions(t, store, exception.ID))
+
+	// A finding that has since been rated more severe isn't covered
+	remaining, _, err = store.Apply(ctx, scope, []findings.Finding{vulnerability(findings.SeverityHigh)})
+	require.NoError(t, err)
+	assert.Len(t, remaining, 1)
+}
+
+func TestExceptionRequiresApprovalsFromOwners(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, clock.NewFake(time.Now()))
+	critical := []findings.Finding{vulnerability(findings.SeverityCritical)}
+
+	exception, err := store.Request(ctx, request(findings.SeverityCritical))
+	require.NoError(t, err)
+	assert.Equal(t, exceptions.StatusPending, exception.Status)
+	assert.Equal(t, 2, exception.RequiredApprovals)
+	// Owners of both matching rules are eligible, except the requester
+	require.Len(t, exception.Approvers, 2)
+	assert.Equal(t, "bob", exception.Approvers[0].Name)
+	assert.Equal(t, "carol", exception.Approvers[1].Name)
+
+	// Pending exceptions are not applied
+	remaining, _, err := store.Apply(ctx, scope, critical)
+	require.NoError(t, err)
+	assert.Len(t, remaining, 1)
+
+	_, err = store.Approve(ctx, exception.ID, "alice", "self-approval")
+	assert.True(t, errors.Is(err, exceptions.ErrNotApprover))
+
+	exception, err = store.Approve(ctx, exception.ID, "bob", "Reviewed call graph")
+	require.NoError(t, err)
+	assert.Equal(t, exceptions.StatusPending, exception.Status)
+	_, err = store.Approve(ctx, exception.ID, "bob", "again")
+	assert.ErrorContains(t, err, "already approved")
+
+	exception, err = store.Approve(ctx, exception.ID, "carol", "")
+	require.NoError(t, err)
+	assert.Equal(t, exceptions.StatusApproved, exception.Status)
+	assert.NotNil(t, exception.DecidedAt)
+
+	remaining, waived, err := store.Apply(ctx, scope, critical)
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	assert.Len(t, waived, 1)
+
+	// Another scope is unaffected
+	remaining, _, err = store.Apply(ctx, "ghcr.io/acme/ledger", critical)
+	require.NoError(t, err)
+	assert.Len(t, remaining, 1)
+
+	assert.Equal(t, []string{"alice:requested", "bob:approved", "carol:approved", "carol:activated"}, actions(t, store, exception.ID))
+}
+
+func TestExceptionRejectionAndRevocation(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, clock.NewFake(time.Now()))
+
+	rejected, err := store.Request(ctx, request(findings.SeverityHigh))
+	require.NoError(t, err)
+	rejected, err = store.Reject(ctx, rejected.ID, "carol", "Fix is available")
+	require.NoError(t, err)
+	assert.Equal(t, exceptions.StatusRejected, rejected.Status)
+	_, err = store.Approve(ctx, rejected.ID, "bob", "")
+	assert.True(t, errors.Is(err, exceptions.ErrNotPending))
+
+	revoked, err := store.Request(ctx, request(findings.SeverityLow))
+	require.NoError(t, err)
+	revoked, err = store.Revoke(ctx, revoked.ID, "bob", "Upgrade shipped")
+	require.NoError(t, err)
+	assert.Equal(t, exceptions.StatusRevoked, revoked.Status)
+	_, err = store.Revoke(ctx, revoked.ID, "bob", "")
+	assert.ErrorContains(t, err, "already revoked")
+
+	remaining, _, err := store.Apply(ctx, scope, []findings.Finding{vulnerability(findings.SeverityLow)})
+	require.NoError(t, err)
+	assert.Len(t, remaining, 1)
+
+	_, err = store.Get(ctx, "missing")
+	assert.True(t, errors.Is(err, exceptions.ErrNotFound))
+}
+
+func TestExceptionExpiry(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Now())
+	store := newStore(t, fake)
+
+	req := request(findings.SeverityCritical)
+	req.ExpiresAt = fake.Now().Add(365 * 24 * time.Hour)
+	_, err := store.Request(ctx, req)
+	assert.ErrorContains(t, err, "beyond the maximum")
+
+	req.ExpiresAt = fake.Now().Add(24 * time.Hour)
+	exception, err := store.Request(ctx, req)
+	require.NoError(t, err)
+	_, err = store.Approve(ctx, exception.ID, "bob", "")
+	require.NoError(t, err)
+	_, err = store.Approve(ctx, exception.ID, "carol", "")
+	require.NoError(t, err)
+
+	fake.Advance(25 * time.Hour)
+	remaining, waived, err := store.Apply(ctx, scope, []findings.Finding{vulnerability(findings.SeverityCritical)})
+	require.NoError(t, err)
+	assert.Len(t, remaining, 1)
+	assert.Empty(t, waived)
+
+	// Pending exceptions can't be approved once expired
+	pending, err := store.Request(ctx, request(findings.SeverityCritical))
+	require.NoError(t, err)
+	fake.Advance(91 * 24 * time.Hour)
+	_, err = store.Approve(ctx, pending.ID, "bob", "")
+	assert.True(t, errors.Is(err, exceptions.ErrNotPending))
+}
+
+func TestExceptionNeedsEnoughOwners(t *testing.T) {
+	store := newStore(t, clock.NewFake(time.Now()))
+
+	req := request(findings.SeverityCritical)
+	req.Scope = "ghcr.io/other/app"
+	_, err := store.Request(context.Background(), req)
+	assert.ErrorContains(t, err, "0 eligible approvers")
+
+	req = request(findings.SeverityCritical)
+	req.Reason = ""
+	_, err = store.Request(context.Background(), req)
+	assert.ErrorContains(t, err, "requires a reason")
+}
+
+func TestLoadOwnershipMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "owners.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"owners":[{"scope":"acme/*","approvers":["alice","bob","alice"]}]}`), 0o644))
+
+	owners, err := exceptions.LoadOwnershipMap(path)
+	require.NoError(t, err)
+	approvers, err := owners.Approvers(context.Background(), "ACME/payments")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, approvers)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"owners":[{"scope":"acme/*"}]}`), 0o644))
+	_, err = exceptions.LoadOwnershipMap(path)
+	assert.ErrorContains(t, err, "no approvers")
+}
@@ -0,0 +1,251 @@
+package faults
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+	"github.com/salman-frs/keystone/apps/api/internal/faults"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"advisories":["GHSA-1234","GHSA-5678"]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTransportPassesThroughWithoutFaults(t *testing.T) {
+	server := newServer(t)
+
+	for _, injector := range []*faults.Injector{nil, faults.NewInjector()} {
+		client := &http.Client{Transport: injector.Transport(faults.TargetGitHub, nil)}
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "GHSA-5678")
+	}
+
+	var injector *faults.Injector
+	base := http.DefaultTransport
+	assert.Equal(t, base, injector.Transport(faults.TargetGitHub, base))
+}
+
+func TestTransportInjectsErrors(t *testing.T) {
+	server := newServer(t)
+	injector := faults.NewInjector()
+	client := &http.Client{Transport: injector.Transport(faults.TargetGitHub, nil)}
+
+	require.NoError(t, injector.Set(faults.Fault{Target: faults.TargetGitHub, ErrorRate: 1}))
+	_, err := client.Get(server.URL)
+	assert.ErrorIs(t, err, faults.ErrInjected)
+
+	require.NoError(t, injector.Set(faults.Fault{Target: faults.TargetGitHub, ErrorRate: 1, StatusCode: http.StatusServiceUnavailable}))
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// Faults apply to their target only
+	rekor := &http.Client{Transport: injector.Transport(faults.TargetRekor, nil)}
+	resp, err = rekor.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	injector.Clear(faults.TargetGitHub)
+	resp, err = client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestTransportTruncatesResponses(t *testing.T) {
+	server := newServer(t)
+	injector := faults.NewInjector()
+	require.NoError(t, injector.Set(faults.Fault{Target: faults.TargetRegistry, PartialRate: 1}))
+	client := &http.Client{Transport: injector.Transport(faults.TargetRegistry, nil)}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestTransportAddsLatency(t *testing.T) {
+	server := newServer(t)
+	injector := faults.NewInjector()
+	require.NoError(t, injector.Set(faults.Fault{Target: faults.TargetNVD, LatencyMs: 100}))
+	client := &http.Client{Transport: injector.Transport(faults.TargetNVD, nil)}
+
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	// The caller's deadline still applies
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFaultsExpire(t *testing.T) {
+	server := newServer(t)
+	injector := faults.NewInjector()
+	require.NoError(t, injector.Set(faults.Fault{
+		Target:    faults.TargetGitHub,
+		ErrorRate: 1,
+		ExpiresAt: time.Now().Add(-time.Second),
+	}))
+	assert.Empty(t, injector.Faults())
+
+	client := &http.Client{Transport: injector.Transport(faults.TargetGitHub, nil)}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestFaultValidation(t *testing.T) {
+	injector := faults.NewInjector()
+	for _, fault := range []faults.Fault{
+		{Target: "smtp"},
+		{Target: faults.TargetGitHub, ErrorRate: 1.5},
+		{Target: faults.TargetGitHub, PartialRate: -0.1},
+		{Target: faults.TargetGitHub, LatencyMs: -1},
+		{Target: faults.TargetGitHub, StatusCode: http.StatusOK},
+		{Target: faults.TargetCache, StatusCode: http.StatusBadGateway},
+	} {
+		assert.Error(t, injector.Set(fault), "%+v", fault)
+	}
+	assert.Empty(t, injector.Faults())
+}
+
+// memoryCache is a map-backed faults.Cache
+type memoryCache map[string]interface{}
+
+func (c memoryCache) Get(ctx context.Context, key string) (interface{}, bool) {
+	value, ok := c[key]
+	return value, ok
+}
+
+func (c memoryCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	c[key] = value
+	return nil
+}
+
+func TestWrapCache(t *testing.T) {
+	ctx := context.Background()
+	injector := faults.NewInjector()
+	wrapped := injector.WrapCache(memoryCache{})
+
+	require.NoError(t, wrapped.Set(ctx, "maven:junit", "verified", time.Minute))
+	value, ok := wrapped.Get(ctx, "maven:junit")
+	assert.True(t, ok)
+	assert.Equal(t, "verified", value)
+
+	require.NoError(t, injector.Set(faults.Fault{Target: faults.TargetCache, ErrorRate: 1}))
+	_, ok = wrapped.Get(ctx, "maven:junit")
+	assert.False(t, ok)
+	assert.ErrorIs(t, wrapped.Set(ctx, "maven:junit", "verified", time.Minute), faults.ErrInjected)
+}
+
+func TestRunAppliesPublishedFaults(t *testing.T) {
+	bus := events.NewMemoryBus()
+	defer bus.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	api, worker := faults.NewInjector(), faults.NewInjector()
+	go worker.Run(ctx, bus)
+
+	require.NoError(t, api.Set(faults.Fault{Target: faults.TargetRekor, LatencyMs: 250}))
+	require.Eventually(t, func() bool {
+		require.NoError(t, api.Publish(ctx, bus, "keystone-api"))
+		return len(worker.Faults()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(250), worker.Faults()[0].LatencyMs)
+
+	api.Clear("")
+	require.NoError(t, api.Publish(ctx, bus, "keystone-api"))
+	require.Eventually(t, func() bool { return len(worker.Faults()) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInjectedErrorsOpenTheCircuitBreaker(t *testing.T) {
+	server := newServer(t)
+	injector := faults.NewInjector()
+	require.NoError(t, injector.Set(faults.Fault{Target: faults.TargetGitHub, ErrorRate: 1}))
+	client := &http.Client{Transport: injector.Transport(faults.TargetGitHub, nil)}
+
+	config := circuit.DefaultConfig()
+	config.FailureThreshold = 3
+	breaker := circuit.New(config)
+	call := func(ctx context.Context) error {
+		return breaker.Call(ctx, func() error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			return resp.Body.Close()
+		})
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, call(context.Background()), faults.ErrInjected)
+	}
+	assert.Equal(t, circuit.StateOpen, breaker.State())
+	assert.True(t, errors.Is(call(context.Background()), circuit.ErrCircuitOpen))
+}
+
+func TestInjectedErrorsTakeTheDetectorOffline(t *testing.T) {
+	server := newServer(t)
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	detector := cache.NewOfflineDetector(db, nil)
+
+	injector := faults.NewInjector()
+	require.NoError(t, injector.Set(faults.Fault{Target: faults.TargetGitHub, ErrorRate: 1, StatusCode: http.StatusBadGateway}))
+	require.NoError(t, injector.Set(faults.Fault{Target: faults.TargetNVD, ErrorRate: 1}))
+
+	for _, target := range []string{faults.TargetGitHub, faults.TargetNVD} {
+		client := &http.Client{Transport: detector.Transport(target, injector.Transport(target, nil))}
+		for i := 0; i < 3; i++ {
+			if resp, err := client.Get(server.URL); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	assert.True(t, detector.IsOffline())
+	assert.False(t, detector.Available(cache.CapabilityScanning))
+	assert.Equal(t, "HTTP 502", detector.GetServiceStatus()["github"].LastError)
+}
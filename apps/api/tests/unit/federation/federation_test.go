@@ -0,0 +1,229 @@
+package federation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/federation"
+)
+
+const ecrRegistry = "123456789012.dkr.ecr.eu-west-1.amazonaws.com"
+
+// audienceTokens issues a fake token naming the audience it was requested for
+type audienceTokens struct {
+	requests int32
+}
+
+func (s *audienceTokens) Token(ctx context.Context, audience string) (string, error) {
+	atomic.AddInt32(&s.requests, 1)
+	return "oidc-token-for-" + audience, nil
+}
+
+func newAWSServer(t *testing.T, now time.Time) (*httptest.Server, *int32) {
+	var assumed int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") == "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken" {
+			assert.Contains(t, r.Header.Get("Authorization"), "Credential=ASIAEXAMPLE/")
+			assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/ecr/aws4_request")
+			assert.Equal(t, "session-token", r.Header.Get("X-Amz-Security-Token"))
+			fmt.Fprintf(w, `{"authorizationData":[{"authorizationToken":%q,"expiresAt":%d.5}]}`,
+				base64.StdEncoding.EncodeToString([]byte("AWS:ecr-password")), now.Add(12*time.Hour).Unix())
+			return
+		}
+
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("WebIdentityToken") != "oidc-token-for-sts.amazonaws.com" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>InvalidIdentityToken</Code><Message>Incorrect token audience</Message></Error></ErrorResponse>`)
+			return
+		}
+		atomic.AddInt32(&assumed, 1)
+		assert.Equal(t, "AssumeRoleWithWebIdentity", r.PostForm.Get("Action"))
+		assert.Equal(t, "arn:aws:iam::123456789012:role/keystone", r.PostForm.Get("RoleArn"))
+		assert.Equal(t, "keystone-42", r.PostForm.Get("RoleSessionName"))
+		assert.Equal(t, "3600", r.PostForm.Get("DurationSeconds"))
+		fmt.Fprintf(w, `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIAEXAMPLE</AccessKeyId>
+      <SecretAccessKey>secret-key</SecretAccessKey>
+      <SessionToken>session-token</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`, now.Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	t.Cleanup(server.Close)
+	return server, &assumed
+}
+
+func TestAWSFederation(t *testing.T) {
+	t.Setenv("GITHUB_RUN_ID", "42")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(now)
+	server, assumed := newAWSServer(t, now)
+	tokens := &audienceTokens{}
+
+	aws, err := federation.NewAWS(tokens, federation.AWSConfig{
+		RoleARN:     "arn:aws:iam::123456789012:role/keystone",
+		STSEndpoint: server.URL,
+		ECREndpoint: server.URL,
+		Clock:       fake,
+	})
+	require.NoError(t, err)
+
+	credentials, err := aws.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ASIAEXAMPLE", credentials.AccessKeyID)
+	assert.Equal(t, "secret-key", credentials.SecretAccessKey.Reveal())
+	assert.NotContains(t, fmt.Sprintf("%+v", credentials), "secret-key")
+
+	registry := federation.Registries{AWS: aws}
+	username, password, err := registry.Credential(context.Background(), ecrRegistry)
+	require.NoError(t, err)
+	assert.Equal(t, "AWS", username)
+	assert.Equal(t, "ecr-password", password)
+
+	// Credentials are reused until they near expiry
+	_, _, err = registry.Credential(context.Background(), ecrRegistry)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(assumed))
+
+	fake.Advance(56 * time.Minute)
+	_, err = aws.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(assumed))
+	assert.Empty(t, credentials.SecretAccessKey.Reveal(), "replaced credentials are zeroed")
+
+	// Registries the clouds don't serve are left to other credentials
+	username, password, err = registry.Credential(context.Background(), "ghcr.io")
+	require.NoError(t, err)
+	assert.Empty(t, username+password)
+
+	aws.Release()
+	assert.Empty(t, credentials.SessionToken.Reveal())
+}
+
+func TestAWSFederationRejected(t *testing.T) {
+	server, _ := newAWSServer(t, time.Now())
+	aws, err := federation.NewAWS(&audienceTokens{}, federation.AWSConfig{
+		RoleARN:     "arn:aws:iam::123456789012:role/keystone",
+		Audience:    "sigstore",
+		STSEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+
+	_, err = aws.Credentials(context.Background())
+	assert.Equal(t, attestation.CodeFederationFailed, attestation.CodeOf(err))
+	assert.ErrorContains(t, err, "InvalidIdentityToken: Incorrect token audience")
+
+	_, err = federation.NewAWS(&audienceTokens{}, federation.AWSConfig{RoleARN: "keystone"})
+	assert.Error(t, err)
+}
+
+func TestGCPFederation(t *testing.T) {
+	const provider = "projects/123456789/locations/global/workloadIdentityPools/github/providers/github"
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(now)
+
+	var exchanged int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/token":
+			require.NoError(t, r.ParseForm())
+			if r.PostForm.Get("subject_token") != "oidc-token-for-https://iam.googleapis.com/"+provider {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_grant","error_description":"The audience in ID Token does not match the expected audience."}`)
+				return
+			}
+			atomic.AddInt32(&exchanged, 1)
+			assert.Equal(t, "//iam.googleapis.com/"+provider, r.PostForm.Get("audience"))
+			assert.Equal(t, "urn:ietf:params:oauth:token-type:jwt", r.PostForm.Get("subject_token_type"))
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "federated-token", "expires_in": 3600})
+		case "/v1/projects/-/serviceAccounts/pusher@acme.iam.gserviceaccount.com:generateAccessToken":
+			assert.Equal(t, "Bearer federated-token", r.Header.Get("Authorization"))
+			json.NewEncoder(w).Encode(map[string]interface{}{"accessToken": "impersonated-token", "expireTime": now.Add(time.Hour)})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	gcp, err := federation.NewGCP(&audienceTokens{}, federation.GCPConfig{
+		WorkloadIdentityProvider: "//iam.googleapis.com/" + provider,
+		STSEndpoint:              server.URL,
+		IAMCredentialsEndpoint:   server.URL,
+		Clock:                    fake,
+	})
+	require.NoError(t, err)
+
+	registry := federation.Registries{GCP: gcp}
+	username, password, err := registry.Credential(context.Background(), "europe-west1-docker.pkg.dev")
+	require.NoError(t, err)
+	assert.Equal(t, "oauth2accesstoken", username)
+	assert.Equal(t, "federated-token", password)
+
+	impersonating, err := federation.NewGCP(&audienceTokens{}, federation.GCPConfig{
+		WorkloadIdentityProvider: provider,
+		ServiceAccount:           "pusher@acme.iam.gserviceaccount.com",
+		STSEndpoint:              server.URL,
+		IAMCredentialsEndpoint:   server.URL,
+		Clock:                    fake,
+	})
+	require.NoError(t, err)
+	token, err := impersonating.AccessToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "impersonated-token", token.Token.Reveal())
+	assert.Equal(t, now.Add(time.Hour), token.Expiry.UTC())
+
+	_, err = impersonating.AccessToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&exchanged), "cached tokens aren't exchanged again")
+
+	wrongAudience, err := federation.NewGCP(&audienceTokens{}, federation.GCPConfig{
+		WorkloadIdentityProvider: provider,
+		Audience:                 "sigstore",
+		STSEndpoint:              server.URL,
+	})
+	require.NoError(t, err)
+	_, err = wrongAudience.AccessToken(context.Background())
+	assert.Equal(t, attestation.CodeFederationFailed, attestation.CodeOf(err))
+	assert.ErrorContains(t, err, "invalid_grant")
+}
+
+func TestRegistryHosts(t *testing.T) {
+	assert.True(t, federation.IsECR(ecrRegistry))
+	assert.True(t, federation.IsECR(strings.ToUpper(ecrRegistry)+":443"))
+	assert.False(t, federation.IsECR("public.ecr.aws"))
+	assert.False(t, federation.IsECR("ghcr.io"))
+
+	assert.True(t, federation.IsArtifactRegistry("us-docker.pkg.dev"))
+	assert.True(t, federation.IsArtifactRegistry("eu.gcr.io"))
+	assert.False(t, federation.IsArtifactRegistry("docker.io"))
+}
+
+func TestRegistriesFromEnv(t *testing.T) {
+	t.Setenv(federation.AWSRoleARNEnv, "arn:aws:iam::123456789012:role/keystone")
+	t.Setenv(federation.GCPWorkloadIdentityProviderEnv, "")
+	registries, err := federation.RegistriesFromEnv(&audienceTokens{})
+	require.NoError(t, err)
+	assert.NotNil(t, registries.AWS)
+	assert.Nil(t, registries.GCP)
+
+	t.Setenv(federation.GCPWorkloadIdentityProviderEnv, "github")
+	_, err = federation.RegistriesFromEnv(&audienceTokens{})
+	assert.Error(t, err)
+}
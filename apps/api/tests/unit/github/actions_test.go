@@ -0,0 +1,117 @@
+package github
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestListWorkflowRuns(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/actions/workflows/release.yml/runs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "push", r.URL.Query().Get("event"))
+		assert.Equal(t, "abc123", r.URL.Query().Get("head_sha"))
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?event=push&head_sha=abc123&page=2>; rel="next"`, harness.Server.URL, r.URL.Path))
+			w.Write([]byte(`{"total_count": 2, "workflow_runs": [{"id": 2, "run_attempt": 1, "head_sha": "abc123"}]}`))
+			return
+		}
+		w.Write([]byte(`{"total_count": 2, "workflow_runs": [{"id": 1, "run_attempt": 2, "head_sha": "abc123"}]}`))
+	})
+
+	runs, err := harness.Client.ListWorkflowRuns(context.Background(), "acme", "widgets", github.WorkflowRunQuery{
+		Workflow: "release.yml",
+		Event:    "push",
+		HeadSHA:  "abc123",
+	})
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, int64(2), runs[0].ID)
+	assert.Equal(t, 2, runs[1].RunAttempt)
+}
+
+func TestDownloadArtifact(t *testing.T) {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	file, err := writer.Create("widgets.intoto.jsonl")
+	require.NoError(t, err)
+	file.Write([]byte(`{"payloadType":"application/vnd.in-toto+json"}`))
+	require.NoError(t, writer.Close())
+
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/actions/runs/7/artifacts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"total_count": 1, "artifacts": [{"id": 30, "name": "provenance", "workflow_run": {"id": 7}}]}`))
+	})
+	harness.Handle("/repos/acme/widgets/actions/artifacts/30/zip", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/storage/provenance.zip", http.StatusFound)
+	})
+	harness.Handle("/storage/provenance.zip", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive.Bytes())
+	})
+	harness.Handle("/repos/acme/widgets/actions/artifacts/31/zip", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+
+	artifacts, err := harness.Client.ListWorkflowRunArtifacts(context.Background(), "acme", "widgets", 7)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	assert.Equal(t, "provenance", artifacts[0].Name)
+
+	reader, err := harness.Client.DownloadArtifact(context.Background(), "acme", "widgets", artifacts[0].ID)
+	require.NoError(t, err)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	files, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	require.Len(t, files.File, 1)
+	assert.Equal(t, "widgets.intoto.jsonl", files.File[0].Name)
+
+	_, err = harness.Client.DownloadArtifact(context.Background(), "acme", "widgets", 31)
+	assert.ErrorIs(t, err, github.ErrArtifactExpired)
+}
+
+func TestVerifyRunClaim(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/actions/runs/7/attempts/2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 7, "run_attempt": 2, "head_sha": "abc123", "event": "push",
+			"path": ".github/workflows/release.yml@refs/heads/main", "status": "completed", "conclusion": "success"}`))
+	})
+
+	claim, err := github.ParseRunClaim("7", "2")
+	require.NoError(t, err)
+	claim.HeadSHA = "abc123"
+	claim.WorkflowPath = ".github/workflows/release.yml"
+	run, err := harness.Client.VerifyRunClaim(context.Background(), "acme", "widgets", claim)
+	require.NoError(t, err)
+	assert.Equal(t, github.WorkflowRunCompleted, run.Status)
+
+	claim.HeadSHA = "def456"
+	claim.Event = "workflow_dispatch"
+	_, err = harness.Client.VerifyRunClaim(context.Background(), "acme", "widgets", claim)
+	var mismatch *github.RunMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Len(t, mismatch.Fields, 2)
+	assert.ErrorContains(t, err, `head_sha "def456", actually "abc123"`)
+
+	// A run ID from another repository isn't found in this one
+	_, err = harness.Client.VerifyRunClaim(context.Background(), "acme", "widgets", github.RunClaim{RunID: 8})
+	var statusErr *github.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+
+	_, err = github.ParseRunClaim("latest", "")
+	assert.Error(t, err)
+	_, err = github.ParseRunClaim("7", "0")
+	assert.Error(t, err)
+}
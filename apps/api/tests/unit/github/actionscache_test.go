@@ -0,0 +1,227 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/actionscache"
+)
+
+// cacheService is a stub of the Actions cache service, storing entries in
+// memory and serving them as ranged storage downloads
+type cacheService struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mutex   sync.Mutex
+	entries []*cacheEntry // In reservation order
+	chunks  int           // Upload requests
+	ranges  int           // Download requests
+}
+
+type cacheEntry struct {
+	key, version string
+	data         []byte
+	committed    bool
+}
+
+func newCacheService(t *testing.T) *cacheService {
+	s := &cacheService{t: t}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_apis/artifactcache/cache", s.lookup)
+	mux.HandleFunc("/_apis/artifactcache/caches", s.reserve)
+	mux.HandleFunc("/_apis/artifactcache/caches/", s.upload)
+	mux.HandleFunc("/storage/", s.download)
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *cacheService) lookup(w http.ResponseWriter, r *http.Request) {
+	assert.Equal(s.t, "Bearer runtime-token", r.Header.Get("Authorization"))
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// The newest committed entry with the prefix and version
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		entry := s.entries[i]
+		if entry.committed && entry.version == r.URL.Query().Get("version") && strings.HasPrefix(entry.key, r.URL.Query().Get("keys")) {
+			fmt.Fprintf(w, `{"cacheKey": %q, "archiveLocation": "%s/storage/%d"}`, entry.key, s.server.URL, i)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *cacheService) reserve(w http.ResponseWriter, r *http.Request) {
+	var reservation struct {
+		Key     string `json:"key"`
+		Version string `json:"version"`
+	}
+	require.NoError(s.t, json.NewDecoder(r.Body).Decode(&reservation))
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, entry := range s.entries {
+		if entry.key == reservation.Key && entry.version == reservation.Version {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+	}
+	s.entries = append(s.entries, &cacheEntry{key: reservation.Key, version: reservation.Version})
+	fmt.Fprintf(w, `{"cacheId": %d}`, len(s.entries)-1)
+}
+
+func (s *cacheService) upload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/_apis/artifactcache/caches/"))
+	require.NoError(s.t, err)
+	body, err := io.ReadAll(r.Body)
+	require.NoError(s.t, err)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry := s.entries[id]
+	switch r.Method {
+	case "PATCH":
+		var start, end int
+		_, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/*", &start, &end)
+		require.NoError(s.t, err)
+		assert.Equal(s.t, len(entry.data), start, "chunks are uploaded in order")
+		assert.Equal(s.t, end-start+1, len(body))
+		entry.data = append(entry.data, body...)
+		s.chunks++
+	case "POST":
+		var commit struct {
+			Size int `json:"size"`
+		}
+		require.NoError(s.t, json.Unmarshal(body, &commit))
+		assert.Equal(s.t, len(entry.data), commit.Size)
+		entry.committed = true
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *cacheService) download(w http.ResponseWriter, r *http.Request) {
+	assert.Empty(s.t, r.Header.Get("Authorization"), "storage takes no credentials")
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/storage/"))
+	require.NoError(s.t, err)
+	s.mutex.Lock()
+	data := s.entries[id].data
+	s.ranges++
+	s.mutex.Unlock()
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+func newCacheClient(service *cacheService, clk clock.Clock, scope string) *actionscache.Client {
+	return actionscache.NewClient(actionscache.Config{
+		URL:       service.server.URL,
+		Token:     secret.New("runtime-token"),
+		Scope:     scope,
+		ChunkSize: 8,
+		Clock:     clk,
+	})
+}
+
+func TestActionsCache(t *testing.T) {
+	service := newCacheService(t)
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	client := newCacheClient(service, clk, "")
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, "cve:CVE-2024-0001")
+	assert.ErrorIs(t, err, actionscache.ErrNotFound)
+
+	// Entries larger than a chunk go up and come down in several requests
+	value := []byte(`{"id":"CVE-2024-0001","severity":"high"}`)
+	require.NoError(t, client.Set(ctx, "cve:CVE-2024-0001", value, time.Hour))
+	assert.Greater(t, service.chunks, 1)
+	got, err := client.Get(ctx, "cve:CVE-2024-0001")
+	require.NoError(t, err)
+	assert.Equal(t, value, got)
+	assert.Greater(t, service.ranges, 1)
+
+	// A key isn't a prefix of another's entries
+	_, err = client.Get(ctx, "cve:CVE-2024-000")
+	assert.ErrorIs(t, err, actionscache.ErrNotFound)
+
+	// Entries can't be replaced, so the newest wins
+	clk.Advance(time.Minute)
+	require.NoError(t, client.Set(ctx, "cve:CVE-2024-0001", []byte(`{}`), time.Hour))
+	got, err = client.Get(ctx, "cve:CVE-2024-0001")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{}`), got)
+
+	clk.Advance(time.Hour)
+	_, err = client.Get(ctx, "cve:CVE-2024-0001")
+	assert.ErrorIs(t, err, actionscache.ErrNotFound, "expired")
+
+	clk.Advance(time.Minute)
+	require.NoError(t, client.Set(ctx, "cve:CVE-2024-0001", value, 0))
+	clk.Advance(time.Minute)
+	require.NoError(t, client.Delete(ctx, "cve:CVE-2024-0001"))
+	_, err = client.Get(ctx, "cve:CVE-2024-0001")
+	assert.ErrorIs(t, err, actionscache.ErrNotFound, "deleted")
+
+	// Other scopes don't see the entries
+	require.NoError(t, client.Set(ctx, "sbom:widgets", value, 0))
+	_, err = newCacheClient(service, clk, "other").Get(ctx, "sbom:widgets")
+	assert.ErrorIs(t, err, actionscache.ErrNotFound)
+
+	// Overlong keys are hashed to fit the service's limit
+	long := strings.Repeat("k", 600)
+	require.NoError(t, client.Set(ctx, long, value, 0))
+	got, err = client.Get(ctx, long)
+	require.NoError(t, err)
+	assert.Equal(t, value, got)
+}
+
+func TestActionsCacheAsL3(t *testing.T) {
+	service := newCacheService(t)
+	client := newCacheClient(service, nil, "")
+	ctx := context.Background()
+
+	open := func() *cache.HierarchicalCache {
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cache.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		hierCache, err := cache.NewHierarchicalCache(cache.DefaultCacheConfig(), db, client)
+		require.NoError(t, err)
+		t.Cleanup(func() { hierCache.Close() })
+		return hierCache
+	}
+
+	require.NoError(t, open().Set(ctx, "verify:sha256:abc", map[string]interface{}{"verified": true}, time.Hour))
+
+	// Another job, with empty L1 and L2 caches, finds it in the Actions cache
+	value, found := open().Get(ctx, "verify:sha256:abc")
+	require.True(t, found)
+	assert.Equal(t, map[string]interface{}{"verified": true}, value)
+}
+
+func TestActionsCacheConfigFromEnv(t *testing.T) {
+	t.Setenv(actionscache.CacheURLEnv, "")
+	t.Setenv(actionscache.RuntimeTokenEnv, "")
+	_, err := actionscache.ConfigFromEnv()
+	assert.ErrorIs(t, err, actionscache.ErrNoCacheService)
+
+	t.Setenv(actionscache.CacheURLEnv, "https://artifactcache.actions.githubusercontent.com/abc/")
+	t.Setenv(actionscache.RuntimeTokenEnv, "runtime-token")
+	config, err := actionscache.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "runtime-token", config.Token.Reveal())
+}
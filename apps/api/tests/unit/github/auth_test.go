@@ -0,0 +1,152 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+// verifyAppJWT checks an app JWT's signature and returns its claims
+func verifyAppJWT(t *testing.T, token string, key *rsa.PublicKey) map[string]interface{} {
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.NoError(t, rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature))
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	return claims
+}
+
+func TestAppAuthentication(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+
+	minted := 0
+	harness.Handle("/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		claims := verifyAppJWT(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &key.PublicKey)
+		assert.Equal(t, "7", claims["iss"])
+		assert.Equal(t, float64(harness.Clock.Now().Add(-time.Minute).Unix()), claims["iat"])
+
+		var body struct{ Permissions map[string]string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]string{"contents": "read"}, body.Permissions)
+
+		minted++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token":"ghs_installation%d","expires_at":%q}`, minted, harness.Clock.Now().Add(time.Hour).Format(time.RFC3339))
+	})
+	var authorizations []string
+	harness.Handle("/repos/acme/widgets", func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"default_branch":"main"}`))
+	})
+
+	config := github.DefaultConfig("")
+	config.BaseURL = harness.Server.URL
+	config.Clock = harness.Clock
+	config.App = &github.AppConfig{AppID: 7, InstallationID: 42, PrivateKey: key, Permissions: map[string]string{"contents": "read"}}
+	client := github.NewClient(config)
+	assert.Equal(t, github.CredentialApp, client.CredentialKind())
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GetRepository(context.Background(), "acme", "widgets")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, minted, "installation tokens are cached")
+
+	// Tokens are minted again shortly before they expire
+	harness.Clock.Advance(56 * time.Minute)
+	_, err = client.GetRepository(context.Background(), "acme", "widgets")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"token ghs_installation1", "token ghs_installation1", "token ghs_installation2"}, authorizations)
+
+	client.Close()
+}
+
+func TestAppAuthenticationRejected(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	config := github.DefaultConfig("")
+	config.BaseURL = harness.Server.URL
+	config.App = &github.AppConfig{AppID: 7, InstallationID: 42, PrivateKey: key}
+	_, err = github.NewClient(config).GetRepository(context.Background(), "acme", "widgets")
+	assert.ErrorContains(t, err, "installation token API returned status 401")
+	assert.Zero(t, harness.Requests("/repos/acme/widgets"))
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	for _, env := range []string{github.AppIDEnv, github.AppInstallationIDEnv, github.AppPrivateKeyEnv, github.AppPrivateKeyPathEnv, github.PATEnv, github.WorkflowTokenEnv} {
+		t.Setenv(env, "")
+	}
+	_, err := github.ConfigFromEnv()
+	assert.ErrorIs(t, err, github.ErrNoCredentials)
+
+	t.Setenv(github.WorkflowTokenEnv, "ghs_workflow")
+	config, err := github.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "ghs_workflow", config.Token.Reveal())
+	assert.Equal(t, github.CredentialWorkflowToken, github.NewClient(config).CredentialKind())
+	assert.Less(t, config.RateLimitThreshold, github.DefaultConfig("").RateLimitThreshold, "GITHUB_TOKEN has a lower rate limit")
+
+	t.Setenv(github.PATEnv, "ghp_personal")
+	config, err = github.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_personal", config.Token.Reveal())
+	assert.Equal(t, github.CredentialPAT, github.NewClient(config).CredentialKind())
+	assert.Empty(t, config.Tokens)
+
+	// Several tokens are pooled
+	t.Setenv(github.PATEnv, "ghp_first, ghp_second,,ghp_third")
+	config, err = github.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_first", config.Token.Reveal())
+	require.Len(t, config.Tokens, 2)
+	assert.Equal(t, "ghp_second", config.Tokens[0].Reveal())
+	assert.Equal(t, "ghp_third", config.Tokens[1].Reveal())
+
+	// App settings take precedence, with PKCS #8 keys too
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	t.Setenv(github.AppIDEnv, "7")
+	t.Setenv(github.AppInstallationIDEnv, "42")
+	t.Setenv(github.AppPrivateKeyEnv, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})))
+	config, err = github.ConfigFromEnv()
+	require.NoError(t, err)
+	require.NotNil(t, config.App)
+	assert.Equal(t, int64(42), config.App.InstallationID)
+	assert.True(t, key.Equal(config.App.PrivateKey))
+
+	t.Setenv(github.AppPrivateKeyEnv, "not a key")
+	_, err = github.ConfigFromEnv()
+	assert.ErrorContains(t, err, "not PEM encoded")
+}
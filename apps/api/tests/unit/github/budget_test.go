@@ -0,0 +1,175 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// budgetedClient returns a client whose server reports one less request
+// remaining after each response, starting from remaining, in the window
+// ending at *reset
+func budgetedClient(t *testing.T, budget *github.BudgetManager, remaining int, reset *atomic.Int64) *github.Client {
+	var left atomic.Int32
+	left.Store(int32(remaining + 1))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(left.Add(-1))))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Load(), 10))
+		w.Header().Set("X-RateLimit-Used", "1")
+		w.Write([]byte(`{"full_name": "acme/widgets"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	config := github.DefaultConfig("test-token")
+	config.BaseURL = server.URL
+	config.RateLimitThreshold = 0 // Keep the client's own backoff out of the way
+	config.Budget = budget
+	client := github.NewClient(config)
+
+	// The first response reports the window budgets are allotted from
+	_, err := client.GetRepository(context.Background(), "acme", "widgets")
+	require.NoError(t, err)
+	return client
+}
+
+func TestBudgetManager(t *testing.T) {
+	budget, err := github.NewBudgetManager(map[github.Consumer]float64{
+		github.ConsumerAdvisorySync:      0.3,
+		github.ConsumerAttestationChecks: 0.5,
+	})
+	require.NoError(t, err)
+	var reset atomic.Int64
+	reset.Store(time.Now().Add(time.Hour).Unix())
+	client := budgetedClient(t, budget, 10, &reset)
+
+	syncing := github.WithConsumer(context.Background(), github.ConsumerAdvisorySync)
+	checking := github.WithConsumer(context.Background(), github.ConsumerAttestationChecks)
+
+	// The sync spends its 3 requests, then borrows the 2 nobody was allotted
+	for i := 0; i < 5; i++ {
+		_, err := client.GetRepository(syncing, "acme", "widgets")
+		require.NoError(t, err, "request %d", i+1)
+	}
+	// The 5 left are reserved for attestation checks
+	_, err = client.GetRepository(syncing, "acme", "widgets")
+	var budgetErr *github.BudgetError
+	require.ErrorAs(t, err, &budgetErr)
+	assert.ErrorIs(t, err, github.ErrBudgetExhausted)
+	assert.Equal(t, github.ConsumerAdvisorySync, budgetErr.Consumer)
+	assert.Equal(t, reset.Load(), budgetErr.Reset.Unix())
+
+	_, err = client.GetRepository(checking, "acme", "widgets")
+	assert.NoError(t, err)
+	// Requests without a budget aren't refused
+	_, err = client.GetRepository(context.Background(), "acme", "widgets")
+	assert.NoError(t, err)
+	_, err = client.GetRepository(github.WithConsumer(context.Background(), github.ConsumerPullRequestReports), "acme", "widgets")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []github.BudgetUsage{
+		{Consumer: github.ConsumerAdvisorySync, Share: 0.3, Allotted: 3, Spent: 5},
+		{Consumer: github.ConsumerAttestationChecks, Share: 0.5, Allotted: 5, Spent: 1},
+	}, client.Stats().Budgets)
+
+	// The next window is allotted afresh
+	reset.Add(3600)
+	_, err = client.GetRepository(context.Background(), "acme", "widgets")
+	require.NoError(t, err)
+	_, err = client.GetRepository(syncing, "acme", "widgets")
+	assert.NoError(t, err)
+	usage := client.Stats().Budgets
+	assert.Equal(t, 1, usage[0].Spent)
+	assert.Equal(t, 0, usage[1].Spent)
+}
+
+func TestParseBudgets(t *testing.T) {
+	shares, err := github.ParseBudgets(" advisory-sync=0.25, attestation-checks = 0.5 ,")
+	require.NoError(t, err)
+	assert.Equal(t, map[github.Consumer]float64{
+		github.ConsumerAdvisorySync:      0.25,
+		github.ConsumerAttestationChecks: 0.5,
+	}, shares)
+	_, err = github.NewBudgetManager(shares)
+	assert.NoError(t, err)
+
+	_, err = github.ParseBudgets("advisory-sync")
+	assert.Error(t, err)
+	_, err = github.ParseBudgets("advisory-sync=most")
+	assert.Error(t, err)
+
+	_, err = github.NewBudgetManager(map[github.Consumer]float64{github.ConsumerAdvisorySync: 0})
+	assert.Error(t, err)
+	_, err = github.NewBudgetManager(map[github.Consumer]float64{
+		github.ConsumerAdvisorySync:      0.6,
+		github.ConsumerAttestationChecks: 0.6,
+	})
+	assert.Error(t, err)
+}
+
+func TestBudgetManagerTokenPool(t *testing.T) {
+	budget, err := github.NewBudgetManager(map[github.Consumer]float64{
+		github.ConsumerAdvisorySync:      0.5,
+		github.ConsumerAttestationChecks: 0.5,
+	})
+	require.NoError(t, err)
+
+	// Each token has 10 requests left in a window of its own
+	resets := map[string]int64{
+		"token first":  time.Now().Add(time.Hour).Unix(),
+		"token second": time.Now().Add(2 * time.Hour).Unix(),
+	}
+	var firstLeft, secondLeft atomic.Int32
+	firstLeft.Store(11)
+	secondLeft.Store(11)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		left := &firstLeft
+		if authorization == "token second" {
+			left = &secondLeft
+		}
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(left.Add(-1))))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resets[authorization], 10))
+		w.Header().Set("X-RateLimit-Used", "1")
+		w.Write([]byte(`{"full_name": "acme/widgets"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	config := github.DefaultConfig("first")
+	config.Tokens = []*secret.String{secret.New("second")}
+	config.BaseURL = server.URL
+	config.RateLimitThreshold = 0
+	config.Budget = budget
+	client := github.NewClient(config)
+	for i := 0; i < 2; i++ {
+		_, err := client.GetRepository(context.Background(), "acme", "widgets")
+		require.NoError(t, err)
+	}
+
+	// Alternating tokens doesn't start a new window: the sync spends its 10 of
+	// the 20 left, and the other 10 stay reserved
+	syncing := github.WithConsumer(context.Background(), github.ConsumerAdvisorySync)
+	for i := 0; i < 10; i++ {
+		_, err := client.GetRepository(syncing, "acme", "widgets")
+		require.NoError(t, err, "request %d", i+1)
+	}
+	_, err = client.GetRepository(syncing, "acme", "widgets")
+	assert.ErrorIs(t, err, github.ErrBudgetExhausted)
+	assert.Equal(t, int32(5), firstLeft.Load())
+	assert.Equal(t, int32(5), secondLeft.Load())
+	assert.Equal(t, []github.BudgetUsage{
+		{Consumer: github.ConsumerAdvisorySync, Share: 0.5, Allotted: 10, Spent: 10},
+		{Consumer: github.ConsumerAttestationChecks, Share: 0.5, Allotted: 10, Spent: 0},
+	}, client.Stats().Budgets)
+}
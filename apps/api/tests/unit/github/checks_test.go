@@ -0,0 +1,90 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestCreateCheckRunBatchesAnnotations(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	var batches []int
+	harness.Handle("/repos/acme/widgets/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		var body github.CheckRunOptions
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "keystone", body.Name)
+		assert.Equal(t, "abc123", body.HeadSHA)
+		batches = append(batches, len(body.Output.Annotations))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":42,"name":"keystone","status":"completed"}`))
+	})
+	harness.Handle("/repos/acme/widgets/check-runs/42", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PATCH", r.Method)
+		var body github.CheckRunOptions
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Empty(t, body.HeadSHA)
+		assert.Equal(t, "Verified", body.Output.Title, "updates carrying annotations repeat the output")
+		batches = append(batches, len(body.Output.Annotations))
+		w.Write([]byte(`{"id":42,"name":"keystone","status":"completed"}`))
+	})
+
+	output := &github.CheckRunOutput{Title: "Verified", Summary: "All good"}
+	for i := 0; i < 120; i++ {
+		output.Annotations = append(output.Annotations, github.CheckAnnotation{
+			Path: "go.mod", StartLine: i + 1, EndLine: i + 1, AnnotationLevel: github.AnnotationNotice, Message: fmt.Sprint(i),
+		})
+	}
+	run, err := harness.Client.CreateCheckRun(context.Background(), "acme", "widgets", github.CheckRunOptions{
+		Name:       "keystone",
+		HeadSHA:    "abc123",
+		Status:     github.CheckRunCompleted,
+		Conclusion: github.CheckConclusionSuccess,
+		Output:     output,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), run.ID)
+	assert.Equal(t, []int{50, 50, 20}, batches)
+	assert.Len(t, output.Annotations, 120, "the caller's options are left alone")
+}
+
+func TestFindCheckRun(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/commits/abc123/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "latest", r.URL.Query().Get("filter"))
+		if r.URL.Query().Get("check_name") != "keystone" {
+			w.Write([]byte(`{"total_count":0,"check_runs":[]}`))
+			return
+		}
+		w.Write([]byte(`{"total_count":1,"check_runs":[{"id":7,"name":"keystone","status":"in_progress"}]}`))
+	})
+
+	run, err := harness.Client.FindCheckRun(context.Background(), "acme", "widgets", "abc123", "keystone")
+	require.NoError(t, err)
+	require.NotNil(t, run)
+	assert.Equal(t, github.CheckRunInProgress, run.Status)
+
+	run, err = harness.Client.FindCheckRun(context.Background(), "acme", "widgets", "abc123", "other")
+	require.NoError(t, err)
+	assert.Nil(t, run)
+}
+
+func TestCreateCheckRunRequiresApp(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	})
+
+	_, err := harness.Client.CreateCheckRun(context.Background(), "acme", "widgets", github.CheckRunOptions{Name: "keystone", HeadSHA: "abc123"})
+	assert.ErrorContains(t, err, "GitHub Apps")
+
+	_, err = harness.Client.CreateCheckRun(context.Background(), "acme", "widgets", github.CheckRunOptions{Name: "keystone"})
+	assert.ErrorContains(t, err, "required")
+}
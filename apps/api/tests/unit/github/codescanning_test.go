@@ -0,0 +1,125 @@
+package github
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestListCodeScanningAlerts(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/code-scanning/alerts", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "open", r.URL.Query().Get("state"))
+		assert.Equal(t, "keystone", r.URL.Query().Get("tool_name"))
+		w.Write([]byte(`[{"number":3,"state":"open","rule":{"id":"osv/GHSA-1234","security_severity_level":"high"},
+			"tool":{"name":"keystone"},"most_recent_instance":{"ref":"refs/heads/main","location":{"path":"go.mod","start_line":7}}}]`))
+	})
+
+	alerts, err := harness.Client.ListCodeScanningAlerts(context.Background(), "acme", "widgets",
+		github.CodeScanningAlertQuery{State: "open", ToolName: "keystone"})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 3, alerts[0].Number)
+	assert.Equal(t, "osv/GHSA-1234", alerts[0].Rule.ID)
+	assert.Equal(t, "high", alerts[0].Rule.SecuritySeverityLevel)
+	assert.Equal(t, "go.mod", alerts[0].MostRecentInstance.Location.Path)
+	assert.Equal(t, 7, alerts[0].MostRecentInstance.Location.StartLine)
+}
+
+func TestUploadSARIF(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	var uploaded map[string]interface{}
+	harness.Handle("/repos/acme/widgets/code-scanning/sarifs", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "abc123", body["commit_sha"])
+		assert.Equal(t, "refs/heads/main", body["ref"])
+
+		compressed, err := base64.StdEncoding.DecodeString(body["sarif"])
+		require.NoError(t, err)
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		require.NoError(t, err)
+		log, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(log, &uploaded))
+
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"id":"47177e22","url":"https://api.github.com/repos/acme/widgets/code-scanning/sarifs/47177e22"}`))
+	})
+	var polls int
+	harness.Handle("/repos/acme/widgets/code-scanning/sarifs/47177e22", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls < 3 {
+			w.Write([]byte(`{"processing_status":"pending"}`))
+			return
+		}
+		w.Write([]byte(`{"processing_status":"complete","analyses_url":"https://api.github.com/repos/acme/widgets/code-scanning/analyses?sarif_id=47177e22"}`))
+	})
+
+	finding := findings.New("osv", findings.CategoryVulnerability, findings.SeverityHigh, "GHSA-1234", "pkg:golang/golang.org/x/net@v0.1.0", "Vulnerable golang.org/x/net")
+	finding.Metadata[findings.MetadataLine] = "7"
+	log, err := findings.SARIF([]findings.Finding{finding}, findings.SARIFOptions{ToolVersion: "1.4.0", Path: "go.mod"})
+	require.NoError(t, err)
+
+	status, err := harness.Client.UploadSARIF(context.Background(), "acme", "widgets", github.SARIFUpload{
+		CommitSHA: "abc123",
+		Ref:       "refs/heads/main",
+		SARIF:     log,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "47177e22", status.ID)
+	assert.Equal(t, github.SARIFPending, status.ProcessingStatus)
+
+	// The upload carries the finding as a result GitHub can track across runs
+	runs := uploaded["runs"].([]interface{})
+	result := runs[0].(map[string]interface{})["results"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "osv/GHSA-1234", result["ruleId"])
+	assert.Equal(t, "error", result["level"])
+	assert.Equal(t, finding.ID, result["partialFingerprints"].(map[string]interface{})["keystoneFindingId/v1"])
+
+	done := make(chan error, 1)
+	go func() {
+		status, err = harness.Client.WaitForSARIF(context.Background(), "acme", "widgets", status.ID, time.Second)
+		done <- err
+	}()
+	require.NoError(t, harness.Await(t, done, time.Second))
+	assert.Equal(t, github.SARIFComplete, status.ProcessingStatus)
+	assert.Contains(t, status.AnalysesURL, "sarif_id=47177e22")
+	assert.Equal(t, 3, polls)
+}
+
+func TestUploadSARIFFailedProcessing(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/code-scanning/sarifs/bad", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"processing_status":"failed","errors":["locationFromSarifResult: expected an artifact location"]}`))
+	})
+
+	status, err := harness.Client.WaitForSARIF(context.Background(), "acme", "widgets", "bad", time.Second)
+	assert.ErrorContains(t, err, "expected an artifact location")
+	assert.Equal(t, github.SARIFFailed, status.ProcessingStatus)
+}
+
+func TestSARIFRequiresLocation(t *testing.T) {
+	finding := findings.New("osv", findings.CategoryVulnerability, findings.SeverityLow, "GHSA-1234", "pkg:npm/left-pad@1.0.0", "Vulnerable left-pad")
+	_, err := findings.SARIF([]findings.Finding{finding}, findings.SARIFOptions{})
+	assert.ErrorContains(t, err, "no path")
+
+	finding.Metadata[findings.MetadataPath] = "./package-lock.json"
+	log, err := findings.SARIF([]findings.Finding{finding}, findings.SARIFOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, string(log), `"uri":"package-lock.json"`)
+	assert.Contains(t, string(log), `"name":"keystone"`)
+}
@@ -0,0 +1,182 @@
+package github
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+const migrationsDir = "../../../internal/storage/migrations"
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := storage.OpenDatabase(filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, storage.NewMigrationManager(db, migrationsDir).MigrateWithLock(context.Background(), "test", time.Minute))
+	return db
+}
+
+func listDeadLetters(t *testing.T, handler http.Handler) []github.DeadLetter {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var letters []github.DeadLetter
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&letters))
+	return letters
+}
+
+func TestQueueDeadLettersExhaustedRequests(t *testing.T) {
+	config := github.DefaultQueueConfig()
+	config.Workers = 1
+	config.BatchSize = 1
+	config.DeadLetters = github.NewDeadLetterStore(openDB(t))
+	harness := githubtest.New(t, config)
+	handler := harness.Queue.DeadLetterHandler()
+
+	var limited atomic.Bool
+	limited.Store(true)
+	harness.Handle("/repos/acme/widgets", func(w http.ResponseWriter, r *http.Request) {
+		if limited.Load() {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	harness.Handle("/repos/acme/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	fetch := func(repo string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			_, err := harness.Client.GetRepository(ctx, "acme", repo)
+			return err
+		}
+	}
+
+	err := harness.Await(t, harness.Queue.Enqueue(context.Background(), "widgets", github.PriorityHigh, fetch("widgets")), time.Second)
+	require.EqualError(t, err, "rate limit exceeded")
+
+	// Permanent errors that aren't retried are reported to the caller only
+	err = harness.Await(t, harness.Queue.Enqueue(context.Background(), "missing", github.PriorityHigh, fetch("missing")), time.Second)
+	require.Error(t, err)
+
+	letters := listDeadLetters(t, handler)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "widgets", letters[0].RequestID)
+	assert.Equal(t, github.PriorityHigh, letters[0].Priority)
+	assert.Equal(t, 1, letters[0].Attempts)
+	assert.Equal(t, "rate limit exceeded", letters[0].LastError)
+	assert.True(t, letters[0].Requeueable)
+	assert.Equal(t, int64(1), harness.Queue.Stats().DeadLettered)
+
+	t.Run("requeue", func(t *testing.T) {
+		limited.Store(false)
+		result, err := harness.Queue.Requeue(context.Background(), letters[0].ID)
+		require.NoError(t, err)
+		require.NoError(t, harness.Await(t, result, time.Second))
+
+		assert.Empty(t, listDeadLetters(t, handler))
+		assert.Equal(t, int64(1), harness.Queue.Stats().Requeued)
+
+		_, err = harness.Queue.Requeue(context.Background(), letters[0].ID)
+		assert.ErrorIs(t, err, github.ErrDeadLetterNotFound)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		limited.Store(true)
+		err := harness.Await(t, harness.Queue.Enqueue(context.Background(), "widgets", github.PriorityLow, fetch("widgets")), time.Second)
+		require.Error(t, err)
+		require.Len(t, listDeadLetters(t, handler), 1)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/", nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"purged":1}`, recorder.Body.String())
+		assert.Empty(t, listDeadLetters(t, handler))
+	})
+}
+
+func TestDeadLetterHandlerRequeueFromAnotherProcess(t *testing.T) {
+	db := openDB(t)
+	store := github.NewDeadLetterStore(db)
+	id, err := store.Add(context.Background(), github.DeadLetter{
+		RequestID:  "orphaned",
+		Attempts:   4,
+		LastError:  "rate limit exceeded",
+		EnqueuedAt: time.Now(),
+		FailedAt:   time.Now(),
+	})
+	require.NoError(t, err)
+
+	config := github.DefaultQueueConfig()
+	config.DeadLetters = store
+	handler := githubtest.New(t, config).Queue.DeadLetterHandler()
+
+	letters := listDeadLetters(t, handler)
+	require.Len(t, letters, 1)
+	assert.False(t, letters[0].Requeueable)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/"+strconv.FormatInt(id, 10)+"/requeue", nil))
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/"+strconv.FormatInt(id, 10), nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	count, err := store.Count(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestQueueBoundsParkedRequests(t *testing.T) {
+	config := github.DefaultQueueConfig()
+	config.Workers = 1
+	config.BatchSize = 1
+	config.DeadLetters = github.NewDeadLetterStore(openDB(t))
+	config.MaxParked = 2
+	config.ParkedTTL = time.Hour
+	harness := githubtest.New(t, config)
+	handler := harness.Queue.DeadLetterHandler()
+	harness.Handle("/repos/acme/widgets", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	for _, id := range []string{"first", "second", "third"} {
+		err := harness.Await(t, harness.Queue.Enqueue(context.Background(), id, github.PriorityNormal, func(ctx context.Context) error {
+			_, err := harness.Client.GetRepository(ctx, "acme", "widgets")
+			return err
+		}), time.Second)
+		require.Error(t, err)
+	}
+
+	// Only the most recent requests are held; every dead letter is still listed
+	requeueable := map[string]bool{}
+	for _, letter := range listDeadLetters(t, handler) {
+		requeueable[letter.RequestID] = letter.Requeueable
+	}
+	assert.Equal(t, map[string]bool{"first": false, "second": true, "third": true}, requeueable)
+
+	harness.Clock.Advance(time.Hour)
+	for _, letter := range listDeadLetters(t, handler) {
+		assert.False(t, letter.Requeueable, letter.RequestID)
+		_, err := harness.Queue.Requeue(context.Background(), letter.ID)
+		assert.ErrorIs(t, err, github.ErrNotRequeueable)
+	}
+}
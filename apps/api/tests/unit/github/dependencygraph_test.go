@@ -0,0 +1,49 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+const dependencyGraphSBOM = `{"sbom":{"SPDXID":"SPDXRef-DOCUMENT","spdxVersion":"SPDX-2.3","name":"com.github.acme/widgets",
+	"packages":[
+		{"name":"com.github.acme/widgets","versionInfo":"main","externalRefs":[{"referenceCategory":"PACKAGE-MANAGER","referenceType":"purl","referenceLocator":"pkg:github/acme/widgets@main"}]},
+		{"name":"go:golang.org/x/net","versionInfo":"0.17.0","externalRefs":[{"referenceCategory":"PACKAGE-MANAGER","referenceType":"purl","referenceLocator":"pkg:golang/golang.org/x/net@v0.17.0"}]}
+	]}}`
+
+func TestGetRepositorySBOM(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/dependency-graph/sbom", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(dependencyGraphSBOM))
+	})
+
+	export, err := harness.Client.GetRepositorySBOM(context.Background(), "acme", "widgets")
+	require.NoError(t, err)
+	assert.Equal(t, sbom.FormatSPDX, export.Format)
+	assert.Equal(t, "2.3", export.SpecVersion)
+	require.Len(t, export.ComponentsByType("golang"), 1)
+	assert.Equal(t, "golang.org/x", export.ComponentsByType("golang")[0].Group)
+
+	// The raw document is kept for storing as it is
+	decoded, err := sbom.Decode(export.Data)
+	require.NoError(t, err)
+	assert.Equal(t, export.Document, decoded)
+}
+
+func TestGetRepositorySBOMDisabled(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/dependency-graph/sbom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	_, err := harness.Client.GetRepositorySBOM(context.Background(), "acme", "widgets")
+	assert.ErrorContains(t, err, "dependency graph may be disabled")
+}
@@ -0,0 +1,42 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestCompareDependencies(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/dependency-graph/compare/main...feature/deps", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"change_type":"added","manifest":"go.mod","ecosystem":"gomod","name":"golang.org/x/net","version":"0.1.0",
+			"package_url":"pkg:golang/golang.org/x/net@0.1.0","license":"BSD-3-Clause","scope":"runtime",
+			"vulnerabilities":[{"severity":"high","advisory_ghsa_id":"GHSA-qppj-fm5r-hxr3","advisory_summary":"HTTP/2 rapid reset"}]},
+			{"change_type":"removed","manifest":"go.mod","ecosystem":"gomod","name":"github.com/pkg/errors","version":"0.9.1","vulnerabilities":[]}]`))
+	})
+
+	changes, err := harness.Client.CompareDependencies(context.Background(), "acme", "widgets", "main", "feature/deps")
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, github.DependencyAdded, changes[0].ChangeType)
+	assert.Equal(t, "BSD-3-Clause", changes[0].License)
+	require.Len(t, changes[0].Vulnerabilities, 1)
+	assert.Equal(t, "GHSA-qppj-fm5r-hxr3", changes[0].Vulnerabilities[0].AdvisoryGHSAID)
+	assert.Equal(t, github.DependencyRemoved, changes[1].ChangeType)
+}
+
+func TestCompareDependenciesUnavailable(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/dependency-graph/compare/main...abc123", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	_, err := harness.Client.CompareDependencies(context.Background(), "acme", "widgets", "main", "abc123")
+	assert.ErrorContains(t, err, "dependency graph is disabled")
+}
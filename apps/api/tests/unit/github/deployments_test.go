@@ -0,0 +1,73 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestEnvironments(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/environments", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"total_count": 2, "environments": [{"id": 1, "name": "staging"},
+			{"id": 2, "name": "production", "protection_rules": [{"id": 3, "type": "required_reviewers"}],
+			 "deployment_branch_policy": {"protected_branches": true, "custom_branch_policies": false}}]}`))
+	})
+
+	environments, err := harness.Client.ListEnvironments(context.Background(), "acme", "widgets")
+	require.NoError(t, err)
+	require.Len(t, environments, 2)
+	assert.Nil(t, environments[0].DeploymentBranchPolicy)
+	assert.Equal(t, "required_reviewers", environments[1].ProtectionRules[0].Type)
+	assert.True(t, environments[1].DeploymentBranchPolicy.ProtectedBranches)
+}
+
+func TestCreateDeployment(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/deployments", func(w http.ResponseWriter, r *http.Request) {
+		var options map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&options))
+		assert.Equal(t, false, options["auto_merge"])
+		if options["ref"] == "unverified" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		assert.Equal(t, []interface{}{}, options["required_contexts"])
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 9, "ref": "v1.0.0", "sha": "abc123", "environment": "production"}`))
+	})
+	harness.Handle("/repos/acme/widgets/deployments/9/statuses", func(w http.ResponseWriter, r *http.Request) {
+		var status github.DeploymentStatus
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&status))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(status)
+	})
+
+	deployment, err := harness.Client.CreateDeployment(context.Background(), "acme", "widgets", github.DeploymentOptions{
+		Ref:              "v1.0.0",
+		Environment:      "production",
+		RequiredContexts: &[]string{},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), deployment.ID)
+
+	_, err = harness.Client.CreateDeployment(context.Background(), "acme", "widgets", github.DeploymentOptions{Ref: "unverified"})
+	assert.ErrorIs(t, err, github.ErrDeploymentConflict)
+
+	status, err := harness.Client.CreateDeploymentStatus(context.Background(), "acme", "widgets", 9, github.DeploymentStatus{
+		State:       github.DeploymentStateSuccess,
+		Description: "Verified",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, github.DeploymentStateSuccess, status.State)
+
+	_, err = harness.Client.CreateDeploymentStatus(context.Background(), "acme", "widgets", 9, github.DeploymentStatus{State: "done"})
+	assert.ErrorContains(t, err, "invalid deployment status state")
+}
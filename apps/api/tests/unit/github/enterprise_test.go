@@ -0,0 +1,123 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestAPIRoot(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com":                    "https://api.github.com",
+		"https://api.github.com/":               "https://api.github.com",
+		"https://ghes.example.com":              "https://ghes.example.com/api/v3",
+		"https://ghes.example.com/":             "https://ghes.example.com/api/v3",
+		"https://ghes.example.com/api":          "https://ghes.example.com/api/v3",
+		"https://ghes.example.com/api/v3/":      "https://ghes.example.com/api/v3",
+		"http://localhost:8080/github/api/v3":   "http://localhost:8080/github/api/v3",
+		" https://ghes.example.com/api/v3?x=1 ": "https://ghes.example.com/api/v3",
+	}
+	for input, expected := range tests {
+		root, err := github.APIRoot(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, root, input)
+	}
+
+	_, err := github.APIRoot("ghes.example.com")
+	assert.Error(t, err)
+
+	assert.True(t, github.IsEnterprise("https://ghes.example.com/api/v3"))
+	assert.False(t, github.IsEnterprise(github.DefaultBaseURL))
+}
+
+func TestConfigFromEnvEnterprise(t *testing.T) {
+	t.Setenv(github.AppIDEnv, "")
+	t.Setenv(github.PATEnv, "")
+	t.Setenv(github.WorkflowTokenEnv, "ghs_workflow")
+	t.Setenv(github.WorkflowAPIURLEnv, "https://ghes.example.com/api/v3")
+	t.Setenv(github.APIURLEnv, "")
+
+	config, err := github.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "https://ghes.example.com/api/v3", config.BaseURL)
+
+	t.Setenv(github.APIURLEnv, "https://ghes.internal")
+	config, err = github.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "https://ghes.internal/api/v3", config.BaseURL)
+
+	t.Setenv(github.APIURLEnv, "ghes.internal")
+	_, err = github.ConfigFromEnv()
+	assert.Error(t, err)
+}
+
+func TestEnterprisePathPrefix(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/api/v3/repos/acme/widgets", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, github.DefaultAPIVersion, r.Header.Get("X-GitHub-Api-Version"))
+		w.Header().Set("X-GitHub-Enterprise-Version", "3.12.1")
+		w.Write([]byte(`{"default_branch":"main"}`))
+	})
+	harness.Handle("/api/v3/advisories", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `<`+harness.Server.URL+`/api/v3/advisories?page=2>; rel="next"`)
+			w.Write([]byte(`[{"id":1}]`))
+			return
+		}
+		// A link leaving the API root is refused
+		w.Header().Set("Link", `<`+harness.Server.URL+`/elsewhere?page=3>; rel="next"`)
+		w.Write([]byte(`[{"id":2}]`))
+	})
+
+	config := github.DefaultConfig("test-token")
+	config.BaseURL = harness.Server.URL + "/api/v3/"
+	config.Clock = harness.Clock
+	client := github.NewClient(config)
+
+	branch, err := client.DefaultBranch(context.Background(), "acme", "widgets")
+	require.NoError(t, err)
+	assert.Equal(t, "main", branch)
+	assert.Equal(t, "3.12.1", client.EnterpriseVersion())
+
+	_, err = client.GetSecurityAdvisories(context.Background(), 0)
+	assert.ErrorContains(t, err, "is outside")
+	assert.Equal(t, 2, harness.Requests("/api/v3/advisories"))
+}
+
+func TestAPIVersionNegotiation(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	var versions []string
+	harness.Handle("/repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		version := r.Header.Get("X-GitHub-Api-Version")
+		versions = append(versions, version)
+		if version != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"Unsupported API version ` + version + `"}`))
+			return
+		}
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "Bump deps", body["title"])
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"number":7}`))
+	})
+
+	// The request is retried, body and all, with the server's default version
+	pull, err := harness.Client.CreatePullRequest(context.Background(), "acme", "widgets", "main", "deps", "Bump deps", "")
+	require.NoError(t, err)
+	assert.Equal(t, 7, pull.Number)
+	assert.Equal(t, []string{github.DefaultAPIVersion, ""}, versions)
+	assert.Empty(t, harness.Client.APIVersion())
+
+	// Later requests don't ask for it again
+	_, err = harness.Client.CreatePullRequest(context.Background(), "acme", "widgets", "main", "deps", "Bump deps", "")
+	require.NoError(t, err)
+	assert.Len(t, versions, 3)
+}
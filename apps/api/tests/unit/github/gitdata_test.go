@@ -0,0 +1,103 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+const policyYAML = "identities:\n  - issuer: https://token.actions.githubusercontent.com\n"
+
+// contents answers a contents API request with a file, wrapping its base64
+// as GitHub does
+func contents(w http.ResponseWriter, path, sha, content string) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	var wrapped string
+	for len(encoded) > 60 {
+		wrapped, encoded = wrapped+encoded[:60]+"\\n", encoded[60:]
+	}
+	w.Header().Set("ETag", `"`+sha+`"`)
+	fmt.Fprintf(w, `{"type": "file", "path": %q, "sha": %q, "size": %d, "encoding": "base64", "content": "%s"}`,
+		path, sha, len(content), wrapped+encoded)
+}
+
+func TestGetFileContent(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/contents/.keystone/policy.yaml", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != "abc123" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"blob1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		contents(w, ".keystone/policy.yaml", "blob1", policyYAML)
+	})
+	client, statuses := newCachingClient(t, harness)
+
+	file, err := client.GetFileContent(context.Background(), "acme", "widgets", ".keystone/policy.yaml", "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, policyYAML, string(file.Content))
+	assert.Equal(t, "blob1", file.SHA)
+
+	// Fetching it again is revalidated with its ETag
+	file, err = client.GetFileContent(context.Background(), "acme", "widgets", ".keystone/policy.yaml", "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, policyYAML, string(file.Content))
+	assert.Equal(t, []int{http.StatusOK, http.StatusNotModified}, *statuses)
+
+	_, err = client.GetFileContent(context.Background(), "acme", "widgets", ".keystone/policy.yaml", "def456")
+	assert.ErrorIs(t, err, github.ErrFileNotFound)
+}
+
+func TestGetFileContentOverOneMegabyte(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/contents/sbom.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type": "file", "path": "sbom.json", "sha": "blob2", "size": 2000000, "encoding": "none", "content": ""}`))
+	})
+	harness.Handle("/repos/acme/widgets/git/blobs/blob2", func(w http.ResponseWriter, r *http.Request) {
+		contents(w, "", "blob2", `{"spdxVersion":"SPDX-2.3"}`)
+	})
+
+	file, err := harness.Client.GetFileContent(context.Background(), "acme", "widgets", "sbom.json", "")
+	require.NoError(t, err)
+	assert.Equal(t, `{"spdxVersion":"SPDX-2.3"}`, string(file.Content))
+	assert.Equal(t, "sbom.json", file.Path)
+}
+
+func TestGetWorkflowFiles(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/git/trees/abc123", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1", r.URL.Query().Get("recursive"))
+		w.Write([]byte(`{"sha": "tree1", "truncated": false, "tree": [
+			{"path": ".github", "type": "tree", "sha": "t1"},
+			{"path": ".github/workflows", "type": "tree", "sha": "t2"},
+			{"path": ".github/workflows/release.yml", "type": "blob", "sha": "b1", "size": 20},
+			{"path": ".github/workflows/README.md", "type": "blob", "sha": "b2", "size": 5},
+			{"path": ".github/workflows/templates/build.yml", "type": "blob", "sha": "b3", "size": 5},
+			{"path": "go.mod", "type": "blob", "sha": "b4", "size": 30}
+		]}`))
+	})
+	harness.Handle("/repos/acme/widgets/contents/.github/workflows/release.yml", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "abc123", r.URL.Query().Get("ref"))
+		contents(w, ".github/workflows/release.yml", "b1", "on: push\n")
+	})
+
+	tree, err := harness.Client.GetTree(context.Background(), "acme", "widgets", "abc123", true)
+	require.NoError(t, err)
+	assert.Len(t, tree.Files(""), 4)
+	assert.Len(t, tree.Files(".github/workflows/"), 3)
+
+	files, err := harness.Client.GetWorkflowFiles(context.Background(), "acme", "widgets", "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{".github/workflows/release.yml": []byte("on: push\n")}, files)
+}
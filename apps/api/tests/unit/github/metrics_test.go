@@ -0,0 +1,76 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/metrics"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+func TestClientMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		w.Header().Set("X-RateLimit-Reset", "1700000000")
+		w.Header().Set("X-RateLimit-Used", "679")
+		switch r.URL.Path {
+		case "/repos/acme/widgets":
+			w.Write([]byte(`{"full_name": "acme/widgets"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	config := github.DefaultConfig("test-token")
+	config.BaseURL = server.URL
+	config.RateLimitThreshold = 0
+	config.Retry = github.RetryPolicy{}
+	config.Metrics = registry
+	client := github.NewClient(config)
+
+	ctx := context.Background()
+	_, err := client.GetRepository(ctx, "acme", "widgets")
+	require.NoError(t, err)
+	_, err = client.GetRepository(ctx, "acme", "widgets")
+	require.NoError(t, err)
+	_, err = client.GetFileContent(ctx, "acme", "widgets", ".keystone/policy.yaml", "main")
+	require.ErrorIs(t, err, github.ErrFileNotFound)
+
+	var text bytes.Buffer
+	registry.WriteText(&text)
+	assert.Contains(t, text.String(), `keystone_github_requests_total{method="GET",endpoint="/repos/{owner}/{repo}",code="200"} 2`)
+	assert.Contains(t, text.String(), `keystone_github_requests_total{method="GET",endpoint="/repos/{owner}/{repo}/contents/{path}",code="404"} 1`)
+	assert.Contains(t, text.String(), `keystone_github_request_duration_seconds_count{method="GET",endpoint="/repos/{owner}/{repo}"} 2`)
+	assert.Contains(t, text.String(), "keystone_github_rate_limit_remaining 4321")
+	assert.Contains(t, text.String(), "keystone_github_circuit_breaker_state 0")
+}
+
+func TestClientMetricsTransportError(t *testing.T) {
+	registry := metrics.NewRegistry()
+	config := github.DefaultConfig("test-token")
+	config.BaseURL = "http://github.invalid"
+	config.Retry = github.RetryPolicy{}
+	config.Metrics = registry
+	config.Transport = github.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, assert.AnError
+	})
+	client := github.NewClient(config)
+
+	_, err := client.GetWorkflowRun(context.Background(), "acme", "widgets", 1234)
+	require.Error(t, err)
+
+	var text bytes.Buffer
+	registry.WriteText(&text)
+	assert.Contains(t, text.String(), `keystone_github_requests_total{method="GET",endpoint="/repos/{owner}/{repo}/actions/runs/{id}",code="error"} 1`)
+	// No response has reported a rate limit yet
+	assert.NotContains(t, text.String(), "\nkeystone_github_rate_limit_remaining ")
+}
@@ -0,0 +1,101 @@
+package github
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+// tracing returns middleware noting when requests enter and responses leave it
+func tracing(name string, mutex *sync.Mutex, trace *[]string) github.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return github.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			mutex.Lock()
+			*trace = append(*trace, name+" "+req.Method+" "+req.URL.Path)
+			mutex.Unlock()
+			resp, err := next.RoundTrip(req)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				*trace = append(*trace, name+" error")
+			} else {
+				*trace = append(*trace, name+" "+resp.Status)
+			}
+			return resp, err
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var userAgents []string
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.Header.Get("User-Agent"))
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"full_name": "acme/widgets"}`))
+	}))
+	defer server.Close()
+
+	var mutex sync.Mutex
+	var trace []string
+	config := github.DefaultConfig("test-token")
+	config.BaseURL = server.URL
+	config.Retry.BaseDelay = time.Millisecond
+	config.Middleware = []github.Middleware{
+		tracing("outer", &mutex, &trace),
+		github.SetHeader("User-Agent", "keystone-test"),
+		tracing("inner", &mutex, &trace),
+	}
+	client := github.NewClient(config)
+	client.Use(tracing("used", &mutex, &trace))
+
+	repository, err := client.GetRepository(context.Background(), "acme", "widgets")
+	require.NoError(t, err)
+	assert.Equal(t, "acme/widgets", repository.FullName)
+
+	// Middleware sees every attempt, the first listed outermost and Use
+	// outside the configured middleware
+	assert.Equal(t, []string{
+		"used GET /repos/acme/widgets",
+		"outer GET /repos/acme/widgets",
+		"inner GET /repos/acme/widgets",
+		"inner 502 Bad Gateway",
+		"outer 502 Bad Gateway",
+		"used 502 Bad Gateway",
+		"used GET /repos/acme/widgets",
+		"outer GET /repos/acme/widgets",
+		"inner GET /repos/acme/widgets",
+		"inner 200 OK",
+		"outer 200 OK",
+		"used 200 OK",
+	}, trace)
+	assert.Equal(t, []string{"keystone-test", "keystone-test"}, userAgents)
+}
+
+func TestChainDefaultsTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Deployment")))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: github.Chain(nil, github.SetHeader("X-Deployment", "staging"))}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "staging", string(body))
+}
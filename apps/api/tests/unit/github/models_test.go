@@ -0,0 +1,55 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestAdvisoryDecoding(t *testing.T) {
+	var advisory github.Advisory
+	require.NoError(t, json.Unmarshal([]byte(`{"severity":"high","ghsa_id":"GHSA-xxxx-0001","withdrawn_at":"2024-02-01T00:00:00Z",
+		"epss":{"percentage":0.1},"vulnerabilities":[
+		{"package":{"ecosystem":"npm","name":"left-pad"},"vulnerable_version_range":"< 1.3.0","first_patched_version":{"identifier":"1.3.0"}},
+		{"package":{"ecosystem":"npm","name":"right-pad"},"vulnerable_version_range":"< 2.0.0","first_patched_version":"2.0.0"},
+		{"package":{"ecosystem":"npm","name":"mid-pad"},"vulnerable_version_range":"*","first_patched_version":null}]}`), &advisory))
+
+	assert.Equal(t, "GHSA-xxxx-0001", advisory.GHSAID)
+	require.NotNil(t, advisory.WithdrawnAt)
+	require.Len(t, advisory.Vulnerabilities, 3)
+	assert.Equal(t, github.PatchedVersion("1.3.0"), advisory.Vulnerabilities[0].FirstPatchedVersion)
+	assert.Equal(t, github.PatchedVersion("2.0.0"), advisory.Vulnerabilities[1].FirstPatchedVersion)
+	assert.Empty(t, advisory.Vulnerabilities[2].FirstPatchedVersion)
+
+	// The stored document keeps fields the model lacks, with its keys sorted
+	document, err := advisory.Document()
+	require.NoError(t, err)
+	assert.Contains(t, string(document), `"epss":{"percentage":0.1}`)
+	assert.Regexp(t, `^\{"epss":.*"ghsa_id":.*"severity":.*"vulnerabilities":.*"withdrawn_at"`, string(document))
+
+	// Advisories built in code are encoded from their fields
+	document, err = github.Advisory{GHSAID: "GHSA-xxxx-0002", Severity: "low"}.Document()
+	require.NoError(t, err)
+	assert.Contains(t, string(document), `"ghsa_id":"GHSA-xxxx-0002"`)
+}
+
+func TestGetRepositoryStatusError(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+
+	_, err := harness.Client.GetRepository(context.Background(), "acme", "gone")
+	var statusErr *github.StatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusGone, statusErr.StatusCode)
+	assert.Equal(t, "GET", statusErr.Method)
+}
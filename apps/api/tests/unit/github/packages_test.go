@@ -0,0 +1,81 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+// packageVersion renders a container version updated days before the epoch
+func packageVersion(id int, days int, tags string) string {
+	updated := githubtest.Epoch.Add(-time.Duration(days) * 24 * time.Hour).Format(time.RFC3339)
+	return fmt.Sprintf(`{"id":%d,"name":"sha256:%04d","updated_at":%q,"metadata":{"package_type":"container","container":{"tags":[%s]}}}`,
+		id, id, updated, tags)
+}
+
+func TestPrunePackageVersions(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/users/acme", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"login":"acme","type":"Organization"}`))
+	})
+	harness.Handle("/orgs/acme/packages/container/widgets/api/versions", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/orgs/acme/packages/container/widgets%2Fapi/versions", r.URL.EscapedPath())
+		fmt.Fprintf(w, "[%s,%s,%s,%s,%s]",
+			packageVersion(1, 1, `"sha256-0001.att"`),
+			packageVersion(2, 120, `"sha256-0002.att","sha256-0002.sig"`),
+			packageVersion(3, 400, `"v1.0.0"`),
+			packageVersion(4, 400, ``),
+			packageVersion(5, 120, `"sha256-0005.att","latest"`),
+		)
+	})
+	var deleted []string
+	harness.Handle("/orgs/acme/packages/container/widgets/api/versions/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		deleted = append(deleted, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	policy := github.RetentionPolicy{MaxAge: 90 * 24 * time.Hour, DryRun: true}
+	expired, err := harness.Client.PrunePackageVersions(context.Background(), "acme", "widgets/api", policy)
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+	assert.Equal(t, int64(2), expired[0].ID)
+	assert.Empty(t, deleted)
+
+	// Untagged versions go only when asked; releases and images tagged
+	// alongside attestations stay
+	policy = github.RetentionPolicy{MaxAge: 90 * 24 * time.Hour, UntaggedAge: 365 * 24 * time.Hour}
+	expired, err = harness.Client.PrunePackageVersions(context.Background(), "acme", "widgets/api", policy)
+	require.NoError(t, err)
+	assert.Len(t, expired, 2)
+	assert.Equal(t, []string{
+		"/orgs/acme/packages/container/widgets/api/versions/2",
+		"/orgs/acme/packages/container/widgets/api/versions/4",
+	}, deleted)
+}
+
+func TestPackageVersionsOfUsers(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/users/octocat", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"login":"octocat","type":"User"}`))
+	})
+	harness.Handle("/users/octocat/packages/container/widgets/versions/7", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(packageVersion(7, 0, `"sha256-0007.sbom"`)))
+	})
+
+	version, err := harness.Client.GetPackageVersion(context.Background(), "octocat", "widgets", 7)
+	require.NoError(t, err)
+	assert.True(t, version.IsAttestation())
+	assert.Equal(t, []string{"sha256-0007.sbom"}, version.Tags())
+
+	_, err = harness.Client.GetPackageVersion(context.Background(), "ghost", "widgets", 7)
+	assert.ErrorContains(t, err, "not found")
+}
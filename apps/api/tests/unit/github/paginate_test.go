@@ -0,0 +1,86 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+// servePages serves three pages of two numbered items each, linking each page
+// to the next
+func servePages(t *testing.T, harness *githubtest.Harness, path, wrap string) {
+	harness.Handle(path, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "2", r.URL.Query().Get("per_page"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=%d&per_page=2>; rel="next", <%s%s?page=3&per_page=2>; rel="last"`,
+				harness.Server.URL, path, page+1, harness.Server.URL, path))
+		}
+		items := fmt.Sprintf(`[{"id":%d},{"id":%d}]`, 2*page-1, 2*page)
+		if wrap != "" {
+			items = fmt.Sprintf(`{"total_count":6,%q:%s}`, wrap, items)
+		}
+		w.Write([]byte(items))
+	})
+}
+
+func TestPaginatorFollowsLinks(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	servePages(t, harness, "/repos/acme/widgets/security-advisories", "")
+
+	var ids []int
+	pages := harness.Client.Paginate(harness.Server.URL+"/repos/acme/widgets/security-advisories", github.PageOptions{PerPage: 2})
+	for pages.Next(context.Background()) {
+		var item struct{ ID int }
+		require.NoError(t, pages.Decode(&item))
+		ids = append(ids, item.ID)
+	}
+	require.NoError(t, pages.Err())
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, ids)
+	assert.Equal(t, 3, pages.Pages())
+	assert.False(t, pages.Next(context.Background()))
+}
+
+func TestPaginatorCollectsAll(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	servePages(t, harness, "/advisories", "")
+	servePages(t, harness, "/repos/acme/widgets/actions/runs", "workflow_runs")
+
+	advisories, err := harness.Client.GetSecurityAdvisories(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Len(t, advisories, 6)
+	assert.Equal(t, 3, harness.Requests("/advisories"))
+
+	// Max pages bounds the requests made
+	var runs []struct{ ID int }
+	pages := harness.Client.Paginate(harness.Server.URL+"/repos/acme/widgets/actions/runs", github.PageOptions{
+		PerPage:  2,
+		MaxPages: 2,
+		ItemsKey: "workflow_runs",
+	})
+	require.NoError(t, pages.All(context.Background(), &runs))
+	assert.Len(t, runs, 4)
+	assert.Equal(t, 2, harness.Requests("/repos/acme/widgets/actions/runs"))
+}
+
+func TestPaginatorStaysOnTheAPI(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/advisories", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<https://attacker.example.com/advisories?page=2>; rel="next"`)
+		w.Write([]byte(`[{"id":1}]`))
+	})
+
+	_, err := harness.Client.GetSecurityAdvisories(context.Background(), 1)
+	assert.ErrorContains(t, err, "is outside")
+}
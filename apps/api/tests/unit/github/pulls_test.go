@@ -0,0 +1,100 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestOpenPullRequestCommitsFilesToNewBranch(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+
+	var mutex sync.Mutex
+	var branchRef string
+	puts := make(map[string]map[string]string)
+
+	harness.Handle("/repos/acme/widgets", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"default_branch":"trunk"}`))
+	})
+	harness.Handle("/repos/acme/widgets/git/ref/heads/trunk", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"object":{"sha":"abc123"}}`))
+	})
+	harness.Handle("/repos/acme/widgets/git/refs", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "abc123", body["sha"])
+		mutex.Lock()
+		branchRef = body["ref"]
+		mutex.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	})
+	harness.Handle("/repos/acme/widgets/contents/", func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path[len("/repos/acme/widgets/contents/"):]
+		if r.Method == http.MethodGet {
+			assert.Equal(t, "keystone-init", r.URL.Query().Get("ref"))
+			if path == "keystone.yaml" {
+				w.Write([]byte(`{"sha":"old-blob"}`))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		mutex.Lock()
+		puts[path] = body
+		mutex.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	})
+	harness.Handle("/repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "trunk", body["base"])
+		assert.Equal(t, "keystone-init", body["head"])
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"number":7,"html_url":"https://github.com/acme/widgets/pull/7"}`))
+	})
+
+	pull, err := harness.Client.OpenPullRequest(context.Background(), "acme", "widgets", github.PullRequestChange{
+		Head:  "keystone-init",
+		Title: "Adopt Keystone",
+		Files: []github.FileChange{
+			{Path: ".github/workflows/keystone.yml", Content: []byte("name: Keystone\n")},
+			{Path: "keystone.yaml", Content: []byte("version: 1\n")},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 7, pull.Number)
+	assert.Equal(t, "https://github.com/acme/widgets/pull/7", pull.HTMLURL)
+	assert.Equal(t, "trunk", pull.Base)
+	assert.Equal(t, "refs/heads/keystone-init", branchRef)
+
+	require.Len(t, puts, 2)
+	workflow := puts[".github/workflows/keystone.yml"]
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("name: Keystone\n")), workflow["content"])
+	assert.Equal(t, "keystone-init", workflow["branch"])
+	assert.Equal(t, "Adopt Keystone", workflow["message"], "the message defaults to the title")
+	assert.Empty(t, workflow["sha"])
+	// Files already on the branch are replaced by passing their blob SHA
+	assert.Equal(t, "old-blob", puts["keystone.yaml"]["sha"])
+}
+
+func TestCreateBranchReportsExistingBranch(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/git/refs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	})
+
+	err := harness.Client.CreateBranch(context.Background(), "acme", "widgets", "keystone-init", "abc123")
+	assert.ErrorContains(t, err, "already exists")
+}
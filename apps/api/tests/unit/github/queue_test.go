@@ -0,0 +1,125 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestQueueLeavesRetriesToTheClient(t *testing.T) {
+	config := github.DefaultQueueConfig()
+	config.Workers = 1
+	config.BatchSize = 1
+	harness := githubtest.New(t, config)
+
+	harness.Handle("/repos/acme/down", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	result := harness.Queue.Enqueue(context.Background(), "down", github.PriorityHigh, func(ctx context.Context) error {
+		_, err := harness.Client.GetRepository(ctx, "acme", "down")
+		return err
+	})
+
+	// The client's retry policy makes every attempt; the queue doesn't repeat them
+	err := harness.Await(t, result, time.Second)
+	assert.ErrorContains(t, err, "server error: 503")
+	assert.Equal(t, github.DefaultRetryPolicy().MaxAttempts, harness.Requests("/repos/acme/down"))
+}
+
+func TestQueueDeadLettersRateLimitedRequests(t *testing.T) {
+	config := github.DefaultQueueConfig()
+	config.Workers = 1
+	config.BatchSize = 1
+	harness := githubtest.New(t, config)
+
+	harness.Handle("/repos/acme/widgets", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.WriteHeader(http.StatusForbidden)
+	})
+	result := harness.Queue.Enqueue(context.Background(), "repo", github.PriorityHigh, func(ctx context.Context) error {
+		_, err := harness.Client.GetRepository(ctx, "acme", "widgets")
+		return err
+	})
+
+	// Without a Retry-After the limit lasts until the window resets, so the
+	// request is kept to requeue rather than retried
+	err := harness.Await(t, result, time.Second)
+	assert.ErrorIs(t, err, github.ErrRateLimitExceeded)
+	assert.Equal(t, 1, harness.Requests("/repos/acme/widgets"))
+	assert.Equal(t, int64(1), harness.Queue.Stats().DeadLettered)
+}
+
+func TestQueueGivesUpOnPermanentErrors(t *testing.T) {
+	config := github.DefaultQueueConfig()
+	config.Workers = 1
+	config.BatchSize = 1
+	harness := githubtest.New(t, config)
+
+	harness.Handle("/repos/acme/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	result := harness.Queue.Enqueue(context.Background(), "missing", github.PriorityNormal, func(ctx context.Context) error {
+		_, err := harness.Client.GetRepository(ctx, "acme", "missing")
+		return err
+	})
+
+	err := harness.Await(t, result, time.Second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+	assert.Equal(t, 1, harness.Requests("/repos/acme/missing"))
+}
+
+func TestQueueStopsCancelledRequests(t *testing.T) {
+	config := github.DefaultQueueConfig()
+	config.Workers = 1
+	config.BatchSize = 1
+	harness := githubtest.New(t, config)
+
+	started := make(chan struct{})
+	harness.Handle("/repos/acme/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := harness.Queue.Enqueue(ctx, "slow", github.PriorityNormal, func(ctx context.Context) error {
+		_, err := harness.Client.GetRepository(ctx, "acme", "slow")
+		return err
+	})
+
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	err := harness.Await(t, result, time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+	// The cancelled request is neither retried nor left running
+	assert.Equal(t, 1, harness.Requests("/repos/acme/slow"))
+}
+
+func TestQueueSkipsRequestsCancelledWhileQueued(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var ran atomic.Bool
+	result := harness.Queue.Enqueue(ctx, "abandoned", github.PriorityLow, func(ctx context.Context) error {
+		ran.Store(true)
+		return nil
+	})
+
+	assert.ErrorIs(t, harness.Await(t, result, time.Second), context.Canceled)
+	assert.False(t, ran.Load())
+}
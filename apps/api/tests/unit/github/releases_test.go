@@ -0,0 +1,96 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestCreateRelease(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/releases", func(w http.ResponseWriter, r *http.Request) {
+		var options github.ReleaseOptions
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&options))
+		if options.TagName == "v1.0.0" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		assert.True(t, options.GenerateReleaseNotes)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":2,"tag_name":"v1.1.0","upload_url":"https://uploads.github.com/repos/acme/widgets/releases/2/assets{?name,label}"}`))
+	})
+
+	release, err := harness.Client.CreateRelease(context.Background(), "acme", "widgets", github.ReleaseOptions{TagName: "v1.1.0", GenerateReleaseNotes: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), release.ID)
+
+	_, err = harness.Client.CreateRelease(context.Background(), "acme", "widgets", github.ReleaseOptions{TagName: "v1.0.0"})
+	assert.ErrorContains(t, err, "may already exist")
+}
+
+func TestAttachReleaseAssets(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/releases/tags/v1.0.0", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":1,"tag_name":"v1.0.0","upload_url":"%s/uploads/repos/acme/widgets/releases/1/assets{?name,label}",
+			"assets":[{"id":10,"name":"sbom.spdx.json"}]}`, harness.Server.URL)
+	})
+	var deleted bool
+	harness.Handle("/repos/acme/widgets/releases/assets/10", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+	var uploads []string
+	harness.Handle("/uploads/repos/acme/widgets/releases/1/assets", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token test-token", r.Header.Get("Authorization"))
+		content, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		name := r.URL.Query().Get("name")
+		uploads = append(uploads, name+" "+r.Header.Get("Content-Type")+" "+string(content))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":%d,"name":%q,"state":"uploaded"}`, 10+len(uploads), name)
+	})
+
+	assets, err := harness.Client.AttachReleaseAssets(context.Background(), "acme", "widgets", "v1.0.0", []github.AssetUpload{
+		{Name: "sbom.spdx.json", Content: []byte(`{}`)},
+		{Name: "widgets.sigstore", Content: []byte("bundle")},
+	})
+	require.NoError(t, err)
+	require.Len(t, assets, 2)
+	assert.True(t, deleted, "an asset of the same name is replaced")
+	assert.Equal(t, []string{
+		"sbom.spdx.json application/json {}",
+		"widgets.sigstore application/octet-stream bundle",
+	}, uploads)
+}
+
+func TestDownloadReleaseAsset(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/releases/assets/10", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/octet-stream", r.Header.Get("Accept"))
+		http.Redirect(w, r, "/storage/sbom.spdx.json", http.StatusFound)
+	})
+	harness.Handle("/storage/sbom.spdx.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"spdxVersion":"SPDX-2.3"}`))
+	})
+
+	reader, err := harness.Client.DownloadReleaseAsset(context.Background(), "acme", "widgets", 10)
+	require.NoError(t, err)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"spdxVersion":"SPDX-2.3"}`, string(content))
+
+	_, err = harness.Client.DownloadReleaseAsset(context.Background(), "acme", "widgets", 11)
+	var statusErr *github.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+}
@@ -0,0 +1,178 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+// failing answers with status for the first failures requests, then succeeds
+func failing(failures int, status int, header http.Header) http.HandlerFunc {
+	var requests atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		if int(requests.Add(1)) <= failures {
+			for name, values := range header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 1, "full_name": "acme/widgets"}`))
+	}
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets", failing(2, http.StatusBadGateway, nil))
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := harness.Client.GetRepository(context.Background(), "acme", "widgets")
+		result <- err
+	}()
+	// GetRepository wants a 200, so the eventual 201 is an unexpected status
+	err := harness.Await(t, result, 100*time.Millisecond)
+	var statusErr *github.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusCreated, statusErr.StatusCode)
+	assert.Equal(t, 3, harness.Requests("/repos/acme/widgets"))
+	assert.Equal(t, int64(2), harness.Client.Stats().Retries)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets", failing(10, http.StatusServiceUnavailable, nil))
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := harness.Client.GetRepository(context.Background(), "acme", "widgets")
+		result <- err
+	}()
+	err := harness.Await(t, result, 100*time.Millisecond)
+	assert.ErrorContains(t, err, "server error: 503")
+	assert.Equal(t, github.DefaultRetryPolicy().MaxAttempts, harness.Requests("/repos/acme/widgets"))
+}
+
+func TestRetrySkipsNonIdempotentRequests(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/issues/7/comments", failing(1, http.StatusBadGateway, nil))
+
+	_, err := harness.Client.CreateIssueComment(context.Background(), "acme", "widgets", 7, "report")
+	assert.ErrorContains(t, err, "server error: 502")
+	assert.Equal(t, 1, harness.Requests("/repos/acme/widgets/issues/7/comments"))
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	rateLimited := http.Header{"Retry-After": {"10"}, "X-Ratelimit-Remaining": {"0"}}
+
+	t.Run("waits_as_asked", func(t *testing.T) {
+		harness := githubtest.New(t, github.DefaultQueueConfig())
+		harness.Handle("/repos/acme/widgets/issues/7/comments", failing(1, http.StatusForbidden, rateLimited))
+
+		result := make(chan error, 1)
+		go func() {
+			_, err := harness.Client.CreateIssueComment(context.Background(), "acme", "widgets", 7, "report")
+			result <- err
+		}()
+		// Rate limits are rejected before GitHub acts, so even a POST is retried
+		require.NoError(t, harness.Await(t, result, time.Second))
+		assert.Equal(t, 2, harness.Requests("/repos/acme/widgets/issues/7/comments"))
+		assert.GreaterOrEqual(t, harness.Clock.Since(githubtest.Epoch), 10*time.Second)
+	})
+
+	t.Run("too_long_to_wait", func(t *testing.T) {
+		harness := githubtest.New(t, github.DefaultQueueConfig())
+		header := http.Header{"Retry-After": {"3600"}, "X-Ratelimit-Remaining": {"0"}}
+		harness.Handle("/repos/acme/widgets", failing(1, http.StatusForbidden, header))
+
+		_, err := harness.Client.GetRepository(context.Background(), "acme", "widgets")
+		assert.ErrorIs(t, err, github.ErrRateLimitExceeded)
+		assert.Equal(t, 1, harness.Requests("/repos/acme/widgets"))
+	})
+}
+
+func TestEndpointRetries(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	config := github.DefaultConfig("test-token")
+	config.BaseURL = server.URL
+	config.Retry.BaseDelay = time.Millisecond
+	config.CircuitBreakerConfig.FailureThreshold = 100 // Keep the breaker out of the count
+	config.EndpointRetries = map[string]github.RetryPolicy{
+		"/advisories":        {MaxAttempts: 1},
+		"/repos/acme/gadget": {MaxAttempts: 5, BaseDelay: time.Millisecond},
+	}
+	client := github.NewClient(config)
+
+	_, err := client.ListAdvisories(context.Background(), github.AdvisoryQuery{})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), requests.Swap(0))
+
+	_, err = client.GetRepository(context.Background(), "acme", "gadget")
+	assert.Error(t, err)
+	assert.Equal(t, int32(5), requests.Swap(0))
+
+	_, err = client.GetRepository(context.Background(), "acme", "widgets")
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), requests.Swap(0))
+}
+
+func TestRetryBudget(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := github.DefaultConfig("test-token")
+	config.BaseURL = server.URL
+	config.Retry.BaseDelay = time.Millisecond
+	config.RetryBudget = circuit.NewBudget(circuit.BudgetConfig{Ratio: 0, MinRetries: 1, Window: time.Minute})
+	client := github.NewClient(config)
+
+	// One retry fits the budget; the next is rejected instead of waiting
+	_, err := client.GetRepository(context.Background(), "acme", "widgets")
+	assert.ErrorIs(t, err, circuit.ErrRetryBudgetExhausted)
+	assert.ErrorContains(t, err, "server error: 503")
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, circuit.BudgetStats{Requests: 1, Retries: 1, Rejected: 1}, config.RetryBudget.Stats())
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := github.RetryPolicy{BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+	var delays []time.Duration
+	for attempt := 1; attempt <= 7; attempt++ {
+		delays = append(delays, policy.Backoff(attempt))
+	}
+	assert.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second,
+	}, delays)
+
+	// Jitter takes up to its fraction off each delay
+	policy.Jitter = 0.5
+	policy.Random = func() float64 { return 0.5 }
+	assert.Equal(t, 3*time.Second, policy.Backoff(3))
+	policy.Random = func() float64 { return 0 }
+	assert.Equal(t, 4*time.Second, policy.Backoff(3))
+
+	assert.True(t, policy.Retryable("GET", http.StatusServiceUnavailable))
+	assert.False(t, policy.Retryable("GET", http.StatusNotFound))
+	assert.False(t, policy.Retryable("POST", http.StatusServiceUnavailable))
+}
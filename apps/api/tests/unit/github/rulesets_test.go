@@ -0,0 +1,77 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestRulesets(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/rulesets", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("includes_parents"))
+		w.Write([]byte(`[{"id": 1, "name": "main", "source_type": "Repository", "enforcement": "active"},
+			{"id": 2, "name": "org baseline", "source_type": "Organization", "source": "acme", "enforcement": "evaluate"}]`))
+	})
+	harness.Handle("/orgs/acme/rulesets/2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 2, "name": "org baseline", "target": "branch", "enforcement": "evaluate",
+			"conditions": {"ref_name": {"include": ["~DEFAULT_BRANCH"], "exclude": []}},
+			"rules": [{"type": "required_signatures"}]}`))
+	})
+
+	rulesets, err := harness.Client.ListRepositoryRulesets(context.Background(), "acme", "widgets")
+	require.NoError(t, err)
+	require.Len(t, rulesets, 2)
+	assert.Equal(t, "Organization", rulesets[1].SourceType)
+
+	ruleset, err := harness.Client.GetOrganizationRuleset(context.Background(), "acme", 2)
+	require.NoError(t, err)
+	assert.Equal(t, github.EnforcementEvaluate, ruleset.Enforcement)
+	require.Len(t, ruleset.Rules, 1)
+	assert.Equal(t, github.RuleRequiredSignatures, ruleset.Rules[0].Type)
+}
+
+func TestVerifyBranchRules(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/rules/branches/main", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"type": "required_status_checks", "ruleset_source_type": "Repository", "ruleset_source": "acme/widgets", "ruleset_id": 1,
+			 "parameters": {"required_status_checks": [{"context": "Keystone", "integration_id": 42}, {"context": "test"}],
+			 "strict_required_status_checks_policy": true}},
+			{"type": "pull_request", "ruleset_id": 1, "parameters": {"required_approving_review_count": 1}},
+			{"type": "non_fast_forward", "ruleset_id": 3}
+		]`))
+	})
+
+	rules, err := harness.Client.GetBranchRules(context.Background(), "acme", "widgets", "refs/heads/main")
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	checks, err := rules[0].StatusChecks()
+	require.NoError(t, err)
+	assert.True(t, checks.StrictRequiredStatusChecksPolicy)
+	assert.Equal(t, int64(42), checks.RequiredStatusChecks[0].IntegrationID)
+	_, err = rules[1].StatusChecks()
+	assert.Error(t, err)
+
+	err = harness.Client.VerifyBranchRules(context.Background(), "acme", "widgets", "refs/heads/main", github.BranchRequirement{
+		RequiredChecks: []string{"Keystone"},
+		PullRequests:   true,
+		NoForcePushes:  true,
+	})
+	assert.NoError(t, err)
+
+	err = harness.Client.VerifyBranchRules(context.Background(), "acme", "widgets", "main", github.BranchRequirement{
+		RequiredChecks: []string{"Keystone", "lint"},
+		SignedCommits:  true,
+	})
+	var unenforced *github.UnenforcedError
+	require.ErrorAs(t, err, &unenforced)
+	assert.Equal(t, "main", unenforced.Branch)
+	assert.Equal(t, []string{`required check "lint"`, "signed commits"}, unenforced.Missing)
+}
@@ -0,0 +1,117 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+// throttleFirst answers the first request with a secondary rate limit
+func throttleFirst(harness *githubtest.Harness, path string, retryAfter string) *int32 {
+	var calls int32
+	harness.Handle(path, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.Header().Set("X-RateLimit-Remaining", "4000")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"You have exceeded a secondary rate limit. Please wait a few minutes before you try again."}`))
+			return
+		}
+		w.Write([]byte(`{"full_name":"acme/widgets"}`))
+	})
+	return &calls
+}
+
+func TestSecondaryRateLimitCooldown(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	throttleFirst(harness, "/repos/acme/widgets", "")
+
+	_, err := harness.Client.GetRepository(context.Background(), "acme", "widgets")
+	assert.ErrorIs(t, err, github.ErrSecondaryRateLimit)
+
+	stats := harness.Client.Stats()
+	assert.True(t, stats.SecondaryRateLimit.CoolingDown)
+	assert.Equal(t, githubtest.Epoch.Add(github.DefaultSecondaryCooldown), stats.SecondaryRateLimit.Until)
+	assert.Equal(t, int64(1), stats.SecondaryRateLimit.Hits)
+	assert.Equal(t, circuit.StateClosed, stats.CircuitBreakerState, "throttling isn't an outage")
+
+	// Requests whose deadline falls within the cool-down fail fast
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = harness.Client.GetRepository(ctx, "acme", "widgets")
+	assert.ErrorIs(t, err, github.ErrSecondaryRateLimit)
+	assert.Equal(t, 1, harness.Requests("/repos/acme/widgets"))
+
+	// Others wait it out
+	result := make(chan error, 1)
+	go func() {
+		_, err := harness.Client.GetRepository(context.Background(), "acme", "widgets")
+		result <- err
+	}()
+	require.NoError(t, harness.Await(t, result, 10*time.Second))
+	assert.Equal(t, 2, harness.Requests("/repos/acme/widgets"))
+	assert.False(t, harness.Client.Stats().SecondaryRateLimit.CoolingDown)
+	assert.GreaterOrEqual(t, harness.Clock.Since(githubtest.Epoch), github.DefaultSecondaryCooldown)
+}
+
+func TestSecondaryRateLimitPausesQueue(t *testing.T) {
+	config := github.DefaultQueueConfig()
+	config.Workers = 2
+	config.BatchSize = 1
+	harness := githubtest.New(t, config)
+	throttleFirst(harness, "/repos/acme/widgets", "120")
+	harness.Handle("/repos/acme/gadgets", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"full_name":"acme/gadgets"}`))
+	})
+
+	first := harness.Queue.Enqueue(context.Background(), "widgets", github.PriorityHigh, func(ctx context.Context) error {
+		_, err := harness.Client.GetRepository(ctx, "acme", "widgets")
+		return err
+	})
+	require.Eventually(t, func() bool {
+		return harness.Client.Stats().SecondaryRateLimit.CoolingDown
+	}, 5*time.Second, time.Millisecond)
+
+	// Every worker holds off until the cool-down GitHub asked for is over,
+	// longer than a request's time limit
+	second := harness.Queue.Enqueue(context.Background(), "gadgets", github.PriorityNormal, func(ctx context.Context) error {
+		_, err := harness.Client.GetRepository(ctx, "acme", "gadgets")
+		return err
+	})
+	require.NoError(t, harness.Await(t, first, time.Second))
+	require.NoError(t, harness.Await(t, second, time.Second))
+	assert.GreaterOrEqual(t, harness.Clock.Since(githubtest.Epoch), 120*time.Second)
+	assert.Equal(t, 2, harness.Requests("/repos/acme/widgets"))
+	assert.Equal(t, int64(0), harness.Queue.Stats().DeadLettered)
+}
+
+func TestPrimaryRateLimitIsNotSecondary(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"API rate limit exceeded for installation ID 1."}`))
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := harness.Client.GetRepository(context.Background(), "acme", "widgets")
+		result <- err
+	}()
+	err := harness.Await(t, result, time.Second)
+	assert.False(t, errors.Is(err, github.ErrSecondaryRateLimit))
+	assert.False(t, harness.Client.Stats().SecondaryRateLimit.CoolingDown)
+}
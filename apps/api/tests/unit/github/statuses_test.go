@@ -0,0 +1,44 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+func TestCreateCommitStatus(t *testing.T) {
+	harness := githubtest.New(t, github.DefaultQueueConfig())
+	harness.Handle("/repos/acme/widgets/statuses/abc123", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "failure", body["state"])
+		assert.Equal(t, "keystone", body["context"])
+		assert.NotContains(t, body, "id")
+		description := body["description"].(string)
+		assert.Equal(t, github.MaxStatusDescription, utf8.RuneCountInString(description))
+		assert.True(t, strings.HasSuffix(description, "…"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":3,"state":"failure","context":"keystone"}`))
+	})
+
+	status, err := harness.Client.CreateCommitStatus(context.Background(), "acme", "widgets", "abc123", github.CommitStatus{
+		State:       github.CommitStateFailure,
+		Context:     "keystone",
+		Description: strings.Repeat("é", 200),
+		TargetURL:   "https://example.com/report",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), status.ID)
+
+	_, err = harness.Client.CreateCommitStatus(context.Background(), "acme", "widgets", "abc123", github.CommitStatus{State: "passed"})
+	assert.ErrorContains(t, err, "invalid commit status state")
+}
@@ -0,0 +1,119 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+	"github.com/salman-frs/keystone/apps/api/pkg/github/githubtest"
+)
+
+// tokenServer reports a separate rate limit for each token and counts the
+// requests made with each
+type tokenServer struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	remaining map[string]int
+	used      []string
+}
+
+func newTokenServer(t *testing.T, remaining map[string]int) *tokenServer {
+	s := &tokenServer{remaining: remaining}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "token ")
+		s.mutex.Lock()
+		s.remaining[token]--
+		s.used = append(s.used, token)
+		remaining := s.remaining[token]
+		s.mutex.Unlock()
+
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(githubtest.Epoch.Add(time.Hour).Unix(), 10))
+		w.Write([]byte(`{"full_name": "acme/widgets"}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *tokenServer) tokensUsed() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.used...)
+}
+
+func newPooledClient(server *tokenServer, fake *clock.Fake, tokens ...string) *github.Client {
+	config := github.DefaultConfig(tokens[0])
+	for _, token := range tokens[1:] {
+		config.Tokens = append(config.Tokens, secret.New(token))
+	}
+	config.BaseURL = server.URL
+	config.Clock = fake
+	return github.NewClient(config)
+}
+
+func TestTokenPool(t *testing.T) {
+	server := newTokenServer(t, map[string]int{"low": 100, "high": 4000, "mid": 2000})
+	client := newPooledClient(server, clock.NewFake(githubtest.Epoch), "low", "high", "mid")
+
+	// The low token is under the rate limit threshold, so a request backing
+	// off for it would wait on the fake clock until the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 6; i++ {
+		_, err := client.GetRepository(ctx, "acme", "widgets")
+		require.NoError(t, err)
+	}
+
+	// Each token is tried once to learn its quota, then the one with the
+	// most remaining is preferred
+	assert.Equal(t, []string{"low", "high", "mid", "high", "high", "high"}, server.tokensUsed())
+
+	stats := client.Stats()
+	require.NotNil(t, stats.LastRateLimit)
+	assert.Equal(t, 3996, stats.LastRateLimit.Remaining)
+	require.Len(t, stats.TokenRateLimits, 3)
+	assert.Equal(t, 99, stats.TokenRateLimits[0].Remaining)
+	assert.Equal(t, 1999, stats.TokenRateLimits[2].Remaining)
+}
+
+func TestTokenPoolRotatesTies(t *testing.T) {
+	server := newTokenServer(t, map[string]int{"a": 3000, "b": 3000})
+	client := newPooledClient(server, clock.NewFake(githubtest.Epoch), "a", "b")
+
+	for i := 0; i < 4; i++ {
+		_, err := client.GetRepository(context.Background(), "acme", "widgets")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"a", "b", "a", "b"}, server.tokensUsed())
+}
+
+func TestTokenPoolAfterReset(t *testing.T) {
+	server := newTokenServer(t, map[string]int{"spent": 1200, "fresh": 1500})
+	fake := clock.NewFake(githubtest.Epoch)
+	client := newPooledClient(server, fake, "spent", "fresh")
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetRepository(context.Background(), "acme", "widgets")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"spent", "fresh", "fresh"}, server.tokensUsed())
+
+	// Once the window resets, the spent token counts as having its full quota
+	fake.Advance(time.Hour)
+	_, err := client.GetRepository(context.Background(), "acme", "widgets")
+	require.NoError(t, err)
+	assert.Equal(t, "spent", server.tokensUsed()[3])
+}
@@ -0,0 +1,89 @@
+package history
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/history"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+func newStore(t *testing.T) *history.Store {
+	db, err := storage.OpenDatabase(filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, storage.NewMigrationManager(db, "../../../internal/storage/migrations").MigrateWithLock(context.Background(), "test", time.Minute))
+	return history.NewStore(db)
+}
+
+func TestHistoryRecordsVersionsInEffect(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	added, err := store.Record(ctx, history.KindPolicy, "upload", []byte("v1"), day)
+	require.NoError(t, err)
+	assert.True(t, added)
+	// Unchanged data doesn't add a version
+	added, err = store.Record(ctx, history.KindPolicy, "upload", []byte("v1"), day.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, added)
+	added, err = store.Record(ctx, history.KindPolicy, "upload", []byte("v2"), day.Add(48*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	snapshot, err := store.At(ctx, history.KindPolicy, "upload", day.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Nil(t, snapshot)
+
+	snapshot, err = store.At(ctx, history.KindPolicy, "upload", day.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	assert.Equal(t, "v1", string(snapshot.Data))
+	assert.Contains(t, snapshot.Digest, "sha256:")
+	assert.True(t, day.Equal(snapshot.RecordedAt))
+
+	snapshot, err = store.At(ctx, history.KindPolicy, "upload", day.Add(72*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(snapshot.Data))
+}
+
+func TestHistoryAllAtAndPrune(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	record := func(key, data string, at time.Time) {
+		_, err := store.Record(ctx, history.KindAdvisory, key, []byte(data), at)
+		require.NoError(t, err)
+	}
+	record("GHSA-1", "a1", day)
+	record("GHSA-1", "a2", day.Add(24*time.Hour))
+	record("GHSA-1", "a3", day.Add(72*time.Hour))
+	record("GHSA-2", "b1", day.Add(48*time.Hour))
+
+	contents := func(at time.Time) []string {
+		snapshots, err := store.AllAt(ctx, history.KindAdvisory, at)
+		require.NoError(t, err)
+		var data []string
+		for _, snapshot := range snapshots {
+			data = append(data, snapshot.Key+"="+string(snapshot.Data))
+		}
+		return data
+	}
+	assert.Equal(t, []string{"GHSA-1=a2"}, contents(day.Add(36*time.Hour)))
+	assert.Equal(t, []string{"GHSA-1=a2", "GHSA-2=b1"}, contents(day.Add(60*time.Hour)))
+
+	// a1 was superseded before the cutoff; a2 is still in effect at it
+	pruned, err := store.Prune(ctx, day.Add(60*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+	assert.Empty(t, contents(day.Add(12*time.Hour)))
+	assert.Equal(t, []string{"GHSA-1=a2", "GHSA-2=b1"}, contents(day.Add(60*time.Hour)))
+	assert.Equal(t, []string{"GHSA-1=a3", "GHSA-2=b1"}, contents(day.Add(96*time.Hour)))
+}
@@ -0,0 +1,288 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/index"
+	"github.com/salman-frs/keystone/apps/api/internal/ingest"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+	"github.com/salman-frs/keystone/apps/api/internal/sigv4"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+const cycloneDX = `{"bomFormat":"CycloneDX","specVersion":"1.5","metadata":{"component":{"name":"payments"}},
+	"components":[{"name":"net","version":"v0.15.0","purl":"pkg:golang/golang.org/x/net@v0.15.0"}]}`
+
+// objects is an in-memory bucket shared by the fake object stores
+type objects struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+}
+
+func newObjects(files map[string]string) *objects {
+	o := &objects{data: make(map[string][]byte)}
+	for key, content := range files {
+		o.data[key] = []byte(content)
+	}
+	return o
+}
+
+func (o *objects) keys() []string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	var keys []string
+	for key := range o.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (o *objects) get(key string) ([]byte, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	data, found := o.data[key]
+	return data, found
+}
+
+func (o *objects) put(key string, data []byte) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.data[key] = data
+}
+
+func (o *objects) remove(key string) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	_, found := o.data[key]
+	delete(o.data, key)
+	return found
+}
+
+// fakeS3 serves the path-style S3 API for bucket "drops"
+func fakeS3(t *testing.T, store *objects) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/s3/aws4_request")
+		assert.NotEmpty(t, r.Header.Get("X-Amz-Content-Sha256"))
+
+		key := strings.TrimPrefix(r.URL.Path, "/drops/")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			type content struct {
+				Key  string `xml:"Key"`
+				Size int    `xml:"Size"`
+			}
+			var result struct {
+				XMLName  xml.Name  `xml:"ListBucketResult"`
+				Contents []content `xml:"Contents"`
+			}
+			for _, candidate := range store.keys() {
+				if strings.HasPrefix(candidate, r.URL.Query().Get("prefix")) {
+					data, _ := store.get(candidate)
+					result.Contents = append(result.Contents, content{Key: candidate, Size: len(data)})
+				}
+			}
+			xml.NewEncoder(w).Encode(result)
+		case r.Method == http.MethodGet:
+			data, found := store.get(key)
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+			source, err := url.PathUnescape(strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/drops/"))
+			require.NoError(t, err)
+			data, found := store.get(source)
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			store.put(key, data)
+		case r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			store.put(key, data)
+		case r.Method == http.MethodDelete:
+			store.remove(key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+// fakeGCS serves the Cloud Storage JSON API for bucket "drops"
+func fakeGCS(t *testing.T, store *objects) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+		path := r.URL.EscapedPath()
+		object := func(escaped string) string {
+			key, err := url.PathUnescape(escaped)
+			require.NoError(t, err)
+			return key
+		}
+		switch {
+		case r.Method == http.MethodGet && path == "/storage/v1/b/drops/o":
+			var items []map[string]string
+			for _, key := range store.keys() {
+				if strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+					items = append(items, map[string]string{"name": key, "size": "1"})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+		case r.Method == http.MethodPost && path == "/upload/storage/v1/b/drops/o":
+			data, _ := io.ReadAll(r.Body)
+			store.put(r.URL.Query().Get("name"), data)
+			w.Write([]byte(`{}`))
+		case r.Method == http.MethodPost && strings.Contains(path, "/rewriteTo/"):
+			from, to, _ := strings.Cut(strings.TrimPrefix(path, "/storage/v1/b/drops/o/"), "/rewriteTo/b/drops/o/")
+			data, found := store.get(object(from))
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			store.put(object(to), data)
+			w.Write([]byte(`{"done":true}`))
+		case r.Method == http.MethodGet:
+			data, found := store.get(object(strings.TrimPrefix(path, "/storage/v1/b/drops/o/")))
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case r.Method == http.MethodDelete:
+			store.remove(object(strings.TrimPrefix(path, "/storage/v1/b/drops/o/")))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+// staticTrust serves a fixed trust root
+type staticTrust struct{}
+
+func (staticTrust) TrustRoot(context.Context) (attestation.TrustRoot, error) {
+	return attestation.TrustRoot{}, nil
+}
+
+func newIngester(t *testing.T, bucket ingest.Bucket, prefix string) (*ingest.Ingester, *sbom.Store) {
+	db, err := storage.OpenDatabase(filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, storage.NewMigrationManager(db, "../../../internal/storage/migrations").MigrateWithLock(context.Background(), "test", time.Minute))
+
+	sboms := sbom.NewStore(db)
+	return ingest.New(bucket, prefix, staticTrust{}, index.NewStore(db), sboms, ingest.DefaultConfig()), sboms
+}
+
+// dropped are the files a pipeline wrote under the drop prefix
+func dropped() map[string]string {
+	return map[string]string{
+		"drops/build-1/sbom.cdx.json": cycloneDX,
+		"drops/build-1/notes.txt":     "release notes",
+		"drops/build-1/bundle.json":   `{"mediaType":"` + attestation.BundleMediaType + `"}`,
+		"drops/archive/old.json":      cycloneDX,
+		"other/sbom.cdx.json":         cycloneDX,
+	}
+}
+
+func assertIngested(t *testing.T, store *objects, sboms *sbom.Store) {
+	assert.Equal(t, []string{
+		"drops/archive/build-1/sbom.cdx.json",
+		"drops/archive/old.json",
+		"drops/quarantine/build-1/bundle.json",
+		"drops/quarantine/build-1/bundle.json.error.json",
+		"drops/quarantine/build-1/notes.txt",
+		"drops/quarantine/build-1/notes.txt.error.json",
+		"other/sbom.cdx.json",
+	}, store.keys())
+
+	noteData, _ := store.get("drops/quarantine/build-1/bundle.json.error.json")
+	var note ingest.ErrorNote
+	require.NoError(t, json.Unmarshal(noteData, &note))
+	assert.Equal(t, "drops/build-1/bundle.json", note.Object)
+	assert.NotEmpty(t, note.Code)
+	require.NotNil(t, note.Report)
+	assert.False(t, note.Report.Valid)
+
+	noteData, _ = store.get("drops/quarantine/build-1/notes.txt.error.json")
+	require.NoError(t, json.Unmarshal(noteData, &note))
+	assert.Contains(t, note.Error, "neither a verification bundle nor")
+
+	doc, err := sboms.Get(context.Background(), "sha256:"+digestOf(cycloneDX))
+	require.NoError(t, err)
+	require.NotNil(t, doc)
+	assert.Equal(t, sbom.FormatCycloneDX, doc.Format)
+	assert.Equal(t, 1, doc.ComponentCount)
+	assert.Equal(t, "drops/build-1/sbom.cdx.json", doc.Source)
+}
+
+func TestIngestFromS3(t *testing.T) {
+	store := newObjects(dropped())
+	server := fakeS3(t, store)
+	defer server.Close()
+
+	bucket, prefix, err := ingest.OpenBucket("s3://drops/drops", ingest.Credentials{
+		AWS:        sigv4.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		AWSRegion:  "eu-west-1",
+		S3Endpoint: server.URL,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "drops/", prefix)
+
+	ingester, sboms := newIngester(t, bucket, prefix)
+	summary, err := ingester.Poll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, ingest.Summary{Archived: 1, Quarantined: 2}, *summary)
+	assertIngested(t, store, sboms)
+
+	// Nothing is left to ingest
+	summary, err = ingester.Poll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, ingest.Summary{}, *summary)
+}
+
+func TestIngestFromGCS(t *testing.T) {
+	store := newObjects(dropped())
+	server := fakeGCS(t, store)
+	defer server.Close()
+
+	bucket, prefix, err := ingest.OpenBucket("gs://drops/drops/", ingest.Credentials{GCSToken: "test-token", GCSEndpoint: server.URL})
+	require.NoError(t, err)
+
+	ingester, sboms := newIngester(t, bucket, prefix)
+	summary, err := ingester.Poll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, ingest.Summary{Archived: 1, Quarantined: 2}, *summary)
+	assertIngested(t, store, sboms)
+}
+
+func TestOpenBucketRejectsUnknownSources(t *testing.T) {
+	_, _, err := ingest.OpenBucket("azblob://drops/prefix", ingest.Credentials{})
+	assert.ErrorContains(t, err, "unsupported drop bucket scheme")
+	_, _, err = ingest.OpenBucket("s3://drops", ingest.Credentials{})
+	assert.ErrorContains(t, err, "AWS_ACCESS_KEY_ID")
+	_, _, err = ingest.OpenBucket("drops/prefix", ingest.Credentials{})
+	assert.ErrorContains(t, err, "must be s3://")
+}
+
+func digestOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
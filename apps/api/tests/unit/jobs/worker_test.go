@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+	"github.com/salman-frs/keystone/apps/api/internal/jobs"
+)
+
+// collect subscribes to job outcome events and forwards their results
+func collect(t *testing.T, bus events.Bus) <-chan jobs.Result {
+	results := make(chan jobs.Result, 10)
+	forward := func(ctx context.Context, event events.Event) error {
+		var result jobs.Result
+		require.NoError(t, event.Decode(&result))
+		results <- result
+		return nil
+	}
+
+	_, err := bus.Subscribe(events.TypeJobCompleted, forward)
+	require.NoError(t, err)
+	_, err = bus.Subscribe(events.TypeJobFailed, forward)
+	require.NoError(t, err)
+
+	return results
+}
+
+func waitResult(t *testing.T, results <-chan jobs.Result) jobs.Result {
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for job result")
+		return jobs.Result{}
+	}
+}
+
+func TestWorkerRunsSubmittedJobs(t *testing.T) {
+	bus := events.NewMemoryBus()
+	defer bus.Close()
+	results := collect(t, bus)
+
+	worker := jobs.NewWorker(bus, jobs.DefaultWorkerConfig("worker-1"))
+	worker.Register(jobs.KindAdvisorySync, func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		var payload jobs.AdvisorySyncPayload
+		if err := job.DecodePayload(&payload); err != nil {
+			return nil, err
+		}
+		return map[string]string{"ecosystem": payload.Ecosystem}, nil
+	})
+	worker.Register(jobs.KindScan, func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		return nil, errors.New("scanner unavailable")
+	})
+	require.NoError(t, worker.Start())
+	assert.Error(t, worker.Start())
+
+	t.Run("completed", func(t *testing.T) {
+		job, err := jobs.Submit(context.Background(), bus, "test", jobs.KindAdvisorySync,
+			jobs.AdvisorySyncPayload{Ecosystem: "go", Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+		require.NoError(t, err)
+
+		result := waitResult(t, results)
+		assert.Equal(t, job.ID, result.JobID)
+		assert.Equal(t, "worker-1", result.Worker)
+		assert.Empty(t, result.Error)
+		assert.Equal(t, map[string]interface{}{"ecosystem": "go"}, result.Output)
+	})
+
+	t.Run("runner_error", func(t *testing.T) {
+		_, err := jobs.Submit(context.Background(), bus, "test", jobs.KindScan, nil)
+		require.NoError(t, err)
+
+		result := waitResult(t, results)
+		assert.Equal(t, "scanner unavailable", result.Error)
+	})
+
+	t.Run("unregistered_kind", func(t *testing.T) {
+		_, err := jobs.Submit(context.Background(), bus, "test", jobs.KindVerification, nil)
+		require.NoError(t, err)
+
+		result := waitResult(t, results)
+		assert.Contains(t, result.Error, "no runner registered")
+	})
+
+	t.Run("stopped_worker_ignores_jobs", func(t *testing.T) {
+		require.NoError(t, worker.Stop(context.Background()))
+
+		_, err := jobs.Submit(context.Background(), bus, "test", jobs.KindAdvisorySync, nil)
+		require.NoError(t, err)
+
+		select {
+		case result := <-results:
+			t.Fatalf("unexpected result after stop: %+v", result)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func TestWorkerStopCancelsLongJobs(t *testing.T) {
+	bus := events.NewMemoryBus()
+	defer bus.Close()
+	results := collect(t, bus)
+
+	started := make(chan struct{})
+	worker := jobs.NewWorker(bus, jobs.DefaultWorkerConfig("worker-1"))
+	worker.Register(jobs.KindScan, func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	require.NoError(t, worker.Start())
+
+	_, err := jobs.Submit(context.Background(), bus, "test", jobs.KindScan, nil)
+	require.NoError(t, err)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, worker.Stop(ctx), context.DeadlineExceeded)
+
+	result := waitResult(t, results)
+	assert.Equal(t, context.Canceled.Error(), result.Error)
+}
@@ -0,0 +1,57 @@
+package leaks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/leaks"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+func alert(repository string, number int, validity string) github.SecretScanningAlert {
+	return github.SecretScanningAlert{
+		Number:     number,
+		State:      github.SecretAlertOpen,
+		Repository: repository,
+		SecretType: "github_personal_access_token",
+		Validity:   validity,
+		CreatedAt:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestFindingsCorrelateSigningIdentities(t *testing.T) {
+	identities := []attestation.CertificateIdentity{
+		{Repository: "acme/widgets", WorkflowRef: "acme/widgets/.github/workflows/release.yml@refs/heads/main"},
+		{Repository: "acme/widgets", WorkflowRef: "acme/widgets/.github/workflows/release.yml@refs/heads/main"},
+		{Repository: "acme/widgets", WorkflowRef: "acme/widgets/.github/workflows/build.yml@refs/heads/main"},
+	}
+	resolved := alert("acme/widgets", 9, "active")
+	resolved.State = github.SecretAlertResolved
+	alerts := []github.SecretScanningAlert{
+		alert("acme/widgets", 1, "active"),
+		alert("Acme/Widgets", 2, "inactive"),
+		alert("acme/gadgets", 3, "active"),
+		alert("acme/gadgets", 4, "unknown"),
+		resolved,
+	}
+
+	found := leaks.Findings(alerts, identities)
+	require.Len(t, found, 4)
+
+	assert.Equal(t, findings.SeverityCritical, found[0].Severity)
+	assert.Equal(t, findings.CategorySecret, found[0].Category)
+	assert.Equal(t, "github-secret-scanning:github_personal_access_token:acme/widgets#1", found[0].ID)
+	assert.Equal(t, "acme/widgets/.github/workflows/build.yml@refs/heads/main,acme/widgets/.github/workflows/release.yml@refs/heads/main",
+		found[0].Metadata["workflow_ref"])
+	assert.Contains(t, found[0].Description, "signs attestations")
+
+	assert.Equal(t, findings.SeverityHigh, found[1].Severity, "a revoked credential from a signing repository")
+	assert.Equal(t, findings.SeverityHigh, found[2].Severity, "an active credential elsewhere")
+	assert.Equal(t, findings.SeverityMedium, found[3].Severity)
+	assert.Empty(t, found[3].Metadata["workflow_ref"])
+}
@@ -0,0 +1,100 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/loadtest"
+)
+
+func testConfig(t *testing.T) loadtest.Config {
+	config := loadtest.DefaultConfig()
+	config.Artifacts = 20
+	config.Verifications = 200
+	config.Concurrency = []int{1, 4}
+	config.L1MaxItems = []int{5, 100}
+	config.MigrationsDir = "../../../internal/storage/migrations"
+	config.Dir = t.TempDir()
+	return config
+}
+
+func TestSyntheticArtifactsVerify(t *testing.T) {
+	sigstore, err := loadtest.NewSigstore()
+	require.NoError(t, err)
+
+	artifacts, err := sigstore.Generate(3)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 3)
+	assert.NotEqual(t, artifacts[0].Digest, artifacts[1].Digest)
+
+	for _, artifact := range artifacts {
+		result, err := attestation.VerifyBundle(artifact.Bundle, sigstore.TrustRoot(), sigstore.Policy())
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, loadtest.SyntheticIssuer, result.Issuer)
+	}
+}
+
+func TestRunMatrix(t *testing.T) {
+	config := testConfig(t)
+	var progress []string
+	report, err := loadtest.Run(context.Background(), config, func(result loadtest.Result) {
+		progress = append(progress, result.Scenario.Name)
+	})
+	require.NoError(t, err)
+	require.Len(t, report.Results, 8)
+	assert.Len(t, progress, 8)
+
+	for _, result := range report.Results {
+		assert.Equal(t, 200, result.Verifications, result.Scenario.Name)
+		assert.Zero(t, result.Failures, result.Scenario.Name)
+		assert.Greater(t, result.Throughput, 0.0)
+		assert.Greater(t, result.CacheHitRatio, 0.5, "repeat verifications of 20 artifacts come from the result cache")
+		assert.LessOrEqual(t, result.Latency.P50, result.Latency.P99)
+		assert.NotZero(t, result.IndexWrites.P50)
+	}
+
+	markdown := report.Markdown()
+	assert.Contains(t, markdown, "## Verification load test")
+	assert.Contains(t, markdown, "`sqlite-file/l1=5/c=1`")
+	assert.Contains(t, markdown, "`sqlite-memory/l1=100/c=4`")
+
+	data, err := report.JSON()
+	require.NoError(t, err)
+	var decoded loadtest.Report
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Len(t, decoded.Results, 8)
+}
+
+func TestConfigValidate(t *testing.T) {
+	config := testConfig(t)
+	config.Databases = []string{"postgres"}
+	assert.Error(t, config.Validate())
+
+	config = testConfig(t)
+	config.HotFraction = 1.5
+	assert.Error(t, config.Validate())
+
+	config = testConfig(t)
+	config.Concurrency = nil
+	assert.Error(t, config.Validate())
+}
+
+func BenchmarkVerification(b *testing.B) {
+	sigstore, err := loadtest.NewSigstore()
+	require.NoError(b, err)
+	artifacts, err := sigstore.Generate(1)
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := attestation.VerifyBundle(artifacts[0].Bundle, sigstore.TrustRoot(), sigstore.Policy()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package messages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/messages"
+)
+
+func writeCatalog(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func TestRenderSubstitutesParams(t *testing.T) {
+	catalog := messages.Default()
+	message := messages.ForPolicyRule("branch", "refs/heads/main", "refs/heads/dev")
+	assert.Equal(t, messages.PolicyBranch, message.ID)
+	assert.Equal(t, "The artifact was signed from refs/heads/dev, but the policy requires refs/heads/main.",
+		catalog.Render("en", message))
+
+	message = messages.ForPackageStatus("missing_attestation", "npm", "left-pad", "1.3.0")
+	assert.Equal(t, "The npm package left-pad@1.3.0 was published without provenance.", catalog.Render("", message))
+
+	// Unknown codes fall back to the generic failure, keeping the code for automation
+	message = messages.ForCode("SIGN_999", "")
+	assert.Equal(t, messages.VerificationFailed, message.ID)
+	assert.Equal(t, messages.Params{"code": "SIGN_999"}, message.Params)
+
+	assert.Equal(t, "custom.message", catalog.Render("en", &messages.Message{ID: "custom.message"}))
+}
+
+func TestEveryCodeHasEnglishText(t *testing.T) {
+	catalog := messages.Default()
+	for _, code := range []string{"SIGN_001", "SIGN_013", "SIGN_053", "SIGN_062", "SIGN_083"} {
+		message := messages.ForCode(code, "ghcr.io/owner/app")
+		assert.NotEqual(t, messages.VerificationFailed, message.ID, code)
+		assert.NotEqual(t, string(message.ID), catalog.Render("en", message), code)
+	}
+}
+
+func TestLoadTranslations(t *testing.T) {
+	dir := t.TempDir()
+	writeCatalog(t, dir, "de.yaml", `
+locale: de
+messages:
+  policy.branch: "Das Artefakt wurde aus {actual} signiert, die Richtlinie verlangt {expected}."
+`)
+	catalog := messages.Default()
+	require.NoError(t, catalog.LoadDir(dir))
+	assert.Equal(t, []string{"de", "en"}, catalog.Locales())
+
+	message := messages.ForPolicyRule("branch", "refs/heads/main", "refs/heads/dev")
+	catalog.Localize("de-AT", message)
+	assert.Equal(t, "de", message.Locale, "regional locales fall back to their language")
+	assert.Equal(t, "Das Artefakt wurde aus refs/heads/dev signiert, die Richtlinie verlangt refs/heads/main.", message.Text)
+
+	// Untranslated messages fall back to English
+	message = messages.ForCode("SIGN_057", "")
+	catalog.Localize("de", message)
+	assert.Equal(t, "en", message.Locale)
+	assert.Equal(t, "The artifact was signed from a branch the policy doesn't allow.", message.Text)
+}
+
+func TestLoadRejectsInvalidTranslations(t *testing.T) {
+	dir := t.TempDir()
+	writeCatalog(t, dir, "fr.yaml", `
+messages:
+  policy.branch: "L'artefact a été signé depuis une branche non autorisée."
+`)
+	err := messages.Default().LoadFile(filepath.Join(dir, "fr.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "uses parameters")
+
+	writeCatalog(t, dir, "es.yaml", `
+messages:
+  policy.unknown: "Desconocido"
+`)
+	err = messages.Default().LoadFile(filepath.Join(dir, "es.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown message")
+}
+
+func TestNegotiate(t *testing.T) {
+	dir := t.TempDir()
+	writeCatalog(t, dir, "pt_BR.yaml", `
+messages:
+  verification.failed: "A verificação falhou."
+`)
+	writeCatalog(t, dir, "de.yaml", `
+messages:
+  verification.failed: "Die Verifizierung ist fehlgeschlagen."
+`)
+	catalog := messages.Default()
+	require.NoError(t, catalog.LoadDir(dir))
+
+	assert.Equal(t, "pt-br", catalog.Negotiate("pt-BR,pt;q=0.9,en;q=0.8"))
+	assert.Equal(t, "de", catalog.Negotiate("fr-CH, fr;q=0.9, de;q=0.7, *;q=0.5"))
+	assert.Equal(t, "de", catalog.Negotiate("de-DE;q=0.4, ja;q=0.9"))
+	assert.Equal(t, "en", catalog.Negotiate("ja"))
+	assert.Equal(t, "en", catalog.Negotiate(""))
+}
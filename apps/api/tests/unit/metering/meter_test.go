@@ -0,0 +1,139 @@
+package metering
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+	"github.com/salman-frs/keystone/apps/api/internal/jobs"
+	"github.com/salman-frs/keystone/apps/api/internal/metering"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+	"github.com/salman-frs/keystone/apps/api/pkg/github"
+)
+
+const migrationsDir = "../../../internal/storage/migrations"
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := storage.OpenDatabase(filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, storage.NewMigrationManager(db, migrationsDir).MigrateWithLock(context.Background(), "test", time.Minute))
+	return db
+}
+
+func TestTenantFromContext(t *testing.T) {
+	assert.Equal(t, metering.DefaultTenant, metering.TenantFromContext(context.Background()))
+	assert.Equal(t, metering.DefaultTenant, metering.TenantFromContext(metering.WithTenant(context.Background(), "")))
+	assert.Equal(t, "acme", metering.TenantFromContext(metering.WithTenant(context.Background(), "acme")))
+}
+
+func TestMeterRecordAndUsage(t *testing.T) {
+	meter := metering.NewMeter(openDB(t))
+	acme := metering.WithTenant(context.Background(), "acme")
+	globex := metering.WithTenant(context.Background(), "globex")
+	since := time.Now().Add(-time.Hour)
+
+	require.NoError(t, meter.Record(acme, metering.MetricScans, 1))
+	require.NoError(t, meter.Record(acme, metering.MetricScans, 2))
+	require.NoError(t, meter.Record(acme, metering.MetricStorageBytes, 4096))
+	require.NoError(t, meter.Record(globex, metering.MetricScans, 5))
+
+	usage, err := meter.Usage(context.Background(), "acme", since, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "acme", usage.Tenant)
+	assert.Equal(t, map[string]int64{
+		metering.MetricScans:        3,
+		metering.MetricStorageBytes: 4096,
+	}, usage.Totals)
+	assert.Len(t, usage.Buckets, 2, "repeated records share the hourly bucket")
+
+	total, err := meter.Total(context.Background(), "globex", metering.MetricScans, since)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+
+	total, err = meter.Total(context.Background(), "initech", metering.MetricScans, since)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	// Buckets outside the range are excluded
+	usage, err = meter.Usage(context.Background(), "acme", time.Now().Add(2*time.Hour), time.Now().Add(3*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, usage.Buckets)
+}
+
+func TestMeterGitHubRequestHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	meter := metering.NewMeter(openDB(t))
+	config := github.DefaultConfig("token")
+	config.BaseURL = server.URL
+	config.OnRequest = meter.GitHubRequestHook()
+	client := github.NewClient(config)
+
+	ctx := metering.WithTenant(context.Background(), "acme")
+	for i := 0; i < 3; i++ {
+		_, err := client.GetSecurityAdvisories(ctx, 10)
+		require.NoError(t, err)
+	}
+	// Revalidated responses are free of the rate limit
+	config.OnRequest(ctx, http.MethodGet, server.URL+"/advisories", http.StatusNotModified)
+
+	total, err := meter.Total(context.Background(), "acme", metering.MetricGitHubRequests, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+}
+
+func TestWorkerMetersJobsPerTenant(t *testing.T) {
+	bus := events.NewMemoryBus()
+	defer bus.Close()
+	meter := metering.NewMeter(openDB(t))
+
+	done := make(chan struct{}, 2)
+	_, err := bus.Subscribe(events.TypeJobCompleted, func(ctx context.Context, event events.Event) error {
+		done <- struct{}{}
+		return nil
+	})
+	require.NoError(t, err)
+
+	worker := jobs.NewWorker(bus, jobs.DefaultWorkerConfig("worker-1"))
+	worker.SetMeter(meter)
+	worker.Register(jobs.KindScan, func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		assert.Equal(t, job.Tenant, metering.TenantFromContext(ctx))
+		return nil, nil
+	})
+	require.NoError(t, worker.Start())
+	defer worker.Stop(context.Background())
+
+	_, err = jobs.Submit(metering.WithTenant(context.Background(), "acme"), bus, "test", jobs.KindScan, nil)
+	require.NoError(t, err)
+	_, err = jobs.Submit(context.Background(), bus, "test", jobs.KindScan, nil)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for jobs")
+		}
+	}
+
+	since := time.Now().Add(-time.Hour)
+	for _, tenant := range []string{"acme", metering.DefaultTenant} {
+		total, err := meter.Total(context.Background(), tenant, metering.MetricScans, since)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total, tenant)
+	}
+}
@@ -0,0 +1,42 @@
+package metering
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/metering"
+)
+
+func TestParseTenantTokens(t *testing.T) {
+	tokens, err := metering.ParseTenantTokens(" acme=acme-token, globex=globex-token,")
+	require.NoError(t, err)
+	assert.Equal(t, metering.TenantTokens{"acme": "acme-token", "globex": "globex-token"}, tokens)
+
+	assert.Equal(t, "acme", tokens.Tenant("acme-token"))
+	assert.Equal(t, "globex", tokens.Tenant("globex-token"))
+	assert.Empty(t, tokens.Tenant("acme"), "a tenant name is not its token")
+	assert.Empty(t, tokens.Tenant(""))
+
+	empty, err := metering.ParseTenantTokens("")
+	require.NoError(t, err)
+	assert.Empty(t, empty.Tenant("acme-token"))
+
+	for _, value := range []string{
+		"acme-token",
+		"acme=",
+		"=acme-token",
+		"acme=one,acme=two",
+		"acme=shared,globex=shared",
+	} {
+		_, err := metering.ParseTenantTokens(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseTenantTokensDoesNotEchoBareTokens(t *testing.T) {
+	_, err := metering.ParseTenantTokens("acme=acme-token,s3cr3t")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "s3cr3t")
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/salman-frs/keystone/apps/api/internal/metrics"
+)
+
+func TestRegistryText(t *testing.T) {
+	registry := metrics.NewRegistry()
+	requests := registry.Counter("requests_total", "Requests served", "code")
+	requests.With("200").Add(2)
+	requests.With("500").Inc()
+	// Registering again returns the same counter
+	registry.Counter("requests_total", "Requests served", "code").With("200").Inc()
+
+	durations := registry.Histogram("duration_seconds", "Request latency", []float64{0.1, 1})
+	durations.Observe(0.05)
+	durations.Observe(0.5)
+	durations.Observe(5)
+
+	registry.GaugeFunc("queue_depth", "Jobs waiting\nby queue", func() map[string]float64 {
+		return map[string]float64{metrics.Labels("scan"): 3, metrics.Labels("sync"): 0}
+	}, "queue")
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, strings.Join([]string{
+		"# HELP duration_seconds Request latency",
+		"# TYPE duration_seconds histogram",
+		`duration_seconds_bucket{le="0.1"} 1`,
+		`duration_seconds_bucket{le="1"} 2`,
+		`duration_seconds_bucket{le="+Inf"} 3`,
+		"duration_seconds_sum 5.55",
+		"duration_seconds_count 3",
+		`# HELP queue_depth Jobs waiting\nby queue`,
+		"# TYPE queue_depth gauge",
+		`queue_depth{queue="scan"} 3`,
+		`queue_depth{queue="sync"} 0`,
+		"# HELP requests_total Requests served",
+		"# TYPE requests_total counter",
+		`requests_total{code="200"} 3`,
+		`requests_total{code="500"} 1`,
+		"",
+	}, "\n"), recorder.Body.String())
+}
+
+func TestRegistryKindConflict(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Counter("jobs", "Jobs")
+	assert.Panics(t, func() { registry.Histogram("jobs", "Jobs", metrics.DefaultBuckets) })
+	assert.Panics(t, func() { registry.GaugeFunc("jobs", "Jobs", nil) })
+	assert.Panics(t, func() { registry.Counter("jobs", "Jobs").With("extra") })
+}
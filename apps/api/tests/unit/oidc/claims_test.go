@@ -0,0 +1,59 @@
+package oidc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/pkg/oidc"
+)
+
+func TestValidateClaimsLeeway(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	claims := func(issuedAt, notBefore, expiresAt time.Time) *oidc.Claims {
+		return &oidc.Claims{
+			Issuer:    oidc.GitHubIssuer,
+			Subject:   "repo:octo-org/app:ref:refs/heads/main",
+			Audience:  oidc.Audience{"sigstore"},
+			IssuedAt:  issuedAt.Unix(),
+			NotBefore: notBefore.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		}
+	}
+	leeway := 30 * time.Second
+
+	tests := []struct {
+		name   string
+		claims *oidc.Claims
+		valid  bool
+	}{
+		{"valid", claims(now, now, now.Add(10*time.Minute)), true},
+		{"expired within leeway", claims(now.Add(-10*time.Minute), now.Add(-10*time.Minute), now.Add(-leeway+time.Second)), true},
+		{"expired at leeway", claims(now.Add(-10*time.Minute), now.Add(-10*time.Minute), now.Add(-leeway)), false},
+		{"not yet valid within leeway", claims(now, now.Add(leeway), now.Add(10*time.Minute)), true},
+		{"not yet valid beyond leeway", claims(now, now.Add(leeway+time.Second), now.Add(10*time.Minute)), false},
+		{"issued in the future within leeway", claims(now.Add(leeway), now, now.Add(10*time.Minute)), true},
+		{"issued in the future beyond leeway", claims(now.Add(leeway+time.Second), now, now.Add(10*time.Minute)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := oidc.ValidateClaimsWithLeeway(tt.claims, oidc.GitHubIssuer, "sigstore", now, leeway)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, attestation.CodeTokenExpired, attestation.CodeOf(err))
+			}
+		})
+	}
+
+	// Without leeway a token is rejected the second it expires
+	expiring := claims(now.Add(-10*time.Minute), now.Add(-10*time.Minute), now)
+	assert.Equal(t, attestation.CodeTokenExpired, attestation.CodeOf(oidc.ValidateClaimsWithLeeway(expiring, oidc.GitHubIssuer, "sigstore", now, 0)))
+	assert.NoError(t, oidc.ValidateClaimsWithLeeway(expiring, oidc.GitHubIssuer, "sigstore", now.Add(-time.Second), 0))
+
+	// ValidateClaims tolerates the default drift
+	assert.NoError(t, oidc.ValidateClaims(expiring, oidc.GitHubIssuer, "sigstore", now.Add(oidc.DefaultLeeway-time.Second)))
+	assert.Error(t, oidc.ValidateClaims(expiring, oidc.GitHubIssuer, "sigstore", now.Add(oidc.DefaultLeeway)))
+}
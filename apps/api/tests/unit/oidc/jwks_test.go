@@ -0,0 +1,296 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/pkg/oidc"
+)
+
+// issuer serves a JWKS for the keys it signs tokens with
+type issuer struct {
+	server  *httptest.Server
+	keys    map[string]*rsa.PrivateKey
+	ecKeys  map[string]*ecdsa.PrivateKey
+	fetches atomic.Int32
+}
+
+func newIssuer(t *testing.T, kids ...string) *issuer {
+	t.Helper()
+	iss := &issuer{keys: map[string]*rsa.PrivateKey{}, ecKeys: map[string]*ecdsa.PrivateKey{}}
+	for _, kid := range kids {
+		iss.addKey(t, kid)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": iss.server.URL, "jwks_uri": iss.server.URL + "/.well-known/jwks"})
+	})
+	mux.HandleFunc("/.well-known/jwks", func(w http.ResponseWriter, r *http.Request) {
+		iss.fetches.Add(1)
+		set := oidc.JSONWebKeySet{}
+		for kid, key := range iss.keys {
+			set.Keys = append(set.Keys, oidc.JSONWebKey{
+				KeyType: "RSA", KeyID: kid, Algorithm: "RS256", Use: "sig",
+				N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		for kid, key := range iss.ecKeys {
+			size := (key.Curve.Params().BitSize + 7) / 8
+			set.Keys = append(set.Keys, oidc.JSONWebKey{
+				KeyType: "EC", KeyID: kid, Use: "sig", Curve: key.Curve.Params().Name,
+				X: base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+				Y: base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	})
+	iss.server = httptest.NewServer(mux)
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+func (i *issuer) addKey(t *testing.T, kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	i.keys[kid] = key
+}
+
+func (i *issuer) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.keys[kid], crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (i *issuer) addECKey(t *testing.T, kid string, curve elliptic.Curve) {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	require.NoError(t, err)
+	i.ecKeys[kid] = key
+}
+
+// signEC signs a token with an EC key, hashing as the named algorithm does
+// whatever the key's curve
+func (i *issuer) signEC(t *testing.T, kid, algorithm string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": algorithm, "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	var digest []byte
+	switch algorithm {
+	case "ES384":
+		sum := sha512.Sum384([]byte(signed))
+		digest = sum[:]
+	case "ES512":
+		sum := sha512.Sum512([]byte(signed))
+		digest = sum[:]
+	default:
+		sum := sha256.Sum256([]byte(signed))
+		digest = sum[:]
+	}
+	key := i.ecKeys[kid]
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	require.NoError(t, err)
+	size := (key.Curve.Params().BitSize + 7) / 8
+	signature := append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (i *issuer) claims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":              i.server.URL,
+		"aud":              "sigstore",
+		"sub":              "repo:octo-org/app:ref:refs/heads/main",
+		"iat":              now.Unix(),
+		"exp":              now.Add(5 * time.Minute).Unix(),
+		"repository":       "octo-org/app",
+		"job_workflow_ref": "octo-org/workflows/.github/workflows/release.yml@refs/heads/main",
+		"custom_claim":     "kept",
+	}
+}
+
+func TestVerifierVerify(t *testing.T) {
+	iss := newIssuer(t, "key-1")
+	now := time.Now()
+	verifier := oidc.NewVerifier(oidc.VerifierConfig{Issuer: iss.server.URL, Audience: "sigstore", Clock: clock.NewFake(now)})
+
+	claims, err := verifier.Verify(context.Background(), iss.sign(t, "key-1", iss.claims(now)))
+	require.NoError(t, err)
+	assert.Equal(t, "octo-org/app", claims.Repository)
+	assert.Equal(t, "octo-org/workflows/.github/workflows/release.yml@refs/heads/main", claims.JobWorkflowRef)
+	assert.Equal(t, oidc.Audience{"sigstore"}, claims.Audience)
+	assert.Equal(t, "kept", claims.Raw["custom_claim"])
+
+	// A token whose payload was changed after signing
+	token := iss.sign(t, "key-1", iss.claims(now))
+	forged := iss.claims(now)
+	forged["repository"] = "attacker/app"
+	payload, _ := json.Marshal(forged)
+	parsed, err := oidc.Parse(token)
+	require.NoError(t, err)
+	header, _ := json.Marshal(parsed.Header)
+	tampered := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(parsed.Signature)
+	_, err = verifier.Verify(context.Background(), tampered)
+	assert.Equal(t, attestation.CodeInvalidTokenSignature, attestation.CodeOf(err))
+
+	// Unsigned tokens are never accepted
+	none, _ := json.Marshal(map[string]string{"alg": "none", "kid": "key-1"})
+	_, err = verifier.Verify(context.Background(), base64.RawURLEncoding.EncodeToString(none)+"."+base64.RawURLEncoding.EncodeToString(payload)+".")
+	assert.Equal(t, attestation.CodeInvalidTokenSignature, attestation.CodeOf(err))
+
+	// Claims are checked once the signature is
+	expired := iss.claims(now.Add(-time.Hour))
+	_, err = verifier.Verify(context.Background(), iss.sign(t, "key-1", expired))
+	assert.Equal(t, attestation.CodeTokenExpired, attestation.CodeOf(err))
+
+	wrongAudience := iss.claims(now)
+	wrongAudience["aud"] = []string{"other", "another"}
+	_, err = verifier.Verify(context.Background(), iss.sign(t, "key-1", wrongAudience))
+	assert.Equal(t, attestation.CodeInvalidAudience, attestation.CodeOf(err))
+
+	_, err = verifier.Verify(context.Background(), "not-a-jwt")
+	assert.Equal(t, attestation.CodeOIDCTokenRequestFailed, attestation.CodeOf(err))
+}
+
+func TestVerifierMatchesECAlgorithmsToCurves(t *testing.T) {
+	iss := newIssuer(t)
+	iss.addECKey(t, "p256", elliptic.P256())
+	iss.addECKey(t, "p384", elliptic.P384())
+	iss.addECKey(t, "p521", elliptic.P521())
+	now := time.Now()
+	verifier := oidc.NewVerifier(oidc.VerifierConfig{Issuer: iss.server.URL, Audience: "sigstore", Clock: clock.NewFake(now)})
+
+	for _, tt := range []struct {
+		kid, algorithm string
+		valid          bool
+	}{
+		{"p256", "ES256", true},
+		{"p384", "ES384", true},
+		{"p521", "ES512", true},
+		// RFC 7518 §3.4: each algorithm signs on one curve only
+		{"p384", "ES256", false},
+		{"p256", "ES384", false},
+		{"p521", "ES384", false},
+	} {
+		t.Run(tt.algorithm+"_"+tt.kid, func(t *testing.T) {
+			_, err := verifier.Verify(context.Background(), iss.signEC(t, tt.kid, tt.algorithm, iss.claims(now)))
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, attestation.CodeInvalidTokenSignature, attestation.CodeOf(err))
+			}
+		})
+	}
+}
+
+func TestVerifierRejectsAlgorithmsTheKeyIsNotPublishedFor(t *testing.T) {
+	iss := newIssuer(t, "key-1")
+	now := time.Now()
+	verifier := oidc.NewVerifier(oidc.VerifierConfig{Issuer: iss.server.URL, Audience: "sigstore", Clock: clock.NewFake(now)})
+
+	// key-1 is published for RS256; a valid PSS signature by it must not pass
+	header, _ := json.Marshal(map[string]string{"alg": "PS256", "typ": "JWT", "kid": "key-1"})
+	payload, _ := json.Marshal(iss.claims(now))
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPSS(rand.Reader, iss.keys["key-1"], crypto.SHA256, digest[:], nil)
+	require.NoError(t, err)
+
+	_, err = verifier.Verify(context.Background(), signed+"."+base64.RawURLEncoding.EncodeToString(signature))
+	assert.Equal(t, attestation.CodeInvalidTokenSignature, attestation.CodeOf(err))
+
+	_, err = verifier.Verify(context.Background(), iss.sign(t, "key-1", iss.claims(now)))
+	assert.NoError(t, err)
+}
+
+func TestVerifierCachesKeysAndRefreshesOnRotation(t *testing.T) {
+	iss := newIssuer(t, "key-1")
+	now := time.Now()
+	fake := clock.NewFake(now)
+	config := oidc.VerifierConfig{Issuer: iss.server.URL, Audience: "sigstore", MinRefreshInterval: time.Minute, Clock: fake}
+	verifier := oidc.NewVerifier(config)
+
+	for i := 0; i < 3; i++ {
+		_, err := verifier.Verify(context.Background(), iss.sign(t, "key-1", iss.claims(now)))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), iss.fetches.Load())
+
+	// Key sets are kept in process, so another verifier fetches its own
+	_, err := oidc.NewVerifier(config).Verify(context.Background(), iss.sign(t, "key-1", iss.claims(now)))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), iss.fetches.Load())
+
+	// The issuer rotates to a key the cached set lacks
+	iss.addKey(t, "key-2")
+	_, err = verifier.Verify(context.Background(), iss.sign(t, "key-2", iss.claims(now)))
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), iss.fetches.Load())
+
+	// Unknown key IDs refetch at most once per interval
+	_, err = verifier.Verify(context.Background(), forgeKid(t, iss, "key-3", now))
+	assert.Equal(t, attestation.CodeInvalidTokenSignature, attestation.CodeOf(err))
+	_, err = verifier.Verify(context.Background(), forgeKid(t, iss, "key-4", now))
+	assert.Equal(t, attestation.CodeInvalidTokenSignature, attestation.CodeOf(err))
+	assert.Equal(t, int32(3), iss.fetches.Load(), "the rotation refetch was less than a minute ago")
+
+	fake.Advance(2 * time.Minute)
+	_, err = verifier.Verify(context.Background(), forgeKid(t, iss, "key-5", fake.Now()))
+	assert.Equal(t, attestation.CodeInvalidTokenSignature, attestation.CodeOf(err))
+	assert.Equal(t, int32(4), iss.fetches.Load())
+}
+
+// forgeKid signs a token with key-1 but names a key the issuer doesn't publish
+func forgeKid(t *testing.T, iss *issuer, kid string, now time.Time) string {
+	token := iss.sign(t, "key-1", iss.claims(now))
+	parsed, err := oidc.Parse(token)
+	require.NoError(t, err)
+	header, _ := json.Marshal(oidc.Header{Algorithm: "RS256", KeyID: kid})
+	payload, _ := json.Marshal(iss.claims(now))
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(parsed.Signature)
+}
+
+func TestVerifierConfigFromEnv(t *testing.T) {
+	t.Setenv(oidc.IssuerEnv, "")
+	t.Setenv(oidc.JWKSURLEnv, "")
+	t.Setenv("GITHUB_SERVER_URL", "https://github.com")
+	assert.Equal(t, oidc.GitHubIssuer, oidc.VerifierConfigFromEnv("sigstore").Issuer)
+
+	// Workflows on GitHub Enterprise Server get tokens from the server itself
+	t.Setenv("GITHUB_SERVER_URL", "https://ghes.example.com/")
+	config := oidc.VerifierConfigFromEnv("sigstore")
+	assert.Equal(t, "https://ghes.example.com/_services/token", config.Issuer)
+	assert.Equal(t, "sigstore", config.Audience)
+	assert.Empty(t, config.JWKSURL)
+
+	t.Setenv(oidc.IssuerEnv, "https://ghes.example.com/custom")
+	t.Setenv(oidc.JWKSURLEnv, "https://keys.example.com/jwks")
+	config = oidc.VerifierConfigFromEnv("sigstore")
+	assert.Equal(t, "https://ghes.example.com/custom", config.Issuer)
+	assert.Equal(t, "https://keys.example.com/jwks", config.JWKSURL)
+}
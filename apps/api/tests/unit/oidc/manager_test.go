@@ -0,0 +1,261 @@
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/cache"
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/pkg/oidc"
+)
+
+// tokenSource issues unsigned tokens valid for lifetime from the clock's now
+type tokenSource struct {
+	clock    *clock.Fake
+	lifetime time.Duration
+	requests []string
+	err      error
+}
+
+func (s *tokenSource) Token(ctx context.Context, audience string) (string, error) {
+	s.requests = append(s.requests, audience)
+	if s.err != nil {
+		return "", s.err
+	}
+	now := s.clock.Now()
+	header, _ := json.Marshal(oidc.Header{Algorithm: "RS256"})
+	payload, _ := json.Marshal(map[string]interface{}{
+		"aud": audience, "sub": "repo:octo-org/app:ref:refs/heads/main",
+		"iat": now.Unix(), "exp": now.Add(s.lifetime).Unix(), "jti": fmt.Sprint(len(s.requests)),
+	})
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln", nil
+}
+
+func TestTokenManagerRefreshesBeforeExpiry(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	source := &tokenSource{clock: fake, lifetime: 15 * time.Minute}
+	manager := oidc.NewTokenManager(source, oidc.ManagerConfig{Clock: fake})
+
+	first, err := manager.GetValidToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sigstore"}, source.requests)
+	assert.Equal(t, fake.Now().Add(15*time.Minute), manager.Expiry("sigstore"))
+
+	// 11 minutes left: still valid
+	fake.Advance(4 * time.Minute)
+	token, err := manager.GetValidToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, first, token)
+	assert.Len(t, source.requests, 1)
+
+	// 9 minutes left: refreshed
+	fake.Advance(2 * time.Minute)
+	token, err = manager.GetValidToken(context.Background())
+	require.NoError(t, err)
+	assert.NotEqual(t, first, token)
+	assert.Len(t, source.requests, 2)
+
+	manager.Invalidate()
+	_, err = manager.GetValidToken(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, source.requests, 3)
+}
+
+func TestTokenManagerFailedRefresh(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	source := &tokenSource{clock: fake, lifetime: 15 * time.Minute}
+	manager := oidc.NewTokenManager(source, oidc.ManagerConfig{Audience: "registry", RefreshThreshold: 5 * time.Minute, Clock: fake})
+
+	first, err := manager.GetValidToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"registry"}, source.requests)
+
+	// The token service is down, but the cached token hasn't expired
+	source.err = attestation.Errorf(attestation.CodeNetworkTimeout, "token service unreachable")
+	fake.Advance(12 * time.Minute)
+	token, err := manager.GetValidToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, first, token)
+
+	fake.Advance(5 * time.Minute)
+	_, err = manager.GetValidToken(context.Background())
+	assert.Equal(t, attestation.CodeNetworkTimeout, attestation.CodeOf(err))
+
+	// Tokens without an expiry can't be managed
+	manager = oidc.NewTokenManager(&fixedSource{"e30.e30.c2ln"}, oidc.ManagerConfig{Clock: fake})
+	_, err = manager.GetValidToken(context.Background())
+	assert.Equal(t, attestation.CodeTokenExpired, attestation.CodeOf(err))
+
+	manager = oidc.NewTokenManager(&fixedSource{"not-a-jwt"}, oidc.ManagerConfig{Clock: fake})
+	_, err = manager.GetValidToken(context.Background())
+	assert.Equal(t, attestation.CodeOIDCTokenRequestFailed, attestation.CodeOf(err))
+}
+
+type fixedSource struct{ token string }
+
+func (s *fixedSource) Token(ctx context.Context, audience string) (string, error) {
+	return s.token, nil
+}
+
+func TestTokenManagerAudiences(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	source := &tokenSource{clock: fake, lifetime: 15 * time.Minute}
+	manager := oidc.NewTokenManager(source, oidc.ManagerConfig{
+		Clock: fake,
+		Audiences: map[string]oidc.AudienceRule{
+			"sts.amazonaws.com": {RefreshThreshold: 2 * time.Minute},
+		},
+	})
+
+	sigstore, err := manager.GetValidToken(context.Background())
+	require.NoError(t, err)
+	aws, err := manager.Token(context.Background(), "sts.amazonaws.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, sigstore, aws)
+	assert.Equal(t, []string{"sigstore", "sts.amazonaws.com"}, manager.Audiences())
+
+	// Each audience is refreshed on its own schedule
+	fake.Advance(6 * time.Minute)
+	_, err = manager.Token(context.Background(), "sigstore")
+	require.NoError(t, err)
+	token, err := manager.Token(context.Background(), "sts.amazonaws.com")
+	require.NoError(t, err)
+	assert.Equal(t, aws, token)
+	assert.Equal(t, []string{"sigstore", "sts.amazonaws.com", "sigstore"}, source.requests)
+	assert.True(t, manager.Expiry("sigstore").After(manager.Expiry("sts.amazonaws.com")))
+
+	manager.Invalidate("sts.amazonaws.com")
+	assert.Equal(t, []string{"sigstore"}, manager.Audiences())
+
+	// The manager is a token source for issuer profiles
+	profile, err := attestation.LookupIssuerProfile(attestation.IssuerProfileGitHub)
+	require.NoError(t, err)
+	token, err = profile.RequestToken(context.Background(), manager)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+}
+
+func TestTokenManagerAudienceRules(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	manager := oidc.NewTokenManager(&tokenSource{clock: fake, lifetime: 15 * time.Minute}, oidc.ManagerConfig{
+		Clock: fake,
+		Audiences: map[string]oidc.AudienceRule{
+			"registry.example.com": {Issuer: oidc.GitHubIssuer},
+		},
+	})
+	// The test source doesn't set iss
+	_, err := manager.Token(context.Background(), "registry.example.com")
+	assert.Equal(t, attestation.CodeInvalidIssuer, attestation.CodeOf(err))
+
+	// Tokens issued for another audience than requested are rejected
+	manager = oidc.NewTokenManager(&fixedSource{unsignedToken(map[string]interface{}{"aud": "other", "exp": fake.Now().Add(time.Hour).Unix()})}, oidc.ManagerConfig{Clock: fake})
+	_, err = manager.Token(context.Background(), "sigstore")
+	assert.Equal(t, attestation.CodeInvalidAudience, attestation.CodeOf(err))
+}
+
+func unsignedToken(claims map[string]interface{}) string {
+	header, _ := json.Marshal(oidc.Header{Algorithm: "RS256"})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+}
+
+func TestTokenManagerSigningConstraints(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	token := func(ref string) string {
+		return unsignedToken(map[string]interface{}{
+			"aud": "sigstore", "exp": fake.Now().Add(time.Hour).Unix(),
+			"repository": "org/api", "ref": ref, "event_name": "push",
+		})
+	}
+	rules := map[string]oidc.AudienceRule{"sigstore": {Constraints: &attestation.SigningConstraints{
+		Repositories: []string{"org/*"},
+		Branches:     []string{"main"},
+	}}}
+
+	manager := oidc.NewTokenManager(&fixedSource{token("refs/heads/main")}, oidc.ManagerConfig{Clock: fake, Audiences: rules})
+	_, err := manager.GetValidToken(context.Background())
+	assert.NoError(t, err)
+
+	manager = oidc.NewTokenManager(&fixedSource{token("refs/heads/experiment")}, oidc.ManagerConfig{Clock: fake, Audiences: rules})
+	_, err = manager.GetValidToken(context.Background())
+	assert.Equal(t, attestation.CodeRefNotAllowed, attestation.CodeOf(err))
+}
+
+func TestTokenManagerWorkflowAllowlist(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	token := func(workflowRef string) string {
+		return unsignedToken(map[string]interface{}{
+			"aud": "sigstore", "exp": fake.Now().Add(time.Hour).Unix(),
+			"repository": "org/api", "job_workflow_ref": workflowRef,
+		})
+	}
+	allowlist := filepath.Join(t.TempDir(), "allowlist.json")
+	require.NoError(t, os.WriteFile(allowlist, []byte(`{"workflows": [
+		{"workflow": "org/signing/.github/workflows/sign.yml", "refs": ["refs/tags/v1.0.0"]}
+	]}`), 0o644))
+	t.Setenv(oidc.SigningConstraintsEnv, "")
+	t.Setenv(oidc.WorkflowAllowlistEnv, allowlist)
+
+	config, err := oidc.ManagerConfigFromEnv()
+	require.NoError(t, err)
+	config.Clock = fake
+
+	manager := oidc.NewTokenManager(&fixedSource{token("org/signing/.github/workflows/sign.yml@refs/tags/v1.0.0")}, config)
+	_, err = manager.GetValidToken(context.Background())
+	assert.NoError(t, err)
+
+	// An ad-hoc workflow in the caller's own repository
+	manager = oidc.NewTokenManager(&fixedSource{token("org/api/.github/workflows/adhoc.yml@refs/heads/main")}, config)
+	_, err = manager.GetValidToken(context.Background())
+	assert.Equal(t, attestation.CodeWorkflowNotApproved, attestation.CodeOf(err))
+
+	manager = oidc.NewTokenManager(&fixedSource{token("org/signing/.github/workflows/sign.yml@refs/heads/main")}, config)
+	_, err = manager.GetValidToken(context.Background())
+	assert.Equal(t, attestation.CodeWorkflowRefNotPinned, attestation.CodeOf(err))
+
+	t.Setenv(oidc.WorkflowAllowlistEnv, filepath.Join(t.TempDir(), "missing.json"))
+	_, err = oidc.ManagerConfigFromEnv()
+	assert.Error(t, err)
+}
+
+// downServices reports the listed services down for every capability
+type downServices []string
+
+func (d *downServices) Unavailable(capability cache.Capability) []string {
+	return *d
+}
+
+func TestTokenManagerSigningUnavailable(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	source := &tokenSource{clock: fake, lifetime: 15 * time.Minute}
+	down := &downServices{}
+	manager := oidc.NewTokenManager(source, oidc.ManagerConfig{Clock: fake, Availability: down})
+
+	_, err := manager.GetValidToken(context.Background())
+	require.NoError(t, err)
+
+	// Signing fails fast, even with a cached token, without asking the issuer
+	*down = downServices{"oidc"}
+	_, err = manager.GetValidToken(context.Background())
+	assert.Equal(t, attestation.CodeSigningUnavailable, attestation.CodeOf(err))
+	assert.ErrorContains(t, err, "Signing unavailable in offline mode: oidc unreachable")
+	assert.Len(t, source.requests, 1)
+
+	// Other audiences don't sign, so they're left to the token source
+	_, err = manager.Token(context.Background(), "sts.amazonaws.com")
+	require.NoError(t, err)
+
+	*down = nil
+	_, err = manager.GetValidToken(context.Background())
+	require.NoError(t, err)
+}
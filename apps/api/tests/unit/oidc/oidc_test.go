@@ -0,0 +1,98 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/secret"
+	"github.com/salman-frs/keystone/apps/api/pkg/oidc"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Setenv(oidc.RequestTokenEnv, "")
+	t.Setenv(oidc.RequestURLEnv, "https://pipelines.actions.githubusercontent.com/token?api-version=2.0")
+	_, err := oidc.FromEnvironment()
+	assert.Equal(t, attestation.CodeOIDCTokenUnavailable, attestation.CodeOf(err))
+
+	t.Setenv(oidc.RequestTokenEnv, "request-token")
+	t.Setenv(oidc.RequestURLEnv, "")
+	_, err = oidc.FromEnvironment()
+	assert.Equal(t, attestation.CodeOIDCURLUnavailable, attestation.CodeOf(err))
+
+	t.Setenv(oidc.RequestURLEnv, "https://pipelines.actions.githubusercontent.com/token?api-version=2.0")
+	_, err = oidc.FromEnvironment()
+	assert.NoError(t, err)
+
+	// Plain HTTP is only accepted for local test servers
+	err = oidc.Config{RequestURL: "http://pipelines.example.com/token", RequestToken: secret.New("t")}.Validate()
+	assert.Equal(t, attestation.CodeOIDCURLUnavailable, attestation.CodeOf(err))
+	assert.NoError(t, oidc.Config{RequestURL: "http://127.0.0.1:8080/token", RequestToken: secret.New("t")}.Validate())
+}
+
+func TestClientToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "bearer request-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "2.0", r.URL.Query().Get("api-version"), "keeps the service's own query")
+		json.NewEncoder(w).Encode(oidc.TokenResponse{Value: "jwt-for-" + r.URL.Query().Get("audience"), Count: 1})
+	}))
+	defer server.Close()
+
+	client, err := oidc.NewClient(oidc.Config{RequestURL: server.URL + "/token?api-version=2.0", RequestToken: secret.New("request-token")})
+	require.NoError(t, err)
+	token, err := client.Token(context.Background(), "sigstore")
+	require.NoError(t, err)
+	assert.Equal(t, "jwt-for-sigstore", token)
+
+	// The signing subsystem requests GitHub tokens for the profile's audience
+	profile, err := attestation.LookupIssuerProfile(attestation.IssuerProfileGitHub)
+	require.NoError(t, err)
+	token, err = profile.RequestToken(context.Background(), client)
+	require.NoError(t, err)
+	assert.Equal(t, "jwt-for-sigstore", token)
+
+	_, err = profile.RequestToken(context.Background(), nil)
+	assert.Equal(t, attestation.CodeOIDCTokenUnavailable, attestation.CodeOf(err))
+}
+
+func TestClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("audience") {
+		case "forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+		case "empty":
+			w.Write([]byte(`{"count":0}`))
+		default:
+			w.Write([]byte(`not json`))
+		}
+	}))
+
+	client, err := oidc.NewClient(oidc.Config{RequestURL: server.URL, RequestToken: secret.New("request-token")})
+	require.NoError(t, err)
+
+	_, err = client.Token(context.Background(), "forbidden")
+	assert.Equal(t, attestation.CodeOIDCTokenRequestFailed, attestation.CodeOf(err))
+	assert.Contains(t, err.Error(), "Resource not accessible by integration")
+
+	_, err = client.Token(context.Background(), "empty")
+	assert.Equal(t, attestation.CodeOIDCTokenRequestFailed, attestation.CodeOf(err))
+
+	_, err = client.Token(context.Background(), "sigstore")
+	assert.Equal(t, attestation.CodeOIDCTokenRequestFailed, attestation.CodeOf(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Token(ctx, "sigstore")
+	assert.Equal(t, attestation.CodeOIDCTokenRequestFailed, attestation.CodeOf(err))
+
+	server.Close()
+	_, err = client.Token(context.Background(), "sigstore")
+	assert.Equal(t, attestation.CodeNetworkTimeout, attestation.CodeOf(err))
+}
@@ -0,0 +1,173 @@
+package pkgverify
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/pkgverify"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+// memoryCache is a minimal ResultCache for tests
+type memoryCache struct {
+	mutex sync.Mutex
+	items map[string]interface{}
+}
+
+func (m *memoryCache) Get(ctx context.Context, key string) (interface{}, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	value, found := m.items[key]
+	return value, found
+}
+
+func (m *memoryCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.items[key] = value
+	return nil
+}
+
+func newSigner(t *testing.T) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity("Release Bot", "", "release@example.com", nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	return entity, buf.String()
+}
+
+func sign(t *testing.T, entity *openpgp.Entity, data []byte) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, openpgp.ArmoredDetachSign(&buf, entity, bytes.NewReader(data), nil))
+	return buf.Bytes()
+}
+
+func TestMavenCoordinatesFromPURL(t *testing.T) {
+	purl, err := sbom.ParsePackageURL("pkg:maven/org.apache.commons/commons-lang3@3.12.0?classifier=sources&type=jar")
+	require.NoError(t, err)
+
+	coords, err := pkgverify.MavenCoordinatesFromPURL(purl)
+	require.NoError(t, err)
+	assert.Equal(t, "org/apache/commons/commons-lang3/3.12.0/commons-lang3-3.12.0-sources.jar", coords.Path())
+
+	npm, err := sbom.ParsePackageURL("pkg:npm/left-pad@1.3.0")
+	require.NoError(t, err)
+	_, err = pkgverify.MavenCoordinatesFromPURL(npm)
+	assert.Error(t, err)
+}
+
+func TestMavenVerifier(t *testing.T) {
+	trusted, trustedKey := newSigner(t)
+	stranger, _ := newSigner(t)
+
+	artifact := []byte("PK\x03\x04 fake jar contents")
+	files := map[string][]byte{
+		"/org/example/good/1.0/good-1.0.jar":             artifact,
+		"/org/example/good/1.0/good-1.0.jar.asc":         sign(t, trusted, artifact),
+		"/org/example/unsigned/1.0/unsigned-1.0.jar":     artifact,
+		"/org/example/tampered/1.0/tampered-1.0.jar":     []byte("different contents"),
+		"/org/example/tampered/1.0/tampered-1.0.jar.asc": sign(t, trusted, artifact),
+		"/org/example/stranger/1.0/stranger-1.0.jar":     artifact,
+		"/org/example/stranger/1.0/stranger-1.0.jar.asc": sign(t, stranger, artifact),
+		"/com/other/lib/1.0/lib-1.0.jar":                 artifact,
+		"/com/other/lib/1.0/lib-1.0.jar.asc":             sign(t, trusted, artifact),
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		data, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	keyring := pkgverify.NewKeyring()
+	require.NoError(t, keyring.AddArmoredKeys(strings.NewReader(trustedKey)))
+	keyring.Trust("org.example", hex.EncodeToString(trusted.PrimaryKey.Fingerprint))
+
+	config := pkgverify.DefaultMavenConfig()
+	config.RepositoryURL = server.URL
+	cache := &memoryCache{items: make(map[string]interface{})}
+	verifier := pkgverify.NewMavenVerifier(config, keyring, cache)
+
+	tests := []struct {
+		name   string
+		purl   string
+		status pkgverify.Status
+	}{
+		{"trusted_signature", "pkg:maven/org.example/good@1.0", pkgverify.StatusVerified},
+		{"missing_signature", "pkg:maven/org.example/unsigned@1.0", pkgverify.StatusMissingSignature},
+		{"tampered_artifact", "pkg:maven/org.example/tampered@1.0", pkgverify.StatusInvalidSignature},
+		{"unknown_key", "pkg:maven/org.example/stranger@1.0", pkgverify.StatusUnknownKey},
+		{"key_not_pinned_for_group", "pkg:maven/com.other/lib@1.0", pkgverify.StatusUntrustedKey},
+		{"artifact_not_found", "pkg:maven/org.example/absent@1.0", pkgverify.StatusMissingSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := verifier.VerifyComponent(context.Background(), sbom.Component{PURL: tt.purl})
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, result.Status, result.Error)
+		})
+	}
+
+	t.Run("results_are_cached", func(t *testing.T) {
+		before := requests
+		result, err := verifier.VerifyComponent(context.Background(), sbom.Component{PURL: "pkg:maven/org.example/good@1.0"})
+		require.NoError(t, err)
+		assert.True(t, result.Verified())
+		assert.Equal(t, before, requests)
+	})
+
+	t.Run("cached_results_recheck_the_digest", func(t *testing.T) {
+		component := sbom.Component{
+			PURL:   "pkg:maven/org.example/good@1.0",
+			Hashes: map[string]string{"sha256": strings.Repeat("0", 64)},
+		}
+		result, err := verifier.VerifyComponent(context.Background(), component)
+		require.NoError(t, err)
+		assert.Equal(t, pkgverify.StatusDigestMismatch, result.Status)
+	})
+
+	t.Run("cached_results_follow_the_keyring", func(t *testing.T) {
+		keyring.Trust("com.other", hex.EncodeToString(trusted.PrimaryKey.Fingerprint))
+		result, err := verifier.VerifyComponent(context.Background(), sbom.Component{PURL: "pkg:maven/com.other/lib@1.0"})
+		require.NoError(t, err)
+		assert.Equal(t, pkgverify.StatusVerified, result.Status, result.Error)
+	})
+
+	t.Run("document_findings", func(t *testing.T) {
+		doc := &sbom.Document{Components: []sbom.Component{
+			{Name: "good", PURL: "pkg:maven/org.example/good@1.0"},
+			{Name: "tampered", PURL: "pkg:maven/org.example/tampered@1.0"},
+			{Name: "left-pad", PURL: "pkg:npm/left-pad@1.3.0"},
+		}}
+
+		results, found := verifier.VerifyDocument(context.Background(), doc)
+		assert.Len(t, results, 2)
+		require.Len(t, found, 1)
+		assert.Equal(t, "HIGH", string(found[0].Severity))
+		assert.Equal(t, "pkg:maven/org.example/tampered@1.0", found[0].PURL)
+	})
+}
@@ -0,0 +1,370 @@
+package pkgverify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/pkgverify"
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+const githubIssuer = "https://token.actions.githubusercontent.com"
+
+// sigstoreFixture issues Fulcio-style certificates and Rekor entries from test keys
+type sigstoreFixture struct {
+	root     *x509.Certificate
+	rootKey  *ecdsa.PrivateKey
+	rekorKey *ecdsa.PrivateKey
+	trust    attestation.TrustRoot
+}
+
+func (f *sigstoreFixture) TrustRoot(context.Context) (attestation.TrustRoot, error) {
+	return f.trust, nil
+}
+
+func newSigstoreFixture(t *testing.T) *sigstoreFixture {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-fulcio-root"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &rootKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	root, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rekorDER, err := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+	require.NoError(t, err)
+
+	return &sigstoreFixture{
+		root:     root,
+		rootKey:  rootKey,
+		rekorKey: rekorKey,
+		trust: attestation.TrustRoot{
+			FulcioCertificates: []string{string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))},
+			RekorPublicKeys:    []string{string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rekorDER}))},
+		},
+	}
+}
+
+// sign signs a statement as a workflow of the repository and logs it, returning
+// the base64 certificate, the envelope and its transparency log entry
+func (f *sigstoreFixture) sign(t *testing.T, repository string, subjects ...attestation.Subject) (string, *attestation.Envelope, attestation.SigstoreTlogEntry) {
+	issuedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	san, err := url.Parse("https://github.com/" + repository + "/.github/workflows/release.yml@refs/heads/main")
+	require.NoError(t, err)
+	extension := func(n int, value string) pkix.Extension {
+		encoded, err := asn1.MarshalWithParams(value, "utf8")
+		require.NoError(t, err)
+		return pkix.Extension{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, n}, Value: encoded}
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    issuedAt,
+		NotAfter:     issuedAt.Add(10 * time.Minute),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:         []*url.URL{san},
+		ExtraExtensions: []pkix.Extension{
+			extension(8, githubIssuer),
+			extension(12, "https://github.com/"+repository),
+			extension(14, "refs/heads/main"),
+		},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, template, f.root, &leafKey.PublicKey, f.rootKey)
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(attestation.Statement{
+		Type:          "https://in-toto.io/Statement/v1",
+		Subject:       subjects,
+		PredicateType: attestation.PredicateSLSAProvenanceV1,
+		Predicate:     map[string]interface{}{"buildDefinition": map[string]interface{}{"buildType": "https://example.com/build"}},
+	})
+	require.NoError(t, err)
+	digest := sha256.Sum256(attestation.PAE(attestation.PayloadTypeInToto, payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, leafKey, digest[:])
+	require.NoError(t, err)
+	envelope := &attestation.Envelope{
+		PayloadType: attestation.PayloadTypeInToto,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []attestation.EnvelopeSignature{{Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}
+
+	rekorDER, err := x509.MarshalPKIXPublicKey(&f.rekorKey.PublicKey)
+	require.NoError(t, err)
+	logID := sha256.Sum256(rekorDER)
+	payloadHash := sha256.Sum256(payload)
+	verifier := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+	body := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(
+		`{"apiVersion":"0.0.1","kind":"dsse","spec":{"payloadHash":{"algorithm":"sha256","value":"%s"},`+
+			`"signatures":[{"signature":"%s","verifier":"%s"}]}}`,
+		hex.EncodeToString(payloadHash[:]), base64.StdEncoding.EncodeToString(sig), base64.StdEncoding.EncodeToString(verifier))))
+	integratedTime := issuedAt.Add(time.Minute).Unix()
+	canonical, err := json.Marshal(map[string]interface{}{
+		"body":           body,
+		"integratedTime": integratedTime,
+		"logID":          hex.EncodeToString(logID[:]),
+		"logIndex":       7,
+	})
+	require.NoError(t, err)
+	setDigest := sha256.Sum256(canonical)
+	set, err := ecdsa.SignASN1(rand.Reader, f.rekorKey, setDigest[:])
+	require.NoError(t, err)
+
+	entry := attestation.SigstoreTlogEntry{
+		LogIndex:          "7",
+		LogID:             attestation.SigstoreLogID{KeyID: base64.StdEncoding.EncodeToString(logID[:])},
+		IntegratedTime:    strconv.FormatInt(integratedTime, 10),
+		InclusionPromise:  &attestation.SigstorePromise{SignedEntryTimestamp: base64.StdEncoding.EncodeToString(set)},
+		CanonicalizedBody: body,
+	}
+	return base64.StdEncoding.EncodeToString(leafDER), envelope, entry
+}
+
+// npmBundle returns the Sigstore bundle JSON npm publishes for provenance
+func (f *sigstoreFixture) npmBundle(t *testing.T, repository string, subjects ...attestation.Subject) json.RawMessage {
+	certificate, envelope, entry := f.sign(t, repository, subjects...)
+	data, err := json.Marshal(attestation.SigstoreBundle{
+		MediaType: attestation.SigstoreBundleMediaType + "+json;version=0.2",
+		VerificationMaterial: attestation.SigstoreVerificationMaterial{
+			X509CertificateChain: &attestation.SigstoreCertificateChain{
+				Certificates: []attestation.SigstoreCertificate{{RawBytes: certificate}},
+			},
+			TlogEntries: []attestation.SigstoreTlogEntry{entry},
+		},
+		DSSEEnvelope: envelope,
+	})
+	require.NoError(t, err)
+	return data
+}
+
+type npmPackage struct {
+	tarball   []byte
+	bundle    json.RawMessage // Omitted when nil
+	predicate string
+}
+
+// fakeNpmRegistry serves version documents and attestations for packages keyed by name@version
+func fakeNpmRegistry(t *testing.T, packages map[string]npmPackage) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for key, pkg := range packages {
+			sum := sha512.Sum512(pkg.tarball)
+			integrity := "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+			at := strings.LastIndex(key, "@")
+			escaped := strings.Replace(key[:at], "/", "%2f", 1) + "/" + key[at+1:]
+
+			switch r.URL.EscapedPath() {
+			case "/" + escaped:
+				dist := map[string]interface{}{"integrity": integrity}
+				if pkg.bundle != nil {
+					dist["attestations"] = map[string]interface{}{"url": server.URL + "/attestations/" + escaped}
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"dist": dist})
+				return
+			case "/attestations/" + escaped:
+				json.NewEncoder(w).Encode(map[string]interface{}{"attestations": []interface{}{
+					map[string]interface{}{"predicateType": "https://github.com/npm/attestation/tree/main/specs/publish/v0.1", "bundle": map[string]interface{}{}},
+					map[string]interface{}{"predicateType": pkg.predicate, "bundle": pkg.bundle},
+				}})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func sha512Hex(data []byte) string {
+	sum := sha512.Sum512(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestNpmProvenanceVerifier(t *testing.T) {
+	sigstore := newSigstoreFixture(t)
+	tarball := []byte("package tarball")
+	subject := func(purl string, data []byte) attestation.Subject {
+		return attestation.Subject{Name: purl, Digest: attestation.DigestSet{"sha512": sha512Hex(data)}}
+	}
+
+	server := fakeNpmRegistry(t, map[string]npmPackage{
+		"@acme/widget@1.0.0": {tarball, sigstore.npmBundle(t, "acme/widget", subject("pkg:npm/%40acme/widget@1.0.0", tarball)), attestation.PredicateSLSAProvenanceV1},
+		"@acme/other@1.0.0":  {tarball, sigstore.npmBundle(t, "evil/fork", subject("pkg:npm/%40acme/other@1.0.0", tarball)), attestation.PredicateSLSAProvenanceV1},
+		"left-pad@1.3.0":     {tarball, nil, ""},
+		"swapped@2.0.0":      {tarball, sigstore.npmBundle(t, "acme/swapped", subject("pkg:npm/swapped@2.0.0", []byte("other tarball"))), attestation.PredicateSLSAProvenanceV1},
+		"forged@1.0.0":       {tarball, newSigstoreFixture(t).npmBundle(t, "acme/forged", subject("pkg:npm/forged@1.0.0", tarball)), attestation.PredicateSLSAProvenanceV1},
+	})
+
+	config := pkgverify.DefaultNpmConfig()
+	config.RegistryURL = server.URL
+	config.Policies = pkgverify.PublisherPolicies{
+		"@acme/*": {Issuer: githubIssuer, SANRegexp: `https://github\.com/acme/.*`},
+	}
+	cache := &memoryCache{items: make(map[string]interface{})}
+	verifier := pkgverify.NewNpmVerifier(config, sigstore, cache)
+
+	tests := []struct {
+		name   string
+		purl   string
+		hashes map[string]string
+		status pkgverify.Status
+	}{
+		{"scoped_provenance", "pkg:npm/%40acme/widget@1.0.0", nil, pkgverify.StatusVerified},
+		{"publisher_not_allowed", "pkg:npm/%40acme/other@1.0.0", nil, pkgverify.StatusPolicyViolation},
+		{"published_without_provenance", "pkg:npm/left-pad@1.3.0", nil, pkgverify.StatusMissingAttestation},
+		{"provenance_for_other_tarball", "pkg:npm/swapped@2.0.0", nil, pkgverify.StatusDigestMismatch},
+		{"untrusted_signer", "pkg:npm/forged@1.0.0", nil, pkgverify.StatusInvalidSignature},
+		{"sbom_digest_differs", "pkg:npm/%40acme/widget@1.0.0", map[string]string{"sha512": sha512Hex([]byte("x"))}, pkgverify.StatusDigestMismatch},
+		{"version_not_published", "pkg:npm/absent@1.0.0", nil, pkgverify.StatusMissingAttestation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache.items = make(map[string]interface{})
+			result, err := verifier.VerifyComponent(context.Background(), sbom.Component{PURL: tt.purl, Hashes: tt.hashes})
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, result.Status, result.Error)
+			assert.Equal(t, tt.purl, result.PURL)
+		})
+	}
+
+	result, err := verifier.VerifyComponent(context.Background(), sbom.Component{PURL: "pkg:npm/%40acme/widget@1.0.0"})
+	require.NoError(t, err)
+	assert.Equal(t, "@acme/widget", result.Package)
+	assert.Equal(t, "acme/widget", result.Repository)
+	assert.Equal(t, "acme/widget/.github/workflows/release.yml@refs/heads/main", result.WorkflowRef)
+}
+
+func TestPyPIProvenanceVerifier(t *testing.T) {
+	sigstore := newSigstoreFixture(t)
+	wheel := []byte("wheel contents")
+	sdist := []byte("sdist contents")
+	sha256Hex := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	files := map[string][]byte{
+		"acme_tool-1.0-py3-none-any.whl": wheel,
+		"acme_tool-1.0.tar.gz":           sdist,
+	}
+	provenance := func(filename string, data []byte) map[string]interface{} {
+		certificate, envelope, entry := sigstore.sign(t, "acme/tool",
+			attestation.Subject{Name: filename, Digest: attestation.DigestSet{"sha256": sha256Hex(data)}})
+		return map[string]interface{}{
+			"version": 1,
+			"attestation_bundles": []interface{}{map[string]interface{}{
+				"publisher": map[string]interface{}{"kind": "GitHub", "repository": "acme/tool"},
+				"attestations": []interface{}{map[string]interface{}{
+					"version": 1,
+					"verification_material": map[string]interface{}{
+						"certificate":          certificate,
+						"transparency_entries": []attestation.SigstoreTlogEntry{entry},
+					},
+					"envelope": map[string]interface{}{
+						"statement": envelope.Payload,
+						"signature": envelope.Signatures[0].Sig,
+					},
+				}},
+			}},
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pypi/acme-tool/1.0/json":
+			var urls []interface{}
+			for _, name := range []string{"acme_tool-1.0-py3-none-any.whl", "acme_tool-1.0.tar.gz"} {
+				urls = append(urls, map[string]interface{}{"filename": name, "digests": map[string]string{"sha256": sha256Hex(files[name])}})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"urls": urls})
+		case "/integrity/acme-tool/1.0/acme_tool-1.0-py3-none-any.whl/provenance":
+			assert.Equal(t, "application/vnd.pypi.integrity.v1+json", r.Header.Get("Accept"))
+			json.NewEncoder(w).Encode(provenance("acme_tool-1.0-py3-none-any.whl", wheel))
+		case "/integrity/acme-tool/1.0/acme_tool-1.0.tar.gz/provenance":
+			// The attestation was made for different sdist contents
+			json.NewEncoder(w).Encode(provenance("acme_tool-1.0.tar.gz", []byte("tampered")))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := pkgverify.DefaultPyPIConfig()
+	config.RegistryURL = server.URL
+	config.Policies = pkgverify.PublisherPolicies{"acme-tool": {Issuer: githubIssuer, Repository: "acme/tool"}}
+	verifier := pkgverify.NewPyPIVerifier(config, sigstore, nil)
+
+	tests := []struct {
+		name   string
+		purl   string
+		hashes map[string]string
+		status pkgverify.Status
+		files  []string
+	}{
+		{"wheel_by_file_name", "pkg:pypi/Acme.Tool@1.0?file_name=acme_tool-1.0-py3-none-any.whl", nil, pkgverify.StatusVerified, []string{"acme_tool-1.0-py3-none-any.whl"}},
+		{"wheel_by_digest", "pkg:pypi/acme_tool@1.0", map[string]string{"sha256": sha256Hex(wheel)}, pkgverify.StatusVerified, []string{"acme_tool-1.0-py3-none-any.whl"}},
+		{"every_file_must_be_attested", "pkg:pypi/acme-tool@1.0", nil, pkgverify.StatusDigestMismatch, []string{"acme_tool-1.0-py3-none-any.whl", "acme_tool-1.0.tar.gz"}},
+		{"digest_not_in_release", "pkg:pypi/acme-tool@1.0", map[string]string{"sha256": sha256Hex([]byte("x"))}, pkgverify.StatusDigestMismatch, nil},
+		{"release_not_published", "pkg:pypi/acme-tool@2.0", nil, pkgverify.StatusMissingAttestation, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := verifier.VerifyComponent(context.Background(), sbom.Component{PURL: tt.purl, Hashes: tt.hashes})
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, result.Status, result.Error)
+			assert.Equal(t, "acme-tool", result.Package)
+			assert.Equal(t, tt.files, result.Files)
+		})
+	}
+}
+
+func TestPublisherPoliciesPreferLongestPrefix(t *testing.T) {
+	policies := pkgverify.PublisherPolicies{
+		"*":            {Issuer: githubIssuer},
+		"@acme/*":      {Repository: "acme/monorepo"},
+		"@acme/cli-*":  {Repository: "acme/cli"},
+		"@acme/legacy": {Repository: "acme/legacy"},
+	}
+
+	assert.Equal(t, "acme/cli", policies.For("@acme/cli-tools").Repository)
+	assert.Equal(t, "acme/monorepo", policies.For("@acme/widget").Repository)
+	assert.Equal(t, "acme/legacy", policies.For("@acme/legacy").Repository)
+	assert.Equal(t, githubIssuer, policies.For("left-pad").Issuer)
+	assert.Empty(t, pkgverify.PublisherPolicies{}.For("left-pad").Issuer)
+}
@@ -0,0 +1,227 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/attestation/pipeline"
+	"github.com/salman-frs/keystone/apps/api/internal/circuit"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/plugins"
+	"github.com/salman-frs/keystone/apps/api/pkg/plugin"
+)
+
+// TestMain doubles as the plugin binary: started by the host, the test
+// binary serves the plugin named by PLUGIN_MODE instead of running tests
+func TestMain(m *testing.M) {
+	if os.Getenv(plugin.MagicCookieKey) == "" {
+		os.Exit(m.Run())
+	}
+	if err := plugin.Serve(testPlugin(os.Getenv("PLUGIN_MODE"))); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func testPlugin(mode string) plugin.Plugin {
+	return plugin.Plugin{
+		Name:    "test",
+		Version: "1.0.0",
+		Enrichers: map[string]plugin.Enricher{
+			"owner": plugin.EnricherFunc(func(ctx context.Context, found []plugin.Finding) ([]plugin.Finding, error) {
+				switch mode {
+				case "hang":
+					time.Sleep(time.Minute)
+				case "crash":
+					os.Exit(3)
+				case "fail":
+					return nil, fmt.Errorf("owner lookup failed")
+				}
+				for i := range found {
+					if found[i].Metadata == nil {
+						found[i].Metadata = map[string]string{}
+					}
+					found[i].Metadata["owner"] = "team-" + found[i].Component
+					// The sandbox withholds the worker's environment
+					found[i].Metadata["github_token"] = os.Getenv("GITHUB_TOKEN")
+				}
+				return found, nil
+			}),
+		},
+		Gates: map[string]plugin.Gate{
+			"no-critical": plugin.GateFunc(func(ctx context.Context, req plugin.EvaluateRequest) (plugin.Decision, error) {
+				if mode == "fail" {
+					return plugin.Decision{}, fmt.Errorf("gate unavailable")
+				}
+				for _, finding := range req.Findings {
+					if finding.Severity == "CRITICAL" {
+						return plugin.Decision{Allow: false, Reason: finding.ID + " is critical"}, nil
+					}
+				}
+				return plugin.Decision{Allow: true}, nil
+			}),
+		},
+		Generators: map[string]plugin.PredicateGenerator{
+			"licenses": licenseGenerator{},
+		},
+	}
+}
+
+type licenseGenerator struct{}
+
+func (licenseGenerator) PredicateType() string { return "https://example.com/licenses/v1" }
+
+func (licenseGenerator) Generate(ctx context.Context, req plugin.GenerateRequest) (interface{}, error) {
+	return map[string]interface{}{"subject": req.Subject.Name, "licenses": []string{"Apache-2.0"}}, nil
+}
+
+func startHost(t *testing.T, mode string, configure func(*plugins.PluginConfig)) *plugins.Host {
+	t.Helper()
+	t.Setenv("GITHUB_TOKEN", "secret")
+	config := plugins.PluginConfig{
+		Name:    "test",
+		Command: os.Args[0],
+		Env:     map[string]string{"PLUGIN_MODE": mode},
+		Timeout: 2 * time.Second,
+	}
+	if configure != nil {
+		configure(&config)
+	}
+	breaker := plugins.DefaultBreakerConfig()
+	breaker.FailureThreshold = 2
+	host, err := plugins.New(plugins.Config{Plugins: []plugins.PluginConfig{config}, Breaker: breaker})
+	require.NoError(t, err)
+	require.NoError(t, host.Start())
+	t.Cleanup(host.Close)
+	return host
+}
+
+func testFindings() []findings.Finding {
+	return []findings.Finding{
+		findings.New("npm", findings.CategorySignature, findings.SeverityHigh, "missing_attestation", "left-pad@1.3.0", "left-pad isn't attested"),
+	}
+}
+
+func TestHostEnrichAndEvaluate(t *testing.T) {
+	host := startHost(t, "", nil)
+
+	status := host.Status()
+	require.Len(t, status, 1)
+	assert.True(t, status[0].Running)
+	assert.Equal(t, "1.0.0", status[0].Version)
+	assert.Len(t, status[0].Capabilities, 3)
+
+	found := testFindings()
+	found[0].Component = "left-pad"
+	enriched, failures := host.Enrich(context.Background(), found)
+	assert.Empty(t, failures)
+	require.Len(t, enriched, 1)
+	assert.Equal(t, "team-left-pad", enriched[0].Metadata["owner"])
+	assert.Empty(t, enriched[0].Metadata["github_token"], "plugins don't inherit the worker's environment")
+	assert.Equal(t, found[0].ID, enriched[0].ID)
+
+	results := host.Evaluate(context.Background(), plugins.GateInput{Findings: enriched})
+	require.Len(t, results, 1)
+	assert.True(t, plugins.Allowed(results))
+
+	enriched[0].Severity = findings.SeverityCritical
+	results = host.Evaluate(context.Background(), plugins.GateInput{Findings: enriched})
+	assert.False(t, plugins.Allowed(results))
+	assert.Equal(t, enriched[0].ID+" is critical", results[0].Reason)
+}
+
+func TestHostPredicateSteps(t *testing.T) {
+	host := startHost(t, "", nil)
+
+	steps := host.PredicateSteps()
+	require.Len(t, steps, 1)
+	assert.Equal(t, "test/licenses", steps[0].Name)
+
+	subject := attestation.Subject{Name: "ghcr.io/octo-org/app", Digest: attestation.DigestSet{"sha256": strings.Repeat("a", 64)}}
+	statement, err := steps[0].Generate(context.Background(), pipeline.Input{Subject: subject})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/licenses/v1", statement.PredicateType)
+	predicate, err := json.Marshal(statement.Predicate)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"subject":"ghcr.io/octo-org/app","licenses":["Apache-2.0"]}`, string(predicate))
+}
+
+func TestHostIsolatesFailingPlugins(t *testing.T) {
+	host := startHost(t, "fail", nil)
+
+	found := testFindings()
+	enriched, failures := host.Enrich(context.Background(), found)
+	assert.Equal(t, found[0].ID, enriched[0].ID, "findings pass through a failing enricher")
+	require.Len(t, failures, 1)
+	assert.Contains(t, failures[0].Error, "owner lookup failed")
+
+	// Gates fail open unless configured otherwise
+	results := host.Evaluate(context.Background(), plugins.GateInput{Findings: found})
+	assert.True(t, plugins.Allowed(results))
+	assert.NotEmpty(t, results[0].Error)
+
+	// Two failures opened the circuit, so the plugin isn't called
+	_, failures = host.Enrich(context.Background(), found)
+	assert.Contains(t, failures[0].Error, circuit.ErrCircuitOpen.Error())
+	assert.True(t, host.Status()[0].CircuitOpen)
+
+	closed := startHost(t, "fail", func(config *plugins.PluginConfig) { config.FailClosed = true })
+	assert.False(t, plugins.Allowed(closed.Evaluate(context.Background(), plugins.GateInput{Findings: found})))
+}
+
+func TestHostRestartsPlugins(t *testing.T) {
+	host := startHost(t, "hang", func(config *plugins.PluginConfig) { config.Timeout = 200 * time.Millisecond })
+
+	// A plugin that doesn't answer in time is killed
+	_, failures := host.Enrich(context.Background(), testFindings())
+	require.Len(t, failures, 1)
+	assert.Contains(t, failures[0].Error, "timed out")
+
+	// and started again for the next call
+	results := host.Evaluate(context.Background(), plugins.GateInput{Findings: testFindings()})
+	assert.Empty(t, results[0].Error)
+	assert.Equal(t, 1, host.Status()[0].Restarts)
+
+	crashing := startHost(t, "crash", nil)
+	_, failures = crashing.Enrich(context.Background(), testFindings())
+	require.Len(t, failures, 1)
+	results = crashing.Evaluate(context.Background(), plugins.GateInput{Findings: testFindings()})
+	assert.Empty(t, results[0].Error)
+	assert.True(t, crashing.Status()[0].Running)
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := t.TempDir() + "/plugins.yaml"
+	require.NoError(t, os.WriteFile(path, []byte(`plugins:
+  - name: licenses
+    command: /usr/local/bin/keystone-licenses
+    args: [--strict]
+    env:
+      LICENSE_DB: /var/lib/licenses.db
+    timeout: 10s
+    fail_closed: true
+`), 0o644))
+	config, err := plugins.LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, config.Plugins, 1)
+	assert.Equal(t, 10*time.Second, config.Plugins[0].Timeout)
+	assert.True(t, config.Plugins[0].FailClosed)
+
+	require.NoError(t, os.WriteFile(path, []byte("plugins:\n  - name: a\n    command: x\n  - name: a\n    command: y\n"), 0o644))
+	_, err = plugins.LoadConfig(path)
+	assert.ErrorContains(t, err, "duplicate plugin")
+
+	host, err := plugins.New(plugins.Config{Plugins: []plugins.PluginConfig{{Name: "missing", Command: "/nonexistent/plugin"}}})
+	require.NoError(t, err, "commands are only run by Start")
+	assert.ErrorContains(t, host.Start(), "failed to start plugin missing")
+}
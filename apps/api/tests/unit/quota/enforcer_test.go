@@ -0,0 +1,161 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+	"github.com/salman-frs/keystone/apps/api/internal/jobs"
+	"github.com/salman-frs/keystone/apps/api/internal/metering"
+	"github.com/salman-frs/keystone/apps/api/internal/quota"
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+const migrationsDir = "../../../internal/storage/migrations"
+
+func newEnforcer(t *testing.T, limits quota.Limits) (*quota.Enforcer, *metering.Meter) {
+	db, err := storage.OpenDatabase(filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, storage.NewMigrationManager(db, migrationsDir).MigrateWithLock(context.Background(), "test", time.Minute))
+
+	meter := metering.NewMeter(db)
+	return quota.NewEnforcer(db, meter, limits), meter
+}
+
+func requireExceeded(t *testing.T, err error, name string) *quota.ExceededError {
+	var exceeded *quota.ExceededError
+	require.True(t, errors.As(err, &exceeded), "expected quota error, got %v", err)
+	assert.Equal(t, name, exceeded.Quota)
+	return exceeded
+}
+
+func TestLimitsFromEnv(t *testing.T) {
+	t.Setenv("QUOTA_SCANS_PER_DAY", "10")
+	t.Setenv("QUOTA_API_REQUESTS_PER_MINUTE", "0")
+
+	limits, err := quota.LimitsFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), limits.ScansPerDay)
+	assert.Zero(t, limits.APIRequestsPerMinute)
+	assert.Equal(t, quota.DefaultLimits().TrackedArtifacts, limits.TrackedArtifacts)
+
+	t.Setenv("QUOTA_TRACKED_ARTIFACTS", "-1")
+	_, err = quota.LimitsFromEnv()
+	assert.Error(t, err)
+}
+
+func TestEnforcerOverrides(t *testing.T) {
+	enforcer, _ := newEnforcer(t, quota.DefaultLimits())
+	ctx := context.Background()
+
+	limits, err := enforcer.Limits(ctx, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, quota.DefaultLimits(), limits)
+
+	override := quota.Limits{ScansPerDay: 5000}
+	require.NoError(t, enforcer.SetOverride(ctx, "acme", override))
+	limits, err = enforcer.Limits(ctx, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, override, limits)
+
+	require.NoError(t, enforcer.DeleteOverride(ctx, "acme"))
+	limits, err = enforcer.Limits(ctx, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, quota.DefaultLimits(), limits)
+}
+
+func TestEnforcerAdmitDailyLimit(t *testing.T) {
+	enforcer, meter := newEnforcer(t, quota.Limits{ScansPerDay: 2})
+	acme := metering.WithTenant(context.Background(), "acme")
+	globex := metering.WithTenant(context.Background(), "globex")
+
+	require.NoError(t, enforcer.Admit(acme, metering.MetricScans))
+	require.NoError(t, meter.Record(acme, metering.MetricScans, 2))
+
+	exceeded := requireExceeded(t, enforcer.Admit(acme, metering.MetricScans), quota.QuotaScansPerDay)
+	assert.True(t, exceeded.Temporary())
+	assert.Equal(t, int64(2), exceeded.Used)
+
+	assert.NoError(t, enforcer.Admit(globex, metering.MetricScans), "quotas are per tenant")
+	assert.NoError(t, enforcer.Admit(acme, metering.MetricVerifications), "unlimited quota")
+	assert.NoError(t, enforcer.Admit(quota.WithOverride(acme), metering.MetricScans), "admin override")
+}
+
+func TestEnforcerAllowRequest(t *testing.T) {
+	enforcer, _ := newEnforcer(t, quota.Limits{APIRequestsPerMinute: 2})
+	acme := metering.WithTenant(context.Background(), "acme")
+
+	require.NoError(t, enforcer.AllowRequest(acme))
+	require.NoError(t, enforcer.AllowRequest(acme))
+	exceeded := requireExceeded(t, enforcer.AllowRequest(acme), quota.QuotaAPIRequestsPerMinute)
+	assert.True(t, exceeded.Temporary())
+	assert.LessOrEqual(t, exceeded.RetryAfter, time.Minute)
+
+	assert.NoError(t, enforcer.AllowRequest(metering.WithTenant(context.Background(), "globex")))
+	assert.NoError(t, enforcer.AllowRequest(quota.WithOverride(acme)))
+}
+
+func TestEnforcerTrackArtifact(t *testing.T) {
+	enforcer, _ := newEnforcer(t, quota.Limits{TrackedArtifacts: 2})
+	acme := metering.WithTenant(context.Background(), "acme")
+
+	require.NoError(t, enforcer.TrackArtifact(acme, "ghcr.io/acme/api"))
+	require.NoError(t, enforcer.TrackArtifact(acme, "ghcr.io/acme/web"))
+	require.NoError(t, enforcer.TrackArtifact(acme, "ghcr.io/acme/api"), "already tracked artifacts are not counted again")
+
+	exceeded := requireExceeded(t, enforcer.TrackArtifact(acme, "ghcr.io/acme/worker"), quota.QuotaTrackedArtifacts)
+	assert.False(t, exceeded.Temporary(), "tracked artifacts do not replenish")
+
+	assert.NoError(t, enforcer.TrackArtifact(quota.WithOverride(acme), "ghcr.io/acme/worker"))
+}
+
+func TestWorkerRejectsJobsOverQuota(t *testing.T) {
+	bus := events.NewMemoryBus()
+	defer bus.Close()
+	enforcer, meter := newEnforcer(t, quota.Limits{ScansPerDay: 1})
+
+	results := make(chan jobs.Result, 3)
+	forward := func(ctx context.Context, event events.Event) error {
+		var result jobs.Result
+		require.NoError(t, event.Decode(&result))
+		results <- result
+		return nil
+	}
+	_, err := bus.Subscribe(events.TypeJobCompleted, forward)
+	require.NoError(t, err)
+	_, err = bus.Subscribe(events.TypeJobFailed, forward)
+	require.NoError(t, err)
+
+	worker := jobs.NewWorker(bus, jobs.WorkerConfig{Name: "worker-1", QueueGroup: jobs.DefaultQueueGroup, Concurrency: 1, JobTimeout: time.Minute})
+	worker.SetMeter(meter)
+	worker.SetAdmitter(enforcer)
+	worker.Register(jobs.KindScan, func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		return nil, nil
+	})
+	require.NoError(t, worker.Start())
+	defer worker.Stop(context.Background())
+
+	acme := metering.WithTenant(context.Background(), "acme")
+	submit := func(ctx context.Context) jobs.Result {
+		_, err := jobs.Submit(ctx, bus, "test", jobs.KindScan, nil)
+		require.NoError(t, err)
+		select {
+		case result := <-results:
+			return result
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for job result")
+			return jobs.Result{}
+		}
+	}
+
+	assert.Empty(t, submit(acme).Error)
+	assert.Contains(t, submit(acme).Error, quota.QuotaScansPerDay)
+	assert.Empty(t, submit(quota.WithOverride(acme)).Error, "admin submissions bypass quotas")
+}
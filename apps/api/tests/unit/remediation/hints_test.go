@@ -0,0 +1,82 @@
+package remediation
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/findings"
+	"github.com/salman-frs/keystone/apps/api/internal/remediation"
+)
+
+func TestForErrorCode(t *testing.T) {
+	ctx := remediation.Context{
+		Target:        "ghcr.io/owner/repo@sha256:abc",
+		Identity:      "https://github.com/owner/repo/.github/workflows/release.yml@refs/heads/main",
+		PredicateType: "https://slsa.dev/provenance/v1",
+	}
+
+	tests := []struct {
+		code    string
+		kind    remediation.Kind
+		command string
+	}{
+		{"SIGN_001", remediation.KindConfiguration, "id-token: write"},
+		{"SIGN_012", remediation.KindCommand, "sha256sum ghcr.io/owner/repo@sha256:abc"},
+		{"SIGN_013", remediation.KindCommand, "crane digest ghcr.io/owner/repo@sha256:abc"},
+		{"SIGN_021", remediation.KindCommand, "crane digest ghcr.io/owner/repo@sha256:abc"},
+		{"SIGN_042", remediation.KindCommand, "cosign sign --yes ghcr.io/owner/repo@sha256:abc"},
+		{"SIGN_048", remediation.KindConfiguration, "cosign initialize --mirror"},
+		{"SIGN_051", remediation.KindCommand, `--certificate-identity="https://github.com/owner/repo/.github/workflows/release.yml@refs/heads/main"`},
+		{"SIGN_052", remediation.KindCommand, "cosign attest --yes --type slsaprovenance1 --predicate provenance.json"},
+		{"SIGN_082", remediation.KindConfiguration, "uses: <org>/<repo>/.github/workflows/<workflow>.yml@<pinned-ref>"},
+		{"SIGN_055", remediation.KindConfiguration, `--certificate-oidc-issuer="https://token.actions.githubusercontent.com"`},
+		{"SIGN_058", remediation.KindConfiguration, ""},
+		{"SIGN_059", remediation.KindConfiguration, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			hints := remediation.ForErrorCode(tt.code, ctx)
+			require.NotEmpty(t, hints)
+			assert.Equal(t, tt.kind, hints[0].Kind)
+			assert.Contains(t, hints[0].Command, tt.command)
+		})
+	}
+
+	assert.Empty(t, remediation.ForErrorCode("SIGN_999", ctx))
+}
+
+func TestForFinding(t *testing.T) {
+	finding := findings.New("grype", findings.CategoryVulnerability, findings.SeverityHigh, "CVE-2023-1234", "golang.org/x/net", "HTTP/2 rapid reset")
+	finding.Component = "golang.org/x/net"
+	finding.Version = "v0.7.0"
+	finding.PURL = "pkg:golang/golang.org/x/net@v0.7.0"
+	finding.Metadata["fixed_version"] = "0.17.0"
+
+	hints := remediation.ForFinding(finding)
+	require.Len(t, hints, 1)
+	assert.Equal(t, remediation.KindUpgrade, hints[0].Kind)
+	assert.Equal(t, "go get golang.org/x/net@v0.17.0 && go mod tidy", hints[0].Command)
+	assert.Equal(t, "v0.7.0", hints[0].FromVersion)
+
+	delete(finding.Metadata, "fixed_version")
+	assert.Empty(t, remediation.ForFinding(finding))
+}
+
+func TestVerificationResultFail(t *testing.T) {
+	result := &attestation.VerificationResult{Valid: true}
+	result.Fail(attestation.Errorf(attestation.CodeRekorEntryNotFound, "No transparency log entries found for signature"),
+		remediation.Context{Target: "ghcr.io/owner/repo:latest"})
+
+	assert.False(t, result.Valid)
+	assert.Equal(t, "SIGN_042", result.ErrorCode)
+	require.NotEmpty(t, result.Remediation)
+
+	var out bytes.Buffer
+	remediation.Render(&out, result.Remediation)
+	assert.Contains(t, out.String(), "cosign sign --yes ghcr.io/owner/repo:latest")
+}
@@ -0,0 +1,65 @@
+package sbom
+
+import (
+	"testing"
+
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+func FuzzParsePackageURL(f *testing.F) {
+	for _, seed := range []string{
+		"pkg:maven/org.apache.commons/commons-lang3@3.12.0?type=jar&classifier=sources",
+		"pkg:npm/%40angular/core@16.0.0",
+		"pkg:golang/github.com/gorilla/mux@v1.8.0#pkg/router",
+		"pkg:pypi/requests",
+		"pkg:deb/debian/curl@7.50.3-1?arch=i386&distro=jessie",
+		"pkg:docker/library/alpine@sha256:abc?repository_url=ghcr.io",
+		"pkg:npm/a@1?novalue",
+		"pkg:npm/",
+		"pkg:/%zz",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		purl, err := sbom.ParsePackageURL(raw)
+		if err != nil {
+			return
+		}
+
+		// The canonical form must parse back to the same purl
+		canonical := purl.String()
+		reparsed, err := sbom.ParsePackageURL(canonical)
+		if err != nil {
+			t.Fatalf("canonical form %q of %q does not parse: %v", canonical, raw, err)
+		}
+		if reparsed.String() != canonical {
+			t.Fatalf("canonical form of %q is unstable: %q then %q", raw, canonical, reparsed.String())
+		}
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range []string{
+		`{"bomFormat":"CycloneDX","specVersion":"1.5","metadata":{"component":{"name":"app"}},"components":[{"name":"core","group":"org","version":"1.0","purl":"pkg:maven/org/core@1.0","hashes":[{"alg":"SHA-256","content":"AB"}],"components":[{"name":"nested","purl":"pkg:npm/nested@2"}]}]}`,
+		`{"spdxVersion":"SPDX-2.3","name":"app","packages":[{"name":"curl","versionInfo":"7.50","externalRefs":[{"referenceType":"purl","referenceLocator":"pkg:deb/debian/curl@7.50"}],"checksums":[{"algorithm":"SHA1","checksumValue":"AA"}]}]}`,
+		`{"bomFormat":"CycloneDX","components":null}`,
+		`{"spdxVersion":"SPDX-2.3","packages":[{}]}`,
+		`{}`,
+		`[]`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, err := sbom.Decode(data)
+		if err != nil {
+			return
+		}
+		for _, component := range doc.Components {
+			if purl, err := component.PackageURL(); err == nil {
+				doc.ComponentsByType(purl.Type)
+			}
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package sbom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/sbom"
+)
+
+func TestParsePackageURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		expected  sbom.PackageURL
+		canonical string
+		expectErr bool
+	}{
+		{
+			name:      "maven_with_qualifiers",
+			raw:       "pkg:maven/org.apache.commons/commons-lang3@3.12.0?type=jar&classifier=sources",
+			expected:  sbom.PackageURL{Type: "maven", Namespace: "org.apache.commons", Name: "commons-lang3", Version: "3.12.0", Qualifiers: map[string]string{"type": "jar", "classifier": "sources"}},
+			canonical: "pkg:maven/org.apache.commons/commons-lang3@3.12.0?classifier=sources&type=jar",
+		},
+		{
+			name:      "scoped_npm",
+			raw:       "pkg:npm/%40angular/core@16.0.0",
+			expected:  sbom.PackageURL{Type: "npm", Namespace: "@angular", Name: "core", Version: "16.0.0"},
+			canonical: "pkg:npm/%40angular/core@16.0.0",
+		},
+		{
+			name:      "golang_nested_namespace_and_subpath",
+			raw:       "pkg:golang/github.com/gorilla/mux@v1.8.0#pkg/router",
+			expected:  sbom.PackageURL{Type: "golang", Namespace: "github.com/gorilla", Name: "mux", Version: "v1.8.0", Subpath: "pkg/router"},
+			canonical: "pkg:golang/github.com/gorilla/mux@v1.8.0#pkg/router",
+		},
+		{
+			name:      "no_version",
+			raw:       "pkg:pypi/requests",
+			expected:  sbom.PackageURL{Type: "pypi", Name: "requests"},
+			canonical: "pkg:pypi/requests",
+		},
+		{name: "missing_scheme", raw: "maven/org/a@1", expectErr: true},
+		{name: "missing_name", raw: "pkg:npm/", expectErr: true},
+		{name: "bad_qualifier", raw: "pkg:npm/a@1?novalue", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			purl, err := sbom.ParsePackageURL(tt.raw)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected.Type, purl.Type)
+			assert.Equal(t, tt.expected.Namespace, purl.Namespace)
+			assert.Equal(t, tt.expected.Name, purl.Name)
+			assert.Equal(t, tt.expected.Version, purl.Version)
+			assert.Equal(t, tt.expected.Subpath, purl.Subpath)
+			if tt.expected.Qualifiers != nil {
+				assert.Equal(t, tt.expected.Qualifiers, purl.Qualifiers)
+			}
+			assert.Equal(t, tt.canonical, purl.String())
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("cyclonedx_nested_components", func(t *testing.T) {
+		doc, err := sbom.Decode([]byte(`{
+			"bomFormat": "CycloneDX",
+			"specVersion": "1.5",
+			"metadata": {"component": {"name": "vulnerable-app"}},
+			"components": [
+				{
+					"name": "commons-lang3", "group": "org.apache.commons", "version": "3.12.0",
+					"purl": "pkg:maven/org.apache.commons/commons-lang3@3.12.0",
+					"hashes": [{"alg": "SHA-256", "content": "ABCDEF"}],
+					"components": [{"name": "inner", "version": "1.0", "purl": "pkg:maven/org.example/inner@1.0"}]
+				}
+			]
+		}`))
+		require.NoError(t, err)
+		assert.Equal(t, sbom.FormatCycloneDX, doc.Format)
+		assert.Equal(t, "vulnerable-app", doc.Name)
+		require.Len(t, doc.Components, 2)
+		assert.Equal(t, "abcdef", doc.Components[0].Hashes["sha256"])
+		assert.Len(t, doc.ComponentsByType("maven"), 2)
+	})
+
+	t.Run("spdx_packages", func(t *testing.T) {
+		doc, err := sbom.Decode([]byte(`{
+			"spdxVersion": "SPDX-2.3",
+			"name": "vulnerable-app",
+			"packages": [
+				{
+					"name": "github.com/gorilla/mux", "versionInfo": "v1.8.0",
+					"externalRefs": [{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:golang/github.com/gorilla/mux@v1.8.0"}],
+					"checksums": [{"algorithm": "SHA256", "checksumValue": "1234"}]
+				}
+			]
+		}`))
+		require.NoError(t, err)
+		assert.Equal(t, sbom.FormatSPDX, doc.Format)
+		assert.Equal(t, "2.3", doc.SpecVersion)
+		require.Len(t, doc.Components, 1)
+		assert.Equal(t, "github.com/gorilla", doc.Components[0].Group)
+		assert.Equal(t, "1234", doc.Components[0].Hashes["sha256"])
+	})
+
+	t.Run("unknown_format", func(t *testing.T) {
+		_, err := sbom.Decode([]byte(`{"hello": "world"}`))
+		assert.ErrorIs(t, err, sbom.ErrUnknownFormat)
+	})
+}
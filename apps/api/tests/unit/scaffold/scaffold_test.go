@@ -0,0 +1,157 @@
+package scaffold
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/salman-frs/keystone/apps/api/internal/attestation"
+	"github.com/salman-frs/keystone/apps/api/internal/scaffold"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestInspectDetectsLanguageDockerfileAndRemote(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/widgets\n")
+	writeFile(t, filepath.Join(dir, "Dockerfile"), "FROM scratch\n")
+	writeFile(t, filepath.Join(dir, ".git", "config"), `[core]
+	bare = false
+[remote "upstream"]
+	url = https://github.com/other/fork.git
+[remote "origin"]
+	url = git@github.com:acme/widgets.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+`)
+
+	project, err := scaffold.Inspect(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, scaffold.LanguageGo, project.Language)
+	assert.True(t, project.Dockerfile)
+	assert.Equal(t, "acme/widgets", project.Repository())
+	assert.Equal(t, "main", project.DefaultBranch)
+}
+
+func TestInspectWithoutRemoteUsesDirectoryName(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "service")
+	writeFile(t, filepath.Join(dir, "requirements.txt"), "requests\n")
+
+	project, err := scaffold.Inspect(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, scaffold.LanguagePython, project.Language)
+	assert.False(t, project.Dockerfile)
+	assert.Equal(t, "service", project.Name)
+	assert.Empty(t, project.Repository())
+}
+
+func TestParseGitHubRemote(t *testing.T) {
+	tests := []struct {
+		remote string
+		owner  string
+		name   string
+		ok     bool
+	}{
+		{"https://github.com/acme/widgets.git", "acme", "widgets", true},
+		{"https://github.com/acme/widgets", "acme", "widgets", true},
+		{"git@github.com:acme/widgets.git", "acme", "widgets", true},
+		{"ssh://git@github.com/acme/widgets.git", "acme", "widgets", true},
+		{"https://gitlab.com/acme/widgets.git", "", "", false},
+		{"https://github.com/acme", "", "", false},
+		{"https://github.com/acme/widgets/tree/main", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remote, func(t *testing.T) {
+			owner, name, ok := scaffold.ParseGitHubRemote(tt.remote)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.owner, owner)
+			assert.Equal(t, tt.name, name)
+		})
+	}
+}
+
+func TestGeneratedFilesAreValidYAML(t *testing.T) {
+	for _, project := range []*scaffold.Project{
+		{Name: "widgets", Owner: "acme", Language: scaffold.LanguageGo, Dockerfile: true, DefaultBranch: "main"},
+		{Name: "widgets", Language: scaffold.LanguageNode, DefaultBranch: "trunk"},
+		{Name: "widgets", Language: scaffold.LanguageGeneric, DefaultBranch: "main"},
+	} {
+		files, err := scaffold.Generate(project)
+		require.NoError(t, err)
+		require.Len(t, files, 3)
+
+		for _, file := range files {
+			var document map[string]interface{}
+			assert.NoError(t, yaml.Unmarshal(file.Content, &document), "%s for %s", file.Path, project.Language)
+			assert.NotContains(t, string(file.Content), "[[", file.Path)
+		}
+	}
+}
+
+func TestGeneratedPolicyLoadsAsIdentityPolicy(t *testing.T) {
+	project := &scaffold.Project{Name: "widgets", Owner: "acme", Language: scaffold.LanguageGo, DefaultBranch: "trunk"}
+	files, err := scaffold.Generate(project)
+	require.NoError(t, err)
+
+	var policyFile *scaffold.File
+	for i := range files {
+		if files[i].Path == scaffold.PolicyPath {
+			policyFile = &files[i]
+		}
+	}
+	require.NotNil(t, policyFile)
+
+	var policy attestation.IdentityPolicy
+	decoder := yaml.NewDecoder(bytes.NewReader(policyFile.Content))
+	decoder.KnownFields(true)
+	require.NoError(t, decoder.Decode(&policy))
+
+	assert.Equal(t, attestation.IdentityPolicy{
+		Issuer:      attestation.GitHubActionsIssuer,
+		Repository:  "acme/widgets",
+		WorkflowRef: scaffold.WorkflowPath,
+		Branch:      "trunk",
+	}, policy)
+
+	// The workflow signs with the identity the policy accepts
+	identity := &attestation.CertificateIdentity{
+		Issuer:      attestation.GitHubActionsIssuer,
+		Repository:  "acme/widgets",
+		WorkflowRef: "acme/widgets/" + scaffold.WorkflowPath + "@refs/heads/trunk",
+		Ref:         "refs/heads/trunk",
+	}
+	assert.NoError(t, policy.Verify(identity))
+}
+
+func TestWriteRefusesToOverwriteWithoutForce(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, scaffold.ConfigPath), "existing\n")
+
+	files, err := scaffold.Generate(&scaffold.Project{Name: "widgets", Language: scaffold.LanguageGo, DefaultBranch: "main"})
+	require.NoError(t, err)
+
+	err = scaffold.Write(dir, files, false)
+	assert.True(t, errors.Is(err, scaffold.ErrFileExists))
+	// Nothing is written when any file exists
+	_, statErr := os.Stat(filepath.Join(dir, filepath.FromSlash(scaffold.WorkflowPath)))
+	assert.True(t, os.IsNotExist(statErr))
+
+	require.NoError(t, scaffold.Write(dir, files, true))
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Path)))
+		require.NoError(t, err)
+		assert.Equal(t, file.Content, content)
+	}
+}
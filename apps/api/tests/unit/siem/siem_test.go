@@ -0,0 +1,253 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+	"github.com/salman-frs/keystone/apps/api/internal/siem"
+)
+
+// testEvent is a fixed verification failure
+func testEvent(t *testing.T) events.Event {
+	data, err := json.Marshal(events.VerificationFailed{
+		Target:    "ghcr.io/owner/repo",
+		ErrorCode: "SIGN_056",
+		Message:   `workflow "deploy.yml" = not release.yml]`,
+	})
+	require.NoError(t, err)
+	return events.Event{
+		ID:     "0123abcd",
+		Type:   events.TypeVerificationFailed,
+		Source: "keystone-api",
+		Time:   time.Date(2026, 3, 4, 5, 6, 7, 890000000, time.UTC),
+		Data:   data,
+	}
+}
+
+func newExporter(t *testing.T, address, format string) *siem.Exporter {
+	config := siem.DefaultConfig(address)
+	config.Hostname = "worker-1"
+	config.Format = format
+	exporter, err := siem.NewExporter(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { exporter.Close() })
+	return exporter
+}
+
+func TestFormatRFC5424(t *testing.T) {
+	message := string(newExporter(t, "collector:6514", siem.FormatRFC5424).Format(testEvent(t)))
+
+	// log audit (13) * 8 + warning (4)
+	assert.Equal(t, `<108>1 2026-03-04T05:06:07.890000Z worker-1 keystone - verification.failed `+
+		`[keystone@32473 id="0123abcd" source="keystone-api" error_code="SIGN_056" `+
+		`message="workflow \"deploy.yml\" = not release.yml\]" target="ghcr.io/owner/repo"] `+
+		`Attestation verification failed`, message)
+}
+
+func TestFormatCEF(t *testing.T) {
+	message := string(newExporter(t, "collector:6514", siem.FormatCEF).Format(testEvent(t)))
+
+	assert.Equal(t, `<108>1 2026-03-04T05:06:07.890000Z worker-1 keystone - verification.failed - `+
+		`CEF:0|Keystone|Keystone|1.0|verification.failed|Attestation verification failed|7|`+
+		`rt=1772600767890 externalId=0123abcd deviceProcessName=keystone-api dvchost=worker-1 `+
+		`error_code=SIGN_056 message=workflow "deploy.yml" \= not release.yml] target=ghcr.io/owner/repo`, message)
+}
+
+func TestNewExporterRejectsUnknownFormats(t *testing.T) {
+	_, err := siem.NewExporter(siem.Config{Address: "collector:514", Format: "leef"})
+	assert.ErrorContains(t, err, "unknown syslog format")
+
+	_, err = siem.NewExporter(siem.Config{Address: "collector:514", Framing: "nul"})
+	assert.ErrorContains(t, err, "unknown syslog framing")
+
+	_, err = siem.NewExporter(siem.Config{})
+	assert.ErrorContains(t, err, "address is required")
+}
+
+// collector accepts syslog connections and reads octet-counted frames
+type collector struct {
+	listener net.Listener
+	frames   chan string
+}
+
+func newCollector(t *testing.T, config *tls.Config) *collector {
+	var listener net.Listener
+	var err error
+	if config != nil {
+		listener, err = tls.Listen("tcp", "127.0.0.1:0", config)
+	} else {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	c := &collector{listener: listener, frames: make(chan string, 16)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go c.read(conn)
+		}
+	}()
+	return c
+}
+
+// read parses "LEN SP MSG" frames until the connection closes
+func (c *collector) read(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		prefix, err := reader.ReadString(' ')
+		if err != nil {
+			return
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(prefix))
+		if err != nil {
+			return
+		}
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return
+		}
+		c.frames <- string(frame)
+	}
+}
+
+func (c *collector) next(t *testing.T) string {
+	select {
+	case frame := <-c.frames:
+		return frame
+	case <-time.After(5 * time.Second):
+		t.Fatal("collector received no frame")
+		return ""
+	}
+}
+
+func TestExporterSendsBusEventsOverTCP(t *testing.T) {
+	collector := newCollector(t, nil)
+	exporter := newExporter(t, collector.listener.Addr().String(), siem.FormatRFC5424)
+
+	bus := events.NewMemoryBus()
+	defer bus.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go exporter.Run(ctx, bus)
+
+	// Run subscribes asynchronously; publish until the exporter is listening
+	event, err := events.NewEvent(events.TypeAdminAction, "keystone-api", events.AdminAction{
+		Action: "identity_pin.reset", Target: "ghcr.io/owner/repo",
+	})
+	require.NoError(t, err)
+	var frame string
+	require.Eventually(t, func() bool {
+		require.NoError(t, bus.Publish(ctx, event))
+		select {
+		case frame = <-collector.frames:
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Contains(t, frame, "keystone - audit.admin_action [keystone@32473")
+	assert.Contains(t, frame, `action="identity_pin.reset"`)
+	assert.True(t, strings.HasPrefix(frame, "<109>1 "), frame) // log audit, notice
+
+	// Types outside the defaults are not exported
+	ignored, err := events.NewEvent(events.TypeJobRequested, "keystone-api", nil)
+	require.NoError(t, err)
+	require.NoError(t, bus.Publish(ctx, ignored))
+	select {
+	case frame := <-collector.frames:
+		if !strings.Contains(frame, "audit.admin_action") {
+			t.Fatalf("unexpected frame %q", frame)
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestExporterReconnectsAfterTheCollectorDropsTheConnection(t *testing.T) {
+	collector := newCollector(t, nil)
+	exporter := newExporter(t, collector.listener.Addr().String(), siem.FormatCEF)
+
+	require.NoError(t, exporter.Export(context.Background(), testEvent(t)))
+	assert.Contains(t, collector.next(t), "CEF:0|Keystone|")
+
+	require.NoError(t, exporter.Close())
+	require.NoError(t, exporter.Export(context.Background(), testEvent(t)))
+	assert.Contains(t, collector.next(t), "CEF:0|Keystone|")
+}
+
+func TestExporterSendsOverTLS(t *testing.T) {
+	cert, pool := selfSignedCertificate(t)
+	collector := newCollector(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	config := siem.DefaultConfig(collector.listener.Addr().String())
+	config.TLS = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+	exporter, err := siem.NewExporter(config)
+	require.NoError(t, err)
+	defer exporter.Close()
+
+	require.NoError(t, exporter.Export(context.Background(), testEvent(t)))
+	assert.Contains(t, collector.next(t), "verification.failed")
+
+	untrusted := siem.DefaultConfig(collector.listener.Addr().String())
+	untrusted.TLS = &tls.Config{RootCAs: x509.NewCertPool()}
+	rejected, err := siem.NewExporter(untrusted)
+	require.NoError(t, err)
+	defer rejected.Close()
+	assert.Error(t, rejected.Export(context.Background(), testEvent(t)))
+}
+
+func TestExporterUnreachableCollector(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	err = newExporter(t, address, siem.FormatRFC5424).Export(context.Background(), testEvent(t))
+	assert.ErrorContains(t, err, "unreachable")
+}
+
+// selfSignedCertificate issues a certificate for 127.0.0.1 and a pool trusting it
+func selfSignedCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "collector"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	parsed, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
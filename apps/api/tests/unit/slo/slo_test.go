@@ -0,0 +1,117 @@
+package slo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/clock"
+	"github.com/salman-frs/keystone/apps/api/internal/events"
+	"github.com/salman-frs/keystone/apps/api/internal/slo"
+)
+
+func newTracker(t *testing.T) (*slo.Tracker, *clock.Fake) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	config := slo.DefaultConfig()
+	config.Clock = fake
+	return slo.NewTracker(config), fake
+}
+
+func TestBurnRate(t *testing.T) {
+	tracker, fake := newTracker(t)
+
+	for i := 0; i < 96; i++ {
+		tracker.Record(slo.OperationVerify, 2*time.Second, nil)
+	}
+	for i := 0; i < 3; i++ {
+		tracker.Record(slo.OperationVerify, 12*time.Second, nil) // Over the 10s objective
+	}
+	tracker.Record(slo.OperationVerify, time.Second, errors.New("signature invalid"))
+	tracker.Record(slo.OperationSign, 25*time.Second, nil) // Within the 30s objective
+	tracker.Record("scan", time.Hour, nil)                 // No objective
+
+	// 4% bad against a 1% budget
+	rate, requests := tracker.BurnRate(slo.OperationVerify, time.Hour)
+	assert.InDelta(t, 4.0, rate, 0.01)
+	assert.Equal(t, int64(100), requests)
+
+	rate, _ = tracker.BurnRate(slo.OperationSign, time.Hour)
+	assert.Zero(t, rate)
+
+	fake.Advance(2 * time.Hour)
+	rate, requests = tracker.BurnRate(slo.OperationVerify, time.Hour)
+	assert.Zero(t, rate)
+	assert.Zero(t, requests)
+}
+
+func TestTrackerTiming(t *testing.T) {
+	tracker, fake := newTracker(t)
+
+	done := tracker.Start(slo.OperationSign)
+	fake.Advance(31 * time.Second)
+	done(nil)
+
+	rate, requests := tracker.BurnRate(slo.OperationSign, time.Hour)
+	assert.Equal(t, int64(1), requests)
+	assert.InDelta(t, 100.0, rate, 0.01)
+}
+
+func TestBurnRateAlerts(t *testing.T) {
+	tracker, fake := newTracker(t)
+	bus := events.NewMemoryBus()
+	defer bus.Close()
+
+	var mutex sync.Mutex
+	var received []events.SLOBurnRate
+	_, err := bus.Subscribe(events.TypeSLOBurnRate, func(ctx context.Context, event events.Event) error {
+		var payload events.SLOBurnRate
+		require.NoError(t, event.Decode(&payload))
+		mutex.Lock()
+		received = append(received, payload)
+		mutex.Unlock()
+		return nil
+	})
+	require.NoError(t, err)
+	alerts := func() []events.SLOBurnRate {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]events.SLOBurnRate(nil), received...)
+	}
+
+	// Too few requests to page on
+	for i := 0; i < 5; i++ {
+		tracker.Record(slo.OperationVerify, 0, errors.New("timeout"))
+	}
+	assert.Empty(t, tracker.Evaluate())
+
+	// A verification outage burns the budget far faster than 14.4x
+	for i := 0; i < 20; i++ {
+		tracker.Record(slo.OperationVerify, 0, errors.New("timeout"))
+	}
+	firing := tracker.Evaluate()
+	require.Len(t, firing, 2)
+	assert.Equal(t, slo.SeverityPage, firing[0].Rule.Severity)
+	assert.Equal(t, slo.SeverityTicket, firing[1].Rule.Severity)
+
+	require.NoError(t, tracker.Notify(context.Background(), bus, "worker-1"))
+	require.NoError(t, tracker.Notify(context.Background(), bus, "worker-1"))
+	require.Eventually(t, func() bool { return len(alerts()) == 2 }, time.Second, 10*time.Millisecond)
+	assert.True(t, alerts()[0].Firing)
+	assert.Equal(t, slo.OperationVerify, alerts()[0].Operation)
+
+	// Healthy traffic after recovery clears the short windows
+	fake.Advance(31 * time.Minute)
+	for i := 0; i < 20; i++ {
+		tracker.Record(slo.OperationVerify, time.Second, nil)
+	}
+	require.NoError(t, tracker.Notify(context.Background(), bus, "worker-1"))
+	require.Eventually(t, func() bool { return len(alerts()) == 4 }, time.Second, 10*time.Millisecond)
+	for _, alert := range alerts()[2:] {
+		assert.False(t, alert.Firing)
+	}
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+const migrationsDir = "../../../internal/storage/migrations"
+
+func TestMigrateWithLockConcurrentProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keystone.db")
+
+	// Each "process" gets its own connection pool to the shared file
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i, owner := range []string{"api", "worker-1", "worker-2"} {
+		db, err := storage.OpenDatabase(path)
+		require.NoError(t, err)
+		defer db.Close()
+
+		wg.Add(1)
+		go func(i int, owner string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			errs[i] = storage.NewMigrationManager(db, migrationsDir).MigrateWithLock(ctx, owner, time.Minute)
+		}(i, owner)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	db, err := storage.OpenDatabase(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	manager := storage.NewMigrationManager(db, migrationsDir)
+	migrations, err := manager.LoadMigrations()
+	require.NoError(t, err)
+	applied, err := manager.GetAppliedMigrations()
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrations))
+
+	var locks int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM schema_migrations_lock").Scan(&locks))
+	assert.Zero(t, locks, "lock must be released after migrating")
+}
+
+func TestMigrateWithLockStaleLock(t *testing.T) {
+	db, err := storage.OpenDatabase(filepath.Join(t.TempDir(), "keystone.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	manager := storage.NewMigrationManager(db, migrationsDir)
+	require.NoError(t, manager.Initialize())
+	_, err = db.Exec("INSERT INTO schema_migrations_lock (id, owner, acquired_at) VALUES (1, 'crashed', ?)",
+		time.Now().UTC().Add(-time.Hour))
+	require.NoError(t, err)
+
+	t.Run("held_lock_blocks", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+		assert.Error(t, manager.MigrateWithLock(ctx, "api", 2*time.Hour))
+	})
+
+	t.Run("stale_lock_taken_over", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, manager.MigrateWithLock(ctx, "api", time.Minute))
+	})
+}
+
+func TestWaitForSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keystone.db")
+	apiDB, err := storage.OpenDatabase(path)
+	require.NoError(t, err)
+	defer apiDB.Close()
+	workerDB, err := storage.OpenDatabase(path)
+	require.NoError(t, err)
+	defer workerDB.Close()
+
+	worker := storage.NewMigrationManager(workerDB, migrationsDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, worker.WaitForSchema(ctx, 10*time.Millisecond), "schema is not migrated yet")
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		storage.NewMigrationManager(apiDB, migrationsDir).MigrateWithLock(context.Background(), "api", time.Minute)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, worker.WaitForSchema(ctx, 10*time.Millisecond))
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/salman-frs/keystone/apps/api/internal/storage"
+)
+
+func FuzzParseMigration(f *testing.F) {
+	// Every shipped migration is a seed
+	files, err := filepath.Glob(filepath.Join(migrationsDir, "*.sql"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(filepath.Base(file), content)
+	}
+	f.Add("002_no_sections.sql", []byte("CREATE TABLE t (id INTEGER);"))
+	f.Add("-1_negative.sql", []byte("-- +migrate Up\nSELECT 1;"))
+	f.Add("noversion.sql", []byte(""))
+	f.Add("003_crlf.sql", []byte("-- Description: x\r\n-- +migrate Up\r\nSELECT 1;\r\n-- +migrate Down\r\n"))
+
+	f.Fuzz(func(t *testing.T, filename string, content []byte) {
+		migration, err := storage.ParseMigration(filename, content)
+		if err != nil {
+			return
+		}
+
+		if migration.Version < 1 {
+			t.Fatalf("migration %q parsed with version %d", filename, migration.Version)
+		}
+		if len(migration.Checksum) != 64 {
+			t.Fatalf("migration %q has checksum %q", filename, migration.Checksum)
+		}
+		for _, section := range []string{migration.UpSQL, migration.DownSQL} {
+			for _, line := range strings.Split(section, "\n") {
+				if strings.HasPrefix(strings.TrimSpace(line), "-- +migrate") {
+					t.Fatalf("migration %q kept a section marker in its SQL: %q", filename, line)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/salman-frs/keystone/apps/api/internal/webhooks"
+)
+
+const secret = "It's a Secret to Everybody"
+
+// deliver posts a payload to the receiver as GitHub would, signed with key
+func deliver(receiver http.Handler, event, payload, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/github", strings.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	req.Header.Set("X-Hub-Signature-256", webhooks.Sign([]byte(key), []byte(payload)))
+	recorder := httptest.NewRecorder()
+	receiver.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestVerify(t *testing.T) {
+	// The example from GitHub's documentation
+	signature := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	require.NoError(t, webhooks.Verify([]byte(secret), []byte("Hello, World!"), signature))
+	assert.Equal(t, signature, webhooks.Sign([]byte(secret), []byte("Hello, World!")))
+
+	assert.ErrorIs(t, webhooks.Verify([]byte(secret), []byte("Hello, World?"), signature), webhooks.ErrInvalidSignature)
+	assert.ErrorIs(t, webhooks.Verify([]byte(secret), []byte("Hello, World!"), strings.TrimPrefix(signature, "sha256=")), webhooks.ErrInvalidSignature)
+	assert.ErrorIs(t, webhooks.Verify([]byte(secret), []byte("Hello, World!"), "sha256=zz"), webhooks.ErrInvalidSignature)
+
+	_, err := webhooks.NewReceiver("")
+	assert.Error(t, err)
+}
+
+func TestReceiverDispatchesPackages(t *testing.T) {
+	receiver, err := webhooks.NewReceiver(secret)
+	require.NoError(t, err)
+	var images []string
+	receiver.OnPackage(func(ctx context.Context, event *webhooks.PackageEvent) error {
+		assert.Equal(t, "72d3162e-cc78-11e3-81ab-4c9367dc0958", event.Delivery)
+		assert.Equal(t, webhooks.PackagePublished, event.Action)
+		assert.Equal(t, "acme/widgets", event.Repository.FullName)
+		images = append(images, event.Image())
+		return nil
+	})
+
+	payload := `{"action":"published","repository":{"full_name":"acme/widgets","pushed_at":1700000000},
+		"package":{"name":"widgets","package_type":"container","package_version":{"version":"sha256:aaaa",
+		"package_url":"ghcr.io/acme/widgets:v1.0.0","container_metadata":{"tag":{"name":"v1.0.0","digest":"sha256:abcd"}}}}}`
+	assert.Equal(t, http.StatusNoContent, deliver(receiver, webhooks.EventPackage, payload, secret).Code)
+	assert.Equal(t, http.StatusNoContent, deliver(receiver, webhooks.EventRegistryPackage, payload, secret).Code)
+	assert.Equal(t, []string{"ghcr.io/acme/widgets@sha256:abcd", "ghcr.io/acme/widgets@sha256:abcd"}, images)
+
+	// Forged and unhandled deliveries don't reach handlers
+	assert.Equal(t, http.StatusUnauthorized, deliver(receiver, webhooks.EventPackage, payload, "guess").Code)
+	assert.Equal(t, http.StatusAccepted, deliver(receiver, webhooks.EventPing, `{"zen":"Keep it logically awesome."}`, secret).Code)
+	assert.Equal(t, http.StatusAccepted, deliver(receiver, webhooks.EventPush, `{"ref":"refs/heads/main"}`, secret).Code)
+	assert.Equal(t, http.StatusBadRequest, deliver(receiver, webhooks.EventPackage, `{"package":[]}`, secret).Code)
+	assert.Len(t, images, 2)
+}
+
+func TestReceiverReportsHandlerFailures(t *testing.T) {
+	receiver, err := webhooks.NewReceiver(secret)
+	require.NoError(t, err)
+	var ran []string
+	receiver.OnWorkflowRun(func(ctx context.Context, event *webhooks.WorkflowRunEvent) error {
+		ran = append(ran, event.WorkflowRun.Conclusion)
+		return errors.New("bus unavailable")
+	})
+	receiver.OnWorkflowRun(func(ctx context.Context, event *webhooks.WorkflowRunEvent) error {
+		ran = append(ran, "second")
+		return nil
+	})
+	receiver.OnPush(func(ctx context.Context, event *webhooks.PushEvent) error {
+		assert.Equal(t, "refs/heads/main", event.Ref)
+		require.NotNil(t, event.HeadCommit)
+		assert.Equal(t, "abc123", event.HeadCommit.ID)
+		return nil
+	})
+
+	recorder := deliver(receiver, webhooks.EventWorkflowRun, `{"action":"completed","workflow_run":{"conclusion":"success"}}`, secret)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code, "failed deliveries can be redelivered")
+	assert.Equal(t, []string{"success"}, ran)
+
+	recorder = deliver(receiver, webhooks.EventPush, `{"ref":"refs/heads/main","head_commit":{"id":"abc123"},"repository":{"created_at":1700000000}}`, secret)
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/webhooks/github", nil)
+	recorder = httptest.NewRecorder()
+	receiver.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestPackageImage(t *testing.T) {
+	var event webhooks.PackageEvent
+	event.Package.PackageType = "container"
+	event.Package.PackageVersion.Version = "sha256:abcd"
+	event.Package.PackageVersion.PackageURL = "ghcr.io/acme/widgets@sha256:abcd"
+	assert.Equal(t, "ghcr.io/acme/widgets@sha256:abcd", event.Image())
+
+	event.Package.PackageVersion.PackageURL = "localhost:5000/acme/widgets"
+	assert.Equal(t, "localhost:5000/acme/widgets@sha256:abcd", event.Image())
+
+	event.Package.PackageType = "npm"
+	assert.Empty(t, event.Image())
+}